@@ -0,0 +1,732 @@
+// Package app assembles the HTTP API and its background work from injected dependencies.
+// main builds the concrete implementations from config; tests pass the in-memory store and fakes.
+package app
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"log"
+	"maps"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/kevinaaaquil/books/backend/config"
+	"github.com/kevinaaaquil/books/backend/events"
+	"github.com/kevinaaaquil/books/backend/handlers"
+	"github.com/kevinaaaquil/books/backend/jobs"
+	"github.com/kevinaaaquil/books/backend/logging"
+	"github.com/kevinaaaquil/books/backend/middleware"
+	"github.com/kevinaaaquil/books/backend/models"
+	"github.com/kevinaaaquil/books/backend/notify"
+	"github.com/kevinaaaquil/books/backend/search"
+	"github.com/kevinaaaquil/books/backend/service"
+	"github.com/kevinaaaquil/books/backend/store"
+	"github.com/kevinaaaquil/books/backend/systemmail"
+	"github.com/kevinaaaquil/books/backend/telegram"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Deps are the implementations the app is built on. Store is required; nil Mailer, Metadata and Clock
+// default to iCloud SMTP, Google Books and the system clock.
+type Deps struct {
+	Store        store.Store
+	Storage      service.ObjectStore   // nil disables uploads, downloads and backups
+	LocalStorage *service.LocalStorage // set when Storage is local; its signed URLs are served under /api/storage
+	Signer       *service.URLSigner    // nil = derived from cfg.JWTSecret
+	Mailer       service.Mailer
+	SystemMailer service.Mailer           // invites, password resets and admin notifications; nil disables them
+	SMTPPool     *service.SMTPPool        // the connections the SMTP mailers reuse, reported to admins; nil when they don't
+	Drives       map[string]service.Drive // cloud drives books can be sent to (and imported from, if they are service.DriveSources), by models.Target* kind
+	Converter    service.Converter        // converts books for devices; nil sends only stored formats
+	Speaker      service.Speaker          // reads EPUBs aloud; nil disables read-aloud
+	Classifier   service.Classifier       // suggests categories for books without any; nil disables suggestions
+	Embedder     service.Embedder         // embeds books for semantic search; nil disables it
+	Assistant    service.Assistant        // answers questions about the library; nil disables it
+	Telegram     *service.TelegramClient  // the Telegram bot's API client; nil disables the bot
+	Prices       []service.PriceProvider  // stores wishlist items' prices are watched at; none disables price watches
+	Metadata     service.MetadataProvider
+	Documents    service.DOIProvider  // looks up papers and theses by DOI; nil disables DOI lookups
+	ArXiv        *service.ArXiv       // papers imported by arXiv ID; nil = arxiv.org
+	HTTPClient   *http.Client         // requests to third-party APIs and cover images; nil = service.DefaultOutbound
+	RequestLog   logging.Sink         // where requests are logged; nil = stdout
+	Reporter     logging.Reporter     // where panics and 5xx answers are reported; nil only logs panics
+	Tracer       trace.TracerProvider // traces requests (see tracing.New); nil traces nothing
+	Events       events.Sink          // where domain events are exported; nil exports none
+	JobBroker    jobs.Broker          // announces queued jobs across instances; nil finds them by polling the store
+	Clock        service.Clock
+	Web          fs.FS // the frontend's static export, served outside /api; nil serves the API only
+	// MigrationTarget is where the migrate-storage job copies Storage's files (see config.StorageTarget); nil
+	// disables it.
+	MigrationTarget service.ObjectStore
+}
+
+// App is the configured API.
+type App struct {
+	cfg     *config.Config
+	deps    Deps
+	router  http.Handler
+	admin   *handlers.AdminHandler
+	books   *handlers.BooksHandler
+	upload  *handlers.UploadHandler
+	imports *handlers.ImportsHandler
+	peers   *handlers.SyncPeersHandler
+	jobs    *jobs.Runner
+	queue   *jobs.Queue
+	search  *search.Index
+	vectors *search.Vectors // nil without Deps.Embedder
+	bot     *telegram.Bot   // nil without Deps.Telegram
+}
+
+// New prepares the database (indexes, bootstrap admin and guest users) and builds the handlers and routes.
+func New(ctx context.Context, cfg *config.Config, deps Deps) (*App, error) {
+	if deps.Store == nil {
+		return nil, errors.New("app: Store is required")
+	}
+	if deps.Signer == nil {
+		deps.Signer = service.NewURLSigner(cfg.JWTSecret)
+	}
+	if deps.Mailer == nil {
+		deps.Mailer = service.SMTPMailer{Pool: deps.SMTPPool}
+	}
+	if deps.RequestLog == nil {
+		deps.RequestLog = logging.NewWriter(os.Stdout)
+	}
+	if deps.HTTPClient == nil {
+		deps.HTTPClient = service.DefaultOutbound
+	}
+	if deps.Events == nil {
+		deps.Events = events.Multi{}
+	}
+	if deps.Metadata == nil {
+		deps.Metadata = service.GoogleBooks{Timeout: cfg.MetadataTimeout, Client: deps.HTTPClient}
+	}
+	if deps.Clock == nil {
+		deps.Clock = service.SystemClock{}
+	}
+
+	db := deps.Store
+	if err := db.EnsureEmailConfigIndex(ctx); err != nil {
+		return nil, err
+	}
+	// A read-only replica gets its users from the primary.
+	if !cfg.ReadOnly {
+		// If users collection is empty, create admin user from env (once); after that only the database is used for login.
+		if err := seedBootstrapUser(ctx, db, cfg.AuthEmail, cfg.AuthPass); err != nil {
+			return nil, err
+		}
+		// Ensure at least one guest user exists for "View as guest" on login page.
+		if err := seedGuestUser(ctx, db); err != nil {
+			return nil, err
+		}
+	}
+
+	var systemMail *systemmail.Service
+	if deps.SystemMailer != nil {
+		var err error
+		systemMail, err = systemmail.New(deps.SystemMailer, cfg.SystemMailFrom, cfg.AppURL, cfg.SystemMailTemplateDir, db, deps.Clock)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	a := &App{cfg: cfg, deps: deps, jobs: jobs.NewRunner(db), search: search.New()}
+	if deps.Embedder != nil {
+		a.vectors = search.NewVectors()
+	}
+	a.queue = jobs.NewQueue(db, a.jobs.Instance, cfg.JobWorkers, cfg.JobMaxAttempts, cfg.JobRetryBackoff)
+	a.queue.Kinds, a.queue.Broker = cfg.JobQueueKinds, deps.JobBroker
+	a.admin = &handlers.AdminHandler{
+		DB:          db,
+		Storage:     deps.Storage,
+		Jobs:        a.jobs,
+		Notify:      &notify.Service{DB: db, Mail: systemMail},
+		Clock:       deps.Clock,
+		Search:      a.search,
+		SendLimits:  cfg.SendLimits,
+		RateLimiter: middleware.NewRateLimiter(cfg.RateLimits, cfg.JWTSecret),
+		SMTPPool:    deps.SMTPPool,
+		Metadata:    deps.Metadata,
+		Prices:      deps.Prices,
+		Classifier:  deps.Classifier,
+		Embedder:    deps.Embedder,
+		Vectors:     a.vectors,
+		Schedules: map[string]string{
+			jobs.TypeCloudImport:     cfg.ImportSchedule,
+			jobs.TypeFederation:      cfg.FederationSchedule,
+			jobs.TypeRecommendations: cfg.RecommendationsSchedule,
+			jobs.TypeNewReleases:     cfg.NewReleasesSchedule,
+			jobs.TypeDigest:          cfg.DigestSchedule,
+			jobs.TypePriceWatch:      cfg.PriceWatchSchedule,
+			jobs.TypeStorageAlerts:   storageAlertSchedule(cfg),
+			jobs.TypeColdStorage:     coldStorageSchedule(cfg),
+			jobs.TypeTelemetry:       telemetrySchedule(cfg),
+		},
+		MigrationTarget: deps.MigrationTarget,
+		ColdStorage: jobs.ColdStorageOptions{
+			AfterMonths: cfg.ColdStorageAfterMonths,
+			Class:       cfg.ColdStorageClass,
+			Lifecycle:   cfg.ColdStorageLifecycle,
+		},
+		Quotas: jobs.StorageQuotas{
+			StorageBytes:         cfg.StorageQuotaBytes,
+			MonthlyDownloadBytes: cfg.DownloadQuotaBytes,
+			AlertPercent:         cfg.StorageAlertPercent,
+		},
+		Backup: handlers.BackupSettings{
+			Schedule:   cfg.BackupSchedule,
+			KeepDaily:  cfg.BackupKeepDaily,
+			KeepWeekly: cfg.BackupKeepWeekly,
+		},
+	}
+	settings := &handlers.SettingsHandler{DB: db, Clock: deps.Clock}
+	a.books = &handlers.BooksHandler{
+		DB:                        db,
+		Storage:                   deps.Storage,
+		Metadata:                  deps.Metadata,
+		Documents:                 deps.Documents,
+		Mailer:                    deps.Mailer,
+		Clock:                     deps.Clock,
+		EncKey:                    cfg.EmailConfigEncryptionKey,
+		FilenameTemplate:          cfg.DownloadFilenameTemplate,
+		StreamDownloads:           cfg.DownloadMode == config.DownloadModeStream,
+		Signer:                    deps.Signer,
+		SendLimits:                cfg.SendLimits,
+		RequireKindleVerification: cfg.RequireKindleVerification,
+		Drives:                    deps.Drives,
+		Converter:                 deps.Converter,
+		Speaker:                   deps.Speaker,
+		Classifier:                deps.Classifier,
+		Embedder:                  deps.Embedder,
+		Vectors:                   a.vectors,
+		Assistant:                 deps.Assistant,
+		PreviewWords:              cfg.PreviewWords,
+		OptimizeMaxImagePx:        cfg.OptimizeMaxImagePx,
+		Search:                    a.search,
+		RefreshTimeout:            cfg.MetadataRefreshTimeout,
+		DownloadURLExpiry:         cfg.DownloadURLExpiry,
+		Settings:                  settings,
+		GraphMaxBooks:             cfg.GraphExportMaxBooks,
+		RestoreDays:               cfg.ColdStorageRestoreDays,
+		BundleMaxBytes:            cfg.BundleMaxBytes,
+		Queue:                     a.queue,
+		Events:                    deps.Events,
+	}
+	a.queue.Handle(handlers.JobSend, a.books.RunSend)
+	a.queue.Handle(handlers.JobReadAloud, a.books.RunReadAloud)
+	a.upload = &handlers.UploadHandler{
+		DB:        db,
+		Storage:   deps.Storage,
+		Metadata:  deps.Metadata,
+		Documents: deps.Documents,
+		Clock:     deps.Clock,
+		MaxBytes:  cfg.MaxUploadMB * 1024 * 1024,
+		Search:    a.search,
+		Client:    deps.HTTPClient,
+		Events:    deps.Events,
+		TempDir:   cfg.UploadTempDir,
+		Queue:     a.queue,
+	}
+	if deps.Classifier != nil {
+		a.queue.Handle(handlers.JobClassify, a.books.RunClassify)
+	}
+	if deps.Embedder != nil {
+		a.queue.Handle(handlers.JobEmbed, a.books.RunEmbed)
+	}
+	var lookup *handlers.LookupHandler
+	if cfg.PublicLookup {
+		lookup = &handlers.LookupHandler{Metadata: deps.Metadata, Clock: deps.Clock, Timeout: cfg.MetadataRefreshTimeout, CacheTTL: cfg.LookupCacheTTL}
+	}
+	importSources := map[string]service.DriveSource{}
+	for kind, d := range deps.Drives {
+		if s, ok := d.(service.DriveSource); ok {
+			importSources[kind] = s
+		}
+	}
+	a.imports = &handlers.ImportsHandler{
+		DB:        db,
+		Sources:   importSources,
+		Clock:     deps.Clock,
+		EncKey:    cfg.EmailConfigEncryptionKey,
+		JWTSecret: cfg.JWTSecret,
+		APIURL:    cfg.APIURL,
+		AppURL:    cfg.AppURL,
+		Jobs:      a.jobs,
+		Ingest:    a.upload.Ingest,
+		MaxBytes:  a.upload.MaxBytes,
+	}
+	a.peers = &handlers.SyncPeersHandler{
+		DB:       db,
+		Clock:    deps.Clock,
+		EncKey:   cfg.EmailConfigEncryptionKey,
+		Jobs:     a.jobs,
+		Client:   &http.Client{Timeout: 10 * time.Minute},
+		Ingest:   a.upload.Ingest,
+		MaxBytes: a.upload.MaxBytes,
+	}
+	var s3Events *handlers.S3EventsHandler
+	if cfg.S3EventsPrefix != "" {
+		if deps.Storage == nil {
+			log.Println("warning: S3_EVENTS_PREFIX set but storage is not configured; S3 events disabled")
+		} else {
+			s3Events = &handlers.S3EventsHandler{
+				DB:         db,
+				Storage:    deps.Storage,
+				SNS:        &service.SNSVerifier{Client: &http.Client{Timeout: 30 * time.Second}},
+				Bucket:     cfg.S3Bucket,
+				Prefix:     cfg.S3EventsPrefix,
+				Secret:     cfg.S3EventsSecret,
+				TopicARN:   cfg.S3EventsTopicARN,
+				UploadedBy: cfg.S3EventsUploadedBy,
+				MaxBytes:   a.upload.MaxBytes,
+				Ingest:     a.upload.Ingest,
+			}
+		}
+	}
+	clipClient := service.PublicHTTPClient(10 * time.Minute)
+	if cfg.ClipAllowPrivateURLs {
+		clipClient = &http.Client{Timeout: 10 * time.Minute}
+	}
+	arxiv := service.ArXiv{Timeout: cfg.MetadataRefreshTimeout, Client: deps.HTTPClient}
+	if deps.ArXiv != nil {
+		arxiv = *deps.ArXiv
+	}
+	telegramLinks := &handlers.TelegramHandler{
+		DB:          db,
+		Clock:       deps.Clock,
+		JWTSecret:   cfg.JWTSecret,
+		Enabled:     deps.Telegram != nil,
+		BotUsername: cfg.TelegramBotUsername,
+	}
+	capabilities := handlers.Capabilities{
+		Upload:                    deps.Storage != nil && !cfg.ReadOnly,
+		UploadFormats:             models.BookFormats,
+		MaxUploadMB:               cfg.MaxUploadMB,
+		Search:                    true,
+		Previews:                  cfg.PreviewWords > 0,
+		Conversion:                deps.Converter != nil,
+		ReadAloud:                 deps.Speaker != nil && deps.Storage != nil,
+		CategorySuggestions:       deps.Classifier != nil,
+		SemanticSearch:            deps.Embedder != nil,
+		Assistant:                 deps.Assistant != nil,
+		Drives:                    append([]string{}, slices.Sorted(maps.Keys(deps.Drives))...),
+		RequireKindleVerification: cfg.RequireKindleVerification,
+		Lookup:                    cfg.PublicLookup,
+		PriceWatch:                len(deps.Prices) > 0,
+		ReadOnly:                  cfg.ReadOnly,
+		GuestLoginPoWBits:         cfg.GuestLoginPoWBits,
+	}
+	// Set even without TELEMETRY, so admins can preview what would be reported.
+	a.admin.Telemetry = jobs.TelemetryOptions{Version: cfg.Revision, Features: capabilities.Enabled(), Client: deps.HTTPClient}
+	if cfg.Telemetry {
+		a.admin.Telemetry.URL = cfg.TelemetryURL
+	}
+	a.router = a.routes(handlerSet{
+		auth: &handlers.AuthHandler{
+			DB:           db,
+			JWTSecret:    cfg.JWTSecret,
+			AccessTTL:    cfg.AccessTokenTTL,
+			RefreshTTL:   cfg.RefreshTokenTTL,
+			Clock:        deps.Clock,
+			SystemMail:   systemMail,
+			ReadOnly:     cfg.ReadOnly,
+			GuestPoWBits: cfg.GuestLoginPoWBits,
+		},
+		upload: a.upload,
+		books:  a.books,
+		users:  &handlers.UsersHandler{DB: db, Clock: deps.Clock, JWTSecret: cfg.JWTSecret, SystemMail: systemMail},
+		emailConfig: &handlers.EmailConfigHandler{
+			DB:            db,
+			EncKey:        cfg.EmailConfigEncryptionKey,
+			Mailer:        deps.Mailer,
+			Clock:         deps.Clock,
+			KindleDomains: cfg.KindleDomains,
+		},
+		admin:         a.admin,
+		notifications: &handlers.NotificationsHandler{DB: db},
+		queuedJobs:    &handlers.QueuedJobsHandler{DB: db},
+		targets: &handlers.TargetsHandler{
+			DB:        db,
+			Mailer:    deps.Mailer,
+			Clock:     deps.Clock,
+			EncKey:    cfg.EmailConfigEncryptionKey,
+			JWTSecret: cfg.JWTSecret,
+			Drives:    deps.Drives,
+			APIURL:    cfg.APIURL,
+			AppURL:    cfg.AppURL,
+			Imports:   a.imports,
+		},
+		imports:         a.imports,
+		peers:           a.peers,
+		s3Events:        s3Events,
+		devices:         &handlers.DevicesHandler{DB: db, Clock: deps.Clock},
+		collections:     &handlers.CollectionsHandler{DB: db, Clock: deps.Clock},
+		progress:        &handlers.ProgressHandler{DB: db, Clock: deps.Clock, AppURL: cfg.AppURL},
+		recommendations: &handlers.RecommendationsHandler{DB: db, Clock: deps.Clock},
+		newReleases:     &handlers.NewReleasesHandler{DB: db},
+		blocklist: &handlers.BlocklistHandler{
+			DB:        db,
+			Clock:     deps.Clock,
+			Blocklist: middleware.NewBlocklist(db),
+			Burst:     middleware.NewBurstGuard(cfg.BurstLimit, cfg.BurstWindow, cfg.BurstPenalty, cfg.JWTSecret),
+		},
+		capabilities: &handlers.CapabilitiesHandler{Capabilities: capabilities},
+		settings:     settings,
+		telegram:     telegramLinks,
+		lookup:       lookup,
+		apiKeys:      &handlers.APIKeysHandler{DB: db, Clock: deps.Clock},
+		wishlist:     &handlers.WishlistHandler{DB: db, PriceWatch: len(deps.Prices) > 0},
+		stats:        &handlers.StatsHandler{DB: db, Settings: settings, Clock: deps.Clock},
+		clip: &handlers.ClipHandler{
+			DB:            db,
+			Metadata:      deps.Metadata,
+			Clock:         deps.Clock,
+			Client:        clipClient,
+			Ingest:        a.upload.Ingest,
+			MaxBytes:      a.upload.MaxBytes,
+			LookupTimeout: cfg.MetadataRefreshTimeout,
+		},
+		arxiv:     &handlers.ArXivHandler{DB: db, ArXiv: arxiv, Ingest: a.upload.Ingest, MaxBytes: a.upload.MaxBytes},
+		purchases: &handlers.PurchasesHandler{DB: db, Clock: deps.Clock},
+	})
+	if deps.Telegram != nil {
+		a.bot = &telegram.Bot{Client: deps.Telegram, API: a.router, DB: db, JWTSecret: cfg.JWTSecret, Clock: deps.Clock}
+	}
+	return a, nil
+}
+
+// Handler returns the API's router.
+func (a *App) Handler() http.Handler {
+	return a.router
+}
+
+// Run serves the API on cfg.ListenAddrs, with the database health monitor, scheduled backups and imports, the watch folder
+// and the Telegram bot, until ctx is cancelled; then it shuts the server down gracefully, giving requests and jobs up to cfg.ShutdownTimeout to
+// finish (jobs are cancelled and save their progress; interrupted ones resume at the next start). It returns
+// early only if the server fails to start or one of its listeners fails.
+// The search index is built in the background at startup, once storage has connected; until it is, searches match only new uploads. When
+// the store reports changes (see store.BookWatcher), edits made outside the app reach the index as they happen.
+func (a *App) Run(ctx context.Context) error {
+	addrs := a.cfg.ListenAddrs
+	if len(addrs) == 0 {
+		addrs = []string{":" + a.cfg.Port}
+	}
+	listeners, err := listen(addrs, a.cfg.ListenSocketMode)
+	if err != nil {
+		return err
+	}
+	go a.deps.Store.MonitorHealth(ctx, 5*time.Second)
+	if s, ok := a.deps.Storage.(*service.LazyStorage); ok {
+		go s.Monitor(ctx, a.cfg.StorageCheckInterval)
+	}
+	if w, ok := a.deps.Store.(store.BookWatcher); ok {
+		go a.watchBooks(ctx, w)
+	}
+	// Startup work that reads book files waits for storage to connect: the index would lack EPUB text, and a
+	// resumed storage check would find every file missing. The index waits only so long, as search can't wait.
+	go func() {
+		connected := a.awaitStorage(ctx, a.cfg.StorageCheckInterval)
+		if _, err := a.jobs.Start(jobs.TypeReindexSearch, "startup", jobs.ReindexSearch(a.deps.Store, a.deps.Storage, a.search)); err != nil {
+			log.Printf("search index: %v", err)
+		}
+		if !a.cfg.ReadOnly && (connected || a.awaitStorage(ctx, 0)) {
+			a.resumeJobs(ctx)
+		}
+	}()
+	if a.cfg.ReadOnly {
+		log.Println("read-only replica: writes are refused; the job queue, schedules, interrupted jobs, the Telegram bot and the watch folder are off")
+	} else {
+		a.runBackground(ctx)
+	}
+
+	server := &http.Server{Handler: a.router}
+	errc := make(chan error, len(listeners))
+	for _, l := range listeners {
+		log.Println("server listening on " + listenerName(l))
+		go func() { errc <- server.Serve(l) }()
+	}
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), a.cfg.ShutdownTimeout)
+	defer cancel()
+	jobsDone := make(chan error, 1)
+	go func() { jobsDone <- a.jobs.Shutdown(shutdownCtx) }()
+	queueDone := make(chan error, 1)
+	go func() { queueDone <- a.queue.Shutdown(shutdownCtx) }()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Println("shutdown:", err)
+	}
+	for _, done := range []chan error{jobsDone, queueDone} {
+		if err := <-done; err != nil {
+			log.Println("shutdown:", err)
+		}
+	}
+	return nil
+}
+
+// RunWorker runs the instance as a dedicated worker until ctx is cancelled: the job queue's workers, and the admin
+// jobs a shutdown interrupted (file info backfills, storage checks and migrations), which it resumes like Run does.
+// It serves nothing and runs no schedules, bot or watch folder, so CPU-heavy work (sends and the conversions in
+// them) scales apart from the API: API instances queue the jobs, with JOB_WORKERS=0 if they should run none, and
+// workers sharing their store (and JOB_QUEUE_BROKER) run them. On shutdown, the attempts and jobs in progress get up
+// to cfg.ShutdownTimeout to finish.
+func (a *App) RunWorker(ctx context.Context) error {
+	if a.cfg.ReadOnly || a.cfg.JobWorkers == 0 {
+		return errors.New("a worker needs JOB_WORKERS above 0 and READ_ONLY off")
+	}
+	go a.deps.Store.MonitorHealth(ctx, 5*time.Second)
+	if s, ok := a.deps.Storage.(*service.LazyStorage); ok {
+		go s.Monitor(ctx, a.cfg.StorageCheckInterval)
+	}
+	go func() {
+		if a.awaitStorage(ctx, 0) {
+			a.resumeJobs(ctx)
+		}
+	}()
+	a.queue.Start()
+	log.Printf("worker: running %d job queue worker(s) for %s jobs", a.cfg.JobWorkers, kindsName(a.cfg.JobQueueKinds))
+	<-ctx.Done()
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), a.cfg.ShutdownTimeout)
+	defer cancel()
+	jobsDone := make(chan error, 1)
+	go func() { jobsDone <- a.jobs.Shutdown(shutdownCtx) }()
+	if err := a.queue.Shutdown(shutdownCtx); err != nil {
+		log.Println("shutdown:", err)
+	}
+	if err := <-jobsDone; err != nil {
+		log.Println("shutdown:", err)
+	}
+	return nil
+}
+
+func kindsName(kinds []string) string {
+	if len(kinds) == 0 {
+		return "all"
+	}
+	return strings.Join(kinds, ", ")
+}
+
+// runBackground starts the job queue's workers, the scheduled jobs, the Telegram bot and the watch folder, all of which write.
+func (a *App) runBackground(ctx context.Context) {
+	a.queue.Start()
+	// With several instances, only the leader runs schedules.
+	leader := jobs.NewLeader(a.deps.Store, "scheduler", a.jobs.Instance)
+	go leader.Run(ctx)
+	if a.cfg.BackupSchedule != "" {
+		if a.deps.Storage == nil {
+			log.Println("warning: BACKUP_SCHEDULE set but storage is not configured; scheduled backups disabled")
+		} else {
+			go jobs.RunScheduled(ctx, jobs.TypeBackup, a.cfg.BackupSchedule, leader, func() {
+				if _, err := a.jobs.Start(jobs.TypeBackup, "scheduler", a.admin.BackupJob("scheduled")); err != nil {
+					log.Printf("scheduled backup: %v", err)
+				}
+			})
+		}
+	}
+	if a.cfg.ImportSchedule != "" {
+		if a.deps.Storage == nil {
+			log.Println("warning: IMPORT_SCHEDULE set but storage is not configured; scheduled imports disabled")
+		} else {
+			go jobs.RunScheduled(ctx, jobs.TypeCloudImport, a.cfg.ImportSchedule, leader, func() {
+				if _, err := a.jobs.Start(jobs.TypeCloudImport, "scheduler", a.imports.SyncJob(primitive.NilObjectID)); err != nil {
+					log.Printf("scheduled import: %v", err)
+				}
+			})
+		}
+	}
+	if a.cfg.FederationSchedule != "" {
+		if a.deps.Storage == nil {
+			log.Println("warning: FEDERATION_SCHEDULE set but storage is not configured; scheduled federation disabled")
+		} else {
+			go jobs.RunScheduled(ctx, jobs.TypeFederation, a.cfg.FederationSchedule, leader, func() {
+				if peers, err := a.deps.Store.SyncPeers(ctx); err != nil || len(peers) == 0 {
+					return
+				}
+				if _, err := a.jobs.Start(jobs.TypeFederation, "scheduler", a.peers.SyncJob(primitive.NilObjectID)); err != nil {
+					log.Printf("scheduled federation: %v", err)
+				}
+			})
+		}
+	}
+	if a.cfg.RecommendationsSchedule != "" {
+		go jobs.RunScheduled(ctx, jobs.TypeRecommendations, a.cfg.RecommendationsSchedule, leader, func() {
+			if _, err := a.jobs.Start(jobs.TypeRecommendations, "scheduler", jobs.Recommendations(a.deps.Store)); err != nil {
+				log.Printf("scheduled recommendations: %v", err)
+			}
+		})
+	}
+	if a.cfg.NewReleasesSchedule != "" {
+		if job, ok := a.admin.NewReleasesJob(); ok {
+			go jobs.RunScheduled(ctx, jobs.TypeNewReleases, a.cfg.NewReleasesSchedule, leader, func() {
+				if _, err := a.jobs.Start(jobs.TypeNewReleases, "scheduler", job); err != nil {
+					log.Printf("scheduled new releases: %v", err)
+				}
+			})
+		}
+	}
+	if a.cfg.DigestSchedule != "" {
+		if job, ok := a.admin.DigestJob(); ok {
+			go jobs.RunScheduled(ctx, jobs.TypeDigest, a.cfg.DigestSchedule, leader, func() {
+				if _, err := a.jobs.Start(jobs.TypeDigest, "scheduler", job); err != nil {
+					log.Printf("scheduled digest: %v", err)
+				}
+			})
+		}
+	}
+	if a.cfg.PriceWatchSchedule != "" {
+		if job, ok := a.admin.PriceWatchJob(); ok {
+			go jobs.RunScheduled(ctx, jobs.TypePriceWatch, a.cfg.PriceWatchSchedule, leader, func() {
+				if _, err := a.jobs.Start(jobs.TypePriceWatch, "scheduler", job); err != nil {
+					log.Printf("scheduled price watch: %v", err)
+				}
+			})
+		}
+	}
+	if schedule := storageAlertSchedule(a.cfg); schedule != "" {
+		go jobs.RunScheduled(ctx, jobs.TypeStorageAlerts, schedule, leader, func() {
+			if _, err := a.jobs.Start(jobs.TypeStorageAlerts, "scheduler", jobs.StorageAlerts(a.deps.Store, a.admin.Notify, a.admin.Quotas)); err != nil {
+				log.Printf("scheduled storage alerts: %v", err)
+			}
+		})
+	}
+	if schedule := coldStorageSchedule(a.cfg); schedule != "" {
+		go jobs.RunScheduled(ctx, jobs.TypeColdStorage, schedule, leader, func() {
+			job, reason := a.admin.ColdStorageJob()
+			if job == nil {
+				log.Printf("scheduled cold storage: %s", reason)
+				return
+			}
+			if _, err := a.jobs.Start(jobs.TypeColdStorage, "scheduler", job); err != nil {
+				log.Printf("scheduled cold storage: %v", err)
+			}
+		})
+	}
+	if a.cfg.Telemetry {
+		if job, ok := a.admin.TelemetryJob(); ok {
+			go jobs.RunScheduled(ctx, jobs.TypeTelemetry, a.cfg.TelemetrySchedule, leader, func() {
+				if _, err := a.jobs.Start(jobs.TypeTelemetry, "scheduler", job); err != nil {
+					log.Printf("scheduled telemetry: %v", err)
+				}
+			})
+		}
+	}
+	if a.bot != nil {
+		go a.bot.Run(ctx, leader)
+	}
+	if a.cfg.WatchDir != "" {
+		if a.deps.Storage == nil {
+			log.Println("warning: WATCH_DIR set but storage is not configured; watch folder disabled")
+		} else {
+			go a.watchFolder(ctx, leader)
+		}
+	}
+}
+
+// storageAlertSchedule is STORAGE_ALERT_SCHEDULE when a soft quota is set; "" otherwise, as there is nothing to check.
+func storageAlertSchedule(cfg *config.Config) string {
+	if cfg.StorageQuotaBytes <= 0 && cfg.DownloadQuotaBytes <= 0 {
+		return ""
+	}
+	return cfg.StorageAlertSchedule
+}
+
+// coldStorageSchedule is COLD_STORAGE_SCHEDULE when COLD_STORAGE_AFTER_MONTHS is set; "" otherwise.
+func coldStorageSchedule(cfg *config.Config) string {
+	if cfg.ColdStorageAfterMonths <= 0 {
+		return ""
+	}
+	return cfg.ColdStorageSchedule
+}
+
+// telemetrySchedule is TELEMETRY_SCHEDULE when the instance opted in to telemetry; "" otherwise.
+func telemetrySchedule(cfg *config.Config) string {
+	if !cfg.Telemetry {
+		return ""
+	}
+	return cfg.TelemetrySchedule
+}
+
+// awaitStorage waits until storage has connected, for up to timeout (no limit when 0), and reports whether it has.
+// Storage other than service.LazyStorage is always connected.
+func (a *App) awaitStorage(ctx context.Context, timeout time.Duration) bool {
+	s, ok := a.deps.Storage.(*service.LazyStorage)
+	if !ok {
+		return true
+	}
+	var expired <-chan time.Time
+	if timeout > 0 {
+		t := time.NewTimer(timeout)
+		defer t.Stop()
+		expired = t.C
+	}
+	select {
+	case <-s.Ready():
+		return true
+	case <-expired:
+		return false
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// resumeJobs restarts the jobs a shutdown interrupted, from their checkpoints.
+func (a *App) resumeJobs(ctx context.Context) {
+	if a.deps.Storage == nil {
+		return
+	}
+	db, storage := a.deps.Store, a.deps.Storage
+	resumable := map[string]func(params map[string]string) jobs.Func{
+		jobs.TypeVerifyStorage: func(map[string]string) jobs.Func { return jobs.VerifyStorage(db, storage) },
+		jobs.TypeBackfillFileInfo: func(params map[string]string) jobs.Func {
+			return jobs.BackfillFileInfo(db, storage, params["all"] == "true")
+		},
+		// Imported files are recorded as they are done, so running it again picks up where it stopped.
+		jobs.TypeCloudImport: func(params map[string]string) jobs.Func {
+			id, _ := primitive.ObjectIDFromHex(params["source"])
+			return a.imports.SyncJob(id)
+		},
+		// Each pull saves its cursors as it goes, so running it again picks up where it stopped.
+		jobs.TypeFederation: func(params map[string]string) jobs.Func {
+			id, _ := primitive.ObjectIDFromHex(params["peer"])
+			return a.peers.SyncJob(id)
+		},
+	}
+	if c := a.deps.Classifier; c != nil {
+		resumable[jobs.TypeClassifyBooks] = func(params map[string]string) jobs.Func {
+			return jobs.ClassifyBooks(db, storage, c, params["all"] == "true")
+		}
+	}
+	if e := a.deps.Embedder; e != nil {
+		resumable[jobs.TypeEmbedBooks] = func(params map[string]string) jobs.Func {
+			return jobs.EmbedBooks(db, storage, e, a.vectors, params["all"] == "true")
+		}
+	}
+	if target := a.deps.MigrationTarget; target != nil {
+		resumable[jobs.TypeMigrateStorage] = func(map[string]string) jobs.Func { return jobs.MigrateStorage(db, storage, target) }
+	}
+	for jobType, build := range resumable {
+		run, err := a.jobs.Resume(ctx, jobType, build)
+		if err != nil && !errors.Is(err, jobs.ErrAlreadyRunning) {
+			log.Printf("resume %s: %v", jobType, err)
+		} else if run != nil {
+			log.Printf("resumed interrupted %s job as %s", jobType, run.ID.Hex())
+		}
+	}
+}
+
+// watchBooks applies book changes from w to the search index and cached copies until ctx is cancelled.
+func (a *App) watchBooks(ctx context.Context, w store.BookWatcher) {
+	err := w.WatchBooks(ctx, func(c store.BookChange) { a.books.BookChanged(ctx, c) })
+	if errors.Is(err, store.ErrWatchUnsupported) {
+		log.Println("books change stream unavailable (MongoDB is not a replica set); edits made outside the app need a search reindex")
+	} else if err != nil {
+		log.Printf("books change stream: %v", err)
+	}
+}
@@ -0,0 +1,770 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/textproto"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kevinaaaquil/books/backend/config"
+	"github.com/kevinaaaquil/books/backend/handlers"
+	"github.com/kevinaaaquil/books/backend/models"
+	"github.com/kevinaaaquil/books/backend/service"
+	"github.com/kevinaaaquil/books/backend/store/docstore"
+	"github.com/kevinaaaquil/books/backend/utils"
+	"github.com/nats-io/nats-server/v2/server"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// testPassword is the password of every user seeded by newTestEnv.
+const testPassword = "secret"
+
+// Seeded accounts, one per role.
+const (
+	adminEmail  = "admin@test.local"
+	editorEmail = "editor@test.local"
+	viewerEmail = "viewer@test.local"
+	guestEmail  = "guest@test.local"
+)
+
+// testEnv is the full API served by httptest on the in-memory store, with local storage in a temp dir,
+// a fake metadata provider and a fake SMTP server for Kindle sends.
+type testEnv struct {
+	app      *App
+	srv      *httptest.Server
+	db       *docstore.Store
+	storage  *service.LocalStorage
+	metadata *fakeMetadata
+	smtp     *fakeSMTP
+	now      time.Time // what the app's clock returns
+}
+
+// newTestEnv builds the app; opts may replace any of the default test dependencies.
+func newTestEnv(t *testing.T, opts ...func(*Deps)) *testEnv {
+	t.Helper()
+	return newTestEnvWithConfig(t, nil, opts...)
+}
+
+// newTestEnvWithConfig is newTestEnv with configure (if non-nil) applied to the config before the app is built.
+func newTestEnvWithConfig(t *testing.T, configure func(*config.Config), opts ...func(*Deps)) *testEnv {
+	t.Helper()
+	db := docstore.NewMemory()
+	ctx := context.Background()
+	hash, err := bcrypt.GenerateFromPassword([]byte(testPassword), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for email, role := range map[string]string{
+		adminEmail:  models.RoleAdmin,
+		editorEmail: models.RoleEditor,
+		viewerEmail: models.RoleViewer,
+		guestEmail:  models.RoleGuest,
+	} {
+		if _, err := db.CreateUser(ctx, &models.User{Email: email, Password: string(hash), Role: role, CreatedAt: time.Now()}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cfg := &config.Config{
+		JWTSecret:                "test-secret",
+		AccessTokenTTL:           15 * time.Minute,
+		RefreshTokenTTL:          720 * time.Hour,
+		JobWorkers:               2,
+		JobMaxAttempts:           3,
+		JobRetryBackoff:          time.Millisecond,
+		MaxUploadMB:              10,
+		EmailConfigEncryptionKey: bytes.Repeat([]byte("k"), 32),
+		DownloadFilenameTemplate: utils.DefaultFilenameTemplate,
+		BundleMaxBytes:           2048 << 20,
+	}
+	if configure != nil {
+		configure(cfg)
+	}
+	signer := service.NewURLSigner(cfg.JWTSecret)
+	storage, err := service.NewLocalStorage(t.TempDir(), signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	env := &testEnv{
+		db:       db,
+		storage:  storage,
+		metadata: &fakeMetadata{books: map[string]*service.BookMetadata{}},
+		smtp:     newFakeSMTP(t),
+		now:      time.Now().UTC().Truncate(time.Second),
+	}
+	deps := Deps{
+		Store:        db,
+		Storage:      storage,
+		LocalStorage: storage,
+		Signer:       signer,
+		Mailer:       env.smtp.mailer(),
+		Metadata:     env.metadata,
+		Documents:    env.metadata,
+		Clock:        fixedClock(env.now),
+	}
+	for _, opt := range opts {
+		opt(&deps)
+	}
+	a, err := New(ctx, cfg, deps)
+	if err != nil {
+		t.Fatal(err)
+	}
+	env.app = a
+	a.queue.Start()
+	t.Cleanup(func() { a.queue.Shutdown(context.Background()) })
+	env.srv = httptest.NewServer(a.Handler())
+	t.Cleanup(env.srv.Close)
+	return env
+}
+
+// login returns a bearer token for email, failing the test if login is rejected.
+func (e *testEnv) login(t *testing.T, email string) string {
+	t.Helper()
+	res := e.do(t, http.MethodPost, "/api/auth/login", "", jsonBody(map[string]string{"email": email, "password": testPassword}))
+	var body handlers.LoginResponse
+	decode(t, res, http.StatusOK, &body)
+	return body.Token
+}
+
+// send posts a send request, which must be queued, and waits for its job to finish.
+func (e *testEnv) send(t *testing.T, path, token string, body io.Reader) models.QueuedJob {
+	t.Helper()
+	var queued map[string]string
+	decode(t, e.do(t, http.MethodPost, path, token, body), http.StatusAccepted, &queued)
+	return e.awaitJob(t, token, queued["jobId"])
+}
+
+// awaitJob polls the queued job until it has succeeded or failed.
+func (e *testEnv) awaitJob(t *testing.T, token, id string) models.QueuedJob {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		var job models.QueuedJob
+		decode(t, e.do(t, http.MethodGet, "/api/jobs/"+id, token, nil), http.StatusOK, &job)
+		if !job.Pending() {
+			return job
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("job %s still %s after %d attempt(s)", id, job.Status, job.Attempts)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// do sends a request to the test server. body may be nil; a JSON content type is set for non-nil bodies.
+func (e *testEnv) do(t *testing.T, method, path, token string, body io.Reader) *http.Response {
+	t.Helper()
+	return e.doWithType(t, method, path, token, body, "application/json")
+}
+
+func (e *testEnv) doWithType(t *testing.T, method, path, token string, body io.Reader, contentType string) *http.Response {
+	t.Helper()
+	return e.doWithHeader(t, method, path, token, body, http.Header{"Content-Type": {contentType}})
+}
+
+// loopbackProxies trusts proxies on loopback, where the test server's clients are.
+var loopbackProxies = []netip.Prefix{netip.MustParsePrefix("127.0.0.0/8"), netip.MustParsePrefix("::1/128")}
+
+// doFrom is do for a client at ip behind a proxy on loopback, which the config must trust (see loopbackProxies).
+func (e *testEnv) doFrom(t *testing.T, ip, method, path, token string, body io.Reader) *http.Response {
+	t.Helper()
+	return e.doWithHeader(t, method, path, token, body, http.Header{"Content-Type": {"application/json"}, "X-Forwarded-For": {ip}})
+}
+
+func (e *testEnv) doWithHeader(t *testing.T, method, path, token string, body io.Reader, header http.Header) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(method, e.srv.URL+path, body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, values := range header {
+		if name != "Content-Type" || body != nil {
+			req.Header[name] = values
+		}
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	res, err := e.srv.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { res.Body.Close() })
+	return res
+}
+
+// upload posts content as the multipart "file" field, named filename.
+func (e *testEnv) upload(t *testing.T, token, filename string, content []byte) *http.Response {
+	t.Helper()
+	return e.uploadWith(t, token, filename, content, nil)
+}
+
+// uploadWith uploads a file with more form fields.
+func (e *testEnv) uploadWith(t *testing.T, token, filename string, content []byte, fields url.Values) *http.Response {
+	t.Helper()
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	for name, values := range fields {
+		for _, v := range values {
+			mw.WriteField(name, v)
+		}
+	}
+	part, err := mw.CreateFormFile("file", filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	part.Write(content)
+	mw.Close()
+	return e.doWithType(t, http.MethodPost, "/api/upload", token, &buf, mw.FormDataContentType())
+}
+
+// addBook stores the sample EPUB and inserts a book record for it directly, bypassing the upload handler.
+// CreatedAt defaults to now.
+func (e *testEnv) addBook(t *testing.T, book models.Book) models.Book {
+	t.Helper()
+	ctx := context.Background()
+	content := fixture(t, "sample.epub")
+	key, err := e.storage.Upload(ctx, "books/", "sample.epub", bytes.NewReader(content), "application/epub+zip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	book.Format, book.S3Key, book.OriginalName = "epub", key, "sample.epub"
+	if book.CreatedAt.IsZero() {
+		book.CreatedAt = time.Now()
+	}
+	id, err := e.db.InsertBook(ctx, &book)
+	if err != nil {
+		t.Fatal(err)
+	}
+	book.ID = id
+	return book
+}
+
+// waitJob polls the job run until it is no longer running.
+func (e *testEnv) waitJob(t *testing.T, token string, id primitive.ObjectID) models.JobRun {
+	t.Helper()
+	var run models.JobRun
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		decode(t, e.do(t, http.MethodGet, "/api/admin/jobs/"+id.Hex(), token, nil), http.StatusOK, &run)
+		if run.Status != models.JobStatusRunning {
+			return run
+		}
+	}
+	t.Fatalf("job %s still running", id.Hex())
+	return run
+}
+
+func fixture(t *testing.T, name string) []byte {
+	t.Helper()
+	b, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func jsonBody(v any) io.Reader {
+	b, _ := json.Marshal(v)
+	return bytes.NewReader(b)
+}
+
+// decode checks the response status and unmarshals the JSON body into v (when v is non-nil).
+func decode(t *testing.T, res *http.Response, wantStatus int, v any) {
+	t.Helper()
+	body, _ := io.ReadAll(res.Body)
+	if res.StatusCode != wantStatus {
+		t.Fatalf("%s %s: status %d, want %d; body: %s", res.Request.Method, res.Request.URL.Path, res.StatusCode, wantStatus, body)
+	}
+	if v != nil {
+		if err := json.Unmarshal(body, v); err != nil {
+			t.Fatalf("%s %s: decode %s: %v", res.Request.Method, res.Request.URL.Path, body, err)
+		}
+	}
+}
+
+type fixedClock time.Time
+
+func (c fixedClock) Now() time.Time {
+	return time.Time(c)
+}
+
+// fakeMetadata serves metadata from a map of ISBNs and DOIs; unknown ones return an error like Google Books' "no volume found".
+// Lookups take delay, or until the context is done.
+type fakeMetadata struct {
+	mu       sync.Mutex
+	books    map[string]*service.BookMetadata
+	byAuthor map[string][]service.BookMetadata
+	byTitle  map[string][]service.BookMetadata
+	searches []string // "title / author" of each SearchByTitle
+	delay    time.Duration
+	fetches  int
+}
+
+func (f *fakeMetadata) set(isbn string, meta *service.BookMetadata) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.books[isbn] = meta
+}
+
+// setAuthor sets what SearchByAuthor returns for author.
+func (f *fakeMetadata) setAuthor(author string, books ...service.BookMetadata) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.byAuthor == nil {
+		f.byAuthor = map[string][]service.BookMetadata{}
+	}
+	f.byAuthor[author] = books
+}
+
+// setTitle sets what SearchByTitle returns for title, whatever the author.
+func (f *fakeMetadata) setTitle(title string, books ...service.BookMetadata) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.byTitle == nil {
+		f.byTitle = map[string][]service.BookMetadata{}
+	}
+	f.byTitle[title] = books
+}
+
+func (f *fakeMetadata) setDelay(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.delay = d
+}
+
+func (f *fakeMetadata) fetchCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.fetches
+}
+
+func (f *fakeMetadata) FetchByISBN(ctx context.Context, isbn string) (*service.BookMetadata, error) {
+	f.mu.Lock()
+	f.fetches++
+	delay := f.delay
+	meta, ok := f.books[isbn]
+	f.mu.Unlock()
+	if delay > 0 {
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	if !ok {
+		return nil, fmt.Errorf("%w for isbn %s", service.ErrNoMetadata, isbn)
+	}
+	m := *meta
+	return &m, nil
+}
+
+func (f *fakeMetadata) FetchByDOI(ctx context.Context, doi string) (*service.BookMetadata, error) {
+	return f.FetchByISBN(ctx, doi)
+}
+
+func (f *fakeMetadata) SearchByAuthor(ctx context.Context, author string, limit int) ([]service.BookMetadata, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	books := f.byAuthor[author]
+	return books[:min(limit, len(books))], nil
+}
+
+func (f *fakeMetadata) SearchByTitle(ctx context.Context, title, author string, limit int) ([]service.BookMetadata, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.searches = append(f.searches, title+" / "+author)
+	books := f.byTitle[title]
+	return books[:min(limit, len(books))], nil
+}
+
+// apiMailer records mail like the HTTPS transports (SES, Mailgun, SendGrid), which send from their own address.
+// tieredStorage is LocalStorage with S3's storage classes and restores, kept in memory.
+type tieredStorage struct {
+	*service.LocalStorage
+
+	mu       sync.Mutex
+	classes  map[string]string
+	tags     map[string]string
+	restores map[string]int  // restore requests by key
+	restored map[string]bool // restores the test has finished
+}
+
+func newTieredStorage(local *service.LocalStorage) *tieredStorage {
+	return &tieredStorage{LocalStorage: local, classes: map[string]string{}, tags: map[string]string{}, restores: map[string]int{}, restored: map[string]bool{}}
+}
+
+func (s *tieredStorage) SetStorageClass(ctx context.Context, key, class string) error {
+	if _, err := s.HeadObject(ctx, key); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.classes[key] = class
+	return nil
+}
+
+func (s *tieredStorage) TagObject(ctx context.Context, key, tag, value string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tags[key] = tag + "=" + value
+	return nil
+}
+
+func (s *tieredStorage) Tier(ctx context.Context, key string) (*service.TierInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	info := &service.TierInfo{Class: s.classes[key], Restoring: s.restores[key] > 0 && !s.restored[key]}
+	if s.restored[key] {
+		info.RestoredUntil = time.Now().Add(24 * time.Hour)
+	}
+	return info, nil
+}
+
+func (s *tieredStorage) Restore(ctx context.Context, key string, days int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.restores[key]++
+	return nil
+}
+
+func (s *tieredStorage) finishRestore(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.restored[key] = true
+}
+
+// errArchived is what S3 answers (InvalidObjectState) to reading an archived object that is not restored.
+var errArchived = errors.New("the object is archived")
+
+// readable reports whether key can be read: it is not archived, or it has been restored.
+func (s *tieredStorage) readable(key string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return !service.NeedsRestore(s.classes[key]) || s.restored[key]
+}
+
+func (s *tieredStorage) GetObject(ctx context.Context, key string) (io.ReadCloser, string, error) {
+	if !s.readable(key) {
+		return nil, "", errArchived
+	}
+	return s.LocalStorage.GetObject(ctx, key)
+}
+
+func (s *tieredStorage) OpenObject(ctx context.Context, key string) (service.Object, error) {
+	if !s.readable(key) {
+		return nil, errArchived
+	}
+	return s.LocalStorage.OpenObject(ctx, key)
+}
+
+type apiMailer struct {
+	mu          sync.Mutex
+	sent        []service.Mail
+	attachments []string // attachment contents, parallel to sent
+}
+
+func (m *apiMailer) UsesSenderAccount() bool {
+	return false
+}
+
+func (m *apiMailer) Send(ctx context.Context, mail *service.Mail) error {
+	var attachment []byte
+	if mail.Attachment != nil {
+		var err error
+		if attachment, err = io.ReadAll(mail.Attachment); err != nil {
+			return err
+		}
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sent = append(m.sent, *mail)
+	m.attachments = append(m.attachments, string(attachment))
+	return nil
+}
+
+// smtpMessage is one message accepted by fakeSMTP.
+type smtpMessage struct {
+	username, password string
+	from               string
+	to                 []string
+	data               string
+}
+
+// fakeSMTP is a minimal SMTP server supporting STARTTLS (with a self-signed certificate) and AUTH PLAIN,
+// enough for service.SMTPMailer. Accepted messages are recorded for assertions.
+type fakeSMTP struct {
+	ln      net.Listener
+	tls     *tls.Config
+	roots   *x509.CertPool
+	mu      sync.Mutex
+	msgs    []smtpMessage
+	conns   []net.Conn // every connection accepted
+	rejectN int        // reject the next rejectN messages with a 550
+	deferN  int // then answer the next deferN with a 451, asking to try again later
+}
+
+func newFakeSMTP(t *testing.T) *fakeSMTP {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(cert)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &fakeSMTP{
+		ln:    ln,
+		tls:   &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}},
+		roots: roots,
+	}
+	t.Cleanup(func() { ln.Close() })
+	go f.serve()
+	return f
+}
+
+// mailer returns an SMTPMailer that delivers to f and trusts its certificate.
+func (f *fakeSMTP) mailer() service.SMTPMailer {
+	addr := f.ln.Addr().(*net.TCPAddr)
+	return service.SMTPMailer{
+		Host:      "127.0.0.1",
+		Port:      addr.Port,
+		TLSConfig: &tls.Config{RootCAs: f.roots, ServerName: "127.0.0.1"},
+	}
+}
+
+func (f *fakeSMTP) messages() []smtpMessage {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]smtpMessage(nil), f.msgs...)
+}
+
+// connections returns how many connections f has accepted.
+func (f *fakeSMTP) connections() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.conns)
+}
+
+// hangUp closes every connection, as a server does with ones idle too long.
+func (f *fakeSMTP) hangUp() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, conn := range f.conns {
+		conn.Close()
+	}
+}
+
+func (f *fakeSMTP) serve() {
+	for {
+		conn, err := f.ln.Accept()
+		if err != nil {
+			return
+		}
+		f.mu.Lock()
+		f.conns = append(f.conns, conn)
+		f.mu.Unlock()
+		go f.session(conn)
+	}
+}
+
+func (f *fakeSMTP) session(conn net.Conn) {
+	defer conn.Close()
+	tp := textproto.NewConn(conn)
+	secure := false
+	var msg smtpMessage
+	tp.PrintfLine("220 fake ESMTP")
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(verb) {
+		case "EHLO", "HELO":
+			if secure {
+				tp.PrintfLine("250-fake\r\n250 AUTH PLAIN")
+			} else {
+				tp.PrintfLine("250-fake\r\n250 STARTTLS")
+			}
+		case "STARTTLS":
+			tp.PrintfLine("220 ready")
+			tlsConn := tls.Server(conn, f.tls)
+			if err := tlsConn.Handshake(); err != nil {
+				return
+			}
+			conn, secure = tlsConn, true
+			tp = textproto.NewConn(tlsConn)
+		case "AUTH":
+			mech, initial, _ := strings.Cut(arg, " ")
+			if !secure || !strings.EqualFold(mech, "PLAIN") {
+				tp.PrintfLine("504 unsupported")
+				continue
+			}
+			raw, _ := base64.StdEncoding.DecodeString(initial)
+			parts := strings.Split(string(raw), "\x00")
+			if len(parts) == 3 {
+				msg.username, msg.password = parts[1], parts[2]
+			}
+			tp.PrintfLine("235 ok")
+		case "MAIL":
+			msg.from = smtpAddress(arg)
+			tp.PrintfLine("250 ok")
+		case "RCPT":
+			msg.to = append(msg.to, smtpAddress(arg))
+			tp.PrintfLine("250 ok")
+		case "DATA":
+			tp.PrintfLine("354 go ahead")
+			data, err := tp.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			msg.data = string(data)
+			f.mu.Lock()
+			reject, deferred := f.rejectN > 0, f.rejectN == 0 && f.deferN > 0
+			switch {
+			case reject:
+				f.rejectN--
+			case deferred:
+				f.deferN--
+			default:
+				f.msgs = append(f.msgs, msg)
+			}
+			f.mu.Unlock()
+			switch {
+			case reject:
+				tp.PrintfLine("550 mailbox unavailable")
+			case deferred:
+				tp.PrintfLine("451 try again later")
+			default:
+				tp.PrintfLine("250 queued")
+			}
+			msg = smtpMessage{username: msg.username, password: msg.password}
+		case "RSET", "NOOP":
+			tp.PrintfLine("250 ok")
+		case "QUIT":
+			tp.PrintfLine("221 bye")
+			return
+		default:
+			tp.PrintfLine("502 not implemented")
+		}
+	}
+}
+
+// smtpAddress extracts the address from "FROM:<a@b>" / "TO:<a@b>".
+func smtpAddress(arg string) string {
+	_, addr, _ := strings.Cut(arg, ":")
+	addr, _, _ = strings.Cut(strings.TrimSpace(addr), " ")
+	return strings.Trim(addr, "<>")
+}
+
+// fakeBroker is an in-memory jobs.Broker: each announcement goes to one of the instances listening for its kind,
+// in turn, and is lost when none is.
+type fakeBroker struct {
+	mu        sync.Mutex
+	listeners map[string][]func(string)
+	next      int
+	announced []string // kind and job ID of each announcement
+}
+
+func (b *fakeBroker) Announce(ctx context.Context, kind string, id primitive.ObjectID) error {
+	b.mu.Lock()
+	b.announced = append(b.announced, kind+" "+id.Hex())
+	var due func(string)
+	if ls := b.listeners[kind]; len(ls) > 0 {
+		due = ls[b.next%len(ls)]
+		b.next++
+	}
+	b.mu.Unlock()
+	if due != nil {
+		due(kind)
+	}
+	return nil
+}
+
+func (b *fakeBroker) Listen(ctx context.Context, kinds []string, due func(kind string)) {
+	b.mu.Lock()
+	if b.listeners == nil {
+		b.listeners = map[string][]func(string){}
+	}
+	for _, kind := range kinds {
+		b.listeners[kind] = append(b.listeners[kind], due)
+	}
+	b.mu.Unlock()
+	<-ctx.Done()
+}
+
+func (b *fakeBroker) Close() error { return nil }
+
+func (b *fakeBroker) announcements() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]string(nil), b.announced...)
+}
+
+// newNATSServer starts an in-process NATS server on a free port, with JetStream (stored under a temp dir) when
+// jetStream is set, that only accepts clients presenting token when it is not "". It is shut down with the test.
+func newNATSServer(t *testing.T, token string, jetStream bool) *server.Server {
+	t.Helper()
+	s, err := server.NewServer(&server.Options{
+		Host:          "127.0.0.1",
+		Port:          -1,
+		NoLog:         true,
+		NoSigs:        true,
+		Authorization: token,
+		JetStream:     jetStream,
+		StoreDir:      t.TempDir(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.Start()
+	if !s.ReadyForConnections(5 * time.Second) {
+		t.Fatal("nats server not ready")
+	}
+	t.Cleanup(s.Shutdown)
+	return s
+}
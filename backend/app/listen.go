@@ -0,0 +1,69 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"strings"
+)
+
+// listen opens addrs: TCP addresses, and unix:/path sockets created with socketMode (0 = 0660). A stale socket
+// file left by an unclean exit is replaced; other files at the path are not. On error, the listeners already open
+// are closed.
+func listen(addrs []string, socketMode os.FileMode) ([]net.Listener, error) {
+	if socketMode == 0 {
+		socketMode = 0o660
+	}
+	var listeners []net.Listener
+	fail := func(err error) ([]net.Listener, error) {
+		for _, l := range listeners {
+			l.Close()
+		}
+		return nil, err
+	}
+	for _, addr := range addrs {
+		path, unix := strings.CutPrefix(addr, "unix:")
+		if !unix {
+			l, err := net.Listen("tcp", addr)
+			if err != nil {
+				return fail(err)
+			}
+			listeners = append(listeners, l)
+			continue
+		}
+		if fi, err := os.Lstat(path); err == nil {
+			if fi.Mode().Type() != fs.ModeSocket {
+				return fail(fmt.Errorf("listen %s: file exists and is not a socket", addr))
+			}
+			// A socket nothing accepts on is left over; one that accepts belongs to a running server.
+			if c, err := net.Dial("unix", path); err == nil {
+				c.Close()
+				return fail(fmt.Errorf("listen %s: another server is listening", addr))
+			}
+			if err := os.Remove(path); err != nil {
+				return fail(err)
+			}
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return fail(err)
+		}
+		l, err := net.Listen("unix", path)
+		if err != nil {
+			return fail(err)
+		}
+		listeners = append(listeners, l)
+		if err := os.Chmod(path, socketMode); err != nil {
+			return fail(err)
+		}
+	}
+	return listeners, nil
+}
+
+// listenerName is how a listener is logged: its address, or unix:/path.
+func listenerName(l net.Listener) string {
+	if l.Addr().Network() == "unix" {
+		return "unix:" + l.Addr().String()
+	}
+	return l.Addr().String()
+}
@@ -0,0 +1,363 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/kevinaaaquil/books/backend/handlers"
+	"github.com/kevinaaaquil/books/backend/middleware"
+	"github.com/kevinaaaquil/books/backend/models"
+	"github.com/kevinaaaquil/books/backend/service"
+	"github.com/kevinaaaquil/books/backend/web"
+)
+
+// handlerSet is every HTTP handler the routes dispatch to.
+type handlerSet struct {
+	auth            *handlers.AuthHandler
+	upload          *handlers.UploadHandler
+	books           *handlers.BooksHandler
+	users           *handlers.UsersHandler
+	emailConfig     *handlers.EmailConfigHandler
+	admin           *handlers.AdminHandler
+	notifications   *handlers.NotificationsHandler
+	queuedJobs      *handlers.QueuedJobsHandler
+	targets         *handlers.TargetsHandler
+	imports         *handlers.ImportsHandler
+	peers           *handlers.SyncPeersHandler
+	blocklist       *handlers.BlocklistHandler
+	s3Events        *handlers.S3EventsHandler // nil unless S3_EVENTS_PREFIX and storage are set
+	devices         *handlers.DevicesHandler
+	collections     *handlers.CollectionsHandler
+	progress        *handlers.ProgressHandler
+	capabilities    *handlers.CapabilitiesHandler
+	settings        *handlers.SettingsHandler
+	telegram        *handlers.TelegramHandler
+	lookup          *handlers.LookupHandler // nil unless PUBLIC_LOOKUP
+	apiKeys         *handlers.APIKeysHandler
+	wishlist        *handlers.WishlistHandler
+	recommendations *handlers.RecommendationsHandler
+	newReleases     *handlers.NewReleasesHandler
+	clip            *handlers.ClipHandler
+	stats           *handlers.StatsHandler
+	arxiv           *handlers.ArXivHandler
+	purchases       *handlers.PurchasesHandler
+}
+
+// routes builds the router: public endpoints, then /api with auth and role groups.
+func (a *App) routes(h handlerSet) http.Handler {
+	limit := a.admin.RateLimiter.Limit
+	burst := h.blocklist.Burst.Guard
+	hasQuery := func(r *http.Request) bool { return r.URL.Query().Get("q") != "" }
+
+	r := chi.NewRouter()
+	r.Use(middleware.AllowAll())
+	r.Use(middleware.RequestLogger(a.deps.RequestLog, a.cfg.LogSample))
+	r.Use(middleware.Recoverer(a.deps.Reporter, a.cfg.ErrorReport5xx))
+	if a.cfg.ForwardAuth {
+		// Before RealIP, so only headers from the proxy itself are believed.
+		r.Use(middleware.ForwardAuth(middleware.ForwardAuthConfig{
+			UserHeader:   a.cfg.ForwardAuthUserHeader,
+			EmailHeader:  a.cfg.ForwardAuthEmailHeader,
+			GroupsHeader: a.cfg.ForwardAuthGroupsHeader,
+			Roles:        a.cfg.ForwardAuthRoles,
+			DefaultRole:  a.cfg.ForwardAuthDefaultRole,
+			Trusted:      a.cfg.TrustedProxies,
+		}, h.auth.ResolveForwardUser))
+	}
+	r.Use(middleware.RealIP(a.cfg.TrustedProxies))
+	r.Use(middleware.Trace(a.deps.Tracer))
+
+	if a.deps.Web != nil {
+		// Everything outside /api and /health is the web UI; unknown /api paths still get the API's 404.
+		r.Handle("/*", web.Handler(a.deps.Web))
+	} else {
+		r.Get("/", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"message":"welcome to books."}`))
+		})
+	}
+	r.Get("/health", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if !a.deps.Store.Healthy() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(`{"status":"degraded","database":"unreachable"}`))
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"status":"ok"}`))
+	})
+	r.Get("/health/ready", a.ready)
+
+	r.Route("/api", func(r chi.Router) {
+		r.Use(middleware.RequireDB(a.deps.Store.Healthy))
+		r.Use(h.blocklist.Blocklist.Block)
+		r.Use(middleware.Maintenance(a.cfg.JWTSecret, h.settings.Maintenance))
+		if a.cfg.ReadOnly {
+			r.Use(middleware.ReadOnly)
+		}
+		r.Post("/auth/login", h.auth.Login)
+		r.With(burst).Post("/auth/guest", h.auth.LoginAsGuest)
+		r.With(burst).Get("/auth/guest/challenge", h.auth.GuestChallenge)
+		r.Post("/auth/proxy", h.auth.ProxyLogin)
+		r.Post("/auth/refresh", h.auth.Refresh)
+		r.Post("/auth/logout", h.auth.Logout)
+		r.Post("/auth/forgot-password", h.auth.ForgotPassword)
+		r.Post("/auth/reset-password", h.auth.ResetPassword)
+		r.With(middleware.Cache(middleware.CachePublic)).Get("/capabilities", h.capabilities.Get)
+		r.Get("/terms", h.settings.Terms) // public, so the terms of use can be read before signing in
+		if h.lookup != nil {
+			// Public for companion tools; answers are the same for everyone and cached server-side too.
+			r.With(limit(models.RateLookup, nil), middleware.Cache(middleware.CachePublic)).Get("/lookup", h.lookup.Lookup)
+		}
+		// Public for badges on personal sites; 404 unless an admin turns on the publicStats setting.
+		r.With(limit(models.RateStats, nil), middleware.Cache(middleware.CachePublic)).Get("/public/stats", h.stats.Stats)
+		r.With(limit(models.RateStats, nil), middleware.Cache(middleware.CachePublic)).Get("/public/stats.svg", h.stats.Badge)
+		// Public so <img src> works without auth. Cover URLs carry a version (see Cover), so they never go stale.
+		r.With(burst, limit(models.RateCovers, nil), middleware.Cache(middleware.CacheImmutable)).Get("/books/{id}/cover", h.books.Cover)
+		r.With(limit(models.RateDownloads, nil)).Get("/books/{id}/file", h.books.StreamFile) // public; requires a signed URL from /download
+		r.With(limit(models.RateDownloads, nil)).Head("/books/{id}/file", h.books.StreamFile)
+		r.With(limit(models.RateDownloads, nil)).Get("/books/{id}/audio/{chapter}/file", h.books.StreamAudio) // public; requires a signed URL from /audio
+		r.With(limit(models.RateDownloads, nil)).Head("/books/{id}/audio/{chapter}/file", h.books.StreamAudio)
+		r.With(limit(models.RateDownloads, nil)).Get("/collections/{id}/bundle/{user}", h.books.BundleFile) // public; requires a signed URL from /collections/{id}/download
+		if h.s3Events != nil {
+			r.With(middleware.MaxBodyBytes(1<<20)).Post("/s3/events", h.s3Events.Events) // public; SNS-signed, or signed with S3_EVENTS_SECRET
+		}
+		if a.deps.LocalStorage != nil {
+			r.With(limit(models.RateDownloads, nil)).Get("/storage/*", a.deps.LocalStorage.ServeSigned) // public; signed URLs from LocalStorage.PresignedGetURL
+			r.With(limit(models.RateDownloads, nil)).Head("/storage/*", a.deps.LocalStorage.ServeSigned)
+		}
+		// Public: the provider redirects here after linking a drive; the OAuth state identifies the user.
+		r.Get("/oauth/{provider}/callback", h.targets.LinkCallback)
+		// OPDS feeds for e-readers, which can only be given a URL: an API key with the feeds scope in the path.
+		r.Route("/opds/{key}", func(r chi.Router) {
+			r.Use(middleware.APIKeyInPath("key", models.ScopeFeeds, h.apiKeys.Resolve))
+			r.Get("/", h.books.OPDSRoot)
+			r.Get("/all", h.books.OPDSAll)
+			r.Get("/shelves/{shelf}", h.books.OPDSShelf)
+			r.Get("/tags", h.books.OPDSTags)
+			r.Get("/tags/{tag}", h.books.OPDSTag)
+			r.With(limit(models.RateDownloads, nil)).Get("/books/{id}/file", h.books.OPDSFile)
+			r.With(limit(models.RateDownloads, nil)).Head("/books/{id}/file", h.books.OPDSFile)
+		})
+		// One-click saving from browser extensions and scripts: a token or an API key with the clip scope.
+		r.Group(func(r chi.Router) {
+			r.Use(middleware.AuthOrAPIKey(a.cfg.JWTSecret, models.ScopeClip, h.apiKeys.Resolve))
+			r.Use(middleware.RequireAnyRole("admin", "editor", "viewer"))
+			r.Use(middleware.RequireTerms(h.settings.PendingTerms))
+			r.With(middleware.MaxBodyBytes(64<<10)).Post("/clip", h.clip.Clip)
+		})
+		// Mirroring by other instances (see models.SyncPeer): an admin's token or API key with the sync scope.
+		r.Group(func(r chi.Router) {
+			r.Use(middleware.AuthOrAPIKey(a.cfg.JWTSecret, models.ScopeSync, h.apiKeys.Resolve))
+			r.Use(middleware.RequireAnyRole("admin"))
+			r.Get("/sync/books", h.books.SyncBooks)
+			r.Get("/sync/progress", h.books.SyncProgress)
+		})
+		r.Group(func(r chi.Router) {
+			r.Use(middleware.Auth(a.cfg.JWTSecret, h.apiKeys.Resolve))
+			// Until the user accepts the current terms of use, only /me and accepting them answer (451 otherwise).
+			r.Use(middleware.RequireTerms(h.settings.PendingTerms))
+			r.Get("/me", h.users.GetMe)
+			r.Post("/me/terms", h.settings.AcceptTerms)
+			r.Post("/me/onboarding", h.users.CompleteOnboarding)
+			r.Patch("/me/preferences", h.users.PatchMePreferences)
+			r.Get("/me/notifications", h.notifications.List)
+			r.Post("/me/notifications/{id}/read", h.notifications.MarkRead)
+			r.Get("/jobs/{id}", h.queuedJobs.Get)
+			// Read: admin, editor, viewer, guest (guests see only books with viewByGuest; users with a maxContentRating only books within it)
+			r.Group(func(r chi.Router) {
+				r.Use(middleware.RequireAnyRole("admin", "editor", "viewer", "guest"))
+				r.With(limit(models.RateSearch, hasQuery)).Get("/books", h.books.List)
+				r.Get("/books/timeline", h.books.Timeline)
+				r.With(limit(models.RateSearch, nil)).Get("/search/semantic", h.books.Semantic)
+				r.With(limit(models.RateAssistant, nil), middleware.MaxBodyBytes(16<<10)).Post("/ask", h.books.Ask)
+				r.Get("/export/graph", h.books.Graph)
+				r.With(burst, limit(models.RateDetails, nil), middleware.Cache(middleware.CacheRevalidate)).Get("/books/{id}", h.books.Get)
+				r.With(limit(models.RateDownloads, nil)).Get("/books/{id}/download", h.books.Download)
+				r.With(limit(models.RateDownloads, nil)).Head("/books/{id}/download", h.books.Download)
+				r.With(middleware.Cache(middleware.CachePreview)).Get("/books/{id}/preview", h.books.Preview)
+				r.With(middleware.Cache(middleware.CacheBookContent)).Get("/books/{id}/content/*", h.books.Content)
+				r.With(middleware.Cache(middleware.CacheBookContent)).Head("/books/{id}/content/*", h.books.Content)
+				r.Get("/books/{id}/similar", h.books.Similar)
+				r.Get("/books/{id}/audio", h.books.Audio)
+				r.Get("/books/{id}/audio/chapters", h.books.AudioChapters)
+				r.With(limit(models.RateDownloads, nil)).Get("/books/{id}/audio/{chapter}", h.books.AudioFile)
+				r.With(limit(models.RateDownloads, nil)).Head("/books/{id}/audio/{chapter}", h.books.AudioFile)
+				r.Post("/books/{id}/send-to-kindle", h.books.Send)
+			})
+			// Delivery targets (email addresses, linked drives), devices, collections, reading progress, recommendations and new releases, Telegram chats, API keys and wishlists: signed-in users other than the shared guest
+			r.Group(func(r chi.Router) {
+				r.Use(middleware.RequireAnyRole("admin", "editor", "viewer"))
+				r.Post("/books/{id}/send", h.books.Send)
+				r.With(limit(models.RateDownloads, nil)).Post("/collections/{id}/download", h.books.DownloadBundle)
+				r.Get("/targets", h.targets.List)
+				r.Post("/targets", h.targets.Create)
+				r.Post("/targets/{id}/verification", h.targets.ResendCode)
+				r.Post("/targets/{id}/confirm", h.targets.Confirm)
+				r.Delete("/targets/{id}", h.targets.Delete)
+				r.Get("/targets/oauth/{provider}/start", h.targets.StartLink)
+				r.Get("/devices", h.devices.List)
+				r.Post("/devices", h.devices.Create)
+				r.Put("/devices/{id}", h.devices.Update)
+				r.Delete("/devices/{id}", h.devices.Delete)
+				r.Get("/collections", h.collections.List)
+				r.Post("/collections", h.collections.Create)
+				r.Get("/collections/{id}", h.collections.Get)
+				r.Patch("/collections/{id}", h.collections.Rename)
+				r.Delete("/collections/{id}", h.collections.Delete)
+				r.Get("/collections/{id}/books", h.collections.Books)
+				r.Post("/collections/{id}/books", h.collections.AddBook)
+				r.Delete("/collections/{id}/books/{bookId}", h.collections.RemoveBook)
+				r.Put("/books/{id}/progress", h.progress.Save)
+				r.Get("/books/{id}/progress", h.progress.Continue)
+				r.Get("/books/{id}/continue", h.progress.Continue)
+				r.Get("/me/reading", h.progress.Reading)
+				r.Get("/me/recommendations", h.recommendations.List)
+				r.Get("/me/new-releases", h.newReleases.List)
+				r.Get("/me/telegram", h.telegram.Status)
+				r.Post("/me/telegram/link", h.telegram.Link)
+				r.Delete("/me/telegram", h.telegram.Unlink)
+				r.With(middleware.RequireSignIn).Get("/me/api-keys", h.apiKeys.List)
+				r.With(middleware.RequireSignIn).Post("/me/api-keys", h.apiKeys.Create)
+				r.With(middleware.RequireSignIn).Delete("/me/api-keys/{id}", h.apiKeys.Delete)
+				r.With(middleware.RequireSignIn).Post("/me/api-keys/{id}/regenerate", h.apiKeys.Regenerate)
+				r.Get("/wishlist", h.wishlist.List)
+				r.Delete("/wishlist/{id}", h.wishlist.Delete)
+				r.Put("/wishlist/{id}/price-watch", h.wishlist.WatchPrice)
+				r.Delete("/wishlist/{id}/price-watch", h.wishlist.UnwatchPrice)
+				r.Get("/wishlist/{id}/prices", h.wishlist.Prices)
+			})
+			// Write (upload, arXiv imports): admin, editor
+			r.Group(func(r chi.Router) {
+				r.Use(middleware.RequireAnyRole("admin", "editor"))
+				r.Post("/upload", h.upload.Upload)
+				r.Post("/import/arxiv", h.arxiv.Import)
+			})
+			// Cloud drive imports (each user's own sources): admin, editor
+			r.Group(func(r chi.Router) {
+				r.Use(middleware.RequireAnyRole("admin", "editor"))
+				r.Get("/imports", h.imports.List)
+				r.Get("/imports/oauth/{provider}/start", h.imports.StartLink)
+				r.Patch("/imports/{id}", h.imports.Patch)
+				r.Delete("/imports/{id}", h.imports.Delete)
+				r.Post("/imports/{id}/sync", h.imports.Sync)
+			})
+			// Refresh and search metadata, retry failed upload steps, set content ratings, review suggested categories, import
+			// ONIX metadata and read books aloud: admin, editor
+			r.Group(func(r chi.Router) {
+				r.Use(middleware.RequireAnyRole("admin", "editor"))
+				r.Post("/books/{id}/audio", h.books.ReadAloud)
+				r.With(limit(models.RateMetadata, nil), middleware.MaxBodyBytes(a.cfg.MetadataRefreshMaxBytes)).Post("/books/{id}/refresh-metadata", h.books.RefreshMetadata)
+				r.With(limit(models.RateMetadata, nil), middleware.MaxBodyBytes(a.cfg.MetadataRefreshMaxBytes)).Post("/books/{id}/search-metadata", h.books.SearchMetadata)
+				r.Post("/books/{id}/retry-upload", h.upload.RetryUpload)
+				r.Patch("/books/{id}/content-rating", h.books.PatchContentRating)
+				r.Put("/books/{id}/content-rating", h.books.PatchContentRating)
+				r.With(limit(models.RateMetadata, nil)).Post("/books/{id}/categories/suggest", h.books.SuggestCategories)
+				r.Post("/books/{id}/categories/approve", h.books.ApproveCategories)
+				r.Delete("/books/{id}/categories/suggestions", h.books.DismissCategories)
+				r.With(middleware.MaxBodyBytes(64<<20)).Post("/import/onix", h.books.ImportONIX)
+			})
+			// Purchase records (each book's own visible only to admins and its uploader): admin, editor
+			r.Group(func(r chi.Router) {
+				r.Use(middleware.RequireAnyRole("admin", "editor"))
+				r.Get("/books/{id}/purchase", h.purchases.Get)
+				r.Put("/books/{id}/purchase", h.purchases.Put)
+				r.Delete("/books/{id}/purchase", h.purchases.Delete)
+				r.Get("/purchases.csv", h.purchases.Export)
+			})
+			// Delete books: admin only
+			r.Group(func(r chi.Router) {
+				r.Use(middleware.RequireAdmin)
+				r.Delete("/books/{id}", h.books.Delete)
+			})
+			// Toggle view-by-guest (demo visibility): admin only
+			r.Group(func(r chi.Router) {
+				r.Use(middleware.RequireAdmin)
+				r.Patch("/books/{id}/view-by-guest", h.books.PatchViewByGuest)
+				r.Put("/books/{id}/view-by-guest", h.books.PatchViewByGuest)
+			})
+			// Manage users: admin only
+			r.Group(func(r chi.Router) {
+				r.Use(middleware.RequireAdmin)
+				r.Get("/users", h.users.ListUsers)
+				r.Post("/users", h.users.CreateUser)
+				r.Patch("/users/{id}", h.users.UpdateUser)
+				r.Delete("/users/{id}", h.users.DeleteUser)
+			})
+			// Library maintenance: admin only
+			r.Group(func(r chi.Router) {
+				r.Use(middleware.RequireAdmin)
+				r.Get("/admin/library/health", h.admin.LibraryHealth)
+				r.Get("/admin/books", h.admin.Books)
+				r.Get("/admin/storage", h.admin.StorageUsage)
+				r.Get("/admin/storage/usage", h.admin.StorageTrends)
+				r.Get("/admin/backups", h.admin.Backups)
+				r.Get("/admin/system-emails", h.admin.SystemEmails)
+				r.Get("/admin/download-links", h.admin.DownloadLinks)
+				r.Get("/admin/sync-peers", h.peers.List)
+				r.Post("/admin/sync-peers", h.peers.Create)
+				r.Delete("/admin/sync-peers/{id}", h.peers.Delete)
+				r.Post("/admin/sync-peers/{id}/sync", h.peers.Sync)
+				r.Get("/admin/api-keys", h.apiKeys.AdminList)
+				r.Delete("/admin/api-keys/{id}", h.apiKeys.AdminRevoke)
+				r.Get("/admin/send-usage", h.admin.SendUsage)
+				r.Get("/admin/rate-limits", h.admin.RateLimits)
+				r.Get("/admin/smtp-pool", h.admin.SMTPPoolStats)
+				r.Get("/admin/blocklist", h.blocklist.List)
+				r.Post("/admin/blocklist", h.blocklist.Create)
+				r.Delete("/admin/blocklist/{id}", h.blocklist.Delete)
+				r.Delete("/admin/blocklist/flagged/{ip}", h.blocklist.Unflag)
+				r.Post("/admin/backups", h.admin.RunBackup)
+				r.Get("/admin/jobs", h.admin.ListJobs)
+				r.Get("/admin/jobs/runs", h.admin.ListJobRuns)
+				r.Post("/admin/jobs/{type}", h.admin.StartJob)
+				r.Post("/admin/jobs/{id}/cancel", h.admin.CancelJob)
+				r.Get("/admin/jobs/{id}", h.admin.GetJob)
+				r.Get("/admin/search", h.admin.SearchStatus)
+				r.Post("/admin/search/reindex", h.admin.ReindexSearch)
+				r.Get("/admin/telemetry", h.admin.TelemetryReport)
+				r.Get("/admin/settings", h.settings.Get)
+				r.Patch("/admin/settings", h.settings.Patch)
+			})
+			// Kindle config (per user): any authenticated user
+			r.Get("/email-config", h.emailConfig.Get)
+			r.Put("/email-config", h.emailConfig.Save)
+			r.Patch("/email-config", h.emailConfig.Save)
+			r.Post("/email-config/verification", h.emailConfig.SendVerification)
+			r.Post("/email-config/verification/confirm", h.emailConfig.ConfirmVerification)
+		})
+	})
+
+	return r
+}
+
+// readiness is the body of GET /health/ready.
+type readiness struct {
+	Status       string `json:"status"`   // ok, degraded (storage unavailable) or unavailable (database unreachable)
+	Database     string `json:"database"` // ok or unreachable
+	Storage      string `json:"storage"`  // ok, unavailable or not configured
+	StorageError string `json:"storageError,omitempty"`
+}
+
+// ready reports whether this instance can serve: 200 while the database is reachable, even if storage is not (the
+// catalog still works; uploads and downloads return 503 until it connects), else 503.
+func (a *App) ready(w http.ResponseWriter, r *http.Request) {
+	res := readiness{Status: "ok", Database: "ok", Storage: "ok"}
+	switch {
+	case a.deps.Storage == nil:
+		res.Storage = "not configured"
+	case service.StorageErr(a.deps.Storage) != nil:
+		res.Status, res.Storage = "degraded", "unavailable"
+		res.StorageError = service.StorageErr(a.deps.Storage).Error()
+	}
+	status := http.StatusOK
+	if !a.deps.Store.Healthy() {
+		res.Status, res.Database = "unavailable", "unreachable"
+		status = http.StatusServiceUnavailable
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(res)
+}
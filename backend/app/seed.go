@@ -0,0 +1,65 @@
+package app
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/kevinaaaquil/books/backend/models"
+	"github.com/kevinaaaquil/books/backend/store"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func seedBootstrapUser(ctx context.Context, db store.Store, email, password string) error {
+	count, err := db.UsersCount(ctx)
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	user := &models.User{
+		Email:     email,
+		Password:  string(hash),
+		Role:      models.RoleAdmin,
+		CreatedAt: time.Now(),
+	}
+	_, err = db.CreateUser(ctx, user)
+	if err != nil {
+		return err
+	}
+	log.Println("created bootstrap admin user from env (users collection was empty)")
+	return nil
+}
+
+const guestUserEmail = "guest@guest.local"
+
+func seedGuestUser(ctx context.Context, db store.Store) error {
+	existing, err := db.UserByRole(ctx, models.RoleGuest)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		return nil
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte("guest"), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	user := &models.User{
+		Email:     guestUserEmail,
+		Password:  string(hash),
+		Role:      models.RoleGuest,
+		CreatedAt: time.Now(),
+	}
+	_, err = db.CreateUser(ctx, user)
+	if err != nil {
+		return err
+	}
+	log.Println("created bootstrap guest user for View as guest")
+	return nil
+}
@@ -0,0 +1,236 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kevinaaaquil/books/backend/handlers"
+	"github.com/kevinaaaquil/books/backend/jobs"
+	"github.com/kevinaaaquil/books/backend/models"
+	"github.com/kevinaaaquil/books/backend/service"
+)
+
+// watchFailedDir is the directory under the watch folder that files which could not be ingested are moved to,
+// each with a .error note saying why. Like every directory starting with a dot, it is not scanned.
+const watchFailedDir = ".failed"
+
+// folderWatcher ingests the EPUB and PDF files that appear under a directory, such as the target of Calibre's
+// "Save to disk", through the upload pipeline. Polling rather than file system events works on NFS and other
+// network mounts; a file is only picked up once it has kept the same size and modification time between two
+// scans, so one still being written is left alone.
+type folderWatcher struct {
+	dir        string
+	archiveDir string // ingested files are moved here, keeping their path under dir; empty deletes them
+	uploadedBy string
+	maxBytes   int64 // larger files fail; 0 = no limit
+	ingest     func(context.Context, handlers.IngestFile) (*handlers.IngestResult, error)
+
+	seen  map[string]fileState // candidates from the last scan, by path
+	stuck map[string]fileState // ingested files that could not be moved away, so are not ingested again
+}
+
+type fileState struct {
+	size    int64
+	modTime time.Time
+}
+
+func (a *App) newFolderWatcher() *folderWatcher {
+	return &folderWatcher{
+		dir:        a.cfg.WatchDir,
+		archiveDir: a.cfg.WatchArchiveDir,
+		uploadedBy: a.cfg.WatchUploadedBy,
+		maxBytes:   a.upload.MaxBytes,
+		ingest:     a.upload.Ingest,
+		seen:       map[string]fileState{},
+		stuck:      map[string]fileState{},
+	}
+}
+
+// watchFolder scans cfg.WatchDir every cfg.WatchInterval until ctx is cancelled. With several instances only the
+// leader scans, so a shared folder is ingested once; nobody scans while storage is unavailable.
+func (a *App) watchFolder(ctx context.Context, leader *jobs.Leader) {
+	w := a.newFolderWatcher()
+	log.Printf("watch folder: scanning %s every %s", w.dir, a.cfg.WatchInterval)
+	ticker := time.NewTicker(a.cfg.WatchInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if !leader.IsLeader() || service.StorageErr(a.deps.Storage) != nil {
+			continue
+		}
+		w.scan(ctx)
+	}
+}
+
+// scan ingests the files that have not changed since the last scan and remembers the rest for the next one.
+func (w *folderWatcher) scan(ctx context.Context) {
+	current := map[string]fileState{}
+	err := filepath.WalkDir(w.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			log.Printf("watch folder: %v", err)
+			return nil
+		}
+		if strings.HasPrefix(d.Name(), ".") && path != w.dir {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			if w.archiveDir != "" && path == filepath.Clean(w.archiveDir) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !models.IsBookFile(path) || !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		current[path] = fileState{size: info.Size(), modTime: info.ModTime()}
+		return nil
+	})
+	if err != nil {
+		log.Printf("watch folder: %v", err)
+		return
+	}
+	for path, state := range current {
+		if ctx.Err() != nil {
+			return
+		}
+		if prev, ok := w.seen[path]; !ok || prev != state {
+			continue
+		}
+		if stuck, ok := w.stuck[path]; ok && stuck == state {
+			continue
+		}
+		if w.ingestFile(ctx, path, state) {
+			delete(current, path)
+		}
+	}
+	w.seen = current
+}
+
+// ingestFile uploads the file at path and moves it out of the watch folder: to the archive when it was ingested,
+// else to watchFailedDir. It reports whether the file was dealt with; it is left in place (false) when storage
+// went away, to be tried again.
+func (w *folderWatcher) ingestFile(ctx context.Context, path string, state fileState) bool {
+	rel, err := filepath.Rel(w.dir, path)
+	if err != nil {
+		rel = filepath.Base(path)
+	}
+	var res *handlers.IngestResult
+	if w.maxBytes > 0 && state.size > w.maxBytes {
+		err = fmt.Errorf("file is %d bytes, over the upload limit of %d", state.size, w.maxBytes)
+	} else {
+		var data []byte
+		data, err = os.ReadFile(path)
+		if err == nil {
+			res, err = w.ingest(ctx, handlers.IngestFile{Name: filepath.Base(path), Data: data, UploadedBy: w.uploadedBy, Source: watchSource(path), SourcePath: rel})
+		}
+	}
+	if errors.Is(err, service.ErrStorageUnavailable) || ctx.Err() != nil {
+		return false
+	}
+	if err != nil {
+		log.Printf("watch folder: %s: %v", rel, err)
+		failed, moveErr := moveFile(path, filepath.Join(w.dir, watchFailedDir, rel))
+		if moveErr != nil {
+			log.Printf("watch folder: %s: move to %s: %v", rel, watchFailedDir, moveErr)
+			return false
+		}
+		os.WriteFile(failed+".error", []byte(err.Error()+"\n"), 0o644)
+		w.removeEmptyDirs(filepath.Dir(path))
+		return true
+	}
+	log.Printf("watch folder: %s: added %q (%s)", rel, res.Book.Title, res.Book.ID.Hex())
+	if w.archiveDir == "" {
+		err = os.Remove(path)
+	} else {
+		_, err = moveFile(path, filepath.Join(w.archiveDir, rel))
+	}
+	if err != nil {
+		// The book is in the library; the file must not be added again while it stays as it is.
+		log.Printf("watch folder: %s: ingested but not moved away: %v", rel, err)
+		w.stuck[path] = state
+		return true
+	}
+	w.removeEmptyDirs(filepath.Dir(path))
+	return true
+}
+
+// removeEmptyDirs removes dir and its parents up to the watch folder while they are empty, so the folders a
+// Calibre export creates per author and title go away once their books have been ingested.
+func (w *folderWatcher) removeEmptyDirs(dir string) {
+	root := filepath.Clean(w.dir)
+	for dir = filepath.Clean(dir); dir != root && strings.HasPrefix(dir, root+string(filepath.Separator)); dir = filepath.Dir(dir) {
+		if os.Remove(dir) != nil {
+			return
+		}
+	}
+}
+
+// moveFile moves src to dst, creating dst's directory, and returns where it went: dst, or when dst exists, dst
+// with a number added to its name. It copies when a rename is not possible (e.g. across file systems).
+func moveFile(src, dst string) (string, error) {
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return "", err
+	}
+	ext := filepath.Ext(dst)
+	base := strings.TrimSuffix(dst, ext)
+	for i := 1; ; i++ {
+		if _, err := os.Lstat(dst); errors.Is(err, fs.ErrNotExist) {
+			break
+		}
+		dst = base + "-" + strconv.Itoa(i) + ext
+	}
+	if err := os.Rename(src, dst); err == nil {
+		return dst, nil
+	}
+	if err := copyFile(src, dst); err != nil {
+		os.Remove(dst)
+		return "", err
+	}
+	return dst, os.Remove(src)
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// watchSource is the source of a file in the watch folder: Calibre when it is in a book folder of a Calibre
+// library or export, which has a metadata.opf next to the book.
+func watchSource(path string) string {
+	if _, err := os.Stat(filepath.Join(filepath.Dir(path), "metadata.opf")); err == nil {
+		return models.SourceCalibre
+	}
+	return models.SourceWatchFolder
+}
@@ -0,0 +1,207 @@
+// Package demo seeds sample users and books for --demo mode.
+package demo
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"fmt"
+	"html"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/kevinaaaquil/books/backend/models"
+	"github.com/kevinaaaquil/books/backend/service"
+	"github.com/kevinaaaquil/books/backend/store"
+	"github.com/kevinaaaquil/books/backend/utils"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Password is shared by the seeded editor and viewer accounts.
+const Password = "demo"
+
+// Users are created in addition to the bootstrap admin and guest.
+var Users = []struct{ Email, Role string }{
+	{"editor@demo.local", models.RoleEditor},
+	{"viewer@demo.local", models.RoleViewer},
+}
+
+type sampleBook struct {
+	title, author, year, isbn, format, category string
+	viewByGuest                                 bool
+	text                                        string
+}
+
+// Public-domain titles; the files are generated, holding only an opening line.
+var books = []sampleBook{
+	{"Pride and Prejudice", "Jane Austen", "1813", "9780141439518", "epub", "Fiction", true,
+		"It is a truth universally acknowledged, that a single man in possession of a good fortune, must be in want of a wife."},
+	{"Moby-Dick", "Herman Melville", "1851", "9780142437247", "epub", "Fiction", true,
+		"Call me Ishmael. Some years ago, never mind how long precisely, having little or no money in my purse, and nothing particular to interest me on shore, I thought I would sail about a little and see the watery part of the world."},
+	{"Frankenstein", "Mary Shelley", "1818", "9780141439471", "epub", "Horror", false,
+		"You will rejoice to hear that no disaster has accompanied the commencement of an enterprise which you have regarded with such evil forebodings."},
+	{"The Adventures of Sherlock Holmes", "Arthur Conan Doyle", "1892", "", "pdf", "Mystery", true,
+		"To Sherlock Holmes she is always the woman."},
+	{"Alice's Adventures in Wonderland", "Lewis Carroll", "1865", "", "epub", "Fantasy", false,
+		"Alice was beginning to get very tired of sitting by her sister on the bank, and of having nothing to do."},
+	{"The Time Machine", "H. G. Wells", "1895", "", "pdf", "Science Fiction", false,
+		"The Time Traveller (for so it will be convenient to speak of him) was expounding a recondite matter to us."},
+}
+
+// Seed creates the demo users and uploads the sample books to storage. Meant for an empty in-memory store.
+func Seed(ctx context.Context, db store.Store, storage service.ObjectStore, uploadedBy string) error {
+	hash, err := bcrypt.GenerateFromPassword([]byte(Password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	for _, u := range Users {
+		if _, err := db.CreateUser(ctx, &models.User{Email: u.Email, Password: string(hash), Role: u.Role, CreatedAt: time.Now()}); err != nil {
+			return fmt.Errorf("user %s: %w", u.Email, err)
+		}
+	}
+	now := time.Now()
+	for i, b := range books {
+		var file []byte
+		var contentType string
+		if b.format == "epub" {
+			file, err = epubFile(b)
+			contentType = "application/epub+zip"
+		} else {
+			file = pdfFile(b)
+			contentType = "application/pdf"
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", b.title, err)
+		}
+		info, err := utils.ComputeFileInfo(file, b.format)
+		if err != nil {
+			return fmt.Errorf("%s: %w", b.title, err)
+		}
+		filename := strings.ReplaceAll(b.title, " ", "_") + "." + b.format
+		key, err := storage.UploadWithSHA256(ctx, "books/", filename, bytes.NewReader(file), contentType, info.SHA256)
+		if err != nil {
+			return fmt.Errorf("%s: %w", b.title, err)
+		}
+		coverKey, err := storage.Upload(ctx, "books/covers/", "cover.svg", strings.NewReader(coverSVG(b, i)), "image/svg+xml")
+		if err != nil {
+			return fmt.Errorf("%s cover: %w", b.title, err)
+		}
+		book := &models.Book{
+			Title:           b.title,
+			Authors:         []string{b.author},
+			PublishDate:     b.year,
+			ISBN:            b.isbn,
+			Category:        b.category,
+			Categories:      []string{b.category},
+			CoverS3Key:      coverKey,
+			Format:          b.format,
+			S3Key:           key,
+			OriginalName:    filename,
+			FileInfo:        info,
+			UploadedByEmail: uploadedBy,
+			ViewByGuest:     b.viewByGuest,
+			CreatedAt:       now.Add(-time.Duration(len(books)-i) * 24 * time.Hour), // spread over the last week for the timeline
+		}
+		if _, err := db.InsertBook(ctx, book); err != nil {
+			return fmt.Errorf("%s: %w", b.title, err)
+		}
+	}
+	log.Printf("demo: seeded %d books and %d users", len(books), len(Users))
+	return nil
+}
+
+// epubFile builds a minimal valid EPUB 3 with one chapter.
+func epubFile(b sampleBook) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	// mimetype must be the first entry and stored uncompressed.
+	w, err := zw.CreateHeader(&zip.FileHeader{Name: "mimetype", Method: zip.Store})
+	if err != nil {
+		return nil, err
+	}
+	w.Write([]byte("application/epub+zip"))
+	title, author := html.EscapeString(b.title), html.EscapeString(b.author)
+	identifier := "urn:uuid:demo-" + strings.ToLower(strings.ReplaceAll(b.title, " ", "-"))
+	if b.isbn != "" {
+		identifier = "urn:isbn:" + b.isbn
+	}
+	files := []struct{ name, body string }{
+		{"META-INF/container.xml", `<?xml version="1.0"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+  <rootfiles><rootfile full-path="OEBPS/content.opf" media-type="application/oebps-package+xml"/></rootfiles>
+</container>`},
+		{"OEBPS/content.opf", `<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0" unique-identifier="id">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+    <dc:identifier id="id">` + identifier + `</dc:identifier>
+    <dc:title>` + title + `</dc:title>
+    <dc:creator>` + author + `</dc:creator>
+    <dc:date>` + b.year + `</dc:date>
+    <dc:language>en</dc:language>
+  </metadata>
+  <manifest>
+    <item id="nav" href="nav.xhtml" media-type="application/xhtml+xml" properties="nav"/>
+    <item id="ch1" href="chapter1.xhtml" media-type="application/xhtml+xml"/>
+  </manifest>
+  <spine><itemref idref="ch1"/></spine>
+</package>`},
+		{"OEBPS/nav.xhtml", `<?xml version="1.0" encoding="UTF-8"?>
+<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops"><head><title>` + title + `</title></head>
+<body><nav epub:type="toc"><ol><li><a href="chapter1.xhtml">Chapter 1</a></li></ol></nav></body></html>`},
+		{"OEBPS/chapter1.xhtml", `<?xml version="1.0" encoding="UTF-8"?>
+<html xmlns="http://www.w3.org/1999/xhtml"><head><title>` + title + `</title></head>
+<body><h1>Chapter 1</h1><p>` + html.EscapeString(b.text) + `</p></body></html>`},
+	}
+	for _, f := range files {
+		w, err := zw.Create(f.name)
+		if err != nil {
+			return nil, err
+		}
+		w.Write([]byte(f.body))
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// pdfFile builds a one-page PDF showing the title, author and opening line.
+func pdfFile(b sampleBook) []byte {
+	esc := strings.NewReplacer(`\`, `\\`, `(`, `\(`, `)`, `\)`)
+	stream := fmt.Sprintf("BT /F1 24 Tf 72 720 Td (%s) Tj 0 -32 Td /F1 14 Tf (%s, %s) Tj 0 -40 Td /F1 11 Tf (%s) Tj ET",
+		esc.Replace(b.title), esc.Replace(b.author), b.year, esc.Replace(b.text))
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] /Contents 4 0 R /Resources << /Font << /F1 5 0 R >> >> >>",
+		fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(stream), stream),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>",
+		fmt.Sprintf("<< /Title (%s) /Author (%s) >>", esc.Replace(b.title), esc.Replace(b.author)),
+	}
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R /Info %d 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, len(objects), xref)
+	return buf.Bytes()
+}
+
+var coverColors = []string{"#7c2d12", "#1e3a8a", "#14532d", "#4c1d95", "#831843", "#134e4a"}
+
+// coverSVG draws a plain coloured cover with the title and author.
+func coverSVG(b sampleBook, i int) string {
+	return fmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg" width="400" height="600" viewBox="0 0 400 600">
+<rect width="400" height="600" fill="%s"/>
+<text x="200" y="260" fill="#fff" font-family="Georgia, serif" font-size="22" text-anchor="middle">%s</text>
+<text x="200" y="320" fill="#e5e7eb" font-family="Georgia, serif" font-size="20" text-anchor="middle">%s</text>
+</svg>`, coverColors[i%len(coverColors)], html.EscapeString(b.title), html.EscapeString(b.author))
+}
@@ -0,0 +1,203 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	batchSize     = 100             // events per delivery
+	batchInterval = time.Second     // longest an event waits for its batch
+	batchBuffer   = 4096            // events waiting to be delivered; more are dropped
+	batchAttempts = 3               // deliveries of a batch before it is given up
+	batchBackoff  = 2 * time.Second // before the second delivery, doubling after
+)
+
+// batcher delivers events in batches from a goroutine, retrying a failed batch a few times. When the sink falls
+// behind, events are dropped rather than slowing requests down, and the drops are logged.
+type batcher struct {
+	name    string
+	deliver func(ctx context.Context, batch []Event) error
+	queue   chan Event
+	done    chan struct{}
+	stop    chan struct{} // closed by Close, to cut retries short
+
+	mu      sync.Mutex // held while emitting, so Close never closes queue under a send
+	dropped int
+	closed  bool
+}
+
+func newBatcher(name string, deliver func(ctx context.Context, batch []Event) error) *batcher {
+	b := &batcher{
+		name:    name,
+		deliver: deliver,
+		queue:   make(chan Event, batchBuffer),
+		done:    make(chan struct{}),
+		stop:    make(chan struct{}),
+	}
+	go b.run()
+	return b
+}
+
+// Emit queues e for delivery; events emitted after Close are dropped.
+func (b *batcher) Emit(e Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return
+	}
+	select {
+	case b.queue <- e:
+	default:
+		b.dropped++
+	}
+}
+
+// Close delivers the events still queued, with one attempt per batch.
+func (b *batcher) Close() error {
+	b.mu.Lock()
+	if !b.closed {
+		b.closed = true
+		close(b.stop)
+		close(b.queue)
+	}
+	b.mu.Unlock()
+	<-b.done
+	return nil
+}
+
+func (b *batcher) run() {
+	defer close(b.done)
+	ticker := time.NewTicker(batchInterval)
+	defer ticker.Stop()
+	var batch []Event
+	for {
+		select {
+		case e, ok := <-b.queue:
+			if !ok {
+				b.send(batch)
+				return
+			}
+			if batch = append(batch, e); len(batch) >= batchSize {
+				b.send(batch)
+				batch = nil
+			}
+		case <-ticker.C:
+			b.send(batch)
+			batch = nil
+		}
+	}
+}
+
+func (b *batcher) send(batch []Event) {
+	b.mu.Lock()
+	dropped := b.dropped
+	b.dropped = 0
+	b.mu.Unlock()
+	if dropped > 0 {
+		log.Printf("events: %s: dropped %d events; the sink is not keeping up", b.name, dropped)
+	}
+	if len(batch) == 0 {
+		return
+	}
+	backoff := batchBackoff
+	for attempt := 1; ; attempt++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		err := b.deliver(ctx, batch)
+		cancel()
+		if err == nil {
+			return
+		}
+		if attempt == batchAttempts {
+			log.Printf("events: %s: %v; %d events lost", b.name, err, len(batch))
+			return
+		}
+		select {
+		case <-time.After(backoff):
+			backoff *= 2
+		case <-b.stop:
+			log.Printf("events: %s: %v; %d events lost at shutdown", b.name, err, len(batch))
+			return
+		}
+	}
+}
+
+// postJSON posts body to url and fails unless the answer is 2xx.
+func postJSON(ctx context.Context, client *http.Client, url, contentType string, body []byte, header http.Header) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", contentType)
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("%s answered %d", url, resp.StatusCode)
+	}
+	return nil
+}
+
+// NewWebhook returns a sink POSTing batches of events to url as a JSON array. With a secret, each body is signed
+// with HMAC-SHA256 in X-Books-Signature: sha256=<hex>, as S3 event relays sign theirs. client nil = a client with a
+// 10s timeout.
+func NewWebhook(url, secret string, client *http.Client) Sink {
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	return newBatcher("webhook", func(ctx context.Context, batch []Event) error {
+		body, err := json.Marshal(batch)
+		if err != nil {
+			return err
+		}
+		header := http.Header{}
+		if secret != "" {
+			mac := hmac.New(sha256.New, []byte(secret))
+			mac.Write(body)
+			header.Set("X-Books-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+		}
+		return postJSON(ctx, client, url, "application/json", body, header)
+	})
+}
+
+// NewKafkaREST returns a sink producing events to a Kafka topic through a Kafka REST proxy (Confluent's, or
+// Redpanda's HTTP proxy), by POSTing batches to topicURL, e.g. http://rest-proxy:8082/topics/books-events, in the
+// v2 JSON format. Events are keyed by user, so each user's events stay in order. client nil = a client with a
+// 10s timeout.
+func NewKafkaREST(topicURL string, client *http.Client) Sink {
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	type record struct {
+		Key   string `json:"key,omitempty"`
+		Value Event  `json:"value"`
+	}
+	return newBatcher("kafka", func(ctx context.Context, batch []Event) error {
+		records := make([]record, len(batch))
+		for i, e := range batch {
+			records[i] = record{Key: e.UserID, Value: e}
+		}
+		body, err := json.Marshal(map[string]any{"records": records})
+		if err != nil {
+			return err
+		}
+		header := http.Header{"Accept": {"application/vnd.kafka.v2+json"}}
+		return postJSON(ctx, client, topicURL, "application/vnd.kafka.json.v2+json", body, header)
+	})
+}
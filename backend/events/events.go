@@ -0,0 +1,167 @@
+// Package events exports domain events (books added and deleted, download links issued, sends finished) to the
+// configured sinks: JSON lines files, webhooks, NATS subjects and Kafka topics, for dashboards in an analytics stack.
+package events
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Event types.
+const (
+	TypeBookCreated    = "book.created"    // data: Book
+	TypeBookDeleted    = "book.deleted"    // data: Book
+	TypeDownloadIssued = "download.issued" // data: Download
+	TypeSendCompleted  = "send.completed"  // data: Send
+	TypeSendFailed     = "send.failed"     // data: Send, with the error; not sent for attempts that are retried
+)
+
+// Event is one thing that happened, as exported: a JSON object with the type's data under "data".
+type Event struct {
+	ID     string    `json:"id"` // unique; sinks may deliver an event twice, so consumers dedupe on it
+	Type   string    `json:"type"`
+	Time   time.Time `json:"time"`
+	UserID string    `json:"userId,omitempty"` // the user who caused it, if any
+	Data   any       `json:"data"`
+}
+
+// Book is the data of book.created and book.deleted.
+type Book struct {
+	BookID    string   `json:"bookId"`
+	Title     string   `json:"title"`
+	Authors   []string `json:"authors,omitempty"`
+	ISBN      string   `json:"isbn,omitempty"`
+	Format    string   `json:"format"`
+	SizeBytes int64    `json:"sizeBytes"`
+	Source    string   `json:"source,omitempty"` // how it was added, as models.Book.Source, e.g. "upload" or "arxiv"
+}
+
+// Download is the data of download.issued: a link to a book's file handed out, or a file streamed.
+type Download struct {
+	BookID string `json:"bookId"`
+	Kind   string `json:"kind"` // as in the download link audit: "presigned", "stream", "feed", "bundle" or "sync"
+	Role   string `json:"role"`
+	Bytes  int64  `json:"bytes"`
+}
+
+// Send is the data of send.completed and send.failed.
+type Send struct {
+	JobID       string `json:"jobId"`
+	BookID      string `json:"bookId"`
+	Format      string `json:"format,omitempty"`
+	Destination string `json:"destination"` // "kindle", "device" or a delivery target's kind
+	Attempts    int    `json:"attempts"`
+	Error       string `json:"error,omitempty"`
+}
+
+// New returns an event of type typ that happened at at, with a new ID.
+func New(typ string, at time.Time, userID string, data any) Event {
+	return Event{ID: uuid.NewString(), Type: typ, Time: at.UTC(), UserID: userID, Data: data}
+}
+
+// Sink receives events. Emit must not block for long; sinks that send over the network buffer.
+type Sink interface {
+	Emit(Event)
+	Close() error // flushes buffered events
+}
+
+// Multi emits to every sink; empty, it drops events.
+type Multi []Sink
+
+func (m Multi) Emit(e Event) {
+	for _, s := range m {
+		s.Emit(e)
+	}
+}
+
+func (m Multi) Close() error {
+	var errs []error
+	for _, s := range m {
+		errs = append(errs, s.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// File appends events to a file as JSON lines.
+type File struct {
+	mu  sync.Mutex
+	f   *os.File
+	enc *json.Encoder
+}
+
+// OpenFile returns a sink appending to path, which is created if needed.
+func OpenFile(path string) (*File, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o640)
+	if err != nil {
+		return nil, err
+	}
+	return &File{f: f, enc: json.NewEncoder(f)}, nil
+}
+
+func (f *File) Emit(e Event) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.enc.Encode(e)
+}
+
+func (f *File) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.f.Close()
+}
+
+// Options configure the sinks Open returns.
+type Options struct {
+	WebhookSecret string // signs webhook bodies; empty = unsigned
+}
+
+// Open returns the sinks of EVENT_SINKS entries: file:/path (JSON lines), webhook:https://host/path (batches
+// POSTed as JSON arrays), nats://[user:pass@]host:4222/subject, and kafka:http://rest-proxy:8082/topics/topic
+// (through a Kafka REST proxy). An empty list exports nothing.
+func Open(sinks []string, opts Options) (Sink, error) {
+	var out Multi
+	fail := func(err error) (Sink, error) {
+		out.Close()
+		return nil, err
+	}
+	for _, s := range sinks {
+		kind, arg, _ := strings.Cut(s, ":")
+		switch {
+		case kind == "file" && arg != "":
+			f, err := OpenFile(arg)
+			if err != nil {
+				return fail(err)
+			}
+			out = append(out, f)
+		case kind == "webhook" && isHTTPURL(arg):
+			out = append(out, NewWebhook(arg, opts.WebhookSecret, nil))
+		case kind == "kafka" && isHTTPURL(arg):
+			out = append(out, NewKafkaREST(arg, nil))
+		case kind == "nats":
+			n, err := NewNATS(s)
+			if err != nil {
+				return fail(fmt.Errorf("nats event sink: %w", err)) // the URL may hold a password
+			}
+			out = append(out, n)
+		default:
+			return fail(fmt.Errorf("unknown event sink %q", s))
+		}
+	}
+	if len(out) == 1 {
+		return out[0], nil
+	}
+	return out, nil
+}
+
+func isHTTPURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
@@ -0,0 +1,504 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/kevinaaaquil/books/backend/jobs"
+	"github.com/kevinaaaquil/books/backend/middleware"
+	"github.com/kevinaaaquil/books/backend/models"
+	"github.com/kevinaaaquil/books/backend/notify"
+	"github.com/kevinaaaquil/books/backend/search"
+	"github.com/kevinaaaquil/books/backend/service"
+	"github.com/kevinaaaquil/books/backend/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// AdminHandler serves admin-only maintenance endpoints under /api/admin.
+type AdminHandler struct {
+	DB      store.Store
+	Storage service.ObjectStore
+	Jobs    *jobs.Runner
+	Notify  *notify.Service
+	Clock   service.Clock
+	Backup  BackupSettings
+	Search  *search.Index
+	// SendLimits are the per-role Send to Kindle limits, for SendUsage.
+	SendLimits map[string]models.SendLimit
+	// RateLimiter limits the expensive endpoints; RateLimits reports its counts.
+	RateLimiter *middleware.RateLimiter
+	// SMTPPool holds the connections SMTP sends reuse; nil when each send opens its own.
+	SMTPPool *service.SMTPPool
+	// Metadata is searched for new releases by authors users have read; providers without a
+	// service.AuthorSearcher (see service.AuthorSearcherOf) can't be.
+	Metadata service.MetadataProvider
+	// Prices are the stores the price watch job asks; none disables it.
+	Prices []service.PriceProvider
+	// Classifier suggests categories for the classify-books job; nil disables it.
+	Classifier service.Classifier
+	// Embedder embeds books for the embed-books job, into Vectors; nil disables it.
+	Embedder service.Embedder
+	Vectors  *search.Vectors
+	// Schedules are the cron schedules jobs run on, by job type, for ListJobs (the backup's is in Backup).
+	Schedules map[string]string
+	// Quotas are the soft storage quotas, for StorageTrends and the storage alerts job.
+	Quotas jobs.StorageQuotas
+	// ColdStorage configures the cold storage job; AfterMonths 0 disables it.
+	ColdStorage jobs.ColdStorageOptions
+	// MigrationTarget is where the migrate-storage job copies files; nil disables it.
+	MigrationTarget service.ObjectStore
+	// Telemetry configures the telemetry job; URL "" means the instance did not opt in.
+	Telemetry jobs.TelemetryOptions
+}
+
+// BackupSettings is the backup schedule and retention policy from config.
+type BackupSettings struct {
+	Schedule   string
+	KeepDaily  int
+	KeepWeekly int
+}
+
+// BackupJob returns the backup job for the given trigger ("scheduled" or "manual").
+func (h *AdminHandler) BackupJob(trigger string) jobs.Func {
+	return jobs.Backup(h.DB, h.Storage, h.Notify, jobs.BackupOptions{
+		KeepDaily:  h.Backup.KeepDaily,
+		KeepWeekly: h.Backup.KeepWeekly,
+		Trigger:    trigger,
+	})
+}
+
+// NewReleasesJob returns the new releases job, or false when the metadata provider can't search by author.
+func (h *AdminHandler) NewReleasesJob() (jobs.Func, bool) {
+	search, ok := service.AuthorSearcherOf(h.Metadata)
+	if !ok {
+		return nil, false
+	}
+	return jobs.NewReleases(h.DB, search, h.Notify), true
+}
+
+// DigestJob returns the weekly digest job, or false when system mail is not configured.
+func (h *AdminHandler) DigestJob() (jobs.Func, bool) {
+	if h.Notify == nil || h.Notify.Mail == nil {
+		return nil, false
+	}
+	return jobs.Digest(h.DB, h.Notify.Mail), true
+}
+
+// PriceWatchJob returns the price watch job, or false when no price providers are configured.
+func (h *AdminHandler) PriceWatchJob() (jobs.Func, bool) {
+	if len(h.Prices) == 0 {
+		return nil, false
+	}
+	return jobs.PriceWatch(h.DB, h.Prices, h.Notify), true
+}
+
+// TelemetryJob returns the telemetry job, or false when the instance did not opt in.
+func (h *AdminHandler) TelemetryJob() (jobs.Func, bool) {
+	if h.Telemetry.URL == "" {
+		return nil, false
+	}
+	return jobs.Telemetry(h.DB, h.Telemetry), true
+}
+
+// ColdStorageJob returns the cold storage job, or why it can't run.
+func (h *AdminHandler) ColdStorageJob() (jobs.Func, string) {
+	if h.ColdStorage.AfterMonths <= 0 {
+		return nil, "COLD_STORAGE_AFTER_MONTHS is not set"
+	}
+	if h.Storage == nil {
+		return nil, "storage is not configured"
+	}
+	if err := service.StorageErr(h.Storage); err != nil {
+		return nil, err.Error()
+	}
+	tier, ok := service.TiererOf(h.Storage)
+	if !ok {
+		return nil, "storage has no storage classes"
+	}
+	return jobs.ColdStorage(h.DB, tier, h.ColdStorage), ""
+}
+
+// libraryHealthChecks describes each health check in report order, with the suggested remediation.
+var libraryHealthChecks = []models.LibraryHealthCheck{
+	{
+		Issue:       models.IssueMissingCover,
+		Description: "No API cover and no cover extracted from the file",
+		Remediation: models.Remediation{Action: "refresh-metadata", Description: "Refresh metadata by ISBN to pick up a cover", Method: http.MethodPost, Href: "/api/books/{id}/refresh-metadata"},
+	},
+	{
+		Issue:       models.IssueMissingISBN,
+		Description: "No ISBN, so metadata cannot be looked up",
+		Remediation: models.Remediation{Action: "refresh-metadata", Description: "Refresh metadata with a manually supplied ISBN in the request body", Method: http.MethodPost, Href: "/api/books/{id}/refresh-metadata"},
+	},
+	{
+		Issue:       models.IssueZeroPageCount,
+		Description: "Page count is missing or zero",
+		Remediation: models.Remediation{Action: "refresh-metadata", Description: "Refresh metadata by ISBN to fill in the page count", Method: http.MethodPost, Href: "/api/books/{id}/refresh-metadata"},
+	},
+	{
+		Issue:       models.IssueMetadataFailed,
+		Description: "The last metadata lookup failed",
+		Remediation: models.Remediation{Action: "refresh-metadata", Description: "Retry the metadata lookup, optionally with a corrected ISBN", Method: http.MethodPost, Href: "/api/books/{id}/refresh-metadata"},
+	},
+	{
+		Issue:       models.IssueUnreadableEPUB,
+		Description: "The EPUB could not be parsed at upload",
+		Remediation: models.Remediation{Action: "backfill-file-info", Description: "Re-validate all files; if the EPUB is still unreadable, delete the book and upload a valid copy", Method: http.MethodPost, Href: "/api/admin/jobs/backfill-file-info?all=true"},
+	},
+	{
+		Issue:       models.IssueSmallFile,
+		Description: "The stored file is under 10 KB, likely truncated or a placeholder",
+		Remediation: models.Remediation{Action: "verify-storage", Description: "Run storage verification, then replace the file if it is confirmed broken", Method: http.MethodPost, Href: "/api/admin/jobs/verify-storage"},
+	},
+	{
+		Issue:       models.IssueStorageProblem,
+		Description: "The last storage verification found the file or cover missing or corrupted",
+		Remediation: models.Remediation{Action: "verify-storage", Description: "Re-upload the file, then re-run storage verification", Method: http.MethodPost, Href: "/api/admin/jobs/verify-storage"},
+	},
+	{
+		Issue:       models.IssueUploadFailed,
+		Description: "A step of the upload (metadata lookup or storing the cover) failed after retries",
+		Remediation: models.Remediation{Action: "retry-upload", Description: "Run the failed upload steps again", Method: http.MethodPost, Href: "/api/books/{id}/retry-upload"},
+	},
+}
+
+// LibraryHealth reports books with missing covers, ISBNs or page counts, failed metadata lookups and unreadable EPUBs. GET /api/admin/library/health (admin only).
+func (h *AdminHandler) LibraryHealth(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	total, err := h.DB.BooksCount(r.Context())
+	if err != nil {
+		http.Error(w, `{"error":"failed to build health report"}`, http.StatusInternalServerError)
+		return
+	}
+	byIssue, err := h.DB.BooksWithHealthIssues(r.Context())
+	if err != nil {
+		http.Error(w, `{"error":"failed to build health report"}`, http.StatusInternalServerError)
+		return
+	}
+	report := models.LibraryHealthReport{GeneratedAt: h.Clock.Now(), TotalBooks: total}
+	for _, check := range libraryHealthChecks {
+		books := byIssue[check.Issue]
+		if books == nil {
+			books = []models.BookRef{}
+		}
+		check.Books = books
+		check.Count = len(books)
+		report.Checks = append(report.Checks, check)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+// ReindexSearch starts the job rebuilding the search index from every book's metadata and EPUB text, needed after
+// changes to how books are indexed or a restore from backup. Searches use the old index until it completes.
+// POST /api/admin/search/reindex (admin only). Returns 202 with the job run; poll GET /api/admin/jobs/{id}.
+func (h *AdminHandler) ReindexSearch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	h.startJob(w, r, jobs.TypeReindexSearch, nil, jobs.ReindexSearch(h.DB, h.Storage, h.Search))
+}
+
+// SearchStatus returns the size of the search index and when it was last rebuilt. GET /api/admin/search (admin only).
+func (h *AdminHandler) SearchStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.Search.Stats())
+}
+
+func (h *AdminHandler) startJob(w http.ResponseWriter, r *http.Request, jobType string, params map[string]string, fn jobs.Func) {
+	run, err := h.Jobs.StartWith(jobType, middleware.EmailFromContext(r.Context()), params, fn)
+	if errors.Is(err, jobs.ErrAlreadyRunning) {
+		http.Error(w, `{"error":"job already running"}`, http.StatusConflict)
+		return
+	}
+	if errors.Is(err, jobs.ErrShuttingDown) {
+		http.Error(w, `{"error":"server is shutting down"}`, http.StatusServiceUnavailable)
+		return
+	}
+	if err != nil {
+		http.Error(w, `{"error":"failed to start job"}`, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(run)
+}
+
+// GetJob returns a job run's status and progress. GET /api/admin/jobs/{id} (admin only).
+func (h *AdminHandler) GetJob(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	id, err := primitive.ObjectIDFromHex(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, `{"error":"invalid job id"}`, http.StatusBadRequest)
+		return
+	}
+	run, err := h.DB.JobRunByID(r.Context(), id)
+	if err != nil {
+		http.Error(w, `{"error":"failed to load job"}`, http.StatusInternalServerError)
+		return
+	}
+	if run == nil {
+		http.Error(w, `{"error":"job not found"}`, http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(run)
+}
+
+// StorageUsage returns total stored bytes by format, by uploader (per-user usage) and growth by month. GET /api/admin/storage (admin only).
+func (h *AdminHandler) StorageUsage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	usage, err := h.DB.StorageUsage(r.Context())
+	if err != nil {
+		http.Error(w, `{"error":"failed to compute storage usage"}`, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(usage)
+}
+
+// StorageTrends returns stored and this month's presigned download bytes against the soft quotas, the alerts
+// sent, and the last 12 months of history. GET /api/admin/storage/usage (admin only).
+func (h *AdminHandler) StorageTrends(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	trends, err := jobs.StorageTrends(r.Context(), h.DB, h.Quotas, h.Clock.Now())
+	if err != nil {
+		http.Error(w, `{"error":"failed to compute storage usage"}`, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(trends)
+}
+
+// sourceUnknown filters Books for books added before sources were recorded.
+const sourceUnknown = "unknown"
+
+// Books lists books with where their files came from, newest first, for auditing. ?source= takes a models.Source*
+// value or "unknown", ?uploadedBy= an uploader email, ?url= part of the source URL or path; limit 1-1000, default
+// 100. GET /api/admin/books (admin only).
+func (h *AdminHandler) Books(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	q := r.URL.Query()
+	limit := 100
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 1000 {
+			http.Error(w, `{"error":"limit must be between 1 and 1000"}`, http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+	source, uploadedBy, url := q.Get("source"), strings.TrimSpace(q.Get("uploadedBy")), strings.ToLower(strings.TrimSpace(q.Get("url")))
+	if source == sourceUnknown {
+		source = ""
+	} else if source == "" {
+		source = "*"
+	}
+	books, err := h.DB.AllBooks(r.Context())
+	if err != nil {
+		http.Error(w, `{"error":"failed to list books"}`, http.StatusInternalServerError)
+		return
+	}
+	books = slices.DeleteFunc(books, func(b models.Book) bool {
+		return source != "*" && b.Source != source ||
+			uploadedBy != "" && !strings.EqualFold(b.UploadedByEmail, uploadedBy) ||
+			url != "" && !strings.Contains(strings.ToLower(b.SourceURL), url) && !strings.Contains(strings.ToLower(b.SourcePath), url)
+	})
+	slices.SortStableFunc(books, func(a, b models.Book) int { return b.CreatedAt.Compare(a.CreatedAt) })
+	if len(books) > limit {
+		books = books[:limit]
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(books)
+}
+
+// Backups returns the backup schedule, the last backup run and the stored backups. GET /api/admin/backups (admin only).
+func (h *AdminHandler) Backups(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	backups, err := h.DB.ListBackups(r.Context())
+	if err != nil {
+		http.Error(w, `{"error":"failed to list backups"}`, http.StatusInternalServerError)
+		return
+	}
+	lastRun, err := h.DB.LatestJobRun(r.Context(), jobs.TypeBackup)
+	if err != nil {
+		http.Error(w, `{"error":"failed to list backups"}`, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.BackupStatus{
+		Schedule:   h.Backup.Schedule,
+		NextRun:    jobs.NextRun(h.Backup.Schedule),
+		KeepDaily:  h.Backup.KeepDaily,
+		KeepWeekly: h.Backup.KeepWeekly,
+		LastRun:    lastRun,
+		Backups:    backups,
+	})
+}
+
+// SystemEmails lists the most recent invite, password reset and notification emails with their delivery status.
+// GET /api/admin/system-emails?limit=N (admin only; default 100, max 500).
+func (h *AdminHandler) SystemEmails(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	limit := int64(100)
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n < 1 || n > 500 {
+			http.Error(w, `{"error":"limit must be between 1 and 500"}`, http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+	emails, err := h.DB.RecentSystemEmails(r.Context(), limit)
+	if err != nil {
+		http.Error(w, `{"error":"failed to list system emails"}`, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(emails)
+}
+
+// DownloadLinks lists the most recently issued download links, newest first: who got a link to which book, of
+// which kind and until when. GET /api/admin/download-links?bookId=&limit= (admin only; limit 1-500, default 100).
+func (h *AdminHandler) DownloadLinks(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	limit := int64(100)
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n < 1 || n > 500 {
+			http.Error(w, `{"error":"limit must be between 1 and 500"}`, http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+	var bookID primitive.ObjectID
+	if v := r.URL.Query().Get("bookId"); v != "" {
+		id, err := primitive.ObjectIDFromHex(v)
+		if err != nil {
+			http.Error(w, `{"error":"invalid book id"}`, http.StatusBadRequest)
+			return
+		}
+		bookID = id
+	}
+	links, err := h.DB.RecentDownloadLinks(r.Context(), bookID, limit)
+	if err != nil {
+		http.Error(w, `{"error":"failed to list download links"}`, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(links)
+}
+
+// SendUsage lists users who sent books to Kindle in the last day, with their usage against their role's
+// hourly and daily limits, most used first. GET /api/admin/send-usage (admin only).
+// With ?status=near, only users at 80% or more of a limit (including those already limited) are returned.
+func (h *AdminHandler) SendUsage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	usage, err := sendUsage(r.Context(), h.DB, h.SendLimits, h.Clock.Now())
+	if err != nil {
+		http.Error(w, `{"error":"failed to load send usage"}`, http.StatusInternalServerError)
+		return
+	}
+	if r.URL.Query().Get("status") == models.SendUsageNear {
+		near := usage[:0]
+		for _, u := range usage {
+			if u.Status != models.SendUsageOK {
+				near = append(near, u)
+			}
+		}
+		usage = near
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.SendUsageReport{Limits: h.SendLimits, Users: usage})
+}
+
+type RateLimitsResponse struct {
+	Stats []models.RateLimitStat `json:"stats"`
+}
+
+// RateLimits reports, for each rate-limited endpoint class and role, the limit and how many requests this
+// instance allowed and refused (429) since it started. GET /api/admin/rate-limits (admin only).
+func (h *AdminHandler) RateLimits(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	stats := []models.RateLimitStat{}
+	if h.RateLimiter != nil {
+		stats = append(stats, h.RateLimiter.Stats()...)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(RateLimitsResponse{Stats: stats})
+}
+
+// SMTPPoolResponse is the JSON response for GET /api/admin/smtp-pool.
+type SMTPPoolResponse struct {
+	Enabled bool                  `json:"enabled"` // false when every send opens its own connection (SMTP_POOL_IDLE=0)
+	Stats   []models.SMTPPoolStat `json:"stats"`
+}
+
+// SMTPPoolStats reports, for each SMTP server and account this instance sent through since it started, its open and
+// idle connections, how many sends reused one, and how many sends and logins failed. GET /api/admin/smtp-pool
+// (admin only).
+func (h *AdminHandler) SMTPPoolStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	res := SMTPPoolResponse{Stats: []models.SMTPPoolStat{}}
+	if h.SMTPPool != nil {
+		res.Enabled, res.Stats = true, h.SMTPPool.Stats()
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(res)
+}
+
+// RunBackup starts a backup now. POST /api/admin/backups (admin only). Returns 202 with the job run.
+func (h *AdminHandler) RunBackup(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if h.Storage == nil {
+		http.Error(w, `{"error":"storage not configured"}`, http.StatusServiceUnavailable)
+		return
+	}
+	h.startJob(w, r, jobs.TypeBackup, nil, h.BackupJob("manual"))
+}
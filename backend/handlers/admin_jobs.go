@@ -0,0 +1,324 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/kevinaaaquil/books/backend/jobs"
+	"github.com/kevinaaaquil/books/backend/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// adminJob is a job admins can start from /api/admin/jobs.
+type adminJob struct {
+	models.JobType
+	// build returns the job for params (every declared param is set, to its default if not given), or the reason
+	// it can't run.
+	build func(params map[string]string) (jobs.Func, string)
+}
+
+// adminJobs lists the jobs admins can start, in the order GET /api/admin/jobs shows them.
+func (h *AdminHandler) adminJobs() []adminJob {
+	needStorage := func(fn func(map[string]string) jobs.Func) func(map[string]string) (jobs.Func, string) {
+		return func(params map[string]string) (jobs.Func, string) {
+			if h.Storage == nil {
+				return nil, "storage is not configured"
+			}
+			return fn(params), ""
+		}
+	}
+	return []adminJob{
+		{
+			JobType: models.JobType{Type: jobs.TypeVerifyStorage, Description: "Check that every book's file and cover are in storage and intact"},
+			build:   needStorage(func(map[string]string) jobs.Func { return jobs.VerifyStorage(h.DB, h.Storage) }),
+		},
+		{
+			JobType: models.JobType{
+				Type:        jobs.TypeBackfillFileInfo,
+				Description: "Compute size, SHA-256 and word and page counts for books that lack them",
+				Params:      []models.JobParam{{Name: "all", Type: "bool", Description: "Recompute every book and re-validate EPUBs", Default: "false"}},
+			},
+			build: needStorage(func(params map[string]string) jobs.Func {
+				return jobs.BackfillFileInfo(h.DB, h.Storage, params["all"] == "true")
+			}),
+		},
+		{
+			JobType: models.JobType{
+				Type:        jobs.TypeClassifyBooks,
+				Description: "Suggest categories for books that have none, for editors to approve",
+				Params:      []models.JobParam{{Name: "all", Type: "bool", Description: "Suggest again for books that have suggestions waiting", Default: "false"}},
+			},
+			build: func(params map[string]string) (jobs.Func, string) {
+				if h.Classifier == nil {
+					return nil, "CLASSIFIER is not set"
+				}
+				return jobs.ClassifyBooks(h.DB, h.Storage, h.Classifier, params["all"] == "true"), ""
+			},
+		},
+		{
+			JobType: models.JobType{
+				Type:        jobs.TypeEmbedBooks,
+				Description: "Embed new and changed books for semantic search",
+				Params:      []models.JobParam{{Name: "all", Type: "bool", Description: "Embed every book again", Default: "false"}},
+			},
+			build: func(params map[string]string) (jobs.Func, string) {
+				if h.Embedder == nil {
+					return nil, "EMBEDDINGS is not set"
+				}
+				return jobs.EmbedBooks(h.DB, h.Storage, h.Embedder, h.Vectors, params["all"] == "true"), ""
+			},
+		},
+		{
+			JobType: models.JobType{Type: jobs.TypeReindexSearch, Description: "Rebuild the search index from every book's metadata and EPUB text"},
+			build: func(map[string]string) (jobs.Func, string) {
+				return jobs.ReindexSearch(h.DB, h.Storage, h.Search), ""
+			},
+		},
+		{
+			JobType: models.JobType{Type: jobs.TypeBackup, Description: "Back up the database to storage", Schedule: h.Backup.Schedule},
+			build:   needStorage(func(map[string]string) jobs.Func { return h.BackupJob("manual") }),
+		},
+		{
+			JobType: models.JobType{Type: jobs.TypeRecommendations, Description: "Recompute every user's reading recommendations", Schedule: h.Schedules[jobs.TypeRecommendations]},
+			build: func(map[string]string) (jobs.Func, string) {
+				return jobs.Recommendations(h.DB), ""
+			},
+		},
+		{
+			JobType: models.JobType{Type: jobs.TypeNewReleases, Description: "Look for new books by the authors users have read", Schedule: h.Schedules[jobs.TypeNewReleases]},
+			build: func(map[string]string) (jobs.Func, string) {
+				if job, ok := h.NewReleasesJob(); ok {
+					return job, ""
+				}
+				return nil, "metadata provider cannot search by author"
+			},
+		},
+		{
+			JobType: models.JobType{Type: jobs.TypePriceWatch, Description: "Check the prices of watched wishlist items", Schedule: h.Schedules[jobs.TypePriceWatch]},
+			build: func(map[string]string) (jobs.Func, string) {
+				if job, ok := h.PriceWatchJob(); ok {
+					return job, ""
+				}
+				return nil, "price watch not configured"
+			},
+		},
+		{
+			JobType: models.JobType{Type: jobs.TypeDigest, Description: "Email the weekly digest to users who turned it on", Schedule: h.Schedules[jobs.TypeDigest]},
+			build: func(map[string]string) (jobs.Func, string) {
+				if job, ok := h.DigestJob(); ok {
+					return job, ""
+				}
+				return nil, "system mail is not configured"
+			},
+		},
+		{
+			JobType: models.JobType{Type: jobs.TypeStorageAlerts, Description: "Alert admins when storage or monthly downloads near their soft quota", Schedule: h.Schedules[jobs.TypeStorageAlerts]},
+			build: func(map[string]string) (jobs.Func, string) {
+				if h.Quotas.StorageBytes <= 0 && h.Quotas.MonthlyDownloadBytes <= 0 {
+					return nil, "no storage quota set"
+				}
+				return jobs.StorageAlerts(h.DB, h.Notify, h.Quotas), ""
+			},
+		},
+		{
+			JobType: models.JobType{Type: jobs.TypeColdStorage, Description: "Move the files of books nobody has read for months to a cheaper storage class", Schedule: h.Schedules[jobs.TypeColdStorage]},
+			build: func(map[string]string) (jobs.Func, string) {
+				return h.ColdStorageJob()
+			},
+		},
+		{
+			JobType: models.JobType{Type: jobs.TypeTelemetry, Description: "Report aggregate usage counts to TELEMETRY_URL", Schedule: h.Schedules[jobs.TypeTelemetry]},
+			build: func(map[string]string) (jobs.Func, string) {
+				if job, ok := h.TelemetryJob(); ok {
+					return job, ""
+				}
+				return nil, "TELEMETRY is not set"
+			},
+		},
+		{
+			JobType: models.JobType{Type: jobs.TypeMigrateStorage, Description: "Copy every book, cover and backup to the storage set by MIGRATE_TO_*, checking each copy"},
+			build: func(map[string]string) (jobs.Func, string) {
+				switch {
+				case h.Storage == nil:
+					return nil, "storage is not configured"
+				case h.MigrationTarget == nil:
+					return nil, "MIGRATE_TO_S3_BUCKET or MIGRATE_TO_DATA_DIR is not set"
+				}
+				return jobs.MigrateStorage(h.DB, h.Storage, h.MigrationTarget), ""
+			},
+		},
+	}
+}
+
+// ListJobs returns the jobs admins can start, with their parameters, whether they can run here, their schedule
+// and their latest run. GET /api/admin/jobs (admin only).
+func (h *AdminHandler) ListJobs(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	types := []models.JobType{}
+	for _, job := range h.adminJobs() {
+		t := job.JobType
+		if t.Params == nil {
+			t.Params = []models.JobParam{}
+		}
+		_, reason := job.build(defaultJobParams(t.Params))
+		t.Available, t.Unavailable = reason == "", reason
+		last, err := h.DB.LatestJobRun(r.Context(), t.Type)
+		if err != nil {
+			http.Error(w, `{"error":"failed to load jobs"}`, http.StatusInternalServerError)
+			return
+		}
+		t.LastRun = last
+		types = append(types, t)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(types)
+}
+
+// StartJobRequest is the optional body of StartJob.
+type StartJobRequest struct {
+	Params map[string]any `json:"params"` // e.g. {"all": true}; values may also be strings
+}
+
+// StartJob starts a job listed by ListJobs, with params from the body or the query string; missing ones take
+// their defaults. POST /api/admin/jobs/{type} (admin only). Returns 202 with the run; 404 for an unknown job,
+// 400 for unknown or invalid params, 503 when the job can't run here, 409 while it is already running.
+func (h *AdminHandler) StartJob(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	jobType := chi.URLParam(r, "type")
+	var job *adminJob
+	for _, j := range h.adminJobs() {
+		if j.Type == jobType {
+			job = &j
+			break
+		}
+	}
+	if job == nil {
+		http.Error(w, `{"error":"unknown job"}`, http.StatusNotFound)
+		return
+	}
+	var req StartJobRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, `{"error":"invalid request body"}`, http.StatusBadRequest)
+		return
+	}
+	given := map[string]string{}
+	for k, v := range r.URL.Query() {
+		given[k] = v[0]
+	}
+	for k, v := range req.Params {
+		given[k] = fmt.Sprint(v)
+	}
+	params := defaultJobParams(job.Params)
+	for k, v := range given {
+		if _, ok := params[k]; !ok {
+			http.Error(w, `{"error":"unknown param; see GET /api/admin/jobs"}`, http.StatusBadRequest)
+			return
+		}
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			http.Error(w, `{"error":"param `+k+` must be true or false"}`, http.StatusBadRequest)
+			return
+		}
+		params[k] = strconv.FormatBool(b)
+	}
+	fn, reason := job.build(params)
+	if fn == nil {
+		http.Error(w, `{"error":"`+reason+`"}`, http.StatusServiceUnavailable)
+		return
+	}
+	if len(params) == 0 {
+		params = nil
+	}
+	h.startJob(w, r, jobType, params, fn)
+}
+
+func defaultJobParams(params []models.JobParam) map[string]string {
+	out := make(map[string]string, len(params))
+	for _, p := range params {
+		out[p.Name] = p.Default
+	}
+	return out
+}
+
+// ListJobRuns returns job runs, newest first, with their progress and logs. ?type= and ?status= filter them;
+// ?limit= (default 50, at most 200) and ?before= (an RFC 3339 start time, for the next page) page through them.
+// GET /api/admin/jobs/runs (admin only).
+func (h *AdminHandler) ListJobRuns(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	q := r.URL.Query()
+	f := models.JobRunFilter{Type: q.Get("type"), Status: q.Get("status"), Limit: 50}
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			http.Error(w, `{"error":"limit must be a positive number"}`, http.StatusBadRequest)
+			return
+		}
+		f.Limit = min(n, 200)
+	}
+	if v := q.Get("before"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			http.Error(w, `{"error":"before must be an RFC 3339 time"}`, http.StatusBadRequest)
+			return
+		}
+		f.Before = &t
+	}
+	runs, err := h.DB.ListJobRuns(r.Context(), f)
+	if err != nil {
+		http.Error(w, `{"error":"failed to load job runs"}`, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(runs)
+}
+
+// CancelJob stops a running job; it ends as cancelled and is not resumed. POST /api/admin/jobs/{id}/cancel (admin
+// only). Returns 202 with the run; 409 when it is not running.
+func (h *AdminHandler) CancelJob(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	id, err := primitive.ObjectIDFromHex(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, `{"error":"invalid job id"}`, http.StatusBadRequest)
+		return
+	}
+	run, err := h.DB.JobRunByID(r.Context(), id)
+	if err != nil {
+		http.Error(w, `{"error":"failed to load job"}`, http.StatusInternalServerError)
+		return
+	}
+	if run == nil {
+		http.Error(w, `{"error":"job not found"}`, http.StatusNotFound)
+		return
+	}
+	err = h.Jobs.Cancel(r.Context(), id)
+	if errors.Is(err, jobs.ErrNotRunning) {
+		http.Error(w, `{"error":"job is not running"}`, http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, `{"error":"failed to cancel job"}`, http.StatusInternalServerError)
+		return
+	}
+	run.CancelRequested = true
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(run)
+}
@@ -0,0 +1,326 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/kevinaaaquil/books/backend/middleware"
+	"github.com/kevinaaaquil/books/backend/models"
+	"github.com/kevinaaaquil/books/backend/service"
+	"github.com/kevinaaaquil/books/backend/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// maxAPIKeysPerUser caps how many keys one user can hold.
+const maxAPIKeysPerUser = 20
+
+// apiKeyPrefix starts every key, so leaked keys are easy to recognise.
+const apiKeyPrefix = "bk_"
+
+// APIKeysHandler manages users' API keys (see models.APIKey) and resolves the keys requests carry.
+type APIKeysHandler struct {
+	DB    store.Store
+	Clock service.Clock
+}
+
+// maxAPIKeyDays caps ExpiresInDays.
+const maxAPIKeyDays = 3650
+
+type CreateAPIKeyRequest struct {
+	Name          string   `json:"name"`
+	Scopes        []string `json:"scopes"`        // models.APIKeyScopes; empty = clip
+	ExpiresInDays int      `json:"expiresInDays"` // 1-3650; 0 = never
+}
+
+// AdminAPIKey is a key as admins see it: whose it is and whether it still works, never the secret.
+type AdminAPIKey struct {
+	models.APIKey
+	OwnerEmail string `json:"ownerEmail,omitempty"` // "" when the owner no longer exists
+	Expired    bool   `json:"expired"`
+}
+
+// CreateAPIKeyResponse is the new key's details and the key itself, which is not shown again.
+type CreateAPIKeyResponse struct {
+	models.APIKey
+	Key string `json:"key"`
+}
+
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// newAPIKey returns a new random key and the prefix stored to tell it apart.
+func newAPIKey() (key, prefix string, err error) {
+	random := make([]byte, 24)
+	if _, err := rand.Read(random); err != nil {
+		return "", "", err
+	}
+	key = apiKeyPrefix + hex.EncodeToString(random)
+	return key, key[:len(apiKeyPrefix)+8], nil
+}
+
+// Resolve returns the owner of key with their current role, recording that the key was used. Expired keys, and keys
+// of users who no longer exist or are now guests, resolve to nil. It is a middleware.APIKeyResolver.
+func (h *APIKeysHandler) Resolve(ctx context.Context, key string) (*middleware.APIKeyOwner, error) {
+	if !strings.HasPrefix(key, apiKeyPrefix) {
+		return nil, nil
+	}
+	k, err := h.DB.APIKeyByHash(ctx, hashAPIKey(key))
+	if err != nil || k == nil || k.Expired(h.Clock.Now()) {
+		return nil, err
+	}
+	user, err := h.DB.UserByID(ctx, k.UserID)
+	if err != nil || user == nil || user.Role == models.RoleGuest {
+		return nil, err
+	}
+	if err := h.DB.TouchAPIKey(ctx, k.ID, h.Clock.Now()); err != nil {
+		log.Printf("api key %s: %v", k.ID.Hex(), err)
+	}
+	return &middleware.APIKeyOwner{UserID: user.ID, Email: user.Email, Role: user.Role, Scopes: k.Scopes}, nil
+}
+
+// List returns the current user's keys, without the keys themselves. GET /api/me/api-keys
+func (h *APIKeysHandler) List(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+	keys, err := h.DB.APIKeysForUser(r.Context(), userID)
+	if err != nil {
+		http.Error(w, `{"error":"failed to list api keys"}`, http.StatusInternalServerError)
+		return
+	}
+	if keys == nil {
+		keys = []models.APIKey{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(keys)
+}
+
+// Create makes a key for the current user and returns it (201); only its hash is kept. Keys can clip books
+// (POST /api/clip), read OPDS feeds (GET /api/opds/{key}) and call the rest of the API as X-API-Key (read: GET
+// and HEAD; write: anything), as their scopes allow, until they expire.
+// POST /api/me/api-keys (signed in with a token, not a key)
+func (h *APIKeysHandler) Create(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+	var req CreateAPIKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error":"invalid json"}`, http.StatusBadRequest)
+		return
+	}
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		http.Error(w, `{"error":"name is required"}`, http.StatusBadRequest)
+		return
+	}
+	scopes := []string{models.ScopeClip}
+	if len(req.Scopes) > 0 {
+		scopes = nil
+		for _, s := range req.Scopes {
+			if !slices.Contains(models.APIKeyScopes, s) {
+				http.Error(w, `{"error":"scopes must be among: `+strings.Join(models.APIKeyScopes, ", ")+`"}`, http.StatusBadRequest)
+				return
+			}
+			if !slices.Contains(scopes, s) {
+				scopes = append(scopes, s)
+			}
+		}
+	}
+	if req.ExpiresInDays < 0 || req.ExpiresInDays > maxAPIKeyDays {
+		http.Error(w, `{"error":"expiresInDays must be between 1 and `+strconv.Itoa(maxAPIKeyDays)+`, or 0 for never"}`, http.StatusBadRequest)
+		return
+	}
+	existing, err := h.DB.APIKeysForUser(r.Context(), userID)
+	if err != nil {
+		http.Error(w, `{"error":"failed to create api key"}`, http.StatusInternalServerError)
+		return
+	}
+	if len(existing) >= maxAPIKeysPerUser {
+		http.Error(w, `{"error":"too many api keys; revoke one first"}`, http.StatusConflict)
+		return
+	}
+	key, prefix, err := newAPIKey()
+	if err != nil {
+		http.Error(w, `{"error":"failed to create api key"}`, http.StatusInternalServerError)
+		return
+	}
+	k := models.APIKey{
+		UserID:    userID,
+		Name:      name,
+		Prefix:    prefix,
+		KeyHash:   hashAPIKey(key),
+		Scopes:    scopes,
+		CreatedAt: h.Clock.Now(),
+	}
+	if req.ExpiresInDays > 0 {
+		expires := k.CreatedAt.AddDate(0, 0, req.ExpiresInDays)
+		k.ExpiresAt = &expires
+	}
+	if k.ID, err = h.DB.InsertAPIKey(r.Context(), &k); err != nil {
+		http.Error(w, `{"error":"failed to create api key"}`, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(CreateAPIKeyResponse{APIKey: k, Key: key})
+}
+
+// Delete revokes one of the current user's keys. DELETE /api/me/api-keys/:id
+func (h *APIKeysHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+	id, err := primitive.ObjectIDFromHex(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, `{"error":"invalid api key id"}`, http.StatusBadRequest)
+		return
+	}
+	found, err := h.DB.DeleteAPIKey(r.Context(), userID, id)
+	if err != nil {
+		http.Error(w, `{"error":"failed to revoke api key"}`, http.StatusInternalServerError)
+		return
+	}
+	if !found {
+		http.Error(w, `{"error":"api key not found"}`, http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Regenerate replaces the secret of one of the current user's keys and returns the new one (shown once); the old
+// one stops working at once. Name, scopes and expiry are kept. POST /api/me/api-keys/:id/regenerate
+func (h *APIKeysHandler) Regenerate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+	id, err := primitive.ObjectIDFromHex(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, `{"error":"invalid api key id"}`, http.StatusBadRequest)
+		return
+	}
+	key, prefix, err := newAPIKey()
+	if err != nil {
+		http.Error(w, `{"error":"failed to regenerate api key"}`, http.StatusInternalServerError)
+		return
+	}
+	found, err := h.DB.RotateAPIKey(r.Context(), userID, id, prefix, hashAPIKey(key), h.Clock.Now())
+	if err != nil {
+		http.Error(w, `{"error":"failed to regenerate api key"}`, http.StatusInternalServerError)
+		return
+	}
+	if !found {
+		http.Error(w, `{"error":"api key not found"}`, http.StatusNotFound)
+		return
+	}
+	k, err := h.DB.APIKeyByHash(r.Context(), hashAPIKey(key))
+	if err != nil || k == nil {
+		http.Error(w, `{"error":"failed to regenerate api key"}`, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(CreateAPIKeyResponse{APIKey: *k, Key: key})
+}
+
+// AdminList returns every user's keys with their owners, oldest first; expired keys only with ?expired=true.
+// ?scope= keeps the keys with that scope. GET /api/admin/api-keys (admin only)
+func (h *APIKeysHandler) AdminList(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	keys, err := h.DB.AllAPIKeys(r.Context())
+	if err != nil {
+		http.Error(w, `{"error":"failed to list api keys"}`, http.StatusInternalServerError)
+		return
+	}
+	users, err := h.DB.ListUsers(r.Context())
+	if err != nil {
+		http.Error(w, `{"error":"failed to list api keys"}`, http.StatusInternalServerError)
+		return
+	}
+	emails := make(map[primitive.ObjectID]string, len(users))
+	for _, u := range users {
+		emails[u.ID] = u.Email
+	}
+	scope, withExpired := r.URL.Query().Get("scope"), r.URL.Query().Get("expired") == "true"
+	now := h.Clock.Now()
+	out := []AdminAPIKey{}
+	for _, k := range keys {
+		expired := k.Expired(now)
+		if expired && !withExpired || scope != "" && !slices.Contains(k.Scopes, scope) {
+			continue
+		}
+		out = append(out, AdminAPIKey{APIKey: k, OwnerEmail: emails[k.UserID], Expired: expired})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(out)
+}
+
+// AdminRevoke revokes any user's key. DELETE /api/admin/api-keys/:id (admin only)
+func (h *APIKeysHandler) AdminRevoke(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	id, err := primitive.ObjectIDFromHex(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, `{"error":"invalid api key id"}`, http.StatusBadRequest)
+		return
+	}
+	keys, err := h.DB.AllAPIKeys(r.Context())
+	if err != nil {
+		http.Error(w, `{"error":"failed to revoke api key"}`, http.StatusInternalServerError)
+		return
+	}
+	i := slices.IndexFunc(keys, func(k models.APIKey) bool { return k.ID == id })
+	if i < 0 {
+		http.Error(w, `{"error":"api key not found"}`, http.StatusNotFound)
+		return
+	}
+	found, err := h.DB.DeleteAPIKey(r.Context(), keys[i].UserID, id)
+	if err != nil {
+		http.Error(w, `{"error":"failed to revoke api key"}`, http.StatusInternalServerError)
+		return
+	}
+	if !found {
+		http.Error(w, `{"error":"api key not found"}`, http.StatusNotFound)
+		return
+	}
+	log.Printf("api key %s of user %s revoked by %s", id.Hex(), keys[i].UserID.Hex(), middleware.EmailFromContext(r.Context()))
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/kevinaaaquil/books/backend/middleware"
+	"github.com/kevinaaaquil/books/backend/models"
+	"github.com/kevinaaaquil/books/backend/service"
+	"github.com/kevinaaaquil/books/backend/store"
+)
+
+// ArXivHandler adds papers from arXiv to the library.
+type ArXivHandler struct {
+	DB       store.Store
+	ArXiv    service.ArXiv
+	Ingest   func(context.Context, IngestFile) (*IngestResult, error) // UploadHandler.Ingest
+	MaxBytes int64                                                    // larger PDFs are refused; 0 = no limit
+}
+
+type ArXivImportResponse struct {
+	UploadResponse
+	ArXivID  string `json:"arxivId"`            // versioned
+	Existing bool   `json:"existing,omitempty"` // the same PDF was already in the library; nothing was added
+}
+
+// Import downloads a paper's PDF and adds it to the library as a document, with arXiv's title, authors, abstract
+// and categories. ?id= takes an identifier (2101.00001, 2101.00001v2, hep-th/9901001), an arxiv: reference or an
+// abs/pdf link; without a version the latest is imported. 201 when added, 200 with existing when the library has
+// the same file. POST /api/import/arxiv (admin, editor)
+func (h *ArXivHandler) Import(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if _, ok := middleware.UserIDFromContext(r.Context()); !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+	id := service.ParseArXivID(r.URL.Query().Get("id"))
+	if id == "" {
+		http.Error(w, `{"error":"id must be an arXiv identifier, e.g. 2101.00001"}`, http.StatusBadRequest)
+		return
+	}
+	paper, err := h.ArXiv.Paper(r.Context(), id)
+	switch {
+	case errors.Is(err, service.ErrNoMetadata):
+		http.Error(w, `{"error":"no such paper on arXiv"}`, http.StatusNotFound)
+		return
+	case err != nil:
+		log.Printf("arxiv %s: %v", id, err)
+		http.Error(w, `{"error":"could not reach arXiv"}`, http.StatusBadGateway)
+		return
+	}
+	data, err := h.ArXiv.DownloadPDF(r.Context(), paper, h.MaxBytes)
+	switch {
+	case errors.Is(err, service.ErrFileTooLarge):
+		http.Error(w, `{"error":"file is too large"}`, http.StatusRequestEntityTooLarge)
+		return
+	case err != nil:
+		log.Printf("arxiv %s: %v", paper.ID, err)
+		http.Error(w, `{"error":"could not download the paper"}`, http.StatusBadGateway)
+		return
+	}
+	sum := sha256.Sum256(data)
+	existing, err := h.DB.BookBySHA256(r.Context(), hex.EncodeToString(sum[:]))
+	if err != nil {
+		http.Error(w, `{"error":"failed to check for duplicates"}`, http.StatusInternalServerError)
+		return
+	}
+	if existing != nil {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(ArXivImportResponse{UploadResponse: UploadResponse{ID: existing.ID.Hex(), Title: existing.Title}, ArXivID: paper.ID, Existing: true})
+		return
+	}
+	res, err := h.Ingest(r.Context(), IngestFile{
+		Name:       strings.ReplaceAll(paper.ID, "/", "_") + ".pdf",
+		Data:       data,
+		UploadedBy: middleware.EmailFromContext(r.Context()),
+		Metadata:   &paper.Metadata,
+		Source:     models.SourceArXiv,
+		SourceURL:  paper.PDFURL,
+	})
+	switch {
+	case errors.Is(err, errStoreFile):
+		http.Error(w, `{"error":"failed to upload to storage"}`, http.StatusInternalServerError)
+		return
+	case err != nil:
+		http.Error(w, `{"error":"failed to save book record"}`, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(ArXivImportResponse{
+		UploadResponse: uploadResponse(res),
+		ArXivID:        paper.ID,
+	})
+}
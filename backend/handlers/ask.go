@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/kevinaaaquil/books/backend/middleware"
+	"github.com/kevinaaaquil/books/backend/models"
+	"github.com/kevinaaaquil/books/backend/search"
+	"github.com/kevinaaaquil/books/backend/service"
+)
+
+const (
+	askMaxQuestion  = 1000 // characters
+	askSources      = 8    // books given to the assistant
+	askPassages     = 3    // passages of each book's text
+	askDescriptions = 1500 // characters of each book's description
+)
+
+type AskRequest struct {
+	Question string `json:"question"`
+}
+
+// AskResponse is the assistant's answer with the books it cites: BookIDs in the order cited, and those books.
+type AskResponse struct {
+	Question string        `json:"question"`
+	Answer   string        `json:"answer"`
+	BookIDs  []string      `json:"bookIds"`
+	Books    []models.Book `json:"books"`
+}
+
+// Ask answers a question about the library ("which of my books cover distributed consensus?") with the assistant,
+// grounded in the books the search index finds for its words: their metadata and the passages of their text that
+// match best. Only books the user can see are used, and only those can be cited.
+// POST /api/ask (auth). 404 when ASSISTANT is not set.
+func (h *BooksHandler) Ask(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if _, ok := middleware.UserIDFromContext(r.Context()); !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+	if h.Assistant == nil {
+		http.Error(w, `{"error":"the assistant is not enabled"}`, http.StatusNotFound)
+		return
+	}
+	var req AskRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error":"invalid JSON"}`, http.StatusBadRequest)
+		return
+	}
+	question := strings.TrimSpace(req.Question)
+	if question == "" {
+		http.Error(w, `{"error":"question is required"}`, http.StatusBadRequest)
+		return
+	}
+	if len(question) > askMaxQuestion {
+		http.Error(w, fmt.Sprintf(`{"error":"question is longer than %d characters"}`, askMaxQuestion), http.StatusBadRequest)
+		return
+	}
+	books, err := h.visibleBooks(r)
+	if err != nil {
+		http.Error(w, `{"error":"failed to list books"}`, http.StatusInternalServerError)
+		return
+	}
+	var found []models.Book
+	if h.Search != nil {
+		found = rankBooks(books, h.Search.Match(question))
+	}
+	if len(found) > askSources {
+		found = found[:askSources]
+	}
+	resp := AskResponse{Question: question, BookIDs: []string{}, Books: []models.Book{}}
+	if len(found) == 0 {
+		// Nothing to ground an answer in: the model would only make one up.
+		resp.Answer = "None of the books in the library seem to be about that."
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+		return
+	}
+	sources := make([]service.AssistantSource, len(found))
+	byID := make(map[string]models.Book, len(found))
+	for i := range found {
+		b := &found[i]
+		byID[b.ID.Hex()] = *b
+		categories := b.Categories
+		if len(categories) == 0 && b.Category != "" {
+			categories = []string{b.Category}
+		}
+		description := b.Preface
+		if len(description) > askDescriptions {
+			description = strings.ToValidUTF8(description[:askDescriptions], "")
+		}
+		sources[i] = service.AssistantSource{
+			ID:          b.ID.Hex(),
+			Title:       b.Title,
+			Authors:     b.Authors,
+			Categories:  categories,
+			Description: description,
+		}
+		if !service.NeedsRestore(b.StorageClass) {
+			sources[i].Passages = search.Passages(h.bookText(r.Context(), b), question, askPassages)
+		}
+	}
+	answer, err := h.Assistant.Answer(r.Context(), question, sources)
+	if err != nil {
+		log.Printf("ask: %v", err)
+		http.Error(w, `{"error":"the assistant failed to answer"}`, http.StatusBadGateway)
+		return
+	}
+	resp.Answer = answer.Text
+	for _, id := range answer.Cited {
+		b := byID[id]
+		setCoverURLIfExtracted(&b)
+		setContentRating(&b)
+		resp.BookIDs = append(resp.BookIDs, id)
+		resp.Books = append(resp.Books, b)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
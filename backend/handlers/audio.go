@@ -0,0 +1,463 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/kevinaaaquil/books/backend/jobs"
+	"github.com/kevinaaaquil/books/backend/middleware"
+	"github.com/kevinaaaquil/books/backend/models"
+	"github.com/kevinaaaquil/books/backend/service"
+	"github.com/kevinaaaquil/books/backend/store"
+	"github.com/kevinaaaquil/books/backend/utils"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// JobReadAloud is the kind of queued job that reads a chapter aloud; see RunReadAloud.
+const JobReadAloud = "read-aloud"
+
+// audioURLExpiry is how long links streaming read-aloud audio stay valid: long enough for players to seek (with
+// Range requests) through a chapter they started.
+const audioURLExpiry = 6 * time.Hour
+
+// AudioChapter is a chapter of an EPUB that can be read aloud.
+type AudioChapter struct {
+	Chapter    int    `json:"chapter"`
+	Title      string `json:"title"`
+	Characters int    `json:"characters"`
+}
+
+// AudioResponse is a book's read-aloud tracks, by chapter, with links streaming the ready ones.
+type AudioResponse struct {
+	Tracks  []models.AudioTrack `json:"tracks"`
+	Ready   int                 `json:"ready"`   // tracks ready to play
+	Percent int                 `json:"percent"` // of the requested tracks' text read so far
+}
+
+// ReadAloudRequest asks for a chapter, or with no chapter the whole book, to be read aloud.
+type ReadAloudRequest struct {
+	Chapter *int `json:"chapter,omitempty"`
+	Replace bool `json:"replace,omitempty"` // read ready chapters again, e.g. after the voice changed
+}
+
+// AudioChapters lists the chapters of an EPUB that can be read aloud. GET /api/books/:id/audio/chapters
+func (h *BooksHandler) AudioChapters(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	book, ok := h.readAloudBook(w, r)
+	if !ok {
+		return
+	}
+	chapters, ok := h.audioChapters(w, r, book)
+	if !ok {
+		return
+	}
+	list := make([]AudioChapter, len(chapters))
+	for i, c := range chapters {
+		list[i] = AudioChapter{Chapter: i, Title: c.Title, Characters: len(c.Text)}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(list)
+}
+
+// Audio reports the book's read-aloud tracks: each chapter's status ("queued", "generating", "ready" or
+// "failed") and progress, with a signed link streaming it once it is ready. GET /api/books/:id/audio
+func (h *BooksHandler) Audio(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	book, ok := h.visibleBook(w, r)
+	if !ok {
+		return
+	}
+	h.writeAudio(w, r, book, http.StatusOK)
+}
+
+// ReadAloud queues chapters of an EPUB to be read aloud: the one asked for, or every one. Chapters already
+// queued, being read or (unless replace is set) ready are left alone. Answers 202 with the book's tracks; GET
+// /api/books/:id/audio follows their progress. POST /api/books/:id/audio
+func (h *BooksHandler) ReadAloud(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+	var req ReadAloudRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, `{"error":"invalid json"}`, http.StatusBadRequest)
+		return
+	}
+	book, ok := h.readAloudBook(w, r)
+	if !ok {
+		return
+	}
+	chapters, ok := h.audioChapters(w, r, book)
+	if !ok {
+		return
+	}
+	first, last := 0, len(chapters)-1
+	if req.Chapter != nil {
+		if *req.Chapter < 0 || *req.Chapter >= len(chapters) {
+			http.Error(w, `{"error":"no such chapter"}`, http.StatusBadRequest)
+			return
+		}
+		first, last = *req.Chapter, *req.Chapter
+	}
+	existing, err := h.DB.AudioTracks(r.Context(), book.ID)
+	if err != nil {
+		http.Error(w, `{"error":"failed to load audio"}`, http.StatusInternalServerError)
+		return
+	}
+	status := map[int]string{}
+	for _, t := range existing {
+		status[t.Chapter] = t.Status
+	}
+	now := h.Clock.Now()
+	for i := first; i <= last; i++ {
+		switch status[i] {
+		case models.AudioQueued, models.AudioGenerating:
+			continue
+		case models.AudioReady:
+			if !req.Replace {
+				continue
+			}
+		}
+		t := &models.AudioTrack{
+			BookID:      book.ID,
+			Chapter:     i,
+			Title:       chapters[i].Title,
+			Status:      models.AudioQueued,
+			Characters:  len(chapters[i].Text),
+			Voice:       h.Speaker.Voice(),
+			RequestedBy: middleware.EmailFromContext(r.Context()),
+			CreatedAt:   now,
+			UpdatedAt:   now,
+		}
+		if err := h.DB.UpsertAudioTrack(r.Context(), t); err != nil {
+			http.Error(w, `{"error":"failed to save audio"}`, http.StatusInternalServerError)
+			return
+		}
+		params := map[string]string{"bookId": book.ID.Hex(), "chapter": strconv.Itoa(i)}
+		if _, err := h.Queue.Enqueue(r.Context(), JobReadAloud, userID, params); err != nil {
+			log.Printf("read aloud: queue %s chapter %d: %v", book.ID.Hex(), i, err)
+			t.Status, t.Error = models.AudioFailed, "failed to queue"
+			h.saveAudioTrack(r.Context(), t)
+			http.Error(w, `{"error":"failed to queue read-aloud"}`, http.StatusInternalServerError)
+			return
+		}
+	}
+	h.writeAudio(w, r, book, http.StatusAccepted)
+}
+
+// AudioFile streams a ready read-aloud track, for clients that sign in. GET /api/books/:id/audio/:chapter
+func (h *BooksHandler) AudioFile(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	book, ok := h.visibleBook(w, r)
+	if !ok {
+		return
+	}
+	h.streamAudio(w, r, book.ID)
+}
+
+// StreamAudio streams a ready read-aloud track for a signed URL from Audio, so players can load it without an
+// Authorization header. GET /api/books/:id/audio/:chapter/file?expires=...&signature=... (public)
+func (h *BooksHandler) StreamAudio(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	id, err := primitive.ObjectIDFromHex(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, `{"error":"invalid book id"}`, http.StatusBadRequest)
+		return
+	}
+	if h.Signer == nil || !h.Signer.Verify(audioFilePath(id, chi.URLParam(r, "chapter")), r.URL.Query()) {
+		http.Error(w, `{"error":"invalid or expired link"}`, http.StatusForbidden)
+		return
+	}
+	h.streamAudio(w, r, id)
+}
+
+func audioFilePath(id primitive.ObjectID, chapter string) string {
+	return "/api/books/" + id.Hex() + "/audio/" + chapter + "/file"
+}
+
+// audioKey is where a chapter's audio is stored.
+func audioKey(bookID primitive.ObjectID, chapter int) string {
+	return fmt.Sprintf("audio/%s/%d.mp3", bookID.Hex(), chapter)
+}
+
+// streamAudio serves the book's track for the chapter in the URL via http.ServeContent, which handles Range and HEAD.
+func (h *BooksHandler) streamAudio(w http.ResponseWriter, r *http.Request, bookID primitive.ObjectID) {
+	chapter, err := strconv.Atoi(chi.URLParam(r, "chapter"))
+	if err != nil {
+		http.Error(w, `{"error":"invalid chapter"}`, http.StatusBadRequest)
+		return
+	}
+	t, err := h.DB.AudioTrack(r.Context(), bookID, chapter)
+	if err != nil {
+		http.Error(w, `{"error":"failed to load audio"}`, http.StatusInternalServerError)
+		return
+	}
+	if t == nil || t.Status != models.AudioReady || h.Storage == nil {
+		http.Error(w, `{"error":"audio not found"}`, http.StatusNotFound)
+		return
+	}
+	obj, err := h.Storage.OpenObject(r.Context(), t.S3Key)
+	if storageUnavailable(w, err) {
+		return
+	}
+	if errors.Is(err, service.ErrObjectNotFound) {
+		http.Error(w, `{"error":"audio file missing from storage"}`, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, `{"error":"failed to load audio"}`, http.StatusInternalServerError)
+		return
+	}
+	defer obj.Close()
+	info := obj.Info()
+	w.Header().Set("Content-Type", "audio/mpeg")
+	if info.ETag != "" && !strings.HasPrefix(info.ETag, "W/") {
+		w.Header().Set("ETag", info.ETag)
+	}
+	http.ServeContent(w, r, "", info.LastModified, obj)
+}
+
+// writeAudio answers with the book's tracks, signing links to the ready ones.
+func (h *BooksHandler) writeAudio(w http.ResponseWriter, r *http.Request, book *models.Book, status int) {
+	tracks, err := h.DB.AudioTracks(r.Context(), book.ID)
+	if err != nil {
+		http.Error(w, `{"error":"failed to load audio"}`, http.StatusInternalServerError)
+		return
+	}
+	resp := AudioResponse{Tracks: tracks}
+	if resp.Tracks == nil {
+		resp.Tracks = []models.AudioTrack{}
+	}
+	var read, total int
+	for i := range resp.Tracks {
+		t := &resp.Tracks[i]
+		if t.Status == models.AudioReady {
+			resp.Ready++
+			if h.Signer != nil {
+				t.URL = h.Signer.Sign(audioFilePath(book.ID, strconv.Itoa(t.Chapter)), audioURLExpiry)
+			}
+		}
+		read += t.Characters * t.Percent / 100
+		total += t.Characters
+	}
+	if total > 0 {
+		resp.Percent = read * 100 / total
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// readAloudBook loads the book named in the URL for reading aloud, writing the error response when it can't be:
+// read-aloud isn't configured, the user may not see the book, or it isn't an EPUB.
+func (h *BooksHandler) readAloudBook(w http.ResponseWriter, r *http.Request) (*models.Book, bool) {
+	if h.Speaker == nil || h.Storage == nil {
+		http.Error(w, `{"error":"read-aloud is not available"}`, http.StatusNotFound)
+		return nil, false
+	}
+	book, ok := h.visibleBook(w, r)
+	if !ok {
+		return nil, false
+	}
+	if book.Format != "epub" {
+		http.Error(w, `{"error":"only EPUB books can be read aloud"}`, http.StatusBadRequest)
+		return nil, false
+	}
+	return book, true
+}
+
+// audioChapters loads the book's chapters, writing the error response when it can't (202 with a RestoreResponse
+// while the file is archived, as Download does).
+func (h *BooksHandler) audioChapters(w http.ResponseWriter, r *http.Request, book *models.Book) ([]utils.Chapter, bool) {
+	if !h.restoreArchived(w, r, book) {
+		return nil, false
+	}
+	chapters, err := h.bookChapters(r.Context(), book)
+	if storageUnavailable(w, err) {
+		return nil, false
+	}
+	var parseErr *chapterError
+	if errors.As(err, &parseErr) {
+		log.Printf("read aloud %s: %v", book.ID.Hex(), err)
+		http.Error(w, `{"error":"no text to read aloud in this book"}`, http.StatusBadRequest)
+		return nil, false
+	}
+	if err != nil {
+		http.Error(w, `{"error":"failed to load book file"}`, http.StatusInternalServerError)
+		return nil, false
+	}
+	return chapters, true
+}
+
+// chapterError is a book file whose chapters can't be read.
+type chapterError struct{ err error }
+
+func (e *chapterError) Error() string { return e.err.Error() }
+func (e *chapterError) Unwrap() error { return e.err }
+
+// bookChapters reads the chapters of an EPUB from storage (see openBookFile).
+func (h *BooksHandler) bookChapters(ctx context.Context, book *models.Book) ([]utils.Chapter, error) {
+	body, err := h.openBookFile(ctx, book)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, err
+	}
+	chapters, err := utils.EPUBChapters(data)
+	if err != nil {
+		return nil, &chapterError{err}
+	}
+	return chapters, nil
+}
+
+// RunReadAloud is the queue's handler for JobReadAloud jobs: it reads a chapter aloud a chunk at a time into a
+// temporary file, saving its progress after each, then stores the MP3. Failures a retry can't fix (the book or its
+// chapter went away, the speech API refused the text) fail the job at once; others are retried, from the start of
+// the chapter.
+func (h *BooksHandler) RunReadAloud(ctx context.Context, job *models.QueuedJob) (map[string]string, error) {
+	bookID, err := primitive.ObjectIDFromHex(job.Params["bookId"])
+	if err != nil {
+		return nil, jobs.Permanent(fmt.Errorf("invalid book id %q", job.Params["bookId"]))
+	}
+	chapter, err := strconv.Atoi(job.Params["chapter"])
+	if err != nil {
+		return nil, jobs.Permanent(fmt.Errorf("invalid chapter %q", job.Params["chapter"]))
+	}
+	if h.Speaker == nil || h.Storage == nil {
+		return nil, jobs.Permanent(errors.New("read-aloud is not configured"))
+	}
+	book, err := h.DB.BookByID(ctx, bookID)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, jobs.Permanent(errors.New("the book was deleted"))
+	}
+	if err != nil {
+		return nil, err
+	}
+	t, err := h.DB.AudioTrack(ctx, bookID, chapter)
+	if err != nil {
+		return nil, err
+	}
+	if t == nil {
+		return nil, jobs.Permanent(errors.New("the chapter's audio was deleted"))
+	}
+	err = h.readAloud(ctx, book, t)
+	switch {
+	case err == nil:
+		return map[string]string{"bookId": bookID.Hex(), "chapter": strconv.Itoa(chapter), "status": t.Status}, nil
+	case ctx.Err() != nil && errors.Is(err, context.Canceled):
+		// Shutting down: the job runs again, so the track stays queued.
+		t.Status, t.Percent = models.AudioQueued, 0
+	case jobs.IsPermanent(err) || job.Attempts >= job.MaxAttempts:
+		t.Status, t.Error = models.AudioFailed, err.Error()
+	default:
+		t.Status, t.Percent, t.Error = models.AudioQueued, 0, err.Error()
+	}
+	h.saveAudioTrack(ctx, t)
+	return nil, err
+}
+
+// readAloud reads t's chapter of book aloud and stores it, marking t ready.
+func (h *BooksHandler) readAloud(ctx context.Context, book *models.Book, t *models.AudioTrack) error {
+	chapters, err := h.bookChapters(ctx, book)
+	var parseErr *chapterError
+	if errors.Is(err, service.ErrObjectNotFound) || errors.As(err, &parseErr) {
+		return jobs.Permanent(fmt.Errorf("the book's file can't be read: %w", err))
+	}
+	if err != nil {
+		return err
+	}
+	if t.Chapter >= len(chapters) {
+		return jobs.Permanent(fmt.Errorf("the book has no chapter %d", t.Chapter))
+	}
+	chunks := service.SpeechChunks(chapters[t.Chapter].Text, service.SpeechChunkChars)
+	t.Status, t.Percent, t.Error, t.Voice = models.AudioGenerating, 0, "", h.Speaker.Voice()
+	h.saveAudioTrack(ctx, t)
+
+	f, err := os.CreateTemp("", "books-audio-*.mp3")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	for i, chunk := range chunks {
+		if err := h.Speaker.Speak(ctx, chunk, f); err != nil {
+			if service.IsPermanentSpeechError(err) {
+				return jobs.Permanent(err)
+			}
+			return err
+		}
+		t.Percent = (i + 1) * 100 / len(chunks)
+		if t.Percent < 100 {
+			h.saveAudioTrack(ctx, t)
+		}
+	}
+	size, err := f.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	key := audioKey(book.ID, t.Chapter)
+	if err := h.Storage.Put(ctx, key, f, "audio/mpeg"); err != nil {
+		return err
+	}
+	t.Status, t.Percent, t.S3Key, t.SizeBytes, t.UpdatedAt = models.AudioReady, 100, key, size, h.Clock.Now()
+	return h.DB.UpsertAudioTrack(ctx, t)
+}
+
+// saveAudioTrack records t's status; a failure only delays what listeners see, so it is logged.
+func (h *BooksHandler) saveAudioTrack(ctx context.Context, t *models.AudioTrack) {
+	t.UpdatedAt = h.Clock.Now()
+	if err := h.DB.UpsertAudioTrack(context.WithoutCancel(ctx), t); err != nil {
+		log.Printf("read aloud %s chapter %d: save: %v", t.BookID.Hex(), t.Chapter, err)
+	}
+}
+
+// deleteAudio removes a deleted book's read-aloud tracks and their files.
+func (h *BooksHandler) deleteAudio(ctx context.Context, bookID primitive.ObjectID) {
+	tracks, err := h.DB.AudioTracks(ctx, bookID)
+	if err != nil {
+		log.Printf("book %s: load audio: %v", bookID.Hex(), err)
+		return
+	}
+	if h.Storage != nil {
+		// By chapter rather than S3Key, which a track being read again doesn't have.
+		for _, t := range tracks {
+			_ = h.Storage.Delete(ctx, audioKey(bookID, t.Chapter))
+		}
+	}
+	if err := h.DB.DeleteAudioTracks(ctx, bookID); err != nil {
+		log.Printf("book %s: delete audio: %v", bookID.Hex(), err)
+	}
+}
@@ -1,20 +1,34 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
+	"io"
 	"net/http"
 	"time"
 
 	"github.com/kevinaaaquil/books/backend/middleware"
 	"github.com/kevinaaaquil/books/backend/models"
+	"github.com/kevinaaaquil/books/backend/service"
 	"github.com/kevinaaaquil/books/backend/store"
+	"github.com/kevinaaaquil/books/backend/systemmail"
 	"github.com/golang-jwt/jwt/v5"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"golang.org/x/crypto/bcrypt"
 )
 
 type AuthHandler struct {
-	DB        *store.DB
-	JWTSecret string
+	DB         store.Store
+	JWTSecret  string
+	AccessTTL  time.Duration // lifetime of access tokens
+	RefreshTTL time.Duration // lifetime of refresh tokens, and of access tokens on read-only replicas
+	Clock      service.Clock
+	SystemMail *systemmail.Service // nil disables password reset by email
+	ReadOnly   bool                // ResolveForwardUser neither creates users nor changes roles; no refresh tokens are stored
+	// GuestPoWBits is the proof of work LoginAsGuest requires (see GuestChallenge); 0 = none.
+	GuestPoWBits int
+
+	spent spentChallenges
 }
 
 type LoginRequest struct {
@@ -23,9 +37,12 @@ type LoginRequest struct {
 }
 
 type LoginResponse struct {
-	Token string `json:"token"`
-	Email string `json:"email"`
-	Role  string `json:"role"`
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expiresAt"` // when Token expires
+	// RefreshToken renews the token at POST /api/auth/refresh, once; absent on read-only replicas, which store none.
+	RefreshToken string `json:"refreshToken,omitempty"`
+	Email        string `json:"email"`
+	Role         string `json:"role"`
 }
 
 func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
@@ -61,21 +78,33 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 		role = models.RoleViewer
 	}
 
-	token, err := h.createToken(user.ID.Hex(), user.Email, role)
+	res, err := h.signIn(r.Context(), user.ID, user.Email, role, primitive.NilObjectID)
 	if err != nil {
 		http.Error(w, `{"error":"could not create token"}`, http.StatusInternalServerError)
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(LoginResponse{Token: token, Email: user.Email, Role: role})
+	json.NewEncoder(w).Encode(res)
 }
 
-// LoginAsGuest returns a JWT for a guest user (no password). Requires at least one user with role guest to exist.
+// LoginAsGuest returns a JWT for a guest user (no password). Requires at least one user with role guest to exist,
+// and when GuestPoWBits is set, a solved challenge from GuestChallenge in the body (see GuestLoginRequest).
 func (h *AuthHandler) LoginAsGuest(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
+	if h.GuestPoWBits > 0 {
+		var req GuestLoginRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+			http.Error(w, `{"error":"invalid json"}`, http.StatusBadRequest)
+			return
+		}
+		if !h.solvedGuestChallenge(req.Challenge, req.Solution) {
+			http.Error(w, `{"error":"solve a challenge from /api/auth/guest/challenge first","code":"CHALLENGE_REQUIRED"}`, http.StatusForbidden)
+			return
+		}
+	}
 	user, err := h.DB.UserByRole(r.Context(), models.RoleGuest)
 	if err != nil {
 		http.Error(w, `{"error":"login failed"}`, http.StatusInternalServerError)
@@ -85,23 +114,45 @@ func (h *AuthHandler) LoginAsGuest(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, `{"error":"guest access not configured"}`, http.StatusServiceUnavailable)
 		return
 	}
-	token, err := h.createToken(user.ID.Hex(), user.Email, models.RoleGuest)
+	res, err := h.signIn(r.Context(), user.ID, user.Email, models.RoleGuest, primitive.NilObjectID)
 	if err != nil {
 		http.Error(w, `{"error":"could not create token"}`, http.StatusInternalServerError)
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(LoginResponse{Token: token, Email: user.Email, Role: models.RoleGuest})
+	json.NewEncoder(w).Encode(res)
+}
+
+// signIn returns an access token for the user and a refresh token of the sign-in family, or of a new sign-in
+// when family is zero.
+func (h *AuthHandler) signIn(ctx context.Context, userID primitive.ObjectID, email, role string, family primitive.ObjectID) (*LoginResponse, error) {
+	now := h.Clock.Now()
+	ttl := h.AccessTTL
+	if h.ReadOnly {
+		ttl = h.RefreshTTL
+	}
+	res := &LoginResponse{ExpiresAt: now.Add(ttl), Email: email, Role: role}
+	var err error
+	if res.Token, err = h.createToken(userID.Hex(), email, role, res.ExpiresAt); err != nil {
+		return nil, err
+	}
+	if h.ReadOnly {
+		return res, nil
+	}
+	if res.RefreshToken, err = h.createRefreshToken(ctx, userID, family, now); err != nil {
+		return nil, err
+	}
+	return res, nil
 }
 
-func (h *AuthHandler) createToken(userID, email, role string) (string, error) {
+func (h *AuthHandler) createToken(userID, email, role string, expires time.Time) (string, error) {
 	claims := &middleware.Claims{
 		UserID: userID,
 		Email:  email,
 		Role:   role,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour * 7)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ExpiresAt: jwt.NewNumericDate(expires),
+			IssuedAt:  jwt.NewNumericDate(h.Clock.Now()),
 		},
 	}
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/kevinaaaquil/books/backend/middleware"
+	"github.com/kevinaaaquil/books/backend/models"
+	"github.com/kevinaaaquil/books/backend/service"
+	"github.com/kevinaaaquil/books/backend/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// BlocklistHandler manages the IP blocks (see models.IPBlock) and shows the addresses the burst guard flagged.
+type BlocklistHandler struct {
+	DB        store.Store
+	Clock     service.Clock
+	Blocklist *middleware.Blocklist
+	Burst     *middleware.BurstGuard
+}
+
+// IPBlockRequest blocks an address or range.
+type IPBlockRequest struct {
+	Range     string `json:"range"` // an address, or a CIDR range such as 203.0.113.0/24
+	Reason    string `json:"reason"`
+	ExpiresIn string `json:"expiresIn"` // a duration such as "24h"; empty = until removed
+}
+
+// BlocklistResponse is the IP blocks in force and the addresses this instance flagged for bursts.
+type BlocklistResponse struct {
+	Blocks  []models.IPBlock   `json:"blocks"`
+	Flagged []models.FlaggedIP `json:"flagged"`
+}
+
+// parseIPRange returns s as a prefix: a CIDR range with the host bits cleared, or a single address.
+func parseIPRange(s string) (netip.Prefix, bool) {
+	s = strings.TrimSpace(s)
+	if p, err := netip.ParsePrefix(s); err == nil {
+		return p.Masked(), true
+	}
+	if addr, err := netip.ParseAddr(s); err == nil {
+		addr = addr.Unmap()
+		return netip.PrefixFrom(addr, addr.BitLen()), true
+	}
+	return netip.Prefix{}, false
+}
+
+// List returns the blocks in force, oldest first, and the flagged addresses, most recent first.
+// GET /api/admin/blocklist (admin only).
+func (h *BlocklistHandler) List(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	blocks, err := h.DB.IPBlocks(r.Context(), h.Clock.Now())
+	if err != nil {
+		http.Error(w, `{"error":"failed to list blocks"}`, http.StatusInternalServerError)
+		return
+	}
+	res := BlocklistResponse{Blocks: blocks, Flagged: []models.FlaggedIP{}}
+	if res.Blocks == nil {
+		res.Blocks = []models.IPBlock{}
+	}
+	if h.Burst != nil {
+		res.Flagged = h.Burst.Flagged()
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(res)
+}
+
+// Create blocks an address or range from the whole API; other instances follow within 30 seconds. A range that
+// holds the admin's own address is refused, so they can't lock themselves out. POST /api/admin/blocklist (admin only).
+func (h *BlocklistHandler) Create(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req IPBlockRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error":"invalid json"}`, http.StatusBadRequest)
+		return
+	}
+	prefix, ok := parseIPRange(req.Range)
+	if !ok {
+		http.Error(w, `{"error":"range must be an IP address or a CIDR range"}`, http.StatusBadRequest)
+		return
+	}
+	own := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(own); err == nil {
+		own = host
+	}
+	if addr, err := netip.ParseAddr(own); err == nil && prefix.Contains(addr.Unmap()) {
+		http.Error(w, `{"error":"range contains your own address"}`, http.StatusBadRequest)
+		return
+	}
+	now := h.Clock.Now()
+	b := &models.IPBlock{
+		Range:     prefix.String(),
+		Reason:    strings.TrimSpace(req.Reason),
+		CreatedBy: middleware.EmailFromContext(r.Context()),
+		CreatedAt: now,
+	}
+	if req.ExpiresIn != "" {
+		d, err := time.ParseDuration(req.ExpiresIn)
+		if err != nil || d <= 0 {
+			http.Error(w, `{"error":"expiresIn must be a positive duration such as 24h"}`, http.StatusBadRequest)
+			return
+		}
+		expires := now.Add(d)
+		b.ExpiresAt = &expires
+	}
+	if err := h.DB.DeleteExpiredIPBlocks(r.Context(), now); err != nil {
+		log.Printf("blocklist: delete expired blocks: %v", err)
+	}
+	var err error
+	if b.ID, err = h.DB.InsertIPBlock(r.Context(), b); err != nil {
+		http.Error(w, `{"error":"failed to save block"}`, http.StatusInternalServerError)
+		return
+	}
+	h.Blocklist.Reload(r.Context())
+	log.Printf("blocklist: %s blocked by %s", b.Range, b.CreatedBy)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(b)
+}
+
+// Delete lifts a block; other instances follow within 30 seconds. DELETE /api/admin/blocklist/:id (admin only).
+func (h *BlocklistHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	id, err := primitive.ObjectIDFromHex(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, `{"error":"invalid block id"}`, http.StatusBadRequest)
+		return
+	}
+	found, err := h.DB.DeleteIPBlock(r.Context(), id)
+	if err != nil {
+		http.Error(w, `{"error":"failed to delete block"}`, http.StatusInternalServerError)
+		return
+	}
+	if !found {
+		http.Error(w, `{"error":"block not found"}`, http.StatusNotFound)
+		return
+	}
+	h.Blocklist.Reload(r.Context())
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Unflag lifts the burst guard's flag on an address, on this instance. DELETE /api/admin/blocklist/flagged/:ip
+// (admin only).
+func (h *BlocklistHandler) Unflag(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	addr, err := netip.ParseAddr(chi.URLParam(r, "ip"))
+	if err != nil {
+		http.Error(w, `{"error":"invalid ip"}`, http.StatusBadRequest)
+		return
+	}
+	if h.Burst == nil || !h.Burst.Unflag(addr.Unmap().String()) {
+		http.Error(w, `{"error":"ip not flagged"}`, http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
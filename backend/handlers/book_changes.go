@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"context"
+	"io"
+	"log"
+	"slices"
+
+	"github.com/kevinaaaquil/books/backend/models"
+	"github.com/kevinaaaquil/books/backend/service"
+	"github.com/kevinaaaquil/books/backend/store"
+	"github.com/kevinaaaquil/books/backend/utils"
+)
+
+// BookChanged brings what is derived from a book (its search index entry and optimized copy) up to date with a
+// change seen by a store.BookWatcher. Changes made through this handler are applied twice, which is harmless;
+// the point is to catch edits made outside the app.
+func (h *BooksHandler) BookChanged(ctx context.Context, c store.BookChange) {
+	switch {
+	case c.Op == store.BookDeleted:
+		if h.Search != nil {
+			h.Search.Remove(c.BookID)
+		}
+		h.dropOptimized(ctx, c)
+	case c.Fields == nil || slices.Contains(c.Fields, "s3Key"):
+		// New book, replaced document or replaced file: index the text too.
+		if h.Search != nil && (c.Op == store.BookUpdated || !h.Search.Contains(c.BookID)) {
+			h.Search.Put(c.Book, h.bookText(ctx, c.Book))
+		}
+		if c.Op == store.BookUpdated {
+			h.dropOptimized(ctx, c)
+		}
+	default:
+		if h.Search != nil {
+			h.Search.PutMetadata(c.Book)
+		}
+	}
+}
+
+func (h *BooksHandler) dropOptimized(ctx context.Context, c store.BookChange) {
+	if h.Storage == nil {
+		return
+	}
+	if err := h.Storage.Delete(ctx, optimizedKey(c.BookID, h.OptimizeMaxImagePx)); err != nil {
+		log.Printf("book change %s: drop optimized copy: %v", c.BookID.Hex(), err)
+	}
+}
+
+// bookText returns the text of an EPUB book for the search index; empty for PDFs and unreadable files, archived
+// ones included: indexing never restores a file.
+func (h *BooksHandler) bookText(ctx context.Context, book *models.Book) string {
+	if h.Storage == nil || book.Format != "epub" || book.S3Key == "" || service.NeedsRestore(book.StorageClass) {
+		return ""
+	}
+	body, _, err := h.Storage.GetObject(ctx, book.S3Key)
+	if err != nil {
+		log.Printf("book change %s: read file: %v", book.ID.Hex(), err)
+		return ""
+	}
+	defer body.Close()
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return ""
+	}
+	text, _ := utils.EPUBText(data)
+	return text
+}
@@ -0,0 +1,190 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/kevinaaaquil/books/backend/models"
+	"github.com/kevinaaaquil/books/backend/search"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Sort orders for GET /api/books. Each has a natural direction (A-Z for text, newest and best rated first),
+// reversed by ?order=.
+const (
+	bookSortTitle     = models.BookSortTitle
+	bookSortCreatedAt = models.BookSortCreatedAt
+	bookSortAuthor    = models.BookSortAuthor
+	bookSortRating    = models.BookSortRating
+	bookSortRelevance = "relevance" // with ?q=: best match first; the default then
+)
+
+const (
+	defaultBooksPageLimit = 50
+	maxBooksPageLimit     = 200
+	maxBooksOffset        = 1 << 30 // far past any library; keeps offset arithmetic from overflowing
+)
+
+// BooksPage is GET /api/books when it is paged (?page=, ?limit=, ?sort=, ?order= or ?cursor= given).
+type BooksPage struct {
+	Books []models.Book `json:"books"`
+	Total int           `json:"total"` // books matching, across every page
+	Page  int           `json:"page"`  // 1-based
+	Limit int           `json:"limit"`
+	Sort  string        `json:"sort"`
+	Order string        `json:"order"` // asc or desc
+	// Next and Prev fetch the neighbouring pages as ?cursor= alone (they carry the query, sort and limit);
+	// empty at either end.
+	Next string `json:"next,omitempty"`
+	Prev string `json:"prev,omitempty"`
+}
+
+// booksCursor is what a BooksPage cursor encodes.
+type booksCursor struct {
+	Q      string   `json:"q,omitempty"`
+	A11y   []string `json:"a,omitempty"` // accessibility features every book must declare
+	Sort   string   `json:"s"`
+	Order  string   `json:"o"`
+	Limit  int      `json:"l"`
+	Offset int      `json:"f"`
+}
+
+func (c booksCursor) encode() string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// pagedBooks reports whether the request asks for a BooksPage rather than the plain list.
+func pagedBooks(r *http.Request) bool {
+	q := r.URL.Query()
+	return q.Has("page") || q.Has("limit") || q.Has("sort") || q.Has("order") || q.Has("cursor")
+}
+
+// accessibilityFilter reads ?accessibility=, a comma-separated list of accessibility features such as
+// alternativeText.
+func accessibilityFilter(r *http.Request) []string {
+	var features []string
+	for _, f := range strings.Split(r.URL.Query().Get("accessibility"), ",") {
+		if f = strings.TrimSpace(f); f != "" {
+			features = append(features, f)
+		}
+	}
+	return features
+}
+
+// booksPageRequest reads ?q=, ?accessibility= and the paging parameters, or the cursor that replaces them all, writing the error
+// response when they are invalid.
+func booksPageRequest(w http.ResponseWriter, r *http.Request) (c booksCursor, ok bool) {
+	query := r.URL.Query()
+	if s := query.Get("cursor"); s != "" {
+		b, err := base64.RawURLEncoding.DecodeString(s)
+		if err != nil || json.Unmarshal(b, &c) != nil || c.Limit < 1 || c.Limit > maxBooksPageLimit || c.Offset < 0 || c.Offset > maxBooksOffset {
+			http.Error(w, `{"error":"invalid cursor"}`, http.StatusBadRequest)
+			return c, false
+		}
+		return c, true
+	}
+	c = booksCursor{Q: strings.TrimSpace(query.Get("q")), A11y: accessibilityFilter(r), Sort: query.Get("sort"), Order: query.Get("order"), Limit: defaultBooksPageLimit}
+	if c.Sort == "" {
+		c.Sort = bookSortCreatedAt
+		if c.Q != "" {
+			c.Sort = bookSortRelevance
+		}
+	}
+	if !slices.Contains([]string{bookSortTitle, bookSortCreatedAt, bookSortAuthor, bookSortRating, bookSortRelevance}, c.Sort) {
+		http.Error(w, `{"error":"sort must be title, createdAt, author or rating"}`, http.StatusBadRequest)
+		return c, false
+	}
+	if c.Order != "" && c.Order != "asc" && c.Order != "desc" {
+		http.Error(w, `{"error":"order must be asc or desc"}`, http.StatusBadRequest)
+		return c, false
+	}
+	if v := query.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxBooksPageLimit {
+			http.Error(w, `{"error":"limit must be between 1 and `+strconv.Itoa(maxBooksPageLimit)+`"}`, http.StatusBadRequest)
+			return c, false
+		}
+		c.Limit = n
+	}
+	if v := query.Get("page"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			http.Error(w, `{"error":"page must be 1 or more"}`, http.StatusBadRequest)
+			return c, false
+		}
+		if n-1 > maxBooksOffset/c.Limit {
+			http.Error(w, `{"error":"page is out of range"}`, http.StatusBadRequest)
+			return c, false
+		}
+		c.Offset = (n - 1) * c.Limit
+	}
+	if c.Order == "" {
+		c.Order = "asc"
+		if c.Sort == bookSortCreatedAt || c.Sort == bookSortRating {
+			c.Order = "desc"
+		}
+	}
+	return c, true
+}
+
+// booksPage loads the page c asks for. The store filters, sorts and cuts it; with the relevance sort it returns
+// every visible match of the search instead, which is ranked and cut here.
+func (h *BooksHandler) booksPage(r *http.Request, c booksCursor) (BooksPage, error) {
+	q, err := h.visibleQuery(r)
+	if err != nil {
+		return BooksPage{}, err
+	}
+	q.Accessibility = c.A11y
+	q.Sort, q.Desc = c.Sort, c.Order == "desc"
+	var hits []search.Hit
+	if c.Q != "" && h.Search != nil {
+		hits = h.Search.Search(c.Q)
+		q.IDs = make([]primitive.ObjectID, len(hits))
+		for i, hit := range hits {
+			q.IDs[i] = hit.BookID
+		}
+	}
+	if c.Sort != bookSortRelevance {
+		q.Offset, q.Limit = c.Offset, c.Limit
+		books, total, err := h.DB.BooksPage(r.Context(), q)
+		if err != nil {
+			return BooksPage{}, err
+		}
+		return newBooksPage(c, books, total), nil
+	}
+	q.Sort, q.Desc = bookSortCreatedAt, true // the order without a search to rank by
+	books, _, err := h.DB.BooksPage(r.Context(), q)
+	if err != nil {
+		return BooksPage{}, err
+	}
+	if hits != nil {
+		books = rankBooks(books, hits)
+	}
+	total := len(books)
+	books = books[min(c.Offset, total):min(c.Offset+c.Limit, total)]
+	return newBooksPage(c, books, total), nil
+}
+
+// newBooksPage returns the BooksPage c asks for, holding books out of total.
+func newBooksPage(c booksCursor, books []models.Book, total int) BooksPage {
+	page := BooksPage{Books: books, Total: total, Page: c.Offset/c.Limit + 1, Limit: c.Limit, Sort: c.Sort, Order: c.Order}
+	if page.Books == nil {
+		page.Books = []models.Book{}
+	}
+	if c.Offset+c.Limit < total {
+		next := c
+		next.Offset += c.Limit
+		page.Next = next.encode()
+	}
+	if c.Offset > 0 {
+		prev := c
+		prev.Offset = max(0, min(c.Offset, total)-c.Limit)
+		page.Prev = prev.encode()
+	}
+	return page
+}
@@ -1,32 +1,76 @@
 package handlers
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"io"
 	"log"
+	"math"
+	"net"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/go-chi/chi/v5"
-	mail "github.com/go-mail/mail/v2"
+	"github.com/kevinaaaquil/books/backend/events"
+	"github.com/kevinaaaquil/books/backend/jobs"
 	"github.com/kevinaaaquil/books/backend/middleware"
 	"github.com/kevinaaaquil/books/backend/models"
+	"github.com/kevinaaaquil/books/backend/search"
 	"github.com/kevinaaaquil/books/backend/service"
 	"github.com/kevinaaaquil/books/backend/store"
 	"github.com/kevinaaaquil/books/backend/utils"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
-const iCloudSMTPHost = "smtp.mail.me.com"
-const iCloudSMTPPort = 587
-
 type BooksHandler struct {
-	DB     *store.DB
-	S3     *service.S3Service
-	EncKey []byte // 32 bytes for decrypting Kindle app password; nil = not set
+	DB               store.Store
+	Storage          service.ObjectStore
+	Metadata         service.MetadataProvider
+	Documents        service.DOIProvider // refreshes documents by DOI; nil refreshes everything by ISBN
+	Mailer           service.Mailer      // delivers sends by email; see service.Mailer.UsesSenderAccount
+	Clock            service.Clock
+	EncKey           []byte // 32 bytes for decrypting Kindle app password; nil = not set
+	FilenameTemplate string // download/attachment filename template; see utils.RenderFilename
+	StreamDownloads  bool   // when true, Download returns signed URLs to StreamFile instead of S3 presigned URLs
+	Signer           *service.URLSigner
+	SendLimits       map[string]models.SendLimit // per-role send limits; roles not listed are unlimited
+	// RequireKindleVerification refuses sends to Kindle addresses not yet confirmed (see EmailConfigHandler.ConfirmVerification).
+	RequireKindleVerification bool
+	Drives                    map[string]service.Drive // cloud drives for delivery targets, by target kind
+	Converter                 service.Converter        // nil = books are only sent in the format they are stored in
+	Speaker                   service.Speaker          // reads EPUBs aloud (see ReadAloud); nil disables read-aloud
+	Classifier                service.Classifier       // suggests categories (see SuggestCategories); nil disables suggestions
+	Embedder                  service.Embedder         // embeds books and queries for Semantic; nil disables semantic search
+	Vectors                   *search.Vectors          // the embeddings Semantic searches; set with Embedder
+	Assistant                 service.Assistant        // answers questions about the library (see Ask); nil disables it
+	PreviewWords              int                      // length of book previews; 0 disables them
+	OptimizeMaxImagePx        int                      // longer side images are downscaled to in optimized sends; 0 = keep
+	Search                    *search.Index            // kept current as books change; nil disables ?q=
+	RefreshTimeout            time.Duration            // overall deadline for RefreshMetadata; 0 = none beyond the provider's
+	DownloadURLExpiry         map[string]time.Duration // by role; roles not listed get downloadURLExpiry
+	Settings                  *SettingsHandler         // can turn presigned downloads off at runtime
+	GraphMaxBooks             int                      // most books in a graph export; 0 = defaultGraphMaxBooks
+	RestoreDays               int                      // how long a copy of an archived file restored for a download lasts; 0 = 7
+	BundleMaxBytes            int64                    // largest collection DownloadBundle zips; 0 disables bundles
+	Queue                     *jobs.Queue              // runs sends in the background; see RunSend
+	Events                    events.Sink              // books deleted, download links and sends are exported to it; may be nil
+
+	sendLocks userLocks
 }
 
+// downloadURLExpiry is how long download links (S3 presigned or signed stream URLs) stay valid by default.
+const downloadURLExpiry = 15 * time.Minute
+
+// List returns the books the user may see. With ?q= only books matching every word (in metadata or EPUB text)
+// are returned, best match first; with ?accessibility= only EPUBs declaring every listed accessibility feature
+// (e.g. alternativeText for image descriptions). With ?page=, ?limit=, ?sort=, ?order= or ?cursor= the answer is a BooksPage
+// instead of every book.
 func (h *BooksHandler) List(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
@@ -36,26 +80,85 @@ func (h *BooksHandler) List(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
 		return
 	}
-	role := middleware.RoleFromContext(r.Context())
-	var books []models.Book
-	var err error
-	if role == models.RoleGuest {
-		books, err = h.DB.BooksVisibleToGuest(r.Context())
-	} else {
-		books, err = h.DB.AllBooks(r.Context())
+	if pagedBooks(r) {
+		c, ok := booksPageRequest(w, r)
+		if !ok {
+			return
+		}
+		page, err := h.booksPage(r, c)
+		if err != nil {
+			http.Error(w, `{"error":"failed to list books"}`, http.StatusInternalServerError)
+			return
+		}
+		for i := range page.Books {
+			setCoverURLIfExtracted(&page.Books[i])
+			setContentRating(&page.Books[i])
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(page)
+		return
 	}
+	q, err := h.visibleQuery(r)
 	if err != nil {
 		http.Error(w, `{"error":"failed to list books"}`, http.StatusInternalServerError)
 		return
 	}
+	q.Accessibility, q.Desc = accessibilityFilter(r), true
+	books, _, err := h.DB.BooksPage(r.Context(), q)
+	if err != nil {
+		http.Error(w, `{"error":"failed to list books"}`, http.StatusInternalServerError)
+		return
+	}
+	if text := strings.TrimSpace(r.URL.Query().Get("q")); text != "" && h.Search != nil {
+		books = rankBooks(books, h.Search.Search(text))
+	}
 	for i := range books {
 		setCoverURLIfExtracted(&books[i])
+		setContentRating(&books[i])
 	}
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(books)
 }
 
-func (h *BooksHandler) Get(w http.ResponseWriter, r *http.Request) {
+// visibleQuery returns the query for the books the current user may see: for guests those with ViewByGuest, for
+// users with a maximum content rating those within it.
+func (h *BooksHandler) visibleQuery(r *http.Request) (models.BookQuery, error) {
+	maxRating, err := h.maxContentRating(r)
+	if err != nil {
+		return models.BookQuery{}, err
+	}
+	return models.BookQuery{GuestOnly: middleware.RoleFromContext(r.Context()) == models.RoleGuest, MaxContentRating: maxRating}, nil
+}
+
+// visibleBooks returns the books the current user may see (see visibleQuery), newest first.
+func (h *BooksHandler) visibleBooks(r *http.Request) ([]models.Book, error) {
+	q, err := h.visibleQuery(r)
+	if err != nil {
+		return nil, err
+	}
+	q.Desc = true
+	books, _, err := h.DB.BooksPage(r.Context(), q)
+	return books, err
+}
+
+// rankBooks returns the books that are among hits, in the hits' order.
+func rankBooks(books []models.Book, hits []search.Hit) []models.Book {
+	byID := make(map[primitive.ObjectID]models.Book, len(books))
+	for _, b := range books {
+		byID[b.ID] = b
+	}
+	ranked := make([]models.Book, 0, len(hits))
+	for _, hit := range hits {
+		if b, ok := byID[hit.BookID]; ok {
+			ranked = append(ranked, b)
+		}
+	}
+	return ranked
+}
+
+// Timeline returns book counts grouped by publication decade/year and by month added (GET /api/books/timeline).
+// Only the books the user may see are counted (see visibleQuery).
+func (h *BooksHandler) Timeline(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
 		return
@@ -64,33 +167,95 @@ func (h *BooksHandler) Get(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
 		return
 	}
-	idStr := chi.URLParam(r, "id")
-	id, err := primitive.ObjectIDFromHex(idStr)
+	q, err := h.visibleQuery(r)
 	if err != nil {
-		http.Error(w, `{"error":"invalid book id"}`, http.StatusBadRequest)
+		http.Error(w, `{"error":"failed to build timeline"}`, http.StatusInternalServerError)
 		return
 	}
-	book, err := h.DB.BookByID(r.Context(), id)
+	timeline, err := h.DB.BookTimeline(r.Context(), q)
 	if err != nil {
-		http.Error(w, `{"error":"book not found"}`, http.StatusNotFound)
+		http.Error(w, `{"error":"failed to build timeline"}`, http.StatusInternalServerError)
 		return
 	}
-	role := middleware.RoleFromContext(r.Context())
-	if role == models.RoleGuest && !book.ViewByGuest {
-		http.Error(w, `{"error":"book not found"}`, http.StatusNotFound)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(timeline)
+}
+
+func (h *BooksHandler) Get(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if _, ok := middleware.UserIDFromContext(r.Context()); !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+	book, ok := h.visibleBook(w, r)
+	if !ok {
 		return
 	}
 	setCoverURLIfExtracted(book)
+	setContentRating(book)
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(book)
 }
 
+// visibleBook loads the book named in the URL, writing the error response when there is none the current user
+// may see (guests see only books with ViewByGuest, and users with a maximum content rating only books within it).
+func (h *BooksHandler) visibleBook(w http.ResponseWriter, r *http.Request) (*models.Book, bool) {
+	id, err := primitive.ObjectIDFromHex(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, `{"error":"invalid book id"}`, http.StatusBadRequest)
+		return nil, false
+	}
+	book, err := h.DB.BookByID(r.Context(), id)
+	if err != nil {
+		http.Error(w, `{"error":"book not found"}`, http.StatusNotFound)
+		return nil, false
+	}
+	if middleware.RoleFromContext(r.Context()) == models.RoleGuest && !book.ViewByGuest {
+		http.Error(w, `{"error":"book not found"}`, http.StatusNotFound)
+		return nil, false
+	}
+	maxRating, err := h.maxContentRating(r)
+	if err != nil {
+		http.Error(w, `{"error":"failed to load user"}`, http.StatusInternalServerError)
+		return nil, false
+	}
+	if !contentRatingAllowed(maxRating, book) {
+		http.Error(w, `{"error":"book not found"}`, http.StatusNotFound)
+		return nil, false
+	}
+	return book, true
+}
+
+// maxContentRating returns the current user's maximum content rating, "" when they have none.
+func (h *BooksHandler) maxContentRating(r *http.Request) (string, error) {
+	userID, _ := middleware.UserIDFromContext(r.Context())
+	user, err := h.DB.UserByID(r.Context(), userID)
+	if err != nil || user == nil {
+		return "", err
+	}
+	return user.MaxContentRating, nil
+}
+
+// contentRatingAllowed reports whether a user limited to maxRating may see book.
+func contentRatingAllowed(maxRating string, book *models.Book) bool {
+	rating, _ := book.EffectiveContentRating()
+	return models.ContentRatingAllowed(maxRating, rating)
+}
+
+// setContentRating fills in book.ContentRating from its categories when it has none set, for responses.
+func setContentRating(book *models.Book) {
+	book.ContentRating, book.ContentRatingInferred = book.EffectiveContentRating()
+}
+
 // setCoverURLIfExtracted sets book.CoverURL / ThumbnailURL when an extracted cover is stored, and always sets ExtractedCoverURL when CoverS3Key is set so the frontend can toggle.
 func setCoverURLIfExtracted(book *models.Book) {
 	if book.CoverS3Key == "" {
 		return
 	}
-	extractedURL := "/api/books/" + book.ID.Hex() + "/cover"
+	extractedURL := "/api/books/" + book.ID.Hex() + "/cover?v=" + coverVersion(book.CoverS3Key)
 	book.ExtractedCoverURL = extractedURL
 	if book.CoverURL == "" {
 		book.CoverURL = extractedURL
@@ -100,7 +265,14 @@ func setCoverURLIfExtracted(book *models.Book) {
 	}
 }
 
-// Cover streams the book's extracted cover image from S3 (e.g. cover.jpeg from EPUB). GET /api/books/:id/cover (public so img src works).
+// coverVersion identifies a stored cover. Every cover is stored under a new key, so URLs carrying it are content-addressed.
+func coverVersion(coverS3Key string) string {
+	sum := sha256.Sum256([]byte(coverS3Key))
+	return hex.EncodeToString(sum[:6])
+}
+
+// Cover streams the book's extracted cover image from storage (e.g. cover.jpeg from EPUB). GET /api/books/:id/cover (public so img src works).
+// Responses to ?v= URLs for the current cover are cacheable forever (the route's policy); any other URL must be revalidated.
 func (h *BooksHandler) Cover(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
@@ -117,11 +289,14 @@ func (h *BooksHandler) Cover(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, `{"error":"book not found"}`, http.StatusNotFound)
 		return
 	}
-	if book.CoverS3Key == "" || h.S3 == nil {
+	if book.CoverS3Key == "" || h.Storage == nil {
 		http.Error(w, `{"error":"no cover"}`, http.StatusNotFound)
 		return
 	}
-	body, contentType, err := h.S3.GetObject(r.Context(), book.CoverS3Key)
+	body, contentType, err := h.Storage.GetObject(r.Context(), book.CoverS3Key)
+	if storageUnavailable(w, err) {
+		return
+	}
 	if err != nil {
 		http.Error(w, `{"error":"failed to load cover"}`, http.StatusInternalServerError)
 		return
@@ -130,6 +305,9 @@ func (h *BooksHandler) Cover(w http.ResponseWriter, r *http.Request) {
 	if contentType != "" {
 		w.Header().Set("Content-Type", contentType)
 	}
+	if r.URL.Query().Get("v") != coverVersion(book.CoverS3Key) {
+		w.Header().Set("Cache-Control", middleware.CacheNone.CacheControl)
+	}
 	io.Copy(w, body)
 }
 
@@ -137,8 +315,21 @@ type DownloadResponse struct {
 	URL string `json:"url"`
 }
 
+// RestoreResponse is Download's answer while an archived book's file is being restored.
+type RestoreResponse struct {
+	Status       string     `json:"status"` // "restoring"
+	StorageClass string     `json:"storageClass"`
+	RequestedAt  *time.Time `json:"requestedAt,omitempty"`
+}
+
+// restoreRetryAfter is the Retry-After, in seconds, sent while a restore is in progress; Standard restores take
+// hours.
+const restoreRetryAfter = "3600"
+
+// Download returns a download URL as JSON, or streams the file with ?mode=stream. HEAD always reports
+// the streamed file's size and hash headers without a body.
 func (h *BooksHandler) Download(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
@@ -146,49 +337,232 @@ func (h *BooksHandler) Download(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
 		return
 	}
-	idStr := chi.URLParam(r, "id")
-	id, err := primitive.ObjectIDFromHex(idStr)
+	book, ok := h.visibleBook(w, r)
+	if !ok {
+		return
+	}
+	role := middleware.RoleFromContext(r.Context())
+	if h.Storage == nil {
+		http.Error(w, `{"error":"download not configured"}`, http.StatusServiceUnavailable)
+		return
+	}
+	if !h.restoreArchived(w, r, book) {
+		return
+	}
+	if r.URL.Query().Get("mode") == "stream" || r.Method == http.MethodHead {
+		h.streamBook(w, r, book)
+		return
+	}
+	expiry := h.DownloadURLExpiry[role]
+	if expiry <= 0 {
+		expiry = downloadURLExpiry
+	}
+	userID, _ := middleware.UserIDFromContext(r.Context())
+	now := h.Clock.Now()
+	link := &models.DownloadLink{
+		BookID:    book.ID,
+		UserID:    userID,
+		Email:     middleware.EmailFromContext(r.Context()),
+		Role:      role,
+		Kind:      models.DownloadLinkPresigned,
+		IP:        clientIP(r),
+		IssuedAt:  now,
+		ExpiresAt: now.Add(expiry),
+		Bytes:     book.SizeBytes,
+	}
+	var url string
+	if h.StreamDownloads || h.Settings != nil && h.Settings.Current(r.Context()).PresignedDownloadsDisabled {
+		link.Kind = models.DownloadLinkStream
+		url = h.Signer.Sign(streamFilePath(book.ID), expiry)
+	} else {
+		responseFilename := utils.RenderFilename(h.FilenameTemplate, book)
+		var err error
+		url, err = h.Storage.PresignedGetURL(r.Context(), book.S3Key, expiry, responseFilename)
+		if storageUnavailable(w, err) {
+			return
+		}
+		if err != nil {
+			http.Error(w, `{"error":"failed to generate download url"}`, http.StatusInternalServerError)
+			return
+		}
+	}
+	// Every link is audited; one that can't be recorded is not handed out.
+	if err := h.recordDownloadLink(r.Context(), link); err != nil {
+		http.Error(w, `{"error":"failed to record download link"}`, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(DownloadResponse{URL: url})
+}
+
+// restoreArchived reports whether an archived book's file can be read. When it can't, it starts a restore unless
+// one is in progress and answers 202 with a RestoreResponse; downloading again once it is done gets the file.
+func (h *BooksHandler) restoreArchived(w http.ResponseWriter, r *http.Request, book *models.Book) bool {
+	restore, err := h.restoreState(r.Context(), book)
+	if storageUnavailable(w, err) {
+		return false
+	}
+	if errors.Is(err, errRestoreFailed) {
+		http.Error(w, `{"error":"failed to restore the archived file"}`, http.StatusInternalServerError)
+		return false
+	}
+	if err != nil {
+		http.Error(w, `{"error":"failed to check the file's storage class"}`, http.StatusInternalServerError)
+		return false
+	}
+	if restore == nil {
+		return true
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Retry-After", restoreRetryAfter)
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(restore)
+	return false
+}
+
+// restoreState checks whether book's file can be read now. It returns nil when it can; when it is archived, it
+// starts a restore unless one is in progress and returns the restore's state.
+func (h *BooksHandler) restoreState(ctx context.Context, book *models.Book) (*RestoreResponse, error) {
+	if !service.NeedsRestore(book.StorageClass) {
+		return nil, nil
+	}
+	tier, ok := service.TiererOf(h.Storage)
+	if !ok {
+		return nil, nil // moved to storage without classes; nothing to restore
+	}
+	info, err := tier.Tier(ctx, book.S3Key)
+	if err != nil {
+		return nil, err
+	}
+	now := h.Clock.Now()
+	if info.Readable(now) {
+		return nil, nil
+	}
+	requestedAt := book.RestoreRequestedAt
+	if !info.Restoring {
+		days := h.RestoreDays
+		if days <= 0 {
+			days = 7
+		}
+		if err := tier.Restore(ctx, book.S3Key, days); err != nil {
+			log.Printf("restore %s: %v", book.ID.Hex(), err)
+			return nil, errRestoreFailed
+		}
+		if err := h.DB.SetBookRestoreRequested(ctx, book.ID, now); err != nil {
+			log.Printf("restore %s: %v", book.ID.Hex(), err)
+		}
+		requestedAt = &now
+	}
+	return &RestoreResponse{Status: "restoring", StorageClass: info.Class, RequestedAt: requestedAt}, nil
+}
+
+// errRestoreFailed is returned by restoreState when the storage refused to restore an archived file.
+var errRestoreFailed = errors.New("failed to restore the archived file")
+
+// errRestoring is returned by openBookFile while an archived book's file is being restored.
+var errRestoring = errors.New("the book's file is archived and being restored")
+
+// openBookFile opens book's stored file for jobs, which can't answer with a RestoreResponse: an archived file is
+// restored first (see restoreState), failing with errRestoring until it can be read.
+func (h *BooksHandler) openBookFile(ctx context.Context, book *models.Book) (io.ReadCloser, error) {
+	restore, err := h.restoreState(ctx, book)
+	if err != nil {
+		return nil, err
+	}
+	if restore != nil {
+		return nil, errRestoring
+	}
+	body, _, err := h.Storage.GetObject(ctx, book.S3Key)
+	return body, err
+}
+
+// clientIP returns the caller's address without the port (RemoteAddr is the real client IP behind middleware.RealIP).
+func clientIP(r *http.Request) string {
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
+
+// storageUnavailable answers 503 with a retry hint when err is service.ErrStorageUnavailable, and reports whether
+// it did.
+func storageUnavailable(w http.ResponseWriter, err error) bool {
+	if !errors.Is(err, service.ErrStorageUnavailable) {
+		return false
+	}
+	w.Header().Set("Retry-After", "30")
+	http.Error(w, `{"error":"storage unavailable, try again shortly"}`, http.StatusServiceUnavailable)
+	return true
+}
+
+func streamFilePath(id primitive.ObjectID) string {
+	return "/api/books/" + id.Hex() + "/file"
+}
+
+// StreamFile streams a book through the API for a signed URL issued by Download in stream mode.
+// GET /api/books/:id/file?expires=...&signature=... (public; the signature stands in for auth).
+func (h *BooksHandler) StreamFile(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	id, err := primitive.ObjectIDFromHex(chi.URLParam(r, "id"))
 	if err != nil {
 		http.Error(w, `{"error":"invalid book id"}`, http.StatusBadRequest)
 		return
 	}
+	if h.Signer == nil || !h.Signer.Verify(streamFilePath(id), r.URL.Query()) {
+		http.Error(w, `{"error":"invalid or expired link"}`, http.StatusForbidden)
+		return
+	}
 	book, err := h.DB.BookByID(r.Context(), id)
 	if err != nil {
 		http.Error(w, `{"error":"book not found"}`, http.StatusNotFound)
 		return
 	}
-	role := middleware.RoleFromContext(r.Context())
-	if role == models.RoleGuest && !book.ViewByGuest {
-		http.Error(w, `{"error":"book not found"}`, http.StatusNotFound)
+	if h.Storage == nil {
+		http.Error(w, `{"error":"download not configured"}`, http.StatusServiceUnavailable)
 		return
 	}
-	if h.S3 == nil {
-		http.Error(w, `{"error":"download not configured"}`, http.StatusServiceUnavailable)
+	h.streamBook(w, r, book)
+}
+
+// streamBook proxies the book file from storage via http.ServeContent, which handles Range, If-Range and HEAD.
+// Clients resuming a download send Range with If-Range set to the ETag; HEAD exposes Content-Length and
+// the SHA-256 (ETag and Repr-Digest) so a finished download can be verified.
+func (h *BooksHandler) streamBook(w http.ResponseWriter, r *http.Request, book *models.Book) {
+	obj, err := h.Storage.OpenObject(r.Context(), book.S3Key)
+	if storageUnavailable(w, err) {
 		return
 	}
-	ext := ".epub"
-	if book.Format != "" {
-		ext = "." + strings.ToLower(strings.TrimPrefix(book.Format, "."))
-	}
-	responseFilename := book.OriginalName
-	if responseFilename == "" || strings.Contains(responseFilename, "/") {
-		if book.Title != "" {
-			safeTitle := strings.TrimSpace(book.Title)
-			for _, c := range `/\.?%*:|"<>` {
-				safeTitle = strings.ReplaceAll(safeTitle, string(c), "-")
-			}
-			responseFilename = safeTitle + ext
-		} else {
-			responseFilename = "book" + ext
-		}
+	if errors.Is(err, service.ErrObjectNotFound) {
+		http.Error(w, `{"error":"book file missing from storage"}`, http.StatusNotFound)
+		return
 	}
-	url, err := h.S3.PresignedGetURL(r.Context(), book.S3Key, 15*time.Minute, responseFilename)
 	if err != nil {
-		http.Error(w, `{"error":"failed to generate download url"}`, http.StatusInternalServerError)
+		http.Error(w, `{"error":"failed to load book file"}`, http.StatusInternalServerError)
 		return
 	}
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(DownloadResponse{URL: url})
+	defer obj.Close()
+	info := obj.Info()
+	if info.ContentType != "" {
+		w.Header().Set("Content-Type", info.ContentType)
+	}
+	// A strong ETag lets ServeContent honor If-Range, so resumed downloads never splice two versions of a file.
+	sha := book.SHA256
+	if sha == "" {
+		sha = info.SHA256
+	}
+	if sha != "" {
+		w.Header().Set("ETag", `"`+sha+`"`)
+		if sum, err := hex.DecodeString(sha); err == nil {
+			w.Header().Set("Repr-Digest", "sha-256=:"+base64.StdEncoding.EncodeToString(sum)+":")
+		}
+	} else if info.ETag != "" && !strings.HasPrefix(info.ETag, "W/") {
+		w.Header().Set("ETag", info.ETag)
+	}
+	w.Header().Set("Content-Disposition", service.ContentDisposition(utils.RenderFilename(h.FilenameTemplate, book)))
+	http.ServeContent(w, r, "", info.LastModified, obj)
 }
 
 func (h *BooksHandler) Delete(w http.ResponseWriter, r *http.Request) {
@@ -206,27 +580,63 @@ func (h *BooksHandler) Delete(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, `{"error":"invalid book id"}`, http.StatusBadRequest)
 		return
 	}
+	book, _ := h.DB.BookByID(r.Context(), id) // for the book.deleted event
 	s3Key, coverS3Key, err := h.DB.DeleteBook(r.Context(), id)
 	if err != nil {
 		http.Error(w, `{"error":"book not found"}`, http.StatusNotFound)
 		return
 	}
-	if h.S3 != nil {
+	if h.Storage != nil {
 		if s3Key != "" {
-			_ = h.S3.Delete(r.Context(), s3Key)
+			_ = h.Storage.Delete(r.Context(), s3Key)
 		}
 		if coverS3Key != "" {
-			_ = h.S3.Delete(r.Context(), coverS3Key)
+			_ = h.Storage.Delete(r.Context(), coverS3Key)
 		}
+		_ = h.Storage.Delete(r.Context(), optimizedKey(id, h.OptimizeMaxImagePx))
+	}
+	if h.Search != nil {
+		h.Search.Remove(id)
+	}
+	if _, err := h.DB.DeleteBookPurchase(r.Context(), id); err != nil {
+		log.Printf("book %s: delete purchase record: %v", id.Hex(), err)
+	}
+	if err := h.DB.RemoveBookFromCollections(r.Context(), id); err != nil {
+		log.Printf("book %s: remove from collections: %v", id.Hex(), err)
+	}
+	h.deleteAudio(r.Context(), id)
+	if err := h.DB.DeleteBookEmbedding(r.Context(), id); err != nil {
+		log.Printf("book %s: delete embedding: %v", id.Hex(), err)
+	}
+	if h.Vectors != nil {
+		h.Vectors.Remove(id)
+	}
+	if book != nil {
+		emitEvent(h.Events, events.New(events.TypeBookDeleted, h.Clock.Now(), eventUserID(r.Context()), bookEvent(book)))
 	}
 	w.WriteHeader(http.StatusNoContent)
 }
 
 type RefreshMetadataRequest struct {
 	ISBN string `json:"isbn"`
+	DOI  string `json:"doi"` // bare, or as a doi: or https://doi.org/ link
+}
+
+// MetadataTimeoutResponse is RefreshMetadata's 504 body when the lookup did not finish in time, and its 503 body
+// when the metadata API has asked books to wait.
+type MetadataTimeoutResponse struct {
+	Error      string `json:"error"`
+	Code       string `json:"code"`       // METADATA_TIMEOUT, METADATA_RATE_LIMITED
+	RetryAfter int    `json:"retryAfter"` // seconds; also sent as Retry-After
 }
 
+// metadataRetryAfter is the wait suggested after a metadata lookup times out.
+const metadataRetryAfter = 30 * time.Second
+
 // RefreshMetadata refetches book metadata by ISBN and updates the book. If body.isbn is provided, uses it (overwrites book ISBN); otherwise uses book's current ISBN.
+// Documents are refreshed by DOI instead: body.doi, else the book's DOI, when Documents is set.
+// 504 (MetadataTimeoutResponse) when the lookup outlasts RefreshTimeout or the provider's own timeout; 503 with its
+// Retry-After while the provider is rate limiting books; 413 for an oversized body.
 func (h *BooksHandler) RefreshMetadata(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost && r.Method != http.MethodPatch {
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
@@ -248,21 +658,211 @@ func (h *BooksHandler) RefreshMetadata(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	var req RefreshMetadataRequest
-	_ = json.NewDecoder(r.Body).Decode(&req)
+	// The body is optional, so only an oversized one is an error.
+	var tooLarge *http.MaxBytesError
+	if err := json.NewDecoder(r.Body).Decode(&req); errors.As(err, &tooLarge) {
+		http.Error(w, `{"error":"request body too large"}`, http.StatusRequestEntityTooLarge)
+		return
+	}
 	isbn := strings.ReplaceAll(strings.TrimSpace(req.ISBN), "-", "")
-	if isbn == "" {
-		isbn = strings.ReplaceAll(strings.TrimSpace(book.ISBN), "-", "")
+	doi := normalizeDOI(req.DOI)
+	if isbn == "" && doi == "" {
+		if h.Documents != nil {
+			doi = book.DOI
+		}
+		if doi == "" {
+			isbn = strings.ReplaceAll(strings.TrimSpace(book.ISBN), "-", "")
+		}
+	}
+	if doi != "" && h.Documents == nil {
+		http.Error(w, `{"error":"DOI lookups are not enabled"}`, http.StatusBadRequest)
+		return
 	}
-	if isbn == "" {
+	if isbn == "" && doi == "" {
 		http.Error(w, `{"error":"no ISBN provided and book has no ISBN"}`, http.StatusBadRequest)
 		return
 	}
-	meta, err := service.FetchMetadataByISBN(isbn)
+	ctx := r.Context()
+	if h.RefreshTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, h.RefreshTimeout)
+		defer cancel()
+	}
+	var meta *service.BookMetadata
+	if doi != "" {
+		meta, err = h.Documents.FetchByDOI(ctx, doi)
+	} else {
+		meta, err = h.Metadata.FetchByISBN(ctx, isbn)
+	}
+	// Not recorded as the book's metadata error: the lookup was not finished, or not tried.
+	if metadataUnavailable(w, err) {
+		return
+	}
 	if err != nil {
+		if err := h.DB.SetBookMetadataError(r.Context(), id, err.Error()); err != nil {
+			log.Printf("refresh-metadata: record error: %v", err)
+		}
 		http.Error(w, `{"error":"failed to fetch metadata: `+err.Error()+`"}`, http.StatusBadRequest)
 		return
 	}
+	book.MetadataError = ""
+	applyMetadata(book, meta)
+	if err := h.DB.UpdateBookMetadata(r.Context(), id, book); err != nil {
+		http.Error(w, `{"error":"failed to update book"}`, http.StatusInternalServerError)
+		return
+	}
+	book, _ = h.DB.BookByID(r.Context(), id)
+	if h.Search != nil && book != nil {
+		h.Search.PutMetadata(book)
+	}
+	if book != nil {
+		setContentRating(book)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(book)
+}
+
+// metadataUnavailable answers a lookup that timed out (504) or was put off by the provider's rate limit (503) with
+// a MetadataTimeoutResponse, and reports whether it did.
+func metadataUnavailable(w http.ResponseWriter, err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		retryAfter := int(metadataRetryAfter.Seconds())
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+		w.WriteHeader(http.StatusGatewayTimeout)
+		json.NewEncoder(w).Encode(MetadataTimeoutResponse{Error: "metadata lookup timed out; try again later", Code: "METADATA_TIMEOUT", RetryAfter: retryAfter})
+		return true
+	}
+	var limited *service.RetryAfterError
+	if errors.As(err, &limited) {
+		retryAfter := max(int(math.Ceil(time.Until(limited.Until).Seconds())), 1)
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(MetadataTimeoutResponse{Error: "the metadata service asked us to wait; try again later", Code: "METADATA_RATE_LIMITED", RetryAfter: retryAfter})
+		return true
+	}
+	return false
+}
+
+// SearchMetadataRequest is the optional body of POST /api/books/{id}/search-metadata.
+type SearchMetadataRequest struct {
+	Title  string `json:"title"`  // default: the book's title
+	Author string `json:"author"` // default: the book's first author
+}
+
+// SearchMetadataResponse lists the books a metadata search found, best match first.
+type SearchMetadataResponse struct {
+	Candidates []LookupResponse `json:"candidates"`
+}
+
+// metadataSearchCandidates is how many candidates SearchMetadata returns at most.
+const metadataSearchCandidates = 10
+
+// SearchMetadata searches the metadata provider by title and author, for books without an ISBN (or with a wrong
+// one), and returns the candidates that have an ISBN; the user picks one by POSTing its ISBN to refresh-metadata.
+// The book is not changed. 503 when the provider can't search by title; 504 and 503 (MetadataTimeoutResponse) as
+// for RefreshMetadata. POST /api/books/{id}/search-metadata (admin, editor).
+func (h *BooksHandler) SearchMetadata(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if _, ok := middleware.UserIDFromContext(r.Context()); !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+	id, err := primitive.ObjectIDFromHex(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, `{"error":"invalid book id"}`, http.StatusBadRequest)
+		return
+	}
+	book, err := h.DB.BookByID(r.Context(), id)
+	if err != nil {
+		http.Error(w, `{"error":"book not found"}`, http.StatusNotFound)
+		return
+	}
+	var req SearchMetadataRequest
+	// The body is optional, so only an oversized one is an error.
+	var tooLarge *http.MaxBytesError
+	if err := json.NewDecoder(r.Body).Decode(&req); errors.As(err, &tooLarge) {
+		http.Error(w, `{"error":"request body too large"}`, http.StatusRequestEntityTooLarge)
+		return
+	}
+	search, ok := service.TitleSearcherOf(h.Metadata)
+	if !ok {
+		http.Error(w, `{"error":"the metadata provider can't search by title"}`, http.StatusServiceUnavailable)
+		return
+	}
+	title, author := strings.TrimSpace(req.Title), strings.TrimSpace(req.Author)
+	if title == "" {
+		title = book.Title
+		if author == "" && len(book.Authors) > 0 {
+			author = book.Authors[0]
+		}
+	}
+	if strings.TrimSpace(title) == "" {
+		http.Error(w, `{"error":"title is required"}`, http.StatusBadRequest)
+		return
+	}
+	ctx := r.Context()
+	if h.RefreshTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, h.RefreshTimeout)
+		defer cancel()
+	}
+	found, err := search.SearchByTitle(ctx, title, author, metadataSearchCandidates)
+	if metadataUnavailable(w, err) {
+		return
+	}
+	if err != nil {
+		log.Printf("search-metadata %s: %v", id.Hex(), err)
+		http.Error(w, `{"error":"metadata search failed"}`, http.StatusBadGateway)
+		return
+	}
+	res := SearchMetadataResponse{Candidates: []LookupResponse{}}
+	for _, m := range found {
+		if m.ISBN == "" {
+			continue
+		}
+		res.Candidates = append(res.Candidates, LookupResponse{
+			ISBN:         m.ISBN,
+			Title:        m.Title,
+			Authors:      m.Authors,
+			Publisher:    m.Publisher,
+			PublishDate:  m.PublishDate,
+			PageCount:    m.PageCount,
+			CoverURL:     m.CoverURL,
+			ThumbnailURL: m.ThumbnailURL,
+		})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(res)
+}
+
+// normalizeDOI strips the doi: or https://doi.org/ prefix DOIs are often written with.
+func normalizeDOI(s string) string {
+	s = strings.TrimSpace(s)
+	for _, prefix := range []string{"https://doi.org/", "http://doi.org/", "https://dx.doi.org/", "http://dx.doi.org/", "doi:"} {
+		if len(s) >= len(prefix) && strings.EqualFold(s[:len(prefix)], prefix) {
+			return strings.TrimSpace(s[len(prefix):])
+		}
+	}
+	return s
+}
+
+// applyMetadata copies looked-up metadata onto book, keeping its title when the lookup has none and its DOI when
+// the lookup was by ISBN.
+func applyMetadata(book *models.Book, meta *service.BookMetadata) {
 	book.ISBN = meta.ISBN
+	if meta.DOI != "" {
+		book.DOI = meta.DOI
+	}
+	book.Journal = meta.Journal
+	book.Kind = ""
+	if meta.Document {
+		book.Kind = models.BookKindDocument
+	}
 	if meta.Title != "" {
 		book.Title = meta.Title
 	}
@@ -278,13 +878,6 @@ func (h *BooksHandler) RefreshMetadata(w http.ResponseWriter, r *http.Request) {
 	book.Categories = meta.Categories
 	book.RatingAverage = meta.RatingAverage
 	book.RatingCount = meta.RatingCount
-	if err := h.DB.UpdateBookMetadata(r.Context(), id, book); err != nil {
-		http.Error(w, `{"error":"failed to update book"}`, http.StatusInternalServerError)
-		return
-	}
-	book, _ = h.DB.BookByID(r.Context(), id)
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(book)
 }
 
 type PatchViewByGuestRequest struct {
@@ -318,104 +911,54 @@ func (h *BooksHandler) PatchViewByGuest(w http.ResponseWriter, r *http.Request)
 	}
 	book, _ := h.DB.BookByID(r.Context(), id)
 	setCoverURLIfExtracted(book)
+	setContentRating(book)
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(book)
 }
 
-// SendToKindleResponse is returned on 400 when Kindle config is not set up.
-type SendToKindleErrorResponse struct {
-	Error string `json:"error"`
-	Code  string `json:"code"`
+type PatchContentRatingRequest struct {
+	ContentRating string `json:"contentRating"` // one of models.ContentRatings; "" infers it from the categories
 }
 
-// SendToKindle sends the book file to the user's Kindle email using their Kindle config (iCloud SMTP).
-func (h *BooksHandler) SendToKindle(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
+// PatchContentRating sets a book's content rating (admin, editor). PATCH /api/books/:id/content-rating
+func (h *BooksHandler) PatchContentRating(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPatch && r.Method != http.MethodPut {
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
-	userID, ok := middleware.UserIDFromContext(r.Context())
-	if !ok {
+	if _, ok := middleware.UserIDFromContext(r.Context()); !ok {
 		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
 		return
 	}
-	idStr := chi.URLParam(r, "id")
-	id, err := primitive.ObjectIDFromHex(idStr)
+	id, err := primitive.ObjectIDFromHex(chi.URLParam(r, "id"))
 	if err != nil {
 		http.Error(w, `{"error":"invalid book id"}`, http.StatusBadRequest)
 		return
 	}
-	book, err := h.DB.BookByID(r.Context(), id)
-	if err != nil {
-		http.Error(w, `{"error":"book not found"}`, http.StatusNotFound)
-		return
-	}
-	role := middleware.RoleFromContext(r.Context())
-	if role == models.RoleGuest && !book.ViewByGuest {
-		http.Error(w, `{"error":"book not found"}`, http.StatusNotFound)
+	var req PatchContentRatingRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error":"invalid json"}`, http.StatusBadRequest)
 		return
 	}
-	cfg, err := h.DB.GetEmailConfig(r.Context(), userID)
-	if err != nil {
-		http.Error(w, `{"error":"failed to load Kindle config"}`, http.StatusInternalServerError)
+	if req.ContentRating != "" && !models.ValidContentRating(req.ContentRating) {
+		http.Error(w, `{"error":"contentRating must be one of: `+strings.Join(models.ContentRatings, ", ")+`"}`, http.StatusBadRequest)
 		return
 	}
-	if cfg == nil || cfg.KindleMail == "" || cfg.SenderMail == "" || cfg.AppSpecificPassword == "" || cfg.ICloudMail == "" {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(SendToKindleErrorResponse{
-			Error: "Kindle config required. Set up your Kindle email in Kindle setup.",
-			Code:  "KINDLE_CONFIG_REQUIRED",
-		})
+	if _, err := h.DB.BookByID(r.Context(), id); err != nil {
+		http.Error(w, `{"error":"book not found"}`, http.StatusNotFound)
 		return
 	}
-	appPassword := cfg.AppSpecificPassword
-	if len(h.EncKey) == 32 && appPassword != "" {
-		dec, err := utils.Decrypt(appPassword, h.EncKey)
-		if err != nil {
-			log.Printf("send-to-kindle: decrypt app password: %v", err)
-			http.Error(w, `{"error":"failed to use Kindle config"}`, http.StatusInternalServerError)
-			return
-		}
-		appPassword = dec
-	}
-	if h.S3 == nil {
-		http.Error(w, `{"error":"download not configured"}`, http.StatusServiceUnavailable)
+	if err := h.DB.SetBookContentRating(r.Context(), id, req.ContentRating); err != nil {
+		http.Error(w, `{"error":"failed to update book"}`, http.StatusInternalServerError)
 		return
 	}
-	body, _, err := h.S3.GetObject(r.Context(), book.S3Key)
+	book, err := h.DB.BookByID(r.Context(), id)
 	if err != nil {
-		http.Error(w, `{"error":"failed to load book file"}`, http.StatusInternalServerError)
-		return
-	}
-	defer body.Close()
-
-	m := mail.NewMessage()
-	m.SetHeader("From", cfg.SenderMail)
-	m.SetHeader("To", cfg.KindleMail)
-	m.SetHeader("Subject", book.Title)
-	m.SetBody("text/plain", "Sent from Books. Attachment: "+book.OriginalName)
-	m.AttachReader(book.OriginalName, body)
-
-	d := mail.NewDialer(iCloudSMTPHost, iCloudSMTPPort, cfg.ICloudMail, appPassword)
-	d.StartTLSPolicy = mail.MandatoryStartTLS
-	if err := d.DialAndSend(m); err != nil {
-		log.Printf("send-to-kindle: %v", err)
-		http.Error(w, `{"error":"failed to send to Kindle: `+err.Error()+`"}`, http.StatusInternalServerError)
+		http.Error(w, `{"error":"book not found"}`, http.StatusNotFound)
 		return
 	}
-	emailLog := &models.EmailLog{
-		BookID:    id,
-		FileTitle: book.Title,
-		ToEmail:   cfg.KindleMail,
-		UserID:    userID,
-		UserEmail: middleware.EmailFromContext(r.Context()),
-		SentAt:    time.Now(),
-	}
-	if err := h.DB.InsertEmailLog(r.Context(), emailLog); err != nil {
-		log.Printf("send-to-kindle: failed to insert email log: %v", err)
-	}
+	setCoverURLIfExtracted(book)
+	setContentRating(book)
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]string{"message": "Sent to Kindle", "kindleMail": cfg.KindleMail})
+	json.NewEncoder(w).Encode(book)
 }
@@ -0,0 +1,218 @@
+package handlers
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/kevinaaaquil/books/backend/middleware"
+	"github.com/kevinaaaquil/books/backend/models"
+	"github.com/kevinaaaquil/books/backend/service"
+	"github.com/kevinaaaquil/books/backend/utils"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// BundleResponse is DownloadBundle's answer: a signed link to the collection's ZIP.
+type BundleResponse struct {
+	URL     string   `json:"url"`
+	Books   int      `json:"books"`
+	Bytes   int64    `json:"bytes"`             // total size of the files; the ZIP is slightly larger
+	Skipped []string `json:"skipped,omitempty"` // titles left out because their files are archived and must be restored first
+}
+
+func bundlePath(name string, userID primitive.ObjectID) string {
+	return "/api/collections/" + url.PathEscape(name) + "/bundle/" + userID.Hex()
+}
+
+// collectionParam is the collection name in the URL, which may be escaped.
+func collectionParam(r *http.Request) string {
+	name := chi.URLParam(r, "id")
+	if n, err := url.PathUnescape(name); err == nil {
+		name = n
+	}
+	return name
+}
+
+// DownloadBundle downloads a collection ("all", "shelf:<shelf>", "tag:<tag>"; see collectionBooks) as one ZIP of
+// its books' files, for moving a shelf onto a new device in one go. It answers a BundleResponse with a signed link
+// (see BundleFile), or with ?mode=stream the ZIP itself. Collections over BundleMaxBytes get 413; archived files
+// that must be restored first are left out. POST /api/collections/:id/download
+func (h *BooksHandler) DownloadBundle(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+	if h.BundleMaxBytes <= 0 {
+		http.Error(w, `{"error":"download bundles are turned off"}`, http.StatusNotFound)
+		return
+	}
+	if h.Storage == nil {
+		http.Error(w, `{"error":"download not configured"}`, http.StatusServiceUnavailable)
+		return
+	}
+	name := collectionParam(r)
+	title, books, skipped, total, ok := h.bundleBooks(w, r.Context(), userID, name)
+	if !ok {
+		return
+	}
+	role := middleware.RoleFromContext(r.Context())
+	expiry := h.DownloadURLExpiry[role]
+	if expiry <= 0 {
+		expiry = downloadURLExpiry
+	}
+	now := h.Clock.Now()
+	for _, b := range books {
+		link := &models.DownloadLink{
+			BookID:    b.ID,
+			UserID:    userID,
+			Email:     middleware.EmailFromContext(r.Context()),
+			Role:      role,
+			Kind:      models.DownloadLinkBundle,
+			IP:        clientIP(r),
+			IssuedAt:  now,
+			ExpiresAt: now.Add(expiry),
+			Bytes:     b.SizeBytes,
+		}
+		if err := h.recordDownloadLink(r.Context(), link); err != nil {
+			http.Error(w, `{"error":"failed to record download link"}`, http.StatusInternalServerError)
+			return
+		}
+	}
+	if r.URL.Query().Get("mode") == "stream" {
+		h.streamBundle(w, r, title, books)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(BundleResponse{URL: h.Signer.Sign(bundlePath(name, userID), expiry), Books: len(books), Bytes: total, Skipped: skipped})
+}
+
+// BundleFile streams a collection's ZIP for a signed URL issued by DownloadBundle, as the collection is now.
+// GET /api/collections/:id/bundle/:user?expires=...&signature=... (public; the signature stands in for auth).
+func (h *BooksHandler) BundleFile(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	userID, err := primitive.ObjectIDFromHex(chi.URLParam(r, "user"))
+	if err != nil {
+		http.Error(w, `{"error":"invalid or expired link"}`, http.StatusForbidden)
+		return
+	}
+	name := collectionParam(r)
+	if h.Signer == nil || !h.Signer.Verify(bundlePath(name, userID), r.URL.Query()) {
+		http.Error(w, `{"error":"invalid or expired link"}`, http.StatusForbidden)
+		return
+	}
+	if h.BundleMaxBytes <= 0 || h.Storage == nil {
+		http.Error(w, `{"error":"download bundles are turned off"}`, http.StatusNotFound)
+		return
+	}
+	title, books, _, _, ok := h.bundleBooks(w, r.Context(), userID, name)
+	if !ok {
+		return
+	}
+	h.streamBundle(w, r, title, books)
+}
+
+// bundleBooks returns the collection's books that can be zipped now, the titles of those that can't, and their
+// total size, writing the error response when there are none or they are over BundleMaxBytes.
+func (h *BooksHandler) bundleBooks(w http.ResponseWriter, ctx context.Context, userID primitive.ObjectID, name string) (title string, books []models.Book, skipped []string, total int64, ok bool) {
+	title, all, err := h.collectionBooks(ctx, userID, name)
+	if errors.Is(err, errNoCollection) {
+		http.Error(w, `{"error":"collection not found"}`, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, `{"error":"failed to list books"}`, http.StatusInternalServerError)
+		return
+	}
+	for _, b := range all {
+		switch {
+		case b.S3Key == "":
+		case service.NeedsRestore(b.StorageClass):
+			skipped = append(skipped, b.Title)
+		default:
+			books = append(books, b)
+			total += b.SizeBytes
+		}
+	}
+	if len(books) == 0 {
+		http.Error(w, `{"error":"collection has no books to download"}`, http.StatusNotFound)
+		return
+	}
+	if total > h.BundleMaxBytes {
+		msg := fmt.Sprintf("collection is %d MB, over the %d MB bundle limit", (total+1<<20-1)>>20, h.BundleMaxBytes>>20)
+		http.Error(w, `{"error":"`+msg+`"}`, http.StatusRequestEntityTooLarge)
+		return
+	}
+	return title, books, skipped, total, true
+}
+
+// bundleFilename names the ZIP of the collection titled title.
+func bundleFilename(title string) string {
+	return strings.Map(func(r rune) rune {
+		if r < ' ' || strings.ContainsRune(`/\?%*:|"<>`, r) {
+			return '_'
+		}
+		return r
+	}, title) + ".zip"
+}
+
+// streamBundle writes a ZIP of the books' files as it reads them from storage. Files are stored uncompressed
+// (EPUBs and PDFs are compressed already) and named by FilenameTemplate. Once the ZIP has started, a file that
+// can't be read is left out, and the ZIP is cut short if it grows past BundleMaxBytes.
+func (h *BooksHandler) streamBundle(w http.ResponseWriter, r *http.Request, title string, books []models.Book) {
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", service.ContentDisposition(bundleFilename(title)))
+	w.Header().Set("Cache-Control", "no-store")
+	zw := zip.NewWriter(w)
+	names := map[string]bool{}
+	var written int64
+	for i := range books {
+		b := &books[i]
+		obj, err := h.Storage.OpenObject(r.Context(), b.S3Key)
+		if err != nil {
+			log.Printf("bundle %q: book %s: %v", title, b.ID.Hex(), err)
+			continue
+		}
+		name := utils.RenderFilename(h.FilenameTemplate, b)
+		ext := path.Ext(name)
+		for n := 2; names[strings.ToLower(name)]; n++ {
+			name = strings.TrimSuffix(utils.RenderFilename(h.FilenameTemplate, b), ext) + " (" + strconv.Itoa(n) + ")" + ext
+		}
+		names[strings.ToLower(name)] = true
+		fw, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Store, Modified: b.CreatedAt})
+		if err == nil {
+			var n int64
+			n, err = io.Copy(fw, io.LimitReader(obj, h.BundleMaxBytes-written+1))
+			written += n
+		}
+		obj.Close()
+		if err != nil {
+			log.Printf("bundle %q: %v", title, err)
+			return
+		}
+		if written > h.BundleMaxBytes {
+			log.Printf("bundle %q: over %d bytes; cut short", title, h.BundleMaxBytes)
+			return
+		}
+	}
+	if err := zw.Close(); err != nil {
+		log.Printf("bundle %q: %v", title, err)
+	}
+}
@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+)
+
+// Capabilities are the features this server has configured, so clients can hide what is unavailable.
+type Capabilities struct {
+	Upload                    bool     `json:"upload"`        // storage is configured
+	UploadFormats             []string `json:"uploadFormats"` // file formats accepted by POST /api/upload
+	MaxUploadMB               int64    `json:"maxUploadMB"`
+	Search                    bool     `json:"search"`              // GET /api/books?q=
+	Previews                  bool     `json:"previews"`            // GET /api/books/{id}/preview
+	Conversion                bool     `json:"conversion"`          // books are converted for devices that don't take the stored format
+	ReadAloud                 bool     `json:"readAloud"`           // POST /api/books/{id}/audio reads EPUBs aloud
+	CategorySuggestions       bool     `json:"categorySuggestions"` // books without categories get suggestions to approve
+	SemanticSearch            bool     `json:"semanticSearch"`      // GET /api/search/semantic?q=
+	Assistant                 bool     `json:"assistant"`           // POST /api/ask
+	Drives                    []string `json:"drives"`              // drive kinds delivery targets can link
+	RequireKindleVerification bool     `json:"requireKindleVerification"`
+	Lookup                    bool     `json:"lookup"`            // GET /api/lookup?isbn= answers without sign-in
+	PriceWatch                bool     `json:"priceWatch"`        // PUT /api/wishlist/{id}/price-watch
+	ReadOnly                  bool     `json:"readOnly"`          // a read-only replica: everything but reads and sign-in is refused
+	GuestLoginPoWBits         int      `json:"guestLoginPowBits"` // guest login needs a solved GET /api/auth/guest/challenge; 0 = no
+}
+
+// Enabled lists the optional features turned on, by their JSON names, for telemetry.
+func (c Capabilities) Enabled() []string {
+	var fields map[string]any
+	b, _ := json.Marshal(c)
+	json.Unmarshal(b, &fields)
+	features := []string{}
+	for name, v := range fields {
+		switch v := v.(type) {
+		case bool:
+			if v {
+				features = append(features, name)
+			}
+		case []any:
+			if len(v) > 0 && name != "uploadFormats" {
+				features = append(features, name)
+			}
+		}
+	}
+	slices.Sort(features)
+	return features
+}
+
+// CapabilitiesHandler serves the server's Capabilities.
+type CapabilitiesHandler struct {
+	Capabilities Capabilities
+}
+
+// Get returns the server's capabilities. GET /api/capabilities (public).
+func (h *CapabilitiesHandler) Get(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.Capabilities)
+}
@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/kevinaaaquil/books/backend/jobs"
+	"github.com/kevinaaaquil/books/backend/middleware"
+	"github.com/kevinaaaquil/books/backend/models"
+	"github.com/kevinaaaquil/books/backend/service"
+	"github.com/kevinaaaquil/books/backend/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// JobClassify is the job queue kind that suggests categories for a new book without any (see RunClassify).
+const JobClassify = "classify"
+
+// ApproveCategoriesRequest is the optional body of ApproveCategories: the categories to keep, when not all of the
+// suggestions (or others, for an editor correcting them).
+type ApproveCategoriesRequest struct {
+	Categories []string `json:"categories"`
+}
+
+// categoryBook loads the book named in the URL, writing the error response when there is none or no classifier.
+func (h *BooksHandler) categoryBook(w http.ResponseWriter, r *http.Request) (*models.Book, bool) {
+	if _, ok := middleware.UserIDFromContext(r.Context()); !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return nil, false
+	}
+	id, err := primitive.ObjectIDFromHex(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, `{"error":"invalid book id"}`, http.StatusBadRequest)
+		return nil, false
+	}
+	book, err := h.DB.BookByID(r.Context(), id)
+	if err != nil {
+		http.Error(w, `{"error":"book not found"}`, http.StatusNotFound)
+		return nil, false
+	}
+	return book, true
+}
+
+// SuggestCategories asks the classifier for categories for the book now, and saves them as its suggestions. Books
+// that have categories get suggestions too, to compare. POST /api/books/:id/categories/suggest (admin, editor).
+// 404 when no classifier is configured (CLASSIFIER).
+func (h *BooksHandler) SuggestCategories(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	book, ok := h.categoryBook(w, r)
+	if !ok {
+		return
+	}
+	if h.Classifier == nil {
+		http.Error(w, `{"error":"category suggestions are not enabled"}`, http.StatusNotFound)
+		return
+	}
+	suggested, err := jobs.SuggestCategories(r.Context(), h.DB, h.Storage, h.Classifier, book)
+	if err != nil {
+		log.Printf("suggest categories %s: %v", book.ID.Hex(), err)
+		http.Error(w, `{"error":"failed to suggest categories"}`, http.StatusBadGateway)
+		return
+	}
+	book.SuggestedCategories = suggested
+	setCoverURLIfExtracted(book)
+	setContentRating(book)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(book)
+}
+
+// ApproveCategories makes the book's suggested categories (or those in the body) its categories, and clears the
+// suggestions. POST /api/books/:id/categories/approve (admin, editor).
+func (h *BooksHandler) ApproveCategories(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	book, ok := h.categoryBook(w, r)
+	if !ok {
+		return
+	}
+	var req ApproveCategoriesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, `{"error":"invalid json"}`, http.StatusBadRequest)
+		return
+	}
+	categories := book.SuggestedCategories
+	if req.Categories != nil {
+		categories = nil
+		for _, c := range req.Categories {
+			if c = strings.TrimSpace(c); c != "" && !slices.Contains(categories, c) {
+				categories = append(categories, c)
+			}
+		}
+	}
+	if len(categories) == 0 {
+		http.Error(w, `{"error":"no categories to approve"}`, http.StatusBadRequest)
+		return
+	}
+	book.Categories = categories
+	if book.Category == "" {
+		book.Category = categories[0]
+	}
+	if err := h.DB.UpdateBookMetadata(r.Context(), book.ID, book); err != nil {
+		http.Error(w, `{"error":"failed to update book"}`, http.StatusInternalServerError)
+		return
+	}
+	if err := h.DB.SetBookSuggestedCategories(r.Context(), book.ID, nil); err != nil {
+		http.Error(w, `{"error":"failed to update book"}`, http.StatusInternalServerError)
+		return
+	}
+	book.SuggestedCategories = nil
+	if h.Search != nil {
+		h.Search.PutMetadata(book)
+	}
+	setCoverURLIfExtracted(book)
+	setContentRating(book)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(book)
+}
+
+// DismissCategories throws away the book's suggested categories. DELETE /api/books/:id/categories/suggestions
+// (admin, editor).
+func (h *BooksHandler) DismissCategories(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	book, ok := h.categoryBook(w, r)
+	if !ok {
+		return
+	}
+	if err := h.DB.SetBookSuggestedCategories(r.Context(), book.ID, nil); err != nil {
+		http.Error(w, `{"error":"failed to update book"}`, http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RunClassify is the JobClassify handler: it suggests categories for the book in the "bookId" param, unless it
+// has categories by now (from a metadata refresh, say).
+func (h *BooksHandler) RunClassify(ctx context.Context, job *models.QueuedJob) (map[string]string, error) {
+	bookID, err := primitive.ObjectIDFromHex(job.Params["bookId"])
+	if err != nil {
+		return nil, jobs.Permanent(fmt.Errorf("invalid book id %q", job.Params["bookId"]))
+	}
+	if h.Classifier == nil {
+		return nil, jobs.Permanent(errors.New("category suggestions are not enabled"))
+	}
+	book, err := h.DB.BookByID(ctx, bookID)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, jobs.Permanent(errors.New("the book was deleted"))
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(book.Categories) > 0 {
+		return map[string]string{"bookId": bookID.Hex(), "skipped": "has categories"}, nil
+	}
+	suggested, err := jobs.SuggestCategories(ctx, h.DB, h.Storage, h.Classifier, book)
+	if service.IsPermanentClassifierError(err) {
+		return nil, jobs.Permanent(err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return map[string]string{"bookId": bookID.Hex(), "suggested": strings.Join(suggested, "; ")}, nil
+}
@@ -0,0 +1,316 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/kevinaaaquil/books/backend/middleware"
+	"github.com/kevinaaaquil/books/backend/models"
+	"github.com/kevinaaaquil/books/backend/service"
+	"github.com/kevinaaaquil/books/backend/store"
+)
+
+// ClipMaxNotes is the longest note a clip can carry, in bytes.
+const ClipMaxNotes = 2000
+
+// Kinds of ClipResponse.
+const (
+	ClipBook     = "book"
+	ClipWishlist = "wishlist"
+)
+
+// ClipHandler saves books from wherever the user found them, in one request: a browser extension on a storefront
+// page, a phone scanner, a script. It is authenticated with a bearer token or an API key with the clip scope.
+type ClipHandler struct {
+	DB            store.Store
+	Metadata      service.MetadataProvider
+	Clock         service.Clock
+	Client        *http.Client                                             // downloads ebook URLs; should refuse private addresses (see service.PublicHTTPClient)
+	Ingest        func(context.Context, IngestFile) (*IngestResult, error) // UploadHandler.Ingest
+	MaxBytes      int64                                                    // larger files are refused; 0 = no limit
+	LookupTimeout time.Duration                                            // for the metadata lookup; 0 = the provider's own
+}
+
+// ClipRequest needs a URL or an ISBN. Title is used when no metadata is found (extensions send the page title).
+type ClipRequest struct {
+	URL   string `json:"url"`
+	ISBN  string `json:"isbn"`
+	Title string `json:"title"`
+	Notes string `json:"notes"` // kept on wishlist items
+}
+
+type ClipResponse struct {
+	Kind string               `json:"kind"`           // ClipBook: the file is in the library; ClipWishlist: saved to the wishlist
+	Book *UploadResponse      `json:"book,omitempty"` // for ClipBook
+	Item *models.WishlistItem `json:"item,omitempty"` // for ClipWishlist
+	// Existing is true when nothing new was added: the same file is already in the library, or the book is
+	// already on the wishlist (its notes are updated).
+	Existing bool `json:"existing,omitempty"`
+}
+
+// Clip adds what a URL or ISBN points to. A URL to an .epub or .pdf file is downloaded and added to the library
+// like an upload (editors and admins only); anything else becomes a metadata-only item on the user's wishlist,
+// with metadata looked up by the ISBN given or found in the URL (storefront URLs usually carry one). 201 when
+// something was added, 200 when it was already there. POST /api/clip
+func (h *ClipHandler) Clip(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+	var req ClipRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error":"invalid json"}`, http.StatusBadRequest)
+		return
+	}
+	req.URL, req.Title, req.Notes = strings.TrimSpace(req.URL), strings.TrimSpace(req.Title), strings.TrimSpace(req.Notes)
+	if len(req.Notes) > ClipMaxNotes {
+		http.Error(w, `{"error":"notes are too long"}`, http.StatusBadRequest)
+		return
+	}
+	var u *url.URL
+	if req.URL != "" {
+		var err error
+		u, err = url.Parse(req.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			http.Error(w, `{"error":"url must be an http or https URL"}`, http.StatusBadRequest)
+			return
+		}
+	}
+	isbn := ""
+	if req.ISBN != "" {
+		if isbn = normalizeISBN(req.ISBN); isbn == "" {
+			http.Error(w, `{"error":"isbn must be an ISBN-10 or ISBN-13"}`, http.StatusBadRequest)
+			return
+		}
+	}
+	switch {
+	case u != nil && models.IsBookFile(u.Path):
+		h.clipFile(w, r, u)
+	case u != nil || isbn != "":
+		if isbn == "" {
+			isbn = isbnInURL(req.URL)
+		}
+		h.clipWishlist(w, r, &models.WishlistItem{UserID: userID, ISBN: isbn, Title: req.Title, SourceURL: req.URL, Notes: req.Notes})
+	default:
+		http.Error(w, `{"error":"url or isbn is required"}`, http.StatusBadRequest)
+	}
+}
+
+// clipFile downloads an ebook and adds it to the library, unless a book with the same content is already there.
+func (h *ClipHandler) clipFile(w http.ResponseWriter, r *http.Request, u *url.URL) {
+	if role := middleware.RoleFromContext(r.Context()); role != models.RoleAdmin && role != models.RoleEditor {
+		http.Error(w, `{"error":"only editors and admins can add book files"}`, http.StatusForbidden)
+		return
+	}
+	name, contentType, data, err := h.download(r.Context(), u)
+	var tooLarge *fileTooLargeError
+	switch {
+	case errors.Is(err, service.ErrNonPublicAddress):
+		http.Error(w, `{"error":"url must point to a public address"}`, http.StatusBadRequest)
+		return
+	case errors.As(err, &tooLarge):
+		http.Error(w, `{"error":"file is too large"}`, http.StatusRequestEntityTooLarge)
+		return
+	case err != nil:
+		log.Printf("clip %s: %v", u.Redacted(), err)
+		http.Error(w, `{"error":"could not download the file"}`, http.StatusBadGateway)
+		return
+	}
+	sum := sha256.Sum256(data)
+	existing, err := h.DB.BookBySHA256(r.Context(), hex.EncodeToString(sum[:]))
+	if err != nil {
+		http.Error(w, `{"error":"failed to check for duplicates"}`, http.StatusInternalServerError)
+		return
+	}
+	if existing != nil {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(ClipResponse{Kind: ClipBook, Book: &UploadResponse{ID: existing.ID.Hex(), Title: existing.Title}, Existing: true})
+		return
+	}
+	res, err := h.Ingest(r.Context(), IngestFile{
+		Name:        name,
+		ContentType: contentType,
+		Data:        data,
+		UploadedBy:  middleware.EmailFromContext(r.Context()),
+		Source:      models.SourceURL,
+		SourceURL:   u.Redacted(),
+	})
+	switch {
+	case errors.Is(err, ErrUnsupportedFormat):
+		http.Error(w, `{"error":"only epub, pdf, mobi and azw3 are allowed"}`, http.StatusBadRequest)
+		return
+	case errors.Is(err, errStoreFile):
+		http.Error(w, `{"error":"failed to upload to storage"}`, http.StatusInternalServerError)
+		return
+	case err != nil:
+		http.Error(w, `{"error":"failed to save book record"}`, http.StatusInternalServerError)
+		return
+	}
+	book := uploadResponse(res)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(ClipResponse{Kind: ClipBook, Book: &book})
+}
+
+type fileTooLargeError struct{ limit int64 }
+
+func (e *fileTooLargeError) Error() string { return fmt.Sprintf("file is over %d bytes", e.limit) }
+
+// download fetches u, returning the file's name (from Content-Disposition, else the URL) and content type.
+func (h *ClipHandler) download(ctx context.Context, u *url.URL) (name, contentType string, data []byte, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return "", "", nil, err
+	}
+	resp, err := h.Client.Do(req)
+	if err != nil {
+		return "", "", nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", "", nil, fmt.Errorf("server returned %d", resp.StatusCode)
+	}
+	if h.MaxBytes > 0 && resp.ContentLength > h.MaxBytes {
+		return "", "", nil, &fileTooLargeError{h.MaxBytes}
+	}
+	var body io.Reader = resp.Body
+	if h.MaxBytes > 0 {
+		body = io.LimitReader(resp.Body, h.MaxBytes+1)
+	}
+	if data, err = io.ReadAll(body); err != nil {
+		return "", "", nil, err
+	}
+	if h.MaxBytes > 0 && int64(len(data)) > h.MaxBytes {
+		return "", "", nil, &fileTooLargeError{h.MaxBytes}
+	}
+	name = path.Base(resp.Request.URL.Path) // after redirects
+	if !models.IsBookFile(name) {
+		name = path.Base(u.Path)
+	}
+	if _, params, err := mime.ParseMediaType(resp.Header.Get("Content-Disposition")); err == nil && models.IsBookFile(params["filename"]) {
+		name = path.Base(params["filename"])
+	}
+	return name, resp.Header.Get("Content-Type"), data, nil
+}
+
+// clipWishlist saves item to the user's wishlist with metadata looked up by its ISBN, or updates the notes of the
+// item already there for the same ISBN or page.
+func (h *ClipHandler) clipWishlist(w http.ResponseWriter, r *http.Request, item *models.WishlistItem) {
+	items, err := h.DB.WishlistForUser(r.Context(), item.UserID)
+	if err != nil {
+		http.Error(w, `{"error":"failed to load wishlist"}`, http.StatusInternalServerError)
+		return
+	}
+	for _, existing := range items {
+		if (item.ISBN != "" && existing.ISBN == item.ISBN) || (item.ISBN == "" && existing.ISBN == "" && existing.SourceURL == item.SourceURL) {
+			if item.Notes != "" && item.Notes != existing.Notes {
+				existing.Notes = item.Notes
+				if _, err := h.DB.UpdateWishlistItem(r.Context(), &existing); err != nil {
+					http.Error(w, `{"error":"failed to save wishlist item"}`, http.StatusInternalServerError)
+					return
+				}
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(ClipResponse{Kind: ClipWishlist, Item: &existing, Existing: true})
+			return
+		}
+	}
+	if item.ISBN != "" {
+		h.applyLookup(r.Context(), item)
+	}
+	if item.Title == "" {
+		item.Title = item.SourceURL
+	}
+	if item.Title == "" {
+		item.Title = "ISBN " + item.ISBN
+	}
+	item.CreatedAt = h.Clock.Now()
+	if item.ID, err = h.DB.InsertWishlistItem(r.Context(), item); err != nil {
+		http.Error(w, `{"error":"failed to save wishlist item"}`, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(ClipResponse{Kind: ClipWishlist, Item: item})
+}
+
+// applyLookup fills item in from the metadata provider. The item is saved either way, so failures are only logged.
+func (h *ClipHandler) applyLookup(ctx context.Context, item *models.WishlistItem) {
+	if h.LookupTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, h.LookupTimeout)
+		defer cancel()
+	}
+	meta, err := h.Metadata.FetchByISBN(ctx, item.ISBN)
+	if err != nil {
+		if !errors.Is(err, service.ErrNoMetadata) {
+			log.Printf("clip: lookup %s: %v", item.ISBN, err)
+		}
+		return
+	}
+	if meta.Title != "" {
+		item.Title = meta.Title
+	}
+	item.Authors, item.Publisher, item.PublishDate = meta.Authors, meta.Publisher, meta.PublishDate
+	item.CoverURL, item.ThumbnailURL = meta.CoverURL, meta.ThumbnailURL
+}
+
+// isbnCandidate matches digit runs that could be an ISBN-13 or ISBN-10, once hyphens are removed.
+var isbnCandidate = regexp.MustCompile(`(?i)(?:^|[^0-9])(97[89][0-9]{10}|[0-9]{9}[0-9x])(?:[^0-9]|$)`)
+
+// isbnInURL returns the first ISBN with a valid check digit in a URL, preferring ISBN-13s, or "".
+func isbnInURL(raw string) string {
+	found := ""
+	for _, m := range isbnCandidate.FindAllStringSubmatch(strings.ReplaceAll(raw, "-", ""), -1) {
+		isbn := strings.ToUpper(m[1])
+		if len(isbn) == 13 && validISBN13(isbn) {
+			return isbn
+		}
+		if found == "" && len(isbn) == 10 && validISBN10(isbn) {
+			found = isbn
+		}
+	}
+	return found
+}
+
+func validISBN13(s string) bool {
+	sum := 0
+	for i, r := range s {
+		d := int(r - '0')
+		if i%2 == 1 {
+			d *= 3
+		}
+		sum += d
+	}
+	return sum%10 == 0
+}
+
+func validISBN10(s string) bool {
+	sum := 0
+	for i, r := range s {
+		d := int(r - '0')
+		if r == 'X' {
+			d = 10
+		}
+		sum += d * (10 - i)
+	}
+	return sum%11 == 0
+}
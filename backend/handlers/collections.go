@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+
+	"github.com/kevinaaaquil/books/backend/models"
+	"github.com/kevinaaaquil/books/backend/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Collections group books for OPDS feeds and download bundles: every book, one of the user's shelves (see
+// opdsShelves), the books with a tag or one of the user's own collections (see CollectionsHandler), named "all",
+// "shelf:<shelf>", "tag:<tag>" and "collection:<id>".
+const (
+	collectionAll      = "all"
+	collectionShelfPre = "shelf:"
+	collectionTagPre   = "tag:"
+	collectionUserPre  = "collection:"
+)
+
+// errNoCollection is returned by collectionBooks for names that are not a collection.
+var errNoCollection = errors.New("collection not found")
+
+// libraryBooks returns the books the user may see (within their maximum content rating), newest first.
+func libraryBooks(ctx context.Context, db store.Store, userID primitive.ObjectID) ([]models.Book, error) {
+	books, err := db.AllBooks(ctx)
+	if err != nil {
+		return nil, err
+	}
+	user, err := db.UserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user != nil && user.MaxContentRating != "" {
+		books = slices.DeleteFunc(books, func(b models.Book) bool { return !contentRatingAllowed(user.MaxContentRating, &b) })
+	}
+	slices.SortStableFunc(books, func(a, b models.Book) int { return b.CreatedAt.Compare(a.CreatedAt) })
+	return books, nil
+}
+
+// collectionBooks returns the title and books of the named collection as the user sees it: shelves other than
+// to-read most recently read first, the user's own collections in the order books were added, the rest newest
+// first. Tags match case-insensitively.
+func (h *BooksHandler) collectionBooks(ctx context.Context, userID primitive.ObjectID, name string) (string, []models.Book, error) {
+	if id, ok := strings.CutPrefix(name, collectionUserPre); ok {
+		c, books, err := userCollectionBooks(ctx, h.DB, userID, id)
+		if err != nil {
+			return "", nil, err
+		}
+		return c.Name, books, nil
+	}
+	shelf, isShelf := strings.CutPrefix(name, collectionShelfPre)
+	tag, isTag := strings.CutPrefix(name, collectionTagPre)
+	i := slices.IndexFunc(opdsShelves, func(s struct{ Name, Title string }) bool { return s.Name == shelf })
+	switch {
+	case name == collectionAll, isShelf && i >= 0, isTag && strings.TrimSpace(tag) != "":
+	default:
+		return "", nil, errNoCollection
+	}
+	books, err := libraryBooks(ctx, h.DB, userID)
+	if err != nil {
+		return "", nil, err
+	}
+	if isTag {
+		books = slices.DeleteFunc(books, func(b models.Book) bool {
+			return !slices.ContainsFunc(bookTags(&b), func(c string) bool { return strings.EqualFold(c, tag) })
+		})
+		return tag, books, nil
+	}
+	if !isShelf {
+		return "All books", books, nil
+	}
+	progress, err := h.DB.AllReadingProgress(ctx)
+	if err != nil {
+		return "", nil, err
+	}
+	mine := map[primitive.ObjectID]models.ReadingProgress{}
+	for _, p := range progress {
+		if p.UserID == userID {
+			mine[p.BookID] = p
+		}
+	}
+	books = slices.DeleteFunc(books, func(b models.Book) bool {
+		p, started := mine[b.ID]
+		switch shelf {
+		case ShelfToRead:
+			return started
+		case ShelfReading:
+			return !started || p.Percent >= models.FinishedPercent
+		}
+		return !started || p.Percent < models.FinishedPercent
+	})
+	if shelf != ShelfToRead {
+		slices.SortStableFunc(books, func(a, b models.Book) int { return mine[b.ID].UpdatedAt.Compare(mine[a.ID].UpdatedAt) })
+	}
+	return opdsShelves[i].Title, books, nil
+}
+
+// userCollectionBooks returns one of the user's own collections, by hex ID, and the books on it the user may
+// see, in the order they were added.
+func userCollectionBooks(ctx context.Context, db store.Store, userID primitive.ObjectID, id string) (*models.Collection, []models.Book, error) {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, nil, errNoCollection
+	}
+	c, err := db.Collection(ctx, userID, oid)
+	if err != nil {
+		return nil, nil, err
+	}
+	if c == nil {
+		return nil, nil, errNoCollection
+	}
+	library, err := libraryBooks(ctx, db, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	byID := make(map[primitive.ObjectID]models.Book, len(library))
+	for _, b := range library {
+		byID[b.ID] = b
+	}
+	books := []models.Book{}
+	for _, id := range c.BookIDs {
+		if b, ok := byID[id]; ok {
+			books = append(books, b)
+		}
+	}
+	return c, books, nil
+}
+
+// bookTags are a book's categories, its main category first.
+func bookTags(b *models.Book) []string {
+	tags := slices.Clone(b.Categories)
+	if b.Category != "" && !slices.Contains(tags, b.Category) {
+		tags = append([]string{b.Category}, tags...)
+	}
+	return slices.DeleteFunc(tags, func(t string) bool { return strings.TrimSpace(t) == "" })
+}
@@ -0,0 +1,159 @@
+package handlers
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/kevinaaaquil/books/backend/service"
+)
+
+// maxInflatedContent is the largest compressed file inside an EPUB that Content serves. Compressed files are
+// inflated in memory (so they can be served with Range); media is usually stored uncompressed and streamed.
+const maxInflatedContent = 32 << 20
+
+// epubMediaTypes are the content types of files in EPUBs that mime.TypeByExtension may not know.
+var epubMediaTypes = map[string]string{
+	".xhtml": "application/xhtml+xml",
+	".xht":   "application/xhtml+xml",
+	".html":  "text/html; charset=utf-8",
+	".htm":   "text/html; charset=utf-8",
+	".opf":   "application/oebps-package+xml",
+	".ncx":   "application/x-dtbncx+xml",
+	".xml":   "application/xml",
+	".css":   "text/css; charset=utf-8",
+	".js":    "text/javascript; charset=utf-8",
+	".svg":   "image/svg+xml",
+	".smil":  "application/smil+xml",
+	".ttf":   "font/ttf",
+	".otf":   "font/otf",
+	".woff":  "font/woff",
+	".woff2": "font/woff2",
+	".mp3":   "audio/mpeg",
+	".m4a":   "audio/mp4",
+	".mp4":   "video/mp4",
+}
+
+// epubMediaType returns the content type of a file inside an EPUB, by its name.
+func epubMediaType(name string) string {
+	if path.Base(name) == "mimetype" {
+		return "text/plain; charset=utf-8"
+	}
+	ext := strings.ToLower(path.Ext(name))
+	if t, ok := epubMediaTypes[ext]; ok {
+		return t
+	}
+	if t := mime.TypeByExtension(ext); t != "" {
+		return t
+	}
+	return "application/octet-stream"
+}
+
+// Content serves one file from inside an EPUB (its container.xml, OPF, chapters, images, stylesheets and fonts),
+// for an in-browser reader such as epub.js opened at /api/books/:id/content/. Only the zip's directory and the
+// file asked for are read from storage. Names match case-insensitively when there is no exact match.
+// GET or HEAD /api/books/:id/content/*
+func (h *BooksHandler) Content(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	book, ok := h.visibleBook(w, r)
+	if !ok {
+		return
+	}
+	if h.Storage == nil || book.Format != "epub" {
+		http.Error(w, `{"error":"only EPUB books can be read in the browser"}`, http.StatusNotFound)
+		return
+	}
+	name := strings.TrimPrefix(path.Clean("/"+chi.URLParam(r, "*")), "/")
+	if name == "" {
+		http.Error(w, `{"error":"file name is required"}`, http.StatusNotFound)
+		return
+	}
+	if !h.restoreArchived(w, r, book) {
+		return
+	}
+	obj, err := h.Storage.OpenObject(r.Context(), book.S3Key)
+	if storageUnavailable(w, err) {
+		return
+	}
+	if errors.Is(err, service.ErrObjectNotFound) {
+		http.Error(w, `{"error":"book file missing from storage"}`, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, `{"error":"failed to load book file"}`, http.StatusInternalServerError)
+		return
+	}
+	defer obj.Close()
+	info := obj.Info()
+	zr, err := zip.NewReader(obj, info.Size)
+	if err != nil {
+		log.Printf("content %s: %v", book.ID.Hex(), err)
+		http.Error(w, `{"error":"book file is not a valid EPUB"}`, http.StatusUnprocessableEntity)
+		return
+	}
+	f := epubEntry(zr, name)
+	if f == nil || f.FileInfo().IsDir() {
+		http.Error(w, `{"error":"file not found in book"}`, http.StatusNotFound)
+		return
+	}
+	var content io.ReadSeeker
+	switch {
+	case f.Method == zip.Store:
+		offset, err := f.DataOffset()
+		if err != nil {
+			http.Error(w, `{"error":"failed to read file from book"}`, http.StatusInternalServerError)
+			return
+		}
+		content = io.NewSectionReader(obj, offset, int64(f.UncompressedSize64))
+	case f.UncompressedSize64 > maxInflatedContent:
+		http.Error(w, `{"error":"file is too large to serve"}`, http.StatusRequestEntityTooLarge)
+		return
+	default:
+		rc, err := f.Open()
+		if err != nil {
+			http.Error(w, `{"error":"failed to read file from book"}`, http.StatusInternalServerError)
+			return
+		}
+		data, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			log.Printf("content %s %s: %v", book.ID.Hex(), f.Name, err)
+			http.Error(w, `{"error":"failed to read file from book"}`, http.StatusInternalServerError)
+			return
+		}
+		content = bytes.NewReader(data)
+	}
+	w.Header().Set("Content-Type", epubMediaType(f.Name))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	// Readers load these with fetch; opened directly, a book's scripts must not run as this site.
+	w.Header().Set("Content-Security-Policy", "sandbox")
+	// Identifies this version of the file: a replaced book file gets a new ETag for files that changed.
+	w.Header().Set("ETag", fmt.Sprintf(`"%08x-%d"`, f.CRC32, f.UncompressedSize64))
+	http.ServeContent(w, r, "", info.LastModified, content)
+}
+
+// epubEntry returns the zip entry named name, matching case-insensitively (and with backslashes as slashes, as
+// some EPUB tools write them) when no entry has exactly that name.
+func epubEntry(zr *zip.Reader, name string) *zip.File {
+	var fold *zip.File
+	for _, f := range zr.File {
+		if f.Name == name {
+			return f
+		}
+		if fold == nil && strings.EqualFold(strings.ReplaceAll(f.Name, `\`, "/"), name) {
+			fold = f
+		}
+	}
+	return fold
+}
@@ -0,0 +1,217 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/kevinaaaquil/books/backend/middleware"
+	"github.com/kevinaaaquil/books/backend/models"
+	"github.com/kevinaaaquil/books/backend/service"
+	"github.com/kevinaaaquil/books/backend/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// DevicesHandler manages users' reading devices. Sending a book to a device (BooksHandler.Send) picks the
+// format from its profile, converting when needed, and delivers to its target or the Kindle address.
+type DevicesHandler struct {
+	DB    store.Store
+	Clock service.Clock
+}
+
+// DeviceRequest creates or replaces a device. Empty Formats and a nil MaxAttachmentMB take the type's defaults
+// (models.DefaultDeviceProfile); an empty TargetID sends to the Kindle address.
+type DeviceRequest struct {
+	Name            string   `json:"name"`
+	Type            string   `json:"type"`
+	Formats         []string `json:"formats"`
+	MaxAttachmentMB *int     `json:"maxAttachmentMB"`
+	TargetID        string   `json:"targetId"`
+	Optimize        bool     `json:"optimize"`
+}
+
+type DevicesResponse struct {
+	Devices []models.Device `json:"devices"`
+	Formats []string        `json:"formats"` // formats a device can ask for
+}
+
+// List returns the current user's devices. GET /api/devices
+func (h *DevicesHandler) List(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+	devices, err := h.DB.DevicesForUser(r.Context(), userID)
+	if err != nil {
+		http.Error(w, `{"error":"failed to list devices"}`, http.StatusInternalServerError)
+		return
+	}
+	if devices == nil {
+		devices = []models.Device{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(DevicesResponse{Devices: devices, Formats: models.DeviceFormats})
+}
+
+// Create adds a device. POST /api/devices
+func (h *DevicesHandler) Create(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+	d := &models.Device{UserID: userID, CreatedAt: h.Clock.Now()}
+	if !h.applyRequest(w, r, d) {
+		return
+	}
+	id, err := h.DB.InsertDevice(r.Context(), d)
+	if err != nil {
+		http.Error(w, `{"error":"failed to save device"}`, http.StatusInternalServerError)
+		return
+	}
+	d.ID = id
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(d)
+}
+
+// Update replaces a device's profile. PUT /api/devices/:id
+func (h *DevicesHandler) Update(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+	id, err := primitive.ObjectIDFromHex(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, `{"error":"invalid device id"}`, http.StatusBadRequest)
+		return
+	}
+	d, err := h.DB.Device(r.Context(), userID, id)
+	if err != nil {
+		http.Error(w, `{"error":"failed to load device"}`, http.StatusInternalServerError)
+		return
+	}
+	if d == nil {
+		http.Error(w, `{"error":"device not found"}`, http.StatusNotFound)
+		return
+	}
+	if !h.applyRequest(w, r, d) {
+		return
+	}
+	found, err := h.DB.UpdateDevice(r.Context(), d)
+	if err != nil {
+		http.Error(w, `{"error":"failed to save device"}`, http.StatusInternalServerError)
+		return
+	}
+	if !found {
+		http.Error(w, `{"error":"device not found"}`, http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(d)
+}
+
+// Delete removes a device. DELETE /api/devices/:id
+func (h *DevicesHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+	id, err := primitive.ObjectIDFromHex(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, `{"error":"invalid device id"}`, http.StatusBadRequest)
+		return
+	}
+	found, err := h.DB.DeleteDevice(r.Context(), userID, id)
+	if err != nil {
+		http.Error(w, `{"error":"failed to delete device"}`, http.StatusInternalServerError)
+		return
+	}
+	if !found {
+		http.Error(w, `{"error":"device not found"}`, http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// applyRequest decodes and validates a DeviceRequest into d, writing the error response on failure.
+func (h *DevicesHandler) applyRequest(w http.ResponseWriter, r *http.Request, d *models.Device) bool {
+	var req DeviceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error":"invalid json"}`, http.StatusBadRequest)
+		return false
+	}
+	switch req.Type {
+	case models.DeviceKindle, models.DeviceKobo, models.DeviceTablet, models.DeviceOther:
+	default:
+		http.Error(w, `{"error":"type must be kindle, kobo, tablet or other"}`, http.StatusBadRequest)
+		return false
+	}
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		http.Error(w, `{"error":"name is required"}`, http.StatusBadRequest)
+		return false
+	}
+	formats, maxMB := models.DefaultDeviceProfile(req.Type)
+	if len(req.Formats) > 0 {
+		formats = nil
+		for _, f := range req.Formats {
+			f = strings.ToLower(strings.TrimSpace(f))
+			if !slices.Contains(models.DeviceFormats, f) {
+				http.Error(w, `{"error":"formats must be from `+strings.Join(models.DeviceFormats, ", ")+`"}`, http.StatusBadRequest)
+				return false
+			}
+			if !slices.Contains(formats, f) {
+				formats = append(formats, f)
+			}
+		}
+	}
+	if req.MaxAttachmentMB != nil {
+		if *req.MaxAttachmentMB < 0 {
+			http.Error(w, `{"error":"maxAttachmentMB must not be negative"}`, http.StatusBadRequest)
+			return false
+		}
+		maxMB = *req.MaxAttachmentMB
+	}
+	var targetID primitive.ObjectID
+	if req.TargetID != "" {
+		id, err := primitive.ObjectIDFromHex(req.TargetID)
+		if err != nil {
+			http.Error(w, `{"error":"invalid target id"}`, http.StatusBadRequest)
+			return false
+		}
+		t, err := h.DB.DeliveryTarget(r.Context(), d.UserID, id)
+		if err != nil {
+			http.Error(w, `{"error":"failed to load target"}`, http.StatusInternalServerError)
+			return false
+		}
+		if t == nil {
+			http.Error(w, `{"error":"target not found"}`, http.StatusBadRequest)
+			return false
+		}
+		targetID = id
+	}
+	d.Name, d.Type, d.Formats, d.MaxAttachmentMB, d.TargetID, d.Optimize = name, req.Type, formats, maxMB, targetID, req.Optimize
+	return true
+}
@@ -4,23 +4,31 @@ import (
 	"encoding/json"
 	"log"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/kevinaaaquil/books/backend/middleware"
 	"github.com/kevinaaaquil/books/backend/models"
+	"github.com/kevinaaaquil/books/backend/service"
 	"github.com/kevinaaaquil/books/backend/store"
 	"github.com/kevinaaaquil/books/backend/utils"
 )
 
 type EmailConfigHandler struct {
-	DB    *store.DB
+	DB    store.Store
 	EncKey []byte // 32 bytes for AES-256; nil means store/return app password in plaintext (not recommended)
+	Mailer        service.Mailer // sends verification codes, like Send to Kindle
+	Clock         service.Clock
+	KindleDomains []string // allowed Kindle address domains; empty = any
 }
 
 type EmailConfigResponse struct {
-	AppSpecificPassword string `json:"appSpecificPassword"`
-	ICloudMail          string `json:"icloudMail"`
-	SenderMail          string `json:"senderMail"`
-	KindleMail          string `json:"kindleMail"`
+	AppSpecificPassword string     `json:"appSpecificPassword"`
+	ICloudMail          string     `json:"icloudMail"`
+	SenderMail          string     `json:"senderMail"`
+	KindleMail          string     `json:"kindleMail"`
+	KindleVerified      bool       `json:"kindleVerified"`
+	VerificationSentAt  *time.Time `json:"verificationSentAt,omitempty"`
 }
 
 type SaveEmailConfigRequest struct {
@@ -28,6 +36,22 @@ type SaveEmailConfigRequest struct {
 	ICloudMail          string `json:"icloudMail"`
 	SenderMail          string `json:"senderMail"`
 	KindleMail          string `json:"kindleMail"`
+	// AllowAnyDomain saves a Kindle address outside the allowed domains (e.g. a reseller's Kindle service).
+	AllowAnyDomain bool `json:"allowAnyDomain"`
+}
+
+func emailConfigResponse(cfg *models.EmailConfig, password string) EmailConfigResponse {
+	resp := EmailConfigResponse{
+		AppSpecificPassword: password,
+		ICloudMail:          cfg.ICloudMail,
+		SenderMail:          cfg.SenderMail,
+		KindleMail:          cfg.KindleMail,
+		KindleVerified:      cfg.KindleVerified(),
+	}
+	if v := cfg.KindleVerification; v != nil && !v.Verified {
+		resp.VerificationSentAt = v.SentAt
+	}
+	return resp
 }
 
 // Get returns the current user's Kindle config. Password is decrypted when EncKey is set.
@@ -61,15 +85,11 @@ func (h *EmailConfigHandler) Get(w http.ResponseWriter, r *http.Request) {
 			password = dec
 		}
 	}
-	json.NewEncoder(w).Encode(EmailConfigResponse{
-		AppSpecificPassword: password,
-		ICloudMail:          cfg.ICloudMail,
-		SenderMail:          cfg.SenderMail,
-		KindleMail:          cfg.KindleMail,
-	})
+	json.NewEncoder(w).Encode(emailConfigResponse(cfg, password))
 }
 
 // Save creates or updates the current user's Kindle config. App-specific password is encrypted at rest when EncKey is set.
+// The Kindle address must be at one of KindleDomains unless allowAnyDomain is set; changing it resets verification.
 func (h *EmailConfigHandler) Save(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPut && r.Method != http.MethodPatch && r.Method != http.MethodPost {
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
@@ -85,6 +105,17 @@ func (h *EmailConfigHandler) Save(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, `{"error":"invalid json"}`, http.StatusBadRequest)
 		return
 	}
+	req.KindleMail = strings.TrimSpace(req.KindleMail)
+	if req.KindleMail != "" && !req.AllowAnyDomain && !kindleDomainAllowed(req.KindleMail, h.KindleDomains) {
+		writeSendError(w, http.StatusBadRequest, "KINDLE_DOMAIN",
+			"Kindle address must end in @"+strings.Join(h.KindleDomains, " or @")+". Check for typos, or save anyway if your device uses another address.")
+		return
+	}
+	existing, err := h.DB.GetEmailConfig(r.Context(), userID)
+	if err != nil {
+		http.Error(w, `{"error":"failed to save Kindle config"}`, http.StatusInternalServerError)
+		return
+	}
 	passwordToStore := req.AppSpecificPassword
 	if len(h.EncKey) == 32 && passwordToStore != "" {
 		enc, err := utils.Encrypt([]byte(passwordToStore), h.EncKey)
@@ -101,16 +132,16 @@ func (h *EmailConfigHandler) Save(w http.ResponseWriter, r *http.Request) {
 		SenderMail:          req.SenderMail,
 		KindleMail:          req.KindleMail,
 	}
+	if existing != nil && strings.EqualFold(existing.KindleMail, cfg.KindleMail) {
+		cfg.KindleVerification = existing.KindleVerification
+	} else if cfg.KindleMail != "" {
+		cfg.KindleVerification = &models.AddressVerification{}
+	}
 	if err := h.DB.UpsertEmailConfig(r.Context(), userID, cfg); err != nil {
 		http.Error(w, `{"error":"failed to save Kindle config"}`, http.StatusInternalServerError)
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(EmailConfigResponse{
-		AppSpecificPassword: req.AppSpecificPassword,
-		ICloudMail:          cfg.ICloudMail,
-		SenderMail:          cfg.SenderMail,
-		KindleMail:          cfg.KindleMail,
-	})
+	json.NewEncoder(w).Encode(emailConfigResponse(cfg, req.AppSpecificPassword))
 }
@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+
+	"github.com/kevinaaaquil/books/backend/events"
+	"github.com/kevinaaaquil/books/backend/jobs"
+	"github.com/kevinaaaquil/books/backend/middleware"
+	"github.com/kevinaaaquil/books/backend/models"
+)
+
+// emitEvent hands e to sink; a nil sink drops it.
+func emitEvent(sink events.Sink, e events.Event) {
+	if sink != nil {
+		sink.Emit(e)
+	}
+}
+
+// eventUserID is the signed-in user's ID; empty for work no request asked for, such as WATCH_DIR pickups.
+func eventUserID(ctx context.Context) string {
+	if id, ok := middleware.UserIDFromContext(ctx); ok {
+		return id.Hex()
+	}
+	return ""
+}
+
+func bookEvent(b *models.Book) events.Book {
+	return events.Book{
+		BookID:    b.ID.Hex(),
+		Title:     b.Title,
+		Authors:   b.Authors,
+		ISBN:      b.ISBN,
+		Format:    b.Format,
+		SizeBytes: b.SizeBytes,
+		Source:    b.Source,
+	}
+}
+
+// recordDownloadLink audits link, then exports it as download.issued.
+func (h *BooksHandler) recordDownloadLink(ctx context.Context, link *models.DownloadLink) error {
+	if err := h.DB.InsertDownloadLink(ctx, link); err != nil {
+		return err
+	}
+	emitEvent(h.Events, events.New(events.TypeDownloadIssued, link.IssuedAt, link.UserID.Hex(), events.Download{
+		BookID: link.BookID.Hex(),
+		Kind:   link.Kind,
+		Role:   link.Role,
+		Bytes:  link.Bytes,
+	}))
+	return nil
+}
+
+// emitSend exports the outcome of an attempt of a send job: send.completed, or send.failed once the job won't be
+// retried. Attempts cut short by a shutdown are run again, so they export nothing.
+func (h *BooksHandler) emitSend(ctx context.Context, job *models.QueuedJob, destination string, err error) {
+	typ := events.TypeSendCompleted
+	data := events.Send{
+		JobID:       job.ID.Hex(),
+		BookID:      job.Params["bookId"],
+		Format:      job.Params["format"],
+		Destination: destination,
+		Attempts:    job.Attempts,
+	}
+	if err != nil {
+		if !jobs.IsPermanent(err) && (job.Attempts < job.MaxAttempts || errors.Is(ctx.Err(), context.Canceled)) {
+			return
+		}
+		typ, data.Error = events.TypeSendFailed, err.Error()
+	}
+	emitEvent(h.Events, events.New(typ, h.Clock.Now(), job.UserID.Hex(), data))
+}
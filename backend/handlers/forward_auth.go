@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+
+	"github.com/kevinaaaquil/books/backend/middleware"
+	"github.com/kevinaaaquil/books/backend/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ResolveForwardUser returns the ID of the user with email, creating them on their first visit and giving them
+// role when their groups at the proxy have changed. New users get a password nobody knows, so they can only sign
+// in through the proxy (or after a password reset). Read-only replicas only sign in users that exist, with the
+// role the proxy gives them for the request. It is a middleware.ForwardUserResolver.
+func (h *AuthHandler) ResolveForwardUser(ctx context.Context, email, role string) (primitive.ObjectID, error) {
+	user, err := h.DB.UserByEmail(ctx, email)
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+	if user != nil {
+		if user.Role != role && !h.ReadOnly {
+			if err := h.DB.UpdateUser(ctx, user.ID, nil, nil, &role); err != nil {
+				return primitive.NilObjectID, err
+			}
+		}
+		return user.ID, nil
+	}
+	if h.ReadOnly {
+		return primitive.NilObjectID, nil
+	}
+	random := make([]byte, 24)
+	if _, err := rand.Read(random); err != nil {
+		return primitive.NilObjectID, err
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(base64.RawURLEncoding.EncodeToString(random)), bcrypt.DefaultCost)
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+	return h.DB.CreateUser(ctx, &models.User{Email: email, Password: string(hash), Role: role, CreatedAt: h.Clock.Now()})
+}
+
+// ProxyLogin returns a token for the user the SSO proxy signed in (see middleware.ForwardAuth), so clients built
+// around tokens need no second login. 401 without a trusted proxy identity. POST /api/auth/proxy
+func (h *AuthHandler) ProxyLogin(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, `{"error":"not signed in by a proxy"}`, http.StatusUnauthorized)
+		return
+	}
+	email, role := middleware.EmailFromContext(r.Context()), middleware.RoleFromContext(r.Context())
+	res, err := h.signIn(r.Context(), userID, email, role, primitive.NilObjectID)
+	if err != nil {
+		http.Error(w, `{"error":"could not create token"}`, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(res)
+}
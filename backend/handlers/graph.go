@@ -0,0 +1,193 @@
+package handlers
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/kevinaaaquil/books/backend/middleware"
+	"github.com/kevinaaaquil/books/backend/models"
+)
+
+// defaultGraphMaxBooks caps graph exports when BooksHandler.GraphMaxBooks is not set.
+const defaultGraphMaxBooks = 5000
+
+// Graph exports the books the user may see as a graph of books, authors and categories (see models.BookGraph):
+// JSON by default, GraphML with ?format=graphml. At most GraphMaxBooks books, the newest, are included; ?limit=
+// asks for fewer. GET /api/export/graph
+func (h *BooksHandler) Graph(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if _, ok := middleware.UserIDFromContext(r.Context()); !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+	format := r.URL.Query().Get("format")
+	if format != "" && format != "json" && format != "graphml" {
+		http.Error(w, `{"error":"format must be json or graphml"}`, http.StatusBadRequest)
+		return
+	}
+	limit := h.GraphMaxBooks
+	if limit <= 0 {
+		limit = defaultGraphMaxBooks
+	}
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			http.Error(w, `{"error":"limit must be a positive number"}`, http.StatusBadRequest)
+			return
+		}
+		limit = min(n, limit)
+	}
+	guestOnly := middleware.RoleFromContext(r.Context()) == models.RoleGuest
+	books, truncated, err := h.DB.BooksForGraph(r.Context(), guestOnly, limit)
+	if err != nil {
+		http.Error(w, `{"error":"failed to export graph"}`, http.StatusInternalServerError)
+		return
+	}
+	maxRating, err := h.maxContentRating(r)
+	if err != nil {
+		http.Error(w, `{"error":"failed to export graph"}`, http.StatusInternalServerError)
+		return
+	}
+	graph := bookGraph(books, maxRating)
+	graph.Truncated = truncated
+	if format == "graphml" {
+		w.Header().Set("Content-Type", "application/graphml+xml")
+		w.Header().Set("Content-Disposition", `attachment; filename="library.graphml"`)
+		w.Write([]byte(xml.Header))
+		enc := xml.NewEncoder(w)
+		enc.Indent("", "  ")
+		enc.Encode(graphML(graph))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(graph)
+}
+
+// bookGraph links each book within maxRating to its authors and categories. Books come first in the given order,
+// then authors, then categories, by name.
+func bookGraph(books []models.Book, maxRating string) *models.BookGraph {
+	graph := &models.BookGraph{Nodes: []models.GraphNode{}, Edges: []models.GraphEdge{}}
+	linked := map[string]*models.GraphNode{}
+	// link adds an edge between the book and the author or category name, once per book.
+	link := func(book *models.GraphNode, kind, name, edge string, seen map[string]bool) {
+		id := kind + ":" + strings.ToLower(name)
+		if name == "" || seen[id] {
+			return
+		}
+		seen[id] = true
+		if linked[id] == nil {
+			linked[id] = &models.GraphNode{ID: id, Type: kind, Label: name}
+		}
+		linked[id].Books++
+		if kind == models.GraphNodeAuthor {
+			graph.Edges = append(graph.Edges, models.GraphEdge{Source: id, Target: book.ID, Type: edge})
+		} else {
+			graph.Edges = append(graph.Edges, models.GraphEdge{Source: book.ID, Target: id, Type: edge})
+		}
+	}
+	for i := range books {
+		b := &books[i]
+		if !contentRatingAllowed(maxRating, b) {
+			continue
+		}
+		node := models.GraphNode{ID: models.GraphNodeBook + ":" + b.ID.Hex(), Type: models.GraphNodeBook, Label: b.Title}
+		if len(b.PublishDate) >= 4 {
+			if _, err := strconv.Atoi(b.PublishDate[:4]); err == nil {
+				node.Year = b.PublishDate[:4]
+			}
+		}
+		graph.Nodes = append(graph.Nodes, node)
+		seen := map[string]bool{}
+		for _, a := range b.Authors {
+			link(&node, models.GraphNodeAuthor, strings.TrimSpace(a), models.GraphEdgeWrote, seen)
+		}
+		for _, c := range append([]string{b.Category}, b.Categories...) {
+			link(&node, models.GraphNodeCategory, strings.TrimSpace(c), models.GraphEdgeInCategory, seen)
+		}
+	}
+	others := make([]models.GraphNode, 0, len(linked))
+	for _, n := range linked {
+		others = append(others, *n)
+	}
+	sort.Slice(others, func(i, j int) bool {
+		if others[i].Type != others[j].Type {
+			return others[i].Type == models.GraphNodeAuthor
+		}
+		return others[i].ID < others[j].ID
+	})
+	graph.Nodes = append(graph.Nodes, others...)
+	return graph
+}
+
+// GraphML (http://graphml.graphdrawing.org), as read by Gephi, yEd and Cytoscape.
+type graphMLDoc struct {
+	XMLName xml.Name     `xml:"graphml"`
+	XMLNS   string       `xml:"xmlns,attr"`
+	Keys    []graphMLKey `xml:"key"`
+	Graph   graphMLGraph `xml:"graph"`
+}
+
+type graphMLKey struct {
+	ID       string `xml:"id,attr"`
+	For      string `xml:"for,attr"`
+	AttrName string `xml:"attr.name,attr"`
+	AttrType string `xml:"attr.type,attr"`
+}
+
+type graphMLGraph struct {
+	ID          string        `xml:"id,attr"`
+	EdgeDefault string        `xml:"edgedefault,attr"`
+	Nodes       []graphMLNode `xml:"node"`
+	Edges       []graphMLEdge `xml:"edge"`
+}
+
+type graphMLNode struct {
+	ID   string        `xml:"id,attr"`
+	Data []graphMLData `xml:"data"`
+}
+
+type graphMLEdge struct {
+	Source string        `xml:"source,attr"`
+	Target string        `xml:"target,attr"`
+	Data   []graphMLData `xml:"data"`
+}
+
+type graphMLData struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:",chardata"`
+}
+
+func graphML(g *models.BookGraph) graphMLDoc {
+	doc := graphMLDoc{
+		XMLNS: "http://graphml.graphdrawing.org/xmlns",
+		Keys: []graphMLKey{
+			{ID: "type", For: "node", AttrName: "type", AttrType: "string"},
+			{ID: "label", For: "node", AttrName: "label", AttrType: "string"},
+			{ID: "year", For: "node", AttrName: "year", AttrType: "string"},
+			{ID: "books", For: "node", AttrName: "books", AttrType: "int"},
+			{ID: "relation", For: "edge", AttrName: "type", AttrType: "string"},
+		},
+		Graph: graphMLGraph{ID: "library", EdgeDefault: "directed"},
+	}
+	for _, n := range g.Nodes {
+		node := graphMLNode{ID: n.ID, Data: []graphMLData{{Key: "type", Value: n.Type}, {Key: "label", Value: n.Label}}}
+		if n.Year != "" {
+			node.Data = append(node.Data, graphMLData{Key: "year", Value: n.Year})
+		}
+		if n.Books > 0 {
+			node.Data = append(node.Data, graphMLData{Key: "books", Value: strconv.Itoa(n.Books)})
+		}
+		doc.Graph.Nodes = append(doc.Graph.Nodes, node)
+	}
+	for _, e := range g.Edges {
+		doc.Graph.Edges = append(doc.Graph.Edges, graphMLEdge{Source: e.Source, Target: e.Target, Data: []graphMLData{{Key: "relation", Value: e.Type}}})
+	}
+	return doc
+}
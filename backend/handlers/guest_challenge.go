@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"math/bits"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// guestChallengeTTL is how long a guest login challenge can be solved in.
+const guestChallengeTTL = 5 * time.Minute
+
+// GuestChallenge is the proof of work guest login requires when GUEST_LOGIN_POW_BITS is set: find a solution such
+// that the SHA-256 of challenge + ":" + solution starts with Bits zero bits, and send both to POST /api/auth/guest.
+type GuestChallenge struct {
+	Challenge string    `json:"challenge"`
+	Bits      int       `json:"bits"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// GuestLoginRequest is the body of POST /api/auth/guest; empty unless guest login requires proof of work.
+type GuestLoginRequest struct {
+	Challenge string `json:"challenge"`
+	Solution  string `json:"solution"` // at most 64 bytes
+}
+
+// guestChallengeClaims are a challenge: its difficulty, and an ID so it can be used once. They are signed with a
+// key derived from JWT_SECRET, so they can't be used to log in.
+type guestChallengeClaims struct {
+	Bits int `json:"bits"`
+	jwt.RegisteredClaims
+}
+
+func guestChallengeKey(jwtSecret string) []byte {
+	return []byte("guest-challenge:" + jwtSecret)
+}
+
+// spentChallenges remembers the challenges used on this instance until they expire.
+type spentChallenges struct {
+	mu  sync.Mutex
+	ids map[string]time.Time // challenge ID -> expiry
+}
+
+// spend records id as used until expires, and reports whether it was unused.
+func (s *spentChallenges) spend(id string, expires, now time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ids == nil {
+		s.ids = map[string]time.Time{}
+	}
+	for spent, exp := range s.ids {
+		if !exp.After(now) {
+			delete(s.ids, spent)
+		}
+	}
+	if _, ok := s.ids[id]; ok {
+		return false
+	}
+	s.ids[id] = expires
+	return true
+}
+
+// GuestChallenge returns a new proof-of-work challenge for guest login; 404 when none is required.
+// GET /api/auth/guest/challenge (public).
+func (h *AuthHandler) GuestChallenge(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if h.GuestPoWBits <= 0 {
+		http.Error(w, `{"error":"guest login requires no challenge"}`, http.StatusNotFound)
+		return
+	}
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		http.Error(w, `{"error":"could not create challenge"}`, http.StatusInternalServerError)
+		return
+	}
+	now := h.Clock.Now()
+	res := GuestChallenge{Bits: h.GuestPoWBits, ExpiresAt: now.Add(guestChallengeTTL)}
+	claims := &guestChallengeClaims{Bits: h.GuestPoWBits, RegisteredClaims: jwt.RegisteredClaims{
+		ID:        hex.EncodeToString(nonce),
+		ExpiresAt: jwt.NewNumericDate(res.ExpiresAt),
+		IssuedAt:  jwt.NewNumericDate(now),
+	}}
+	var err error
+	if res.Challenge, err = jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(guestChallengeKey(h.JWTSecret)); err != nil {
+		http.Error(w, `{"error":"could not create challenge"}`, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(res)
+}
+
+// solvedGuestChallenge reports whether solution solves a challenge from GuestChallenge that is unexpired, at least
+// as hard as now required, and not used before on this instance.
+func (h *AuthHandler) solvedGuestChallenge(challenge, solution string) bool {
+	if challenge == "" || solution == "" || len(solution) > 64 {
+		return false
+	}
+	now := h.Clock.Now()
+	claims := &guestChallengeClaims{}
+	token, err := jwt.ParseWithClaims(challenge, claims, func(t *jwt.Token) (interface{}, error) {
+		return guestChallengeKey(h.JWTSecret), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Name}), jwt.WithTimeFunc(func() time.Time { return now }))
+	if err != nil || !token.Valid || claims.ID == "" || claims.ExpiresAt == nil || claims.Bits < h.GuestPoWBits {
+		return false
+	}
+	if leadingZeroBits(sha256.Sum256([]byte(challenge+":"+solution))) < claims.Bits {
+		return false
+	}
+	return h.spent.spend(claims.ID, claims.ExpiresAt.Time, now)
+}
+
+// leadingZeroBits counts the zero bits sum starts with.
+func leadingZeroBits(sum [sha256.Size]byte) int {
+	n := 0
+	for _, b := range sum {
+		if b != 0 {
+			return n + bits.LeadingZeros8(b)
+		}
+		n += 8
+	}
+	return n
+}
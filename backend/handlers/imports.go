@@ -0,0 +1,297 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/kevinaaaquil/books/backend/jobs"
+	"github.com/kevinaaaquil/books/backend/middleware"
+	"github.com/kevinaaaquil/books/backend/models"
+	"github.com/kevinaaaquil/books/backend/service"
+	"github.com/kevinaaaquil/books/backend/store"
+	"github.com/kevinaaaquil/books/backend/utils"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ImportsHandler manages the cloud drive folders users import books from. Drives are linked with OAuth through
+// the same callback as delivery targets (see TargetsHandler.LinkCallback), with read access to the user's files.
+// Sources are synced by the cloud import job, on demand or by IMPORT_SCHEDULE.
+type ImportsHandler struct {
+	DB        store.Store
+	Sources   map[string]service.DriveSource // by drive kind; providers not configured are absent
+	Clock     service.Clock
+	EncKey    []byte // 32 bytes to encrypt drive tokens; nil = stored in plaintext
+	JWTSecret string
+	APIURL    string // this API's base URL, for OAuth redirects
+	AppURL    string // frontend base URL, where the OAuth callback sends the user back to
+	Jobs      *jobs.Runner
+	Ingest    func(context.Context, IngestFile) (*IngestResult, error) // UploadHandler.Ingest
+	MaxBytes  int64                                                    // larger files are not imported; 0 = no limit
+}
+
+type ImportsResponse struct {
+	Sources   []models.ImportSource `json:"sources"`
+	Providers []string              `json:"providers"` // drive kinds that can be linked
+}
+
+// ImportSourceRequest changes a source; fields left out keep their value.
+type ImportSourceRequest struct {
+	Name     *string `json:"name"`
+	Folder   *string `json:"folder"`
+	AutoSync *bool   `json:"autoSync"`
+}
+
+// SyncJob returns the cloud import job for one source, or for every source with AutoSync when id is zero.
+func (h *ImportsHandler) SyncJob(id primitive.ObjectID) jobs.Func {
+	return jobs.CloudImport(h.DB, jobs.CloudImportOptions{
+		Sources:  h.Sources,
+		Ingest:   h.ingest,
+		EncKey:   h.EncKey,
+		MaxBytes: h.MaxBytes,
+		SourceID: id,
+	})
+}
+
+func (h *ImportsHandler) ingest(ctx context.Context, name string, data []byte, uploadedBy, sourcePath string) (primitive.ObjectID, error) {
+	res, err := h.Ingest(ctx, IngestFile{Name: name, Data: data, UploadedBy: uploadedBy, Source: models.SourceCloudImport, SourcePath: sourcePath})
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+	return res.Book.ID, nil
+}
+
+// List returns the current user's import sources. GET /api/imports
+func (h *ImportsHandler) List(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+	sources, err := h.DB.ImportSources(r.Context())
+	if err != nil {
+		http.Error(w, `{"error":"failed to list import sources"}`, http.StatusInternalServerError)
+		return
+	}
+	resp := ImportsResponse{Sources: []models.ImportSource{}, Providers: []string{}}
+	for _, s := range sources {
+		if s.UserID == userID {
+			resp.Sources = append(resp.Sources, s)
+		}
+	}
+	for kind := range h.Sources {
+		resp.Providers = append(resp.Providers, kind)
+	}
+	sort.Strings(resp.Providers)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// StartLink returns the provider URL where the user grants read access to their drive. ?folder= is the folder
+// to import from (a Dropbox path, or a Google Drive folder ID; empty for the whole drive) and ?autoSync=true
+// includes it in scheduled syncs. GET /api/imports/oauth/:provider/start. 404 if the provider is not configured.
+func (h *ImportsHandler) StartLink(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+	provider := chi.URLParam(r, "provider")
+	source, ok := h.Sources[provider]
+	if !ok {
+		http.Error(w, `{"error":"provider not configured"}`, http.StatusNotFound)
+		return
+	}
+	q := r.URL.Query()
+	state, err := signOAuthState(&oauthStateClaims{
+		UserID:   userID.Hex(),
+		Provider: provider,
+		Purpose:  oauthPurposeImport,
+		Folder:   strings.TrimSpace(q.Get("folder")),
+		AutoSync: q.Get("autoSync") == "true",
+	}, h.JWTSecret, h.Clock.Now())
+	if err != nil {
+		http.Error(w, `{"error":"failed to start linking"}`, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"url": source.ImportAuthURL(state, oauthRedirectURL(h.APIURL, provider))})
+}
+
+// linkCallback finishes a link started by StartLink: it stores the import source and redirects to the imports
+// page with ?linked=<provider>, or ?link_error=<message>.
+func (h *ImportsHandler) linkCallback(w http.ResponseWriter, r *http.Request, claims *oauthStateClaims, now time.Time) {
+	back := func(key, value string) {
+		http.Redirect(w, r, strings.TrimSuffix(h.AppURL, "/")+"/imports?"+url.Values{key: {value}}.Encode(), http.StatusFound)
+	}
+	provider := claims.Provider
+	source, ok := h.Sources[provider]
+	if !ok {
+		back("link_error", "provider not configured")
+		return
+	}
+	q := r.URL.Query()
+	if e := q.Get("error"); e != "" {
+		back("link_error", "access was not granted")
+		return
+	}
+	refreshToken, err := source.LinkImport(r.Context(), q.Get("code"), oauthRedirectURL(h.APIURL, provider))
+	if err != nil {
+		log.Printf("link %s for import: %v", provider, err)
+		back("link_error", "could not link "+driveNames[provider])
+		return
+	}
+	if len(h.EncKey) == 32 {
+		if refreshToken, err = utils.Encrypt([]byte(refreshToken), h.EncKey); err != nil {
+			back("link_error", "could not link "+driveNames[provider])
+			return
+		}
+	}
+	userID, _ := primitive.ObjectIDFromHex(claims.UserID)
+	s := &models.ImportSource{
+		UserID:       userID,
+		Kind:         provider,
+		Name:         driveNames[provider],
+		Folder:       claims.Folder,
+		RefreshToken: refreshToken,
+		AutoSync:     claims.AutoSync,
+		CreatedAt:    now,
+	}
+	if _, err := h.DB.InsertImportSource(r.Context(), s); err != nil {
+		back("link_error", "could not save "+driveNames[provider])
+		return
+	}
+	back("linked", provider)
+}
+
+// source loads the current user's source named in the URL, writing the error response when there is none.
+func (h *ImportsHandler) source(w http.ResponseWriter, r *http.Request) (*models.ImportSource, bool) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return nil, false
+	}
+	id, err := primitive.ObjectIDFromHex(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, `{"error":"invalid import source id"}`, http.StatusBadRequest)
+		return nil, false
+	}
+	s, err := h.DB.ImportSource(r.Context(), id)
+	if err != nil {
+		http.Error(w, `{"error":"failed to load import source"}`, http.StatusInternalServerError)
+		return nil, false
+	}
+	if s == nil || s.UserID != userID {
+		http.Error(w, `{"error":"import source not found"}`, http.StatusNotFound)
+		return nil, false
+	}
+	return s, true
+}
+
+// Patch renames a source, changes its folder or turns scheduled syncs on or off. PATCH /api/imports/:id
+func (h *ImportsHandler) Patch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPatch {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req ImportSourceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error":"invalid json"}`, http.StatusBadRequest)
+		return
+	}
+	s, ok := h.source(w, r)
+	if !ok {
+		return
+	}
+	if req.Name != nil {
+		name := strings.TrimSpace(*req.Name)
+		if name == "" {
+			http.Error(w, `{"error":"name must not be empty"}`, http.StatusBadRequest)
+			return
+		}
+		s.Name = name
+	}
+	if req.Folder != nil {
+		s.Folder = strings.TrimSpace(*req.Folder)
+	}
+	if req.AutoSync != nil {
+		s.AutoSync = *req.AutoSync
+	}
+	found, err := h.DB.UpdateImportSource(r.Context(), s)
+	if err != nil {
+		http.Error(w, `{"error":"failed to save import source"}`, http.StatusInternalServerError)
+		return
+	}
+	if !found {
+		http.Error(w, `{"error":"import source not found"}`, http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s)
+}
+
+// Delete unlinks a source. Books imported from it stay in the library. DELETE /api/imports/:id
+func (h *ImportsHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	s, ok := h.source(w, r)
+	if !ok {
+		return
+	}
+	found, err := h.DB.DeleteImportSource(r.Context(), s.ID)
+	if err != nil {
+		http.Error(w, `{"error":"failed to delete import source"}`, http.StatusInternalServerError)
+		return
+	}
+	if !found {
+		http.Error(w, `{"error":"import source not found"}`, http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Sync starts importing a source's new files and returns the job run (202). The outcome is the source's lastSync
+// once the run has finished. 409 while a sync, of this or any source, is running. POST /api/imports/:id/sync
+func (h *ImportsHandler) Sync(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	s, ok := h.source(w, r)
+	if !ok {
+		return
+	}
+	run, err := h.Jobs.StartWith(jobs.TypeCloudImport, middleware.EmailFromContext(r.Context()), map[string]string{"source": s.ID.Hex()}, h.SyncJob(s.ID))
+	if errors.Is(err, jobs.ErrAlreadyRunning) {
+		http.Error(w, `{"error":"an import is already running"}`, http.StatusConflict)
+		return
+	}
+	if errors.Is(err, jobs.ErrShuttingDown) {
+		http.Error(w, `{"error":"server is shutting down"}`, http.StatusServiceUnavailable)
+		return
+	}
+	if err != nil {
+		http.Error(w, `{"error":"failed to start import"}`, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(run)
+}
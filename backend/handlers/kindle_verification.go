@@ -0,0 +1,238 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/kevinaaaquil/books/backend/middleware"
+	"github.com/kevinaaaquil/books/backend/models"
+	"github.com/kevinaaaquil/books/backend/service"
+	"github.com/kevinaaaquil/books/backend/utils"
+)
+
+const (
+	// verificationResendAfter is how long a user waits before another code can be sent.
+	verificationResendAfter = time.Minute
+	// verificationCodeTTL allows for slow Kindle deliveries.
+	verificationCodeTTL = 48 * time.Hour
+	// verificationMaxAttempts wrong codes invalidate the code; a new one must be sent.
+	verificationMaxAttempts = 5
+)
+
+// kindleConfigReady reports whether cfg has what mailer needs: the Kindle address, plus the iCloud account
+// when the mailer sends as the user.
+func kindleConfigReady(cfg *models.EmailConfig, mailer service.Mailer) bool {
+	return cfg != nil && cfg.KindleMail != "" && senderReady(cfg, mailer)
+}
+
+// senderReady reports whether mailer can send as the user: always for API transports, which send from the
+// server's own address, otherwise only once cfg holds the iCloud account.
+func senderReady(cfg *models.EmailConfig, mailer service.Mailer) bool {
+	return !mailer.UsesSenderAccount() || (cfg != nil && cfg.SenderMail != "" && cfg.AppSpecificPassword != "" && cfg.ICloudMail != "")
+}
+
+// kindleSender returns a Mail addressed from cfg's sender to its Kindle, with the iCloud login (app password
+// decrypted when encKey is set) if mailer sends as the user.
+func kindleSender(cfg *models.EmailConfig, mailer service.Mailer, encKey []byte) (service.Mail, error) {
+	m, err := userSender(cfg, mailer, encKey)
+	m.To = cfg.KindleMail
+	return m, err
+}
+
+// userSender returns a Mail from the user's sender address, with the iCloud login if mailer sends as the user.
+// cfg may be nil for API transports (see senderReady).
+func userSender(cfg *models.EmailConfig, mailer service.Mailer, encKey []byte) (service.Mail, error) {
+	var m service.Mail
+	if cfg == nil {
+		return m, nil
+	}
+	m.From = cfg.SenderMail
+	if !mailer.UsesSenderAccount() {
+		return m, nil
+	}
+	password := cfg.AppSpecificPassword
+	if len(encKey) == 32 && password != "" {
+		dec, err := utils.Decrypt(password, encKey)
+		if err != nil {
+			return m, fmt.Errorf("decrypt app password: %w", err)
+		}
+		password = dec
+	}
+	m.Username, m.Password = cfg.ICloudMail, password
+	return m, nil
+}
+
+// kindleDomainAllowed reports whether addr is at one of domains (any address when domains is empty).
+func kindleDomainAllowed(addr string, domains []string) bool {
+	if len(domains) == 0 {
+		return true
+	}
+	at := strings.LastIndex(addr, "@")
+	if at < 0 {
+		return false
+	}
+	domain := strings.ToLower(addr[at+1:])
+	for _, d := range domains {
+		if domain == d {
+			return true
+		}
+	}
+	return false
+}
+
+func verificationCodeHash(code string) string {
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
+
+// newVerificationCode returns a random 6-digit code.
+func newVerificationCode() (string, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(1000000))
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%06d", n.Int64()), nil
+}
+
+// verificationThrottled reports whether a code was sent for v less than verificationResendAfter ago.
+func verificationThrottled(v *models.AddressVerification, now time.Time) bool {
+	return v != nil && v.SentAt != nil && now.Sub(*v.SentAt) < verificationResendAfter
+}
+
+var (
+	errCodeExpired = errors.New("code expired; send a new one")
+	errWrongCode   = errors.New("wrong code")
+)
+
+// checkVerificationCode compares code with the last one sent for v (nil when none was), counting a wrong guess in v.Attempts.
+// The caller saves v either way.
+func checkVerificationCode(v *models.AddressVerification, code string, now time.Time) error {
+	if v == nil || v.CodeHash == "" || v.SentAt == nil || now.Sub(*v.SentAt) > verificationCodeTTL || v.Attempts >= verificationMaxAttempts {
+		return errCodeExpired
+	}
+	if subtle.ConstantTimeCompare([]byte(verificationCodeHash(strings.TrimSpace(code))), []byte(v.CodeHash)) != 1 {
+		v.Attempts++
+		return errWrongCode
+	}
+	return nil
+}
+
+type ConfirmVerificationRequest struct {
+	Code string `json:"code"`
+}
+
+// SendVerification sends a small document holding a 6-digit code to the user's Kindle address.
+// POST /api/email-config/verification. 429 if a code was sent less than a minute ago.
+func (h *EmailConfigHandler) SendVerification(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+	cfg, err := h.DB.GetEmailConfig(r.Context(), userID)
+	if err != nil {
+		http.Error(w, `{"error":"failed to load Kindle config"}`, http.StatusInternalServerError)
+		return
+	}
+	if !kindleConfigReady(cfg, h.Mailer) {
+		http.Error(w, `{"error":"save your Kindle config first"}`, http.StatusBadRequest)
+		return
+	}
+	if cfg.KindleVerified() {
+		http.Error(w, `{"error":"Kindle address already verified"}`, http.StatusConflict)
+		return
+	}
+	now := h.Clock.Now()
+	if verificationThrottled(cfg.KindleVerification, now) {
+		http.Error(w, `{"error":"a code was just sent; wait a minute before sending another"}`, http.StatusTooManyRequests)
+		return
+	}
+	code, err := newVerificationCode()
+	if err != nil {
+		http.Error(w, `{"error":"failed to send verification"}`, http.StatusInternalServerError)
+		return
+	}
+	mail, err := kindleSender(cfg, h.Mailer, h.EncKey)
+	if err != nil {
+		log.Printf("kindle verification: %v", err)
+		http.Error(w, `{"error":"failed to use Kindle config"}`, http.StatusInternalServerError)
+		return
+	}
+	// Kindle drops messages without a supported attachment, so the code travels as a text document.
+	mail.Subject = "Books verification code"
+	mail.Body = "Your Books verification code is in the attached document."
+	mail.AttachmentName = "Books verification code.txt"
+	mail.Attachment = strings.NewReader("Books verification code: " + code + "\n\nEnter this code in Kindle setup to confirm this address.\n")
+	if err := h.Mailer.Send(r.Context(), &mail); err != nil {
+		log.Printf("kindle verification: %v", err)
+		http.Error(w, `{"error":"failed to send verification: `+err.Error()+`"}`, http.StatusInternalServerError)
+		return
+	}
+	cfg.KindleVerification = &models.AddressVerification{CodeHash: verificationCodeHash(code), SentAt: &now}
+	if err := h.DB.UpsertEmailConfig(r.Context(), userID, cfg); err != nil {
+		http.Error(w, `{"error":"failed to save Kindle config"}`, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Verification code sent", "kindleMail": cfg.KindleMail})
+}
+
+// ConfirmVerification marks the Kindle address verified when the code matches the last one sent.
+// POST /api/email-config/verification/confirm.
+func (h *EmailConfigHandler) ConfirmVerification(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+	var req ConfirmVerificationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error":"invalid json"}`, http.StatusBadRequest)
+		return
+	}
+	cfg, err := h.DB.GetEmailConfig(r.Context(), userID)
+	if err != nil {
+		http.Error(w, `{"error":"failed to load Kindle config"}`, http.StatusInternalServerError)
+		return
+	}
+	if cfg == nil || cfg.KindleVerified() {
+		http.Error(w, `{"error":"nothing to verify"}`, http.StatusBadRequest)
+		return
+	}
+	now := h.Clock.Now()
+	if err := checkVerificationCode(cfg.KindleVerification, req.Code, now); err != nil {
+		if err == errWrongCode {
+			if err := h.DB.UpsertEmailConfig(r.Context(), userID, cfg); err != nil {
+				http.Error(w, `{"error":"failed to save Kindle config"}`, http.StatusInternalServerError)
+				return
+			}
+		}
+		http.Error(w, `{"error":"`+err.Error()+`"}`, http.StatusBadRequest)
+		return
+	}
+	cfg.KindleVerification = &models.AddressVerification{Verified: true, VerifiedAt: &now}
+	if err := h.DB.UpsertEmailConfig(r.Context(), userID, cfg); err != nil {
+		http.Error(w, `{"error":"failed to save Kindle config"}`, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"message": "Kindle address verified", "kindleVerified": true})
+}
@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kevinaaaquil/books/backend/service"
+)
+
+// lookupCacheSize bounds the lookup cache; when it is full, expired answers are dropped, then arbitrary ones.
+const lookupCacheSize = 1000
+
+// LookupHandler answers anonymous metadata lookups by ISBN, for companion tools (a browser extension, a phone
+// scanner) that preview a book before adding it. It is public, so it returns only what a preview needs, and
+// answers, including "not found", are cached so repeated lookups don't reach the metadata provider.
+type LookupHandler struct {
+	Metadata service.MetadataProvider
+	Clock    service.Clock
+	Timeout  time.Duration // overall deadline for a lookup; 0 = the provider's own
+	CacheTTL time.Duration // how long answers are reused; 0 disables the cache
+
+	mu    sync.Mutex
+	cache map[string]lookupEntry // by normalized ISBN
+}
+
+type lookupEntry struct {
+	meta    *service.BookMetadata // nil = no metadata for the ISBN
+	expires time.Time
+}
+
+// LookupResponse is the metadata GET /api/lookup returns.
+type LookupResponse struct {
+	ISBN         string   `json:"isbn"`
+	Title        string   `json:"title"`
+	Authors      []string `json:"authors,omitempty"`
+	Publisher    string   `json:"publisher,omitempty"`
+	PublishDate  string   `json:"publishDate,omitempty"`
+	PageCount    int      `json:"pageCount,omitempty"`
+	CoverURL     string   `json:"coverUrl,omitempty"`
+	ThumbnailURL string   `json:"thumbnailUrl,omitempty"`
+}
+
+// normalizeISBN strips spaces and hyphens, returning "" unless what is left is an ISBN-10 or ISBN-13.
+func normalizeISBN(s string) string {
+	s = strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(strings.TrimSpace(s)))
+	if len(s) != 10 && len(s) != 13 {
+		return ""
+	}
+	for i, r := range s {
+		if r < '0' || r > '9' {
+			if r != 'X' || len(s) != 10 || i != 9 { // an ISBN-10 check digit may be X
+				return ""
+			}
+		}
+	}
+	return s
+}
+
+// Lookup returns metadata for ?isbn=. 400 for a malformed ISBN, 404 when the provider has none, 504
+// (MetadataTimeoutResponse) when the lookup times out, 502 when it fails. GET /api/lookup
+func (h *LookupHandler) Lookup(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	isbn := normalizeISBN(r.URL.Query().Get("isbn"))
+	if isbn == "" {
+		http.Error(w, `{"error":"isbn must be an ISBN-10 or ISBN-13"}`, http.StatusBadRequest)
+		return
+	}
+	meta, cached := h.cached(isbn)
+	if !cached {
+		ctx := r.Context()
+		if h.Timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, h.Timeout)
+			defer cancel()
+		}
+		var err error
+		meta, err = h.Metadata.FetchByISBN(ctx, isbn)
+		if errors.Is(err, context.DeadlineExceeded) {
+			retryAfter := int(metadataRetryAfter.Seconds())
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+			w.WriteHeader(http.StatusGatewayTimeout)
+			json.NewEncoder(w).Encode(MetadataTimeoutResponse{Error: "metadata lookup timed out; try again later", Code: "METADATA_TIMEOUT", RetryAfter: retryAfter})
+			return
+		}
+		if err != nil && !errors.Is(err, service.ErrNoMetadata) {
+			log.Printf("lookup %s: %v", isbn, err)
+			http.Error(w, `{"error":"metadata lookup failed"}`, http.StatusBadGateway)
+			return
+		}
+		h.store(isbn, meta)
+	}
+	if meta == nil {
+		http.Error(w, `{"error":"no metadata found for this isbn"}`, http.StatusNotFound)
+		return
+	}
+	resp := LookupResponse{
+		ISBN:         meta.ISBN,
+		Title:        meta.Title,
+		Authors:      meta.Authors,
+		Publisher:    meta.Publisher,
+		PublishDate:  meta.PublishDate,
+		PageCount:    meta.PageCount,
+		CoverURL:     meta.CoverURL,
+		ThumbnailURL: meta.ThumbnailURL,
+	}
+	if resp.ISBN == "" {
+		resp.ISBN = isbn
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// cached returns the cached answer for isbn, if there is one that has not expired.
+func (h *LookupHandler) cached(isbn string) (*service.BookMetadata, bool) {
+	if h.CacheTTL <= 0 {
+		return nil, false
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	e, ok := h.cache[isbn]
+	if !ok || !h.Clock.Now().Before(e.expires) {
+		return nil, false
+	}
+	return e.meta, true
+}
+
+func (h *LookupHandler) store(isbn string, meta *service.BookMetadata) {
+	if h.CacheTTL <= 0 {
+		return
+	}
+	now := h.Clock.Now()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.cache == nil {
+		h.cache = map[string]lookupEntry{}
+	}
+	if len(h.cache) >= lookupCacheSize {
+		for k, e := range h.cache {
+			if !now.Before(e.expires) {
+				delete(h.cache, k)
+			}
+		}
+		for k := range h.cache {
+			if len(h.cache) < lookupCacheSize {
+				break
+			}
+			delete(h.cache, k)
+		}
+	}
+	h.cache[isbn] = lookupEntry{meta: meta, expires: now.Add(h.CacheTTL)}
+}
@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/kevinaaaquil/books/backend/middleware"
+	"github.com/kevinaaaquil/books/backend/store"
+)
+
+// newReleasesLimit caps the new releases feed.
+const newReleasesLimit = 100
+
+// NewReleasesHandler serves the new releases the new releases job found for users.
+type NewReleasesHandler struct {
+	DB store.Store
+}
+
+// List returns the books recently published by authors the current user has finished books by, most recently
+// found first. GET /api/me/new-releases
+func (h *NewReleasesHandler) List(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+	releases, err := h.DB.NewReleasesForUser(r.Context(), userID, newReleasesLimit)
+	if err != nil {
+		http.Error(w, `{"error":"failed to list new releases"}`, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(releases)
+}
@@ -0,0 +1,187 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/kevinaaaquil/books/backend/middleware"
+	"github.com/kevinaaaquil/books/backend/models"
+	"github.com/kevinaaaquil/books/backend/service"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// What ImportONIX did, or would do, with a product record.
+const (
+	ONIXActionUpdate    = "update"
+	ONIXActionUnchanged = "unchanged" // matched a book that already has everything the record has
+	ONIXActionUnmatched = "unmatched" // no book in the library has its ISBN, or its title and first author
+	ONIXActionSkipped   = "skipped"   // withdrawn by the sender
+)
+
+// ONIXImportReport sums up an ONIX import, with one item per product record in the message's order.
+type ONIXImportReport struct {
+	DryRun    bool             `json:"dryRun"`
+	Products  int              `json:"products"`
+	Updated   int              `json:"updated"`
+	Unchanged int              `json:"unchanged"`
+	Unmatched int              `json:"unmatched"`
+	Skipped   int              `json:"skipped"`
+	Items     []ONIXImportItem `json:"items"`
+}
+
+type ONIXImportItem struct {
+	RecordReference string              `json:"recordReference,omitempty"`
+	ISBN            string              `json:"isbn,omitempty"`
+	Title           string              `json:"title,omitempty"`
+	BookID          *primitive.ObjectID `json:"bookId,omitempty"`
+	Action          string              `json:"action"`
+	Fields          []string            `json:"fields,omitempty"` // book fields that change, by their JSON names
+}
+
+// ImportONIX enriches books from an ONIX 3.0 message in the request body (see service.ParseONIX). Each product
+// record is matched to a book by ISBN, or by title and first author, and fills in the fields the book lacks;
+// with ?overwrite=true it replaces those it has too. Books are only created by uploading their files, so records
+// for books not in the library are reported as unmatched. With ?dryRun=true nothing is saved and the report says
+// what would change. POST /api/import/onix (admin, editor)
+func (h *BooksHandler) ImportONIX(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if _, ok := middleware.UserIDFromContext(r.Context()); !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+	dryRun := r.URL.Query().Get("dryRun") == "true"
+	overwrite := r.URL.Query().Get("overwrite") == "true"
+	products, err := service.ParseONIX(r.Body)
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		http.Error(w, `{"error":"request body too large"}`, http.StatusRequestEntityTooLarge)
+		return
+	}
+	if err != nil {
+		http.Error(w, `{"error":"invalid ONIX message: `+err.Error()+`"}`, http.StatusBadRequest)
+		return
+	}
+	books, err := h.DB.AllBooks(r.Context())
+	if err != nil {
+		http.Error(w, `{"error":"failed to load books"}`, http.StatusInternalServerError)
+		return
+	}
+	byISBN, byTitle := map[string]*models.Book{}, map[string]*models.Book{}
+	for i := range books {
+		b := &books[i]
+		if isbn := isbn13(b.ISBN); isbn != "" {
+			byISBN[isbn] = b
+		}
+		if len(b.Authors) > 0 {
+			byTitle[onixTitleKey(b.Title, b.Authors[0])] = b
+		}
+	}
+
+	report := ONIXImportReport{DryRun: dryRun, Products: len(products), Items: []ONIXImportItem{}}
+	for _, p := range products {
+		m := p.Metadata
+		item := ONIXImportItem{RecordReference: p.RecordReference, ISBN: m.ISBN, Title: m.Title}
+		book := byISBN[isbn13(m.ISBN)]
+		if book == nil && len(m.Authors) > 0 {
+			book = byTitle[onixTitleKey(m.Title, m.Authors[0])]
+		}
+		switch {
+		case p.Delete:
+			item.Action = ONIXActionSkipped
+			report.Skipped++
+		case book == nil:
+			item.Action = ONIXActionUnmatched
+			report.Unmatched++
+		default:
+			item.BookID = &book.ID
+			item.Fields = enrichBook(book, &m, overwrite)
+			if len(item.Fields) == 0 {
+				item.Action = ONIXActionUnchanged
+				report.Unchanged++
+				break
+			}
+			item.Action = ONIXActionUpdate
+			report.Updated++
+			if dryRun {
+				break
+			}
+			if err := h.DB.UpdateBookMetadata(r.Context(), book.ID, book); err != nil {
+				http.Error(w, `{"error":"failed to update book"}`, http.StatusInternalServerError)
+				return
+			}
+			if h.Search != nil {
+				h.Search.PutMetadata(book)
+			}
+		}
+		report.Items = append(report.Items, item)
+	}
+	if !dryRun {
+		log.Printf("onix import by %s: %d products, %d books updated, %d unmatched", middleware.EmailFromContext(r.Context()), report.Products, report.Updated, report.Unmatched)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+// enrichBook copies the metadata's non-empty fields onto book where book has none, or wherever they differ when
+// overwrite is set, returning the JSON names of the fields it changed.
+func enrichBook(book *models.Book, m *service.BookMetadata, overwrite bool) []string {
+	var changed []string
+	setString := func(name string, dst *string, v string) {
+		if v != "" && *dst != v && (overwrite || *dst == "") {
+			*dst = v
+			changed = append(changed, name)
+		}
+	}
+	setList := func(name string, dst *[]string, v []string) {
+		if len(v) > 0 && !slices.Equal(*dst, v) && (overwrite || len(*dst) == 0) {
+			*dst = v
+			changed = append(changed, name)
+		}
+	}
+	setString("isbn", &book.ISBN, m.ISBN)
+	setString("title", &book.Title, m.Title)
+	setList("authors", &book.Authors, m.Authors)
+	setString("publisher", &book.Publisher, m.Publisher)
+	setString("publishDate", &book.PublishDate, m.PublishDate)
+	if m.PageCount > 0 && book.PageCount != m.PageCount && (overwrite || book.PageCount == 0) {
+		book.PageCount = m.PageCount
+		changed = append(changed, "pageCount")
+	}
+	setString("coverUrl", &book.CoverURL, m.CoverURL)
+	setString("thumbnailUrl", &book.ThumbnailURL, m.ThumbnailURL)
+	setString("edition", &book.Edition, m.Edition)
+	setString("preface", &book.Preface, m.Preface)
+	setString("category", &book.Category, m.Category)
+	setList("categories", &book.Categories, m.Categories)
+	return changed
+}
+
+// isbn13 normalizes an ISBN-10 or ISBN-13 to ISBN-13, so either form of one matches the other; "" when s is
+// neither.
+func isbn13(s string) string {
+	isbn := normalizeISBN(s)
+	if len(isbn) != 10 {
+		return isbn
+	}
+	isbn = "978" + isbn[:9]
+	sum := 0
+	for i, r := range isbn {
+		d := int(r - '0')
+		if i%2 == 1 {
+			d *= 3
+		}
+		sum += d
+	}
+	return isbn + string(rune('0'+(10-sum%10)%10))
+}
+
+func onixTitleKey(title, author string) string {
+	return strings.ToLower(strings.TrimSpace(title)) + "/" + strings.ToLower(strings.TrimSpace(author))
+}
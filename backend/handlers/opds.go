@@ -0,0 +1,293 @@
+package handlers
+
+import (
+	"encoding/xml"
+	"errors"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/kevinaaaquil/books/backend/middleware"
+	"github.com/kevinaaaquil/books/backend/models"
+)
+
+// OPDS feed media types.
+const (
+	opdsNavigationType  = "application/atom+xml;profile=opds-catalog;kind=navigation"
+	opdsAcquisitionType = "application/atom+xml;profile=opds-catalog;kind=acquisition"
+)
+
+// OPDS shelves: books sorted by the key owner's reading progress.
+const (
+	ShelfReading  = "reading"  // started, not finished
+	ShelfToRead   = "to-read"  // not started
+	ShelfFinished = "finished" // at models.FinishedPercent or more
+)
+
+// opdsShelves are the shelves in the order the root feed lists them, with their titles.
+var opdsShelves = []struct{ Name, Title string }{
+	{ShelfToRead, "To read"},
+	{ShelfReading, "Reading"},
+	{ShelfFinished, "Finished"},
+}
+
+type opdsFeed struct {
+	XMLName xml.Name    `xml:"feed"`
+	Xmlns   string      `xml:"xmlns,attr"`
+	XmlnsDC string      `xml:"xmlns:dc,attr"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Links   []opdsLink  `xml:"link"`
+	Entries []opdsEntry `xml:"entry"`
+}
+
+type opdsLink struct {
+	Rel  string `xml:"rel,attr"`
+	Href string `xml:"href,attr"`
+	Type string `xml:"type,attr"`
+}
+
+type opdsEntry struct {
+	Title      string         `xml:"title"`
+	ID         string         `xml:"id"`
+	Updated    string         `xml:"updated"`
+	Authors    []opdsAuthor   `xml:"author"`
+	Publisher  string         `xml:"dc:publisher,omitempty"`
+	Issued     string         `xml:"dc:issued,omitempty"`
+	Identifier string         `xml:"dc:identifier,omitempty"`
+	Categories []opdsCategory `xml:"category"`
+	Summary    string         `xml:"summary,omitempty"`
+	Content    *opdsContent   `xml:"content"`
+	Links      []opdsLink     `xml:"link"`
+}
+
+type opdsAuthor struct {
+	Name string `xml:"name"`
+}
+
+type opdsCategory struct {
+	Term  string `xml:"term,attr"`
+	Label string `xml:"label,attr"`
+}
+
+type opdsContent struct {
+	Type string `xml:"type,attr"`
+	Text string `xml:",chardata"`
+}
+
+// opdsBase is the root of the feeds for the key in the request's URL.
+func opdsBase(r *http.Request) string {
+	return "/api/opds/" + chi.URLParam(r, "key")
+}
+
+// OPDSRoot is the navigation feed e-readers subscribe to: all books, the shelves and the tags.
+// GET /api/opds/:key (an API key with the feeds scope)
+func (h *BooksHandler) OPDSRoot(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	base := opdsBase(r)
+	now := h.Clock.Now()
+	feed := newOPDSFeed(base, "", "urn:books:opds", "Library", now, opdsNavigationType)
+	feed.Entries = append(feed.Entries, navigationEntry(base+"/all", "urn:books:opds:all", "All books", "Every book, newest first", now, opdsAcquisitionType))
+	for _, s := range opdsShelves {
+		feed.Entries = append(feed.Entries, navigationEntry(base+"/shelves/"+s.Name, "urn:books:opds:"+url.PathEscape(collectionShelfPre+s.Name), s.Title, "", now, opdsAcquisitionType))
+	}
+	feed.Entries = append(feed.Entries, navigationEntry(base+"/tags", "urn:books:opds:tags", "Tags", "Books by category", now, opdsNavigationType))
+	writeOPDS(w, feed, opdsNavigationType)
+}
+
+// OPDSTags lists the categories of the books the key's owner can see, each linking to its feed.
+// GET /api/opds/:key/tags
+func (h *BooksHandler) OPDSTags(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	userID, _ := middleware.UserIDFromContext(r.Context())
+	books, err := libraryBooks(r.Context(), h.DB, userID)
+	if err != nil {
+		http.Error(w, `{"error":"failed to list books"}`, http.StatusInternalServerError)
+		return
+	}
+	// Tags differing only in case are one tag, spelled as on the newest book.
+	counts := map[string]int{}
+	var tags []string
+	for _, b := range books {
+		for _, c := range bookTags(&b) {
+			key := strings.ToLower(c)
+			if counts[key] == 0 {
+				tags = append(tags, c)
+			}
+			counts[key]++
+		}
+	}
+	slices.SortFunc(tags, func(a, b string) int { return strings.Compare(strings.ToLower(a), strings.ToLower(b)) })
+	base := opdsBase(r)
+	now := h.Clock.Now()
+	feed := newOPDSFeed(base, "/tags", "urn:books:opds:tags", "Tags", now, opdsNavigationType)
+	for _, t := range tags {
+		feed.Entries = append(feed.Entries, navigationEntry(base+"/tags/"+url.PathEscape(t), "urn:books:opds:"+url.PathEscape(collectionTagPre+strings.ToLower(t)), t, strconv.Itoa(counts[strings.ToLower(t)])+" books", now, opdsAcquisitionType))
+	}
+	writeOPDS(w, feed, opdsNavigationType)
+}
+
+// OPDSAll is the acquisition feed of every book the key's owner can see, newest first. GET /api/opds/:key/all
+func (h *BooksHandler) OPDSAll(w http.ResponseWriter, r *http.Request) {
+	h.opdsCollection(w, r, "/all", collectionAll)
+}
+
+// OPDSTag is the acquisition feed of the books in one category (matched case-insensitively), newest first.
+// GET /api/opds/:key/tags/:tag
+func (h *BooksHandler) OPDSTag(w http.ResponseWriter, r *http.Request) {
+	tag := chi.URLParam(r, "tag")
+	if t, err := url.PathUnescape(tag); err == nil {
+		tag = t
+	}
+	h.opdsCollection(w, r, "/tags/"+url.PathEscape(tag), collectionTagPre+tag)
+}
+
+// OPDSShelf is the acquisition feed of one of the key owner's shelves (ShelfToRead, ShelfReading, ShelfFinished):
+// to-read newest first, the others most recently read first. GET /api/opds/:key/shelves/:shelf
+func (h *BooksHandler) OPDSShelf(w http.ResponseWriter, r *http.Request) {
+	shelf := chi.URLParam(r, "shelf")
+	h.opdsCollection(w, r, "/shelves/"+shelf, collectionShelfPre+shelf)
+}
+
+// opdsCollection writes the acquisition feed at path of the named collection (see collectionBooks).
+func (h *BooksHandler) opdsCollection(w http.ResponseWriter, r *http.Request, path, name string) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	userID, _ := middleware.UserIDFromContext(r.Context())
+	title, books, err := h.collectionBooks(r.Context(), userID, name)
+	if errors.Is(err, errNoCollection) {
+		http.Error(w, `{"error":"collection not found"}`, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, `{"error":"failed to list books"}`, http.StatusInternalServerError)
+		return
+	}
+	h.writeAcquisition(w, r, path, "urn:books:opds:"+url.PathEscape(strings.ToLower(name)), title, books)
+}
+
+// OPDSFile streams a book's file to an e-reader, like Download in stream mode. Archived books answer 202 while
+// they are restored. GET /api/opds/:key/books/:id/file
+func (h *BooksHandler) OPDSFile(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	book, ok := h.visibleBook(w, r)
+	if !ok {
+		return
+	}
+	if h.Storage == nil {
+		http.Error(w, `{"error":"download not configured"}`, http.StatusServiceUnavailable)
+		return
+	}
+	if !h.restoreArchived(w, r, book) {
+		return
+	}
+	if r.Method == http.MethodGet {
+		userID, _ := middleware.UserIDFromContext(r.Context())
+		now := h.Clock.Now()
+		link := &models.DownloadLink{
+			BookID:    book.ID,
+			UserID:    userID,
+			Email:     middleware.EmailFromContext(r.Context()),
+			Role:      middleware.RoleFromContext(r.Context()),
+			Kind:      models.DownloadLinkFeed,
+			IP:        clientIP(r),
+			IssuedAt:  now,
+			ExpiresAt: now,
+			Bytes:     book.SizeBytes,
+		}
+		if err := h.recordDownloadLink(r.Context(), link); err != nil {
+			http.Error(w, `{"error":"failed to record download"}`, http.StatusInternalServerError)
+			return
+		}
+	}
+	h.streamBook(w, r, book)
+}
+
+func (h *BooksHandler) writeAcquisition(w http.ResponseWriter, r *http.Request, path, id, title string, books []models.Book) {
+	base := opdsBase(r)
+	updated := h.Clock.Now()
+	if len(books) > 0 && !books[0].CreatedAt.IsZero() {
+		updated = books[0].CreatedAt
+	}
+	feed := newOPDSFeed(base, path, id, title, updated, opdsAcquisitionType)
+	for i := range books {
+		feed.Entries = append(feed.Entries, h.acquisitionEntry(base, &books[i]))
+	}
+	writeOPDS(w, feed, opdsAcquisitionType)
+}
+
+func (h *BooksHandler) acquisitionEntry(base string, b *models.Book) opdsEntry {
+	setCoverURLIfExtracted(b)
+	e := opdsEntry{
+		Title:     b.Title,
+		ID:        "urn:books:book:" + b.ID.Hex(),
+		Updated:   b.CreatedAt.UTC().Format(time.RFC3339),
+		Publisher: b.Publisher,
+		Issued:    b.PublishDate,
+		Summary:   b.Preface,
+	}
+	if b.ISBN != "" {
+		e.Identifier = "urn:isbn:" + b.ISBN
+	}
+	for _, a := range b.Authors {
+		e.Authors = append(e.Authors, opdsAuthor{Name: a})
+	}
+	for _, t := range bookTags(b) {
+		e.Categories = append(e.Categories, opdsCategory{Term: t, Label: t})
+	}
+	e.Links = append(e.Links, opdsLink{Rel: "http://opds-spec.org/acquisition", Href: base + "/books/" + b.ID.Hex() + "/file", Type: bookContentType(b.Format)})
+	if b.CoverURL != "" {
+		e.Links = append(e.Links, opdsLink{Rel: "http://opds-spec.org/image", Href: b.CoverURL, Type: "image/jpeg"})
+	}
+	if b.ThumbnailURL != "" {
+		e.Links = append(e.Links, opdsLink{Rel: "http://opds-spec.org/image/thumbnail", Href: b.ThumbnailURL, Type: "image/jpeg"})
+	}
+	return e
+}
+
+// newOPDSFeed starts the feed at base+path, linking back to the root feed at base.
+func newOPDSFeed(base, path, id, title string, updated time.Time, kind string) *opdsFeed {
+	return &opdsFeed{
+		Xmlns:   "http://www.w3.org/2005/Atom",
+		XmlnsDC: "http://purl.org/dc/terms/",
+		ID:      id,
+		Title:   title,
+		Updated: updated.UTC().Format(time.RFC3339),
+		Links: []opdsLink{
+			{Rel: "self", Href: base + path, Type: kind},
+			{Rel: "start", Href: base, Type: opdsNavigationType},
+		},
+	}
+}
+
+func navigationEntry(href, id, title, content string, updated time.Time, kind string) opdsEntry {
+	e := opdsEntry{Title: title, ID: id, Updated: updated.UTC().Format(time.RFC3339), Links: []opdsLink{{Rel: "subsection", Href: href, Type: kind}}}
+	if content != "" {
+		e.Content = &opdsContent{Type: "text", Text: content}
+	}
+	return e
+}
+
+func writeOPDS(w http.ResponseWriter, feed *opdsFeed, kind string) {
+	w.Header().Set("Content-Type", kind+";charset=utf-8")
+	w.Header().Set("Cache-Control", "private, no-cache")
+	w.Write([]byte(xml.Header))
+	enc := xml.NewEncoder(w)
+	enc.Encode(feed)
+}
@@ -0,0 +1,180 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/kevinaaaquil/books/backend/models"
+	"github.com/kevinaaaquil/books/backend/systemmail"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	passwordResetTTL = time.Hour
+	inviteTTL        = 7 * 24 * time.Hour
+)
+
+// passwordClaims are carried by password reset and invite links. They are signed with a key derived from
+// JWT_SECRET, so they can't be used to log in, and bound to the password hash at the time they were issued,
+// so a link stops working once the password has been changed.
+type passwordClaims struct {
+	UserID   string `json:"userId"`
+	Password string `json:"pwd"`
+	jwt.RegisteredClaims
+}
+
+func passwordTokenKey(jwtSecret string) []byte {
+	return []byte("password-link:" + jwtSecret)
+}
+
+// passwordFingerprint identifies a password hash without revealing it.
+func passwordFingerprint(hash string) string {
+	sum := sha256.Sum256([]byte(hash))
+	return base64.RawURLEncoding.EncodeToString(sum[:12])
+}
+
+func newPasswordToken(jwtSecret string, now time.Time, user *models.User, ttl time.Duration) (string, error) {
+	claims := &passwordClaims{
+		UserID:   user.ID.Hex(),
+		Password: passwordFingerprint(user.Password),
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(passwordTokenKey(jwtSecret))
+}
+
+var errInvalidPasswordToken = errors.New("invalid or expired link")
+
+func parsePasswordToken(jwtSecret string, now time.Time, token string) (*passwordClaims, primitive.ObjectID, error) {
+	claims := &passwordClaims{}
+	t, err := jwt.ParseWithClaims(token, claims, func(t *jwt.Token) (interface{}, error) {
+		return passwordTokenKey(jwtSecret), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Name}), jwt.WithTimeFunc(func() time.Time { return now }))
+	if err != nil || !t.Valid {
+		return nil, primitive.NilObjectID, errInvalidPasswordToken
+	}
+	id, err := primitive.ObjectIDFromHex(claims.UserID)
+	if err != nil {
+		return nil, primitive.NilObjectID, errInvalidPasswordToken
+	}
+	return claims, id, nil
+}
+
+// passwordLink is the frontend page that consumes a password token.
+func passwordLink(mail *systemmail.Service, token string, invite bool) string {
+	link := "/reset-password?token=" + url.QueryEscape(token)
+	if invite {
+		link += "&invite=1"
+	}
+	return mail.URL(link)
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+// ForgotPassword emails a password reset link. POST /api/auth/forgot-password. Responds the same whether or not
+// the account exists, so it can't be used to discover accounts. 503 when system mail is not configured.
+func (h *AuthHandler) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if h.SystemMail == nil {
+		http.Error(w, `{"error":"password reset by email is not configured"}`, http.StatusServiceUnavailable)
+		return
+	}
+	var req ForgotPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error":"invalid json"}`, http.StatusBadRequest)
+		return
+	}
+	email := strings.TrimSpace(strings.ToLower(req.Email))
+	if email == "" {
+		http.Error(w, `{"error":"email required"}`, http.StatusBadRequest)
+		return
+	}
+	user, err := h.DB.UserByEmail(r.Context(), email)
+	if err != nil {
+		http.Error(w, `{"error":"failed to reset password"}`, http.StatusInternalServerError)
+		return
+	}
+	// Guests share one passwordless login; there is nothing to reset.
+	if user != nil && user.Role != models.RoleGuest {
+		token, err := newPasswordToken(h.JWTSecret, h.Clock.Now(), user, passwordResetTTL)
+		if err != nil {
+			http.Error(w, `{"error":"failed to reset password"}`, http.StatusInternalServerError)
+			return
+		}
+		// A failed send is recorded in the system email log; the response stays the same.
+		h.SystemMail.Send(r.Context(), user.Email, systemmail.PasswordReset, systemmail.Data{
+			Link:    passwordLink(h.SystemMail, token, false),
+			Expires: "1 hour",
+		})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "if an account exists for that email, a reset link has been sent"})
+}
+
+// ResetPassword sets a new password using the token from a reset or invite link. POST /api/auth/reset-password.
+func (h *AuthHandler) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req ResetPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error":"invalid json"}`, http.StatusBadRequest)
+		return
+	}
+	if req.Token == "" || req.Password == "" {
+		http.Error(w, `{"error":"token and password required"}`, http.StatusBadRequest)
+		return
+	}
+	claims, userID, err := parsePasswordToken(h.JWTSecret, h.Clock.Now(), req.Token)
+	if err != nil {
+		http.Error(w, `{"error":"invalid or expired link"}`, http.StatusBadRequest)
+		return
+	}
+	user, err := h.DB.UserByID(r.Context(), userID)
+	if err != nil {
+		http.Error(w, `{"error":"failed to reset password"}`, http.StatusInternalServerError)
+		return
+	}
+	if user == nil || passwordFingerprint(user.Password) != claims.Password {
+		http.Error(w, `{"error":"invalid or expired link"}`, http.StatusBadRequest)
+		return
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		http.Error(w, `{"error":"failed to reset password"}`, http.StatusInternalServerError)
+		return
+	}
+	hashed := string(hash)
+	if err := h.DB.UpdateUser(r.Context(), userID, nil, &hashed, nil); err != nil {
+		http.Error(w, `{"error":"failed to reset password"}`, http.StatusInternalServerError)
+		return
+	}
+	// Whoever knew the old password may still be signed in.
+	if err := h.DB.RevokeUserRefreshTokens(r.Context(), userID, h.Clock.Now()); err != nil {
+		log.Printf("reset password: sign out %s: %v", user.Email, err)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "password updated", "email": user.Email})
+}
@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/kevinaaaquil/books/backend/utils"
+)
+
+// PreviewResponse is a sanitized excerpt from the start of a book.
+type PreviewResponse struct {
+	BookID    string `json:"bookId"`
+	Chapter   string `json:"chapter,omitempty"`
+	HTML      string `json:"html"` // basic formatting elements only, safe to render
+	Text      string `json:"text"`
+	Words     int    `json:"words"`
+	Truncated bool   `json:"truncated"`
+}
+
+// Preview returns the start of an EPUB's first chapter, up to h.PreviewWords words, so readers (guests included)
+// can sample a book without downloading it. GET /api/books/:id/preview. 404 for PDFs and when previews are off; an
+// archived book answers 202 with a RestoreResponse, as Download does, until it is restored.
+func (h *BooksHandler) Preview(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	book, ok := h.visibleBook(w, r)
+	if !ok {
+		return
+	}
+	if h.PreviewWords <= 0 || h.Storage == nil {
+		http.Error(w, `{"error":"previews are not available"}`, http.StatusNotFound)
+		return
+	}
+	if book.Format != "epub" {
+		http.Error(w, `{"error":"previews are only available for EPUB books"}`, http.StatusNotFound)
+		return
+	}
+	if !h.restoreArchived(w, r, book) {
+		return
+	}
+	body, _, err := h.Storage.GetObject(r.Context(), book.S3Key)
+	if storageUnavailable(w, err) {
+		return
+	}
+	if err != nil {
+		http.Error(w, `{"error":"failed to load book file"}`, http.StatusInternalServerError)
+		return
+	}
+	defer body.Close()
+	data, err := io.ReadAll(body)
+	if err != nil {
+		http.Error(w, `{"error":"failed to load book file"}`, http.StatusInternalServerError)
+		return
+	}
+	p, err := utils.EPUBPreview(data, h.PreviewWords)
+	if err != nil {
+		log.Printf("preview %s: %v", book.ID.Hex(), err)
+		http.Error(w, `{"error":"no preview available for this book"}`, http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(PreviewResponse{
+		BookID:    book.ID.Hex(),
+		Chapter:   p.Chapter,
+		HTML:      p.HTML,
+		Text:      p.Text,
+		Words:     p.Words,
+		Truncated: p.Truncated,
+	})
+}
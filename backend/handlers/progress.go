@@ -0,0 +1,185 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/kevinaaaquil/books/backend/middleware"
+	"github.com/kevinaaaquil/books/backend/models"
+	"github.com/kevinaaaquil/books/backend/service"
+	"github.com/kevinaaaquil/books/backend/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ProgressHandler records where users are in books and hands the position off between devices.
+type ProgressHandler struct {
+	DB     store.Store
+	Clock  service.Clock
+	AppURL string // frontend base URL, for continue links
+}
+
+// SaveProgressRequest reports a position. Set Anchor from the web reader, KindleLocation from a Kindle, or both.
+type SaveProgressRequest struct {
+	Anchor         string  `json:"anchor"`
+	KindleLocation int     `json:"kindleLocation"`
+	Percent        float64 `json:"percent"`
+	Device         string  `json:"device"`
+}
+
+// ContinueResponse is the user's latest position in a book with links to resume it.
+type ContinueResponse struct {
+	models.ReadingProgress
+	WebURL string `json:"webUrl"` // opens the book in the web app at Anchor
+}
+
+// ReadingBook is a book the user is partway through, with where they are in it.
+type ReadingBook struct {
+	Book     models.Book      `json:"book"`
+	Progress ContinueResponse `json:"progress"`
+}
+
+type ReadingResponse struct {
+	Books []ReadingBook `json:"books"`
+}
+
+// progressBook loads the book named in the URL, writing the error response when there is none.
+func (h *ProgressHandler) progressBook(w http.ResponseWriter, r *http.Request) (*models.Book, bool) {
+	id, err := primitive.ObjectIDFromHex(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, `{"error":"invalid book id"}`, http.StatusBadRequest)
+		return nil, false
+	}
+	book, err := h.DB.BookByID(r.Context(), id)
+	if err != nil {
+		http.Error(w, `{"error":"book not found"}`, http.StatusNotFound)
+		return nil, false
+	}
+	return book, true
+}
+
+// Save records the current user's position in a book, replacing the previous one. PUT /api/books/:id/progress
+func (h *ProgressHandler) Save(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+	var req SaveProgressRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error":"invalid json"}`, http.StatusBadRequest)
+		return
+	}
+	req.Anchor = strings.TrimSpace(req.Anchor)
+	if req.Anchor == "" && req.KindleLocation <= 0 {
+		http.Error(w, `{"error":"anchor or kindleLocation is required"}`, http.StatusBadRequest)
+		return
+	}
+	if req.Percent < 0 || req.Percent > 100 || req.KindleLocation < 0 {
+		http.Error(w, `{"error":"percent must be 0-100 and kindleLocation positive"}`, http.StatusBadRequest)
+		return
+	}
+	book, ok := h.progressBook(w, r)
+	if !ok {
+		return
+	}
+	p := &models.ReadingProgress{
+		UserID:         userID,
+		BookID:         book.ID,
+		Anchor:         req.Anchor,
+		KindleLocation: req.KindleLocation,
+		Percent:        req.Percent,
+		Device:         strings.TrimSpace(req.Device),
+		UpdatedAt:      h.Clock.Now(),
+	}
+	if err := h.DB.UpsertReadingProgress(r.Context(), p); err != nil {
+		http.Error(w, `{"error":"failed to save progress"}`, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.continueResponse(p))
+}
+
+// Continue returns the current user's latest position in a book, for "continue on another device".
+// GET /api/books/:id/progress and GET /api/books/:id/continue. 404 if they have no recorded progress.
+func (h *ProgressHandler) Continue(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+	book, ok := h.progressBook(w, r)
+	if !ok {
+		return
+	}
+	p, err := h.DB.ReadingProgressFor(r.Context(), userID, book.ID)
+	if err != nil {
+		http.Error(w, `{"error":"failed to load progress"}`, http.StatusInternalServerError)
+		return
+	}
+	if p == nil {
+		http.Error(w, `{"error":"no reading progress for this book"}`, http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.continueResponse(p))
+}
+
+// Reading lists the books the current user has started but not finished, most recently read first, for a
+// "Continue reading" row. Books they may no longer see (deleted, or above their maximum content rating) are left
+// out. GET /api/me/reading
+func (h *ProgressHandler) Reading(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+	user, err := h.DB.UserByID(r.Context(), userID)
+	if err != nil || user == nil {
+		http.Error(w, `{"error":"failed to load user"}`, http.StatusInternalServerError)
+		return
+	}
+	progress, err := h.DB.ReadingProgressByUser(r.Context(), userID)
+	if err != nil {
+		http.Error(w, `{"error":"failed to load progress"}`, http.StatusInternalServerError)
+		return
+	}
+	resp := ReadingResponse{Books: []ReadingBook{}}
+	for i := range progress {
+		p := &progress[i]
+		if p.Percent >= models.FinishedPercent {
+			continue
+		}
+		book, err := h.DB.BookByID(r.Context(), p.BookID)
+		if err != nil || !contentRatingAllowed(user.MaxContentRating, book) {
+			continue // deleted since, or not for this user
+		}
+		setCoverURLIfExtracted(book)
+		setContentRating(book)
+		resp.Books = append(resp.Books, ReadingBook{Book: *book, Progress: h.continueResponse(p)})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+func (h *ProgressHandler) continueResponse(p *models.ReadingProgress) ContinueResponse {
+	link := strings.TrimSuffix(h.AppURL, "/") + "/books/" + p.BookID.Hex()
+	if p.Anchor != "" {
+		link += "?at=" + url.QueryEscape(p.Anchor)
+	}
+	return ContinueResponse{ReadingProgress: *p, WebURL: link}
+}
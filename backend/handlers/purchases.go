@@ -0,0 +1,246 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/kevinaaaquil/books/backend/middleware"
+	"github.com/kevinaaaquil/books/backend/models"
+	"github.com/kevinaaaquil/books/backend/service"
+	"github.com/kevinaaaquil/books/backend/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// PurchasesHandler serves books' purchase records (see models.Purchase). Only admins and a book's uploader can read
+// or change its record.
+type PurchasesHandler struct {
+	DB    store.Store
+	Clock service.Clock
+}
+
+// Limits on purchase record fields, in characters.
+const (
+	purchaseFieldMax = 200
+	licenseNotesMax  = 4000
+)
+
+// PurchaseRequest replaces a book's purchase record. Every field is optional.
+type PurchaseRequest struct {
+	Store        string  `json:"store"`
+	PurchasedOn  string  `json:"purchasedOn"` // YYYY-MM-DD
+	OrderID      string  `json:"orderId"`
+	Price        float64 `json:"price"`
+	Currency     string  `json:"currency"` // ISO 4217, e.g. "USD"; required with a price
+	LicenseNotes string  `json:"licenseNotes"`
+}
+
+// purchaseCSVHeader is the first row of the CSV export.
+var purchaseCSVHeader = []string{"book_id", "title", "authors", "isbn", "store", "purchased_on", "order_id", "price", "currency", "license_notes", "uploaded_by", "updated_by", "updated_at"}
+
+// Get returns the book's purchase record, 404 when it has none. GET /api/books/:id/purchase
+func (h *PurchasesHandler) Get(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	book, ok := h.ownedBook(w, r)
+	if !ok {
+		return
+	}
+	p, err := h.DB.BookPurchase(r.Context(), book.ID)
+	if err != nil {
+		http.Error(w, `{"error":"failed to load purchase record"}`, http.StatusInternalServerError)
+		return
+	}
+	if p == nil {
+		http.Error(w, `{"error":"no purchase record"}`, http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(p)
+}
+
+// Put creates or replaces the book's purchase record. PUT /api/books/:id/purchase
+func (h *PurchasesHandler) Put(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	book, ok := h.ownedBook(w, r)
+	if !ok {
+		return
+	}
+	var req PurchaseRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error":"invalid json"}`, http.StatusBadRequest)
+		return
+	}
+	p := &models.Purchase{
+		BookID:       book.ID,
+		Store:        strings.TrimSpace(req.Store),
+		PurchasedOn:  strings.TrimSpace(req.PurchasedOn),
+		OrderID:      strings.TrimSpace(req.OrderID),
+		Price:        req.Price,
+		Currency:     strings.ToUpper(strings.TrimSpace(req.Currency)),
+		LicenseNotes: strings.TrimSpace(req.LicenseNotes),
+		UpdatedBy:    middleware.EmailFromContext(r.Context()),
+		UpdatedAt:    h.Clock.Now(),
+	}
+	if msg := validatePurchase(p); msg != "" {
+		http.Error(w, `{"error":"`+msg+`"}`, http.StatusBadRequest)
+		return
+	}
+	if err := h.DB.SaveBookPurchase(r.Context(), p); err != nil {
+		http.Error(w, `{"error":"failed to save purchase record"}`, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(p)
+}
+
+func validatePurchase(p *models.Purchase) string {
+	if p.PurchasedOn != "" {
+		if _, err := time.Parse(time.DateOnly, p.PurchasedOn); err != nil {
+			return "purchasedOn must be a date such as 2024-01-31"
+		}
+	}
+	if p.Price < 0 || math.IsNaN(p.Price) || math.IsInf(p.Price, 0) {
+		return "price must not be negative"
+	}
+	if p.Currency != "" && (len(p.Currency) != 3 || strings.Trim(p.Currency, "ABCDEFGHIJKLMNOPQRSTUVWXYZ") != "") {
+		return "currency must be a three-letter code such as USD"
+	}
+	if p.Price > 0 && p.Currency == "" {
+		return "currency is required with a price"
+	}
+	for _, s := range []string{p.Store, p.OrderID} {
+		if len([]rune(s)) > purchaseFieldMax {
+			return "store and orderId must be at most " + strconv.Itoa(purchaseFieldMax) + " characters"
+		}
+	}
+	if len([]rune(p.LicenseNotes)) > licenseNotesMax {
+		return "licenseNotes must be at most " + strconv.Itoa(licenseNotesMax) + " characters"
+	}
+	return ""
+}
+
+// Delete removes the book's purchase record. DELETE /api/books/:id/purchase
+func (h *PurchasesHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	book, ok := h.ownedBook(w, r)
+	if !ok {
+		return
+	}
+	found, err := h.DB.DeleteBookPurchase(r.Context(), book.ID)
+	if err != nil {
+		http.Error(w, `{"error":"failed to delete purchase record"}`, http.StatusInternalServerError)
+		return
+	}
+	if !found {
+		http.Error(w, `{"error":"no purchase record"}`, http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Export returns purchase records as CSV, oldest purchase first: every record for admins, those of the books they
+// uploaded for others. Records of deleted books are left out. GET /api/purchases.csv
+func (h *PurchasesHandler) Export(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if _, ok := middleware.UserIDFromContext(r.Context()); !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+	purchases, err := h.DB.AllPurchases(r.Context())
+	if err != nil {
+		http.Error(w, `{"error":"failed to load purchase records"}`, http.StatusInternalServerError)
+		return
+	}
+	books, err := h.DB.AllBooks(r.Context())
+	if err != nil {
+		http.Error(w, `{"error":"failed to load books"}`, http.StatusInternalServerError)
+		return
+	}
+	byID := make(map[primitive.ObjectID]*models.Book, len(books))
+	for i := range books {
+		byID[books[i].ID] = &books[i]
+	}
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", service.ContentDisposition("purchases-"+h.Clock.Now().UTC().Format(time.DateOnly)+".csv"))
+	w.Header().Set("Cache-Control", "no-store")
+	cw := csv.NewWriter(w)
+	cw.Write(purchaseCSVHeader)
+	for _, p := range purchases {
+		book := byID[p.BookID]
+		if book == nil || !canSeePurchase(r, book) {
+			continue
+		}
+		price := ""
+		if p.Price > 0 {
+			price = strconv.FormatFloat(p.Price, 'f', 2, 64)
+		}
+		row := []string{p.BookID.Hex(), book.Title, strings.Join(book.Authors, "; "), book.ISBN, p.Store, p.PurchasedOn, p.OrderID, price, p.Currency, p.LicenseNotes, book.UploadedByEmail, p.UpdatedBy, p.UpdatedAt.UTC().Format(time.RFC3339)}
+		for i := range row {
+			row[i] = csvCell(row[i])
+		}
+		cw.Write(row)
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		log.Printf("purchases export: %v", err)
+	}
+}
+
+// csvCell keeps spreadsheets from evaluating s as a formula.
+func csvCell(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+// canSeePurchase reports whether the caller is an admin or uploaded book.
+func canSeePurchase(r *http.Request, book *models.Book) bool {
+	if middleware.RoleFromContext(r.Context()) == "admin" {
+		return true
+	}
+	email := middleware.EmailFromContext(r.Context())
+	return email != "" && strings.EqualFold(book.UploadedByEmail, email)
+}
+
+// ownedBook loads the :id book, answering 404 when it does not exist and 403 unless canSeePurchase.
+func (h *PurchasesHandler) ownedBook(w http.ResponseWriter, r *http.Request) (*models.Book, bool) {
+	if _, ok := middleware.UserIDFromContext(r.Context()); !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return nil, false
+	}
+	id, err := primitive.ObjectIDFromHex(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, `{"error":"invalid book id"}`, http.StatusBadRequest)
+		return nil, false
+	}
+	book, err := h.DB.BookByID(r.Context(), id)
+	if err != nil {
+		http.Error(w, `{"error":"book not found"}`, http.StatusNotFound)
+		return nil, false
+	}
+	if !canSeePurchase(r, book) {
+		http.Error(w, `{"error":"only admins and the book's uploader can see its purchase record"}`, http.StatusForbidden)
+		return nil, false
+	}
+	return book, true
+}
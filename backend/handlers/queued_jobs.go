@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/kevinaaaquil/books/backend/middleware"
+	"github.com/kevinaaaquil/books/backend/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// QueuedJobsHandler reports on the background work users started, such as sends.
+type QueuedJobsHandler struct {
+	DB store.Store
+}
+
+// Get returns one of the user's queued jobs: its status ("queued", "running", "succeeded" or "failed"), the
+// attempts made, when a queued one runs next, the last attempt's error and, once it succeeded, its result.
+// Other users' jobs are 404. Finished jobs are kept for a week. GET /api/jobs/{id}
+func (h *QueuedJobsHandler) Get(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+	id, err := primitive.ObjectIDFromHex(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, `{"error":"invalid job id"}`, http.StatusBadRequest)
+		return
+	}
+	job, err := h.DB.QueuedJobByID(r.Context(), id)
+	if err != nil {
+		http.Error(w, `{"error":"failed to load job"}`, http.StatusInternalServerError)
+		return
+	}
+	if job == nil || job.UserID != userID {
+		http.Error(w, `{"error":"job not found"}`, http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(job)
+}
@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/kevinaaaquil/books/backend/jobs"
+	"github.com/kevinaaaquil/books/backend/middleware"
+	"github.com/kevinaaaquil/books/backend/models"
+	"github.com/kevinaaaquil/books/backend/service"
+	"github.com/kevinaaaquil/books/backend/store"
+)
+
+// RecommendationsHandler serves the recommendations the recommendations job computes from reading history.
+type RecommendationsHandler struct {
+	DB    store.Store
+	Clock service.Clock
+}
+
+// RecommendedBook is a suggested book with why it was suggested.
+type RecommendedBook struct {
+	Book   models.Book `json:"book"`
+	Score  float64     `json:"score"`
+	Reason string      `json:"reason"`
+}
+
+type RecommendationsResponse struct {
+	Items      []RecommendedBook `json:"items"`
+	ComputedAt time.Time         `json:"computedAt"`
+}
+
+// List returns the current user's recommendations, best first. They are computed nightly; a user with none yet
+// gets them computed now. Books the user may no longer see (deleted, or above their maximum content rating)
+// are left out. GET /api/me/recommendations
+func (h *RecommendationsHandler) List(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+	user, err := h.DB.UserByID(r.Context(), userID)
+	if err != nil || user == nil {
+		http.Error(w, `{"error":"failed to load user"}`, http.StatusInternalServerError)
+		return
+	}
+	recs, err := h.DB.RecommendationsFor(r.Context(), userID)
+	if err != nil {
+		http.Error(w, `{"error":"failed to load recommendations"}`, http.StatusInternalServerError)
+		return
+	}
+	if recs == nil {
+		rec, err := jobs.LoadRecommender(r.Context(), h.DB)
+		if err != nil {
+			http.Error(w, `{"error":"failed to compute recommendations"}`, http.StatusInternalServerError)
+			return
+		}
+		recs = &models.UserRecommendations{UserID: userID, Items: rec.For(userID), ComputedAt: h.Clock.Now()}
+		if err := h.DB.SaveRecommendations(r.Context(), recs); err != nil {
+			http.Error(w, `{"error":"failed to compute recommendations"}`, http.StatusInternalServerError)
+			return
+		}
+	}
+	resp := RecommendationsResponse{Items: []RecommendedBook{}, ComputedAt: recs.ComputedAt}
+	for _, item := range recs.Items {
+		book, err := h.DB.BookByID(r.Context(), item.BookID)
+		if err != nil || !contentRatingAllowed(user.MaxContentRating, book) {
+			continue // deleted since, or not for this user
+		}
+		setCoverURLIfExtracted(book)
+		setContentRating(book)
+		resp.Items = append(resp.Items, RecommendedBook{Book: *book, Score: item.Score, Reason: item.Reason})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/kevinaaaquil/books/backend/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// RefreshRequest is the body of POST /api/auth/refresh and POST /api/auth/logout.
+type RefreshRequest struct {
+	RefreshToken string `json:"refreshToken"`
+	All          bool   `json:"all,omitempty"` // logout: end every sign-in of the user, not just this one
+}
+
+func hashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// createRefreshToken stores a new refresh token of the sign-in family (a new one when family is zero) and returns
+// it. Expired tokens are cleared out on the way.
+func (h *AuthHandler) createRefreshToken(ctx context.Context, userID, family primitive.ObjectID, now time.Time) (string, error) {
+	random := make([]byte, 32)
+	if _, err := rand.Read(random); err != nil {
+		return "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(random)
+	t := &models.RefreshToken{ID: primitive.NewObjectID(), UserID: userID, Family: family, TokenHash: hashRefreshToken(token), CreatedAt: now, ExpiresAt: now.Add(h.RefreshTTL)}
+	if t.Family.IsZero() {
+		t.Family = t.ID
+	}
+	if err := h.DB.InsertRefreshToken(ctx, t); err != nil {
+		return "", err
+	}
+	if err := h.DB.DeleteExpiredRefreshTokens(ctx, now); err != nil {
+		log.Printf("auth: delete expired refresh tokens: %v", err)
+	}
+	return token, nil
+}
+
+// Refresh exchanges a refresh token for a new access token and refresh token of the same sign-in, with the user's
+// current role. Each refresh token works once: one used again must have been copied, so its whole sign-in is
+// revoked. 401 for unknown, expired and revoked tokens. POST /api/auth/refresh
+func (h *AuthHandler) Refresh(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req RefreshRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error":"invalid json"}`, http.StatusBadRequest)
+		return
+	}
+	if req.RefreshToken == "" {
+		http.Error(w, `{"error":"refreshToken required"}`, http.StatusBadRequest)
+		return
+	}
+	ctx, now := r.Context(), h.Clock.Now()
+	t, err := h.DB.RefreshTokenByHash(ctx, hashRefreshToken(req.RefreshToken))
+	if err != nil {
+		http.Error(w, `{"error":"failed to refresh"}`, http.StatusInternalServerError)
+		return
+	}
+	if t == nil || t.RevokedAt != nil || !now.Before(t.ExpiresAt) {
+		http.Error(w, `{"error":"invalid or expired refresh token"}`, http.StatusUnauthorized)
+		return
+	}
+	used, err := h.DB.UseRefreshToken(ctx, t.ID, now)
+	if err != nil {
+		http.Error(w, `{"error":"failed to refresh"}`, http.StatusInternalServerError)
+		return
+	}
+	if !used {
+		log.Printf("auth: refresh token of user %s used twice; revoking its sign-in", t.UserID.Hex())
+		if err := h.DB.RevokeRefreshTokenFamily(ctx, t.Family, now); err != nil {
+			log.Printf("auth: revoke sign-in %s: %v", t.Family.Hex(), err)
+		}
+		http.Error(w, `{"error":"refresh token already used; sign in again"}`, http.StatusUnauthorized)
+		return
+	}
+	user, err := h.DB.UserByID(ctx, t.UserID)
+	if err != nil {
+		http.Error(w, `{"error":"failed to refresh"}`, http.StatusInternalServerError)
+		return
+	}
+	if user == nil {
+		http.Error(w, `{"error":"invalid or expired refresh token"}`, http.StatusUnauthorized)
+		return
+	}
+	role := user.Role
+	if role == "" {
+		role = models.RoleViewer
+	}
+	res, err := h.signIn(ctx, user.ID, user.Email, role, t.Family)
+	if err != nil {
+		http.Error(w, `{"error":"could not create token"}`, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(res)
+}
+
+// Logout revokes the refresh token's sign-in, or with "all" every sign-in of its user (e.g. after losing a
+// device). Access tokens already issued keep working until they expire. Unknown tokens are ignored, so signing
+// out twice is fine. 204. POST /api/auth/logout
+func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req RefreshRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error":"invalid json"}`, http.StatusBadRequest)
+		return
+	}
+	if req.RefreshToken == "" {
+		http.Error(w, `{"error":"refreshToken required"}`, http.StatusBadRequest)
+		return
+	}
+	ctx, now := r.Context(), h.Clock.Now()
+	t, err := h.DB.RefreshTokenByHash(ctx, hashRefreshToken(req.RefreshToken))
+	if err == nil && t != nil {
+		if req.All {
+			err = h.DB.RevokeUserRefreshTokens(ctx, t.UserID, now)
+		} else {
+			err = h.DB.RevokeRefreshTokenFamily(ctx, t.Family, now)
+		}
+	}
+	if err != nil {
+		http.Error(w, `{"error":"failed to sign out"}`, http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
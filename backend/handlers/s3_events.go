@@ -0,0 +1,224 @@
+package handlers
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/kevinaaaquil/books/backend/models"
+	"github.com/kevinaaaquil/books/backend/service"
+	"github.com/kevinaaaquil/books/backend/store"
+)
+
+// S3EventsSignatureHeader carries the hex HMAC-SHA256 of a webhook body, keyed with S3_EVENTS_SECRET, as
+// "sha256=<hex>".
+const S3EventsSignatureHeader = "X-Books-Signature"
+
+// S3EventsHandler adds EPUB and PDF files put into the bucket directly (aws s3 cp, the S3 console, rclone) under
+// Prefix to the library through the upload pipeline, when S3 event notifications report them: from SNS, or
+// posted by a webhook (a MinIO notification target, or a relay from SQS or EventBridge). Like the watch folder,
+// the dropped object is deleted once its book is in the library, as the book keeps its own copy.
+type S3EventsHandler struct {
+	DB         store.Store
+	Storage    service.ObjectStore
+	SNS        *service.SNSVerifier
+	Bucket     string // events about other buckets are ignored; "" = any
+	Prefix     string // keys outside it are ignored
+	Secret     string // for webhook deliveries: HMAC key for S3EventsSignatureHeader, or a bearer token; "" refuses them
+	TopicARN   string // the SNS topic deliveries must come from; "" refuses SNS
+	UploadedBy string
+	MaxBytes   int64                                                    // larger files are not added; 0 = no limit
+	Ingest     func(context.Context, IngestFile) (*IngestResult, error) // UploadHandler.Ingest
+}
+
+// s3Event is an S3 event notification; s3:TestEvent messages have no records.
+type s3Event struct {
+	Records []struct {
+		EventName string `json:"eventName"`
+		S3        struct {
+			Bucket struct {
+				Name string `json:"name"`
+			} `json:"bucket"`
+			Object struct {
+				Key  string `json:"key"` // URL-encoded, with + for spaces
+				Size int64  `json:"size"`
+			} `json:"object"`
+		} `json:"s3"`
+	} `json:"Records"`
+}
+
+// S3EventsResponse says what became of the objects an event reported.
+type S3EventsResponse struct {
+	Added      int      `json:"added"`
+	Duplicates int      `json:"duplicates"` // already in the library (same SHA-256); the object is deleted
+	Ignored    int      `json:"ignored"`    // not an object creation, outside the prefix, not an EPUB or PDF, or gone
+	Failed     []string `json:"failed"`     // keys that could not be added; left in the bucket
+}
+
+// Events consumes an S3 event notification. SNS deliveries (with an x-amz-sns-message-type header) must carry a
+// valid SNS signature and come from TopicARN; subscription confirmations are confirmed. Other deliveries must be
+// signed with Secret in X-Books-Signature or carry it as a bearer token. Returns 200 with what was done, or 503
+// while storage is unavailable so the sender retries. POST /api/s3/events (public; authenticated by signature).
+func (h *S3EventsHandler) Events(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, `{"error":"failed to read body"}`, http.StatusBadRequest)
+		return
+	}
+	if kind := r.Header.Get("x-amz-sns-message-type"); kind != "" {
+		if h.TopicARN == "" {
+			http.Error(w, `{"error":"sns deliveries are not accepted"}`, http.StatusForbidden)
+			return
+		}
+		var m service.SNSMessage
+		if err := json.Unmarshal(body, &m); err != nil {
+			http.Error(w, `{"error":"invalid json"}`, http.StatusBadRequest)
+			return
+		}
+		if m.TopicArn != h.TopicARN {
+			http.Error(w, `{"error":"unknown topic"}`, http.StatusForbidden)
+			return
+		}
+		if err := h.SNS.Verify(r.Context(), &m); err != nil {
+			log.Printf("s3 events: sns: %v", err)
+			http.Error(w, `{"error":"invalid signature"}`, http.StatusUnauthorized)
+			return
+		}
+		switch m.Type {
+		case "SubscriptionConfirmation":
+			if err := h.SNS.ConfirmSubscription(r.Context(), &m); err != nil {
+				log.Printf("s3 events: %v", err)
+				http.Error(w, `{"error":"failed to confirm subscription"}`, http.StatusBadGateway)
+				return
+			}
+			log.Printf("s3 events: confirmed subscription to %s", m.TopicArn)
+			w.WriteHeader(http.StatusOK)
+			return
+		case "Notification":
+			body = []byte(m.Message)
+		default:
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+	} else if !h.signed(r, body) {
+		http.Error(w, `{"error":"invalid signature"}`, http.StatusUnauthorized)
+		return
+	}
+	var event s3Event
+	if err := json.Unmarshal(body, &event); err != nil {
+		http.Error(w, `{"error":"invalid event"}`, http.StatusBadRequest)
+		return
+	}
+	resp := S3EventsResponse{Failed: []string{}}
+	for _, rec := range event.Records {
+		key, err := url.QueryUnescape(rec.S3.Object.Key)
+		if err != nil || !strings.HasPrefix(rec.EventName, "ObjectCreated:") || h.Bucket != "" && rec.S3.Bucket.Name != h.Bucket ||
+			!strings.HasPrefix(key, h.Prefix) {
+			resp.Ignored++
+			continue
+		}
+		if !models.IsBookFile(key) {
+			resp.Ignored++
+			continue
+		}
+		outcome, err := h.addObject(r.Context(), key, rec.S3.Object.Size)
+		if storageUnavailable(w, err) {
+			return
+		}
+		switch {
+		case err != nil:
+			log.Printf("s3 events: %s: %v", key, err)
+			resp.Failed = append(resp.Failed, key)
+		case outcome == s3ObjectAdded:
+			resp.Added++
+		case outcome == s3ObjectDuplicate:
+			resp.Duplicates++
+		default:
+			resp.Ignored++
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// signed reports whether a webhook delivery carries Secret, as an HMAC of body or a bearer token.
+func (h *S3EventsHandler) signed(r *http.Request, body []byte) bool {
+	if h.Secret == "" {
+		return false
+	}
+	if sig, ok := strings.CutPrefix(r.Header.Get(S3EventsSignatureHeader), "sha256="); ok {
+		mac := hmac.New(sha256.New, []byte(h.Secret))
+		mac.Write(body)
+		want := hex.EncodeToString(mac.Sum(nil))
+		return hmac.Equal([]byte(strings.ToLower(sig)), []byte(want))
+	}
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && hmac.Equal([]byte(token), []byte(h.Secret))
+}
+
+type s3ObjectOutcome int
+
+const (
+	s3ObjectGone s3ObjectOutcome = iota // already dealt with, e.g. a repeated delivery
+	s3ObjectAdded
+	s3ObjectDuplicate
+)
+
+// addObject adds the object at key as a book, then deletes it.
+func (h *S3EventsHandler) addObject(ctx context.Context, key string, size int64) (s3ObjectOutcome, error) {
+	if h.MaxBytes > 0 && size > h.MaxBytes {
+		return 0, fmt.Errorf("file is %d bytes, over the upload limit of %d", size, h.MaxBytes)
+	}
+	body, _, err := h.Storage.GetObject(ctx, key)
+	if errors.Is(err, service.ErrObjectNotFound) {
+		return s3ObjectGone, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer body.Close()
+	var src io.Reader = body
+	if h.MaxBytes > 0 {
+		src = io.LimitReader(body, h.MaxBytes+1)
+	}
+	data, err := io.ReadAll(src)
+	if err != nil {
+		return 0, err
+	}
+	if h.MaxBytes > 0 && int64(len(data)) > h.MaxBytes {
+		return 0, fmt.Errorf("file is over the upload limit of %d bytes", h.MaxBytes)
+	}
+	sum := sha256.Sum256(data)
+	existing, err := h.DB.BookBySHA256(ctx, hex.EncodeToString(sum[:]))
+	if err != nil {
+		return 0, err
+	}
+	outcome := s3ObjectDuplicate
+	if existing == nil {
+		res, err := h.Ingest(ctx, IngestFile{Name: path.Base(key), Data: data, UploadedBy: h.UploadedBy, Source: models.SourceS3Event, SourcePath: "s3:" + key})
+		if err != nil {
+			return 0, err
+		}
+		log.Printf("s3 events: %s: added %q (%s)", key, res.Book.Title, res.Book.ID.Hex())
+		outcome = s3ObjectAdded
+	}
+	if err := h.Storage.Delete(ctx, key); err != nil {
+		// The book is in the library; a repeated delivery finds it a duplicate.
+		log.Printf("s3 events: %s: added but not deleted: %v", key, err)
+	}
+	return outcome, nil
+}
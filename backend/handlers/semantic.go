@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kevinaaaquil/books/backend/jobs"
+	"github.com/kevinaaaquil/books/backend/middleware"
+	"github.com/kevinaaaquil/books/backend/models"
+	"github.com/kevinaaaquil/books/backend/service"
+	"github.com/kevinaaaquil/books/backend/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// JobEmbed is the job queue kind that embeds a new book for semantic search (see RunEmbed).
+const JobEmbed = "embed"
+
+const (
+	semanticDefaultLimit = 20
+	semanticMaxLimit     = 100
+	semanticMaxQuery     = 1000 // characters
+	// vectorSyncSlack re-reads embeddings stored a little before the last one loaded, for instances whose clocks
+	// differ and writes that land out of order.
+	vectorSyncSlack = time.Minute
+)
+
+// SemanticHit is a book found by meaning (or like another, see Similar), with its similarity score (up to 1).
+type SemanticHit struct {
+	Book  models.Book `json:"book"`
+	Score float64     `json:"score"`
+}
+
+type SemanticResponse struct {
+	Query string        `json:"query"`
+	Books []SemanticHit `json:"books"`
+}
+
+// Semantic finds books by meaning rather than words ("cozy low-stakes fantasy"): the query is embedded and
+// compared with the embeddings of every book the user can see, best first. ?limit= (20, up to 100).
+// GET /api/search/semantic?q= (auth). 404 when EMBEDDINGS is not set.
+func (h *BooksHandler) Semantic(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if _, ok := middleware.UserIDFromContext(r.Context()); !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+	if h.Embedder == nil || h.Vectors == nil {
+		http.Error(w, `{"error":"semantic search is not enabled"}`, http.StatusNotFound)
+		return
+	}
+	q := strings.TrimSpace(r.URL.Query().Get("q"))
+	if q == "" {
+		http.Error(w, `{"error":"q is required"}`, http.StatusBadRequest)
+		return
+	}
+	if len(q) > semanticMaxQuery {
+		http.Error(w, fmt.Sprintf(`{"error":"q is longer than %d characters"}`, semanticMaxQuery), http.StatusBadRequest)
+		return
+	}
+	limit := semanticDefaultLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			http.Error(w, `{"error":"limit must be a positive number"}`, http.StatusBadRequest)
+			return
+		}
+		limit = min(n, semanticMaxLimit)
+	}
+	if err := h.syncVectors(r.Context()); err != nil {
+		http.Error(w, `{"error":"failed to load embeddings"}`, http.StatusInternalServerError)
+		return
+	}
+	vecs, err := h.Embedder.Embed(r.Context(), []string{q})
+	if err != nil {
+		log.Printf("semantic search: embed query: %v", err)
+		http.Error(w, `{"error":"failed to embed the query"}`, http.StatusBadGateway)
+		return
+	}
+	books, err := h.visibleBooks(r)
+	if err != nil {
+		http.Error(w, `{"error":"failed to list books"}`, http.StatusInternalServerError)
+		return
+	}
+	byID := make(map[primitive.ObjectID]models.Book, len(books))
+	for _, b := range books {
+		byID[b.ID] = b
+	}
+	resp := SemanticResponse{Query: q, Books: []SemanticHit{}}
+	// Every hit, then the first limit the user can see: deleted books and ones they can't see are skipped.
+	for _, hit := range h.Vectors.Search(vecs[0], 0, 0) {
+		b, ok := byID[hit.BookID]
+		if !ok {
+			continue
+		}
+		setCoverURLIfExtracted(&b)
+		setContentRating(&b)
+		resp.Books = append(resp.Books, SemanticHit{Book: b, Score: hit.Score})
+		if len(resp.Books) == limit {
+			break
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// syncVectors loads the embeddings stored since the last sync, by this instance or another, into h.Vectors.
+// Embeddings from another model than h.Embedder's are dropped.
+func (h *BooksHandler) syncVectors(ctx context.Context) error {
+	since := h.Vectors.Synced()
+	if !since.IsZero() {
+		since = since.Add(-vectorSyncSlack)
+	}
+	embeddings, err := h.DB.BookEmbeddingsSince(ctx, since)
+	if err != nil {
+		return err
+	}
+	model := h.Embedder.Model()
+	var latest time.Time
+	for _, e := range embeddings {
+		if e.Model == model {
+			h.Vectors.Put(e.BookID, e.Vector)
+		} else {
+			h.Vectors.Remove(e.BookID)
+		}
+		if e.UpdatedAt.After(latest) {
+			latest = e.UpdatedAt
+		}
+	}
+	h.Vectors.SyncedTo(latest)
+	return nil
+}
+
+// RunEmbed is the JobEmbed handler: it embeds the book in the "bookId" param.
+func (h *BooksHandler) RunEmbed(ctx context.Context, job *models.QueuedJob) (map[string]string, error) {
+	bookID, err := primitive.ObjectIDFromHex(job.Params["bookId"])
+	if err != nil {
+		return nil, jobs.Permanent(fmt.Errorf("invalid book id %q", job.Params["bookId"]))
+	}
+	if h.Embedder == nil {
+		return nil, jobs.Permanent(errors.New("semantic search is not enabled"))
+	}
+	book, err := h.DB.BookByID(ctx, bookID)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, jobs.Permanent(errors.New("the book was deleted"))
+	}
+	if err != nil {
+		return nil, err
+	}
+	embedded, err := jobs.EmbedBook(ctx, h.DB, h.Storage, h.Embedder, h.Vectors, book, false)
+	if service.IsPermanentEmbeddingsError(err) {
+		return nil, jobs.Permanent(err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return map[string]string{"bookId": bookID.Hex(), "embedded": strconv.FormatBool(embedded)}, nil
+}
@@ -0,0 +1,522 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/kevinaaaquil/books/backend/jobs"
+	"github.com/kevinaaaquil/books/backend/middleware"
+	"github.com/kevinaaaquil/books/backend/models"
+	"github.com/kevinaaaquil/books/backend/service"
+	"github.com/kevinaaaquil/books/backend/store"
+	"github.com/kevinaaaquil/books/backend/utils"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// SendErrorResponse is the body of Send's error responses that the client can act on. Codes:
+//   - KINDLE_CONFIG_REQUIRED, KINDLE_NOT_VERIFIED (400): the Kindle address is not set up or not yet confirmed
+//   - TARGET_NOT_CONFIRMED, SENDER_REQUIRED (400): the email target is unconfirmed, or the iCloud sender is missing
+//   - FORMAT_UNSUPPORTED (400): the device takes no format the book is in or can be converted to
+//   - FILE_TOO_LARGE (413): the file is over the device's attachment limit
+//   - FILE_ARCHIVED (409, with Retry-After): the file is archived; a restore was started, send again once it is done
+//   - SEND_LIMIT_REACHED (429, with Retry-After): the user's send limit is reached
+type SendErrorResponse struct {
+	Error string `json:"error"`
+	Code  string `json:"code"`
+}
+
+func writeSendError(w http.ResponseWriter, status int, code, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(SendErrorResponse{Error: msg, Code: code})
+}
+
+// SendRequest picks where Send delivers. Both fields are optional; with neither the book goes to the Kindle address.
+type SendRequest struct {
+	DeviceID string `json:"deviceId,omitempty"` // device profile: formats, attachment limit and destination
+	TargetID string `json:"targetId,omitempty"` // delivery target, when no device is given
+	// Optimize strips fonts and downscales images from EPUBs before sending: true always, false never, omitted
+	// when the device asks for it or the book is over its attachment limit.
+	Optimize *bool `json:"optimize,omitempty"`
+}
+
+// sendDestination is where Send delivers: by email (the Kindle address or an email target) or to a drive.
+type sendDestination struct {
+	name   string                 // for the response: "Kindle" or the target's name
+	target *models.DeliveryTarget // nil for the Kindle address
+	mail   *service.Mail          // From, To and credentials, for email destinations
+	upload func(ctx context.Context, name string, file io.Reader) error
+}
+
+// sendError is a send failure with its HTTP status and, for those the client can act on, a SendErrorResponse code.
+type sendError struct {
+	status int
+	code   string // "" = a plain {"error":...} response
+	msg    string
+}
+
+func (e *sendError) Error() string {
+	return e.msg
+}
+
+func (e *sendError) write(w http.ResponseWriter) {
+	if e.code != "" {
+		writeSendError(w, e.status, e.code, e.msg)
+		return
+	}
+	http.Error(w, `{"error":"`+e.msg+`"}`, e.status)
+}
+
+// kindleDestination checks the user's Kindle config is ready (and verified, when required).
+func (h *BooksHandler) kindleDestination(ctx context.Context, userID primitive.ObjectID) (*sendDestination, *sendError) {
+	cfg, err := h.DB.GetEmailConfig(ctx, userID)
+	if err != nil {
+		return nil, &sendError{status: http.StatusInternalServerError, msg: "failed to load Kindle config"}
+	}
+	if !kindleConfigReady(cfg, h.Mailer) {
+		return nil, &sendError{http.StatusBadRequest, "KINDLE_CONFIG_REQUIRED", "Kindle config required. Set up your Kindle email in Kindle setup."}
+	}
+	if h.RequireKindleVerification && !cfg.KindleVerified() {
+		return nil, &sendError{http.StatusBadRequest, "KINDLE_NOT_VERIFIED", "Kindle address not verified. Confirm it with the code from Kindle setup."}
+	}
+	mail, err := kindleSender(cfg, h.Mailer, h.EncKey)
+	if err != nil {
+		log.Printf("send: %v", err)
+		return nil, &sendError{status: http.StatusInternalServerError, msg: "failed to use Kindle config"}
+	}
+	return &sendDestination{name: "Kindle", mail: &mail}, nil
+}
+
+// targetDestination loads one of the user's delivery targets and what sending to it needs.
+func (h *BooksHandler) targetDestination(ctx context.Context, userID, targetID primitive.ObjectID) (*sendDestination, *sendError) {
+	target, err := h.DB.DeliveryTarget(ctx, userID, targetID)
+	if err != nil {
+		return nil, &sendError{status: http.StatusInternalServerError, msg: "failed to load target"}
+	}
+	if target == nil {
+		return nil, &sendError{status: http.StatusNotFound, msg: "target not found"}
+	}
+	if !target.Ready() {
+		return nil, &sendError{http.StatusBadRequest, "TARGET_NOT_CONFIRMED", "This address has not been confirmed yet. Enter the code that was emailed to it."}
+	}
+	dest := &sendDestination{name: target.Name, target: target}
+	if target.Kind == models.TargetEmail {
+		cfg, err := h.DB.GetEmailConfig(ctx, userID)
+		if err != nil {
+			return nil, &sendError{status: http.StatusInternalServerError, msg: "failed to load Kindle config"}
+		}
+		if !senderReady(cfg, h.Mailer) {
+			return nil, &sendError{http.StatusBadRequest, "SENDER_REQUIRED", "Sending email needs your iCloud sender account. Set it up in Kindle setup."}
+		}
+		mail, err := userSender(cfg, h.Mailer, h.EncKey)
+		if err != nil {
+			log.Printf("send: %v", err)
+			return nil, &sendError{status: http.StatusInternalServerError, msg: "failed to use Kindle config"}
+		}
+		mail.To = target.Email
+		dest.mail = &mail
+		return dest, nil
+	}
+	drive, ok := h.Drives[target.Kind]
+	if !ok {
+		return nil, &sendError{status: http.StatusServiceUnavailable, msg: target.Kind + " is not configured on this server"}
+	}
+	token := target.RefreshToken
+	if len(h.EncKey) == 32 {
+		if token, err = utils.Decrypt(token, h.EncKey); err != nil {
+			log.Printf("send: decrypt %s token: %v", target.Kind, err)
+			return nil, &sendError{status: http.StatusInternalServerError, msg: "failed to use linked account"}
+		}
+	}
+	dest.upload = func(ctx context.Context, name string, file io.Reader) error {
+		return drive.Upload(ctx, token, target.Folder, name, file)
+	}
+	return dest, nil
+}
+
+// destination is the Kindle address when targetID is zero, else the target.
+func (h *BooksHandler) destination(ctx context.Context, userID, targetID primitive.ObjectID) (*sendDestination, *sendError) {
+	if targetID.IsZero() {
+		return h.kindleDestination(ctx, userID)
+	}
+	return h.targetDestination(ctx, userID, targetID)
+}
+
+// deliver sends file, a book in format named name, to d. Mail attachments get an ASCII name (see
+// utils.ASCIIFilename) and the format's content type.
+func (d *sendDestination) deliver(ctx context.Context, mailer service.Mailer, title, name, format string, file io.Reader) error {
+	if d.upload != nil {
+		return d.upload(ctx, name, file)
+	}
+	name = utils.ASCIIFilename(name, format)
+	mail := *d.mail
+	mail.Subject = title
+	mail.Body = "Sent from Books. Attachment: " + name
+	mail.AttachmentName, mail.AttachmentType, mail.Attachment = name, bookContentType(format), file
+	return mailer.Send(ctx, &mail)
+}
+
+// reflowable reports whether format's text reflows to fit the screen; converting a PDF's fixed pages to one of
+// these loses its layout.
+func reflowable(format string) bool {
+	return format == "epub" || format == "mobi" || format == "azw3"
+}
+
+// chooseFormat returns the format to send a book stored as from, honoring the order of formats (the device's,
+// preferred first): the first that is from, or that h.Converter can produce from it without losing a layout.
+// Failing that it is from when formats is empty, otherwise the first of formats h.Converter can produce.
+func (h *BooksHandler) chooseFormat(from string, formats []string) (string, bool) {
+	if len(formats) == 0 {
+		return from, true
+	}
+	for _, f := range formats {
+		if f == from || (h.Converter != nil && reflowable(from) && reflowable(f) && h.Converter.CanConvert(from, f)) {
+			return f, true
+		}
+	}
+	if h.Converter != nil {
+		for _, f := range formats {
+			if h.Converter.CanConvert(from, f) {
+				return f, true
+			}
+		}
+	}
+	return "", false
+}
+
+// overLimit reports whether size bytes exceeds maxMB (0 = no limit). Unknown sizes (0) pass.
+func overLimit(size int64, maxMB int) bool {
+	return maxMB > 0 && size > int64(maxMB)<<20
+}
+
+// optimizedKey is where the optimized copy of a book is cached. It includes the image limit, so changing the
+// setting builds new copies.
+func optimizedKey(bookID primitive.ObjectID, maxImagePx int) string {
+	return fmt.Sprintf("optimized/%s-%d.epub", bookID.Hex(), maxImagePx)
+}
+
+// optimizedEPUB returns the book with embedded fonts removed and images downscaled (see utils.OptimizeEPUB), and
+// its size. The first send builds it from the stored file and caches it in storage for later ones.
+func (h *BooksHandler) optimizedEPUB(ctx context.Context, book *models.Book) (io.ReadCloser, int64, error) {
+	key := optimizedKey(book.ID, h.OptimizeMaxImagePx)
+	if info, err := h.Storage.HeadObject(ctx, key); err == nil {
+		body, _, err := h.Storage.GetObject(ctx, key)
+		return body, info.Size, err
+	}
+	body, err := h.openBookFile(ctx, book)
+	if err != nil {
+		return nil, 0, err
+	}
+	data, err := io.ReadAll(body)
+	body.Close()
+	if err != nil {
+		return nil, 0, err
+	}
+	out, err := utils.OptimizeEPUB(data, utils.OptimizeOptions{StripFonts: true, MaxImagePixels: h.OptimizeMaxImagePx})
+	if err != nil {
+		return nil, 0, fmt.Errorf("optimize: %w", err)
+	}
+	if err := h.Storage.Put(ctx, key, bytes.NewReader(out), "application/epub+zip"); err != nil {
+		log.Printf("send: cache optimized %s: %v", book.ID.Hex(), err)
+	}
+	return io.NopCloser(bytes.NewReader(out)), int64(len(out)), nil
+}
+
+// reserveSend takes the user's send lock and checks their role's send limit, writing a 429 with Retry-After
+// when they must wait. On success the caller holds the lock until it calls unlock, after queueing the send, so
+// the next request from this user counts it.
+func (h *BooksHandler) reserveSend(w http.ResponseWriter, r *http.Request, userID primitive.ObjectID) (unlock func(), ok bool) {
+	unlock = h.sendLocks.lock(userID)
+	err := checkSendLimit(r.Context(), h.DB, userID, h.SendLimits[middleware.RoleFromContext(r.Context())], h.Clock.Now())
+	if err == nil {
+		return unlock, true
+	}
+	unlock()
+	var limitErr *sendLimitError
+	if !errors.As(err, &limitErr) {
+		http.Error(w, `{"error":"failed to check send limit"}`, http.StatusInternalServerError)
+		return nil, false
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(limitErr.retryAfter.Seconds()))))
+	writeSendError(w, http.StatusTooManyRequests, "SEND_LIMIT_REACHED", limitErr.Error())
+	return nil, false
+}
+
+// JobSend is the kind of queued job that sends a book; see RunSend.
+const JobSend = "send"
+
+// Send queues the book for sending to the user's Kindle address, a delivery target or a device (see
+// SendRequest), converting it when the device takes none of the formats it is stored in. What can be checked up
+// front is: the destination, the format and the stored file's size answer at once with SendErrorResponses. The
+// send itself runs in the job queue (see RunSend), so a slow mail server doesn't hold the request; it answers
+// 202 with the job's ID to poll at GET /api/jobs/{id}, whose result is the send's.
+// POST /api/books/:id/send (and /send-to-kindle, with no body).
+func (h *BooksHandler) Send(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+	var req SendRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		http.Error(w, `{"error":"invalid json"}`, http.StatusBadRequest)
+		return
+	}
+	book, ok := h.visibleBook(w, r)
+	if !ok {
+		return
+	}
+	var device *models.Device
+	var targetID primitive.ObjectID
+	if req.DeviceID != "" {
+		id, err := primitive.ObjectIDFromHex(req.DeviceID)
+		if err != nil {
+			http.Error(w, `{"error":"invalid device id"}`, http.StatusBadRequest)
+			return
+		}
+		if device, err = h.DB.Device(r.Context(), userID, id); err != nil {
+			http.Error(w, `{"error":"failed to load device"}`, http.StatusInternalServerError)
+			return
+		}
+		if device == nil {
+			http.Error(w, `{"error":"device not found"}`, http.StatusNotFound)
+			return
+		}
+		targetID = device.TargetID
+	} else if req.TargetID != "" {
+		var err error
+		if targetID, err = primitive.ObjectIDFromHex(req.TargetID); err != nil {
+			http.Error(w, `{"error":"invalid target id"}`, http.StatusBadRequest)
+			return
+		}
+	}
+	dest, sendErr := h.destination(r.Context(), userID, targetID)
+	if sendErr != nil {
+		sendErr.write(w)
+		return
+	}
+
+	// Without a device, the Kindle address gets Kindle's default profile and targets get the book as stored.
+	var formats []string
+	var maxMB int
+	switch {
+	case device != nil:
+		formats, maxMB = device.Formats, device.MaxAttachmentMB
+	case dest.target == nil:
+		formats, maxMB = models.DefaultDeviceProfile(models.DeviceKindle)
+	}
+	format, ok := h.chooseFormat(book.Format, formats)
+	if !ok {
+		writeSendError(w, http.StatusBadRequest, "FORMAT_UNSUPPORTED",
+			fmt.Sprintf("This device takes %s; the book is %s and can't be converted on this server.", strings.Join(formats, ", "), book.Format))
+		return
+	}
+	optimize := book.Format == "epub"
+	switch {
+	case !optimize:
+	case req.Optimize != nil:
+		optimize = *req.Optimize
+	default:
+		optimize = (device != nil && device.Optimize) || overLimit(book.SizeBytes, maxMB)
+	}
+	if format == book.Format && !optimize && overLimit(book.SizeBytes, maxMB) {
+		fileTooLarge(format, book.SizeBytes, maxMB).write(w)
+		return
+	}
+	if h.Storage == nil {
+		http.Error(w, `{"error":"download not configured"}`, http.StatusServiceUnavailable)
+		return
+	}
+	restore, err := h.restoreState(r.Context(), book)
+	if storageUnavailable(w, err) {
+		return
+	}
+	if err != nil {
+		http.Error(w, `{"error":"failed to check the file's storage class"}`, http.StatusInternalServerError)
+		return
+	}
+	if restore != nil {
+		w.Header().Set("Retry-After", restoreRetryAfter)
+		writeSendError(w, http.StatusConflict, "FILE_ARCHIVED", "This book's file is archived. It is being restored; send it again in a few hours.")
+		return
+	}
+	unlock, ok := h.reserveSend(w, r, userID)
+	if !ok {
+		return
+	}
+	defer unlock()
+	params := map[string]string{
+		"bookId":    book.ID.Hex(),
+		"format":    format,
+		"maxMB":     strconv.Itoa(maxMB),
+		"userEmail": middleware.EmailFromContext(r.Context()),
+	}
+	if !targetID.IsZero() {
+		params["targetId"] = targetID.Hex()
+	}
+	if device != nil {
+		params["deviceId"] = device.ID.Hex()
+	}
+	if optimize {
+		params["optimize"] = "true"
+	}
+	job, err := h.Queue.Enqueue(r.Context(), JobSend, userID, params)
+	if err != nil {
+		log.Printf("send: queue %s: %v", book.ID.Hex(), err)
+		http.Error(w, `{"error":"failed to queue send"}`, http.StatusInternalServerError)
+		return
+	}
+	resp := sendResult(dest, format, optimize)
+	resp["message"] = "Sending to " + dest.name
+	resp["jobId"] = job.ID.Hex()
+	resp["status"] = job.Status
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// fileTooLarge is the error for a file of size bytes over a device's maxMB limit.
+func fileTooLarge(format string, size int64, maxMB int) *sendError {
+	return &sendError{http.StatusRequestEntityTooLarge, "FILE_TOO_LARGE",
+		fmt.Sprintf("The %s file is %.1f MB, over this device's %d MB limit.", format, float64(size)/(1<<20), maxMB)}
+}
+
+// sendResult describes a send to dest, for Send's response and the job's result.
+func sendResult(dest *sendDestination, format string, optimize bool) map[string]string {
+	res := map[string]string{"format": format}
+	if dest.target == nil {
+		res["kindleMail"] = dest.mail.To
+	} else {
+		res["targetId"] = dest.target.ID.Hex()
+	}
+	if optimize {
+		res["optimized"] = "true"
+	}
+	return res
+}
+
+// RunSend is the queue's handler for JobSend jobs: it loads (optimizing or converting) the book Send queued and
+// delivers it, then logs the send. Failures a retry can't fix (the book or destination went away, the converted
+// file is too large, the mail server rejected the message) fail the job at once; others are retried.
+func (h *BooksHandler) RunSend(ctx context.Context, job *models.QueuedJob) (res map[string]string, err error) {
+	p := job.Params
+	destination := "kindle"
+	if p["deviceId"] != "" {
+		destination = "device"
+	}
+	defer func() { h.emitSend(ctx, job, destination, err) }()
+	bookID, err := primitive.ObjectIDFromHex(p["bookId"])
+	if err != nil {
+		return nil, jobs.Permanent(fmt.Errorf("invalid book id %q", p["bookId"]))
+	}
+	book, err := h.DB.BookByID(ctx, bookID)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, jobs.Permanent(errors.New("the book was deleted"))
+	}
+	if err != nil {
+		return nil, err
+	}
+	var targetID primitive.ObjectID
+	if p["targetId"] != "" {
+		if targetID, err = primitive.ObjectIDFromHex(p["targetId"]); err != nil {
+			return nil, jobs.Permanent(fmt.Errorf("invalid target id %q", p["targetId"]))
+		}
+	}
+	dest, sendErr := h.destination(ctx, job.UserID, targetID)
+	if sendErr != nil {
+		if sendErr.status == http.StatusInternalServerError {
+			return nil, sendErr
+		}
+		return nil, jobs.Permanent(sendErr)
+	}
+	if dest.target != nil {
+		destination = dest.target.Kind
+	}
+	if h.Storage == nil {
+		return nil, jobs.Permanent(errors.New("download not configured"))
+	}
+	format, optimize := p["format"], p["optimize"] == "true"
+	maxMB, _ := strconv.Atoi(p["maxMB"])
+
+	var body io.ReadCloser
+	size := book.SizeBytes
+	if optimize {
+		body, size, err = h.optimizedEPUB(ctx, book)
+	} else {
+		body, err = h.openBookFile(ctx, book)
+	}
+	if errors.Is(err, service.ErrObjectNotFound) {
+		return nil, jobs.Permanent(errors.New("the book's file is missing"))
+	}
+	if errors.Is(err, errRestoring) {
+		return nil, err // archived since it was queued; a later attempt finds it restored
+	}
+	if err != nil {
+		log.Printf("send: load %s: %v", book.ID.Hex(), err)
+		return nil, errors.New("failed to load book file")
+	}
+	defer body.Close()
+
+	var file io.Reader = body
+	sent := *book
+	if format != book.Format {
+		converted, convertedSize, err := h.Converter.Convert(ctx, body, book.Format, format)
+		if err != nil {
+			log.Printf("send: convert %s: %v", book.ID.Hex(), err)
+			return nil, jobs.Permanent(errors.New("failed to convert book to " + format))
+		}
+		defer converted.Close()
+		file, size, sent.Format = converted, convertedSize, format
+	}
+	if overLimit(size, maxMB) {
+		return nil, jobs.Permanent(fileTooLarge(format, size, maxMB))
+	}
+	if err := dest.deliver(ctx, h.Mailer, book.Title, utils.RenderFilename(h.FilenameTemplate, &sent), format, file); err != nil {
+		err = fmt.Errorf("failed to send to %s: %w", dest.name, err)
+		if service.IsPermanentMailError(err) {
+			return nil, jobs.Permanent(err)
+		}
+		return nil, err
+	}
+
+	entry := models.EmailLog{}
+	if dest.mail != nil {
+		entry.ToEmail = dest.mail.To
+	}
+	if dest.target != nil {
+		entry.TargetID, entry.Target = dest.target.ID, dest.target.Kind
+	}
+	if deviceID, err := primitive.ObjectIDFromHex(p["deviceId"]); err == nil {
+		entry.DeviceID = deviceID
+	}
+	if format != book.Format {
+		entry.Format = format
+	}
+	entry.Optimized = optimize
+	h.logSend(ctx, job.UserID, p["userEmail"], book, entry)
+	res = sendResult(dest, format, optimize)
+	res["message"] = "Sent to " + dest.name
+	return res, nil
+}
+
+func (h *BooksHandler) logSend(ctx context.Context, userID primitive.ObjectID, userEmail string, book *models.Book, entry models.EmailLog) {
+	entry.BookID, entry.FileTitle = book.ID, book.Title
+	entry.UserID, entry.UserEmail = userID, userEmail
+	entry.SentAt = h.Clock.Now()
+	if err := h.DB.InsertEmailLog(ctx, &entry); err != nil {
+		log.Printf("send: failed to insert email log: %v", err)
+	}
+}
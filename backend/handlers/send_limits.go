@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/kevinaaaquil/books/backend/models"
+	"github.com/kevinaaaquil/books/backend/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// sendLimitNear is the share of a limit at which a user shows up as "near" in the admin report.
+const sendLimitNear = 0.8
+
+// userLocks serialises work per user, so concurrent sends by one account can't all pass the limit check.
+type userLocks struct {
+	m sync.Map // primitive.ObjectID -> *sync.Mutex
+}
+
+func (l *userLocks) lock(id primitive.ObjectID) (unlock func()) {
+	mu, _ := l.m.LoadOrStore(id, &sync.Mutex{})
+	mu.(*sync.Mutex).Lock()
+	return mu.(*sync.Mutex).Unlock
+}
+
+// sendLimitError is returned by checkSendLimit when the user must wait.
+type sendLimitError struct {
+	limit      int
+	window     string
+	retryAfter time.Duration
+}
+
+func (e *sendLimitError) Error() string {
+	return fmt.Sprintf("send limit reached: %d per %s", e.limit, e.window)
+}
+
+// checkSendLimit returns a *sendLimitError if sending one more book now would exceed limit. Sends still in the
+// job queue count as sent when they were queued.
+func checkSendLimit(ctx context.Context, db store.Store, userID primitive.ObjectID, limit models.SendLimit, now time.Time) error {
+	if limit.Hourly == 0 && limit.Daily == 0 {
+		return nil
+	}
+	logs, err := db.UserEmailLogsSince(ctx, userID, now.Add(-24*time.Hour))
+	if err != nil {
+		return err
+	}
+	pending, err := db.PendingQueuedJobs(ctx, userID, JobSend)
+	if err != nil {
+		return err
+	}
+	for _, job := range pending {
+		logs = append(logs, models.EmailLog{UserID: userID, SentAt: job.CreatedAt})
+	}
+	sort.SliceStable(logs, func(i, j int) bool { return logs[i].SentAt.Before(logs[j].SentAt) })
+	for _, w := range []struct {
+		limit  int
+		window string
+		length time.Duration
+	}{
+		{limit.Hourly, "hour", time.Hour},
+		{limit.Daily, "day", 24 * time.Hour},
+	} {
+		if w.limit == 0 {
+			continue
+		}
+		// logs are oldest first; the window holds those sent after now-length.
+		inWindow := logs[sendsBefore(logs, now.Add(-w.length)):]
+		if len(inWindow) >= w.limit {
+			// A slot frees up when the oldest send that keeps the count at the limit leaves the window.
+			oldest := inWindow[len(inWindow)-w.limit].SentAt
+			return &sendLimitError{limit: w.limit, window: w.window, retryAfter: oldest.Add(w.length).Sub(now)}
+		}
+	}
+	return nil
+}
+
+// sendsBefore returns how many of logs (oldest first) were sent before t.
+func sendsBefore(logs []models.EmailLog, t time.Time) int {
+	n := 0
+	for n < len(logs) && logs[n].SentAt.Before(t) {
+		n++
+	}
+	return n
+}
+
+// sendUsage reports each user who sent anything in the last day against their role's limit, most used first.
+func sendUsage(ctx context.Context, db store.Store, limits map[string]models.SendLimit, now time.Time) ([]models.SendUsage, error) {
+	logs, err := db.EmailLogsSince(ctx, now.Add(-24*time.Hour))
+	if err != nil {
+		return nil, err
+	}
+	users, err := db.ListUsers(ctx)
+	if err != nil {
+		return nil, err
+	}
+	byID := map[primitive.ObjectID]*models.SendUsage{}
+	var order []primitive.ObjectID
+	for _, l := range logs {
+		u, ok := byID[l.UserID]
+		if !ok {
+			u = &models.SendUsage{UserID: l.UserID, Email: l.UserEmail}
+			byID[l.UserID] = u
+			order = append(order, l.UserID)
+		}
+		u.LastDay++
+		if !l.SentAt.Before(now.Add(-time.Hour)) {
+			u.LastHour++
+		}
+	}
+	for _, user := range users {
+		if u, ok := byID[user.ID]; ok {
+			u.Email, u.Role = user.Email, user.Role
+		}
+	}
+	out := make([]models.SendUsage, 0, len(order))
+	for _, id := range order {
+		u := byID[id]
+		u.Limit = limits[u.Role]
+		hourly, daily := usageShare(u.LastHour, u.Limit.Hourly), usageShare(u.LastDay, u.Limit.Daily)
+		switch {
+		case hourly >= 1 || daily >= 1:
+			u.Status = models.SendUsageLimited
+		case hourly >= sendLimitNear || daily >= sendLimitNear:
+			u.Status = models.SendUsageNear
+		default:
+			u.Status = models.SendUsageOK
+		}
+		out = append(out, *u)
+	}
+	share := func(u models.SendUsage) float64 {
+		return max(usageShare(u.LastHour, u.Limit.Hourly), usageShare(u.LastDay, u.Limit.Daily))
+	}
+	sort.SliceStable(out, func(i, j int) bool { return share(out[i]) > share(out[j]) })
+	return out, nil
+}
+
+// usageShare is used/limit, or 0 when unlimited.
+func usageShare(used, limit int) float64 {
+	if limit == 0 {
+		return 0
+	}
+	return float64(used) / float64(limit)
+}
@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kevinaaaquil/books/backend/middleware"
+	"github.com/kevinaaaquil/books/backend/models"
+	"github.com/kevinaaaquil/books/backend/service"
+	"github.com/kevinaaaquil/books/backend/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// settingsCacheTTL is how long an instance uses the settings it last read; a change made through another instance
+// takes effect here within this time.
+const settingsCacheTTL = 5 * time.Second
+
+// SettingsHandler serves and changes the server-wide settings (see models.Settings).
+type SettingsHandler struct {
+	DB    store.Store
+	Clock service.Clock
+
+	mu       sync.Mutex
+	cached   *models.Settings
+	fetched  time.Time
+	accepted map[primitive.ObjectID]int // terms version each user is known to have accepted
+}
+
+// SettingsRequest changes settings; fields left out keep their value.
+type SettingsRequest struct {
+	Maintenance                *MaintenanceRequest `json:"maintenance"`
+	PresignedDownloadsDisabled *bool               `json:"presignedDownloadsDisabled"`
+	PublicStats                *bool               `json:"publicStats"`
+	// Terms sets the terms of use; changing the text bumps their version and "" removes them.
+	Terms *string `json:"terms"`
+}
+
+type MaintenanceRequest struct {
+	Enabled    *bool   `json:"enabled"`
+	Message    *string `json:"message"`
+	RetryAfter *int    `json:"retryAfter"` // seconds
+}
+
+// Current returns the settings. Reads go through a short cache; if the settings cannot be read, the last known
+// ones stand (defaults if there are none).
+func (h *SettingsHandler) Current(ctx context.Context) models.Settings {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.cached == nil || h.Clock.Now().Sub(h.fetched) >= settingsCacheTTL {
+		s, err := h.DB.Settings(ctx)
+		if err != nil {
+			log.Printf("settings: %v", err)
+			if h.cached == nil {
+				return models.Settings{}
+			}
+			return *h.cached
+		}
+		h.cached, h.fetched = s, h.Clock.Now()
+	}
+	return *h.cached
+}
+
+// Maintenance returns the current maintenance state, for middleware.Maintenance.
+func (h *SettingsHandler) Maintenance(ctx context.Context) models.Maintenance {
+	return h.Current(ctx).Maintenance
+}
+
+// Get returns the settings. GET /api/admin/settings (admin).
+func (h *SettingsHandler) Get(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	s, err := h.DB.Settings(r.Context())
+	if err != nil {
+		http.Error(w, `{"error":"failed to load settings"}`, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s)
+}
+
+// Patch changes the settings and returns them. Turning maintenance on makes every API request from non-admins
+// fail with 503 and a Retry-After until it is turned off; new terms of use, with 451 until they are accepted.
+// PATCH /api/admin/settings (admin).
+func (h *SettingsHandler) Patch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPatch {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req SettingsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error":"invalid json"}`, http.StatusBadRequest)
+		return
+	}
+	s, err := h.DB.Settings(r.Context())
+	if err != nil {
+		http.Error(w, `{"error":"failed to load settings"}`, http.StatusInternalServerError)
+		return
+	}
+	now := h.Clock.Now()
+	if m := req.Maintenance; m != nil {
+		if m.RetryAfter != nil && *m.RetryAfter < 0 {
+			http.Error(w, `{"error":"retryAfter must not be negative"}`, http.StatusBadRequest)
+			return
+		}
+		if m.Enabled != nil && *m.Enabled != s.Maintenance.Enabled {
+			s.Maintenance.Enabled = *m.Enabled
+			s.Maintenance.Since = nil
+			if *m.Enabled {
+				s.Maintenance.Since = &now
+			}
+		}
+		if m.Message != nil {
+			s.Maintenance.Message = strings.TrimSpace(*m.Message)
+		}
+		if m.RetryAfter != nil {
+			s.Maintenance.RetryAfter = *m.RetryAfter
+		}
+	}
+	if req.PresignedDownloadsDisabled != nil {
+		s.PresignedDownloadsDisabled = *req.PresignedDownloadsDisabled
+	}
+	if req.PublicStats != nil {
+		s.PublicStats = *req.PublicStats
+	}
+	if req.Terms != nil {
+		text := strings.TrimSpace(*req.Terms)
+		if len(text) > maxTermsBytes {
+			http.Error(w, `{"error":"terms are too long"}`, http.StatusBadRequest)
+			return
+		}
+		if text != s.Terms.Text {
+			s.Terms.Text, s.Terms.UpdatedAt = text, &now
+			if text != "" {
+				s.Terms.Version++
+			}
+		}
+	}
+	s.UpdatedAt, s.UpdatedBy = now, middleware.EmailFromContext(r.Context())
+	if err := h.DB.SaveSettings(r.Context(), s); err != nil {
+		http.Error(w, `{"error":"failed to save settings"}`, http.StatusInternalServerError)
+		return
+	}
+	h.mu.Lock()
+	h.cached, h.fetched = s, now
+	h.mu.Unlock()
+	log.Printf("settings: maintenance enabled=%v, presigned downloads disabled=%v, public stats=%v, terms version=%d, by %s", s.Maintenance.Enabled, s.PresignedDownloadsDisabled, s.PublicStats, s.Terms.Version, s.UpdatedBy)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s)
+}
@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/kevinaaaquil/books/backend/models"
+	"github.com/kevinaaaquil/books/backend/search"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// How Similar compared books.
+const (
+	SimilarByEmbeddings = "embeddings" // the books' embeddings (see Semantic)
+	SimilarByText       = "text"       // TF-IDF over the words of their text, in the search index
+)
+
+const (
+	similarDefaultLimit = 10
+	similarMaxLimit     = 50
+)
+
+type SimilarResponse struct {
+	Method string        `json:"method"`
+	Books  []SemanticHit `json:"books"`
+}
+
+// Similar returns the books in the library most like this one in content, best first, with a score from 0 to 1:
+// by embeddings when semantic search is on and the book has one, otherwise by the distinctive words of its EPUB
+// text (a book without text has none). Unlike recommendations, authors and categories play no part. ?limit= (10,
+// up to 50). GET /api/books/:id/similar (auth).
+func (h *BooksHandler) Similar(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	book, ok := h.visibleBook(w, r)
+	if !ok {
+		return
+	}
+	limit := similarDefaultLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			http.Error(w, `{"error":"limit must be a positive number"}`, http.StatusBadRequest)
+			return
+		}
+		limit = min(n, similarMaxLimit)
+	}
+	resp := SimilarResponse{Method: SimilarByText, Books: []SemanticHit{}}
+	var hits []search.Hit
+	if h.Embedder != nil && h.Vectors != nil {
+		if err := h.syncVectors(r.Context()); err != nil {
+			http.Error(w, `{"error":"failed to load embeddings"}`, http.StatusInternalServerError)
+			return
+		}
+		if vec := h.Vectors.Vector(book.ID); vec != nil {
+			resp.Method = SimilarByEmbeddings
+			hits = h.Vectors.Search(vec, 0, 0)
+		}
+	}
+	if resp.Method == SimilarByText && h.Search != nil {
+		hits = h.Search.Similar(book.ID)
+	}
+	books, err := h.visibleBooks(r)
+	if err != nil {
+		http.Error(w, `{"error":"failed to list books"}`, http.StatusInternalServerError)
+		return
+	}
+	byID := make(map[primitive.ObjectID]models.Book, len(books))
+	for _, b := range books {
+		byID[b.ID] = b
+	}
+	for _, hit := range hits {
+		b, ok := byID[hit.BookID]
+		if !ok || b.ID == book.ID {
+			continue
+		}
+		setCoverURLIfExtracted(&b)
+		setContentRating(&b)
+		resp.Books = append(resp.Books, SemanticHit{Book: b, Score: hit.Score})
+		if len(resp.Books) == limit {
+			break
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/kevinaaaquil/books/backend/models"
+	"github.com/kevinaaaquil/books/backend/service"
+	"github.com/kevinaaaquil/books/backend/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// statsCacheTTL is how long computed public stats are served before they are computed again.
+const statsCacheTTL = 5 * time.Minute
+
+// StatsHandler serves library-wide totals to anyone, for a badge on a personal site. They are only published while
+// the publicStats setting is on; otherwise the endpoints answer 404 as if they did not exist.
+type StatsHandler struct {
+	DB       store.Store
+	Settings *SettingsHandler
+	Clock    service.Clock
+
+	mu       sync.Mutex
+	cached   *PublicStats
+	computed time.Time
+}
+
+// PublicStats are the totals GET /api/public/stats returns. PagesReadThisYear is estimated from each reader's
+// position in books they read this year, so it is only as good as the books' page counts.
+type PublicStats struct {
+	Books             int64     `json:"books"`
+	PagesReadThisYear int64     `json:"pagesReadThisYear"`
+	CurrentlyReading  int       `json:"currentlyReading"` // books someone has started but not finished
+	Year              int       `json:"year"`
+	ComputedAt        time.Time `json:"computedAt"`
+}
+
+// Stats returns the totals as JSON. GET /api/public/stats
+func (h *StatsHandler) Stats(w http.ResponseWriter, r *http.Request) {
+	stats, ok := h.load(w, r)
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stats)
+}
+
+// Badge returns the totals as a small SVG card, for <img src>. GET /api/public/stats.svg
+func (h *StatsHandler) Badge(w http.ResponseWriter, r *http.Request) {
+	stats, ok := h.load(w, r)
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Type", "image/svg+xml")
+	w.Write([]byte(statsBadge(stats)))
+}
+
+// load checks the method and the setting and returns the stats, writing an error response when it cannot.
+func (h *StatsHandler) load(w http.ResponseWriter, r *http.Request) (*PublicStats, bool) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return nil, false
+	}
+	if !h.Settings.Current(r.Context()).PublicStats {
+		http.Error(w, `{"error":"not found"}`, http.StatusNotFound)
+		return nil, false
+	}
+	stats, err := h.current(r.Context())
+	if err != nil {
+		http.Error(w, `{"error":"failed to compute stats"}`, http.StatusInternalServerError)
+		return nil, false
+	}
+	return stats, true
+}
+
+// current returns the cached stats, computing them again when they are older than statsCacheTTL.
+func (h *StatsHandler) current(ctx context.Context) (*PublicStats, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	now := h.Clock.Now()
+	if h.cached != nil && now.Sub(h.computed) < statsCacheTTL {
+		return h.cached, nil
+	}
+	books, err := h.DB.AllBooks(ctx)
+	if err != nil {
+		return nil, err
+	}
+	progress, err := h.DB.AllReadingProgress(ctx)
+	if err != nil {
+		return nil, err
+	}
+	pages := make(map[primitive.ObjectID]int, len(books))
+	for _, b := range books {
+		pages[b.ID] = b.PageCount
+		if pages[b.ID] == 0 {
+			pages[b.ID] = b.FilePageCount
+		}
+	}
+	stats := &PublicStats{Books: int64(len(books)), Year: now.Year(), ComputedAt: now}
+	reading := map[primitive.ObjectID]bool{}
+	for _, p := range progress {
+		n, ok := pages[p.BookID]
+		if !ok {
+			continue // deleted since
+		}
+		if p.UpdatedAt.Year() == now.Year() {
+			stats.PagesReadThisYear += int64(math.Round(math.Min(p.Percent, 100) / 100 * float64(n)))
+		}
+		if p.Percent > 0 && p.Percent < models.FinishedPercent {
+			reading[p.BookID] = true
+		}
+	}
+	stats.CurrentlyReading = len(reading)
+	h.cached, h.computed = stats, now
+	return stats, nil
+}
+
+// statsBadge renders the stats as a 240×96 card.
+func statsBadge(s *PublicStats) string {
+	rows := []struct{ label, value string }{
+		{"Books", formatCount(s.Books)},
+		{fmt.Sprintf("Pages read in %d", s.Year), formatCount(s.PagesReadThisYear)},
+		{"Currently reading", formatCount(int64(s.CurrentlyReading))},
+	}
+	label := fmt.Sprintf("%s books, %s pages read in %d, %s currently reading", rows[0].value, rows[1].value, s.Year, rows[2].value)
+	svg := `<svg xmlns="http://www.w3.org/2000/svg" width="240" height="96" viewBox="0 0 240 96" role="img" aria-label="` + label + `">` +
+		`<title>` + label + `</title>` +
+		`<rect width="240" height="96" rx="8" fill="#1f2937"/>` +
+		`<g font-family="Verdana,DejaVu Sans,sans-serif" font-size="13" fill="#f9fafb">`
+	for i, row := range rows {
+		y := strconv.Itoa(30 + 24*i)
+		svg += `<text x="14" y="` + y + `" fill="#9ca3af">` + row.label + `</text>` +
+			`<text x="226" y="` + y + `" text-anchor="end" font-weight="bold">` + row.value + `</text>`
+	}
+	return svg + `</g></svg>`
+}
+
+// formatCount writes n with thousands separators, e.g. "12,345".
+func formatCount(n int64) string {
+	s := strconv.FormatInt(n, 10)
+	for i := len(s) - 3; i > 0; i -= 3 {
+		s = s[:i] + "," + s[i:]
+	}
+	return s
+}
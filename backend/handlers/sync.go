@@ -0,0 +1,210 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kevinaaaquil/books/backend/middleware"
+	"github.com/kevinaaaquil/books/backend/models"
+	"github.com/kevinaaaquil/books/backend/service"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// syncFileURLExpiry is how long the file links in GET /api/sync/books work.
+const syncFileURLExpiry = time.Hour
+
+// syncCursor marks a position in a list ordered by time, then ID.
+func syncCursor(t time.Time, id primitive.ObjectID) string {
+	return strconv.FormatInt(t.UnixNano(), 10) + "-" + id.Hex()
+}
+
+// parseSyncCursor parses a syncCursor; "" is the start of the list.
+func parseSyncCursor(s string) (time.Time, primitive.ObjectID, bool) {
+	if s == "" {
+		return time.Time{}, primitive.NilObjectID, true
+	}
+	ns, hex, ok := strings.Cut(s, "-")
+	n, err := strconv.ParseInt(ns, 10, 64)
+	if !ok || err != nil {
+		return time.Time{}, primitive.NilObjectID, false
+	}
+	id, err := primitive.ObjectIDFromHex(hex)
+	if err != nil {
+		return time.Time{}, primitive.NilObjectID, false
+	}
+	return time.Unix(0, n), id, true
+}
+
+// compareSyncOrder orders by time, then ID.
+func compareSyncOrder(at time.Time, id primitive.ObjectID, bt time.Time, bid primitive.ObjectID) int {
+	if c := at.Compare(bt); c != 0 {
+		return c
+	}
+	return strings.Compare(id.Hex(), bid.Hex())
+}
+
+// syncPage reads ?cursor= and ?limit= (1-500, default 100), writing the error response when they are invalid.
+func syncPage(w http.ResponseWriter, r *http.Request) (after time.Time, afterID primitive.ObjectID, limit int, ok bool) {
+	after, afterID, ok = parseSyncCursor(r.URL.Query().Get("cursor"))
+	if !ok {
+		http.Error(w, `{"error":"invalid cursor"}`, http.StatusBadRequest)
+		return
+	}
+	limit = 100
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 500 {
+			http.Error(w, `{"error":"limit must be between 1 and 500"}`, http.StatusBadRequest)
+			return after, afterID, 0, false
+		}
+		limit = n
+	}
+	return after, afterID, limit, true
+}
+
+// SyncBooks returns the books of a collection ("all", "shelf:<shelf>" or "tag:<tag>", as the key's owner sees
+// it) in the order they were added, after ?cursor=, each with a link to its file valid for an hour, for another
+// instance mirroring this one (see models.SyncPeer). Links are recorded in the download link audit as kind
+// "sync". GET /api/sync/books?collection=&cursor=&limit= (admin, or an admin's API key with the sync scope).
+func (h *BooksHandler) SyncBooks(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+	after, afterID, limit, ok := syncPage(w, r)
+	if !ok {
+		return
+	}
+	name := r.URL.Query().Get("collection")
+	if name == "" {
+		name = collectionAll
+	}
+	_, books, err := h.collectionBooks(r.Context(), userID, name)
+	if errors.Is(err, errNoCollection) {
+		http.Error(w, `{"error":"collection not found"}`, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, `{"error":"failed to list books"}`, http.StatusInternalServerError)
+		return
+	}
+	slices.SortFunc(books, func(a, b models.Book) int { return compareSyncOrder(a.CreatedAt, a.ID, b.CreatedAt, b.ID) })
+	books = slices.DeleteFunc(books, func(b models.Book) bool { return compareSyncOrder(b.CreatedAt, b.ID, after, afterID) <= 0 })
+	page := models.SyncBooksPage{Books: []models.SyncBook{}, More: len(books) > limit}
+	if page.More {
+		books = books[:limit]
+	}
+	now := h.Clock.Now()
+	for _, b := range books {
+		sb := models.SyncBook{Book: b}
+		if h.Storage != nil && b.S3Key != "" && !service.NeedsRestore(b.StorageClass) {
+			link := &models.DownloadLink{
+				BookID:    b.ID,
+				UserID:    userID,
+				Email:     middleware.EmailFromContext(r.Context()),
+				Role:      middleware.RoleFromContext(r.Context()),
+				Kind:      models.DownloadLinkSync,
+				IP:        clientIP(r),
+				IssuedAt:  now,
+				ExpiresAt: now.Add(syncFileURLExpiry),
+				Bytes:     b.SizeBytes,
+			}
+			if h.StreamDownloads || h.Settings != nil && h.Settings.Current(r.Context()).PresignedDownloadsDisabled {
+				sb.FileURL = h.Signer.Sign(streamFilePath(b.ID), syncFileURLExpiry)
+			} else if sb.FileURL, err = h.Storage.PresignedGetURL(r.Context(), b.S3Key, syncFileURLExpiry, b.OriginalName); err != nil {
+				if storageUnavailable(w, err) {
+					return
+				}
+				http.Error(w, `{"error":"failed to generate download url"}`, http.StatusInternalServerError)
+				return
+			}
+			if err := h.recordDownloadLink(r.Context(), link); err != nil {
+				http.Error(w, `{"error":"failed to record download link"}`, http.StatusInternalServerError)
+				return
+			}
+		}
+		page.Books = append(page.Books, sb)
+		page.Cursor = syncCursor(b.CreatedAt, b.ID)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(page)
+}
+
+// SyncProgress returns every user's reading positions in the order they were last updated, after ?cursor=,
+// identified by email and the book file's SHA-256, for another instance mirroring this one. Positions in books
+// without a known SHA-256 are left out. GET /api/sync/progress?cursor=&limit= (admin, or an admin's API key with
+// the sync scope).
+func (h *BooksHandler) SyncProgress(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if _, ok := middleware.UserIDFromContext(r.Context()); !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+	after, afterID, limit, ok := syncPage(w, r)
+	if !ok {
+		return
+	}
+	progress, err := h.DB.AllReadingProgress(r.Context())
+	if err != nil {
+		http.Error(w, `{"error":"failed to load reading progress"}`, http.StatusInternalServerError)
+		return
+	}
+	users, err := h.DB.ListUsers(r.Context())
+	if err != nil {
+		http.Error(w, `{"error":"failed to load users"}`, http.StatusInternalServerError)
+		return
+	}
+	books, err := h.DB.AllBooks(r.Context())
+	if err != nil {
+		http.Error(w, `{"error":"failed to load books"}`, http.StatusInternalServerError)
+		return
+	}
+	emails := make(map[primitive.ObjectID]string, len(users))
+	for _, u := range users {
+		emails[u.ID] = u.Email
+	}
+	sums := make(map[primitive.ObjectID]string, len(books))
+	for _, b := range books {
+		sums[b.ID] = b.SHA256
+	}
+	slices.SortFunc(progress, func(a, b models.ReadingProgress) int { return compareSyncOrder(a.UpdatedAt, a.ID, b.UpdatedAt, b.ID) })
+	progress = slices.DeleteFunc(progress, func(p models.ReadingProgress) bool {
+		return compareSyncOrder(p.UpdatedAt, p.ID, after, afterID) <= 0
+	})
+	page := models.SyncProgressPage{Progress: []models.SyncProgress{}, More: len(progress) > limit}
+	if page.More {
+		progress = progress[:limit]
+	}
+	for _, p := range progress {
+		page.Cursor = syncCursor(p.UpdatedAt, p.ID)
+		if emails[p.UserID] == "" || sums[p.BookID] == "" {
+			continue
+		}
+		page.Progress = append(page.Progress, models.SyncProgress{
+			Email:          emails[p.UserID],
+			SHA256:         sums[p.BookID],
+			Anchor:         p.Anchor,
+			KindleLocation: p.KindleLocation,
+			Percent:        p.Percent,
+			Device:         p.Device,
+			UpdatedAt:      p.UpdatedAt,
+		})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(page)
+}
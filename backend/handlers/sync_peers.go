@@ -0,0 +1,253 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/kevinaaaquil/books/backend/jobs"
+	"github.com/kevinaaaquil/books/backend/middleware"
+	"github.com/kevinaaaquil/books/backend/models"
+	"github.com/kevinaaaquil/books/backend/service"
+	"github.com/kevinaaaquil/books/backend/store"
+	"github.com/kevinaaaquil/books/backend/utils"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// SyncPeersHandler manages the other instances this one mirrors (see models.SyncPeer). Peers are synced by the
+// federation job, on demand or by FEDERATION_SCHEDULE.
+type SyncPeersHandler struct {
+	DB       store.Store
+	Clock    service.Clock
+	EncKey   []byte // 32 bytes to encrypt peers' API keys; nil = stored in plaintext
+	Jobs     *jobs.Runner
+	Client   *http.Client
+	Ingest   func(context.Context, IngestFile) (*IngestResult, error) // UploadHandler.Ingest
+	MaxBytes int64                                                    // larger files are not mirrored; 0 = no limit
+}
+
+// SyncPeerRequest adds a peer.
+type SyncPeerRequest struct {
+	Name        string   `json:"name"`
+	URL         string   `json:"url"`         // the peer's base URL
+	APIKey      string   `json:"apiKey"`      // an admin's key on the peer, with the sync scope
+	Collections []string `json:"collections"` // default ["all"]
+	Progress    bool     `json:"progress"`
+}
+
+// SyncJob returns the federation job for one peer, or for every peer when id is zero.
+func (h *SyncPeersHandler) SyncJob(id primitive.ObjectID) jobs.Func {
+	return jobs.Federation(h.DB, jobs.FederationOptions{
+		Client:   h.Client,
+		Ingest:   h.ingest,
+		EncKey:   h.EncKey,
+		MaxBytes: h.MaxBytes,
+		PeerID:   id,
+	})
+}
+
+func (h *SyncPeersHandler) ingest(ctx context.Context, peer *models.SyncPeer, book *models.SyncBook, data []byte) (primitive.ObjectID, error) {
+	name := book.OriginalName
+	if name == "" {
+		name = book.Title + "." + book.Format
+	}
+	res, err := h.Ingest(ctx, IngestFile{
+		Name:       name,
+		Data:       data,
+		UploadedBy: peer.CreatedBy,
+		Metadata: &service.BookMetadata{
+			Title:         book.Title,
+			Authors:       book.Authors,
+			Publisher:     book.Publisher,
+			PublishDate:   book.PublishDate,
+			ISBN:          book.ISBN,
+			PageCount:     book.PageCount,
+			CoverURL:      book.CoverURL,
+			ThumbnailURL:  book.ThumbnailURL,
+			Edition:       book.Edition,
+			Preface:       book.Preface,
+			Category:      book.Category,
+			Categories:    book.Categories,
+			RatingAverage: book.RatingAverage,
+			RatingCount:   book.RatingCount,
+			DOI:           book.DOI,
+			Journal:       book.Journal,
+			Document:      book.Kind == models.BookKindDocument,
+		},
+		Source:    models.SourceFederation,
+		SourceURL: peer.URL + "/api/books/" + book.ID.Hex(),
+	})
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+	return res.Book.ID, nil
+}
+
+// validCollection reports whether name is "all", "shelf:<shelf>" or "tag:<tag>".
+func validCollection(name string) bool {
+	if shelf, ok := strings.CutPrefix(name, collectionShelfPre); ok {
+		return slices.ContainsFunc(opdsShelves, func(s struct{ Name, Title string }) bool { return s.Name == shelf })
+	}
+	if tag, ok := strings.CutPrefix(name, collectionTagPre); ok {
+		return strings.TrimSpace(tag) != ""
+	}
+	return name == collectionAll
+}
+
+// List returns the peers, oldest first, with their latest sync. GET /api/admin/sync-peers (admin only).
+func (h *SyncPeersHandler) List(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	peers, err := h.DB.SyncPeers(r.Context())
+	if err != nil {
+		http.Error(w, `{"error":"failed to list sync peers"}`, http.StatusInternalServerError)
+		return
+	}
+	if peers == nil {
+		peers = []models.SyncPeer{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(peers)
+}
+
+// Create adds a peer to mirror. The key is checked at the first sync, not here. POST /api/admin/sync-peers (admin only).
+func (h *SyncPeersHandler) Create(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req SyncPeerRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error":"invalid json"}`, http.StatusBadRequest)
+		return
+	}
+	base := strings.TrimRight(strings.TrimSpace(req.URL), "/")
+	u, err := url.Parse(base)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.RawQuery != "" || u.Fragment != "" {
+		http.Error(w, `{"error":"url must be an http or https base url"}`, http.StatusBadRequest)
+		return
+	}
+	key := strings.TrimSpace(req.APIKey)
+	if key == "" {
+		http.Error(w, `{"error":"apiKey is required"}`, http.StatusBadRequest)
+		return
+	}
+	collections := []string{collectionAll}
+	if len(req.Collections) > 0 {
+		collections = nil
+		for _, c := range req.Collections {
+			c = strings.TrimSpace(c)
+			if !validCollection(c) {
+				http.Error(w, `{"error":"collections must be \"all\", \"shelf:<shelf>\" or \"tag:<tag>\""}`, http.StatusBadRequest)
+				return
+			}
+			if !slices.Contains(collections, c) {
+				collections = append(collections, c)
+			}
+		}
+	}
+	if len(h.EncKey) == 32 {
+		if key, err = utils.Encrypt([]byte(key), h.EncKey); err != nil {
+			http.Error(w, `{"error":"failed to save sync peer"}`, http.StatusInternalServerError)
+			return
+		}
+	}
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		name = u.Host
+	}
+	p := &models.SyncPeer{
+		Name:        name,
+		URL:         base,
+		APIKey:      key,
+		Collections: collections,
+		Progress:    req.Progress,
+		CreatedBy:   middleware.EmailFromContext(r.Context()),
+		CreatedAt:   h.Clock.Now(),
+	}
+	if p.ID, err = h.DB.InsertSyncPeer(r.Context(), p); err != nil {
+		http.Error(w, `{"error":"failed to save sync peer"}`, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(p)
+}
+
+// peerID parses the {id} URL param, writing the error response when it is invalid.
+func peerID(w http.ResponseWriter, r *http.Request) (primitive.ObjectID, bool) {
+	id, err := primitive.ObjectIDFromHex(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, `{"error":"invalid sync peer id"}`, http.StatusBadRequest)
+		return id, false
+	}
+	return id, true
+}
+
+// Delete stops mirroring a peer; books already mirrored stay. DELETE /api/admin/sync-peers/:id (admin only).
+func (h *SyncPeersHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	id, ok := peerID(w, r)
+	if !ok {
+		return
+	}
+	found, err := h.DB.DeleteSyncPeer(r.Context(), id)
+	if err != nil {
+		http.Error(w, `{"error":"failed to delete sync peer"}`, http.StatusInternalServerError)
+		return
+	}
+	if !found {
+		http.Error(w, `{"error":"sync peer not found"}`, http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Sync starts pulling a peer's new books and progress and returns the job run (202). The outcome is the peer's
+// lastSync once the run has finished. 409 while a sync, of this or any peer, is running.
+// POST /api/admin/sync-peers/:id/sync (admin only).
+func (h *SyncPeersHandler) Sync(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	id, ok := peerID(w, r)
+	if !ok {
+		return
+	}
+	peers, err := h.DB.SyncPeers(r.Context())
+	if err != nil {
+		http.Error(w, `{"error":"failed to load sync peer"}`, http.StatusInternalServerError)
+		return
+	}
+	if !slices.ContainsFunc(peers, func(p models.SyncPeer) bool { return p.ID == id }) {
+		http.Error(w, `{"error":"sync peer not found"}`, http.StatusNotFound)
+		return
+	}
+	run, err := h.Jobs.StartWith(jobs.TypeFederation, middleware.EmailFromContext(r.Context()), map[string]string{"peer": id.Hex()}, h.SyncJob(id))
+	if errors.Is(err, jobs.ErrAlreadyRunning) {
+		http.Error(w, `{"error":"a sync is already running"}`, http.StatusConflict)
+		return
+	}
+	if errors.Is(err, jobs.ErrShuttingDown) {
+		http.Error(w, `{"error":"server is shutting down"}`, http.StatusServiceUnavailable)
+		return
+	}
+	if err != nil {
+		http.Error(w, `{"error":"failed to start sync"}`, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(run)
+}
@@ -0,0 +1,425 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/kevinaaaquil/books/backend/middleware"
+	"github.com/kevinaaaquil/books/backend/models"
+	"github.com/kevinaaaquil/books/backend/service"
+	"github.com/kevinaaaquil/books/backend/store"
+	"github.com/kevinaaaquil/books/backend/utils"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// oauthStateTTL is how long the user has to approve access at the provider.
+const oauthStateTTL = 10 * time.Minute
+
+// driveNames are the default names of linked drive targets.
+var driveNames = map[string]string{
+	models.TargetDropbox:     "Dropbox",
+	models.TargetGoogleDrive: "Google Drive",
+}
+
+// TargetsHandler manages delivery targets other than the Kindle: email addresses, confirmed by their recipient
+// with a code, and cloud drives linked with OAuth. Books are sent to them with BooksHandler.Send.
+type TargetsHandler struct {
+	DB        store.Store
+	Mailer    service.Mailer // sends confirmation codes, as the user like Kindle sends
+	Clock     service.Clock
+	EncKey    []byte // 32 bytes for the Kindle app password and drive tokens; nil = stored in plaintext
+	JWTSecret string
+	Drives    map[string]service.Drive // by target kind; providers not configured are absent
+	APIURL    string                   // this API's base URL, for OAuth redirects
+	AppURL    string                   // frontend base URL, where the OAuth callback sends the user back to
+	Imports   *ImportsHandler          // finishes links started by ImportsHandler.StartLink, which share the callback
+}
+
+// TargetResponse is a delivery target with whether books can be sent to it yet.
+type TargetResponse struct {
+	models.DeliveryTarget
+	Ready bool `json:"ready"`
+}
+
+type TargetsResponse struct {
+	Targets   []TargetResponse `json:"targets"`
+	Providers []string         `json:"providers"` // drive kinds that can be linked
+}
+
+type CreateTargetRequest struct {
+	Name  string `json:"name"`
+	Email string `json:"email"`
+}
+
+// List returns the current user's delivery targets. GET /api/targets
+func (h *TargetsHandler) List(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+	targets, err := h.DB.DeliveryTargetsForUser(r.Context(), userID)
+	if err != nil {
+		http.Error(w, `{"error":"failed to list targets"}`, http.StatusInternalServerError)
+		return
+	}
+	resp := TargetsResponse{Targets: make([]TargetResponse, 0, len(targets)), Providers: []string{}}
+	for _, t := range targets {
+		resp.Targets = append(resp.Targets, TargetResponse{DeliveryTarget: t, Ready: t.Ready()})
+	}
+	for kind := range h.Drives {
+		resp.Providers = append(resp.Providers, kind)
+	}
+	sort.Strings(resp.Providers)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// Create adds an email target and emails it a confirmation code. POST /api/targets
+func (h *TargetsHandler) Create(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+	var req CreateTargetRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error":"invalid json"}`, http.StatusBadRequest)
+		return
+	}
+	addr, err := mail.ParseAddress(strings.TrimSpace(req.Email))
+	if err != nil {
+		http.Error(w, `{"error":"invalid email address"}`, http.StatusBadRequest)
+		return
+	}
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		name = addr.Address
+	}
+	now := h.Clock.Now()
+	t := &models.DeliveryTarget{UserID: userID, Kind: models.TargetEmail, Name: name, Email: addr.Address, CreatedAt: now}
+	if !h.sendCode(w, r, t, now) {
+		return
+	}
+	id, err := h.DB.InsertDeliveryTarget(r.Context(), t)
+	if err != nil {
+		http.Error(w, `{"error":"failed to save target"}`, http.StatusInternalServerError)
+		return
+	}
+	t.ID = id
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(TargetResponse{DeliveryTarget: *t, Ready: false})
+}
+
+// sendCode emails a new confirmation code to t and sets t.Verification, writing the error response on failure.
+func (h *TargetsHandler) sendCode(w http.ResponseWriter, r *http.Request, t *models.DeliveryTarget, now time.Time) bool {
+	cfg, err := h.DB.GetEmailConfig(r.Context(), t.UserID)
+	if err != nil {
+		http.Error(w, `{"error":"failed to load Kindle config"}`, http.StatusInternalServerError)
+		return false
+	}
+	if !senderReady(cfg, h.Mailer) {
+		http.Error(w, `{"error":"set up your iCloud sender in Kindle setup first"}`, http.StatusBadRequest)
+		return false
+	}
+	m, err := userSender(cfg, h.Mailer, h.EncKey)
+	if err != nil {
+		log.Printf("target confirmation: %v", err)
+		http.Error(w, `{"error":"failed to use Kindle config"}`, http.StatusInternalServerError)
+		return false
+	}
+	code, err := newVerificationCode()
+	if err != nil {
+		http.Error(w, `{"error":"failed to send confirmation"}`, http.StatusInternalServerError)
+		return false
+	}
+	m.To = t.Email
+	m.Subject = "Confirm sending books to this address"
+	m.Body = middleware.EmailFromContext(r.Context()) + " wants to send books from Books to this address.\n\n" +
+		"If that's you, enter this code to confirm: " + code + "\n\n" +
+		"If not, ignore this email and nothing will be sent.\n"
+	if err := h.Mailer.Send(r.Context(), &m); err != nil {
+		log.Printf("target confirmation: %v", err)
+		http.Error(w, `{"error":"failed to send confirmation: `+err.Error()+`"}`, http.StatusInternalServerError)
+		return false
+	}
+	t.Verification = &models.AddressVerification{CodeHash: verificationCodeHash(code), SentAt: &now}
+	return true
+}
+
+// target loads the current user's target named in the URL, writing the error response when there is none.
+func (h *TargetsHandler) target(w http.ResponseWriter, r *http.Request, userID primitive.ObjectID) (*models.DeliveryTarget, bool) {
+	id, err := primitive.ObjectIDFromHex(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, `{"error":"invalid target id"}`, http.StatusBadRequest)
+		return nil, false
+	}
+	t, err := h.DB.DeliveryTarget(r.Context(), userID, id)
+	if err != nil {
+		http.Error(w, `{"error":"failed to load target"}`, http.StatusInternalServerError)
+		return nil, false
+	}
+	if t == nil {
+		http.Error(w, `{"error":"target not found"}`, http.StatusNotFound)
+		return nil, false
+	}
+	return t, true
+}
+
+// ResendCode emails a new confirmation code to an email target. POST /api/targets/:id/verification.
+// 429 if a code was sent less than a minute ago.
+func (h *TargetsHandler) ResendCode(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+	t, ok := h.target(w, r, userID)
+	if !ok {
+		return
+	}
+	if t.Ready() {
+		http.Error(w, `{"error":"nothing to confirm"}`, http.StatusConflict)
+		return
+	}
+	now := h.Clock.Now()
+	if verificationThrottled(t.Verification, now) {
+		http.Error(w, `{"error":"a code was just sent; wait a minute before sending another"}`, http.StatusTooManyRequests)
+		return
+	}
+	if !h.sendCode(w, r, t, now) {
+		return
+	}
+	if err := h.DB.UpdateDeliveryTargetVerification(r.Context(), t.ID, t.Verification); err != nil {
+		http.Error(w, `{"error":"failed to save target"}`, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Confirmation code sent", "email": t.Email})
+}
+
+// Confirm marks an email target confirmed when the code matches the last one sent. POST /api/targets/:id/confirm
+func (h *TargetsHandler) Confirm(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+	var req ConfirmVerificationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error":"invalid json"}`, http.StatusBadRequest)
+		return
+	}
+	t, ok := h.target(w, r, userID)
+	if !ok {
+		return
+	}
+	if t.Ready() || t.Verification == nil {
+		http.Error(w, `{"error":"nothing to confirm"}`, http.StatusBadRequest)
+		return
+	}
+	now := h.Clock.Now()
+	if err := checkVerificationCode(t.Verification, req.Code, now); err != nil {
+		if err == errWrongCode {
+			if err := h.DB.UpdateDeliveryTargetVerification(r.Context(), t.ID, t.Verification); err != nil {
+				http.Error(w, `{"error":"failed to save target"}`, http.StatusInternalServerError)
+				return
+			}
+		}
+		http.Error(w, `{"error":"`+err.Error()+`"}`, http.StatusBadRequest)
+		return
+	}
+	t.Verification = &models.AddressVerification{Verified: true, VerifiedAt: &now}
+	if err := h.DB.UpdateDeliveryTargetVerification(r.Context(), t.ID, t.Verification); err != nil {
+		http.Error(w, `{"error":"failed to save target"}`, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(TargetResponse{DeliveryTarget: *t, Ready: true})
+}
+
+// Delete removes one of the current user's targets. DELETE /api/targets/:id
+func (h *TargetsHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+	id, err := primitive.ObjectIDFromHex(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, `{"error":"invalid target id"}`, http.StatusBadRequest)
+		return
+	}
+	found, err := h.DB.DeleteDeliveryTarget(r.Context(), userID, id)
+	if err != nil {
+		http.Error(w, `{"error":"failed to delete target"}`, http.StatusInternalServerError)
+		return
+	}
+	if !found {
+		http.Error(w, `{"error":"target not found"}`, http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// oauthStateClaims identify who started linking a drive, and what for. They are signed with a key derived from
+// JWT_SECRET, so they can't be used to log in.
+type oauthStateClaims struct {
+	UserID   string `json:"userId"`
+	Provider string `json:"provider"`
+	Purpose  string `json:"purpose,omitempty"`  // "" links a delivery target, oauthPurposeImport an import source
+	Folder   string `json:"folder,omitempty"`   // import sources: the folder to import from
+	AutoSync bool   `json:"autoSync,omitempty"` // import sources: synced by the import schedule
+	jwt.RegisteredClaims
+}
+
+// oauthPurposeImport marks OAuth states started by ImportsHandler.StartLink.
+const oauthPurposeImport = "import"
+
+func oauthStateKey(jwtSecret string) []byte {
+	return []byte("oauth-state:" + jwtSecret)
+}
+
+// signOAuthState returns the state parameter for claims, valid for oauthStateTTL.
+func signOAuthState(claims *oauthStateClaims, jwtSecret string, now time.Time) (string, error) {
+	claims.RegisteredClaims = jwt.RegisteredClaims{
+		ExpiresAt: jwt.NewNumericDate(now.Add(oauthStateTTL)),
+		IssuedAt:  jwt.NewNumericDate(now),
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(oauthStateKey(jwtSecret))
+}
+
+// parseOAuthState returns the claims of a state signed by signOAuthState for provider, or nil if it is invalid
+// or has expired.
+func parseOAuthState(state, provider, jwtSecret string, now time.Time) *oauthStateClaims {
+	claims := &oauthStateClaims{}
+	token, err := jwt.ParseWithClaims(state, claims, func(t *jwt.Token) (interface{}, error) {
+		return oauthStateKey(jwtSecret), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Name}), jwt.WithTimeFunc(func() time.Time { return now }))
+	if err != nil || !token.Valid || claims.Provider != provider {
+		return nil
+	}
+	if _, err := primitive.ObjectIDFromHex(claims.UserID); err != nil {
+		return nil
+	}
+	return claims
+}
+
+// oauthRedirectURL is the callback the providers send the user back to, for every purpose.
+func oauthRedirectURL(apiURL, provider string) string {
+	return strings.TrimSuffix(apiURL, "/") + "/api/oauth/" + provider + "/callback"
+}
+
+func (h *TargetsHandler) redirectURL(provider string) string {
+	return oauthRedirectURL(h.APIURL, provider)
+}
+
+// StartLink returns the provider URL where the user grants access to their drive.
+// GET /api/targets/oauth/:provider/start. 404 if the provider is not configured.
+func (h *TargetsHandler) StartLink(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+	provider := chi.URLParam(r, "provider")
+	drive, ok := h.Drives[provider]
+	if !ok {
+		http.Error(w, `{"error":"provider not configured"}`, http.StatusNotFound)
+		return
+	}
+	state, err := signOAuthState(&oauthStateClaims{UserID: userID.Hex(), Provider: provider}, h.JWTSecret, h.Clock.Now())
+	if err != nil {
+		http.Error(w, `{"error":"failed to start linking"}`, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"url": drive.AuthURL(state, h.redirectURL(provider))})
+}
+
+// LinkCallback is where the provider sends the user back after StartLink. It stores the drive as a target and
+// redirects to Kindle setup with ?linked=<provider>, or ?link_error=<message>. Links started by
+// ImportsHandler.StartLink are finished by the imports handler instead. GET /api/oauth/:provider/callback
+// (public: the state parameter identifies the user).
+func (h *TargetsHandler) LinkCallback(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	provider := chi.URLParam(r, "provider")
+	q := r.URL.Query()
+	now := h.Clock.Now()
+	claims := parseOAuthState(q.Get("state"), provider, h.JWTSecret, now)
+	if claims != nil && claims.Purpose == oauthPurposeImport && h.Imports != nil {
+		h.Imports.linkCallback(w, r, claims, now)
+		return
+	}
+	back := func(key, value string) {
+		http.Redirect(w, r, strings.TrimSuffix(h.AppURL, "/")+"/kindle-setup?"+url.Values{key: {value}}.Encode(), http.StatusFound)
+	}
+	drive, ok := h.Drives[provider]
+	if !ok {
+		back("link_error", "provider not configured")
+		return
+	}
+	if e := q.Get("error"); e != "" {
+		back("link_error", "access was not granted")
+		return
+	}
+	if claims == nil || claims.Purpose != "" {
+		back("link_error", "link expired; try again")
+		return
+	}
+	userID, _ := primitive.ObjectIDFromHex(claims.UserID)
+	refreshToken, folder, err := drive.Link(r.Context(), q.Get("code"), h.redirectURL(provider))
+	if err != nil {
+		log.Printf("link %s: %v", provider, err)
+		back("link_error", "could not link "+driveNames[provider])
+		return
+	}
+	if len(h.EncKey) == 32 {
+		if refreshToken, err = utils.Encrypt([]byte(refreshToken), h.EncKey); err != nil {
+			back("link_error", "could not link "+driveNames[provider])
+			return
+		}
+	}
+	t := &models.DeliveryTarget{UserID: userID, Kind: provider, Name: driveNames[provider], Folder: folder, RefreshToken: refreshToken, CreatedAt: now}
+	if _, err := h.DB.InsertDeliveryTarget(r.Context(), t); err != nil {
+		back("link_error", "could not save "+driveNames[provider])
+		return
+	}
+	back("linked", provider)
+}
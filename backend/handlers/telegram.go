@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/kevinaaaquil/books/backend/middleware"
+	"github.com/kevinaaaquil/books/backend/service"
+	"github.com/kevinaaaquil/books/backend/store"
+	"github.com/kevinaaaquil/books/backend/telegram"
+)
+
+// TelegramHandler links users' Telegram chats to their accounts, for the bot (see package telegram).
+type TelegramHandler struct {
+	DB          store.Store
+	Clock       service.Clock
+	JWTSecret   string
+	Enabled     bool   // a bot token is configured
+	BotUsername string // for t.me deep links; empty = users send the code to the bot themselves
+}
+
+type TelegramStatusResponse struct {
+	Enabled  bool       `json:"enabled"`
+	Linked   bool       `json:"linked"`
+	Username string     `json:"username,omitempty"` // the linked chat's Telegram @username
+	LinkedAt *time.Time `json:"linkedAt,omitempty"`
+	Bot      string     `json:"bot,omitempty"` // the bot's @username
+}
+
+type TelegramLinkResponse struct {
+	Code      string    `json:"code"`          // send "/start <code>" to the bot
+	URL       string    `json:"url,omitempty"` // opens the bot with the code filled in
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// Status reports whether the current user has a linked chat. GET /api/me/telegram
+func (h *TelegramHandler) Status(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+	resp := TelegramStatusResponse{Enabled: h.Enabled, Bot: h.BotUsername}
+	c, err := h.DB.TelegramChatForUser(r.Context(), userID)
+	if err != nil {
+		http.Error(w, `{"error":"failed to load telegram link"}`, http.StatusInternalServerError)
+		return
+	}
+	if c != nil {
+		resp.Linked, resp.Username, resp.LinkedAt = true, c.Username, &c.LinkedAt
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// Link returns a code that links the chat it is sent from to the current user, replacing any chat linked
+// before. POST /api/me/telegram/link. 404 if no bot is configured.
+func (h *TelegramHandler) Link(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+	if !h.Enabled {
+		http.Error(w, `{"error":"telegram bot not configured"}`, http.StatusNotFound)
+		return
+	}
+	expires := h.Clock.Now().Add(telegram.LinkCodeTTL)
+	resp := TelegramLinkResponse{Code: telegram.LinkCode(h.JWTSecret, userID, expires), ExpiresAt: expires}
+	if h.BotUsername != "" {
+		resp.URL = "https://t.me/" + url.PathEscape(h.BotUsername) + "?start=" + resp.Code
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// Unlink disconnects the current user's chat from their account. DELETE /api/me/telegram
+func (h *TelegramHandler) Unlink(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+	found, err := h.DB.UnlinkTelegram(r.Context(), userID)
+	if err != nil {
+		http.Error(w, `{"error":"failed to unlink telegram"}`, http.StatusInternalServerError)
+		return
+	}
+	if !found {
+		http.Error(w, `{"error":"no telegram chat linked"}`, http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,32 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/kevinaaaquil/books/backend/jobs"
+	"github.com/kevinaaaquil/books/backend/models"
+)
+
+// TelemetryPreview is GET /api/admin/telemetry: whether the instance reports usage, where, and what it would send.
+type TelemetryPreview struct {
+	Enabled bool                    `json:"enabled"`
+	URL     string                  `json:"url,omitempty"`
+	Report  *models.TelemetryReport `json:"report"`
+}
+
+// TelemetryReport shows admins exactly what the telemetry job sends, whether or not they opted in.
+// GET /api/admin/telemetry (admin).
+func (h *AdminHandler) TelemetryReport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	report, err := jobs.TelemetryReport(r.Context(), h.DB, h.Telemetry, h.Clock.Now())
+	if err != nil {
+		http.Error(w, `{"error":"failed to build the report"}`, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(TelemetryPreview{Enabled: h.Telemetry.URL != "", URL: h.Telemetry.URL, Report: report})
+}
@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/kevinaaaquil/books/backend/middleware"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// maxTermsBytes caps the terms of use admins can set.
+const maxTermsBytes = 64 << 10
+
+type AcceptTermsRequest struct {
+	Version int `json:"version"` // the version read, so terms changed in the meantime are not accepted unseen
+}
+
+// Terms returns the terms of use (text, version, updatedAt), so they can be read before signing in. 404 when
+// there are none. GET /api/terms (public).
+func (h *SettingsHandler) Terms(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	terms := h.Current(r.Context()).Terms
+	if terms.Text == "" {
+		http.Error(w, `{"error":"there are no terms of use"}`, http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(terms)
+}
+
+// AcceptTerms records that the current user accepted the terms of use and returns their profile. 409 when the
+// version given is not the current one. POST /api/me/terms (auth).
+func (h *SettingsHandler) AcceptTerms(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+	var req AcceptTermsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error":"invalid json"}`, http.StatusBadRequest)
+		return
+	}
+	// Not the cached settings: terms just changed on another instance must not be accepted unseen.
+	s, err := h.DB.Settings(r.Context())
+	if err != nil {
+		http.Error(w, `{"error":"failed to load settings"}`, http.StatusInternalServerError)
+		return
+	}
+	if s.Terms.Text == "" {
+		http.Error(w, `{"error":"there are no terms of use"}`, http.StatusNotFound)
+		return
+	}
+	if req.Version != s.Terms.Version {
+		http.Error(w, `{"error":"the terms of use have changed; read them again","code":"TERMS_CHANGED"}`, http.StatusConflict)
+		return
+	}
+	if err := h.DB.SetUserTermsAccepted(r.Context(), userID, req.Version, h.Clock.Now()); err != nil {
+		http.Error(w, `{"error":"failed to accept the terms"}`, http.StatusInternalServerError)
+		return
+	}
+	h.remember(userID, req.Version)
+	user, err := h.DB.UserByID(r.Context(), userID)
+	if err != nil || user == nil {
+		http.Error(w, `{"error":"user not found"}`, http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(userToResponse(user))
+}
+
+// PendingTerms returns the version of the terms of use the user has yet to accept; 0 when there are no terms or
+// they accepted them. For middleware.RequireTerms. Acceptances are remembered, so only users who haven't accepted
+// are looked up on every request; if the user cannot be read, the request goes through.
+func (h *SettingsHandler) PendingTerms(ctx context.Context, userID primitive.ObjectID) int {
+	terms := h.Current(ctx).Terms
+	if terms.Text == "" {
+		return 0
+	}
+	h.mu.Lock()
+	accepted := h.accepted[userID]
+	h.mu.Unlock()
+	if accepted >= terms.Version {
+		return 0
+	}
+	user, err := h.DB.UserByID(ctx, userID)
+	if err != nil {
+		log.Printf("terms: %v", err)
+		return 0
+	}
+	if user == nil {
+		return 0
+	}
+	h.remember(userID, user.TermsVersion)
+	if user.TermsVersion >= terms.Version {
+		return 0
+	}
+	return terms.Version
+}
+
+func (h *SettingsHandler) remember(userID primitive.ObjectID, version int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.accepted == nil {
+		h.accepted = make(map[primitive.ObjectID]int)
+	}
+	h.accepted[userID] = version
+}
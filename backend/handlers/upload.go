@@ -2,26 +2,52 @@ package handlers
 
 import (
 	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
+	"log"
+	"mime/multipart"
 	"net/http"
+	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"sync"
 	"time"
+	"unicode"
 
+	"github.com/go-chi/chi/v5"
+	"github.com/kevinaaaquil/books/backend/events"
+	"github.com/kevinaaaquil/books/backend/jobs"
 	"github.com/kevinaaaquil/books/backend/middleware"
 	"github.com/kevinaaaquil/books/backend/models"
+	"github.com/kevinaaaquil/books/backend/search"
 	"github.com/kevinaaaquil/books/backend/service"
 	"github.com/kevinaaaquil/books/backend/store"
+	"github.com/kevinaaaquil/books/backend/tracing"
 	"github.com/kevinaaaquil/books/backend/utils"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.opentelemetry.io/otel/attribute"
 )
 
-// downloadImage fetches an image from url with a timeout. Returns body, Content-Type, and error.
-func downloadImage(url string, timeout time.Duration) ([]byte, string, error) {
-	client := &http.Client{Timeout: timeout}
-	resp, err := client.Get(url)
+// downloadImage fetches an image from url with client (nil = service.DefaultOutbound) and a timeout. Returns body,
+// Content-Type, and error.
+func downloadImage(client *http.Client, url string, timeout time.Duration) ([]byte, string, error) {
+	if client == nil {
+		client = service.DefaultOutbound
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	resp, err := client.Do(req)
 	if err != nil {
 		return nil, "", err
 	}
@@ -43,18 +69,70 @@ func downloadImage(url string, timeout time.Duration) ([]byte, string, error) {
 const (
 	contentTypeEPUB = "application/epub+zip"
 	contentTypePDF  = "application/pdf"
+	contentTypeMOBI = "application/x-mobipocket-ebook"
+	contentTypeAZW3 = "application/x-mobi8-ebook"
 )
 
 type UploadHandler struct {
-	DB        *store.DB
-	S3        *service.S3Service
+	DB        store.Store
+	Storage   service.ObjectStore
+	Metadata  service.MetadataProvider
+	Documents service.DOIProvider // looks up PDFs with a DOI; nil leaves them without metadata
+	Clock     service.Clock
 	MaxBytes  int64
+	Search    *search.Index // new books are added to it; may be nil
+	Client    *http.Client  // downloads metadata cover images; nil = service.DefaultOutbound
+	Events    events.Sink   // new books are exported to it; may be nil
+	TempDir   string        // where uploaded files are written while they are ingested; "" = os.TempDir()
+	Queue     *jobs.Queue   // runs the follow-up jobs for new books it has handlers for (JobClassify, JobEmbed); may be nil
 }
 
 type UploadResponse struct {
 	ID          string `json:"id"`
 	Title       string `json:"title,omitempty"`
 	NoISBNFound bool   `json:"noISBNFound,omitempty"` // true when EPUB had no ISBN so metadata was not fetched
+	// FailedSteps are the upload steps that failed after retries (see models.UploadStep*); the book was saved
+	// without them and POST /api/books/{id}/retry-upload runs them again.
+	FailedSteps []string `json:"failedSteps,omitempty"`
+	// Report describes how the upload went; absent when the book was already in the library.
+	Report *UploadReport `json:"report,omitempty"`
+}
+
+// Where an upload's cover came from (UploadReport.CoverSource).
+const (
+	CoverSourceFile     = "file"     // extracted from the book file
+	CoverSourceMetadata = "metadata" // downloaded from the metadata's cover URL
+)
+
+// UploadReport is a machine-readable account of an upload, for debugging ones that are slow or come out wrong.
+type UploadReport struct {
+	Format string `json:"format"`         // as detected from the file's content, else its name
+	ISBN   string `json:"isbn,omitempty"` // given with the file, or found in it
+	DOI    string `json:"doi,omitempty"`  // found in a PDF
+	// MetadataProvider is where the book's metadata came from (see service.BookMetadata.Provider), or the importer
+	// that supplied it; empty when there is none.
+	MetadataProvider string `json:"metadataProvider,omitempty"`
+	CoverSource      string `json:"coverSource,omitempty"` // CoverSourceFile or CoverSourceMetadata; empty without a cover
+	// Warnings are problems that did not stop the upload: unreadable or DRM-protected files, failed lookups, and
+	// files already in the library.
+	Warnings []string      `json:"warnings,omitempty"`
+	Timings  UploadTimings `json:"timings"`
+}
+
+// UploadTimings is how long each part of an upload took, in milliseconds. The file is stored while metadata and
+// the cover are fetched, so the parts add up to more than the total.
+type UploadTimings struct {
+	TotalMs     int64 `json:"totalMs"`
+	ParseMs     int64 `json:"parseMs"` // reading the file's format, checksum and identifiers
+	StoreFileMs int64 `json:"storeFileMs"`
+	MetadataMs  int64 `json:"metadataMs"` // the lookup, retries included
+	CoverMs     int64 `json:"coverMs"`
+	SaveMs      int64 `json:"saveMs"` // saving the book and indexing it
+}
+
+// uploadResponse is the response for a book Ingest added.
+func uploadResponse(res *IngestResult) UploadResponse {
+	return UploadResponse{ID: res.Book.ID.Hex(), Title: res.Book.Title, NoISBNFound: res.NoISBNFound, FailedSteps: res.FailedSteps, Report: &res.Report}
 }
 
 func (h *UploadHandler) Upload(w http.ResponseWriter, r *http.Request) {
@@ -70,74 +148,447 @@ func (h *UploadHandler) Upload(w http.ResponseWriter, r *http.Request) {
 	if h.MaxBytes > 0 {
 		r.Body = http.MaxBytesReader(w, r.Body, h.MaxBytes)
 	}
-	if err := r.ParseMultipartForm(h.MaxBytes); err != nil {
+	upload, form, err := h.readUploadForm(r)
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.As(err, &tooLarge):
+		http.Error(w, `{"error":"file too large"}`, http.StatusRequestEntityTooLarge)
+		return
+	case errors.Is(err, errUploadSpool):
+		http.Error(w, `{"error":"failed to read file"}`, http.StatusInternalServerError)
+		return
+	case err != nil:
 		http.Error(w, `{"error":"failed to parse multipart form"}`, http.StatusBadRequest)
 		return
 	}
-	file, header, err := r.FormFile("file")
-	if err != nil {
+	if upload == nil {
 		http.Error(w, `{"error":"missing file"}`, http.StatusBadRequest)
 		return
 	}
-	defer file.Close()
+	defer upload.remove()
+	override, err := uploadOverride(form)
+	if err != nil {
+		http.Error(w, `{"error":"`+err.Error()+`"}`, http.StatusBadRequest)
+		return
+	}
 
-	if h.S3 == nil {
-		http.Error(w, `{"error":"upload not configured (missing S3)"}`, http.StatusServiceUnavailable)
+	if h.Storage == nil {
+		http.Error(w, `{"error":"upload not configured (missing storage)"}`, http.StatusServiceUnavailable)
+		return
+	}
+	if storageUnavailable(w, service.StorageErr(h.Storage)) {
 		return
 	}
-	ext := strings.ToLower(strings.TrimSpace(filepath.Ext(header.Filename)))
-	partContentType := header.Header.Get("Content-Type")
 
-	allowedByExt := ext == ".epub" || ext == ".pdf"
-	allowedByMime := strings.HasPrefix(partContentType, "application/epub+zip") || strings.HasPrefix(partContentType, "application/pdf")
-	if !allowedByExt && !allowedByMime {
-		http.Error(w, `{"error":"only epub and pdf are allowed"}`, http.StatusBadRequest)
+	source := models.SourceUpload
+	if middleware.ViaFromContext(r.Context()) == models.SourceTelegram {
+		source = models.SourceTelegram
+	}
+	res, err := h.Ingest(r.Context(), IngestFile{
+		Name:        upload.name,
+		ContentType: upload.contentType,
+		File:        upload.file,
+		Size:        upload.size,
+		SHA256:      upload.sha256,
+		UploadedBy:  middleware.EmailFromContext(r.Context()),
+		Override:    override,
+		Source:      source,
+	})
+	switch {
+	case errors.Is(err, ErrUnsupportedFormat):
+		http.Error(w, `{"error":"only epub, pdf, mobi and azw3 are allowed"}`, http.StatusBadRequest)
+		return
+	case errors.Is(err, errStoreFile):
+		http.Error(w, `{"error":"failed to upload to storage"}`, http.StatusInternalServerError)
+		return
+	case err != nil:
+		http.Error(w, `{"error":"failed to save book record"}`, http.StatusInternalServerError)
 		return
 	}
 
-	fileBytes, err := io.ReadAll(file)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(uploadResponse(res))
+}
+
+// uploadFieldMaxBytes caps each form field of an upload other than the file.
+const uploadFieldMaxBytes = 64 << 10
+
+// errUploadSpool is returned by readUploadForm when the file can't be written to disk.
+var errUploadSpool = errors.New("failed to spool the upload")
+
+// spooledUpload is the file of an upload form, written to a temporary file as it was read.
+type spooledUpload struct {
+	name        string
+	contentType string
+	file        *os.File
+	size        int64
+	sha256      string
+}
+
+func (u *spooledUpload) remove() {
+	u.file.Close()
+	os.Remove(u.file.Name())
+}
+
+// readUploadForm reads an upload form part by part: the first part named file that is a file goes to a temporary
+// file in h.TempDir, hashed on the way, so no upload is held in memory whatever its size; the other fields are
+// returned as the form's values. The upload is nil when there is no file.
+func (h *UploadHandler) readUploadForm(r *http.Request) (*spooledUpload, *multipart.Form, error) {
+	mr, err := r.MultipartReader()
 	if err != nil {
-		http.Error(w, `{"error":"failed to read file"}`, http.StatusInternalServerError)
-		return
+		return nil, nil, err
+	}
+	form := &multipart.Form{Value: map[string][]string{}}
+	var upload *spooledUpload
+	fail := func(err error) (*spooledUpload, *multipart.Form, error) {
+		if upload != nil {
+			upload.remove()
+		}
+		return nil, nil, err
+	}
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			return upload, form, nil
+		}
+		if err != nil {
+			return fail(err)
+		}
+		switch {
+		case part.FileName() == "":
+			value, err := io.ReadAll(io.LimitReader(part, uploadFieldMaxBytes+1))
+			if err != nil {
+				return fail(err)
+			}
+			if len(value) > uploadFieldMaxBytes {
+				return fail(fmt.Errorf("form field %s too long", part.FormName()))
+			}
+			form.Value[part.FormName()] = append(form.Value[part.FormName()], string(value))
+		case part.FormName() == "file" && upload == nil:
+			tmp, err := os.CreateTemp(h.TempDir, "upload-*")
+			if err != nil {
+				log.Printf("upload: %v", err)
+				return fail(errUploadSpool)
+			}
+			upload = &spooledUpload{name: part.FileName(), contentType: part.Header.Get("Content-Type"), file: tmp}
+			sum := sha256.New()
+			upload.size, err = io.Copy(io.MultiWriter(tmp, sum), part)
+			// Writing the temporary file fails with a path error; reading the request, with anything else.
+			var pathErr *fs.PathError
+			switch {
+			case errors.As(err, &pathErr):
+				log.Printf("upload: %v", err)
+				return fail(errUploadSpool)
+			case err != nil:
+				return fail(err)
+			}
+			upload.sha256 = hex.EncodeToString(sum.Sum(nil))
+		}
+		part.Close()
+	}
+}
+
+// IngestFile is a book file to add to the library.
+type IngestFile struct {
+	Name        string // original file name; its extension decides the format of files not recognized by content
+	ContentType string // used when the name has neither extension; may be empty
+	Data        []byte // the file, unless File is set
+	// File is the file when it is on disk (see Upload), read as needed rather than held in memory; Size is its
+	// length and SHA256, if set, its checksum computed already.
+	File       io.ReaderAt
+	Size       int64
+	SHA256     string
+	UploadedBy string                // recorded on the book
+	Metadata   *service.BookMetadata // known already, e.g. from arXiv; skips the metadata lookup
+	Override   UploadOverride        // given by the uploader; wins over the file and the lookup
+	// Source, SourceURL and SourcePath record where the file came from (see models.Book).
+	Source     string
+	SourceURL  string
+	SourcePath string
+}
+
+// UploadOverride is metadata sent with a file, for scripts that know better than the file or the lookup.
+type UploadOverride struct {
+	Title   string
+	Authors []string
+	ISBN    string   // normalized; looked up instead of the one in the file, also for PDFs
+	Tags    []string // replace the looked-up categories
+}
+
+// uploadOverride reads the optional title, authors, isbn, tags and collection fields of an upload form. authors and
+// tags may be repeated or list several separated by ";". collection is a tag collection ("tag:<tag>", or just the
+// tag), added to the tags.
+func uploadOverride(form *multipart.Form) (UploadOverride, error) {
+	value := func(name string) string {
+		if v := form.Value[name]; len(v) > 0 {
+			return strings.TrimSpace(v[0])
+		}
+		return ""
+	}
+	list := func(name string) []string {
+		var out []string
+		for _, v := range form.Value[name] {
+			for _, s := range strings.Split(v, ";") {
+				if s = strings.TrimSpace(s); s != "" && !slices.Contains(out, s) {
+					out = append(out, s)
+				}
+			}
+		}
+		return out
+	}
+	o := UploadOverride{Title: value("title"), Authors: list("authors"), Tags: list("tags")}
+	if s := value("isbn"); s != "" {
+		if o.ISBN = normalizeISBN(s); o.ISBN == "" {
+			return o, errors.New("isbn must be an ISBN-10 or ISBN-13")
+		}
+	}
+	switch c := value("collection"); {
+	case c == "" || c == collectionAll:
+	case strings.HasPrefix(c, collectionShelfPre):
+		return o, errors.New("books are put on shelves by reading them; collection must be a tag")
+	default:
+		if tag := strings.TrimSpace(strings.TrimPrefix(c, collectionTagPre)); tag != "" && !slices.Contains(o.Tags, tag) {
+			o.Tags = append(o.Tags, tag)
+		}
+	}
+	return o, nil
+}
+
+// apply sets the overridden fields of book.
+func (o UploadOverride) apply(book *models.Book) {
+	if o.Title != "" {
+		book.Title = o.Title
+	}
+	if len(o.Authors) > 0 {
+		book.Authors = o.Authors
+	}
+	if o.ISBN != "" {
+		book.ISBN = o.ISBN
+	}
+	if len(o.Tags) > 0 {
+		book.Category, book.Categories = o.Tags[0], o.Tags
 	}
+}
+
+// IngestResult is a book added by Ingest.
+type IngestResult struct {
+	Book        *models.Book
+	NoISBNFound bool     // the EPUB had no ISBN, so no metadata was fetched
+	FailedSteps []string // upload steps that failed after retries; see UploadResponse.FailedSteps
+	Report      UploadReport
+}
 
-	s3Prefix := "books/"
-	contentType := contentTypePDF
-	format := "pdf"
-	if ext == ".epub" || strings.HasPrefix(partContentType, "application/epub+zip") {
-		contentType = contentTypeEPUB
-		format = "epub"
+var (
+	// ErrUnsupportedFormat is returned by Ingest for files that are not EPUB, PDF, MOBI or AZW3.
+	ErrUnsupportedFormat = errors.New("only epub, pdf, mobi and azw3 are allowed")
+	errStoreFile         = errors.New("failed to upload to storage")
+	errSaveBook          = errors.New("failed to save book record")
+)
+
+// uploadFormat returns the format and content type of the file data named name, sent as partContentType, and
+// whether it is one that can be uploaded. The magic bytes decide first; MOBI and AZW3 (KF8) files are only
+// recognized that way, whatever their name. EPUBs and PDFs that don't start as they should go by the name or
+// content type, and are saved with a parse error.
+func uploadFormat(name, partContentType string, data []byte) (format, contentType string, ok bool) {
+	switch {
+	case utils.IsMOBI(data):
+		if info, err := utils.ParseMOBI(data); err == nil && info.Format == "azw3" {
+			return "azw3", contentTypeAZW3, true
+		}
+		return "mobi", contentTypeMOBI, true
+	case bytes.HasPrefix(data, []byte("PK\x03\x04")) && bytes.HasPrefix(data[min(30, len(data)):], []byte("mimetype"+contentTypeEPUB)):
+		return "epub", contentTypeEPUB, true
+	case bytes.Contains(data[:min(1024, len(data))], []byte("%PDF-")):
+		return "pdf", contentTypePDF, true
+	}
+	ext := strings.ToLower(strings.TrimSpace(filepath.Ext(name)))
+	switch {
+	case ext == ".epub" || strings.HasPrefix(partContentType, contentTypeEPUB):
+		return "epub", contentTypeEPUB, true
+	case ext == ".pdf" || strings.HasPrefix(partContentType, contentTypePDF):
+		return "pdf", contentTypePDF, true
 	}
+	return "", "", false
+}
 
-	uploadedBy := middleware.EmailFromContext(r.Context())
-	fileNameTitle := strings.TrimSuffix(header.Filename, filepath.Ext(header.Filename))
+// bookContentType is the content type of a book file in format.
+func bookContentType(format string) string {
+	switch format {
+	case "pdf":
+		return contentTypePDF
+	case "mobi":
+		return contentTypeMOBI
+	case "azw3":
+		return contentTypeAZW3
+	}
+	return contentTypeEPUB
+}
 
-	var noISBNFound bool
-	var bookKey string
+// uploadSniffBytes is how much of a file on disk is read to tell its format.
+const uploadSniffBytes = 1024
+
+// bookFile is the content of a book file being ingested, read through r. data holds all of it when it is in
+// memory, and for MOBIs and AZW3s, which are only parsed there (they are seldom large); otherwise the start.
+type bookFile struct {
+	r    io.ReaderAt
+	size int64
+	data []byte
+}
+
+func memoryFile(data []byte) bookFile {
+	return bookFile{r: bytes.NewReader(data), size: int64(len(data)), data: data}
+}
+
+// openBookFile returns the content of f: its Data, or its File read up to what telling its format needs.
+func openBookFile(f IngestFile) (bookFile, error) {
+	if f.File == nil {
+		return memoryFile(f.Data), nil
+	}
+	b := bookFile{r: f.File, size: f.Size, data: make([]byte, min(f.Size, uploadSniffBytes))}
+	if _, err := f.File.ReadAt(b.data, 0); err != nil && err != io.EOF {
+		return b, err
+	}
+	if utils.IsMOBI(b.data) {
+		b.data = make([]byte, f.Size)
+		if _, err := f.File.ReadAt(b.data, 0); err != nil && err != io.EOF {
+			return b, err
+		}
+	}
+	return b, nil
+}
+
+// fileCover returns the cover image embedded in a book file, or nil when it has none. PDFs have none.
+func fileCover(file bookFile, format string) ([]byte, string) {
+	switch format {
+	case "epub":
+		if img, contentType, err := utils.ExtractCoverFromEPUB(file.r, file.size); err == nil && len(img) > 0 {
+			return img, contentType
+		}
+	case "mobi", "azw3":
+		if info, err := utils.ParseMOBI(file.data); err == nil {
+			return utils.MOBICover(file.data, info)
+		}
+	}
+	return nil, ""
+}
+
+// mobiMetadata is the metadata a MOBI or AZW3 file carries, used when none is looked up.
+func mobiMetadata(info *utils.MOBIInfo) *service.BookMetadata {
+	meta := &service.BookMetadata{
+		Title:       info.Title,
+		Authors:     info.Authors,
+		Publisher:   info.Publisher,
+		PublishDate: info.PublishDate,
+		ISBN:        info.ISBN,
+		Preface:     info.Description,
+		Categories:  info.Subjects,
+		Provider:    "file",
+	}
+	// EXTH dates are often full timestamps ("2011-03-01T00:00:00+00:00").
+	if len(meta.PublishDate) > 10 && meta.PublishDate[4] == '-' && meta.PublishDate[7] == '-' {
+		meta.PublishDate = meta.PublishDate[:10]
+	}
+	if len(meta.Categories) > 0 {
+		meta.Category = meta.Categories[0]
+	}
+	return meta
+}
+
+// Ingest runs the upload pipeline on f: it stores the file and, for EPUBs, MOBIs and AZW3s, looks up metadata by
+// ISBN and stores the cover (for PDFs, looks up metadata by the DOI in them), then saves the book and adds it to
+// the search index. MOBIs and AZW3s without an ISBN, or whose lookup fails, keep the metadata in their EXTH header.
+// f.Override's ISBN is looked up instead of the file's, and its other fields are applied last.
+// Failed metadata and cover steps leave the book saved without them (see RetryUpload); a file that can't be stored
+// or a book that can't be saved fails the whole upload and nothing is kept.
+func (h *UploadHandler) Ingest(ctx context.Context, f IngestFile) (*IngestResult, error) {
+	start := time.Now()
+	_, parseSpan := tracing.Start(ctx, "upload parse")
+	defer parseSpan.End() // on the way out when the file is rejected; ended below otherwise
+	file, err := openBookFile(f)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", errStoreFile, err)
+	}
+	format, contentType, ok := uploadFormat(f.Name, f.ContentType, file.data)
+	if !ok {
+		return nil, ErrUnsupportedFormat
+	}
+	if h.Storage == nil {
+		return nil, fmt.Errorf("%w: storage not configured", errStoreFile)
+	}
+	fileInfo, fileParseErr := utils.ComputeFileInfoAt(file.r, file.size, f.SHA256, format)
+	fileNameTitle := strings.TrimSuffix(f.Name, filepath.Ext(f.Name))
+
+	var noISBNFound, matchedByTitle bool
+	var findIDs time.Duration // reading the ISBN or DOI, in the metadata goroutine
+	var parseErr, metadataErr string
+	var bookKey, coverS3Key, isbn, doi string
 	var bookKeyErr error
 	var meta *service.BookMetadata
-	var coverS3Key string
 	var wg sync.WaitGroup
+	p := newUploadPipeline(ctx, h.Storage, nil)
 
 	// Run book S3 upload in parallel with metadata and cover work so total time ≈ max(book upload, metadata, cover).
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		k, e := h.S3.Upload(r.Context(), s3Prefix, header.Filename, bytes.NewReader(fileBytes), contentType)
-		bookKey, bookKeyErr = k, e
+		bookKeyErr = p.run(models.UploadStepStoreFile, func(ctx context.Context) error {
+			k, err := h.Storage.UploadWithSHA256(ctx, "books/", f.Name, io.NewSectionReader(file.r, 0, file.size), contentType, fileInfo.SHA256)
+			if err != nil {
+				return err
+			}
+			bookKey = k
+			p.track(k)
+			return nil
+		})
 	}()
 
-	if format == "epub" {
+	if f.Metadata != nil {
+		meta = f.Metadata
+	}
+	var mobi *utils.MOBIInfo
+	if format == "mobi" || format == "azw3" {
+		mobi, _ = utils.ParseMOBI(file.data) // recognized by uploadFormat, so it parses
+		if mobi.Encrypted {
+			parseErr = "the file is DRM-protected"
+		}
+	}
+	parsed := time.Since(start)
+	parseSpan.SetAttributes(attribute.String("books.format", format), attribute.Int64("books.file.size", file.size))
+	parseSpan.End()
+	if format == "epub" || mobi != nil || f.Override.ISBN != "" {
 		wg.Add(2)
-
 		go func() {
 			defer wg.Done()
-			isbn, err := utils.ExtractISBNFromMultipartFile(bytes.NewReader(fileBytes))
-			if err != nil || isbn == "" {
+			if meta != nil {
+				return
+			}
+			if f.Override.ISBN != "" {
+				isbn = f.Override.ISBN
+			} else if mobi != nil {
+				isbn = mobi.ISBN
+			} else {
+				t := time.Now()
+				var err error
+				isbn, err = utils.ExtractISBNFromEPUB(file.r, file.size)
+				if err != nil && !errors.Is(err, utils.ErrNoISBN) {
+					parseErr = err.Error()
+				}
+				findIDs = time.Since(t)
+			}
+			if isbn == "" {
+				if format == "epub" {
+					m, err := h.titleMatch(ctx, file)
+					if err != nil {
+						metadataErr = err.Error()
+					}
+					meta, matchedByTitle = m, m != nil
+				}
 				return
 			}
-			m, err := service.FetchMetadataByISBN(isbn)
+			m, err := p.fetchMetadata(h.Metadata, nil, isbn, "")
 			if err != nil {
+				metadataErr = err.Error()
 				return
 			}
 			meta = m
@@ -145,83 +596,332 @@ func (h *UploadHandler) Upload(w http.ResponseWriter, r *http.Request) {
 
 		go func() {
 			defer wg.Done()
-			coverBytes, coverContentType, err := utils.ExtractCoverFromEPUBBytes(fileBytes)
-			if err != nil || len(coverBytes) == 0 {
+			coverBytes, coverContentType := fileCover(file, format)
+			if len(coverBytes) == 0 {
 				return
 			}
-			coverExt := ".jpg"
-			if strings.Contains(coverContentType, "png") {
-				coverExt = ".png"
+			coverS3Key, _ = p.storeCover(func() ([]byte, string, error) { return coverBytes, coverContentType, nil })
+		}()
+	}
+	if format == "pdf" && h.Documents != nil && meta == nil && f.Override.ISBN == "" {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			t := time.Now()
+			doi = utils.ExtractDOIFromPDFAt(file.r, file.size)
+			if findIDs = time.Since(t); doi == "" {
+				return
 			}
-			key, err := h.S3.Upload(r.Context(), "books/covers/", "cover"+coverExt, bytes.NewReader(coverBytes), coverContentType)
+			m, err := p.fetchMetadata(h.Metadata, h.Documents, "", doi)
 			if err != nil {
+				metadataErr = err.Error()
 				return
 			}
-			coverS3Key = key
+			meta = m
 		}()
 	}
 
 	wg.Wait()
 
+	if parseErr == "" && fileParseErr != nil {
+		parseErr = fileParseErr.Error()
+	}
+
 	if bookKeyErr != nil {
-		http.Error(w, `{"error":"failed to upload to storage"}`, http.StatusInternalServerError)
-		return
+		p.compensate()
+		return nil, fmt.Errorf("%w: %w", errStoreFile, bookKeyErr)
 	}
 
 	book := &models.Book{
+		ID:              primitive.NewObjectID(),
 		Format:          format,
 		S3Key:           bookKey,
-		OriginalName:    header.Filename,
-		UploadedByEmail: uploadedBy,
-		CreatedAt:       time.Now(),
+		OriginalName:    f.Name,
+		FileInfo:        fileInfo,
+		UploadedByEmail: f.UploadedBy,
+		Source:          f.Source,
+		SourceURL:       f.SourceURL,
+		SourcePath:      f.SourcePath,
+		CreatedAt:       h.Clock.Now(),
 		Title:           fileNameTitle,
+		ISBN:            isbn, // kept when the lookup fails so it can be retried
+		DOI:             doi,
+		MetadataError:   metadataErr,
+		ParseError:      parseErr,
 	}
 
-	if format == "epub" {
+	if format != "pdf" {
+		if meta == nil && mobi != nil {
+			noISBNFound = mobi.ISBN == ""
+			meta = mobiMetadata(mobi)
+		}
 		if meta != nil {
-			if meta.Title != "" {
-				book.Title = meta.Title
-			}
-			book.Authors = meta.Authors
-			book.Publisher = meta.Publisher
-			book.PublishDate = meta.PublishDate
-			book.ISBN = meta.ISBN
-			book.PageCount = meta.PageCount
-			book.CoverURL = meta.CoverURL
-			book.ThumbnailURL = meta.ThumbnailURL
-			book.Edition = meta.Edition
-			book.Preface = meta.Preface
-			book.Category = meta.Category
-			book.Categories = meta.Categories
-			book.RatingAverage = meta.RatingAverage
-			book.RatingCount = meta.RatingCount
+			applyMetadata(book, meta)
 		} else {
 			noISBNFound = true
 		}
-		if coverS3Key != "" {
-			book.CoverS3Key = coverS3Key
-		} else if meta != nil && meta.CoverURL != "" {
+		book.CoverS3Key = coverS3Key
+		if coverS3Key == "" && meta != nil && meta.CoverURL != "" {
 			// Store API cover in S3 so we don't depend on slow/unreliable external URLs when displaying.
-			if imgBytes, contentType, err := downloadImage(meta.CoverURL, 10*time.Second); err == nil && len(imgBytes) > 0 {
-				ext := ".jpg"
-				if strings.Contains(contentType, "png") {
-					ext = ".png"
-				}
-				if apiCoverKey, err := h.S3.Upload(r.Context(), "books/covers/", "cover"+ext, bytes.NewReader(imgBytes), contentType); err == nil {
-					book.CoverS3Key = apiCoverKey
-				}
+			book.CoverS3Key, _ = p.storeCover(func() ([]byte, string, error) { return downloadImage(h.Client, meta.CoverURL, 10*time.Second) })
+		}
+	}
+
+	if format == "pdf" && meta != nil {
+		applyMetadata(book, meta)
+	}
+	f.Override.apply(book)
+
+	report := UploadReport{Format: format, ISBN: isbn, DOI: doi}
+	if meta != nil {
+		if report.MetadataProvider = meta.Provider; report.MetadataProvider == "" && f.Metadata != nil {
+			report.MetadataProvider = f.Source
+		}
+	}
+	switch {
+	case coverS3Key != "":
+		report.CoverSource = CoverSourceFile
+	case book.CoverS3Key != "":
+		report.CoverSource = CoverSourceMetadata
+	}
+	for _, w := range []string{parseErr, metadataErr} {
+		if w != "" {
+			report.Warnings = append(report.Warnings, w)
+		}
+	}
+	if noISBNFound && isbn == "" {
+		report.Warnings = append(report.Warnings, "no ISBN in the file, so no metadata was looked up")
+	}
+	if matchedByTitle {
+		report.Warnings = append(report.Warnings, "no ISBN in the file; the metadata was matched by title and author, so check it is the right book")
+	}
+	if dup, err := h.DB.BookBySHA256(ctx, fileInfo.SHA256); err == nil && dup != nil {
+		report.Warnings = append(report.Warnings, fmt.Sprintf("the same file is already in the library as %q (%s)", dup.Title, dup.ID.Hex()))
+	}
+
+	saveStart := time.Now()
+	saveCtx, saveSpan := tracing.Start(ctx, "upload save")
+	defer saveSpan.End()
+	book.UploadSteps = p.outcomes()
+	// The ID is chosen here so that a retried insert whose first attempt was applied is not saved twice.
+	if _, err := retry(saveCtx, func() error {
+		_, err := h.DB.InsertBook(saveCtx, book)
+		if err != nil {
+			if saved, _ := h.DB.BookByID(saveCtx, book.ID); saved != nil {
+				return nil
 			}
 		}
+		return err
+	}); err != nil {
+		tracing.SetError(saveSpan, err)
+		p.compensate()
+		return nil, fmt.Errorf("%w: %w", errSaveBook, err)
+	}
+	if h.Search != nil {
+		text := ""
+		if format == "epub" {
+			text, _ = utils.EPUBTextAt(file.r, file.size)
+		}
+		h.Search.Put(book, text)
 	}
+	saveSpan.End()
+	emitEvent(h.Events, events.New(events.TypeBookCreated, book.CreatedAt, eventUserID(ctx), bookEvent(book)))
+	h.enqueueFollowUps(ctx, book)
+	report.Timings = UploadTimings{
+		TotalMs:     time.Since(start).Milliseconds(),
+		ParseMs:     (parsed + findIDs).Milliseconds(),
+		StoreFileMs: p.duration(models.UploadStepStoreFile).Milliseconds(),
+		MetadataMs:  p.duration(models.UploadStepMetadata).Milliseconds(),
+		CoverMs:     p.duration(models.UploadStepCover).Milliseconds(),
+		SaveMs:      time.Since(saveStart).Milliseconds(),
+	}
+	return &IngestResult{Book: book, NoISBNFound: noISBNFound, FailedSteps: p.failedSteps(), Report: report}, nil
+}
+
+// titleMatchCandidates is how many books a title search for an upload without an ISBN looks through.
+const titleMatchCandidates = 5
 
-	id, err := h.DB.InsertBook(r.Context(), book)
+// titleMatch looks up an EPUB without an ISBN by the title and first author in its OPF, returning the first result
+// whose title and authors agree with the file's; nil when none do or the metadata provider can't search by title.
+func (h *UploadHandler) titleMatch(ctx context.Context, file bookFile) (*service.BookMetadata, error) {
+	search, ok := service.TitleSearcherOf(h.Metadata)
+	if !ok {
+		return nil, nil
+	}
+	title, authors, err := utils.EPUBTitleAuthorsAt(file.r, file.size)
+	if err != nil || title == "" {
+		return nil, nil
+	}
+	author := ""
+	if len(authors) > 0 {
+		author = authors[0]
+	}
+	found, err := search.SearchByTitle(ctx, title, author, titleMatchCandidates)
 	if err != nil {
-		http.Error(w, `{"error":"failed to save book record"}`, http.StatusInternalServerError)
+		return nil, err
+	}
+	for _, m := range found {
+		if sameTitle(title, m.Title) && sameAuthors(authors, m.Authors) {
+			return &m, nil
+		}
+	}
+	return nil, nil
+}
+
+// matchKey lowercases s and keeps only its letters and digits, single-spaced.
+func matchKey(s string) string {
+	return strings.Join(strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}), " ")
+}
+
+// sameTitle reports whether a looked-up title is the file's, allowing for the subtitle one of them may add.
+func sameTitle(file, found string) bool {
+	a, b := matchKey(file), matchKey(found)
+	if a == "" || b == "" {
+		return false
+	}
+	return a == b || strings.HasPrefix(b, a+" ") || strings.HasPrefix(a, b+" ")
+}
+
+// sameAuthors reports whether any looked-up author has the surname of one of the file's; true when the file names
+// no authors.
+func sameAuthors(file, found []string) bool {
+	if len(file) == 0 {
+		return true
+	}
+	surname := func(name string) string {
+		if last, _, ok := strings.Cut(name, ","); ok { // "Austen, Jane"
+			return matchKey(last)
+		}
+		words := strings.Fields(matchKey(name))
+		if len(words) == 0 {
+			return ""
+		}
+		return words[len(words)-1]
+	}
+	for _, a := range file {
+		for _, b := range found {
+			if s := surname(a); s != "" && s == surname(b) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// RetryUpload runs the upload steps that failed for a book again: the metadata lookup by the book's DOI or ISBN, and
+// storing a cover (from the EPUB, else the metadata cover URL, so also after a successful lookup). Returns the updated book; its uploadSteps show what
+// still failed. POST /api/books/{id}/retry-upload (admin, editor). 409 if no step failed.
+func (h *UploadHandler) RetryUpload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
-	book.ID = id
+	if _, ok := middleware.UserIDFromContext(r.Context()); !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+	id, err := primitive.ObjectIDFromHex(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, `{"error":"invalid book id"}`, http.StatusBadRequest)
+		return
+	}
+	book, err := h.DB.BookByID(r.Context(), id)
+	if err != nil {
+		http.Error(w, `{"error":"book not found"}`, http.StatusNotFound)
+		return
+	}
+	if !models.UploadIncomplete(book.UploadSteps) {
+		http.Error(w, `{"error":"no failed upload steps"}`, http.StatusConflict)
+		return
+	}
+	if h.Storage == nil {
+		http.Error(w, `{"error":"storage not configured"}`, http.StatusServiceUnavailable)
+		return
+	}
+	p := newUploadPipeline(r.Context(), h.Storage, book.UploadSteps)
+
+	var gotMetadata bool
+	if p.failed(models.UploadStepMetadata) {
+		isbn := strings.ReplaceAll(strings.TrimSpace(book.ISBN), "-", "")
+		meta, err := p.fetchMetadata(h.Metadata, h.Documents, isbn, book.DOI)
+		if err == nil {
+			gotMetadata = true
+			book.MetadataError = ""
+			applyMetadata(book, meta)
+			err = h.DB.UpdateBookMetadata(r.Context(), id, book)
+		} else {
+			err = h.DB.SetBookMetadataError(r.Context(), id, err.Error())
+		}
+		if err != nil {
+			http.Error(w, `{"error":"failed to update book"}`, http.StatusInternalServerError)
+			return
+		}
+	}
+
+	// As at upload, a cover from the metadata is stored when the file has none.
+	if book.CoverS3Key == "" && (p.failed(models.UploadStepCover) || gotMetadata && book.CoverURL != "") {
+		key, err := p.storeCover(func() ([]byte, string, error) { return h.coverFor(r.Context(), book) })
+		if err == nil {
+			if err := h.DB.SetBookCover(r.Context(), id, key); err != nil {
+				p.compensate()
+				http.Error(w, `{"error":"failed to update book"}`, http.StatusInternalServerError)
+				return
+			}
+			book.CoverS3Key = key
+		}
+	}
 
+	book.UploadSteps = p.outcomes()
+	if err := h.DB.SetBookUploadSteps(r.Context(), id, book.UploadSteps); err != nil {
+		http.Error(w, `{"error":"failed to update book"}`, http.StatusInternalServerError)
+		return
+	}
+	if h.Search != nil {
+		h.Search.PutMetadata(book)
+	}
+	setCoverURLIfExtracted(book)
+	setContentRating(book)
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(UploadResponse{ID: id.Hex(), Title: book.Title, NoISBNFound: noISBNFound})
+	json.NewEncoder(w).Encode(book)
+}
+
+// coverFor returns the cover image for a stored book: the one in its EPUB, else the one at its metadata cover URL.
+func (h *UploadHandler) coverFor(ctx context.Context, book *models.Book) ([]byte, string, error) {
+	if book.Format != "pdf" {
+		body, _, err := h.Storage.GetObject(ctx, book.S3Key)
+		if err != nil {
+			return nil, "", err
+		}
+		fileBytes, err := io.ReadAll(body)
+		body.Close()
+		if err != nil {
+			return nil, "", err
+		}
+		if img, contentType := fileCover(memoryFile(fileBytes), book.Format); len(img) > 0 {
+			return img, contentType, nil
+		}
+	}
+	if book.CoverURL == "" {
+		return nil, "", &permanentError{errors.New("no cover in the file and no cover URL")}
+	}
+	return downloadImage(h.Client, book.CoverURL, 10*time.Second)
+}
+
+// enqueueFollowUps queues the background work for a new book: category suggestions when it has no categories, and
+// its embedding for semantic search, each when the queue has a handler for it.
+func (h *UploadHandler) enqueueFollowUps(ctx context.Context, book *models.Book) {
+	if h.Queue == nil {
+		return
+	}
+	userID, _ := middleware.UserIDFromContext(ctx)
+	for _, kind := range []string{JobClassify, JobEmbed} {
+		if !h.Queue.Handles(kind) || kind == JobClassify && len(book.Categories) > 0 {
+			continue
+		}
+		if _, err := h.Queue.Enqueue(ctx, kind, userID, map[string]string{"bookId": book.ID.Hex()}); err != nil {
+			log.Printf("upload %s: queue %s: %v", book.ID.Hex(), kind, err)
+		}
+	}
 }
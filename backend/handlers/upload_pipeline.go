@@ -0,0 +1,215 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kevinaaaquil/books/backend/models"
+	"github.com/kevinaaaquil/books/backend/service"
+	"github.com/kevinaaaquil/books/backend/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const (
+	// uploadAttempts is how many times an upload step is tried before it is recorded as failed.
+	uploadAttempts = 3
+	// uploadRetryDelay is the wait before the second attempt; it doubles after each failure.
+	uploadRetryDelay = 200 * time.Millisecond
+)
+
+// permanentError marks a step error that retrying cannot fix, such as an ISBN unknown to the metadata provider.
+type permanentError struct{ err error }
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// retry calls fn until it succeeds, returns a permanentError or has been tried uploadAttempts times, and returns
+// the number of attempts made.
+func retry(ctx context.Context, fn func() error) (int, error) {
+	delay := uploadRetryDelay
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		var perm *permanentError
+		if errors.As(err, &perm) {
+			return attempt, perm.err
+		}
+		if err == nil || attempt == uploadAttempts {
+			return attempt, err
+		}
+		select {
+		case <-ctx.Done():
+			return attempt, err
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+// uploadPipeline runs the steps of an upload, retrying each and recording its outcome, and remembers the objects
+// it stored so they can be deleted if the upload fails for good. Steps may run concurrently.
+type uploadPipeline struct {
+	ctx     context.Context
+	storage service.ObjectStore
+
+	mu     sync.Mutex
+	steps  []models.UploadStep
+	took   map[string]time.Duration // by step, across its attempts
+	stored []string
+}
+
+// newUploadPipeline starts with every step skipped. steps, if set, are the outcomes of an earlier run to carry on from.
+func newUploadPipeline(ctx context.Context, storage service.ObjectStore, steps []models.UploadStep) *uploadPipeline {
+	p := &uploadPipeline{ctx: ctx, storage: storage}
+	for _, name := range []string{models.UploadStepStoreFile, models.UploadStepMetadata, models.UploadStepCover} {
+		p.steps = append(p.steps, models.UploadStep{Name: name, Status: models.UploadStepSkipped})
+	}
+	for _, s := range steps {
+		*p.step(s.Name) = s
+	}
+	return p
+}
+
+// step returns the record for name. Callers hold p.mu, or own p.
+func (p *uploadPipeline) step(name string) *models.UploadStep {
+	for i := range p.steps {
+		if p.steps[i].Name == name {
+			return &p.steps[i]
+		}
+	}
+	p.steps = append(p.steps, models.UploadStep{Name: name})
+	return &p.steps[len(p.steps)-1]
+}
+
+// failed reports whether step name failed.
+func (p *uploadPipeline) failed(name string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.step(name).Status == models.UploadStepFailed
+}
+
+// outcomes returns a copy of the step outcomes.
+func (p *uploadPipeline) outcomes() []models.UploadStep {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]models.UploadStep(nil), p.steps...)
+}
+
+// failedSteps returns the names of the steps that failed.
+func (p *uploadPipeline) failedSteps() []string {
+	var names []string
+	for _, s := range p.outcomes() {
+		if s.Status == models.UploadStepFailed {
+			names = append(names, s.Name)
+		}
+	}
+	return names
+}
+
+// duration returns how long step name has taken in this run, retries included.
+func (p *uploadPipeline) duration(name string) time.Duration {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.took[name]
+}
+
+// run runs step name with retries and records its outcome, adding to the attempts of an earlier run. fn is given
+// the context of the step's span (see tracing), for the calls it makes.
+func (p *uploadPipeline) run(name string, fn func(ctx context.Context) error) error {
+	start := time.Now()
+	ctx, span := tracing.Start(p.ctx, "upload "+name)
+	attempts, err := retry(ctx, func() error { return fn(ctx) })
+	span.SetAttributes(attribute.Int("books.upload.attempts", attempts))
+	tracing.SetError(span, err)
+	span.End()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.took == nil {
+		p.took = map[string]time.Duration{}
+	}
+	p.took[name] += time.Since(start)
+	s := p.step(name)
+	s.Attempts += attempts
+	s.Status, s.Error = models.UploadStepDone, ""
+	if err != nil {
+		s.Status, s.Error = models.UploadStepFailed, err.Error()
+	}
+	return err
+}
+
+// track remembers a stored object for compensate.
+func (p *uploadPipeline) track(key string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.stored = append(p.stored, key)
+}
+
+// compensate deletes the objects stored so far, after the book record could not be saved. It runs even when the
+// request was cancelled.
+func (p *uploadPipeline) compensate() {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(p.ctx), 30*time.Second)
+	defer cancel()
+	p.mu.Lock()
+	keys := p.stored
+	p.stored = nil
+	p.mu.Unlock()
+	for _, key := range keys {
+		if err := p.storage.Delete(ctx, key); err != nil {
+			log.Printf("upload: delete %s after failed upload: %v", key, err)
+		}
+	}
+}
+
+// storeCover runs the store-cover step: image returns the cover (fetching it if need be), which is uploaded under
+// books/covers/. Returns the new key.
+func (p *uploadPipeline) storeCover(image func() ([]byte, string, error)) (string, error) {
+	var key string
+	err := p.run(models.UploadStepCover, func(ctx context.Context) error {
+		img, contentType, err := image()
+		if err != nil {
+			return err
+		}
+		if len(img) == 0 {
+			return &permanentError{errors.New("empty cover image")}
+		}
+		ext := ".jpg"
+		if strings.Contains(contentType, "png") {
+			ext = ".png"
+		}
+		k, err := p.storage.Upload(ctx, "books/covers/", "cover"+ext, bytes.NewReader(img), contentType)
+		if err != nil {
+			return err
+		}
+		key = k
+		p.track(k)
+		return nil
+	})
+	return key, err
+}
+
+// fetchMetadata runs the fetch-metadata step: by doi when there is one and documents is set, else by isbn.
+func (p *uploadPipeline) fetchMetadata(provider service.MetadataProvider, documents service.DOIProvider, isbn, doi string) (*service.BookMetadata, error) {
+	var meta *service.BookMetadata
+	err := p.run(models.UploadStepMetadata, func(ctx context.Context) error {
+		var m *service.BookMetadata
+		var err error
+		switch {
+		case doi != "" && documents != nil:
+			m, err = documents.FetchByDOI(ctx, doi)
+		case isbn == "":
+			return &permanentError{errors.New("no ISBN")}
+		default:
+			m, err = provider.FetchByISBN(ctx, isbn)
+		}
+		if errors.Is(err, service.ErrNoMetadata) {
+			return &permanentError{err}
+		}
+		meta = m
+		return err
+	})
+	return meta, err
+}
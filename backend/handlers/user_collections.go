@@ -0,0 +1,371 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/kevinaaaquil/books/backend/middleware"
+	"github.com/kevinaaaquil/books/backend/models"
+	"github.com/kevinaaaquil/books/backend/service"
+	"github.com/kevinaaaquil/books/backend/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// collectionNameMaxLen is the longest collection name, in characters.
+const collectionNameMaxLen = 100
+
+// CollectionsHandler manages the collections users shelve books in, like "To Read" or "Programming". Each user
+// sees only their own; as "collection:<id>" they can also be downloaded as bundles and synced (see
+// collectionBooks).
+type CollectionsHandler struct {
+	DB    store.Store
+	Clock service.Clock
+}
+
+// CollectionRequest creates a collection (with its first books) or renames one.
+type CollectionRequest struct {
+	Name    string   `json:"name"`
+	BookIDs []string `json:"bookIds,omitempty"` // on create only
+}
+
+type CollectionsResponse struct {
+	Collections []models.Collection `json:"collections"`
+}
+
+// CollectionBooksResponse is a collection with the books on it the user may see, in the order they were added.
+type CollectionBooksResponse struct {
+	Collection models.Collection `json:"collection"`
+	Books      []models.Book     `json:"books"`
+}
+
+// CollectionBookRequest adds a book to a collection.
+type CollectionBookRequest struct {
+	BookID string `json:"bookId"`
+}
+
+// List returns the current user's collections, oldest first. GET /api/collections
+func (h *CollectionsHandler) List(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+	collections, err := h.DB.CollectionsForUser(r.Context(), userID)
+	if err != nil {
+		http.Error(w, `{"error":"failed to list collections"}`, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(CollectionsResponse{Collections: collections})
+}
+
+// Create adds a collection, optionally with books on it. Names are unique per user, ignoring case (409).
+// POST /api/collections
+func (h *CollectionsHandler) Create(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+	var req CollectionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error":"invalid json"}`, http.StatusBadRequest)
+		return
+	}
+	name, ok := h.checkName(w, r, userID, primitive.NilObjectID, req.Name)
+	if !ok {
+		return
+	}
+	now := h.Clock.Now()
+	c := &models.Collection{UserID: userID, Name: name, BookIDs: []primitive.ObjectID{}, CreatedAt: now, UpdatedAt: now}
+	for _, hex := range req.BookIDs {
+		bookID, ok := h.visibleBookID(w, r, userID, hex)
+		if !ok {
+			return
+		}
+		if !containsID(c.BookIDs, bookID) {
+			c.BookIDs = append(c.BookIDs, bookID)
+		}
+	}
+	id, err := h.DB.InsertCollection(r.Context(), c)
+	if err != nil {
+		http.Error(w, `{"error":"failed to save collection"}`, http.StatusInternalServerError)
+		return
+	}
+	c.ID = id
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(c)
+}
+
+// Get returns one of the user's collections. GET /api/collections/:id
+func (h *CollectionsHandler) Get(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	c, ok := h.collection(w, r)
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(c)
+}
+
+// Rename renames one of the user's collections. PATCH /api/collections/:id with {"name": ...}
+func (h *CollectionsHandler) Rename(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPatch {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	c, ok := h.collection(w, r)
+	if !ok {
+		return
+	}
+	var req CollectionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error":"invalid json"}`, http.StatusBadRequest)
+		return
+	}
+	name, ok := h.checkName(w, r, c.UserID, c.ID, req.Name)
+	if !ok {
+		return
+	}
+	c.Name, c.UpdatedAt = name, h.Clock.Now()
+	found, err := h.DB.RenameCollection(r.Context(), c.UserID, c.ID, c.Name, c.UpdatedAt)
+	if err != nil {
+		http.Error(w, `{"error":"failed to save collection"}`, http.StatusInternalServerError)
+		return
+	}
+	if !found {
+		http.Error(w, `{"error":"collection not found"}`, http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(c)
+}
+
+// Delete removes one of the user's collections; its books stay in the library. DELETE /api/collections/:id
+func (h *CollectionsHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+	id, err := primitive.ObjectIDFromHex(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, `{"error":"invalid collection id"}`, http.StatusBadRequest)
+		return
+	}
+	found, err := h.DB.DeleteCollection(r.Context(), userID, id)
+	if err != nil {
+		http.Error(w, `{"error":"failed to delete collection"}`, http.StatusInternalServerError)
+		return
+	}
+	if !found {
+		http.Error(w, `{"error":"collection not found"}`, http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Books returns one of the user's collections with the books on it they may see, in the order they were added.
+// GET /api/collections/:id/books
+func (h *CollectionsHandler) Books(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+	c, books, err := userCollectionBooks(r.Context(), h.DB, userID, chi.URLParam(r, "id"))
+	if errors.Is(err, errNoCollection) {
+		http.Error(w, `{"error":"collection not found"}`, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, `{"error":"failed to list books"}`, http.StatusInternalServerError)
+		return
+	}
+	for i := range books {
+		setCoverURLIfExtracted(&books[i])
+		setContentRating(&books[i])
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(CollectionBooksResponse{Collection: *c, Books: books})
+}
+
+// AddBook puts a book the user may see at the end of one of their collections, unless it is on it already, and
+// returns the collection. POST /api/collections/:id/books with {"bookId": ...}
+func (h *CollectionsHandler) AddBook(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	c, ok := h.collection(w, r)
+	if !ok {
+		return
+	}
+	var req CollectionBookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error":"invalid json"}`, http.StatusBadRequest)
+		return
+	}
+	bookID, ok := h.visibleBookID(w, r, c.UserID, req.BookID)
+	if !ok {
+		return
+	}
+	c.UpdatedAt = h.Clock.Now()
+	found, err := h.DB.AddBookToCollection(r.Context(), c.UserID, c.ID, bookID, c.UpdatedAt)
+	if err != nil {
+		http.Error(w, `{"error":"failed to save collection"}`, http.StatusInternalServerError)
+		return
+	}
+	if !found {
+		http.Error(w, `{"error":"collection not found"}`, http.StatusNotFound)
+		return
+	}
+	if !containsID(c.BookIDs, bookID) {
+		c.BookIDs = append(c.BookIDs, bookID)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(c)
+}
+
+// RemoveBook takes a book off one of the user's collections. DELETE /api/collections/:id/books/:bookId
+func (h *CollectionsHandler) RemoveBook(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	c, ok := h.collection(w, r)
+	if !ok {
+		return
+	}
+	bookID, err := primitive.ObjectIDFromHex(chi.URLParam(r, "bookId"))
+	if err != nil {
+		http.Error(w, `{"error":"invalid book id"}`, http.StatusBadRequest)
+		return
+	}
+	if !containsID(c.BookIDs, bookID) {
+		http.Error(w, `{"error":"book not in collection"}`, http.StatusNotFound)
+		return
+	}
+	found, err := h.DB.RemoveBookFromCollection(r.Context(), c.UserID, c.ID, bookID, h.Clock.Now())
+	if err != nil {
+		http.Error(w, `{"error":"failed to save collection"}`, http.StatusInternalServerError)
+		return
+	}
+	if !found {
+		http.Error(w, `{"error":"collection not found"}`, http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// collection loads the current user's collection named in the URL, writing the error response when there is none.
+func (h *CollectionsHandler) collection(w http.ResponseWriter, r *http.Request) (*models.Collection, bool) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return nil, false
+	}
+	id, err := primitive.ObjectIDFromHex(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, `{"error":"invalid collection id"}`, http.StatusBadRequest)
+		return nil, false
+	}
+	c, err := h.DB.Collection(r.Context(), userID, id)
+	if err != nil {
+		http.Error(w, `{"error":"failed to load collection"}`, http.StatusInternalServerError)
+		return nil, false
+	}
+	if c == nil {
+		http.Error(w, `{"error":"collection not found"}`, http.StatusNotFound)
+		return nil, false
+	}
+	return c, true
+}
+
+// checkName trims and validates a collection name, which no other of the user's collections than id may have,
+// writing the error response when it fails.
+func (h *CollectionsHandler) checkName(w http.ResponseWriter, r *http.Request, userID, id primitive.ObjectID, name string) (string, bool) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		http.Error(w, `{"error":"name is required"}`, http.StatusBadRequest)
+		return "", false
+	}
+	if utf8.RuneCountInString(name) > collectionNameMaxLen {
+		http.Error(w, `{"error":"name is too long"}`, http.StatusBadRequest)
+		return "", false
+	}
+	existing, err := h.DB.CollectionsForUser(r.Context(), userID)
+	if err != nil {
+		http.Error(w, `{"error":"failed to list collections"}`, http.StatusInternalServerError)
+		return "", false
+	}
+	for _, c := range existing {
+		if c.ID != id && strings.EqualFold(c.Name, name) {
+			http.Error(w, `{"error":"you already have a collection with that name"}`, http.StatusConflict)
+			return "", false
+		}
+	}
+	return name, true
+}
+
+// visibleBookID parses a book ID and checks the book exists and the user may see it (within their maximum
+// content rating), writing the error response when not.
+func (h *CollectionsHandler) visibleBookID(w http.ResponseWriter, r *http.Request, userID primitive.ObjectID, hex string) (primitive.ObjectID, bool) {
+	id, err := primitive.ObjectIDFromHex(hex)
+	if err != nil {
+		http.Error(w, `{"error":"invalid book id"}`, http.StatusBadRequest)
+		return primitive.NilObjectID, false
+	}
+	book, err := h.DB.BookByID(r.Context(), id)
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, `{"error":"book not found"}`, http.StatusNotFound)
+		return primitive.NilObjectID, false
+	}
+	if err != nil {
+		http.Error(w, `{"error":"failed to load book"}`, http.StatusInternalServerError)
+		return primitive.NilObjectID, false
+	}
+	user, err := h.DB.UserByID(r.Context(), userID)
+	if err != nil {
+		http.Error(w, `{"error":"failed to load user"}`, http.StatusInternalServerError)
+		return primitive.NilObjectID, false
+	}
+	if user != nil && !contentRatingAllowed(user.MaxContentRating, book) {
+		http.Error(w, `{"error":"book not found"}`, http.StatusNotFound)
+		return primitive.NilObjectID, false
+	}
+	return id, true
+}
+
+func containsID(ids []primitive.ObjectID, id primitive.ObjectID) bool {
+	for _, i := range ids {
+		if i == id {
+			return true
+		}
+	}
+	return false
+}
@@ -1,6 +1,8 @@
 package handlers
 
 import (
+	"crypto/rand"
+	"encoding/base64"
 	"encoding/json"
 	"net/http"
 	"strings"
@@ -9,25 +11,35 @@ import (
 	"github.com/go-chi/chi/v5"
 	"github.com/kevinaaaquil/books/backend/middleware"
 	"github.com/kevinaaaquil/books/backend/models"
+	"github.com/kevinaaaquil/books/backend/service"
 	"github.com/kevinaaaquil/books/backend/store"
+	"github.com/kevinaaaquil/books/backend/systemmail"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"golang.org/x/crypto/bcrypt"
 )
 
 type UsersHandler struct {
-	DB *store.DB
+	DB         store.Store
+	Clock      service.Clock
+	JWTSecret  string              // signs invite links
+	SystemMail *systemmail.Service // nil disables invites
 }
 
 type CreateUserRequest struct {
 	Email    string `json:"email"`
-	Password string `json:"password"`
+	Password string `json:"password"` // optional with invite: the user chooses one from the emailed link
 	Role     string `json:"role"`
+	Invite   bool   `json:"invite"`
+	// MaxContentRating limits the books the user sees (see models.ContentRatings); "" = no limit.
+	MaxContentRating string `json:"maxContentRating"`
 }
 
 type CreateUserResponse struct {
-	ID    string `json:"id"`
-	Email string `json:"email"`
-	Role  string `json:"role"`
+	ID          string `json:"id"`
+	Email       string `json:"email"`
+	Role        string `json:"role"`
+	Invited     bool   `json:"invited,omitempty"`
+	InviteError string `json:"inviteError,omitempty"` // the user was created but the invite email failed
 }
 
 type UserResponse struct {
@@ -35,6 +47,11 @@ type UserResponse struct {
 	Email              string `json:"email"`
 	Role               string `json:"role"`
 	UseExtractedCover  bool   `json:"useExtractedCover"`
+	MaxContentRating   string `json:"maxContentRating,omitempty"`
+	WeeklyDigest       bool   `json:"weeklyDigest"`
+	TermsVersion       int    `json:"termsVersion"` // the version of the terms of use last accepted; 0 = none
+	TermsAcceptedAt    string `json:"termsAcceptedAt,omitempty"`
+	OnboardedAt        string `json:"onboardedAt,omitempty"` // unset until the first-login walkthrough is finished
 	CreatedAt          string `json:"createdAt"`
 }
 
@@ -42,6 +59,13 @@ type UpdateUserRequest struct {
 	Email    *string `json:"email"`
 	Password *string `json:"password"`
 	Role     *string `json:"role"`
+	// MaxContentRating limits the books the user sees; "" removes the limit.
+	MaxContentRating *string `json:"maxContentRating"`
+}
+
+// maxContentRatingValid reports whether rating may be set as a user's maximum ("" = no limit).
+func maxContentRatingValid(rating string) bool {
+	return rating == "" || models.ValidContentRating(rating)
 }
 
 func roleValid(role string) bool {
@@ -54,6 +78,7 @@ func roleValid(role string) bool {
 }
 
 // CreateUser creates a new user. Only admin can call. Role must be viewer, editor, or guest (not admin).
+// With invite, the user is emailed a link to choose their password; password may then be omitted.
 func (h *UsersHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
@@ -65,6 +90,19 @@ func (h *UsersHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	req.Email = strings.TrimSpace(strings.ToLower(req.Email))
+	if req.Invite && h.SystemMail == nil {
+		http.Error(w, `{"error":"invites need system email to be configured"}`, http.StatusServiceUnavailable)
+		return
+	}
+	if req.Invite && req.Password == "" {
+		// Nobody knows this password; the invite link replaces it.
+		random := make([]byte, 24)
+		if _, err := rand.Read(random); err != nil {
+			http.Error(w, `{"error":"failed to create user"}`, http.StatusInternalServerError)
+			return
+		}
+		req.Password = base64.RawURLEncoding.EncodeToString(random)
+	}
 	if req.Email == "" || req.Password == "" {
 		http.Error(w, `{"error":"email and password required"}`, http.StatusBadRequest)
 		return
@@ -81,6 +119,10 @@ func (h *UsersHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, `{"error":"invalid role; use viewer, editor, or guest"}`, http.StatusBadRequest)
 		return
 	}
+	if !maxContentRatingValid(req.MaxContentRating) {
+		http.Error(w, `{"error":"maxContentRating must be one of: `+strings.Join(models.ContentRatings, ", ")+`"}`, http.StatusBadRequest)
+		return
+	}
 	existing, err := h.DB.UserByEmail(r.Context(), req.Email)
 	if err != nil {
 		http.Error(w, `{"error":"failed to create user"}`, http.StatusInternalServerError)
@@ -96,37 +138,72 @@ func (h *UsersHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	user := &models.User{
-		Email:     req.Email,
-		Password:  string(hash),
-		Role:      role,
-		CreatedAt: time.Now(),
+		Email:            req.Email,
+		Password:         string(hash),
+		Role:             role,
+		MaxContentRating: req.MaxContentRating,
+		CreatedAt:        h.Clock.Now(),
 	}
 	id, err := h.DB.CreateUser(r.Context(), user)
 	if err != nil {
 		http.Error(w, `{"error":"failed to create user"}`, http.StatusInternalServerError)
 		return
 	}
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(CreateUserResponse{
+	resp := CreateUserResponse{
 		ID:    id.Hex(),
 		Email: user.Email,
 		Role:  user.Role,
+	}
+	if req.Invite {
+		user.ID = id
+		if err := h.sendInvite(r, user); err != nil {
+			resp.InviteError = err.Error()
+		} else {
+			resp.Invited = true
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// sendInvite emails user a link to choose their password.
+func (h *UsersHandler) sendInvite(r *http.Request, user *models.User) error {
+	token, err := newPasswordToken(h.JWTSecret, h.Clock.Now(), user, inviteTTL)
+	if err != nil {
+		return err
+	}
+	return h.SystemMail.Send(r.Context(), user.Email, systemmail.Invite, systemmail.Data{
+		Link:      passwordLink(h.SystemMail, token, true),
+		Expires:   "7 days",
+		Role:      user.Role,
+		InvitedBy: middleware.EmailFromContext(r.Context()),
 	})
 }
 
 type PatchMePreferencesRequest struct {
 	UseExtractedCover *bool `json:"useExtractedCover"`
+	WeeklyDigest      *bool `json:"weeklyDigest"` // email a weekly digest of library activity
 }
 
 func userToResponse(u *models.User) UserResponse {
-	return UserResponse{
+	res := UserResponse{
 		ID:                u.ID.Hex(),
 		Email:             u.Email,
 		Role:              u.Role,
 		UseExtractedCover: u.UseExtractedCover,
+		MaxContentRating:  u.MaxContentRating,
+		WeeklyDigest:      u.WeeklyDigest,
+		TermsVersion:      u.TermsVersion,
 		CreatedAt:         u.CreatedAt.Format(time.RFC3339),
 	}
+	if u.TermsAcceptedAt != nil {
+		res.TermsAcceptedAt = u.TermsAcceptedAt.Format(time.RFC3339)
+	}
+	if u.OnboardedAt != nil {
+		res.OnboardedAt = u.OnboardedAt.Format(time.RFC3339)
+	}
+	return res
 }
 
 // ListUsers returns all users (admin only). Password is omitted via json:"-".
@@ -148,7 +225,7 @@ func (h *UsersHandler) ListUsers(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(out)
 }
 
-// UpdateUser updates a user by ID (admin only). Body: { "email"?, "password"?, "role"? }
+// UpdateUser updates a user by ID (admin only). Body: { "email"?, "password"?, "role"?, "maxContentRating"? }
 func (h *UsersHandler) UpdateUser(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPatch && r.Method != http.MethodPut {
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
@@ -204,10 +281,20 @@ func (h *UsersHandler) UpdateUser(w http.ResponseWriter, r *http.Request) {
 		// Only allow setting admin via update if needed; for simplicity we allow it for admin caller
 		newRole = &r
 	}
+	if req.MaxContentRating != nil && !maxContentRatingValid(*req.MaxContentRating) {
+		http.Error(w, `{"error":"maxContentRating must be one of: `+strings.Join(models.ContentRatings, ", ")+`"}`, http.StatusBadRequest)
+		return
+	}
 	if err := h.DB.UpdateUser(r.Context(), id, newEmail, newHash, newRole); err != nil {
 		http.Error(w, `{"error":"failed to update user"}`, http.StatusInternalServerError)
 		return
 	}
+	if req.MaxContentRating != nil {
+		if err := h.DB.UpdateUserMaxContentRating(r.Context(), id, *req.MaxContentRating); err != nil {
+			http.Error(w, `{"error":"failed to update user"}`, http.StatusInternalServerError)
+			return
+		}
+	}
 	user, _ = h.DB.UserByID(r.Context(), id)
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(userToResponse(user))
@@ -257,7 +344,7 @@ func (h *UsersHandler) DeleteUser(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusNoContent)
 }
 
-// GetMe returns the current user's profile (id, email, role, useExtractedCover). Requires auth.
+// GetMe returns the current user's profile (id, email, role, useExtractedCover, weeklyDigest). Requires auth.
 func (h *UsersHandler) GetMe(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
@@ -277,7 +364,7 @@ func (h *UsersHandler) GetMe(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(userToResponse(user))
 }
 
-// PatchMePreferences updates the current user's preferences (e.g. useExtractedCover). Body: { "useExtractedCover": true|false }. Persisted in MongoDB.
+// PatchMePreferences updates the current user's preferences. Body: { "useExtractedCover": true|false, "weeklyDigest": true|false }, at least one. Persisted in MongoDB.
 func (h *UsersHandler) PatchMePreferences(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPatch && r.Method != http.MethodPut {
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
@@ -293,15 +380,52 @@ func (h *UsersHandler) PatchMePreferences(w http.ResponseWriter, r *http.Request
 		http.Error(w, `{"error":"invalid body"}`, http.StatusBadRequest)
 		return
 	}
-	if req.UseExtractedCover == nil {
-		http.Error(w, `{"error":"useExtractedCover required"}`, http.StatusBadRequest)
+	if req.UseExtractedCover == nil && req.WeeklyDigest == nil {
+		http.Error(w, `{"error":"useExtractedCover or weeklyDigest required"}`, http.StatusBadRequest)
 		return
 	}
-	if err := h.DB.UpdateUserUseExtractedCover(r.Context(), userID, *req.UseExtractedCover); err != nil {
-		http.Error(w, `{"error":"failed to update preference"}`, http.StatusInternalServerError)
-		return
+	if req.UseExtractedCover != nil {
+		if err := h.DB.UpdateUserUseExtractedCover(r.Context(), userID, *req.UseExtractedCover); err != nil {
+			http.Error(w, `{"error":"failed to update preference"}`, http.StatusInternalServerError)
+			return
+		}
+	}
+	if req.WeeklyDigest != nil {
+		if err := h.DB.UpdateUserWeeklyDigest(r.Context(), userID, *req.WeeklyDigest); err != nil {
+			http.Error(w, `{"error":"failed to update preference"}`, http.StatusInternalServerError)
+			return
+		}
 	}
 	user, _ := h.DB.UserByID(r.Context(), userID)
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(userToResponse(user))
 }
+
+// CompleteOnboarding records that the current user finished the first-login walkthrough and returns their profile;
+// the first time counts. POST /api/me/onboarding (auth).
+func (h *UsersHandler) CompleteOnboarding(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+	user, err := h.DB.UserByID(r.Context(), userID)
+	if err != nil || user == nil {
+		http.Error(w, `{"error":"user not found"}`, http.StatusNotFound)
+		return
+	}
+	if user.OnboardedAt == nil {
+		now := h.Clock.Now()
+		if err := h.DB.SetUserOnboardedAt(r.Context(), userID, now); err != nil {
+			http.Error(w, `{"error":"failed to save onboarding"}`, http.StatusInternalServerError)
+			return
+		}
+		user.OnboardedAt = &now
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(userToResponse(user))
+}
@@ -0,0 +1,194 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/kevinaaaquil/books/backend/middleware"
+	"github.com/kevinaaaquil/books/backend/models"
+	"github.com/kevinaaaquil/books/backend/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// WishlistHandler serves users' wishlists of books the library doesn't have. Items are added with POST /api/clip.
+type WishlistHandler struct {
+	DB         store.Store
+	PriceWatch bool // price providers are configured, so items' prices can be watched
+}
+
+// priceHistoryLimit caps the price history returned for an item.
+const priceHistoryLimit = 200
+
+// WatchPriceRequest sets an item's price watch.
+type WatchPriceRequest struct {
+	Threshold float64 `json:"threshold"`
+	Currency  string  `json:"currency"` // ISO 4217, e.g. "USD"
+}
+
+// List returns the current user's wishlist, newest first. GET /api/wishlist
+func (h *WishlistHandler) List(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+	items, err := h.DB.WishlistForUser(r.Context(), userID)
+	if err != nil {
+		http.Error(w, `{"error":"failed to load wishlist"}`, http.StatusInternalServerError)
+		return
+	}
+	if items == nil {
+		items = []models.WishlistItem{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(items)
+}
+
+// Delete removes an item from the current user's wishlist. DELETE /api/wishlist/:id
+func (h *WishlistHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+	id, err := primitive.ObjectIDFromHex(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, `{"error":"invalid wishlist item id"}`, http.StatusBadRequest)
+		return
+	}
+	found, err := h.DB.DeleteWishlistItem(r.Context(), userID, id)
+	if err != nil {
+		http.Error(w, `{"error":"failed to delete wishlist item"}`, http.StatusInternalServerError)
+		return
+	}
+	if !found {
+		http.Error(w, `{"error":"wishlist item not found"}`, http.StatusNotFound)
+		return
+	}
+	if err := h.DB.DeletePriceHistory(r.Context(), id); err != nil {
+		log.Printf("wishlist %s: delete price history: %v", id.Hex(), err)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// item loads the current user's item named in the URL, writing the error response when there is none.
+func (h *WishlistHandler) item(w http.ResponseWriter, r *http.Request) (*models.WishlistItem, bool) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return nil, false
+	}
+	id, err := primitive.ObjectIDFromHex(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, `{"error":"invalid wishlist item id"}`, http.StatusBadRequest)
+		return nil, false
+	}
+	items, err := h.DB.WishlistForUser(r.Context(), userID)
+	if err != nil {
+		http.Error(w, `{"error":"failed to load wishlist"}`, http.StatusInternalServerError)
+		return nil, false
+	}
+	for i := range items {
+		if items[i].ID == id {
+			return &items[i], true
+		}
+	}
+	http.Error(w, `{"error":"wishlist item not found"}`, http.StatusNotFound)
+	return nil, false
+}
+
+// WatchPrice sets the price below which the user is notified about an item, checked on PRICE_WATCH_SCHEDULE.
+// Returns the item. 404 when no price providers are configured. PUT /api/wishlist/:id/price-watch
+func (h *WishlistHandler) WatchPrice(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !h.PriceWatch {
+		http.Error(w, `{"error":"price watch not configured"}`, http.StatusNotFound)
+		return
+	}
+	item, ok := h.item(w, r)
+	if !ok {
+		return
+	}
+	var req WatchPriceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error":"invalid json"}`, http.StatusBadRequest)
+		return
+	}
+	currency := strings.ToUpper(strings.TrimSpace(req.Currency))
+	if len(currency) != 3 || strings.Trim(currency, "ABCDEFGHIJKLMNOPQRSTUVWXYZ") != "" {
+		http.Error(w, `{"error":"currency must be a three-letter code such as USD"}`, http.StatusBadRequest)
+		return
+	}
+	if req.Threshold <= 0 {
+		http.Error(w, `{"error":"threshold must be positive"}`, http.StatusBadRequest)
+		return
+	}
+	watch := &models.PriceWatch{Threshold: req.Threshold, Currency: currency}
+	if old := item.PriceWatch; old != nil && old.Currency == currency {
+		// Keep the last check; the user is told again about a price at or below the new threshold.
+		watch.Price, watch.Provider, watch.URL, watch.CheckedAt = old.Price, old.Provider, old.URL, old.CheckedAt
+	}
+	item.PriceWatch = watch
+	if _, err := h.DB.UpdateWishlistItem(r.Context(), item); err != nil {
+		http.Error(w, `{"error":"failed to update wishlist item"}`, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(item)
+}
+
+// UnwatchPrice stops watching an item's price; its price history is kept. DELETE /api/wishlist/:id/price-watch
+func (h *WishlistHandler) UnwatchPrice(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	item, ok := h.item(w, r)
+	if !ok {
+		return
+	}
+	if item.PriceWatch != nil {
+		item.PriceWatch = nil
+		if _, err := h.DB.UpdateWishlistItem(r.Context(), item); err != nil {
+			http.Error(w, `{"error":"failed to update wishlist item"}`, http.StatusInternalServerError)
+			return
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Prices returns an item's price history, newest first. GET /api/wishlist/:id/prices
+func (h *WishlistHandler) Prices(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	item, ok := h.item(w, r)
+	if !ok {
+		return
+	}
+	points, err := h.DB.PriceHistory(r.Context(), item.ID, priceHistoryLimit)
+	if err != nil {
+		http.Error(w, `{"error":"failed to load price history"}`, http.StatusInternalServerError)
+		return
+	}
+	if points == nil {
+		points = []models.PricePoint{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(points)
+}
@@ -0,0 +1,84 @@
+package jobs
+
+import (
+	"context"
+	"io"
+
+	"github.com/kevinaaaquil/books/backend/models"
+	"github.com/kevinaaaquil/books/backend/service"
+	"github.com/kevinaaaquil/books/backend/store"
+	"github.com/kevinaaaquil/books/backend/utils"
+)
+
+// TypeBackfillFileInfo computes size, SHA-256 and word/page counts for stored book files.
+const TypeBackfillFileInfo = "backfill-file-info"
+
+// BackfillFileInfo returns a job that downloads each book file and records its size, hash and counts.
+// By default only books without a recorded size or hash are processed; with all set every book is
+// recomputed, which also re-validates EPUBs (parseError is set or cleared). An interrupted run resumes after the
+// last book it processed.
+func BackfillFileInfo(db store.Store, storage service.ObjectStore, all bool) Func {
+	return func(ctx context.Context, p *Progress) error {
+		count, forEach := db.BooksMissingFileInfoCount, db.ForEachBookMissingFileInfo
+		if all {
+			count, forEach = db.BooksCount, db.ForEachBook
+		}
+		total, err := count(ctx)
+		if err != nil {
+			return err
+		}
+		p.SetTotal(int(total))
+		// Without all, books already done have left the set by themselves.
+		resumed := ""
+		if all {
+			resumed = p.Resumed()
+		}
+		return forEach(ctx, func(book *models.Book) error {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if bookDone(book, resumed) {
+				p.Step() // done before the interruption
+				return nil
+			}
+			if service.NeedsRestore(book.StorageClass) {
+				p.Checkpoint(bookCheckpoint(book))
+				p.Step("archived")
+				return nil
+			}
+			data, err := readObject(ctx, storage, book.S3Key)
+			if err != nil {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				p.Checkpoint(bookCheckpoint(book))
+				p.Step("errors")
+				return nil
+			}
+			info, parseErr := utils.ComputeFileInfo(data, book.Format)
+			parseError := ""
+			if parseErr != nil {
+				parseError = parseErr.Error()
+			}
+			if err := db.UpdateBookFileInfo(ctx, book.ID, info, parseError); err != nil {
+				return err
+			}
+			p.Checkpoint(bookCheckpoint(book))
+			if parseError != "" {
+				p.Step("updated", "unreadable")
+			} else {
+				p.Step("updated")
+			}
+			return nil
+		})
+	}
+}
+
+func readObject(ctx context.Context, storage service.ObjectStore, key string) ([]byte, error) {
+	body, _, err := storage.GetObject(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	return io.ReadAll(body)
+}
@@ -0,0 +1,149 @@
+package jobs
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/kevinaaaquil/books/backend/models"
+	"github.com/kevinaaaquil/books/backend/notify"
+	"github.com/kevinaaaquil/books/backend/service"
+	"github.com/kevinaaaquil/books/backend/store"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// TypeBackup dumps every MongoDB collection to S3.
+const TypeBackup = "backup"
+
+// backupPrefix is the S3 prefix holding backup archives.
+const backupPrefix = "backups/"
+
+// BackupOptions configures the backup job and its retention policy.
+type BackupOptions struct {
+	KeepDaily  int    // keep the newest backup of each of the last KeepDaily days that have one
+	KeepWeekly int    // keep the newest backup of each of the last KeepWeekly ISO weeks that have one
+	Trigger    string // "scheduled" or "manual"
+}
+
+// Backup returns a job that writes a gzipped tar of every collection (one canonical Extended JSON
+// document per line, importable with mongoimport) to backups/<timestamp>.tar.gz, records it, then
+// deletes backups outside the retention policy. Admins are notified when it fails.
+func Backup(db store.Store, storage service.ObjectStore, notifier *notify.Service, opts BackupOptions) Func {
+	return func(ctx context.Context, p *Progress) error {
+		err := runBackup(ctx, db, storage, opts, p)
+		if err != nil {
+			body := fmt.Sprintf("The %s backup failed: %v", opts.Trigger, err)
+			if nerr := notifier.NotifyAdmins(ctx, models.NotificationBackupFailed, "Library backup failed", body, "/api/admin/backups"); nerr != nil {
+				p.Logf("notify admins: %v", nerr)
+			}
+		}
+		return err
+	}
+}
+
+func runBackup(ctx context.Context, db store.Store, storage service.ObjectStore, opts BackupOptions, p *Progress) error {
+	names, err := db.CollectionNames(ctx)
+	if err != nil {
+		return err
+	}
+	p.SetTotal(len(names))
+
+	var archive bytes.Buffer
+	gz := gzip.NewWriter(&archive)
+	tw := tar.NewWriter(gz)
+	now := time.Now().UTC()
+	documents := 0
+	for _, name := range names {
+		var lines bytes.Buffer
+		err := db.ForEachDocument(ctx, name, func(doc bson.Raw) error {
+			line, err := bson.MarshalExtJSON(doc, true, false)
+			if err != nil {
+				return err
+			}
+			lines.Write(line)
+			lines.WriteByte('\n')
+			documents++
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		hdr := &tar.Header{Name: name + ".jsonl", Mode: 0o644, Size: int64(lines.Len()), ModTime: now}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if _, err := tw.Write(lines.Bytes()); err != nil {
+			return err
+		}
+		p.Step()
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+
+	key := backupPrefix + now.Format("20060102T150405Z") + ".tar.gz"
+	size := int64(archive.Len())
+	if err := storage.Put(ctx, key, &archive, "application/gzip"); err != nil {
+		return fmt.Errorf("upload %s: %w", key, err)
+	}
+	_, err = db.InsertBackup(ctx, &models.Backup{
+		Key:         key,
+		SizeBytes:   size,
+		Collections: len(names),
+		Documents:   documents,
+		Trigger:     opts.Trigger,
+		CreatedAt:   now,
+	})
+	if err != nil {
+		return err
+	}
+	return pruneBackups(ctx, db, storage, opts)
+}
+
+// pruneBackups deletes backups not kept by the daily/weekly retention policy. The newest backup is always kept.
+func pruneBackups(ctx context.Context, db store.Store, storage service.ObjectStore, opts BackupOptions) error {
+	backups, err := db.ListBackups(ctx)
+	if err != nil {
+		return err
+	}
+	for _, b := range expiredBackups(backups, opts.KeepDaily, opts.KeepWeekly) {
+		if err := storage.Delete(ctx, b.Key); err != nil {
+			return fmt.Errorf("delete %s: %w", b.Key, err)
+		}
+		if err := db.DeleteBackup(ctx, b.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// expiredBackups returns the backups (given newest first) that neither daily nor weekly retention keeps.
+func expiredBackups(backups []models.Backup, keepDaily, keepWeekly int) []models.Backup {
+	days := map[string]bool{}
+	weeks := map[string]bool{}
+	var expired []models.Backup
+	for i, b := range backups {
+		keep := i == 0
+		day := b.CreatedAt.UTC().Format("2006-01-02")
+		if !days[day] && len(days) < keepDaily {
+			days[day] = true
+			keep = true
+		}
+		year, week := b.CreatedAt.UTC().ISOWeek()
+		wk := fmt.Sprintf("%d-W%02d", year, week)
+		if !weeks[wk] && len(weeks) < keepWeekly {
+			weeks[wk] = true
+			keep = true
+		}
+		if !keep {
+			expired = append(expired, b)
+		}
+	}
+	return expired
+}
@@ -0,0 +1,17 @@
+package jobs
+
+import (
+	"fmt"
+
+	"github.com/kevinaaaquil/books/backend/models"
+)
+
+// bookCheckpoint identifies a book's place in ForEachBook order (creation time, then ID).
+func bookCheckpoint(b *models.Book) string {
+	return fmt.Sprintf("%013d:%s", b.CreatedAt.UnixMilli(), b.ID.Hex())
+}
+
+// bookDone reports whether a resumed run already processed b, i.e. b comes at or before checkpoint cp.
+func bookDone(b *models.Book, cp string) bool {
+	return cp != "" && bookCheckpoint(b) <= cp
+}
@@ -0,0 +1,88 @@
+package jobs
+
+import (
+	"context"
+	"strings"
+
+	"github.com/kevinaaaquil/books/backend/models"
+	"github.com/kevinaaaquil/books/backend/service"
+	"github.com/kevinaaaquil/books/backend/store"
+	"github.com/kevinaaaquil/books/backend/utils"
+)
+
+// TypeClassifyBooks suggests categories for books that have none.
+const TypeClassifyBooks = "classify-books"
+
+// ClassifyBooks returns a job that asks c to suggest categories for every book without categories, and saves
+// them for an editor to approve. Books with suggestions waiting are skipped unless all is set. An interrupted run
+// resumes after the last book it processed.
+func ClassifyBooks(db store.Store, storage service.ObjectStore, c service.Classifier, all bool) Func {
+	return func(ctx context.Context, p *Progress) error {
+		total, err := db.BooksCount(ctx)
+		if err != nil {
+			return err
+		}
+		p.SetTotal(int(total))
+		resumed := p.Resumed()
+		return db.ForEachBook(ctx, func(book *models.Book) error {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if bookDone(book, resumed) {
+				p.Step() // done before the interruption
+				return nil
+			}
+			defer p.Checkpoint(bookCheckpoint(book))
+			if len(book.Categories) > 0 || len(book.SuggestedCategories) > 0 && !all {
+				p.Step("skipped")
+				return nil
+			}
+			suggested, err := SuggestCategories(ctx, db, storage, c, book)
+			switch {
+			case ctx.Err() != nil:
+				return ctx.Err()
+			case err != nil:
+				p.Logf("%s: %v", book.ID.Hex(), err)
+				p.Step("errors")
+			case len(suggested) == 0:
+				p.Step("none")
+			default:
+				p.Step("suggested")
+			}
+			return nil
+		})
+	}
+}
+
+// SuggestCategories asks c for categories for book, from its metadata and the start of its EPUB text, and saves
+// them as the book's suggestions. It returns them; none when the classifier can't tell, which leaves any earlier
+// suggestions as they were.
+func SuggestCategories(ctx context.Context, db store.Store, storage service.ObjectStore, c service.Classifier, book *models.Book) ([]string, error) {
+	in := service.ClassifyInput{Title: book.Title, Authors: book.Authors, Description: book.Preface}
+	in.Text = startOfText(ctx, storage, book, service.ClassifyTextChars)
+	categories, err := c.Classify(ctx, in)
+	if err != nil || len(categories) == 0 {
+		return nil, err
+	}
+	return categories, db.SetBookSuggestedCategories(ctx, book.ID, categories)
+}
+
+// startOfText returns up to chars bytes of an EPUB book's text, from the start; "" for other formats, or when the
+// file can't be read. Archived files would need a restore to be read; those books go by their metadata.
+func startOfText(ctx context.Context, storage service.ObjectStore, book *models.Book, chars int) string {
+	if book.Format != "epub" || storage == nil || service.NeedsRestore(book.StorageClass) {
+		return ""
+	}
+	data, err := readObject(ctx, storage, book.S3Key)
+	if err != nil {
+		return ""
+	}
+	text, err := utils.EPUBText(data)
+	if err != nil {
+		return ""
+	}
+	if len(text) > chars {
+		text = strings.ToValidUTF8(text[:chars], "")
+	}
+	return text
+}
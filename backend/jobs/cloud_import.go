@@ -0,0 +1,177 @@
+package jobs
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"path"
+	"time"
+
+	"github.com/kevinaaaquil/books/backend/models"
+	"github.com/kevinaaaquil/books/backend/service"
+	"github.com/kevinaaaquil/books/backend/store"
+	"github.com/kevinaaaquil/books/backend/utils"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// TypeCloudImport imports new ebook files from the cloud drive folders users have linked (see models.ImportSource).
+const TypeCloudImport = "cloud-import"
+
+// IngestFunc adds a book file to the library as if uploadedBy had uploaded it, recording sourcePath as where it
+// came from, and returns the new book's ID.
+type IngestFunc func(ctx context.Context, name string, data []byte, uploadedBy, sourcePath string) (primitive.ObjectID, error)
+
+// CloudImportOptions configures the cloud import job.
+type CloudImportOptions struct {
+	Sources  map[string]service.DriveSource // by models.Target* kind; sources of other kinds fail
+	Ingest   IngestFunc
+	EncKey   []byte             // decrypts refresh tokens stored encrypted (32 bytes)
+	MaxBytes int64              // larger files fail without being downloaded; 0 = no limit
+	SourceID primitive.ObjectID // sync only this source; zero syncs every source with AutoSync
+}
+
+// CloudImport returns a job that lists each source's folder and imports the EPUB and PDF files it has not dealt
+// with at their current revision. A file whose SHA-256 matches a book already in the library is recorded as a
+// duplicate instead of being added again. Failed files are tried again at the next sync. What each source has
+// dealt with is saved as it goes, so an interrupted run loses nothing.
+func CloudImport(db store.Store, opts CloudImportOptions) Func {
+	return func(ctx context.Context, p *Progress) error {
+		sources, err := db.ImportSources(ctx)
+		if err != nil {
+			return err
+		}
+		total := 0
+		for i := range sources {
+			src := &sources[i]
+			if opts.SourceID.IsZero() && !src.AutoSync || !opts.SourceID.IsZero() && src.ID != opts.SourceID {
+				continue
+			}
+			if err := syncImportSource(ctx, db, opts, src, p, &total); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// syncImportSource imports src's new files. Problems with the drive are recorded in src.LastSync; only database
+// errors and cancellation are returned.
+func syncImportSource(ctx context.Context, db store.Store, opts CloudImportOptions, src *models.ImportSource, p *Progress, total *int) error {
+	sync := &models.ImportSync{At: time.Now()}
+	save := func() error { return db.SaveImportProgress(ctx, src.ID, src.Files, sync) }
+	fail := func(err error) error {
+		p.Logf("%s (%s): %v", src.Name, src.ID.Hex(), err)
+		sync.Error = err.Error()
+		return save()
+	}
+	drive, ok := opts.Sources[src.Kind]
+	if !ok {
+		return fail(fmt.Errorf("%s is not configured", src.Kind))
+	}
+	owner, err := db.UserByID(ctx, src.UserID)
+	if err != nil {
+		return err
+	}
+	if owner == nil {
+		return fail(fmt.Errorf("the user who linked it no longer exists"))
+	}
+	token := src.RefreshToken
+	if len(opts.EncKey) == 32 {
+		if token, err = utils.Decrypt(token, opts.EncKey); err != nil {
+			return fail(err)
+		}
+	}
+	files, err := drive.ListFiles(ctx, token, src.Folder)
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return fail(err)
+	}
+	done := make(map[string]int, len(src.Files)) // index in src.Files by file ID
+	for i, f := range src.Files {
+		done[f.FileID] = i
+	}
+	var todo []service.DriveFile
+	for _, f := range files {
+		if !models.IsBookFile(f.Path) {
+			continue
+		}
+		if i, ok := done[f.ID]; ok && src.Files[i].Revision == f.Revision {
+			continue
+		}
+		todo = append(todo, f)
+	}
+	*total += len(todo)
+	p.SetTotal(*total)
+	for _, f := range todo {
+		bookID, duplicate, err := importDriveFile(ctx, db, opts, drive, token, f, owner.Email, src.Kind+":"+f.Path)
+		if ctx.Err() != nil {
+			if err := db.SaveImportProgress(context.WithoutCancel(ctx), src.ID, src.Files, sync); err != nil {
+				log.Printf("cloud import: %s: %v", src.Name, err)
+			}
+			return ctx.Err()
+		}
+		if err != nil {
+			p.Logf("%s: %s: %v", src.Name, f.Path, err)
+			sync.Failed++
+			p.Step("failed")
+			continue
+		}
+		imported := models.ImportedFile{FileID: f.ID, Revision: f.Revision, BookID: bookID}
+		if i, ok := done[f.ID]; ok {
+			src.Files[i] = imported
+		} else {
+			done[f.ID] = len(src.Files)
+			src.Files = append(src.Files, imported)
+		}
+		if duplicate {
+			sync.Duplicates++
+			p.Step("duplicates")
+		} else {
+			sync.Imported++
+			p.Step("imported")
+		}
+		if err := save(); err != nil {
+			return err
+		}
+	}
+	return save()
+}
+
+// importDriveFile downloads f and adds it to the library, unless a book with the same content is already there;
+// either way it returns the book's ID.
+func importDriveFile(ctx context.Context, db store.Store, opts CloudImportOptions, drive service.DriveSource, token string, f service.DriveFile, uploadedBy, sourcePath string) (bookID primitive.ObjectID, duplicate bool, err error) {
+	if opts.MaxBytes > 0 && f.Size > opts.MaxBytes {
+		return primitive.NilObjectID, false, fmt.Errorf("file is %d bytes, over the upload limit of %d", f.Size, opts.MaxBytes)
+	}
+	body, err := drive.Download(ctx, token, f.ID)
+	if err != nil {
+		return primitive.NilObjectID, false, err
+	}
+	defer body.Close()
+	var r io.Reader = body
+	if opts.MaxBytes > 0 {
+		r = io.LimitReader(body, opts.MaxBytes+1)
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return primitive.NilObjectID, false, err
+	}
+	if opts.MaxBytes > 0 && int64(len(data)) > opts.MaxBytes {
+		return primitive.NilObjectID, false, fmt.Errorf("file is over the upload limit of %d bytes", opts.MaxBytes)
+	}
+	sum := sha256.Sum256(data)
+	existing, err := db.BookBySHA256(ctx, hex.EncodeToString(sum[:]))
+	if err != nil {
+		return primitive.NilObjectID, false, err
+	}
+	if existing != nil {
+		return existing.ID, true, nil
+	}
+	bookID, err = opts.Ingest(ctx, path.Base(f.Path), data, uploadedBy, sourcePath)
+	return bookID, false, err
+}
@@ -0,0 +1,81 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/kevinaaaquil/books/backend/models"
+	"github.com/kevinaaaquil/books/backend/service"
+	"github.com/kevinaaaquil/books/backend/store"
+)
+
+// TypeColdStorage moves the files of books nobody has read for a while to a cheaper storage class.
+const TypeColdStorage = "cold-storage"
+
+// ColdStorageTag is the object tag set in lifecycle mode; the bucket's lifecycle rule should transition objects
+// tagged books-tier=cold.
+const ColdStorageTag = "books-tier"
+
+// ColdStorageOptions configure the cold storage job.
+type ColdStorageOptions struct {
+	AfterMonths int    // books added and last read this many months ago or more are archived
+	Class       string // storage class they move to
+	Lifecycle   bool   // tag the objects for a lifecycle rule instead of copying them into Class
+}
+
+// ColdStorage returns a job that archives every book in the standard tier that was added, and last read by anyone
+// (see models.ReadingProgress), AfterMonths ago or more: its file is copied into Class, or tagged for the bucket's
+// lifecycle rule to move, and the class is recorded on the book. Downloads of archived books that need a restore
+// start one (see service.NeedsRestore).
+func ColdStorage(db store.Store, tier service.Tierer, opts ColdStorageOptions) Func {
+	return func(ctx context.Context, p *Progress) error {
+		progress, err := db.AllReadingProgress(ctx)
+		if err != nil {
+			return err
+		}
+		lastRead := map[string]time.Time{}
+		for _, rp := range progress {
+			if id := rp.BookID.Hex(); rp.UpdatedAt.After(lastRead[id]) {
+				lastRead[id] = rp.UpdatedAt
+			}
+		}
+		total, err := db.BooksCount(ctx)
+		if err != nil {
+			return err
+		}
+		p.SetTotal(int(total))
+		now := time.Now()
+		cutoff := now.AddDate(0, -opts.AfterMonths, 0)
+		return db.ForEachBook(ctx, func(book *models.Book) error {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if book.StorageClass != "" || book.S3Key == "" || !book.CreatedAt.Before(cutoff) || !lastRead[book.ID.Hex()].Before(cutoff) {
+				p.Step()
+				return nil
+			}
+			var err error
+			if opts.Lifecycle {
+				err = tier.TagObject(ctx, book.S3Key, ColdStorageTag, "cold")
+			} else {
+				err = tier.SetStorageClass(ctx, book.S3Key, opts.Class)
+			}
+			if err != nil {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				if !errors.Is(err, service.ErrObjectNotFound) {
+					p.Logf("%s: %v", book.ID.Hex(), err)
+				}
+				p.Step("errors")
+				return nil
+			}
+			if err := db.SetBookStorageClass(ctx, book.ID, opts.Class, now); err != nil {
+				return err
+			}
+			p.Step("archived")
+			return nil
+		})
+	}
+}
@@ -0,0 +1,113 @@
+package jobs
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/kevinaaaquil/books/backend/models"
+	"github.com/kevinaaaquil/books/backend/store"
+	"github.com/kevinaaaquil/books/backend/systemmail"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// TypeDigest emails the weekly digest to the users who opted in.
+const TypeDigest = "digest"
+
+// DigestWindow is the longest a digest looks back: the time since the user's last digest, but at most this.
+const DigestWindow = 7 * 24 * time.Hour
+
+// digestBooks is how many new books, and how many of the user's books in progress, a digest lists.
+const digestBooks = 10
+
+// Digest returns a job that emails every user with models.User.WeeklyDigest the books added to the library (within
+// their content rating), the books they read and their wishlist, since their last digest. Users with no new books
+// and no reading to report are skipped; a failed email is retried on the next run.
+func Digest(db store.Store, mail *systemmail.Service) Func {
+	return func(ctx context.Context, p *Progress) error {
+		users, err := db.ListUsers(ctx)
+		if err != nil {
+			return err
+		}
+		books, err := db.AllBooks(ctx)
+		if err != nil {
+			return err
+		}
+		progress, err := db.AllReadingProgress(ctx)
+		if err != nil {
+			return err
+		}
+		sort.SliceStable(books, func(i, j int) bool { return books[i].CreatedAt.After(books[j].CreatedAt) })
+		byID := make(map[primitive.ObjectID]*models.Book, len(books))
+		for i := range books {
+			byID[books[i].ID] = &books[i]
+		}
+		sort.SliceStable(progress, func(i, j int) bool { return progress[i].UpdatedAt.After(progress[j].UpdatedAt) })
+
+		now := time.Now()
+		p.SetTotal(len(users))
+		for _, u := range users {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if !u.WeeklyDigest {
+				p.Step()
+				continue
+			}
+			since := now.Add(-DigestWindow)
+			if u.DigestSentAt != nil && u.DigestSentAt.After(since) {
+				since = *u.DigestSentAt
+			}
+			digest := &systemmail.Digest{Since: since.Format("Jan 2")}
+			for i := range books {
+				b := &books[i]
+				if !b.CreatedAt.After(since) {
+					break
+				}
+				rating, _ := b.EffectiveContentRating()
+				if !models.ContentRatingAllowed(u.MaxContentRating, rating) {
+					continue
+				}
+				if len(digest.NewBooks) == digestBooks {
+					digest.MoreBooks++
+					continue
+				}
+				digest.NewBooks = append(digest.NewBooks, systemmail.DigestBook{Title: b.Title, Authors: strings.Join(b.Authors, ", ")})
+			}
+			for _, rp := range progress {
+				b := byID[rp.BookID]
+				if rp.UserID != u.ID || b == nil || !rp.UpdatedAt.After(since) {
+					continue
+				}
+				if rp.Percent >= models.FinishedPercent {
+					digest.Finished++
+				}
+				if len(digest.Reading) < digestBooks {
+					digest.Reading = append(digest.Reading, systemmail.DigestBook{Title: b.Title, Percent: int(rp.Percent)})
+				}
+			}
+			if len(digest.NewBooks) == 0 && len(digest.Reading) == 0 {
+				p.Step("quiet")
+				continue
+			}
+			wishlist, err := db.WishlistForUser(ctx, u.ID)
+			if err != nil {
+				return err
+			}
+			for _, item := range wishlist {
+				digest.Requests = append(digest.Requests, systemmail.DigestBook{Title: item.Title, Authors: strings.Join(item.Authors, ", ")})
+			}
+			if err := mail.Send(ctx, u.Email, systemmail.WeeklyDigest, systemmail.Data{Digest: digest}); err != nil {
+				p.Logf("%s: %v", u.Email, err)
+				p.Step("errors")
+				continue
+			}
+			if err := db.SetUserDigestSentAt(ctx, u.ID, now); err != nil {
+				return err
+			}
+			p.Step("sent")
+		}
+		return nil
+	}
+}
@@ -0,0 +1,108 @@
+package jobs
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"github.com/kevinaaaquil/books/backend/models"
+	"github.com/kevinaaaquil/books/backend/search"
+	"github.com/kevinaaaquil/books/backend/service"
+	"github.com/kevinaaaquil/books/backend/store"
+)
+
+// TypeEmbedBooks embeds books for semantic search.
+const TypeEmbedBooks = "embed-books"
+
+// EmbedBooks returns a job that embeds every book whose embedding is missing, from another model or out of date
+// with the book, and puts the embeddings in vectors; all embeds every book again. An interrupted run resumes after
+// the last book it processed.
+func EmbedBooks(db store.Store, storage service.ObjectStore, e service.Embedder, vectors *search.Vectors, all bool) Func {
+	return func(ctx context.Context, p *Progress) error {
+		total, err := db.BooksCount(ctx)
+		if err != nil {
+			return err
+		}
+		p.SetTotal(int(total))
+		resumed := p.Resumed()
+		return db.ForEachBook(ctx, func(book *models.Book) error {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if bookDone(book, resumed) {
+				p.Step() // done before the interruption
+				return nil
+			}
+			defer p.Checkpoint(bookCheckpoint(book))
+			embedded, err := EmbedBook(ctx, db, storage, e, vectors, book, all)
+			switch {
+			case ctx.Err() != nil:
+				return ctx.Err()
+			case err != nil:
+				p.Logf("%s: %v", book.ID.Hex(), err)
+				p.Step("errors")
+			case embedded:
+				p.Step("embedded")
+			default:
+				p.Step("unchanged")
+			}
+			return nil
+		})
+	}
+}
+
+// EmbedBook embeds book from its metadata and the start of its EPUB text, saves the embedding and puts it in
+// vectors (if non-nil). Unless force is set, a book whose stored embedding is from the same model and text is
+// left as it is. It reports whether the book was embedded.
+func EmbedBook(ctx context.Context, db store.Store, storage service.ObjectStore, e service.Embedder, vectors *search.Vectors, book *models.Book, force bool) (bool, error) {
+	text := embeddingText(ctx, storage, book)
+	sum := sha256.Sum256([]byte(text))
+	hash := hex.EncodeToString(sum[:])
+	if !force {
+		stored, err := db.BookEmbedding(ctx, book.ID)
+		if err != nil {
+			return false, err
+		}
+		if stored != nil && stored.Model == e.Model() && stored.TextHash == hash {
+			return false, nil
+		}
+	}
+	vecs, err := e.Embed(ctx, []string{text})
+	if err != nil {
+		return false, err
+	}
+	embedding := &models.BookEmbedding{BookID: book.ID, Model: e.Model(), TextHash: hash, Vector: vecs[0], UpdatedAt: time.Now()}
+	if err := db.UpsertBookEmbedding(ctx, embedding); err != nil {
+		return false, err
+	}
+	if vectors != nil {
+		vectors.Put(book.ID, embedding.Vector)
+	}
+	return true, nil
+}
+
+// embeddingText is what a book is embedded from: its title, authors, categories and description, then the start
+// of its text.
+func embeddingText(ctx context.Context, storage service.ObjectStore, book *models.Book) string {
+	var b strings.Builder
+	b.WriteString(book.Title)
+	if len(book.Authors) > 0 {
+		b.WriteString("\nby " + strings.Join(book.Authors, ", "))
+	}
+	categories := book.Categories
+	if len(categories) == 0 && book.Category != "" {
+		categories = []string{book.Category}
+	}
+	if len(categories) > 0 {
+		b.WriteString("\nCategories: " + strings.Join(categories, "; "))
+	}
+	if book.Preface != "" {
+		b.WriteString("\n\n" + book.Preface)
+	}
+	if text := startOfText(ctx, storage, book, service.EmbedTextChars); text != "" {
+		b.WriteString("\n\n" + text)
+	}
+	return b.String()
+}
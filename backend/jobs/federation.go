@@ -0,0 +1,351 @@
+package jobs
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kevinaaaquil/books/backend/middleware"
+	"github.com/kevinaaaquil/books/backend/models"
+	"github.com/kevinaaaquil/books/backend/store"
+	"github.com/kevinaaaquil/books/backend/utils"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// TypeFederation pulls books and reading progress from the instances this one mirrors (see models.SyncPeer).
+const TypeFederation = "federation"
+
+// syncPageSize is how many books or reading positions are asked for at a time.
+const syncPageSize = 100
+
+// FederatedIngestFunc adds a peer's book to the library with the given file, keeping the peer's metadata, and
+// returns the new book's ID.
+type FederatedIngestFunc func(ctx context.Context, peer *models.SyncPeer, book *models.SyncBook, data []byte) (primitive.ObjectID, error)
+
+// FederationOptions configures the federation job.
+type FederationOptions struct {
+	Client   *http.Client
+	Ingest   FederatedIngestFunc
+	EncKey   []byte             // decrypts API keys stored encrypted (32 bytes)
+	MaxBytes int64              // larger files fail; 0 = no limit
+	PeerID   primitive.ObjectID // sync only this peer; zero syncs every peer
+}
+
+// Federation returns a job that pulls, from each peer, the books added to its chosen collections since the last
+// sync and, when the peer is set to, its users' reading progress. Books whose SHA-256 matches one already in the
+// library are not downloaded again, so two instances can mirror each other. Progress is matched by user email
+// and file SHA-256, and the newer position wins. A book that fails stops its collection there, to be tried again
+// at the next sync; how far each pull got is saved as it goes.
+func Federation(db store.Store, opts FederationOptions) Func {
+	return func(ctx context.Context, p *Progress) error {
+		peers, err := db.SyncPeers(ctx)
+		if err != nil {
+			return err
+		}
+		total := 0
+		for i := range peers {
+			if !opts.PeerID.IsZero() && peers[i].ID != opts.PeerID {
+				continue
+			}
+			if err := syncPeer(ctx, db, opts, &peers[i], p, &total); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// peerSync is one peer's sync in progress.
+type peerSync struct {
+	db     store.Store
+	opts   FederationOptions
+	peer   *models.SyncPeer
+	sync   *models.PeerSync
+	p      *Progress
+	total  *int
+	client *http.Client
+	base   *url.URL
+	key    string
+}
+
+// get fetches path on the peer with the API key and decodes the JSON answer into v.
+func (c *peerSync) get(ctx context.Context, path string, q url.Values, v any) error {
+	u := c.base.JoinPath(path)
+	u.RawQuery = q.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set(middleware.APIKeyHeader, c.key)
+	res, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(res.Body, 512))
+		return fmt.Errorf("%s: %s %s", path, res.Status, strings.TrimSpace(string(body)))
+	}
+	return json.NewDecoder(res.Body).Decode(v)
+}
+
+// download reads the file at fileURL, which may be a path on the peer, up to maxBytes (0 = no limit).
+func (c *peerSync) download(ctx context.Context, fileURL string, maxBytes int64) ([]byte, error) {
+	u, err := c.base.Parse(fileURL)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("download: %s", res.Status)
+	}
+	var r io.Reader = res.Body
+	if maxBytes > 0 {
+		r = io.LimitReader(res.Body, maxBytes+1)
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if maxBytes > 0 && int64(len(data)) > maxBytes {
+		return nil, fmt.Errorf("file is over the upload limit of %d bytes", maxBytes)
+	}
+	return data, nil
+}
+
+// syncPeer pulls from one peer. Problems with the peer are recorded in its LastSync; only database errors and
+// cancellation are returned.
+func syncPeer(ctx context.Context, db store.Store, opts FederationOptions, peer *models.SyncPeer, p *Progress, total *int) error {
+	sync := &models.PeerSync{At: time.Now()}
+	save := func() error {
+		return db.SaveSyncProgress(context.WithoutCancel(ctx), peer.ID, peer.Cursors, peer.ProgressCursor, sync)
+	}
+	problem := func(err error) {
+		p.Logf("%s: %v", peer.Name, err)
+		if sync.Error == "" {
+			sync.Error = err.Error()
+		}
+	}
+	key := peer.APIKey
+	if len(opts.EncKey) == 32 {
+		var err error
+		if key, err = utils.Decrypt(key, opts.EncKey); err != nil {
+			problem(err)
+			return save()
+		}
+	}
+	base, err := url.Parse(peer.URL)
+	if err != nil {
+		problem(err)
+		return save()
+	}
+	c := &peerSync{db: db, opts: opts, peer: peer, sync: sync, p: p, total: total, client: opts.Client, base: base, key: key}
+
+	for _, collection := range peer.Collections {
+		failed, err := c.pullCollection(ctx, collection)
+		if err == nil && ctx.Err() != nil {
+			err = ctx.Err()
+		}
+		if err != nil {
+			if serr := save(); serr != nil {
+				log.Printf("federation: %s: %v", peer.Name, serr)
+			}
+			return err
+		}
+		if failed != nil {
+			problem(fmt.Errorf("%s: %w", collection, failed))
+		}
+	}
+	if peer.Progress {
+		failed, err := c.pullProgress(ctx)
+		if err == nil && ctx.Err() != nil {
+			err = ctx.Err()
+		}
+		if err != nil {
+			if serr := save(); serr != nil {
+				log.Printf("federation: %s: %v", peer.Name, serr)
+			}
+			return err
+		}
+		if failed != nil {
+			problem(fmt.Errorf("reading progress: %w", failed))
+		}
+	}
+	return save()
+}
+
+// pullCollection pulls the books added to one of the peer's collections since its cursor, advancing the
+// cursor a page at a time. It returns what went wrong with the peer (which stops the collection until the next
+// sync) separately from database errors.
+func (c *peerSync) pullCollection(ctx context.Context, collection string) (failed, err error) {
+	db, peer, sync, p := c.db, c.peer, c.sync, c.p
+	at := -1
+	for i, cur := range peer.Cursors {
+		if cur.Collection == collection {
+			at = i
+		}
+	}
+	if at < 0 {
+		peer.Cursors = append(peer.Cursors, models.SyncCursor{Collection: collection})
+		at = len(peer.Cursors) - 1
+	}
+	for {
+		var page models.SyncBooksPage
+		q := url.Values{"collection": {collection}, "cursor": {peer.Cursors[at].Cursor}, "limit": {strconv.Itoa(syncPageSize)}}
+		if err := c.get(ctx, "/api/sync/books", q, &page); err != nil {
+			return err, nil
+		}
+		*c.total += len(page.Books)
+		p.SetTotal(*c.total)
+		for i := range page.Books {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			duplicate, failed, err := c.pullBook(ctx, &page.Books[i])
+			if err != nil {
+				return nil, err
+			}
+			if failed != nil {
+				sync.Failed++
+				p.Step("failed")
+				return fmt.Errorf("%q: %w", page.Books[i].Title, failed), nil
+			}
+			if duplicate {
+				sync.Duplicates++
+				p.Step("duplicates")
+			} else {
+				sync.Imported++
+				p.Step("imported")
+			}
+		}
+		if page.Cursor != "" {
+			peer.Cursors[at].Cursor = page.Cursor
+		}
+		if err := db.SaveSyncProgress(ctx, peer.ID, peer.Cursors, peer.ProgressCursor, sync); err != nil {
+			return nil, err
+		}
+		if !page.More || len(page.Books) == 0 {
+			return nil, nil
+		}
+	}
+}
+
+// pullBook adds one of the peer's books to the library, unless a book with the same content is already there.
+func (c *peerSync) pullBook(ctx context.Context, book *models.SyncBook) (duplicate bool, failed, err error) {
+	db, opts := c.db, c.opts
+	if book.SHA256 != "" {
+		existing, err := db.BookBySHA256(ctx, book.SHA256)
+		if err != nil {
+			return false, nil, err
+		}
+		if existing != nil {
+			return true, nil, nil
+		}
+	}
+	if book.FileURL == "" {
+		return false, fmt.Errorf("the peer can't offer its file now (archived or missing)"), nil
+	}
+	if opts.MaxBytes > 0 && book.SizeBytes > opts.MaxBytes {
+		return false, fmt.Errorf("file is %d bytes, over the upload limit of %d", book.SizeBytes, opts.MaxBytes), nil
+	}
+	data, failed := c.download(ctx, book.FileURL, opts.MaxBytes)
+	if failed != nil {
+		return false, failed, nil
+	}
+	sum := sha256.Sum256(data)
+	if book.SHA256 != "" && hex.EncodeToString(sum[:]) != book.SHA256 {
+		return false, fmt.Errorf("downloaded file does not match its SHA-256"), nil
+	}
+	if existing, err := db.BookBySHA256(ctx, hex.EncodeToString(sum[:])); err != nil || existing != nil {
+		return existing != nil, nil, err
+	}
+	if _, failed := opts.Ingest(ctx, c.peer, book, data); failed != nil {
+		return false, failed, nil
+	}
+	return false, nil, nil
+}
+
+// pullProgress pulls the reading positions updated on the peer since its progress cursor. Positions of users
+// or books this library doesn't have are skipped.
+func (c *peerSync) pullProgress(ctx context.Context) (failed, err error) {
+	db, peer, sync, p := c.db, c.peer, c.sync, c.p
+	users, err := db.ListUsers(ctx)
+	if err != nil {
+		return nil, err
+	}
+	byEmail := make(map[string]primitive.ObjectID, len(users))
+	for _, u := range users {
+		byEmail[strings.ToLower(u.Email)] = u.ID
+	}
+	books := map[string]*models.Book{} // by SHA-256; nil when the library doesn't have it
+	for {
+		var page models.SyncProgressPage
+		q := url.Values{"cursor": {peer.ProgressCursor}, "limit": {strconv.Itoa(syncPageSize)}}
+		if err := c.get(ctx, "/api/sync/progress", q, &page); err != nil {
+			return err, nil
+		}
+		for _, sp := range page.Progress {
+			userID, ok := byEmail[strings.ToLower(sp.Email)]
+			if !ok || sp.SHA256 == "" {
+				continue
+			}
+			book, seen := books[sp.SHA256]
+			if !seen {
+				if book, err = db.BookBySHA256(ctx, sp.SHA256); err != nil {
+					return nil, err
+				}
+				books[sp.SHA256] = book
+			}
+			if book == nil {
+				continue
+			}
+			mine, err := db.ReadingProgressFor(ctx, userID, book.ID)
+			if err != nil {
+				return nil, err
+			}
+			if mine != nil && !sp.UpdatedAt.After(mine.UpdatedAt) {
+				continue
+			}
+			rp := &models.ReadingProgress{
+				UserID:         userID,
+				BookID:         book.ID,
+				Anchor:         sp.Anchor,
+				KindleLocation: sp.KindleLocation,
+				Percent:        sp.Percent,
+				Device:         sp.Device,
+				UpdatedAt:      sp.UpdatedAt,
+			}
+			if err := db.UpsertReadingProgress(ctx, rp); err != nil {
+				return nil, err
+			}
+			sync.Progress++
+			p.Step("progress")
+		}
+		if page.Cursor != "" {
+			peer.ProgressCursor = page.Cursor
+		}
+		if err := db.SaveSyncProgress(ctx, peer.ID, peer.Cursors, peer.ProgressCursor, sync); err != nil {
+			return nil, err
+		}
+		if !page.More || len(page.Progress) == 0 {
+			return nil, nil
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package jobs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/kevinaaaquil/books/backend/store"
+)
+
+const (
+	// lockTTL is how long a lease outlives an instance that stops renewing it (e.g. one that crashed).
+	lockTTL = time.Minute
+	// lockRenewInterval leaves room for two failed renewals before the lease expires.
+	lockRenewInterval = lockTTL / 3
+)
+
+// InstanceID identifies this process in lock leases: host name, process ID and a random suffix (host name and
+// PID alone can repeat across containers).
+func InstanceID() string {
+	host, _ := os.Hostname()
+	suffix := make([]byte, 4)
+	rand.Read(suffix)
+	return fmt.Sprintf("%s-%d-%s", host, os.Getpid(), hex.EncodeToString(suffix))
+}
+
+// holdLock renews owner's lease on name until ctx is cancelled, then releases it. If the lease is lost to another
+// instance (renewals failed for longer than lockTTL), it calls lost and stops.
+func holdLock(ctx context.Context, locks store.LockStore, name, owner string, lost func()) {
+	ticker := time.NewTicker(lockRenewInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			if err := locks.ReleaseLock(context.Background(), name, owner); err != nil {
+				log.Printf("lock %s: release: %v", name, err)
+			}
+			return
+		case <-ticker.C:
+		}
+		ok, err := locks.AcquireLock(ctx, name, owner, time.Now(), lockTTL)
+		switch {
+		case ctx.Err() != nil:
+		case err != nil:
+			log.Printf("lock %s: renew: %v", name, err)
+		case !ok:
+			log.Printf("lock %s: lost to another instance", name)
+			lost()
+			return
+		}
+	}
+}
+
+// Leader campaigns for a named lease so that, with several API instances, one of them (the leader) runs the
+// schedulers. The others take over within lockTTL if it stops.
+type Leader struct {
+	locks   store.LockStore
+	name    string
+	owner   string
+	leading atomic.Bool
+}
+
+func NewLeader(locks store.LockStore, name, owner string) *Leader {
+	return &Leader{locks: locks, name: name, owner: owner}
+}
+
+// IsLeader reports whether this instance held the lease at the last attempt to take or renew it.
+func (l *Leader) IsLeader() bool {
+	return l.leading.Load()
+}
+
+// Run takes the lease when it is free and renews it while held, until ctx is cancelled; then it releases the
+// lease so another instance can lead straight away.
+func (l *Leader) Run(ctx context.Context) {
+	ticker := time.NewTicker(lockRenewInterval)
+	defer ticker.Stop()
+	for {
+		ok, err := l.locks.AcquireLock(ctx, l.name, l.owner, time.Now(), lockTTL)
+		if ctx.Err() != nil {
+			break
+		}
+		if err != nil {
+			// Without a renewal the lease may pass to another instance; stand down rather than risk two leaders.
+			log.Printf("leader %s: %v", l.name, err)
+			ok = false
+		}
+		if l.leading.Swap(ok) != ok {
+			if ok {
+				log.Printf("leader %s: this instance (%s) is now leading", l.name, l.owner)
+			} else {
+				log.Printf("leader %s: this instance (%s) stood down", l.name, l.owner)
+			}
+		}
+		select {
+		case <-ctx.Done():
+		case <-ticker.C:
+			continue
+		}
+		break
+	}
+	if l.leading.Swap(false) {
+		if err := l.locks.ReleaseLock(context.Background(), l.name, l.owner); err != nil {
+			log.Printf("leader %s: release: %v", l.name, err)
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package jobs
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/kevinaaaquil/books/backend/models"
+	"github.com/kevinaaaquil/books/backend/service"
+	"github.com/kevinaaaquil/books/backend/store"
+)
+
+// TypeMigrateStorage copies every stored file to another storage backend.
+const TypeMigrateStorage = "migrate-storage"
+
+// MigrateStorage returns a job that copies the objects the database refers to (database backups, and each book's
+// file and cover) from storage to target under the same keys, so switching to target is a configuration change
+// and nothing in the database has to be rewritten. Each copy is read back and its SHA-256 checked against the
+// book's, or the source's; objects target already holds intact are not copied again, so running it again just
+// before switching copies only what was added since. Archived files that need a restore are skipped until they
+// are restored. Nothing is deleted from storage. An interrupted run resumes after the last book it copied.
+func MigrateStorage(db store.Store, storage, target service.ObjectStore) Func {
+	return func(ctx context.Context, p *Progress) error {
+		if pinger, ok := target.(service.Pinger); ok {
+			if err := pinger.Ping(ctx); err != nil {
+				return fmt.Errorf("target storage: %w", err)
+			}
+		}
+		backups, err := db.ListBackups(ctx)
+		if err != nil {
+			return err
+		}
+		total, err := db.BooksCount(ctx)
+		if err != nil {
+			return err
+		}
+		p.SetTotal(len(backups) + int(total))
+		resumed := p.Resumed()
+		// Backups are few and come first, so a resumed run checks them again rather than tracking its place.
+		for _, b := range backups {
+			outcome, err := migrateObject(ctx, storage, target, b.Key, "")
+			if err != nil {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				p.Logf("backup %s: %v", b.Key, err)
+				outcome = "failed"
+			}
+			p.Step(outcome)
+		}
+		now := time.Now()
+		return db.ForEachBook(ctx, func(book *models.Book) error {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if bookDone(book, resumed) {
+				p.Step() // copied before the interruption
+				return nil
+			}
+			outcome, err := migrateBook(ctx, storage, target, book, now)
+			if err != nil {
+				if ctx.Err() != nil {
+					return ctx.Err() // copy it again after a restart
+				}
+				p.Logf("%s: %v", book.ID.Hex(), err)
+				outcome = "failed"
+			}
+			p.Checkpoint(bookCheckpoint(book))
+			p.Step(outcome)
+			return nil
+		})
+	}
+}
+
+// migrateBook copies the book's file and cover, returning the file's outcome.
+func migrateBook(ctx context.Context, storage, target service.ObjectStore, book *models.Book, now time.Time) (string, error) {
+	if book.S3Key == "" {
+		return "missing", nil
+	}
+	if service.NeedsRestore(book.StorageClass) {
+		tier, ok := service.TiererOf(storage)
+		if !ok {
+			return "archived", nil
+		}
+		info, err := tier.Tier(ctx, book.S3Key)
+		if err != nil && !errors.Is(err, service.ErrObjectNotFound) {
+			return "", err
+		}
+		if info != nil && !info.Readable(now) {
+			return "archived", nil
+		}
+	}
+	outcome, err := migrateObject(ctx, storage, target, book.S3Key, book.SHA256)
+	if err != nil {
+		return "", err
+	}
+	if book.CoverS3Key != "" {
+		if _, err := migrateObject(ctx, storage, target, book.CoverS3Key, ""); err != nil {
+			return "", fmt.Errorf("cover: %w", err)
+		}
+	}
+	return outcome, nil
+}
+
+// migrateObject copies key from storage to target unless target already has it with SHA-256 wantSHA256 (the
+// source's own, when empty), and checks the copy. It returns "copied", "present" or "missing" (not in storage).
+func migrateObject(ctx context.Context, storage, target service.ObjectStore, key, wantSHA256 string) (string, error) {
+	if wantSHA256 == "" {
+		info, err := storage.HeadObject(ctx, key)
+		if errors.Is(err, service.ErrObjectNotFound) {
+			return "missing", nil
+		}
+		if err != nil {
+			return "", err
+		}
+		wantSHA256 = info.SHA256
+	}
+	if wantSHA256 != "" {
+		if got, err := objectSHA256(ctx, target, key); err != nil {
+			return "", err
+		} else if strings.EqualFold(got, wantSHA256) {
+			return "present", nil
+		}
+	}
+	body, contentType, err := storage.GetObject(ctx, key)
+	if errors.Is(err, service.ErrObjectNotFound) {
+		return "missing", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	defer body.Close()
+
+	// Spool to disk: S3 needs a seekable body to check a checksum, and the hash must be known before the put.
+	tmp, err := os.CreateTemp("", "books-migrate-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+	h := sha256.New()
+	if _, err := io.Copy(tmp, io.TeeReader(body, h)); err != nil {
+		return "", fmt.Errorf("read: %w", err)
+	}
+	sum := hex.EncodeToString(h.Sum(nil))
+	if wantSHA256 != "" && !strings.EqualFold(sum, wantSHA256) {
+		return "", fmt.Errorf("stored object does not match its SHA-256 (%s, want %s)", sum, wantSHA256)
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	if putter, ok := target.(service.ChecksumPutter); ok {
+		err = putter.PutWithSHA256(ctx, key, tmp, contentType, sum)
+	} else {
+		err = target.Put(ctx, key, tmp, contentType)
+	}
+	if err != nil {
+		return "", fmt.Errorf("write: %w", err)
+	}
+	got, err := objectSHA256(ctx, target, key)
+	if err != nil {
+		return "", fmt.Errorf("verify: %w", err)
+	}
+	if got != sum {
+		return "", fmt.Errorf("copy does not match the original (%s, want %s)", got, sum)
+	}
+	return "copied", nil
+}
+
+// objectSHA256 returns the hex SHA-256 of the object at key, as its store records it or else by reading it; ""
+// when there is no such object.
+func objectSHA256(ctx context.Context, storage service.ObjectStore, key string) (string, error) {
+	info, err := storage.HeadObject(ctx, key)
+	if errors.Is(err, service.ErrObjectNotFound) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if info.SHA256 != "" {
+		return strings.ToLower(info.SHA256), nil
+	}
+	body, _, err := storage.GetObject(ctx, key)
+	if err != nil {
+		return "", err
+	}
+	defer body.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, body); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,201 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/kevinaaaquil/books/backend/models"
+	"github.com/kevinaaaquil/books/backend/notify"
+	"github.com/kevinaaaquil/books/backend/service"
+	"github.com/kevinaaaquil/books/backend/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// TypeNewReleases looks for recently published books by the authors users have read.
+const TypeNewReleases = "new-releases"
+
+// NewReleaseWindow is how recently a book must have been published to count as new. Books announced for later
+// count too.
+const NewReleaseWindow = 365 * 24 * time.Hour
+
+// newReleasesPerAuthor is how many of each author's newest books are asked for.
+const newReleasesPerAuthor = 20
+
+// notifiedTitles is how many titles a new releases notification lists before "and N more".
+const notifiedTitles = 5
+
+// NewReleases returns a job that asks the metadata provider for the newest books by every author a user has
+// finished a book by (see models.FinishedPercent), and records those published within NewReleaseWindow that
+// the library doesn't have as the user's new releases. Users with new ones get a notification.
+func NewReleases(db store.Store, search service.AuthorSearcher, notifier *notify.Service) Func {
+	return func(ctx context.Context, p *Progress) error {
+		books, err := db.AllBooks(ctx)
+		if err != nil {
+			return err
+		}
+		progress, err := db.AllReadingProgress(ctx)
+		if err != nil {
+			return err
+		}
+		users, err := db.ListUsers(ctx)
+		if err != nil {
+			return err
+		}
+		readAuthors := finishedAuthors(books, progress)
+		var authors []string
+		seen := map[string]bool{}
+		for _, names := range readAuthors {
+			for _, a := range names {
+				if !seen[strings.ToLower(a)] {
+					seen[strings.ToLower(a)] = true
+					authors = append(authors, a)
+				}
+			}
+		}
+		sort.Strings(authors)
+		p.SetTotal(len(authors) + len(users))
+
+		owned := map[string]bool{}
+		for _, b := range books {
+			for _, a := range b.Authors {
+				owned[releaseKey(a, b.Title)] = true
+			}
+			if b.ISBN != "" {
+				owned[normalizeISBN(b.ISBN)] = true
+			}
+		}
+		now := time.Now()
+		found := map[string][]service.BookMetadata{} // by lowercased author
+		for _, author := range authors {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			results, err := search.SearchByAuthor(ctx, author, newReleasesPerAuthor)
+			if err != nil {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				p.Logf("%s: %v", author, err)
+				p.Step("errors")
+				continue
+			}
+			for _, m := range results {
+				if !containsFold(m.Authors, author) || owned[releaseKey(author, m.Title)] || (m.ISBN != "" && owned[normalizeISBN(m.ISBN)]) {
+					continue
+				}
+				if published, ok := publishedBy(m.PublishDate); !ok || published.Before(now.Add(-NewReleaseWindow)) {
+					continue
+				}
+				found[strings.ToLower(author)] = append(found[strings.ToLower(author)], m)
+			}
+			p.Step("searched")
+		}
+
+		for _, u := range users {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			var added []models.NewRelease
+			for _, author := range readAuthors[u.ID] {
+				for _, m := range found[strings.ToLower(author)] {
+					r := models.NewRelease{
+						UserID:       u.ID,
+						Key:          releaseKey(author, m.Title),
+						Author:       author,
+						ISBN:         m.ISBN,
+						Title:        m.Title,
+						Authors:      m.Authors,
+						Publisher:    m.Publisher,
+						PublishDate:  m.PublishDate,
+						CoverURL:     m.CoverURL,
+						ThumbnailURL: m.ThumbnailURL,
+						FoundAt:      now,
+					}
+					ok, err := db.AddNewRelease(ctx, &r)
+					if err != nil {
+						return err
+					}
+					if ok {
+						added = append(added, r)
+					}
+				}
+			}
+			if len(added) == 0 {
+				p.Step()
+				continue
+			}
+			title, body := newReleasesMessage(added)
+			if err := notifier.NotifyUser(ctx, &u, models.NotificationNewReleases, title, body, "/api/me/new-releases"); err != nil {
+				return err
+			}
+			p.Step("notified")
+		}
+		return nil
+	}
+}
+
+// finishedAuthors returns, for each user, the authors of the books they finished, as first spelled.
+func finishedAuthors(books []models.Book, progress []models.ReadingProgress) map[primitive.ObjectID][]string {
+	byID := make(map[primitive.ObjectID]*models.Book, len(books))
+	for i := range books {
+		byID[books[i].ID] = &books[i]
+	}
+	authors := map[primitive.ObjectID][]string{}
+	for _, p := range progress {
+		b := byID[p.BookID]
+		if b == nil || p.Percent < models.FinishedPercent {
+			continue
+		}
+		for _, a := range b.Authors {
+			if a = strings.TrimSpace(a); a != "" && !containsFold(authors[p.UserID], a) {
+				authors[p.UserID] = append(authors[p.UserID], a)
+			}
+		}
+	}
+	return authors
+}
+
+func releaseKey(author, title string) string {
+	return strings.ToLower(strings.TrimSpace(author)) + "/" + strings.ToLower(strings.TrimSpace(title))
+}
+
+func normalizeISBN(isbn string) string {
+	return strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(isbn))
+}
+
+// publishedBy returns the latest day a publication date as metadata providers give it ("2024", "2024-05" or
+// "2024-05-17") can mean.
+func publishedBy(date string) (time.Time, bool) {
+	date = strings.TrimSpace(date)
+	for _, f := range []struct {
+		layout     string
+		years, mon int
+	}{{"2006-01-02", 0, 0}, {"2006-01", 0, 1}, {"2006", 1, 0}} {
+		if t, err := time.Parse(f.layout, date); err == nil {
+			if f.years > 0 || f.mon > 0 {
+				t = t.AddDate(f.years, f.mon, -1)
+			}
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// newReleasesMessage is the notification title and body for the releases found for a user.
+func newReleasesMessage(added []models.NewRelease) (string, string) {
+	if len(added) == 1 {
+		return fmt.Sprintf("New from %s: %s", added[0].Author, added[0].Title), ""
+	}
+	var lines []string
+	for i, r := range added {
+		if i == notifiedTitles {
+			lines = append(lines, fmt.Sprintf("and %d more", len(added)-notifiedTitles))
+			break
+		}
+		lines = append(lines, r.Title+" by "+r.Author)
+	}
+	return fmt.Sprintf("%d new releases by authors you've read", len(added)), strings.Join(lines, "\n")
+}
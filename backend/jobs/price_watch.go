@@ -0,0 +1,104 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/kevinaaaquil/books/backend/models"
+	"github.com/kevinaaaquil/books/backend/notify"
+	"github.com/kevinaaaquil/books/backend/service"
+	"github.com/kevinaaaquil/books/backend/store"
+)
+
+// TypePriceWatch checks the prices of watched wishlist items.
+const TypePriceWatch = "price-watch"
+
+// PriceWatch returns a job that asks every provider for the price of each wishlist item with a price watch,
+// records the quotes in its price history and the lowest one in the watch, and notifies the user when that is
+// at or below their threshold and lower than the last price they were told about.
+func PriceWatch(db store.Store, providers []service.PriceProvider, notifier *notify.Service) Func {
+	return func(ctx context.Context, p *Progress) error {
+		items, err := db.WatchedWishlistItems(ctx)
+		if err != nil {
+			return err
+		}
+		p.SetTotal(len(items))
+		for _, item := range items {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			notified, err := checkPrice(ctx, db, providers, notifier, &item)
+			if err != nil {
+				return err
+			}
+			if notified {
+				p.Step("checked", "notified")
+			} else {
+				p.Step("checked")
+			}
+		}
+		return nil
+	}
+}
+
+// checkPrice updates one item's watch and history, reporting whether the user was notified.
+func checkPrice(ctx context.Context, db store.Store, providers []service.PriceProvider, notifier *notify.Service, item *models.WishlistItem) (bool, error) {
+	watch := item.PriceWatch
+	now := time.Now()
+	q := service.PriceQuery{ISBN: item.ISBN, Title: item.Title, Authors: item.Authors}
+	var best *service.PriceQuote
+	for _, provider := range providers {
+		quote, err := provider.Price(ctx, q)
+		if err != nil {
+			if ctx.Err() != nil {
+				return false, ctx.Err()
+			}
+			log.Printf("price watch: %s for %s: %v", provider.Name(), item.ID.Hex(), err)
+			continue
+		}
+		if quote == nil {
+			continue
+		}
+		point := &models.PricePoint{ItemID: item.ID, UserID: item.UserID, Provider: quote.Provider, Price: quote.Price, Currency: quote.Currency, URL: quote.URL, CheckedAt: now}
+		if err := db.InsertPricePoint(ctx, point); err != nil {
+			return false, err
+		}
+		if strings.EqualFold(quote.Currency, watch.Currency) && (best == nil || quote.Price < best.Price) {
+			best = quote
+		}
+	}
+	watch.CheckedAt = &now
+	watch.Price, watch.Provider, watch.URL = 0, "", ""
+	dropped := false
+	if best != nil {
+		watch.Price, watch.Provider, watch.URL = best.Price, best.Provider, best.URL
+		if best.Price > watch.Threshold {
+			watch.NotifiedPrice = 0
+		} else if watch.NotifiedPrice == 0 || best.Price < watch.NotifiedPrice {
+			watch.NotifiedPrice = best.Price
+			dropped = true
+		}
+	}
+	if _, err := db.UpdateWishlistItem(ctx, item); err != nil {
+		return false, err
+	}
+	if !dropped {
+		return false, nil
+	}
+	user, err := db.UserByID(ctx, item.UserID)
+	if err != nil || user == nil {
+		return false, err
+	}
+	title := fmt.Sprintf("Price drop: %s is %.2f %s at %s", item.Title, best.Price, strings.ToUpper(best.Currency), best.Provider)
+	body := fmt.Sprintf("Your threshold is %.2f %s.", watch.Threshold, strings.ToUpper(watch.Currency))
+	if best.URL != "" {
+		body += "\n" + best.URL
+	}
+	if err := notifier.NotifyUser(ctx, user, models.NotificationPriceDrop, title, body, "/api/wishlist/"+item.ID.Hex()+"/prices"); err != nil {
+		return false, err
+	}
+	return true, nil
+}
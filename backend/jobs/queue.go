@@ -0,0 +1,257 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/kevinaaaquil/books/backend/models"
+	"github.com/kevinaaaquil/books/backend/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Handler runs one attempt of a queued job and returns its result. A failed attempt is retried with backoff
+// unless its error is Permanent.
+type Handler func(ctx context.Context, job *models.QueuedJob) (map[string]string, error)
+
+type permanentError struct{ err error }
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent marks err as a failure another attempt can't fix, so the job fails at once.
+func Permanent(err error) error {
+	return &permanentError{err}
+}
+
+// IsPermanent reports whether err was marked with Permanent.
+func IsPermanent(err error) bool {
+	var p *permanentError
+	return errors.As(err, &p)
+}
+
+const (
+	// queueAttemptTimeout bounds one attempt; the lease outlives it, so a job is only run again once its
+	// instance is surely gone.
+	queueAttemptTimeout = 10 * time.Minute
+	queueLease          = queueAttemptTimeout + time.Minute
+	// queuePollInterval is how often idle workers look for jobs queued by other instances, and due retries.
+	queuePollInterval = 2 * time.Second
+	// queueBrokerPollInterval replaces it when a Broker announces jobs, and only finds those whose announcement
+	// was lost.
+	queueBrokerPollInterval = 30 * time.Second
+	// queueMaxBackoff caps the delay between attempts.
+	queueMaxBackoff = time.Hour
+	// queueRetention is how long finished jobs stay readable.
+	queueRetention = 7 * 24 * time.Hour
+)
+
+// Queue runs queued jobs with a pool of workers. Jobs live in the store, so any instance can pick them up, and
+// one that was running on an instance that stopped is run again once its lease runs out.
+type Queue struct {
+	DB          store.Store
+	Instance    string
+	Workers     int // 0 queues jobs for other instances to run
+	MaxAttempts int
+	Backoff     time.Duration // delay before the second attempt, doubling for each one after
+	Kinds       []string      // the kinds of job the workers run; nil for all
+	Broker      Broker        // announces jobs across instances; nil to find them by polling the store
+
+	handlers map[string]Handler
+	wake     chan struct{}
+	ctx      context.Context // cancelled by Shutdown
+	stop     context.CancelFunc
+	active   sync.WaitGroup
+	started  bool
+}
+
+// NewQueue returns a queue that runs jobs with workers workers once started, giving each up to maxAttempts attempts.
+func NewQueue(db store.Store, instance string, workers, maxAttempts int, backoff time.Duration) *Queue {
+	ctx, stop := context.WithCancel(context.Background())
+	return &Queue{
+		DB: db, Instance: instance, Workers: max(workers, 0), MaxAttempts: max(maxAttempts, 1), Backoff: backoff,
+		handlers: map[string]Handler{}, wake: make(chan struct{}, 1), ctx: ctx, stop: stop,
+	}
+}
+
+// Handle registers the handler for jobs of kind. Call it before Start.
+func (q *Queue) Handle(kind string, h Handler) {
+	q.handlers[kind] = h
+}
+
+// Handles reports whether a handler is registered for jobs of kind.
+func (q *Queue) Handles(kind string) bool {
+	return q.handlers[kind] != nil
+}
+
+// Enqueue queues a job of kind for userID, due at once, and returns it.
+func (q *Queue) Enqueue(ctx context.Context, kind string, userID primitive.ObjectID, params map[string]string) (*models.QueuedJob, error) {
+	if q.handlers[kind] == nil {
+		return nil, fmt.Errorf("no handler for %s jobs", kind)
+	}
+	now := time.Now()
+	job := &models.QueuedJob{
+		Kind:        kind,
+		UserID:      userID,
+		Params:      params,
+		Status:      models.QueuedJobQueued,
+		MaxAttempts: q.MaxAttempts,
+		RunAt:       now,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if err := q.DB.InsertQueuedJob(ctx, job); err != nil {
+		return nil, err
+	}
+	go q.announce(kind, job.ID)
+	return job, nil
+}
+
+// announce tells a worker that the job id of kind is due: through the broker, or else this instance's.
+func (q *Queue) announce(kind string, id primitive.ObjectID) {
+	if q.Broker != nil {
+		err := q.Broker.Announce(q.ctx, kind, id)
+		if err == nil || q.ctx.Err() != nil {
+			return
+		}
+		log.Printf("job queue: announce %s job %s: %v", kind, id.Hex(), err)
+	}
+	q.notify()
+}
+
+// notify wakes an idle worker.
+func (q *Queue) notify() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Start launches the workers, and has the broker wake them for jobs of their kinds.
+func (q *Queue) Start() {
+	if q.started || q.Workers == 0 {
+		return
+	}
+	q.started = true
+	for range q.Workers {
+		q.active.Add(1)
+		go q.work()
+	}
+	if q.Broker != nil {
+		kinds := q.Kinds
+		if kinds == nil {
+			for kind := range q.handlers {
+				kinds = append(kinds, kind)
+			}
+			slices.Sort(kinds)
+		}
+		q.active.Add(1)
+		go func() {
+			defer q.active.Done()
+			q.Broker.Listen(q.ctx, kinds, func(string) { q.notify() })
+		}()
+	}
+}
+
+// Shutdown stops the workers and waits for the attempts in progress, which are cancelled and queued again,
+// until ctx expires.
+func (q *Queue) Shutdown(ctx context.Context) error {
+	q.stop()
+	drained := make(chan struct{})
+	go func() {
+		q.active.Wait()
+		close(drained)
+	}()
+	select {
+	case <-drained:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("queued jobs still running at shutdown: %w", ctx.Err())
+	}
+}
+
+func (q *Queue) work() {
+	defer q.active.Done()
+	interval := queuePollInterval
+	if q.Broker != nil {
+		interval = queueBrokerPollInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	var lastCleanup time.Time
+	for q.ctx.Err() == nil {
+		now := time.Now()
+		job, err := q.DB.ClaimQueuedJob(q.ctx, q.Instance, q.Kinds, now, now.Add(queueLease))
+		if err != nil && q.ctx.Err() == nil {
+			log.Printf("job queue: claim: %v", err)
+		}
+		if job != nil {
+			q.run(job)
+			continue
+		}
+		if now.Sub(lastCleanup) > time.Hour {
+			lastCleanup = now
+			if err := q.DB.DeleteFinishedQueuedJobs(q.ctx, now.Add(-queueRetention)); err != nil && q.ctx.Err() == nil {
+				log.Printf("job queue: delete finished jobs: %v", err)
+			}
+		}
+		select {
+		case <-q.ctx.Done():
+		case <-q.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// run makes one attempt of job and saves its outcome: done, failed, or queued again after a backoff.
+func (q *Queue) run(job *models.QueuedJob) {
+	var result map[string]string
+	var err error
+	if h := q.handlers[job.Kind]; h == nil {
+		err = Permanent(fmt.Errorf("no handler for %s jobs", job.Kind))
+	} else {
+		ctx, cancel := context.WithTimeout(q.ctx, queueAttemptTimeout)
+		result, err = h(ctx, job)
+		cancel()
+	}
+	now := time.Now()
+	job.UpdatedAt, job.LeaseUntil = now, nil
+	switch {
+	case err == nil:
+		job.Status, job.Result, job.Error, job.FinishedAt = models.QueuedJobSucceeded, result, "", &now
+	case q.ctx.Err() != nil:
+		// Shut down mid-attempt: not the job's fault, so the attempt doesn't count.
+		job.Status, job.RunAt = models.QueuedJobQueued, now
+		job.Attempts--
+	case IsPermanent(err) || job.Attempts >= job.MaxAttempts:
+		job.Status, job.Error, job.FinishedAt = models.QueuedJobFailed, err.Error(), &now
+		log.Printf("job queue: %s job %s failed after %d attempt(s): %v", job.Kind, job.ID.Hex(), job.Attempts, err)
+	default:
+		delay := q.backoff(job.Attempts)
+		job.Status, job.Error, job.RunAt = models.QueuedJobQueued, err.Error(), now.Add(delay)
+		log.Printf("job queue: %s job %s attempt %d failed, retrying in %s: %v", job.Kind, job.ID.Hex(), job.Attempts, delay, err)
+	}
+	saved, err := q.DB.UpdateQueuedJob(context.Background(), job, q.Instance)
+	switch {
+	case err != nil:
+		log.Printf("job queue: save %s job %s: %v", job.Kind, job.ID.Hex(), err)
+	case !saved:
+		log.Printf("job queue: %s job %s was taken over by another instance", job.Kind, job.ID.Hex())
+	case job.Status == models.QueuedJobQueued && q.ctx.Err() == nil:
+		// Announced once saved, so the worker told finds it due.
+		time.AfterFunc(time.Until(job.RunAt), func() { q.announce(job.Kind, job.ID) })
+	}
+}
+
+// backoff is the delay after the attempts-th failed attempt.
+func (q *Queue) backoff(attempts int) time.Duration {
+	d := q.Backoff
+	for i := 1; i < attempts && d < queueMaxBackoff; i++ {
+		d *= 2
+	}
+	return min(d, queueMaxBackoff)
+}
@@ -0,0 +1,230 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/kevinaaaquil/books/backend/models"
+	"github.com/kevinaaaquil/books/backend/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// TypeRecommendations recomputes every user's recommendations from the library's reading history.
+const TypeRecommendations = "recommendations"
+
+// MaxRecommendations is how many recommendations are kept per user.
+const MaxRecommendations = 20
+
+// How much each signal adds to a book's score: per finished book by the same author or in the same category, and
+// per unit of similarity to a finished book (see Recommender).
+const (
+	authorWeight   = 0.5
+	categoryWeight = 0.2
+	similarWeight  = 1.0
+)
+
+// Recommendations returns a job that computes every user's recommendations (see Recommender) and stores them,
+// replacing the previous ones.
+func Recommendations(db store.Store) Func {
+	return func(ctx context.Context, p *Progress) error {
+		r, err := LoadRecommender(ctx, db)
+		if err != nil {
+			return err
+		}
+		users, err := db.ListUsers(ctx)
+		if err != nil {
+			return err
+		}
+		p.SetTotal(len(users))
+		now := time.Now()
+		for _, u := range users {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			items := r.For(u.ID)
+			if err := db.SaveRecommendations(ctx, &models.UserRecommendations{UserID: u.ID, Items: items, ComputedAt: now}); err != nil {
+				return err
+			}
+			if len(items) == 0 {
+				p.Step("empty")
+			} else {
+				p.Step("computed")
+			}
+		}
+		return nil
+	}
+}
+
+// Recommender suggests books to a user from what they finished (at least models.FinishedPercent): books by the
+// same authors or in the same categories, and books that readers who finished the same books also finished
+// (item-to-item cosine similarity over who finished what). Books the user has started are never suggested.
+type Recommender struct {
+	books     map[primitive.ObjectID]*models.Book
+	started   map[primitive.ObjectID]map[primitive.ObjectID]bool // user -> books with any progress
+	finished  map[primitive.ObjectID][]primitive.ObjectID        // user -> finished books
+	finishers map[primitive.ObjectID][]primitive.ObjectID        // book -> users who finished it
+}
+
+// LoadRecommender builds a Recommender from every book and all reading progress.
+func LoadRecommender(ctx context.Context, db store.Store) (*Recommender, error) {
+	books, err := db.AllBooks(ctx)
+	if err != nil {
+		return nil, err
+	}
+	progress, err := db.AllReadingProgress(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return NewRecommender(books, progress), nil
+}
+
+// NewRecommender indexes the books and who has read what. Progress in books not among books is ignored.
+func NewRecommender(books []models.Book, progress []models.ReadingProgress) *Recommender {
+	r := &Recommender{
+		books:     make(map[primitive.ObjectID]*models.Book, len(books)),
+		started:   map[primitive.ObjectID]map[primitive.ObjectID]bool{},
+		finished:  map[primitive.ObjectID][]primitive.ObjectID{},
+		finishers: map[primitive.ObjectID][]primitive.ObjectID{},
+	}
+	for i := range books {
+		r.books[books[i].ID] = &books[i]
+	}
+	for _, p := range progress {
+		if r.books[p.BookID] == nil {
+			continue // deleted since
+		}
+		if r.started[p.UserID] == nil {
+			r.started[p.UserID] = map[primitive.ObjectID]bool{}
+		}
+		r.started[p.UserID][p.BookID] = true
+		if p.Percent >= models.FinishedPercent {
+			r.finished[p.UserID] = append(r.finished[p.UserID], p.BookID)
+			r.finishers[p.BookID] = append(r.finishers[p.BookID], p.UserID)
+		}
+	}
+	return r
+}
+
+// candidate accumulates a book's score and remembers the signal that contributed most, to explain it.
+type candidate struct {
+	score, best float64
+	reason      string
+}
+
+func (c *candidate) add(score float64, reason string) {
+	c.score += score
+	if score > c.best {
+		c.best, c.reason = score, reason
+	}
+}
+
+// For returns up to MaxRecommendations books for the user, best first, each with why it was suggested. Users who
+// have finished nothing get none.
+func (r *Recommender) For(userID primitive.ObjectID) []models.Recommendation {
+	finished := r.finished[userID]
+	if len(finished) == 0 {
+		return []models.Recommendation{}
+	}
+	started := r.started[userID]
+	candidates := map[primitive.ObjectID]*candidate{}
+	get := func(id primitive.ObjectID) *candidate {
+		c := candidates[id]
+		if c == nil {
+			c = &candidate{}
+			candidates[id] = c
+		}
+		return c
+	}
+
+	authors, categories := map[string]int{}, map[string]int{}
+	names := map[string]string{} // lowercased -> as first seen
+	for _, id := range finished {
+		b := r.books[id]
+		for _, a := range b.Authors {
+			authors[strings.ToLower(a)]++
+			names[strings.ToLower(a)] = a
+		}
+		for _, c := range bookCategories(b) {
+			categories[strings.ToLower(c)]++
+			names[strings.ToLower(c)] = c
+		}
+	}
+	for id, b := range r.books {
+		if started[id] {
+			continue
+		}
+		for _, a := range b.Authors {
+			if n := authors[strings.ToLower(a)]; n > 0 {
+				get(id).add(authorWeight*float64(n), fmt.Sprintf("Because you finished %s by %s", countBooks(n), names[strings.ToLower(a)]))
+			}
+		}
+		for _, c := range bookCategories(b) {
+			if n := categories[strings.ToLower(c)]; n > 0 {
+				get(id).add(categoryWeight*float64(n), fmt.Sprintf("Because you finished %s in %s", countBooks(n), names[strings.ToLower(c)]))
+			}
+		}
+	}
+
+	for _, x := range finished {
+		// co[y] = how many of x's finishers (other than the user) also finished y.
+		co := map[primitive.ObjectID]int{}
+		for _, v := range r.finishers[x] {
+			if v == userID {
+				continue
+			}
+			for _, y := range r.finished[v] {
+				if y != x && !started[y] {
+					co[y]++
+				}
+			}
+		}
+		for y, n := range co {
+			sim := float64(n) / math.Sqrt(float64(len(r.finishers[x])*len(r.finishers[y])))
+			get(y).add(similarWeight*sim, fmt.Sprintf("Readers who finished %s also finished this", r.books[x].Title))
+		}
+	}
+
+	recs := make([]models.Recommendation, 0, len(candidates))
+	for id, c := range candidates {
+		recs = append(recs, models.Recommendation{BookID: id, Score: math.Round(c.score*1000) / 1000, Reason: c.reason})
+	}
+	sort.Slice(recs, func(i, j int) bool {
+		if recs[i].Score != recs[j].Score {
+			return recs[i].Score > recs[j].Score
+		}
+		return r.books[recs[i].BookID].Title < r.books[recs[j].BookID].Title
+	})
+	if len(recs) > MaxRecommendations {
+		recs = recs[:MaxRecommendations]
+	}
+	return recs
+}
+
+// bookCategories returns the book's categories and its single category, without repeats.
+func bookCategories(b *models.Book) []string {
+	cats := b.Categories
+	if b.Category != "" && !containsFold(cats, b.Category) {
+		cats = append(cats[:len(cats):len(cats)], b.Category)
+	}
+	return cats
+}
+
+func containsFold(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
+
+func countBooks(n int) string {
+	if n == 1 {
+		return "a book"
+	}
+	return fmt.Sprintf("%d books", n)
+}
@@ -0,0 +1,56 @@
+package jobs
+
+import (
+	"context"
+	"time"
+
+	"github.com/kevinaaaquil/books/backend/models"
+	"github.com/kevinaaaquil/books/backend/search"
+	"github.com/kevinaaaquil/books/backend/service"
+	"github.com/kevinaaaquil/books/backend/store"
+	"github.com/kevinaaaquil/books/backend/utils"
+)
+
+// TypeReindexSearch rebuilds the search index from the books and their files.
+const TypeReindexSearch = "reindex-search"
+
+// ReindexSearch returns a job that indexes every book's metadata and EPUB text into a fresh index, then swaps it
+// in; with no storage only metadata is indexed. Searches keep using the old index until the rebuild completes; a failed or cancelled rebuild leaves it as is.
+func ReindexSearch(db store.Store, storage service.ObjectStore, idx *search.Index) Func {
+	return func(ctx context.Context, p *Progress) error {
+		total, err := db.BooksCount(ctx)
+		if err != nil {
+			return err
+		}
+		p.SetTotal(int(total))
+		next := idx.StartRebuild()
+		err = db.ForEachBook(ctx, func(book *models.Book) error {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			text := ""
+			// Archived files would need a restore to be read; those books stay searchable by metadata.
+			if book.Format == "epub" && storage != nil && !service.NeedsRestore(book.StorageClass) {
+				data, err := readObject(ctx, storage, book.S3Key)
+				if err == nil {
+					text, err = utils.EPUBText(data)
+				}
+				if err != nil {
+					// Still searchable by metadata.
+					next.Put(book, "")
+					p.Step("indexed", "unreadable")
+					return nil
+				}
+			}
+			next.Put(book, text)
+			p.Step("indexed")
+			return nil
+		})
+		if err != nil {
+			idx.AbortRebuild(next)
+			return err
+		}
+		idx.FinishRebuild(next, time.Now())
+		return nil
+	}
+}
@@ -0,0 +1,356 @@
+// Package jobs runs long admin jobs (storage verification, backfills) in the background and records their progress in MongoDB,
+// and queues the work users start that runs in the background (see Queue).
+// Jobs and schedulers take leases in the store (see store.LockStore), so the API can run as several instances.
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/kevinaaaquil/books/backend/models"
+	"github.com/kevinaaaquil/books/backend/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ErrAlreadyRunning is returned by Start when a run of the same job type is still in progress, on this instance or another.
+var ErrAlreadyRunning = errors.New("job already running")
+
+// ErrShuttingDown is returned by Start once Shutdown has been called.
+var ErrShuttingDown = errors.New("shutting down")
+
+// ErrNotRunning is returned by Cancel for runs that don't exist or have finished.
+var ErrNotRunning = errors.New("job not running")
+
+// Func is the body of a job. It reports progress through p and should return early when ctx is cancelled,
+// which happens when the API shuts down. Jobs that record a checkpoint (see Progress.Checkpoint) can be resumed.
+type Func func(ctx context.Context, p *Progress) error
+
+// Runner starts jobs in goroutines, allowing one concurrent run per job type across all instances.
+type Runner struct {
+	DB       store.Store
+	Instance string // identifies this instance in job locks; see InstanceID
+
+	mu       sync.Mutex
+	running  map[string]bool
+	runs     map[primitive.ObjectID]*runState // runs in progress on this instance
+	draining bool
+	active   sync.WaitGroup
+	ctx      context.Context // parent of every job's context; cancelled by Shutdown
+	stop     context.CancelFunc
+}
+
+func NewRunner(db store.Store) *Runner {
+	ctx, stop := context.WithCancel(context.Background())
+	return &Runner{DB: db, Instance: InstanceID(), running: make(map[string]bool), runs: make(map[primitive.ObjectID]*runState), ctx: ctx, stop: stop}
+}
+
+// runState lets Cancel stop a run in progress.
+type runState struct {
+	cancel    context.CancelFunc
+	cancelled atomic.Bool
+}
+
+func (s *runState) stop() {
+	s.cancelled.Store(true)
+	s.cancel()
+}
+
+// cancelPollInterval is how often a run checks whether an admin cancelled it through another instance.
+const cancelPollInterval = 5 * time.Second
+
+// localJobs work on this instance's memory (the search index), so each instance runs its own rather than taking
+// the cluster-wide lock.
+var localJobs = map[string]bool{TypeReindexSearch: true}
+
+func jobLock(jobType string) string {
+	return "job:" + jobType
+}
+
+// Start records a new run of jobType and executes fn in the background. The returned run is the initial record.
+func (r *Runner) Start(jobType, startedBy string, fn Func) (*models.JobRun, error) {
+	return r.StartWith(jobType, startedBy, nil, fn)
+}
+
+// StartWith is Start for jobs with options; params are recorded in the run so it can be resumed with them.
+func (r *Runner) StartWith(jobType, startedBy string, params map[string]string, fn Func) (*models.JobRun, error) {
+	return r.start(&models.JobRun{Type: jobType, StartedBy: startedBy, Params: params, Summary: map[string]int{}}, fn)
+}
+
+// Resume continues the latest run of jobType if a shutdown interrupted it, with the job build returns for the
+// run's params. The new run starts from the old one's checkpoint; its counters cover only its own work. It
+// returns nil when there is nothing to resume.
+func (r *Runner) Resume(ctx context.Context, jobType string, build func(params map[string]string) Func) (*models.JobRun, error) {
+	last, err := r.DB.LatestJobRun(ctx, jobType)
+	if err != nil || last == nil || last.Status != models.JobStatusInterrupted {
+		return nil, err
+	}
+	run := &models.JobRun{
+		Type:        jobType,
+		StartedBy:   "resume",
+		Params:      last.Params,
+		Summary:     map[string]int{},
+		Checkpoint:  last.Checkpoint,
+		ResumedFrom: &last.ID,
+	}
+	return r.start(run, build(last.Params))
+}
+
+func (r *Runner) start(run *models.JobRun, fn Func) (*models.JobRun, error) {
+	jobType := run.Type
+	r.mu.Lock()
+	if r.draining {
+		r.mu.Unlock()
+		return nil, ErrShuttingDown
+	}
+	if r.running[jobType] {
+		r.mu.Unlock()
+		return nil, ErrAlreadyRunning
+	}
+	r.running[jobType] = true
+	r.active.Add(1)
+	r.mu.Unlock()
+
+	locked := !localJobs[jobType]
+	if locked {
+		ok, err := r.DB.AcquireLock(context.Background(), jobLock(jobType), r.Instance, time.Now(), lockTTL)
+		if err != nil || !ok {
+			r.finish(jobType)
+			if err == nil {
+				err = ErrAlreadyRunning
+			}
+			return nil, err
+		}
+	}
+	run.Status = models.JobStatusRunning
+	run.Instance = r.Instance
+	run.StartedAt = time.Now()
+	run.Log = []models.JobLogLine{{Time: run.StartedAt, Message: "started by " + run.StartedBy}}
+	id, err := r.DB.InsertJobRun(context.Background(), run)
+	if err != nil {
+		if locked {
+			r.DB.ReleaseLock(context.Background(), jobLock(jobType), r.Instance)
+		}
+		r.finish(jobType)
+		return nil, err
+	}
+	run.ID = id
+	snapshot := *run
+
+	ctx, cancel := context.WithCancel(r.ctx)
+	state := &runState{cancel: cancel}
+	r.mu.Lock()
+	r.runs[id] = state
+	r.mu.Unlock()
+	go func() {
+		defer r.finish(jobType)
+		defer func() {
+			r.mu.Lock()
+			delete(r.runs, id)
+			r.mu.Unlock()
+		}()
+		var held sync.WaitGroup
+		held.Add(1)
+		go func() {
+			defer held.Done()
+			r.watchCancel(ctx, id, state)
+		}()
+		if locked {
+			// A job whose lease passed to another instance is cancelled, so the two don't overlap for long.
+			held.Add(1)
+			go func() {
+				defer held.Done()
+				holdLock(ctx, r.DB, jobLock(jobType), r.Instance, cancel)
+			}()
+		}
+		p := &Progress{db: r.DB, run: run, resumed: run.Checkpoint}
+		err := fn(ctx, p)
+		p.done(err, err != nil && state.cancelled.Load(), err != nil && r.ctx.Err() != nil)
+		cancel()
+		held.Wait() // the lease is released before another run can start here
+	}()
+	return &snapshot, nil
+}
+
+// Cancel stops a running run: at once when it runs on this instance, else within cancelPollInterval on the
+// instance running it. The run ends as cancelled, and is not resumed. ErrNotRunning when it isn't running.
+func (r *Runner) Cancel(ctx context.Context, id primitive.ObjectID) error {
+	ok, err := r.DB.RequestJobCancel(ctx, id)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrNotRunning
+	}
+	r.mu.Lock()
+	state := r.runs[id]
+	r.mu.Unlock()
+	if state != nil {
+		state.stop()
+	}
+	return nil
+}
+
+// watchCancel stops the run when its record says an admin cancelled it, until ctx is done.
+func (r *Runner) watchCancel(ctx context.Context, id primitive.ObjectID, state *runState) {
+	ticker := time.NewTicker(cancelPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if run, err := r.DB.JobRunByID(ctx, id); err == nil && run != nil && run.CancelRequested {
+			state.stop()
+			return
+		}
+	}
+}
+
+func (r *Runner) finish(jobType string) {
+	r.mu.Lock()
+	delete(r.running, jobType)
+	r.mu.Unlock()
+	r.active.Done()
+}
+
+// Shutdown stops new jobs, cancels running ones and waits until they have saved their progress (marked
+// interrupted) or ctx expires.
+func (r *Runner) Shutdown(ctx context.Context) error {
+	r.mu.Lock()
+	r.draining = true
+	n := len(r.running)
+	r.mu.Unlock()
+	r.stop()
+	if n > 0 {
+		log.Printf("jobs: stopping %d running job(s)", n)
+	}
+	drained := make(chan struct{})
+	go func() {
+		r.active.Wait()
+		close(drained)
+	}()
+	select {
+	case <-drained:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("jobs still running at shutdown: %w", ctx.Err())
+	}
+}
+
+// progressFlushInterval bounds how often progress is written to MongoDB while a job runs.
+const progressFlushInterval = 2 * time.Second
+
+// Progress lets a running job report how far it has got. Updates are persisted at most every progressFlushInterval.
+type Progress struct {
+	db        store.Store
+	mu        sync.Mutex
+	run       *models.JobRun
+	resumed   string // checkpoint the run started from
+	lastFlush time.Time
+}
+
+// Resumed returns the checkpoint of the interrupted run this run continues, or "" for a fresh run.
+func (p *Progress) Resumed() string {
+	return p.resumed
+}
+
+// Checkpoint records the last item processed, saved with the next progress update. A resumed run gets it back
+// from Resumed and skips what was done.
+func (p *Progress) Checkpoint(cp string) {
+	p.mu.Lock()
+	p.run.Checkpoint = cp
+	p.mu.Unlock()
+}
+
+// maxJobLogLines is how many of a run's latest log messages are kept.
+const maxJobLogLines = 200
+
+// Logf adds a message to the run's log, saved with the next progress update, and to the server log.
+func (p *Progress) Logf(format string, args ...any) {
+	msg := fmt.Sprintf(format, args...)
+	p.mu.Lock()
+	log.Printf("job %s (%s): %s", p.run.Type, p.run.ID.Hex(), msg)
+	p.run.Log = append(p.run.Log, models.JobLogLine{Time: time.Now(), Message: msg})
+	if n := len(p.run.Log) - maxJobLogLines; n > 0 {
+		p.run.Log = append([]models.JobLogLine(nil), p.run.Log[n:]...)
+	}
+	p.mu.Unlock()
+	p.flush(false)
+}
+
+// SetTotal sets the number of items the job expects to process.
+func (p *Progress) SetTotal(n int) {
+	p.mu.Lock()
+	p.run.Total = n
+	p.mu.Unlock()
+	p.flush(false)
+}
+
+// Step marks one item as processed and increments the named summary counters (e.g. "missing").
+func (p *Progress) Step(counters ...string) {
+	p.mu.Lock()
+	p.run.Processed++
+	for _, c := range counters {
+		p.run.Summary[c]++
+	}
+	p.mu.Unlock()
+	p.flush(false)
+}
+
+func (p *Progress) done(err error, cancelled, interrupted bool) {
+	p.mu.Lock()
+	processed, total := p.run.Processed, p.run.Total
+	p.mu.Unlock()
+	switch {
+	case cancelled:
+		p.Logf("cancelled after %d/%d", processed, total)
+	case interrupted:
+		p.Logf("interrupted after %d/%d", processed, total)
+	case err != nil:
+		p.Logf("failed: %v", err)
+	default:
+		p.Logf("finished after %d/%d", processed, total)
+	}
+	p.mu.Lock()
+	now := time.Now()
+	p.run.FinishedAt = &now
+	switch {
+	case cancelled:
+		p.run.Status = models.JobStatusCancelled
+	case interrupted:
+		p.run.Status = models.JobStatusInterrupted
+	case err != nil:
+		p.run.Status = models.JobStatusFailed
+		p.run.Error = err.Error()
+	default:
+		p.run.Status = models.JobStatusSucceeded
+	}
+	p.mu.Unlock()
+	p.flush(true)
+}
+
+func (p *Progress) flush(force bool) {
+	p.mu.Lock()
+	if !force && time.Since(p.lastFlush) < progressFlushInterval {
+		p.mu.Unlock()
+		return
+	}
+	p.lastFlush = time.Now()
+	run := *p.run
+	run.Summary = make(map[string]int, len(p.run.Summary))
+	for k, v := range p.run.Summary {
+		run.Summary[k] = v
+	}
+	run.Log = slices.Clone(p.run.Log)
+	p.mu.Unlock()
+	if err := p.db.UpdateJobRun(context.Background(), &run); err != nil {
+		log.Printf("job %s (%s): save progress: %v", run.Type, run.ID.Hex(), err)
+	}
+}
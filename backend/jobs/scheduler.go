@@ -0,0 +1,47 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// RunScheduled calls fn at every activation of the cron schedule spec until ctx is cancelled, skipping
+// activations while leader (when not nil) is not this instance. spec must already be validated (see config.Load);
+// an invalid spec logs and returns.
+func RunScheduled(ctx context.Context, name, spec string, leader *Leader, fn func()) {
+	sched, err := cron.ParseStandard(spec)
+	if err != nil {
+		log.Printf("scheduler %s: invalid schedule %q: %v", name, spec, err)
+		return
+	}
+	log.Printf("scheduler %s: running on %q", name, spec)
+	for {
+		next := sched.Next(time.Now())
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+			if leader == nil || leader.IsLeader() {
+				fn()
+			}
+		}
+	}
+}
+
+// NextRun returns the next activation of spec after now, or nil if spec is empty or invalid.
+func NextRun(spec string) *time.Time {
+	if spec == "" {
+		return nil
+	}
+	sched, err := cron.ParseStandard(spec)
+	if err != nil {
+		return nil
+	}
+	next := sched.Next(time.Now())
+	return &next
+}
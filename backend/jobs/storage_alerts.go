@@ -0,0 +1,164 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/kevinaaaquil/books/backend/models"
+	"github.com/kevinaaaquil/books/backend/notify"
+	"github.com/kevinaaaquil/books/backend/store"
+)
+
+// TypeStorageAlerts checks stored and downloaded bytes against the soft quotas.
+const TypeStorageAlerts = "storage-alerts"
+
+// trendMonths is how many months StorageTrends covers, including the current one.
+const trendMonths = 12
+
+// StorageQuotas are soft limits: going over one alerts admins but blocks nothing.
+type StorageQuotas struct {
+	StorageBytes         int64 // bytes stored; 0 = none
+	MonthlyDownloadBytes int64 // bytes handed out as presigned links per calendar month (UTC); 0 = none
+	AlertPercent         int   // admins are also warned at this percentage of a quota; 0 = only when it is exceeded
+}
+
+// StorageTrends returns stored bytes and this month's presigned download bytes against the quotas, with the
+// history of the last 12 months.
+func StorageTrends(ctx context.Context, db store.Store, q StorageQuotas, now time.Time) (*models.StorageTrends, error) {
+	usage, err := db.StorageUsage(ctx)
+	if err != nil {
+		return nil, err
+	}
+	now = now.UTC()
+	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	first := monthStart.AddDate(0, 1-trendMonths, 0)
+	downloads, err := db.PresignedDownloadsByMonth(ctx, first)
+	if err != nil {
+		return nil, err
+	}
+	s, err := db.Settings(ctx)
+	if err != nil {
+		return nil, err
+	}
+	t := &models.StorageTrends{
+		StoredBytes:        usage.TotalBytes,
+		StorageQuotaBytes:  q.StorageBytes,
+		StoragePercent:     percentOf(usage.TotalBytes, q.StorageBytes),
+		DownloadQuotaBytes: q.MonthlyDownloadBytes,
+		AlertPercent:       q.AlertPercent,
+		Alerts:             s.StorageAlerts,
+		Months:             make([]models.StorageMonth, trendMonths),
+	}
+	for i := range t.Months {
+		t.Months[i].Month = first.AddDate(0, i, 0).Format("2006-01")
+	}
+	index := func(month string) (int, bool) {
+		for i := range t.Months {
+			if t.Months[i].Month == month {
+				return i, true
+			}
+		}
+		return 0, false
+	}
+	// Growth runs from the first book; bytes added before the window are where it starts.
+	var stored int64
+	for _, g := range usage.Growth {
+		if i, ok := index(g.Month); ok {
+			t.Months[i].AddedBytes = g.AddedBytes
+		} else if g.Month < t.Months[0].Month {
+			stored = g.CumulativeBytes
+		}
+	}
+	for i := range t.Months {
+		stored += t.Months[i].AddedBytes
+		t.Months[i].StoredBytes = stored
+	}
+	for _, d := range downloads {
+		if i, ok := index(d.Month); ok {
+			t.Months[i].DownloadBytes, t.Months[i].Downloads = d.Bytes, d.Links
+		}
+	}
+	t.MonthDownloadBytes = t.Months[trendMonths-1].DownloadBytes
+	t.DownloadPercent = percentOf(t.MonthDownloadBytes, q.MonthlyDownloadBytes)
+	elapsed := now.Sub(monthStart)
+	if monthLen := monthStart.AddDate(0, 1, 0).Sub(monthStart); elapsed > 0 {
+		t.ProjectedMonthBytes = int64(float64(t.MonthDownloadBytes) * float64(monthLen) / float64(elapsed))
+	}
+	return t, nil
+}
+
+func percentOf(n, quota int64) float64 {
+	if quota <= 0 {
+		return 0
+	}
+	return float64(n) * 100 / float64(quota)
+}
+
+// level is the alert level usage has reached: 100 over quota, AlertPercent at or above it, else 0.
+func (q StorageQuotas) level(used, quota int64) int {
+	switch {
+	case quota <= 0:
+		return 0
+	case used >= quota:
+		return 100
+	case q.AlertPercent > 0 && used*100 >= quota*int64(q.AlertPercent):
+		return q.AlertPercent
+	}
+	return 0
+}
+
+// StorageAlerts returns a job that notifies admins when stored bytes or this month's presigned download bytes
+// reach the alert percentage of their quota and again when they exceed it. Each alert is sent once (see
+// models.StorageAlerts).
+func StorageAlerts(db store.Store, notifier *notify.Service, q StorageQuotas) Func {
+	return func(ctx context.Context, p *Progress) error {
+		t, err := StorageTrends(ctx, db, q, time.Now())
+		if err != nil {
+			return err
+		}
+		s, err := db.Settings(ctx)
+		if err != nil {
+			return err
+		}
+		alerts := s.StorageAlerts
+		month := t.Months[trendMonths-1].Month
+		if alerts.DownloadMonth != month {
+			alerts.DownloadMonth, alerts.DownloadLevel = month, 0
+		}
+		storage := q.level(t.StoredBytes, q.StorageBytes)
+		if storage > alerts.StorageLevel {
+			if err := notifyQuota(ctx, notifier, "Storage", storage, t.StoredBytes, q.StorageBytes); err != nil {
+				return err
+			}
+			p.Logf("storage at %.0f%% of its quota; admins notified", t.StoragePercent)
+		}
+		alerts.StorageLevel = storage
+		download := q.level(t.MonthDownloadBytes, q.MonthlyDownloadBytes)
+		if download > alerts.DownloadLevel {
+			if err := notifyQuota(ctx, notifier, "Downloads this month", download, t.MonthDownloadBytes, q.MonthlyDownloadBytes); err != nil {
+				return err
+			}
+			p.Logf("downloads at %.0f%% of the monthly quota; admins notified", t.DownloadPercent)
+			alerts.DownloadLevel = download
+		}
+		if alerts == s.StorageAlerts {
+			return nil
+		}
+		s.StorageAlerts = alerts
+		return db.SaveSettings(ctx, s)
+	}
+}
+
+func notifyQuota(ctx context.Context, notifier *notify.Service, what string, level int, used, quota int64) error {
+	title := fmt.Sprintf("%s at %d%% of the soft quota", what, level)
+	if level >= 100 {
+		title = what + " over the soft quota"
+	}
+	body := fmt.Sprintf("%s of %s used.", gigabytes(used), gigabytes(quota))
+	return notifier.NotifyAdmins(ctx, models.NotificationStorageQuota, title, body, "/api/admin/storage/usage")
+}
+
+func gigabytes(n int64) string {
+	return fmt.Sprintf("%.2f GB", float64(n)/(1<<30))
+}
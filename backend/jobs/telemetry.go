@@ -0,0 +1,147 @@
+package jobs
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/kevinaaaquil/books/backend/models"
+	"github.com/kevinaaaquil/books/backend/store"
+)
+
+// TypeTelemetry reports aggregate usage counts to TELEMETRY_URL, for instances that opted in.
+const TypeTelemetry = "telemetry"
+
+// telemetryPeriod is how far back a report counts sends and job runs.
+const telemetryPeriod = 7 * 24 * time.Hour
+
+// TelemetryOptions configures the telemetry job.
+type TelemetryOptions struct {
+	URL      string // where reports are POSTed; "" disables telemetry
+	Version  string
+	Features []string // the optional features configured
+	Client   *http.Client
+}
+
+// TelemetryIDPending is the InstanceID of reports built before the first one is sent, which gives the instance
+// its ID.
+const TelemetryIDPending = "pending"
+
+// TelemetryReport counts what the report covers as of now. It only reads, so admins can preview the report; until
+// the first one is sent its InstanceID is TelemetryIDPending.
+func TelemetryReport(ctx context.Context, db store.Store, opts TelemetryOptions, now time.Time) (*models.TelemetryReport, error) {
+	s, err := db.Settings(ctx)
+	if err != nil {
+		return nil, err
+	}
+	instanceID := s.TelemetryID
+	if instanceID == "" {
+		instanceID = TelemetryIDPending
+	}
+	usage, err := db.StorageUsage(ctx)
+	if err != nil {
+		return nil, err
+	}
+	users, err := db.ListUsers(ctx)
+	if err != nil {
+		return nil, err
+	}
+	since := now.Add(-telemetryPeriod)
+	sends, err := db.EmailLogsSince(ctx, since)
+	if err != nil {
+		return nil, err
+	}
+	runs, err := db.ListJobRuns(ctx, models.JobRunFilter{Since: &since})
+	if err != nil {
+		return nil, err
+	}
+	r := &models.TelemetryReport{
+		InstanceID: instanceID,
+		Version:    opts.Version,
+		GoVersion:  runtime.Version(),
+		OS:         runtime.GOOS,
+		Arch:       runtime.GOARCH,
+		Books:      usage.TotalBooks,
+		Formats:    map[string]int{},
+		StoredGB:   math.Round(float64(usage.TotalBytes)/(1<<30)*10) / 10,
+		Users:      map[string]int{},
+		Features:   opts.Features,
+		Sends:      len(sends),
+		JobRuns:    map[string]int{},
+		ReportedAt: now.UTC(),
+	}
+	if r.Features == nil {
+		r.Features = []string{}
+	}
+	for _, f := range usage.ByFormat {
+		r.Formats[f.Key] = f.Books
+	}
+	for _, u := range users {
+		r.Users[u.Role]++
+	}
+	for _, run := range runs {
+		r.JobRuns[run.Type]++
+	}
+	return r, nil
+}
+
+// Telemetry returns a job that POSTs a TelemetryReport to opts.URL as JSON. The first run makes the instance's
+// random ID and saves it in the settings.
+func Telemetry(db store.Store, opts TelemetryOptions) Func {
+	return func(ctx context.Context, p *Progress) error {
+		if err := ensureTelemetryID(ctx, db); err != nil {
+			return err
+		}
+		report, err := TelemetryReport(ctx, db, opts, time.Now())
+		if err != nil {
+			return err
+		}
+		body, err := json.Marshal(report)
+		if err != nil {
+			return err
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, opts.URL, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		client := opts.Client
+		if client == nil {
+			client = &http.Client{Timeout: 30 * time.Second}
+		}
+		res, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer res.Body.Close()
+		if res.StatusCode/100 != 2 {
+			msg, _ := io.ReadAll(io.LimitReader(res.Body, 512))
+			return fmt.Errorf("telemetry: %s %s", res.Status, strings.TrimSpace(string(msg)))
+		}
+		p.Logf("reported %d books", report.Books)
+		return nil
+	}
+}
+
+// ensureTelemetryID gives the instance its telemetry ID if it has none yet.
+func ensureTelemetryID(ctx context.Context, db store.Store) error {
+	s, err := db.Settings(ctx)
+	if err != nil || s.TelemetryID != "" {
+		return err
+	}
+	var id [16]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		return err
+	}
+	s.TelemetryID = hex.EncodeToString(id[:])
+	return db.SaveSettings(ctx, s)
+}
@@ -0,0 +1,83 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/kevinaaaquil/books/backend/models"
+	"github.com/kevinaaaquil/books/backend/service"
+	"github.com/kevinaaaquil/books/backend/store"
+)
+
+// TypeVerifyStorage checks every book's S3 objects exist and look intact.
+const TypeVerifyStorage = "verify-storage"
+
+// VerifyStorage returns a job that HEADs each book's s3Key and coverS3Key and records the outcome in Book.FileStatus.
+// Books without a recorded size get it filled in from the object.
+// Books whose objects cannot be checked (S3 errors other than not-found) keep their previous status.
+// An interrupted run resumes after the last book it checked.
+func VerifyStorage(db store.Store, storage service.ObjectStore) Func {
+	return func(ctx context.Context, p *Progress) error {
+		total, err := db.BooksCount(ctx)
+		if err != nil {
+			return err
+		}
+		p.SetTotal(int(total))
+		resumed := p.Resumed()
+		return db.ForEachBook(ctx, func(book *models.Book) error {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if bookDone(book, resumed) {
+				p.Step() // done before the interruption
+				return nil
+			}
+			status, size, err := verifyBookObjects(ctx, storage, book)
+			if err != nil {
+				if ctx.Err() != nil {
+					return ctx.Err() // check it again after a restart
+				}
+				p.Checkpoint(bookCheckpoint(book))
+				p.Step("errors")
+				return nil
+			}
+			if book.SizeBytes == 0 && size > 0 {
+				if err := db.SetBookSize(ctx, book.ID, size); err != nil {
+					return err
+				}
+			}
+			if err := db.UpdateBookFileStatus(ctx, book.ID, status, time.Now()); err != nil {
+				return err
+			}
+			p.Checkpoint(bookCheckpoint(book))
+			p.Step(status)
+			return nil
+		})
+	}
+}
+
+// verifyBookObjects returns the book's file status and the stored object's size (0 when missing).
+// A stored object whose size or S3-recorded SHA-256 differs from the book's is reported as corrupted.
+func verifyBookObjects(ctx context.Context, storage service.ObjectStore, book *models.Book) (string, int64, error) {
+	info, err := storage.HeadObject(ctx, book.S3Key)
+	if errors.Is(err, service.ErrObjectNotFound) {
+		return models.FileStatusMissing, 0, nil
+	}
+	if err != nil {
+		return "", 0, err
+	}
+	if info.Size == 0 || (book.SizeBytes > 0 && info.Size != book.SizeBytes) || (info.SHA256 != "" && book.SHA256 != "" && info.SHA256 != book.SHA256) {
+		return models.FileStatusCorrupted, info.Size, nil
+	}
+	if book.CoverS3Key != "" {
+		_, err := storage.HeadObject(ctx, book.CoverS3Key)
+		if errors.Is(err, service.ErrObjectNotFound) {
+			return models.FileStatusCoverMissing, info.Size, nil
+		}
+		if err != nil {
+			return "", 0, err
+		}
+	}
+	return models.FileStatusOK, info.Size, nil
+}
@@ -0,0 +1,122 @@
+// Package logging writes the server's request log to its configured outputs: stdout, files, syslog and OTLP
+// collectors.
+package logging
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+// Entry is one served request. URLs are redacted (see RedactURL) before they reach a sink; headers are never
+// logged, so Authorization and cookies stay out of the logs.
+type Entry struct {
+	Time       time.Time
+	Method     string
+	URL        string // scheme://host/path?query
+	Proto      string
+	RemoteAddr string
+	Route      string // the router's pattern, e.g. /api/books/{id}/cover; "" when nothing matched
+	Status     int
+	Bytes      int
+	Duration   time.Duration
+}
+
+// String formats the entry as a log line: "GET http://host/path HTTP/1.1" from 127.0.0.1:1234 - 200 15B in 42µs.
+func (e Entry) String() string {
+	return fmt.Sprintf("%q from %s - %d %dB in %s", e.Method+" "+e.URL+" "+e.Proto, e.RemoteAddr, e.Status, e.Bytes, e.Duration)
+}
+
+// Sink receives request log entries. Log must not block for long; sinks that send over the network buffer.
+type Sink interface {
+	Log(Entry)
+	Close() error // flushes buffered entries
+}
+
+// Multi logs to every sink.
+type Multi []Sink
+
+func (m Multi) Log(e Entry) {
+	for _, s := range m {
+		s.Log(e)
+	}
+}
+
+func (m Multi) Close() error {
+	var errs []error
+	for _, s := range m {
+		errs = append(errs, s.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// Writer logs entries as lines, prefixed with the date and time like the standard logger.
+type Writer struct {
+	out    *log.Logger
+	closer io.Closer // closed with the sink; nil for stdout and stderr
+}
+
+// NewWriter returns a sink writing lines to w. Closing the sink leaves w open.
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{out: log.New(w, "", log.LstdFlags)}
+}
+
+func (w *Writer) Log(e Entry) { w.out.Print(e.String()) }
+
+func (w *Writer) Close() error {
+	if w.closer == nil {
+		return nil
+	}
+	return w.closer.Close()
+}
+
+// Open returns the sinks of LOG_OUTPUTS entries: stdout, stderr, file:/path (appended to), syslog (the local
+// daemon), syslog:udp://host:514 or syslog:tcp://host:514, and otlp:http://collector:4318 (OTLP/HTTP logs).
+// An empty list logs to stdout.
+func Open(outputs []string) (Sink, error) {
+	var sinks Multi
+	fail := func(err error) (Sink, error) {
+		sinks.Close()
+		return nil, err
+	}
+	for _, out := range outputs {
+		kind, arg, _ := strings.Cut(out, ":")
+		switch {
+		case out == "stdout":
+			sinks = append(sinks, NewWriter(os.Stdout))
+		case out == "stderr":
+			sinks = append(sinks, NewWriter(os.Stderr))
+		case kind == "file" && arg != "":
+			f, err := os.OpenFile(arg, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o640)
+			if err != nil {
+				return fail(err)
+			}
+			sinks = append(sinks, &Writer{out: log.New(f, "", log.LstdFlags), closer: f})
+		case kind == "syslog":
+			s, err := openSyslog(arg)
+			if err != nil {
+				return fail(fmt.Errorf("%s: %w", out, err))
+			}
+			sinks = append(sinks, s)
+		case kind == "otlp" && (strings.HasPrefix(arg, "http://") || strings.HasPrefix(arg, "https://")):
+			o, err := NewOTLP(arg)
+			if err != nil {
+				return fail(fmt.Errorf("%s: %w", out, err))
+			}
+			sinks = append(sinks, o)
+		default:
+			return fail(fmt.Errorf("unknown log output %q", out))
+		}
+	}
+	if len(sinks) == 0 {
+		sinks = append(sinks, NewWriter(os.Stdout))
+	}
+	if len(sinks) == 1 {
+		return sinks[0], nil
+	}
+	return sinks, nil
+}
@@ -0,0 +1,96 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/contrib/bridges/otelslog"
+	"go.opentelemetry.io/otel/exporters/otlp/otlplog/otlploghttp"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	"go.opentelemetry.io/otel/sdk/resource"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+const (
+	otlpBatch    = 256         // entries per request
+	otlpInterval = time.Second // longest an entry waits for its batch
+	otlpBuffer   = 4096        // entries waiting to be sent; more are dropped
+)
+
+// OTLP sends entries to an OpenTelemetry collector as OTLP/HTTP log records through the OpenTelemetry SDK, in
+// batches, with the request's method, URL, route, status, size, duration and client address as attributes. When
+// the collector falls behind, entries are dropped rather than slowing requests down.
+type OTLP struct {
+	provider *sdklog.LoggerProvider
+	logger   *slog.Logger
+
+	mu     sync.RWMutex // held to log, and to close
+	closed bool
+}
+
+// NewOTLP starts a sink posting to endpoint's /v1/logs (endpoint is the collector's base URL, e.g.
+// http://collector:4318).
+func NewOTLP(endpoint string) (*OTLP, error) {
+	exporter, err := otlploghttp.New(context.Background(),
+		otlploghttp.WithEndpointURL(strings.TrimSuffix(endpoint, "/")+"/v1/logs"),
+		otlploghttp.WithTimeout(10*time.Second),
+	)
+	if err != nil {
+		return nil, err
+	}
+	provider := sdklog.NewLoggerProvider(
+		sdklog.WithResource(resource.NewSchemaless(semconv.ServiceName("books"))),
+		sdklog.WithProcessor(sdklog.NewBatchProcessor(exporter,
+			sdklog.WithExportMaxBatchSize(otlpBatch),
+			sdklog.WithExportInterval(otlpInterval),
+			sdklog.WithMaxQueueSize(otlpBuffer),
+		)),
+	)
+	return &OTLP{provider: provider, logger: otelslog.NewLogger("books/http", otelslog.WithLoggerProvider(provider))}, nil
+}
+
+// Log queues the entry; entries logged once the sink is closing are dropped.
+func (o *OTLP) Log(e Entry) {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	if o.closed {
+		return
+	}
+	level := slog.LevelInfo
+	switch {
+	case e.Status >= 500:
+		level = slog.LevelError
+	case e.Status >= 400:
+		level = slog.LevelWarn
+	}
+	r := slog.NewRecord(e.Time, level, e.String(), 0)
+	r.AddAttrs(
+		slog.String("http.request.method", e.Method),
+		slog.String("url.full", e.URL),
+		slog.String("network.protocol.version", strings.TrimPrefix(e.Proto, "HTTP/")),
+		slog.String("client.address", e.RemoteAddr),
+		slog.Int("http.response.status_code", e.Status),
+		slog.Int("http.response.body.size", e.Bytes),
+		slog.Int64("http.server.request.duration_ms", e.Duration.Milliseconds()),
+	)
+	if e.Route != "" {
+		r.AddAttrs(slog.String("http.route", e.Route))
+	}
+	o.logger.Handler().Handle(context.Background(), r)
+}
+
+// Close sends the entries still queued. Only the first call counts.
+func (o *OTLP) Close() error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.closed {
+		return nil
+	}
+	o.closed = true
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	return o.provider.Shutdown(ctx)
+}
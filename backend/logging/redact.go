@@ -0,0 +1,41 @@
+package logging
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// secretParams are query parameters whose values are credentials: signed-URL signatures, OAuth codes and states,
+// tokens and keys.
+var secretParams = map[string]bool{
+	"signature": true, "sig": true, "code": true, "state": true, "token": true, "access_token": true,
+	"refresh_token": true, "id_token": true, "key": true, "api_key": true, "apikey": true, "password": true, "secret": true,
+}
+
+var (
+	emailPattern = regexp.MustCompile(`[A-Za-z0-9._%+\-]+(?:@|%40)[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)
+	jwtPattern   = regexp.MustCompile(`eyJ[A-Za-z0-9_\-]+\.[A-Za-z0-9_\-]+\.[A-Za-z0-9_\-]*`)
+	// API keys (see handlers.apiKeyPrefix) appear in the path of feed URLs.
+	apiKeyPattern = regexp.MustCompile(`bk_[0-9a-f]{16,}`)
+)
+
+// RedactURL hides the personal data and credentials a request URL can carry: the values of secret query
+// parameters, and email addresses, JWTs and API keys anywhere in the path or query.
+func RedactURL(u *url.URL) string {
+	c := *u
+	c.User = nil
+	if c.RawQuery != "" {
+		q := c.Query()
+		for k := range q {
+			if secretParams[strings.ToLower(k)] {
+				q[k] = []string{"REDACTED"}
+			}
+		}
+		c.RawQuery = q.Encode()
+	}
+	s := c.String()
+	s = jwtPattern.ReplaceAllString(s, "REDACTED")
+	s = apiKeyPattern.ReplaceAllString(s, "REDACTED")
+	return emailPattern.ReplaceAllString(s, "REDACTED")
+}
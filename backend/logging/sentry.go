@@ -0,0 +1,122 @@
+package logging
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+)
+
+// Event is an error worth an operator's attention: a panic while serving a request, or a 5xx answer.
+type Event struct {
+	Time      time.Time
+	Level     string // "fatal" for panics, "error" for 5xx answers
+	Type      string // the panic value's type; "" for 5xx answers
+	Message   string
+	Stack     []runtime.Frame // innermost first; panics only
+	Request   Entry           // URL redacted
+	UserAgent string
+}
+
+// Reporter sends events to an error tracker.
+type Reporter interface {
+	Report(Event)
+	Close() error // sends the events still queued
+}
+
+// sentryFlush bounds how long Close waits for the events still queued.
+const sentryFlush = 10 * time.Second
+
+// Sentry reports events to Sentry, or a server with Sentry's API such as GlitchTip, through sentry-go. Events are
+// sent in the background, so a burst of failures doesn't slow requests down.
+type Sentry struct {
+	hub *sentry.Hub
+
+	mu     sync.RWMutex // held to report, and to close
+	closed bool
+}
+
+// NewSentry starts a reporter for dsn (https://key@host/project-id), tagging events with release and environment
+// (either may be empty). client nil = sentry-go's default client.
+func NewSentry(dsn, release, environment string, client *http.Client) (*Sentry, error) {
+	if dsn == "" {
+		return nil, errors.New("want a DSN such as https://key@sentry.example.com/1")
+	}
+	c, err := sentry.NewClient(sentry.ClientOptions{
+		Dsn:         dsn,
+		Release:     release,
+		Environment: environment,
+		HTTPClient:  client,
+	})
+	if err != nil {
+		return nil, errors.New("want a DSN such as https://key@sentry.example.com/1") // the DSN holds the key
+	}
+	return &Sentry{hub: sentry.NewHub(c, sentry.NewScope())}, nil
+}
+
+// Report queues e; events reported once the reporter is closing are dropped.
+func (s *Sentry) Report(e Event) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.closed {
+		return
+	}
+	s.hub.CaptureEvent(sentryEvent(e))
+}
+
+// Close sends the events still queued, waiting up to sentryFlush. Only the first call counts.
+func (s *Sentry) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	if !s.hub.Flush(sentryFlush) {
+		return errors.New("error report: events still queued after " + sentryFlush.String())
+	}
+	return nil
+}
+
+// sentryEvent is e as a Sentry event.
+func sentryEvent(e Event) *sentry.Event {
+	ev := sentry.NewEvent()
+	ev.Timestamp = e.Time
+	ev.Level = sentry.Level(e.Level)
+	ev.Logger = "books.http"
+	ev.Transaction = e.Request.Method + " " + e.Request.Route
+	ev.Tags = map[string]string{"status_code": fmt.Sprint(e.Request.Status), "route": e.Request.Route}
+	if e.Request.Method != "" {
+		base, query, _ := strings.Cut(e.Request.URL, "?")
+		ev.Request = &sentry.Request{
+			Method:      e.Request.Method,
+			URL:         base,
+			QueryString: query,
+			Headers:     map[string]string{"User-Agent": e.UserAgent},
+			Env:         map[string]string{"REMOTE_ADDR": e.Request.RemoteAddr},
+		}
+	}
+	if e.Type == "" {
+		ev.Message = e.Message
+		return ev
+	}
+	// Sentry lists frames outermost first.
+	frames := make([]sentry.Frame, 0, len(e.Stack))
+	for i := len(e.Stack) - 1; i >= 0; i-- {
+		f := e.Stack[i]
+		frames = append(frames, sentry.Frame{
+			Function: f.Function,
+			AbsPath:  f.File,
+			Filename: f.File,
+			Lineno:   f.Line,
+			InApp:    strings.Contains(f.Function, "kevinaaaquil/books"),
+		})
+	}
+	ev.Exception = []sentry.Exception{{Type: e.Type, Value: e.Message, Stacktrace: &sentry.Stacktrace{Frames: frames}}}
+	return ev
+}
@@ -0,0 +1,42 @@
+//go:build !windows && !plan9
+
+package logging
+
+import (
+	"fmt"
+	"log/syslog"
+	"net/url"
+)
+
+// openSyslog connects to the local syslog daemon, or to udp://host:port or tcp://host:port. Entries are sent at
+// LOG_INFO, or LOG_WARNING and LOG_ERR for 4xx and 5xx answers, with the LOG_DAEMON facility.
+func openSyslog(addr string) (Sink, error) {
+	var network, raddr string
+	if addr != "" {
+		u, err := url.Parse(addr)
+		if err != nil || (u.Scheme != "udp" && u.Scheme != "tcp") || u.Host == "" {
+			return nil, fmt.Errorf("want udp://host:port or tcp://host:port")
+		}
+		network, raddr = u.Scheme, u.Host
+	}
+	w, err := syslog.Dial(network, raddr, syslog.LOG_INFO|syslog.LOG_DAEMON, "books")
+	if err != nil {
+		return nil, err
+	}
+	return syslogSink{w}, nil
+}
+
+type syslogSink struct{ w *syslog.Writer }
+
+func (s syslogSink) Log(e Entry) {
+	switch {
+	case e.Status >= 500:
+		s.w.Err(e.String())
+	case e.Status >= 400:
+		s.w.Warning(e.String())
+	default:
+		s.w.Info(e.String())
+	}
+}
+
+func (s syslogSink) Close() error { return s.w.Close() }
@@ -0,0 +1,9 @@
+//go:build windows || plan9
+
+package logging
+
+import "errors"
+
+func openSyslog(string) (Sink, error) {
+	return nil, errors.New("syslog is not available on this system")
+}
@@ -2,217 +2,417 @@ package main
 
 import (
 	"context"
+	"flag"
+	"fmt"
+	"io/fs"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"strings"
 	"syscall"
-	"time"
 
-	"github.com/go-chi/chi/v5"
-	chimw "github.com/go-chi/chi/v5/middleware"
 	"github.com/joho/godotenv"
+	"github.com/kevinaaaquil/books/backend/app"
 	"github.com/kevinaaaquil/books/backend/config"
-	"github.com/kevinaaaquil/books/backend/handlers"
-	"github.com/kevinaaaquil/books/backend/middleware"
+	"github.com/kevinaaaquil/books/backend/demo"
+	"github.com/kevinaaaquil/books/backend/events"
+	"github.com/kevinaaaquil/books/backend/jobs"
+	"github.com/kevinaaaquil/books/backend/logging"
 	"github.com/kevinaaaquil/books/backend/models"
 	"github.com/kevinaaaquil/books/backend/service"
 	"github.com/kevinaaaquil/books/backend/store"
-	"golang.org/x/crypto/bcrypt"
+	"github.com/kevinaaaquil/books/backend/store/docstore"
+	"github.com/kevinaaaquil/books/backend/tracing"
+	"github.com/kevinaaaquil/books/backend/web"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// runModeWorker is the argument that runs the binary as a dedicated job worker (see app.RunWorker).
+const runModeWorker = "worker"
+
 func main() {
+	demoMode := flag.Bool("demo", false, "run with an in-memory database, temporary file storage and sample books; no MongoDB, S3 or .env needed")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [flags] [worker]\n\nworker runs only the job queue's workers and the admin jobs to resume, serving nothing.\n\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	mode := flag.Arg(0)
+	if flag.NArg() > 1 || (mode != "" && mode != runModeWorker) {
+		flag.Usage()
+		os.Exit(2)
+	}
+
 	_ = godotenv.Load()
-	config.ValidateEnv()
+	if !*demoMode {
+		config.ValidateEnv()
+	}
 
 	cfg, err := config.Load()
 	if err != nil {
 		log.Fatal("config:", err)
 	}
 
-	ctx := context.Background()
-	db, err := store.NewMongoDB(ctx, cfg.MongoURI, cfg.DBName)
-	if err != nil {
-		log.Fatal("mongodb:", err)
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	var db store.Store
+	if *demoMode {
+		// Everything is thrown away on exit: files go to a temp dir, served like DATA_DIR storage.
+		dir, err := os.MkdirTemp("", "books-demo-")
+		if err != nil {
+			log.Fatal("demo:", err)
+		}
+		defer os.RemoveAll(dir)
+		cfg.DatabaseURL, cfg.S3Bucket, cfg.DataDir, cfg.BackupSchedule = "", "", dir, ""
+		cfg.StorageBackend, cfg.StorageDir = config.StorageBackendLocal, filepath.Join(dir, "files")
+		db = docstore.NewMemory()
+	} else {
+		db, err = openStore(ctx, cfg)
+		if err != nil {
+			log.Fatal("database:", err)
+		}
 	}
 	defer func() {
 		if err := db.Disconnect(context.Background()); err != nil {
-			log.Println("mongodb disconnect:", err)
+			log.Println("database disconnect:", err)
 		}
 	}()
 
-	if err := db.EnsureEmailConfigIndex(ctx); err != nil {
-		log.Fatal("email_config index:", err)
+	signer := service.NewURLSigner(cfg.JWTSecret)
+	objects, localStorage, err := openStorage(cfg, signer)
+	if err != nil {
+		log.Fatal("storage:", err)
+	}
+	migrationTarget, err := openMigrationTarget(ctx, cfg.MigrateTo, signer)
+	if err != nil {
+		log.Fatal("migration target:", err)
+	}
+	if len(cfg.EmailConfigEncryptionKey) != 32 {
+		log.Println("warning: Kindle app-specific password will be stored in plaintext (set KINDLE_CONFIG_ENCRYPTION_KEY with: openssl rand -base64 32)")
 	}
 
-	// If users collection is empty, create admin user from env (once); after that only MongoDB is used for login.
-	if err := seedBootstrapUser(ctx, db, cfg.AuthEmail, cfg.AuthPass); err != nil {
-		log.Fatal("bootstrap user:", err)
+	var smtpPool *service.SMTPPool
+	if cfg.SMTPPoolIdle > 0 {
+		smtpPool = service.NewSMTPPool(cfg.SMTPPoolSize, cfg.SMTPPoolIdle)
+		defer smtpPool.Close()
+	}
+	mailer, err := newMailer(ctx, cfg, smtpPool)
+	if err != nil {
+		log.Fatal("mailer:", err)
 	}
-	// Ensure at least one guest user exists for "View as guest" on login page.
-	if err := seedGuestUser(ctx, db); err != nil {
-		log.Fatal("seed guest user:", err)
+	systemMailer, err := newSystemMailer(ctx, cfg, smtpPool)
+	if err != nil {
+		log.Fatal("system mailer:", err)
 	}
 
-	var s3Service *service.S3Service
-	if cfg.S3Bucket != "" {
-		s3Service, err = service.NewS3Service(ctx, cfg.S3Bucket, cfg.S3Region, cfg.S3AccessKeyID, cfg.S3SecretKey)
+	requestLog, err := logging.Open(cfg.LogOutputs)
+	if err != nil {
+		log.Fatal("LOG_OUTPUTS:", err)
+	}
+	defer requestLog.Close()
+	eventSink, err := events.Open(cfg.EventSinks, events.Options{WebhookSecret: cfg.EventWebhookSecret})
+	if err != nil {
+		log.Fatal("EVENT_SINKS:", err)
+	}
+	defer eventSink.Close()
+	jobBroker, err := jobs.OpenBroker(cfg.JobQueueBroker)
+	if err != nil {
+		log.Fatal("JOB_QUEUE_BROKER:", err)
+	}
+	if jobBroker != nil {
+		defer jobBroker.Close()
+	}
+	var reporter logging.Reporter
+	if cfg.ErrorReportDSN != "" {
+		sentry, err := logging.NewSentry(cfg.ErrorReportDSN, cfg.ErrorReportRelease, cfg.ErrorReportEnvironment, nil)
 		if err != nil {
-			log.Fatal("s3:", err)
+			log.Fatal("ERROR_REPORT_DSN:", err)
 		}
-	} else {
-		log.Println("warning: AWS_S3_BUCKET not set; uploads will fail")
-	}
-	if len(cfg.EmailConfigEncryptionKey) != 32 {
-		log.Println("warning: Kindle app-specific password will be stored in plaintext (set KINDLE_CONFIG_ENCRYPTION_KEY with: openssl rand -base64 32)")
+		defer sentry.Close()
+		reporter = sentry
 	}
-
-	authHandler := &handlers.AuthHandler{DB: db, JWTSecret: cfg.JWTSecret}
-	uploadHandler := &handlers.UploadHandler{
-		DB:       db,
-		S3:       s3Service,
-		MaxBytes: cfg.MaxUploadMB * 1024 * 1024,
+	var tracer trace.TracerProvider
+	if cfg.TracesEndpoint != "" {
+		tp, err := tracing.New(cfg.TracesEndpoint, cfg.TracesHeaders, cfg.TracesServiceName, cfg.TracesSample)
+		if err != nil {
+			log.Fatal("OTEL_EXPORTER_OTLP_ENDPOINT:", err)
+		}
+		defer tp.Shutdown(context.Background())
+		tracing.Register(tp)
+		tracer = tp
 	}
-	booksHandler := &handlers.BooksHandler{DB: db, S3: s3Service, EncKey: cfg.EmailConfigEncryptionKey}
-	usersHandler := &handlers.UsersHandler{DB: db}
-	emailConfigHandler := &handlers.EmailConfigHandler{DB: db, EncKey: cfg.EmailConfigEncryptionKey}
 
-	r := chi.NewRouter()
-	r.Use(middleware.AllowAll())
-	r.Use(chimw.Logger)
-	r.Use(chimw.Recoverer)
-	r.Use(chimw.RealIP)
-
-	r.Get("/", func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "application/json")
-		w.Write([]byte(`{"message":"welcome to books."}`))
-	})
-	r.Get("/health", func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte(`{"status":"ok"}`))
+	outbound := newOutbound(cfg)
+	a, err := app.New(ctx, cfg, app.Deps{
+		Store:           db,
+		Storage:         objects,
+		LocalStorage:    localStorage,
+		Signer:          signer,
+		Mailer:          mailer,
+		SystemMailer:    systemMailer,
+		SMTPPool:        smtpPool,
+		Drives:          newDrives(cfg),
+		Telegram:        newTelegram(cfg),
+		Prices:          newPriceProviders(cfg, outbound),
+		Converter:       newConverter(cfg),
+		Speaker:         newSpeaker(cfg, outbound),
+		Classifier:      newClassifier(cfg, outbound),
+		Embedder:        newEmbedder(cfg, outbound),
+		Assistant:       newAssistant(cfg, outbound),
+		Metadata:        newMetadata(cfg, outbound),
+		Documents:       newDocuments(cfg, outbound),
+		HTTPClient:      outbound,
+		RequestLog:      requestLog,
+		Events:          eventSink,
+		JobBroker:       jobBroker,
+		Reporter:        reporter,
+		Tracer:          tracer,
+		Clock:           service.SystemClock{},
+		Web:             webFiles(cfg),
+		MigrationTarget: migrationTarget,
 	})
+	if err != nil {
+		log.Fatal("app:", err)
+	}
+	if *demoMode {
+		if err := demo.Seed(ctx, db, objects, cfg.AuthEmail); err != nil {
+			log.Fatal("demo seed:", err)
+		}
+		log.Printf("demo mode: log in as %s / %s (admin) or as guest", cfg.AuthEmail, cfg.AuthPass)
+		for _, u := range demo.Users {
+			log.Printf("demo mode: log in as %s / %s (%s)", u.Email, demo.Password, u.Role)
+		}
+	}
+	run := a.Run
+	if mode == runModeWorker {
+		run = a.RunWorker
+	}
+	if err := run(ctx); err != nil {
+		log.Fatal(err)
+	}
+}
 
-		r.Route("/api", func(r chi.Router) {
-		r.Post("/auth/login", authHandler.Login)
-		r.Post("/auth/guest", authHandler.LoginAsGuest)
-		r.Get("/books/{id}/cover", booksHandler.Cover) // public so <img src> works without auth
-		r.Group(func(r chi.Router) {
-			r.Use(middleware.Auth(cfg.JWTSecret))
-			r.Get("/me", usersHandler.GetMe)
-			r.Patch("/me/preferences", usersHandler.PatchMePreferences)
-			// Read: admin, editor, viewer, guest (guests see only books with viewByGuest)
-			r.Group(func(r chi.Router) {
-				r.Use(middleware.RequireAnyRole("admin", "editor", "viewer", "guest"))
-				r.Get("/books", booksHandler.List)
-				r.Get("/books/{id}", booksHandler.Get)
-				r.Get("/books/{id}/download", booksHandler.Download)
-				r.Post("/books/{id}/send-to-kindle", booksHandler.SendToKindle)
-			})
-			// Write (upload): admin, editor
-			r.Group(func(r chi.Router) {
-				r.Use(middleware.RequireAnyRole("admin", "editor"))
-				r.Post("/upload", uploadHandler.Upload)
-			})
-			// Refresh metadata: admin, editor
-			r.Group(func(r chi.Router) {
-				r.Use(middleware.RequireAnyRole("admin", "editor"))
-				r.Post("/books/{id}/refresh-metadata", booksHandler.RefreshMetadata)
-			})
-			// Delete books: admin only
-			r.Group(func(r chi.Router) {
-				r.Use(middleware.RequireAdmin)
-				r.Delete("/books/{id}", booksHandler.Delete)
-			})
-			// Toggle view-by-guest (demo visibility): admin only
-			r.Group(func(r chi.Router) {
-				r.Use(middleware.RequireAdmin)
-				r.Patch("/books/{id}/view-by-guest", booksHandler.PatchViewByGuest)
-				r.Put("/books/{id}/view-by-guest", booksHandler.PatchViewByGuest)
-			})
-			// Manage users: admin only
-			r.Group(func(r chi.Router) {
-				r.Use(middleware.RequireAdmin)
-				r.Get("/users", usersHandler.ListUsers)
-				r.Post("/users", usersHandler.CreateUser)
-				r.Patch("/users/{id}", usersHandler.UpdateUser)
-				r.Delete("/users/{id}", usersHandler.DeleteUser)
-			})
-			// Kindle config (per user): any authenticated user
-			r.Get("/email-config", emailConfigHandler.Get)
-			r.Put("/email-config", emailConfigHandler.Save)
-			r.Patch("/email-config", emailConfigHandler.Save)
-		})
+// openStore connects to Postgres when DATABASE_URL is set, opens SQLite under DATA_DIR when that is set,
+// and otherwise connects to MongoDB.
+func openStore(ctx context.Context, cfg *config.Config) (store.Store, error) {
+	switch {
+	case cfg.DatabaseURL != "":
+		return docstore.OpenPostgres(ctx, cfg.DatabaseURL)
+	case cfg.DataDir != "":
+		if err := os.MkdirAll(cfg.DataDir, 0o755); err != nil {
+			return nil, err
+		}
+		return docstore.OpenSQLite(ctx, filepath.Join(cfg.DataDir, "books.db"))
+	}
+	return store.NewMongoDB(ctx, cfg.MongoURI, cfg.DBName, store.Options{
+		MaxPoolSize:            uint64(cfg.MongoMaxPoolSize),
+		MinPoolSize:            uint64(cfg.MongoMinPoolSize),
+		ConnectTimeout:         cfg.MongoConnectTimeout,
+		ServerSelectionTimeout: cfg.MongoSelectionTimeout,
+		OpTimeout:              cfg.MongoOpTimeout,
+		RetryWrites:            cfg.MongoRetryWrites,
+		RetryReads:             cfg.MongoRetryReads,
+		ListReadPreference:     cfg.MongoListReadPreference,
+		Trace:                  cfg.TracesEndpoint != "",
 	})
+}
 
-	server := &http.Server{Addr: ":" + cfg.Port, Handler: r}
-	go func() {
-		log.Println("server listening on :" + cfg.Port)
-		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatal(err)
+// openStorage returns the STORAGE_BACKEND: S3, or local files under STORAGE_DIR. localStorage is non-nil for the
+// latter so its signed URLs can be served. Both are nil when no storage is configured.
+// S3 is a service.LazyStorage: it connects in the background (see app.Run), so a wrong key or an S3 outage leaves
+// uploads and downloads unavailable instead of stopping the server.
+func openStorage(cfg *config.Config, signer *service.URLSigner) (objects service.ObjectStore, localStorage *service.LocalStorage, err error) {
+	switch cfg.StorageBackend {
+	case config.StorageBackendS3:
+		return service.NewLazyStorage("s3", func(ctx context.Context) (service.ObjectStore, error) {
+			return service.NewS3Service(ctx, cfg.S3Endpoint, cfg.S3Bucket, cfg.S3Region, cfg.S3AccessKeyID, cfg.S3SecretKey)
+		}), nil, nil
+	case config.StorageBackendLocal:
+		localStorage, err = service.NewLocalStorage(cfg.StorageDir, signer)
+		if err != nil {
+			return nil, nil, err
 		}
-	}()
+		return localStorage, localStorage, nil
+	}
+	log.Println("warning: none of AWS_S3_BUCKET, STORAGE_DIR and DATA_DIR set; uploads will fail")
+	return nil, nil, nil
+}
 
-	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-	<-quit
-	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-	if err := server.Shutdown(shutdownCtx); err != nil {
-		log.Println("shutdown:", err)
+// openMigrationTarget opens the storage the migrate-storage job copies to, or returns nil when none is set.
+func openMigrationTarget(ctx context.Context, t config.StorageTarget, signer *service.URLSigner) (service.ObjectStore, error) {
+	switch {
+	case t.S3Bucket != "":
+		return service.NewS3Service(ctx, t.S3Endpoint, t.S3Bucket, t.S3Region, t.S3AccessKeyID, t.S3SecretKey)
+	case t.DataDir != "":
+		return service.NewLocalStorage(filepath.Join(t.DataDir, "files"), signer)
 	}
+	return nil, nil
 }
 
-func seedBootstrapUser(ctx context.Context, db *store.DB, email, password string) error {
-	count, err := db.UsersCount(ctx)
-	if err != nil {
-		return err
+// newMailer builds the MAIL_TRANSPORT used for Send to Kindle; SMTP sends reuse pool's connections.
+func newMailer(ctx context.Context, cfg *config.Config, pool *service.SMTPPool) (service.Mailer, error) {
+	switch cfg.MailTransport {
+	case config.MailTransportSES:
+		return service.NewSESMailer(ctx, cfg.SESRegion, cfg.S3AccessKeyID, cfg.S3SecretKey, cfg.MailFrom)
+	case config.MailTransportMailgun:
+		return &service.MailgunMailer{Domain: cfg.MailgunDomain, APIKey: cfg.MailgunAPIKey, From: cfg.MailFrom, BaseURL: cfg.MailgunAPIBase}, nil
+	case config.MailTransportSendGrid:
+		return &service.SendGridMailer{APIKey: cfg.SendGridAPIKey, From: cfg.MailFrom}, nil
 	}
-	if count > 0 {
+	return service.SMTPMailer{Host: cfg.SMTPHost, Port: cfg.SMTPPort, Username: cfg.SMTPUsername, Password: cfg.SMTPPassword, From: cfg.MailFrom, Pool: pool}, nil
+}
+
+// newSystemMailer builds the SYSTEM_MAIL_TRANSPORT used for invites, password resets and notifications, or nil when unset.
+func newSystemMailer(ctx context.Context, cfg *config.Config, pool *service.SMTPPool) (service.Mailer, error) {
+	switch cfg.SystemMailTransport {
+	case config.MailTransportSES:
+		return service.NewSESMailer(ctx, cfg.SESRegion, cfg.S3AccessKeyID, cfg.S3SecretKey, cfg.SystemMailFrom)
+	case config.MailTransportSMTP:
+		return service.SMTPMailer{Host: cfg.SystemSMTPHost, Port: cfg.SystemSMTPPort, Username: cfg.SystemSMTPUsername, Password: cfg.SystemSMTPPassword, From: cfg.SystemMailFrom, Pool: pool}, nil
+	}
+	return nil, nil
+}
+
+// newDrives returns the cloud drives whose OAuth clients are configured.
+func newDrives(cfg *config.Config) map[string]service.Drive {
+	drives := map[string]service.Drive{}
+	if cfg.DropboxClientID != "" {
+		drives[models.TargetDropbox] = &service.DropboxDrive{ClientID: cfg.DropboxClientID, ClientSecret: cfg.DropboxClientSecret}
+	}
+	if cfg.GoogleClientID != "" {
+		drives[models.TargetGoogleDrive] = &service.GoogleDrive{ClientID: cfg.GoogleClientID, ClientSecret: cfg.GoogleClientSecret}
+	}
+	return drives
+}
+
+// newTelegram returns the Telegram bot client when a bot token is configured.
+func newTelegram(cfg *config.Config) *service.TelegramClient {
+	if cfg.TelegramBotToken == "" {
 		return nil
 	}
-	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
-	if err != nil {
-		return err
+	return &service.TelegramClient{Token: cfg.TelegramBotToken}
+}
+
+// newOutbound returns the client for third-party APIs, named with HTTP_USER_AGENT or the default with HTTP_CONTACT.
+func newOutbound(cfg *config.Config) *http.Client {
+	ua := cfg.HTTPUserAgent
+	if ua == "" {
+		ua = service.DefaultUserAgent
+		if contact := cfg.HTTPContact; contact != "" {
+			if strings.Contains(contact, "@") && !strings.HasPrefix(contact, "mailto:") {
+				contact = "mailto:" + contact
+			}
+			ua = strings.TrimSuffix(ua, ")") + "; " + contact + ")"
+		}
 	}
-	user := &models.User{
-		Email:     email,
-		Password:  string(hash),
-		Role:      models.RoleAdmin,
-		CreatedAt: time.Now(),
+	return service.NewOutboundClient(service.OutboundOptions{UserAgent: ua, MaxRetryWait: cfg.HTTPRetryMaxWait})
+}
+
+// newMetadata returns the metadata providers of METADATA_PROVIDERS, chained in order when there are several.
+func newMetadata(cfg *config.Config, client *http.Client) service.MetadataProvider {
+	var chain service.MetadataChain
+	for _, name := range cfg.MetadataProviders {
+		switch name {
+		case config.MetadataGoogleBooks:
+			chain = append(chain, service.GoogleBooks{Timeout: cfg.MetadataTimeout, Client: client})
+		case config.MetadataSRU:
+			chain = append(chain, service.SRU{URL: cfg.SRUURL, ISBNIndex: cfg.SRUISBNIndex, Timeout: cfg.MetadataTimeout, Client: client})
+		}
 	}
-	_, err = db.CreateUser(ctx, user)
-	if err != nil {
-		return err
+	if len(chain) == 1 {
+		return chain[0]
 	}
-	log.Println("created bootstrap admin user from env (users collection was empty)")
-	return nil
+	return chain
 }
 
-const guestUserEmail = "guest@guest.local"
+// newDocuments returns the DOI lookup for papers and theses, unless DOI_METADATA is off.
+func newDocuments(cfg *config.Config, client *http.Client) service.DOIProvider {
+	if !cfg.DOIMetadata {
+		return nil
+	}
+	mailto := cfg.CrossrefMailto
+	if mailto == "" && strings.Contains(cfg.HTTPContact, "@") {
+		mailto = strings.TrimPrefix(cfg.HTTPContact, "mailto:")
+	}
+	return service.Crossref{Mailto: mailto, Timeout: cfg.MetadataTimeout, Client: client}
+}
 
-func seedGuestUser(ctx context.Context, db *store.DB) error {
-	existing, err := db.UserByRole(ctx, models.RoleGuest)
-	if err != nil {
-		return err
+// newPriceProviders returns the stores watched wishlist items are priced at.
+func newPriceProviders(cfg *config.Config, client *http.Client) []service.PriceProvider {
+	var providers []service.PriceProvider
+	if cfg.PriceGoogleBooksCountry != "" {
+		providers = append(providers, service.GoogleBooksPrices{Country: cfg.PriceGoogleBooksCountry, Timeout: cfg.MetadataTimeout, Client: client})
+	}
+	for _, u := range cfg.PriceFeedURLs {
+		providers = append(providers, &service.PriceFeed{URL: u, Client: client})
+	}
+	return providers
+}
+
+// newConverter returns the ebook converter when ebook-convert is configured.
+// webFiles returns the frontend to serve: WEB_DIR when set, else the export built into the binary (nil when there
+// is none, leaving the frontend to be deployed separately).
+func webFiles(cfg *config.Config) fs.FS {
+	if cfg.WebDir != "" {
+		if _, err := os.Stat(filepath.Join(cfg.WebDir, "index.html")); err != nil {
+			log.Fatalf("WEB_DIR: %v", err)
+		}
+		log.Printf("web: serving the frontend from %s", cfg.WebDir)
+		return os.DirFS(cfg.WebDir)
 	}
-	if existing != nil {
+	if files := web.Embedded(); files != nil {
+		log.Printf("web: serving the built-in frontend")
+		return files
+	}
+	return nil
+}
+
+func newConverter(cfg *config.Config) service.Converter {
+	if cfg.EbookConvert == "" {
 		return nil
 	}
-	hash, err := bcrypt.GenerateFromPassword([]byte("guest"), bcrypt.DefaultCost)
-	if err != nil {
-		return err
+	return &service.CalibreConverter{Path: cfg.EbookConvert}
+}
+
+// newClassifier returns what suggests categories for books without any, when CLASSIFIER is set.
+func newClassifier(cfg *config.Config, client *http.Client) service.Classifier {
+	switch cfg.Classifier {
+	case config.ClassifierKeywords:
+		return &service.KeywordClassifier{}
+	case config.ClassifierLLM:
+		return &service.ChatClassifier{URL: cfg.ClassifierAPIURL, APIKey: cfg.ClassifierAPIKey, Model: cfg.ClassifierModel, Client: client}
 	}
-	user := &models.User{
-		Email:     guestUserEmail,
-		Password:  string(hash),
-		Role:      models.RoleGuest,
-		CreatedAt: time.Now(),
+	return nil
+}
+
+// newEmbedder returns what embeds books for semantic search, when EMBEDDINGS is set.
+func newEmbedder(cfg *config.Config, client *http.Client) service.Embedder {
+	if cfg.Embeddings != config.EmbeddingsAPI {
+		return nil
 	}
-	_, err = db.CreateUser(ctx, user)
-	if err != nil {
-		return err
+	return &service.EmbeddingsAPI{URL: cfg.EmbeddingsAPIURL, APIKey: cfg.EmbeddingsAPIKey, ModelName: cfg.EmbeddingsModel, Client: client}
+}
+
+// newAssistant returns what answers questions about the library, when ASSISTANT is set.
+func newAssistant(cfg *config.Config, client *http.Client) service.Assistant {
+	switch cfg.Assistant {
+	case config.AssistantOpenAI:
+		return &service.OpenAIAssistant{URL: cfg.AssistantAPIURL, APIKey: cfg.AssistantAPIKey, Model: cfg.AssistantModel, Client: client}
+	case config.AssistantAnthropic:
+		return &service.AnthropicAssistant{URL: cfg.AssistantAPIURL, APIKey: cfg.AssistantAPIKey, Model: cfg.AssistantModel, Client: client}
+	}
+	return nil
+}
+
+// newSpeaker returns the voice books are read aloud with, when TTS_BACKEND is set.
+func newSpeaker(cfg *config.Config, client *http.Client) service.Speaker {
+	switch cfg.TTSBackend {
+	case config.TTSBackendPiper:
+		return &service.PiperSpeaker{Path: cfg.TTSPiper, Model: cfg.TTSPiperModel, FFmpeg: cfg.TTSFFmpeg}
+	case config.TTSBackendAPI:
+		return &service.SpeechAPI{URL: cfg.TTSAPIURL, APIKey: cfg.TTSAPIKey, Model: cfg.TTSAPIModel, Name: cfg.TTSVoice, Client: client}
 	}
-	log.Println("created bootstrap guest user for View as guest")
 	return nil
 }
@@ -0,0 +1,74 @@
+package middleware
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"slices"
+
+	"github.com/go-chi/chi/v5"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// APIKeyHeader carries an API key in place of a bearer token.
+const APIKeyHeader = "X-API-Key"
+
+// APIKeyOwner is who an API key acts as, and what it may do.
+type APIKeyOwner struct {
+	UserID primitive.ObjectID
+	Email  string
+	Role   string
+	Scopes []string
+}
+
+// APIKeyResolver returns the owner of a key, or nil if the key is unknown or revoked.
+type APIKeyResolver func(ctx context.Context, key string) (*APIKeyOwner, error)
+
+// AuthOrAPIKey is Auth for routes that also take an API key with the given scope in the X-API-Key header. A
+// request with a key is authenticated by the key alone.
+func AuthOrAPIKey(jwtSecret, scope string, resolve APIKeyResolver) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		withToken := Auth(jwtSecret, nil)(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(APIKeyHeader)
+			if key == "" {
+				withToken.ServeHTTP(w, r)
+				return
+			}
+			serveAsKeyOwner(w, r, next, key, resolve, scope)
+		})
+	}
+}
+
+// APIKeyInPath authenticates requests by an API key with the given scope in the URL parameter param, for clients
+// that can only be given a URL, such as e-readers subscribing to feeds.
+func APIKeyInPath(param, scope string, resolve APIKeyResolver) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			serveAsKeyOwner(w, r, next, chi.URLParam(r, param), resolve, scope)
+		})
+	}
+}
+
+// serveAsKeyOwner serves r as the owner of key, if the key has any of scopes.
+func serveAsKeyOwner(w http.ResponseWriter, r *http.Request, next http.Handler, key string, resolve APIKeyResolver, scopes ...string) {
+	owner, err := resolve(r.Context(), key)
+	if err != nil {
+		log.Printf("api key: %v", err)
+		http.Error(w, `{"error":"failed to check api key"}`, http.StatusInternalServerError)
+		return
+	}
+	if owner == nil {
+		http.Error(w, `{"error":"invalid api key"}`, http.StatusUnauthorized)
+		return
+	}
+	if !slices.ContainsFunc(scopes, func(s string) bool { return slices.Contains(owner.Scopes, s) }) {
+		http.Error(w, `{"error":"api key not allowed here"}`, http.StatusForbidden)
+		return
+	}
+	ctx := context.WithValue(r.Context(), UserIDKey, owner.UserID)
+	ctx = context.WithValue(ctx, RoleKey, owner.Role)
+	ctx = context.WithValue(ctx, EmailKey, owner.Email)
+	ctx = context.WithValue(ctx, APIKeyKey, true)
+	next.ServeHTTP(w, r.WithContext(ctx))
+}
@@ -6,6 +6,7 @@ import (
 	"strings"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/kevinaaaquil/books/backend/models"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
@@ -15,6 +16,8 @@ const (
 	UserIDKey contextKey = "userID"
 	RoleKey   contextKey = "role"
 	EmailKey  contextKey = "email"
+	ViaKey    contextKey = "via"
+	APIKeyKey contextKey = "apiKey" // true for requests authenticated by an API key
 )
 
 type Claims struct {
@@ -24,9 +27,25 @@ type Claims struct {
 	jwt.RegisteredClaims
 }
 
-func Auth(jwtSecret string) func(next http.Handler) http.Handler {
+// Auth authenticates requests by a bearer JWT or, when resolve is set, by an API key in the X-API-Key header: one
+// with the read scope for GET and HEAD requests, the write scope for any. A request with a key is authenticated by
+// the key alone. nil resolve takes tokens only.
+func Auth(jwtSecret string, resolve APIKeyResolver) func(next http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if _, ok := UserIDFromContext(r.Context()); ok {
+				// Signed in by ForwardAuth.
+				next.ServeHTTP(w, r)
+				return
+			}
+			if key := r.Header.Get(APIKeyHeader); key != "" && resolve != nil {
+				scopes := []string{models.ScopeWrite}
+				if r.Method == http.MethodGet || r.Method == http.MethodHead {
+					scopes = append(scopes, models.ScopeRead)
+				}
+				serveAsKeyOwner(w, r, next, key, resolve, scopes...)
+				return
+			}
 			auth := r.Header.Get("Authorization")
 			if auth == "" {
 				http.Error(w, `{"error":"missing authorization header"}`, http.StatusUnauthorized)
@@ -77,6 +96,36 @@ func EmailFromContext(ctx context.Context) string {
 	return email
 }
 
+// WithVia marks ctx as a request the server makes to its own API on a user's behalf, naming the caller (e.g.
+// "telegram"). Only in-process callers can set it.
+func WithVia(ctx context.Context, via string) context.Context {
+	return context.WithValue(ctx, ViaKey, via)
+}
+
+// ViaFromContext returns the in-process caller set with WithVia; "" for requests from clients.
+func ViaFromContext(ctx context.Context) string {
+	via, _ := ctx.Value(ViaKey).(string)
+	return via
+}
+
+// FromAPIKey reports whether the request was authenticated by an API key rather than a sign-in.
+func FromAPIKey(ctx context.Context) bool {
+	v, _ := ctx.Value(APIKeyKey).(bool)
+	return v
+}
+
+// RequireSignIn returns 403 for requests authenticated by an API key, for what only a signed-in user may do, such
+// as managing API keys: a leaked key can't make itself new ones.
+func RequireSignIn(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if FromAPIKey(r.Context()) {
+			http.Error(w, `{"error":"api keys can't be used here; sign in"}`, http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
 // RequireAdmin returns 403 if the request context role is not admin.
 func RequireAdmin(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -0,0 +1,111 @@
+package middleware
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"net/netip"
+	"sync"
+	"time"
+
+	"github.com/kevinaaaquil/books/backend/models"
+)
+
+// blocklistRefresh is how often the blocks are reloaded, so ones added or removed on another instance apply here.
+const blocklistRefresh = 30 * time.Second
+
+// BlocklistStore is what Blocklist needs from the database.
+type BlocklistStore interface {
+	IPBlocks(ctx context.Context, now time.Time) ([]models.IPBlock, error)
+}
+
+// Blocklist refuses requests from the addresses and ranges admins blocked (see models.IPBlock) with 403. Blocks are
+// cached, and reloaded by the first request after blocklistRefresh while the others use the cached ones; when a
+// reload fails, the last list stays in force.
+type Blocklist struct {
+	db BlocklistStore
+
+	mu      sync.Mutex
+	ranges  []blockedRange
+	loaded  time.Time
+	loading bool
+}
+
+type blockedRange struct {
+	prefix  netip.Prefix
+	expires *time.Time
+}
+
+// NewBlocklist returns a Blocklist of the blocks in db.
+func NewBlocklist(db BlocklistStore) *Blocklist {
+	return &Blocklist{db: db}
+}
+
+// Block answers requests from blocked addresses with 403.
+func (b *Blocklist) Block(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if b.Blocked(r.Context(), clientIP(r)) {
+			http.Error(w, `{"error":"your address is blocked","code":"IP_BLOCKED"}`, http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Blocked reports whether ip is in a block in force. Addresses that don't parse (e.g. Unix socket peers) never are.
+func (b *Blocklist) Blocked(ctx context.Context, ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	now := time.Now()
+	b.mu.Lock()
+	stale := !b.loading && now.Sub(b.loaded) >= blocklistRefresh
+	if stale {
+		b.loading = true
+	}
+	ranges := b.ranges
+	b.mu.Unlock()
+	if stale {
+		// Other requests go on with the old list meanwhile.
+		ranges = b.reload(context.WithoutCancel(ctx), now)
+	}
+	for _, br := range ranges {
+		if br.prefix.Contains(addr) && (br.expires == nil || br.expires.After(now)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Reload loads the blocks now, e.g. after an admin changed them.
+func (b *Blocklist) Reload(ctx context.Context) {
+	b.mu.Lock()
+	b.loading = true
+	b.mu.Unlock()
+	b.reload(ctx, time.Now())
+}
+
+// reload replaces the cached blocks, unless loading them fails, and returns the ones in force. Callers set b.loading.
+func (b *Blocklist) reload(ctx context.Context, now time.Time) []blockedRange {
+	blocks, err := b.db.IPBlocks(ctx, now)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.loading, b.loaded = false, now
+	if err != nil {
+		log.Printf("blocklist: reload: %v", err)
+		return b.ranges
+	}
+	ranges := make([]blockedRange, 0, len(blocks))
+	for _, block := range blocks {
+		prefix, err := netip.ParsePrefix(block.Range)
+		if err != nil {
+			log.Printf("blocklist: block %s: invalid range %q", block.ID.Hex(), block.Range)
+			continue
+		}
+		ranges = append(ranges, blockedRange{prefix, block.ExpiresAt})
+	}
+	b.ranges = ranges
+	return ranges
+}
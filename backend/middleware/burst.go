@@ -0,0 +1,143 @@
+package middleware
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/kevinaaaquil/books/backend/models"
+)
+
+// BurstGuard catches scrapers: an address making more than limit requests to the guarded routes within window,
+// as a guest or without a token, is flagged and refused with 429 until penalty has passed. Unlike RateLimiter's
+// buckets, which let a client go on at the refill rate, a flagged address gets nothing until the penalty ends.
+// Signed-in users other than the guest are not counted. Flags are per instance.
+type BurstGuard struct {
+	limit     int
+	window    time.Duration
+	penalty   time.Duration
+	jwtSecret string
+
+	mu        sync.Mutex
+	counts    map[string]*burstCount
+	flagged   map[string]*models.FlaggedIP
+	lastSweep time.Time
+}
+
+type burstCount struct {
+	start time.Time
+	n     int
+}
+
+// NewBurstGuard returns a BurstGuard; limit 0 disables it. jwtSecret identifies callers on routes outside Auth.
+func NewBurstGuard(limit int, window, penalty time.Duration, jwtSecret string) *BurstGuard {
+	return &BurstGuard{
+		limit:     limit,
+		window:    window,
+		penalty:   penalty,
+		jwtSecret: jwtSecret,
+		counts:    map[string]*burstCount{},
+		flagged:   map[string]*models.FlaggedIP{},
+	}
+}
+
+// Guard counts requests from guests and anonymous callers against their address, answering those from flagged
+// addresses with 429 and a Retry-After.
+func (g *BurstGuard) Guard(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if g.limit <= 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if role, _, signedIn := requestUser(r, g.jwtSecret); signedIn && role != models.RoleGuest {
+			next.ServeHTTP(w, r)
+			return
+		}
+		wait := g.hit(clientIP(r))
+		if wait <= 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+		retryAfter := int(math.Ceil(wait.Seconds()))
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+		w.WriteHeader(http.StatusTooManyRequests)
+		json.NewEncoder(w).Encode(RateLimitResponse{
+			Error:      "too many requests from your address, try again in " + strconv.Itoa(retryAfter) + "s",
+			Code:       "BURST_LIMITED",
+			RetryAfter: retryAfter,
+		})
+	})
+}
+
+// hit counts a request from ip and returns 0, or how long until ip's flag is lifted.
+func (g *BurstGuard) hit(ip string) time.Duration {
+	now := time.Now()
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.sweep(now)
+	if f := g.flagged[ip]; f != nil {
+		if now.Before(f.Until) {
+			return f.Until.Sub(now)
+		}
+		delete(g.flagged, ip)
+	}
+	c := g.counts[ip]
+	if c == nil || now.Sub(c.start) >= g.window {
+		c = &burstCount{start: now}
+		g.counts[ip] = c
+	}
+	c.n++
+	if c.n <= g.limit {
+		return 0
+	}
+	delete(g.counts, ip)
+	g.flagged[ip] = &models.FlaggedIP{IP: ip, Requests: c.n, FlaggedAt: now, Until: now.Add(g.penalty)}
+	return g.penalty
+}
+
+// sweep drops finished windows and lifted flags, at most once a minute. Callers hold g.mu.
+func (g *BurstGuard) sweep(now time.Time) {
+	if now.Sub(g.lastSweep) < time.Minute {
+		return
+	}
+	g.lastSweep = now
+	for ip, c := range g.counts {
+		if now.Sub(c.start) >= g.window {
+			delete(g.counts, ip)
+		}
+	}
+	for ip, f := range g.flagged {
+		if !now.Before(f.Until) {
+			delete(g.flagged, ip)
+		}
+	}
+}
+
+// Flagged returns the addresses flagged on this instance, most recently flagged first.
+func (g *BurstGuard) Flagged() []models.FlaggedIP {
+	now := time.Now()
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	out := []models.FlaggedIP{}
+	for _, f := range g.flagged {
+		if now.Before(f.Until) {
+			out = append(out, *f)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].FlaggedAt.After(out[j].FlaggedAt) })
+	return out
+}
+
+// Unflag lifts ip's flag, if it has one, and reports whether it had.
+func (g *BurstGuard) Unflag(ip string) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	f := g.flagged[ip]
+	delete(g.flagged, ip)
+	return f != nil && time.Now().Before(f.Until)
+}
@@ -0,0 +1,133 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+)
+
+// CachePolicy is how responses to a route may be cached.
+type CachePolicy struct {
+	CacheControl string
+	// ETag tags successful GET responses with a hash of the body and answers a matching If-None-Match with 304.
+	// Responses are buffered, so use it only for small bodies.
+	ETag bool
+	// Private responses depend on who asks; they vary on Authorization.
+	Private bool
+}
+
+// Cache policies for the routes, kept here so they are set in one place rather than by each handler.
+var (
+	// CacheImmutable is for content-addressed URLs: whatever they serve never changes.
+	CacheImmutable = CachePolicy{CacheControl: "public, max-age=31536000, immutable"}
+	// CacheRevalidate is for per-user documents that change now and then, such as a book's details.
+	CacheRevalidate = CachePolicy{CacheControl: "private, max-age=60, must-revalidate", ETag: true, Private: true}
+	// CachePreview is for derived content that only changes when the book file is replaced.
+	CachePreview = CachePolicy{CacheControl: "private, max-age=3600", ETag: true, Private: true}
+	// CacheBookContent is for files inside a book file, which set their own ETag and may be too large to buffer.
+	CacheBookContent = CachePolicy{CacheControl: "private, max-age=3600", Private: true}
+	// CachePublic is for responses that are the same for everyone and change only with the server's configuration.
+	CachePublic = CachePolicy{CacheControl: "public, max-age=300", ETag: true}
+	// CacheStatic is for files whose names stay the same when their content changes, such as favicon.ico.
+	CacheStatic = CachePolicy{CacheControl: "public, max-age=3600"}
+	// CacheNone is for responses that must be fetched every time.
+	CacheNone = CachePolicy{CacheControl: "no-cache"}
+)
+
+// Cache applies p to successful (200) responses whose handler did not set Cache-Control itself; errors are never
+// cached.
+func Cache(p CachePolicy) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodGet && r.Method != http.MethodHead {
+				next.ServeHTTP(w, r)
+				return
+			}
+			cw := &cacheWriter{ResponseWriter: w, policy: p, buffer: p.ETag && r.Method == http.MethodGet}
+			next.ServeHTTP(cw, r)
+			if cw.buffer {
+				cw.flush(r)
+			}
+		})
+	}
+}
+
+// cacheWriter sets the policy's headers when the status is written and, for ETag policies, holds back the body
+// until the handler returns.
+type cacheWriter struct {
+	http.ResponseWriter
+	policy      CachePolicy
+	buffer      bool
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (w *cacheWriter) WriteHeader(status int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	w.status = status
+	h := w.Header()
+	if status == http.StatusOK && h.Get("Cache-Control") == "" {
+		h.Set("Cache-Control", w.policy.CacheControl)
+		if w.policy.Private {
+			h.Add("Vary", "Authorization")
+		}
+	} else {
+		w.buffer = false
+	}
+	if !w.buffer {
+		w.ResponseWriter.WriteHeader(status)
+	}
+}
+
+func (w *cacheWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.buffer {
+		return w.body.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// flush sends the buffered response, or 304 when the client already has it.
+func (w *cacheWriter) flush(r *http.Request) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	h := w.Header()
+	etag := h.Get("ETag")
+	if etag == "" {
+		sum := sha256.Sum256(w.body.Bytes())
+		etag = `"` + hex.EncodeToString(sum[:16]) + `"`
+		h.Set("ETag", etag)
+	}
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		h.Del("Content-Type")
+		h.Del("Content-Length")
+		w.ResponseWriter.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+	w.ResponseWriter.Write(w.body.Bytes())
+}
+
+// etagMatches reports whether an If-None-Match header lists etag (weak comparison, as RFC 9110 asks for GET).
+func etagMatches(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
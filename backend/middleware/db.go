@@ -0,0 +1,19 @@
+package middleware
+
+import "net/http"
+
+// RequireDB returns 503 immediately while healthy reports the database as unreachable,
+// instead of letting each request block until the driver's server selection times out.
+func RequireDB(healthy func() bool) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !healthy() {
+				w.Header().Set("Content-Type", "application/json")
+				w.Header().Set("Retry-After", "5")
+				http.Error(w, `{"error":"database unavailable, try again shortly"}`, http.StatusServiceUnavailable)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
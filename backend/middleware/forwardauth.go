@@ -0,0 +1,138 @@
+package middleware
+
+import (
+	"context"
+	"log"
+	"net"
+	"net/http"
+	"net/netip"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kevinaaaquil/books/backend/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ForwardAuthConfig describes the identity headers an SSO reverse proxy (Authelia, authentik, oauth2-proxy)
+// sets on requests it has authenticated.
+type ForwardAuthConfig struct {
+	UserHeader   string            // e.g. Remote-User; its presence marks a request as authenticated by the proxy
+	EmailHeader  string            // e.g. Remote-Email; falls back to the user header when that is an email
+	GroupsHeader string            // e.g. Remote-Groups, comma-separated
+	Roles        map[string]string // group -> role; the most privileged matching role wins
+	DefaultRole  string            // for users in no mapped group; "" refuses them
+	Trusted      []netip.Prefix    // proxies whose headers are believed (see RealIP)
+}
+
+// ForwardUserResolver returns the ID of the user with email, creating them or changing their role to role as
+// needed; the zero ID when they can't be signed in.
+type ForwardUserResolver func(ctx context.Context, email, role string) (primitive.ObjectID, error)
+
+// rolePrecedence orders the roles groups can map to from most to least privileged. The shared guest account is
+// not one of them.
+var rolePrecedence = []string{models.RoleAdmin, models.RoleEditor, models.RoleViewer}
+
+// Role maps groups to a role by cfg.Roles, falling back to cfg.DefaultRole.
+func (cfg ForwardAuthConfig) Role(groups []string) string {
+	best := -1
+	for _, g := range groups {
+		if i := slices.Index(rolePrecedence, cfg.Roles[strings.TrimSpace(g)]); i >= 0 && (best < 0 || i < best) {
+			best = i
+		}
+	}
+	if best < 0 {
+		return cfg.DefaultRole
+	}
+	return rolePrecedence[best]
+}
+
+// forwardUserTTL is how long ForwardAuth reuses the user it resolved for an email and role, so that requests
+// through the proxy don't each look the user up.
+const forwardUserTTL = 30 * time.Second
+
+type forwardUser struct {
+	id      primitive.ObjectID
+	expires time.Time
+}
+
+// ForwardAuth signs in requests that a trusted proxy marks as authenticated with cfg.UserHeader, as the user with
+// their email, with the role their groups map to. Auth and AuthOrAPIKey then accept them without a token.
+// Requests without the header, or from peers that are not trusted proxies, pass through untouched. It must run
+// before RealIP, which replaces the peer's address. Resolved users are cached for forwardUserTTL by email and
+// role, so a change of groups at the proxy still takes effect on the next request.
+func ForwardAuth(cfg ForwardAuthConfig, resolve ForwardUserResolver) func(next http.Handler) http.Handler {
+	var mu sync.Mutex
+	cache := map[string]forwardUser{} // email and role -> user
+	cached := func(ctx context.Context, email, role string) (primitive.ObjectID, error) {
+		key, now := email+"\x00"+role, time.Now()
+		mu.Lock()
+		u, ok := cache[key]
+		mu.Unlock()
+		if ok && now.Before(u.expires) {
+			return u.id, nil
+		}
+		id, err := resolve(ctx, email, role)
+		if err != nil || id.IsZero() {
+			return id, err
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		// Expired entries go, and so do the user's other roles: resolving changed their role in the store.
+		for k, u := range cache {
+			if !now.Before(u.expires) || strings.HasPrefix(k, email+"\x00") {
+				delete(cache, k)
+			}
+		}
+		cache[key] = forwardUser{id: id, expires: now.Add(forwardUserTTL)}
+		return id, nil
+	}
+	trusted := func(r *http.Request) bool {
+		host := r.RemoteAddr
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		peer, err := netip.ParseAddr(host)
+		if err != nil {
+			return true // Unix socket
+		}
+		return slices.ContainsFunc(cfg.Trusted, func(p netip.Prefix) bool { return p.Contains(peer.Unmap()) })
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user := strings.TrimSpace(r.Header.Get(cfg.UserHeader))
+			if user == "" || !trusted(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			email := strings.TrimSpace(r.Header.Get(cfg.EmailHeader))
+			if email == "" && strings.Contains(user, "@") {
+				email = user
+			}
+			if email == "" {
+				http.Error(w, `{"error":"the proxy sent no email for this user"}`, http.StatusUnauthorized)
+				return
+			}
+			role := cfg.Role(strings.Split(r.Header.Get(cfg.GroupsHeader), ","))
+			if role == "" {
+				http.Error(w, `{"error":"none of your groups may use this library"}`, http.StatusForbidden)
+				return
+			}
+			userID, err := cached(r.Context(), email, role)
+			if err != nil {
+				log.Printf("forward auth %s: %v", email, err)
+				http.Error(w, `{"error":"failed to sign in"}`, http.StatusInternalServerError)
+				return
+			}
+			if userID.IsZero() {
+				http.Error(w, `{"error":"no account for this user"}`, http.StatusForbidden)
+				return
+			}
+			ctx := context.WithValue(r.Context(), UserIDKey, userID)
+			ctx = context.WithValue(ctx, RoleKey, role)
+			ctx = context.WithValue(ctx, EmailKey, email)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
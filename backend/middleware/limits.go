@@ -0,0 +1,16 @@
+package middleware
+
+import "net/http"
+
+// MaxBodyBytes limits request bodies to n bytes (no limit when n <= 0). Reading past the limit fails with an
+// *http.MaxBytesError, which handlers report as 413.
+func MaxBodyBytes(n int64) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if n > 0 {
+				r.Body = http.MaxBytesReader(w, r.Body, n)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package middleware
+
+import (
+	"math/rand/v2"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	chimw "github.com/go-chi/chi/v5/middleware"
+	"github.com/kevinaaaquil/books/backend/logging"
+)
+
+// RequestLogger logs every request to sink once it is answered, with its URL redacted (see logging.RedactURL).
+// sample maps route patterns (e.g. /api/books/{id}/cover) to the share of their requests logged, from 0 to 1, so
+// high-volume routes don't drown the log; failed requests (4xx and 5xx) are always logged.
+func RequestLogger(sink logging.Sink, sample map[string]float64) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			ww := chimw.NewWrapResponseWriter(w, r.ProtoMajor)
+			defer func() {
+				status := ww.Status()
+				if status == 0 {
+					status = http.StatusOK
+				}
+				e := requestEntry(r, start, status, ww.BytesWritten())
+				if rate, ok := sample[e.Route]; ok && status < 400 && rand.Float64() >= rate {
+					return
+				}
+				sink.Log(e)
+			}()
+			next.ServeHTTP(ww, r)
+		})
+	}
+}
+
+// requestEntry describes r for the request log and error reports.
+func requestEntry(r *http.Request, start time.Time, status, bytes int) logging.Entry {
+	var route string
+	if rc := chi.RouteContext(r.Context()); rc != nil {
+		route = rc.RoutePattern()
+	}
+	u := *r.URL
+	u.Host = r.Host
+	u.Scheme = "http"
+	if r.TLS != nil {
+		u.Scheme = "https"
+	}
+	return logging.Entry{
+		Time:       start,
+		Method:     r.Method,
+		URL:        logging.RedactURL(&u),
+		Proto:      r.Proto,
+		RemoteAddr: r.RemoteAddr,
+		Route:      route,
+		Status:     status,
+		Bytes:      bytes,
+		Duration:   time.Since(start),
+	}
+}
@@ -0,0 +1,69 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/kevinaaaquil/books/backend/models"
+)
+
+// MaintenanceResponse is the 503 body while maintenance mode is on.
+type MaintenanceResponse struct {
+	Error   string `json:"error"`
+	Code    string `json:"code"` // always "MAINTENANCE"
+	Message string `json:"message,omitempty"`
+}
+
+// Maintenance answers requests with 503 and a Retry-After while state reports maintenance mode, except requests
+// from admins (by their bearer token, or signed in by ForwardAuth) and logins, so an admin can still sign in and
+// do the work.
+func Maintenance(jwtSecret string, state func(ctx context.Context) models.Maintenance) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			m := state(r.Context())
+			if !m.Enabled || r.URL.Path == "/api/auth/login" || isAdminToken(r, jwtSecret) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			retryAfter := m.RetryAfter
+			if retryAfter <= 0 {
+				retryAfter = models.DefaultMaintenanceRetryAfter
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+			w.WriteHeader(http.StatusServiceUnavailable)
+			json.NewEncoder(w).Encode(MaintenanceResponse{Error: "down for maintenance, try again later", Code: "MAINTENANCE", Message: m.Message})
+		})
+	}
+}
+
+// isAdminToken reports whether the request carries a valid admin token, or comes from an admin signed in by
+// ForwardAuth.
+func isAdminToken(r *http.Request, jwtSecret string) bool {
+	if RoleFromContext(r.Context()) == models.RoleAdmin {
+		return true
+	}
+	claims := tokenClaims(r, jwtSecret)
+	return claims != nil && claims.Role == models.RoleAdmin
+}
+
+// tokenClaims returns the claims of the request's bearer token, or nil if it has no valid one. For routes
+// outside Auth that still treat signed-in users differently.
+func tokenClaims(r *http.Request, jwtSecret string) *Claims {
+	raw, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		return nil
+	}
+	var claims Claims
+	token, err := jwt.ParseWithClaims(raw, &claims, func(t *jwt.Token) (interface{}, error) {
+		return []byte(jwtSecret), nil
+	})
+	if err != nil || !token.Valid {
+		return nil
+	}
+	return &claims
+}
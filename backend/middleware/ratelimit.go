@@ -0,0 +1,179 @@
+package middleware
+
+import (
+	"encoding/json"
+	"math"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/kevinaaaquil/books/backend/models"
+	"golang.org/x/time/rate"
+)
+
+// rateBucketIdle is how long an unused bucket is kept; by then it has refilled, so dropping it changes nothing.
+const rateBucketIdle = 10 * time.Minute
+
+// RateLimitResponse is the 429 body when a rate limit is hit.
+type RateLimitResponse struct {
+	Error      string `json:"error"`
+	Code       string `json:"code"` // always "RATE_LIMITED"
+	RetryAfter int    `json:"retryAfter"`
+}
+
+// RateLimiter limits requests to expensive endpoints with a token bucket per endpoint class and caller: each
+// bucket holds a minute's worth of requests and refills continuously. Callers are users by ID, except guests
+// (one shared account) and requests without a token, which are told apart by IP. Limits are per instance.
+type RateLimiter struct {
+	limits    models.RateLimits
+	jwtSecret string
+
+	mu        sync.Mutex
+	buckets   map[string]*rateBucket
+	stats     map[[2]string]*models.RateLimitStat // by class, role
+	lastSweep time.Time
+}
+
+type rateBucket struct {
+	limiter *rate.Limiter
+	last    time.Time // when it was last used
+}
+
+// NewRateLimiter returns a RateLimiter enforcing limits. jwtSecret identifies callers on routes outside Auth.
+func NewRateLimiter(limits models.RateLimits, jwtSecret string) *RateLimiter {
+	return &RateLimiter{
+		limits:    limits,
+		jwtSecret: jwtSecret,
+		buckets:   map[string]*rateBucket{},
+		stats:     map[[2]string]*models.RateLimitStat{},
+	}
+}
+
+// Limit applies class's limits to requests for which applies returns true (all when it is nil), answering
+// requests over the limit with 429 and a Retry-After.
+func (l *RateLimiter) Limit(class string, applies func(*http.Request) bool) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if applies != nil && !applies(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			role, caller := l.caller(r)
+			wait := l.take(class, role, caller)
+			if wait <= 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+			retryAfter := int(math.Ceil(wait.Seconds()))
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+			w.WriteHeader(http.StatusTooManyRequests)
+			json.NewEncoder(w).Encode(RateLimitResponse{
+				Error:      "too many requests, try again in " + strconv.Itoa(retryAfter) + "s",
+				Code:       "RATE_LIMITED",
+				RetryAfter: retryAfter,
+			})
+		})
+	}
+}
+
+// caller returns the role the request counts under and the key of its bucket.
+func (l *RateLimiter) caller(r *http.Request) (role, key string) {
+	role, userID, signedIn := requestUser(r, l.jwtSecret)
+	if !signedIn || role == models.RoleGuest {
+		return models.RoleGuest, "ip:" + clientIP(r)
+	}
+	return role, "user:" + userID
+}
+
+// requestUser returns the signed-in user's role and ID: from the context behind Auth, from the token elsewhere.
+func requestUser(r *http.Request, jwtSecret string) (role, userID string, signedIn bool) {
+	if id, ok := UserIDFromContext(r.Context()); ok {
+		return RoleFromContext(r.Context()), id.Hex(), true
+	}
+	if claims := tokenClaims(r, jwtSecret); claims != nil {
+		return claims.Role, claims.UserID, true
+	}
+	return "", "", false
+}
+
+// clientIP is the request's address without the port: the client's, once RealIP has run.
+func clientIP(r *http.Request) string {
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
+
+// take spends a token from the caller's bucket for class and returns 0, or how long until one is available.
+func (l *RateLimiter) take(class, role, caller string) time.Duration {
+	limit := l.limits[role][class]
+	now := time.Now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	stat := l.stats[[2]string{class, role}]
+	if stat == nil {
+		stat = &models.RateLimitStat{Class: class, Role: role, Limit: limit}
+		l.stats[[2]string{class, role}] = stat
+	}
+	if limit <= 0 {
+		stat.Allowed++
+		return 0
+	}
+	l.sweep(now)
+	key := class + "|" + caller
+	b := l.buckets[key]
+	if b == nil {
+		b = &rateBucket{limiter: rate.NewLimiter(rate.Limit(float64(limit)/60), limit)}
+		l.buckets[key] = b
+	}
+	b.last = now
+	// A reservation that would have to wait is given back, so a refused request costs no token.
+	res := b.limiter.ReserveN(now, 1)
+	if wait := res.DelayFrom(now); wait > 0 {
+		res.CancelAt(now)
+		stat.Limited++
+		return wait
+	}
+	stat.Allowed++
+	return 0
+}
+
+// sweep drops idle buckets, at most once a minute. Callers hold l.mu.
+func (l *RateLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < time.Minute {
+		return
+	}
+	l.lastSweep = now
+	for key, b := range l.buckets {
+		if now.Sub(b.last) > rateBucketIdle {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// Stats returns this instance's request counts for every class and role, sorted by class then role.
+func (l *RateLimiter) Stats() []models.RateLimitStat {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var out []models.RateLimitStat
+	for role, classes := range l.limits {
+		for class, limit := range classes {
+			stat := models.RateLimitStat{Class: class, Role: role, Limit: limit}
+			if s := l.stats[[2]string{class, role}]; s != nil {
+				stat.Allowed, stat.Limited = s.Allowed, s.Limited
+			}
+			out = append(out, stat)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Class != out[j].Class {
+			return out[i].Class < out[j].Class
+		}
+		return out[i].Role < out[j].Role
+	})
+	return out
+}
@@ -0,0 +1,38 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// ReadOnlyResponse is the 503 body for writes to a read-only replica.
+type ReadOnlyResponse struct {
+	Error string `json:"error"`
+	Code  string `json:"code"` // always "READ_ONLY"
+}
+
+// readOnlyAllowed are the non-GET requests a read-only replica still serves: sign-in, which writes nothing.
+var readOnlyAllowed = map[string]bool{
+	"POST /api/auth/login": true,
+	"POST /api/auth/guest": true,
+	"POST /api/auth/proxy": true,
+}
+
+// ReadOnly answers every request that could change data with 503, for a replica that serves a copy of the
+// catalog while the primary takes the writes. GET, HEAD and OPTIONS requests and sign-in pass through.
+func ReadOnly(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			next.ServeHTTP(w, r)
+			return
+		}
+		if readOnlyAllowed[r.Method+" "+r.URL.Path] {
+			next.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(ReadOnlyResponse{Error: "this server is a read-only replica; make changes on the primary", Code: "READ_ONLY"})
+	})
+}
@@ -0,0 +1,65 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// RealIP sets r.RemoteAddr to the client's address from X-Forwarded-For or X-Real-IP, but only for requests whose
+// peer is a trusted proxy: in one of trusted, or connected over a Unix socket. X-Forwarded-For is read from the
+// right, skipping trusted proxies, so addresses a client prepends itself are ignored. Other requests keep the
+// peer's address, whatever headers they send.
+func RealIP(trusted []netip.Prefix) func(next http.Handler) http.Handler {
+	isTrusted := func(addr netip.Addr) bool {
+		addr = addr.Unmap()
+		for _, p := range trusted {
+			if p.Contains(addr) {
+				return true
+			}
+		}
+		return false
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			host := r.RemoteAddr
+			if h, _, err := net.SplitHostPort(host); err == nil {
+				host = h
+			}
+			// Unix socket peers have no address ("@" or "").
+			peer, err := netip.ParseAddr(host)
+			if err == nil && !isTrusted(peer) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if ip := forwardedFor(r.Header.Values("X-Forwarded-For"), isTrusted); ip.IsValid() {
+				r.RemoteAddr = ip.String()
+			} else if ip, err := netip.ParseAddr(strings.TrimSpace(r.Header.Get("X-Real-IP"))); err == nil {
+				r.RemoteAddr = ip.Unmap().String()
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// forwardedFor returns the rightmost address in X-Forwarded-For that is not a trusted proxy; the leftmost when
+// all are; invalid when there is none or an entry before it is malformed.
+func forwardedFor(values []string, isTrusted func(netip.Addr) bool) netip.Addr {
+	var hops []string
+	for _, v := range values {
+		hops = append(hops, strings.Split(v, ",")...)
+	}
+	var client netip.Addr
+	for i := len(hops) - 1; i >= 0; i-- {
+		ip, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			return netip.Addr{}
+		}
+		client = ip.Unmap()
+		if !isTrusted(client) {
+			break
+		}
+	}
+	return client
+}
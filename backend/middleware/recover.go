@@ -0,0 +1,81 @@
+package middleware
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	chimw "github.com/go-chi/chi/v5/middleware"
+	"github.com/kevinaaaquil/books/backend/logging"
+)
+
+// Recoverer answers 500 to requests whose handler panicked, logs the panic with its stack, and reports it to
+// reporter with the request's method, redacted URL, route and client. With report5xx, 5xx answers are reported
+// too, except 503s, which the API gives on purpose (maintenance, an unreachable database). reporter nil only logs.
+func Recoverer(reporter logging.Reporter, report5xx bool) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			ww := chimw.NewWrapResponseWriter(w, r.ProtoMajor)
+			defer func() {
+				rec := recover()
+				if rec == http.ErrAbortHandler {
+					panic(rec) // the server aborts the response quietly
+				}
+				status := ww.Status()
+				if rec != nil {
+					log.Printf("panic: %v\n%s", rec, debug.Stack())
+					if ww.Header().Get("Connection") != "Upgrade" && status == 0 {
+						http.Error(ww, `{"error":"internal server error"}`, http.StatusInternalServerError)
+					}
+					status = http.StatusInternalServerError
+				}
+				if reporter == nil || (rec == nil && (!report5xx || status < 500 || status == http.StatusServiceUnavailable)) {
+					return
+				}
+				e := logging.Event{
+					Time:      start,
+					Level:     "error",
+					Message:   fmt.Sprintf("%d %s", status, http.StatusText(status)),
+					Request:   requestEntry(r, start, status, ww.BytesWritten()),
+					UserAgent: r.UserAgent(),
+				}
+				if rec != nil {
+					e.Level = "fatal"
+					e.Type = fmt.Sprintf("%T", rec)
+					e.Message = fmt.Sprint(rec)
+					e.Stack = panicStack()
+				}
+				reporter.Report(e)
+			}()
+			next.ServeHTTP(ww, r)
+		})
+	}
+}
+
+// panicStack returns the stack of the goroutine that panicked, from the frame that panicked, without the
+// runtime's panic machinery and this middleware's deferred function.
+func panicStack() []runtime.Frame {
+	pcs := make([]uintptr, 64)
+	n := runtime.Callers(3, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+	var stack []runtime.Frame
+	afterPanic := false
+	for {
+		f, more := frames.Next()
+		switch {
+		case f.Function == "runtime.gopanic":
+			afterPanic = true
+		case afterPanic && (len(stack) > 0 || !strings.HasPrefix(f.Function, "runtime.")):
+			stack = append(stack, f)
+		}
+		if !more {
+			break
+		}
+	}
+	return stack
+}
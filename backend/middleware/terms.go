@@ -0,0 +1,44 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/kevinaaaquil/books/backend/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// TermsResponse is the 451 body while the user has not accepted the current terms of use.
+type TermsResponse struct {
+	Error   string `json:"error"`
+	Code    string `json:"code"`    // always "TERMS_NOT_ACCEPTED"
+	Version int    `json:"version"` // the version to accept with POST /api/me/terms
+}
+
+// termsExempt are the paths a user can reach before accepting the terms: their profile and accepting them.
+var termsExempt = map[string]bool{"/api/me": true, "/api/me/terms": true}
+
+// RequireTerms answers requests from users who have not accepted the current terms of use with 451 until they do.
+// pending returns the version the user must accept, 0 when there is none. Admins, who publish the terms, and the
+// shared guest account are let through. Use after Auth.
+func RequireTerms(pending func(ctx context.Context, userID primitive.ObjectID) int) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			userID, ok := UserIDFromContext(r.Context())
+			role := RoleFromContext(r.Context())
+			if !ok || role == models.RoleAdmin || role == models.RoleGuest || termsExempt[r.URL.Path] {
+				next.ServeHTTP(w, r)
+				return
+			}
+			version := pending(r.Context(), userID)
+			if version == 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusUnavailableForLegalReasons)
+			json.NewEncoder(w).Encode(TermsResponse{Error: "accept the terms of use to continue", Code: "TERMS_NOT_ACCEPTED", Version: version})
+		})
+	}
+}
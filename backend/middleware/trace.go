@@ -0,0 +1,35 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/kevinaaaquil/books/backend/tracing"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Trace records a span for every sampled request (see tracing.New), named by its method and route once routed
+// (GET /api/books/{id}), and continues the caller's trace when the request carries a traceparent header. The
+// database and storage calls made while serving it become its children. A nil provider traces nothing.
+func Trace(tp trace.TracerProvider) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if tp == nil {
+			return next
+		}
+		routed := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r)
+			if rc := chi.RouteContext(r.Context()); rc != nil && rc.RoutePattern() != "" {
+				span := trace.SpanFromContext(r.Context())
+				span.SetName(r.Method + " " + rc.RoutePattern())
+				span.SetAttributes(semconv.HTTPRoute(rc.RoutePattern()))
+			}
+		})
+		return otelhttp.NewHandler(routed, "",
+			otelhttp.WithTracerProvider(tp),
+			otelhttp.WithPropagators(tracing.Propagator),
+			otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string { return r.Method }),
+		)
+	}
+}
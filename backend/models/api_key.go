@@ -0,0 +1,39 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// API key scopes: what a key may be used for.
+const (
+	ScopeClip  = "clip"  // POST /api/clip, for browser extensions and other one-click savers
+	ScopeFeeds = "feeds" // GET /api/opds/{key}/..., OPDS catalog feeds for e-readers, with the key in the URL
+	ScopeSync  = "sync"  // GET /api/sync/..., for other instances mirroring this one (admins' keys only)
+	ScopeRead  = "read"  // GET and HEAD requests to the rest of the API, for scripts and e-reader integrations
+	ScopeWrite = "write" // any request to the rest of the API, but managing API keys
+)
+
+// APIKeyScopes lists the scopes a key can be given.
+var APIKeyScopes = []string{ScopeClip, ScopeFeeds, ScopeSync, ScopeRead, ScopeWrite}
+
+// APIKey lets a tool act as a user without signing in, sent in the X-API-Key header (or the URL, for feeds). Only a hash of the key is
+// stored; the key itself is shown once, when it is created. The key acts with its owner's current role.
+type APIKey struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID     primitive.ObjectID `bson:"userId" json:"-"`
+	Name       string             `bson:"name" json:"name"`
+	Prefix     string             `bson:"prefix" json:"prefix"` // the start of the key, to tell keys apart
+	KeyHash    string             `bson:"keyHash" json:"-"`     // hex SHA-256 of the key
+	Scopes     []string           `bson:"scopes" json:"scopes"`
+	CreatedAt  time.Time          `bson:"createdAt" json:"createdAt"`
+	LastUsedAt *time.Time         `bson:"lastUsedAt,omitempty" json:"lastUsedAt,omitempty"`
+	ExpiresAt  *time.Time         `bson:"expiresAt,omitempty" json:"expiresAt,omitempty"` // nil = never
+	RotatedAt  *time.Time         `bson:"rotatedAt,omitempty" json:"rotatedAt,omitempty"` // when the key was last regenerated
+}
+
+// Expired reports whether the key has expired at now.
+func (k *APIKey) Expired(now time.Time) bool {
+	return k.ExpiresAt != nil && !k.ExpiresAt.After(now)
+}
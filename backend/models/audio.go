@@ -0,0 +1,35 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Read-aloud track statuses.
+const (
+	AudioQueued     = "queued"
+	AudioGenerating = "generating"
+	AudioReady      = "ready"
+	AudioFailed     = "failed"
+)
+
+// AudioTrack is a chapter of a book read aloud, stored as an MP3 once it is AudioReady. A book has at most one
+// per chapter; it is generated in the job queue for whoever asked, and streamed to anyone who can see the book.
+type AudioTrack struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"-"`
+	BookID      primitive.ObjectID `bson:"bookId" json:"-"`
+	Chapter     int                `bson:"chapter" json:"chapter"` // index in the book's chapters (see utils.EPUBChapters)
+	Title       string             `bson:"title" json:"title"`
+	Status      string             `bson:"status" json:"status"`
+	Percent     int                `bson:"percent" json:"percent"` // of the chapter's text read so far
+	Characters  int                `bson:"characters" json:"characters"`
+	Voice       string             `bson:"voice,omitempty" json:"voice,omitempty"`
+	S3Key       string             `bson:"s3Key,omitempty" json:"-"`
+	SizeBytes   int64              `bson:"sizeBytes,omitempty" json:"sizeBytes,omitempty"`
+	Error       string             `bson:"error,omitempty" json:"error,omitempty"`
+	RequestedBy string             `bson:"requestedBy" json:"requestedBy"`
+	CreatedAt   time.Time          `bson:"createdAt" json:"createdAt"`
+	UpdatedAt   time.Time          `bson:"updatedAt" json:"updatedAt"`
+	URL         string             `bson:"-" json:"url,omitempty"` // a signed link streaming a ready track, in responses
+}
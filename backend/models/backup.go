@@ -0,0 +1,28 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Backup is one dump of the MongoDB collections stored in S3 under backups/.
+type Backup struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Key         string             `bson:"key" json:"key"`
+	SizeBytes   int64              `bson:"sizeBytes" json:"sizeBytes"`
+	Collections int                `bson:"collections" json:"collections"`
+	Documents   int                `bson:"documents" json:"documents"`
+	Trigger     string             `bson:"trigger" json:"trigger"` // "scheduled" or "manual"
+	CreatedAt   time.Time          `bson:"createdAt" json:"createdAt"`
+}
+
+// BackupStatus is returned by GET /api/admin/backups.
+type BackupStatus struct {
+	Schedule   string     `json:"schedule,omitempty"` // cron expression; empty when scheduled backups are off
+	NextRun    *time.Time `json:"nextRun,omitempty"`
+	KeepDaily  int        `json:"keepDaily"`
+	KeepWeekly int        `json:"keepWeekly"`
+	LastRun    *JobRun    `json:"lastRun,omitempty"`
+	Backups    []Backup   `json:"backups"`
+}
@@ -1,11 +1,78 @@
 package models
 
 import (
+	"path"
+	"slices"
+	"strings"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// FileStatus values set by the storage verification job.
+const (
+	FileStatusOK           = "ok"
+	FileStatusMissing      = "missing"       // book object not found in storage
+	FileStatusCorrupted    = "corrupted"     // book object present but does not match what was stored
+	FileStatusCoverMissing = "cover_missing" // book object fine, extracted cover object not found
+)
+
+// Book sources: how a book's file came into the library (Book.Source). Books added before sources were recorded
+// have none.
+const (
+	SourceUpload      = "upload"       // uploaded in the app or with the API
+	SourceURL         = "url"          // downloaded from a link saved with /api/clip
+	SourceArXiv       = "arxiv"        // imported from arXiv
+	SourceCloudImport = "cloud_import" // imported from a linked Dropbox or Google Drive folder
+	SourceWatchFolder = "watch_folder" // picked up from WATCH_DIR
+	SourceCalibre     = "calibre"      // picked up from WATCH_DIR in a Calibre library or export (next to its metadata.opf)
+	SourceTelegram    = "telegram"     // sent to the Telegram bot
+	SourceFederation  = "federation"   // mirrored from another instance (see SyncPeer)
+	SourceS3Event     = "s3_event"     // put into the bucket under S3_EVENTS_PREFIX directly, and reported by S3
+)
+
+// BookKindDocument marks papers, theses and reports, whose metadata comes from their DOI; the catalog shows them
+// as documents rather than books.
+const BookKindDocument = "document"
+
+// BookFormats are the formats book files are stored in (Book.Format), which are also their file extensions.
+var BookFormats = []string{"epub", "pdf", "mobi", "azw3"}
+
+// IsBookFile reports whether name has the extension of one of the BookFormats.
+func IsBookFile(name string) bool {
+	return slices.Contains(BookFormats, strings.TrimPrefix(strings.ToLower(path.Ext(name)), "."))
+}
+
+// FileInfo is computed from the stored book file at upload, or by the file-info backfill job for older books.
+type FileInfo struct {
+	SizeBytes     int64          `bson:"sizeBytes,omitempty" json:"sizeBytes,omitempty"`         // 0 for books uploaded before sizes were tracked
+	SHA256        string         `bson:"sha256,omitempty" json:"sha256,omitempty"`               // hex
+	WordCount     int            `bson:"wordCount,omitempty" json:"wordCount,omitempty"`         // EPUB only
+	FilePageCount int            `bson:"filePageCount,omitempty" json:"filePageCount,omitempty"` // PDF only, counted from the file (PageCount is from metadata)
+	Accessibility *Accessibility `bson:"accessibility,omitempty" json:"accessibility,omitempty"` // EPUB only, when its OPF declares any
+}
+
+// Accessibility is the schema.org accessibility metadata an EPUB declares in its OPF (EPUB Accessibility 1.x).
+// Values are as the publisher wrote them, e.g. features "alternativeText" (image descriptions), "longDescription",
+// "tableOfContents" or "synchronizedAudioText", and access modes "textual", "visual" or "auditory".
+type Accessibility struct {
+	Features              []string `bson:"features,omitempty" json:"features,omitempty"`                           // schema:accessibilityFeature
+	AccessModes           []string `bson:"accessModes,omitempty" json:"accessModes,omitempty"`                     // schema:accessMode
+	AccessModesSufficient []string `bson:"accessModesSufficient,omitempty" json:"accessModesSufficient,omitempty"` // schema:accessModeSufficient, e.g. "textual" or "textual,visual"
+	Hazards               []string `bson:"hazards,omitempty" json:"hazards,omitempty"`                             // schema:accessibilityHazard, e.g. "none" or "flashing"
+	Summary               string   `bson:"summary,omitempty" json:"summary,omitempty"`                             // schema:accessibilitySummary
+}
+
+// HasFeatures reports whether every one of features is declared, ignoring case.
+func (a *Accessibility) HasFeatures(features []string) bool {
+	for _, f := range features {
+		if a == nil || !slices.ContainsFunc(a.Features, func(g string) bool { return strings.EqualFold(g, f) }) {
+			return false
+		}
+	}
+	return true
+}
+
 type Book struct {
 	ID            primitive.ObjectID `bson:"_id,omitempty" json:"id"`
 	Title         string             `bson:"title" json:"title"`
@@ -13,6 +80,9 @@ type Book struct {
 	Publisher     string             `bson:"publisher,omitempty" json:"publisher,omitempty"`
 	PublishDate   string             `bson:"publishDate,omitempty" json:"publishDate,omitempty"`
 	ISBN          string             `bson:"isbn,omitempty" json:"isbn,omitempty"`
+	DOI           string             `bson:"doi,omitempty" json:"doi,omitempty"`         // papers and theses; found in the PDF at upload
+	Journal       string             `bson:"journal,omitempty" json:"journal,omitempty"` // where a document appeared
+	Kind          string             `bson:"kind,omitempty" json:"kind,omitempty"`       // BookKindDocument, or "" for books
 	PageCount     int                `bson:"pageCount,omitempty" json:"pageCount,omitempty"`
 	CoverURL      string             `bson:"coverUrl,omitempty" json:"coverUrl,omitempty"`
 	ThumbnailURL  string             `bson:"thumbnailUrl,omitempty" json:"thumbnailUrl,omitempty"`
@@ -22,12 +92,27 @@ type Book struct {
 	Preface       string             `bson:"preface,omitempty" json:"preface,omitempty"`
 	Category      string             `bson:"category,omitempty" json:"category,omitempty"`
 	Categories    []string           `bson:"categories,omitempty" json:"categories,omitempty"`
+	SuggestedCategories []string     `bson:"suggestedCategories,omitempty" json:"suggestedCategories,omitempty"` // from the classifier, for books without categories; applied when an editor approves them
 	RatingAverage float64            `bson:"ratingAverage,omitempty" json:"ratingAverage,omitempty"`
 	RatingCount   int                `bson:"ratingCount,omitempty" json:"ratingCount,omitempty"`
-	Format           string             `bson:"format" json:"format"`                     // "epub" or "pdf"
+	Format           string             `bson:"format" json:"format"`                     // one of BookFormats
 	S3Key            string             `bson:"s3Key" json:"-"`                         // object key in S3
 	OriginalName     string             `bson:"originalName" json:"originalName"`
+	FileInfo         `bson:",inline"`
 	UploadedByEmail  string             `bson:"uploadedByEmail,omitempty" json:"uploadedByEmail,omitempty"`
+	Source           string             `bson:"source,omitempty" json:"source,omitempty"`         // see Source* constants
+	SourceURL        string             `bson:"sourceUrl,omitempty" json:"sourceUrl,omitempty"`   // the link the file was downloaded from
+	SourcePath       string             `bson:"sourcePath,omitempty" json:"sourcePath,omitempty"` // the file's path in the drive ("dropbox:/Books/x.epub") or watch folder
 	ViewByGuest      bool               `bson:"viewByGuest" json:"viewByGuest"` // when true, guests can see this book (demo)
+	ContentRating    string             `bson:"contentRating,omitempty" json:"contentRating,omitempty"` // set by an editor; responses fall back to InferContentRating(Categories)
+	ContentRatingInferred bool          `bson:"-" json:"contentRatingInferred,omitempty"`                  // set when serializing if ContentRating was inferred
+	MetadataError    string             `bson:"metadataError,omitempty" json:"metadataError,omitempty"` // last failed metadata lookup; cleared on successful refresh
+	ParseError       string             `bson:"parseError,omitempty" json:"parseError,omitempty"`       // set when the EPUB could not be read at upload
+	FileStatus       string             `bson:"fileStatus,omitempty" json:"fileStatus,omitempty"`       // result of the last storage verification (see FileStatus* constants)
+	FileCheckedAt    *time.Time         `bson:"fileCheckedAt,omitempty" json:"fileCheckedAt,omitempty"`
+	UploadSteps      []UploadStep       `bson:"uploadSteps,omitempty" json:"uploadSteps,omitempty"` // outcome of each upload step (see UploadStep*)
+	StorageClass     string             `bson:"storageClass,omitempty" json:"storageClass,omitempty"` // S3 storage class the file was moved to by the cold storage job; "" = standard
+	ArchivedAt       *time.Time         `bson:"archivedAt,omitempty" json:"archivedAt,omitempty"`
+	RestoreRequestedAt *time.Time       `bson:"restoreRequestedAt,omitempty" json:"restoreRequestedAt,omitempty"` // last restore of the archived file started by a download
 	CreatedAt        time.Time          `bson:"createdAt" json:"createdAt"`
 }
@@ -0,0 +1,73 @@
+package models
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Book sort orders for BookQuery. Each has a natural direction (A-Z for text, newest and best rated first).
+const (
+	BookSortTitle     = "title"
+	BookSortCreatedAt = "createdAt"
+	BookSortAuthor    = "author"
+	BookSortRating    = "rating"
+)
+
+// BookQuery selects, orders and pages books (see store.BookStore.BooksPage).
+type BookQuery struct {
+	GuestOnly        bool                 // only books with ViewByGuest
+	MaxContentRating string               // only books within this rating (see ContentRatingAllowed); "" = no limit
+	Accessibility    []string             // accessibility features every book must declare
+	IDs              []primitive.ObjectID // only these books; nil = any
+	Sort             string               // a BookSort*; "" = BookSortCreatedAt
+	Desc             bool
+	Offset           int
+	Limit            int // 0 = no limit
+}
+
+// Matches reports whether b passes the query's filters.
+func (q *BookQuery) Matches(b *Book) bool {
+	if q.GuestOnly && !b.ViewByGuest {
+		return false
+	}
+	if q.IDs != nil && !slices.Contains(q.IDs, b.ID) {
+		return false
+	}
+	if len(q.Accessibility) > 0 && !b.Accessibility.HasFeatures(q.Accessibility) {
+		return false
+	}
+	rating, _ := b.EffectiveContentRating()
+	return ContentRatingAllowed(q.MaxContentRating, rating)
+}
+
+// SortBooks orders books as a BookQuery with sort and desc does: titles and first authors ignoring case, ratings
+// by average then count, and ties by ID so pages are stable.
+func SortBooks(books []Book, sort string, desc bool) {
+	firstAuthor := func(b *Book) string {
+		if len(b.Authors) == 0 {
+			return ""
+		}
+		return strings.ToLower(b.Authors[0])
+	}
+	slices.SortFunc(books, func(a, b Book) int {
+		var n int
+		switch sort {
+		case BookSortTitle:
+			n = cmp.Compare(strings.ToLower(a.Title), strings.ToLower(b.Title))
+		case BookSortAuthor:
+			n = cmp.Compare(firstAuthor(&a), firstAuthor(&b))
+		case BookSortRating:
+			n = cmp.Or(cmp.Compare(a.RatingAverage, b.RatingAverage), cmp.Compare(a.RatingCount, b.RatingCount))
+		default:
+			n = a.CreatedAt.Compare(b.CreatedAt)
+		}
+		n = cmp.Or(n, strings.Compare(a.ID.Hex(), b.ID.Hex()))
+		if desc {
+			return -n
+		}
+		return n
+	})
+}
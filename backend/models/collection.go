@@ -0,0 +1,18 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Collection is a shelf a user organizes books into, like "To Read" or "Programming". Its books are in the order
+// they were added; a book can be on any number of the user's collections.
+type Collection struct {
+	ID        primitive.ObjectID   `bson:"_id,omitempty" json:"id"`
+	UserID    primitive.ObjectID   `bson:"userId" json:"-"`
+	Name      string               `bson:"name" json:"name"`
+	BookIDs   []primitive.ObjectID `bson:"bookIds" json:"bookIds"`
+	CreatedAt time.Time            `bson:"createdAt" json:"createdAt"`
+	UpdatedAt time.Time            `bson:"updatedAt" json:"updatedAt"`
+}
@@ -0,0 +1,74 @@
+package models
+
+import (
+	"slices"
+	"strings"
+)
+
+// Content ratings, from the widest audience to the narrowest.
+const (
+	ContentRatingAll    = "all"
+	ContentRatingTeen   = "teen"
+	ContentRatingMature = "mature"
+)
+
+// ContentRatings lists the ratings in order; a user with a maximum rating sees books rated at or before it.
+var ContentRatings = []string{ContentRatingAll, ContentRatingTeen, ContentRatingMature}
+
+// ValidContentRating reports whether rating is one of ContentRatings.
+func ValidContentRating(rating string) bool {
+	return slices.Contains(ContentRatings, rating)
+}
+
+// ContentRatingCategories maps words in a book's categories to the rating they imply. A category takes the first
+// word it contains; across categories the narrowest rating wins.
+var ContentRatingCategories = []struct {
+	Word   string
+	Rating string
+}{
+	{"juvenile", ContentRatingAll},
+	{"children", ContentRatingAll},
+	{"young adult", ContentRatingTeen},
+	{"erotica", ContentRatingMature},
+	{"erotic", ContentRatingMature},
+	{"adult", ContentRatingMature}, // after "young adult", which also contains it
+}
+
+// InferContentRating guesses a rating from a book's categories (as Google Books names them, e.g. "Juvenile
+// Fiction"), or returns "" when they say nothing about the audience.
+func InferContentRating(categories []string) string {
+	level := -1
+	for _, c := range categories {
+		c = strings.ToLower(c)
+		for _, m := range ContentRatingCategories {
+			if strings.Contains(c, m.Word) {
+				level = max(level, slices.Index(ContentRatings, m.Rating))
+				break
+			}
+		}
+	}
+	if level < 0 {
+		return ""
+	}
+	return ContentRatings[level]
+}
+
+// ContentRatingAllowed reports whether a user limited to maxRating may see a book rated rating. An empty
+// maxRating means no limit; unrated books are only shown to users without one.
+func ContentRatingAllowed(maxRating, rating string) bool {
+	if maxRating == "" {
+		return true
+	}
+	level := slices.Index(ContentRatings, rating)
+	return level >= 0 && level <= slices.Index(ContentRatings, maxRating)
+}
+
+// EffectiveContentRating returns the book's rating: the one set on it, else the one inferred from its categories
+// (inferred is then true). It is "" when there is neither.
+func (b *Book) EffectiveContentRating() (rating string, inferred bool) {
+	if b.ContentRating != "" {
+		return b.ContentRating, false
+	}
+	rating = InferContentRating(b.Categories)
+	return rating, rating != ""
+}
@@ -0,0 +1,33 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Delivery target kinds. Kindle delivery uses EmailConfig rather than a target.
+const (
+	TargetEmail       = "email"
+	TargetDropbox     = "dropbox"
+	TargetGoogleDrive = "gdrive"
+)
+
+// DeliveryTarget is somewhere other than the user's Kindle that books can be sent: an email address, confirmed by
+// its recipient with a code, or a cloud drive folder linked with OAuth.
+type DeliveryTarget struct {
+	ID           primitive.ObjectID   `bson:"_id,omitempty" json:"id"`
+	UserID       primitive.ObjectID   `bson:"userId" json:"userId"`
+	Kind         string               `bson:"kind" json:"kind"`
+	Name         string               `bson:"name" json:"name"`
+	Email        string               `bson:"email,omitempty" json:"email,omitempty"`
+	Verification *AddressVerification `bson:"verification,omitempty" json:"verification,omitempty"` // email targets
+	Folder       string               `bson:"folder,omitempty" json:"folder,omitempty"`             // Dropbox path or Google Drive folder ID
+	RefreshToken string               `bson:"refreshToken,omitempty" json:"-"`                      // drive OAuth token, encrypted like the Kindle app password
+	CreatedAt    time.Time            `bson:"createdAt" json:"createdAt"`
+}
+
+// Ready reports whether books can be sent to the target (email targets must be confirmed first).
+func (t *DeliveryTarget) Ready() bool {
+	return t.Kind != TargetEmail || (t.Verification != nil && t.Verification.Verified)
+}
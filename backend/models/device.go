@@ -0,0 +1,43 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Device types.
+const (
+	DeviceKindle = "kindle"
+	DeviceKobo   = "kobo"
+	DeviceTablet = "tablet"
+	DeviceOther  = "other"
+)
+
+// DeviceFormats are the formats a device can ask for. Books not stored in one of a device's formats need a converter.
+var DeviceFormats = []string{"epub", "pdf", "mobi", "azw3"}
+
+// Device is a reading device the user sends books to, with the formats it takes (preferred first) and the
+// largest attachment its delivery accepts.
+type Device struct {
+	ID              primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID          primitive.ObjectID `bson:"userId" json:"userId"`
+	Name            string             `bson:"name" json:"name"`
+	Type            string             `bson:"type" json:"type"`
+	Formats         []string           `bson:"formats" json:"formats"`
+	MaxAttachmentMB int                `bson:"maxAttachmentMB,omitempty" json:"maxAttachmentMB,omitempty"` // 0 = no limit
+	// TargetID is the DeliveryTarget books for this device go to; zero = the user's Kindle address.
+	TargetID primitive.ObjectID `bson:"targetId,omitempty" json:"targetId,omitempty"`
+	// Optimize strips fonts and downscales images from every EPUB sent to the device, not only oversized ones.
+	Optimize  bool      `bson:"optimize,omitempty" json:"optimize,omitempty"`
+	CreatedAt time.Time `bson:"createdAt" json:"createdAt"`
+}
+
+// DefaultDeviceProfile returns the formats and attachment limit used for a device type when the user gives none.
+// Kindle's is also applied to sends to the Kindle address without a device.
+func DefaultDeviceProfile(deviceType string) (formats []string, maxAttachmentMB int) {
+	if deviceType == DeviceKindle {
+		return []string{"epub", "pdf"}, 50 // Send to Kindle's limit
+	}
+	return []string{"epub", "pdf"}, 0
+}
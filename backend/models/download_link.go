@@ -0,0 +1,32 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Download link kinds.
+const (
+	DownloadLinkPresigned = "presigned" // storage's own URL (S3 presigned, or LocalStorage signed)
+	DownloadLinkStream    = "stream"    // signed URL to the API's own stream endpoint
+	DownloadLinkFeed      = "feed"      // file streamed to an e-reader from an OPDS feed; expires as it is issued
+	DownloadLinkBundle    = "bundle"    // signed URL to a ZIP of a collection (see handlers.DownloadBundle)
+	DownloadLinkSync      = "sync"      // URL handed to another instance mirroring this one (see models.SyncPeer)
+)
+
+// DownloadLink records a download URL the API issued, so admins can audit who could fetch which book and until when.
+type DownloadLink struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	BookID    primitive.ObjectID `bson:"bookId" json:"bookId"`
+	UserID    primitive.ObjectID `bson:"userId" json:"userId"`
+	Email     string             `bson:"email,omitempty" json:"email,omitempty"`
+	Role      string             `bson:"role" json:"role"`
+	Kind      string             `bson:"kind" json:"kind"`
+	IP        string             `bson:"ip,omitempty" json:"ip,omitempty"`
+	IssuedAt  time.Time          `bson:"issuedAt" json:"issuedAt"`
+	ExpiresAt time.Time          `bson:"expiresAt" json:"expiresAt"`
+	// Bytes is the size of the book's file when the link was issued, counted as downloaded from storage for
+	// presigned links (see StorageTrends).
+	Bytes int64 `bson:"bytes,omitempty" json:"bytes,omitempty"`
+}
@@ -1,13 +1,33 @@
 package models
 
-import "go.mongodb.org/mongo-driver/bson/primitive"
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
 
 // EmailConfig holds iCloud/Kindle email settings for sending books. Each document has its own _id and a userId linking to the user.
 type EmailConfig struct {
-	ID                  primitive.ObjectID `bson:"_id,omitempty" json:"id"`
-	UserID              primitive.ObjectID `bson:"userId" json:"userId"`
-	AppSpecificPassword string             `bson:"appSpecificPassword" json:"appSpecificPassword"`
-	ICloudMail          string             `bson:"icloudMail" json:"icloudMail"`
-	SenderMail          string             `bson:"senderMail" json:"senderMail"`
-	KindleMail          string             `bson:"kindleMail" json:"kindleMail"`
+	ID                  primitive.ObjectID   `bson:"_id,omitempty" json:"id"`
+	UserID              primitive.ObjectID   `bson:"userId" json:"userId"`
+	AppSpecificPassword string               `bson:"appSpecificPassword" json:"appSpecificPassword"`
+	ICloudMail          string               `bson:"icloudMail" json:"icloudMail"`
+	SenderMail          string               `bson:"senderMail" json:"senderMail"`
+	KindleMail          string               `bson:"kindleMail" json:"kindleMail"`
+	KindleVerification  *AddressVerification `bson:"kindleVerification,omitempty" json:"kindleVerification,omitempty"` // nil for configs saved before verification existed
+}
+
+// AddressVerification tracks confirming an address (a Kindle, or an email delivery target) with a code sent to it.
+// It is reset whenever the address changes.
+type AddressVerification struct {
+	Verified   bool       `bson:"verified" json:"verified"`
+	VerifiedAt *time.Time `bson:"verifiedAt,omitempty" json:"verifiedAt,omitempty"`
+	CodeHash   string     `bson:"codeHash,omitempty" json:"-"` // hex SHA-256 of the last code sent
+	SentAt     *time.Time `bson:"sentAt,omitempty" json:"sentAt,omitempty"`
+	Attempts   int        `bson:"attempts,omitempty" json:"-"` // wrong codes entered since SentAt
+}
+
+// KindleVerified reports whether KindleMail has been confirmed (or predates verification).
+func (c *EmailConfig) KindleVerified() bool {
+	return c.KindleVerification == nil || c.KindleVerification.Verified
 }
@@ -6,7 +6,7 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
-// EmailLog records a book sent to a Kindle email by a user.
+// EmailLog records a book sent by a user, to their Kindle or to another delivery target.
 type EmailLog struct {
 	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
 	BookID    primitive.ObjectID `bson:"bookId" json:"bookId"`
@@ -15,4 +15,41 @@ type EmailLog struct {
 	UserID    primitive.ObjectID `bson:"userId" json:"userId"`
 	UserEmail string             `bson:"userEmail" json:"userEmail"`
 	SentAt    time.Time          `bson:"sentAt" json:"sentAt"`
+	// TargetID and Target (its kind) are set for sends to a DeliveryTarget rather than the Kindle.
+	TargetID primitive.ObjectID `bson:"targetId,omitempty" json:"targetId,omitempty"`
+	Target   string             `bson:"target,omitempty" json:"target,omitempty"`
+	DeviceID primitive.ObjectID `bson:"deviceId,omitempty" json:"deviceId,omitempty"` // set when sent for a Device
+	Format   string             `bson:"format,omitempty" json:"format,omitempty"`     // set when converted for the device
+	// Optimized is set when fonts and large images were stripped to fit email limits.
+	Optimized bool `bson:"optimized,omitempty" json:"optimized,omitempty"`
+}
+
+// SendLimit caps how many books a user may send to Kindle in a rolling hour and day. 0 = unlimited.
+type SendLimit struct {
+	Hourly int `json:"hourly"`
+	Daily  int `json:"daily"`
+}
+
+// Send usage statuses, from SendUsage.Status.
+const (
+	SendUsageOK      = "ok"
+	SendUsageNear    = "near"    // at 80% or more of a limit
+	SendUsageLimited = "limited" // sends are currently refused
+)
+
+// SendUsage is a user's recent Kindle sends against their role's limit.
+type SendUsage struct {
+	UserID   primitive.ObjectID `json:"userId"`
+	Email    string             `json:"email"`
+	Role     string             `json:"role"`
+	LastHour int                `json:"lastHour"`
+	LastDay  int                `json:"lastDay"`
+	Limit    SendLimit          `json:"limit"`
+	Status   string             `json:"status"`
+}
+
+// SendUsageReport is returned by GET /api/admin/send-usage.
+type SendUsageReport struct {
+	Limits map[string]SendLimit `json:"limits"` // by role
+	Users  []SendUsage          `json:"users"`
 }
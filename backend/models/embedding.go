@@ -0,0 +1,18 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// BookEmbedding is a book's embedding for semantic search: a vector for its title, authors, categories, description
+// and the start of its text, from Model. A book has at most one; TextHash tells whether the book changed since.
+type BookEmbedding struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"-"`
+	BookID    primitive.ObjectID `bson:"bookId" json:"bookId"`
+	Model     string             `bson:"model" json:"model"`
+	TextHash  string             `bson:"textHash" json:"textHash"` // hex SHA-256 of the text embedded
+	Vector    []float32          `bson:"vector" json:"-"`
+	UpdatedAt time.Time          `bson:"updatedAt" json:"updatedAt"`
+}
@@ -0,0 +1,38 @@
+package models
+
+// Node types in a BookGraph.
+const (
+	GraphNodeBook     = "book"
+	GraphNodeAuthor   = "author"
+	GraphNodeCategory = "category"
+)
+
+// Edge types in a BookGraph.
+const (
+	GraphEdgeWrote      = "wrote"       // author -> book
+	GraphEdgeInCategory = "in_category" // book -> category
+)
+
+// GraphNode is a book, author or category. IDs are "book:<id>", "author:<name>" and "category:<name>", with
+// names lowercased so spellings that differ only in case are one node.
+type GraphNode struct {
+	ID    string `json:"id"`
+	Type  string `json:"type"`
+	Label string `json:"label"`
+	Year  string `json:"year,omitempty"`  // books: publication year, when known
+	Books int    `json:"books,omitempty"` // authors and categories: how many books link to them
+}
+
+type GraphEdge struct {
+	Source string `json:"source"`
+	Target string `json:"target"`
+	Type   string `json:"type"`
+}
+
+// BookGraph is the library as a graph of books, their authors and their categories, for visualization tools.
+// Truncated is set when the library had more books than the export allows.
+type BookGraph struct {
+	Nodes     []GraphNode `json:"nodes"`
+	Edges     []GraphEdge `json:"edges"`
+	Truncated bool        `json:"truncated"`
+}
@@ -0,0 +1,50 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Library health issue codes reported by GET /api/admin/library/health.
+const (
+	IssueMissingCover   = "missing_cover"
+	IssueMissingISBN    = "missing_isbn"
+	IssueZeroPageCount  = "zero_page_count"
+	IssueMetadataFailed = "metadata_lookup_failed"
+	IssueUnreadableEPUB = "unreadable_epub"
+	IssueStorageProblem = "storage_problem"
+	IssueSmallFile      = "small_file"
+	IssueUploadFailed   = "upload_step_failed"
+)
+
+// BookRef is a minimal book reference used in reports.
+type BookRef struct {
+	ID     primitive.ObjectID `bson:"_id" json:"id"`
+	Title  string             `bson:"title" json:"title"`
+	Format string             `bson:"format" json:"format"`
+}
+
+// Remediation suggests how an admin can fix a health issue. Href may contain {id}, to be replaced with the book ID.
+type Remediation struct {
+	Action      string `json:"action"`
+	Description string `json:"description"`
+	Method      string `json:"method,omitempty"`
+	Href        string `json:"href,omitempty"`
+}
+
+// LibraryHealthCheck lists the books failing one health check.
+type LibraryHealthCheck struct {
+	Issue       string      `json:"issue"`
+	Description string      `json:"description"`
+	Remediation Remediation `json:"remediation"`
+	Count       int         `json:"count"`
+	Books       []BookRef   `json:"books"`
+}
+
+// LibraryHealthReport is the admin library health report.
+type LibraryHealthReport struct {
+	GeneratedAt time.Time            `json:"generatedAt"`
+	TotalBooks  int64                `json:"totalBooks"`
+	Checks      []LibraryHealthCheck `json:"checks"`
+}
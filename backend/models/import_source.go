@@ -0,0 +1,39 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ImportSource is a folder in a user's cloud drive (TargetDropbox or TargetGoogleDrive) whose ebook files are
+// imported into the library, on demand or by the import schedule.
+type ImportSource struct {
+	ID           primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID       primitive.ObjectID `bson:"userId" json:"userId"` // who linked the drive; imported books are uploaded by them
+	Kind         string             `bson:"kind" json:"kind"`
+	Name         string             `bson:"name" json:"name"`
+	Folder       string             `bson:"folder" json:"folder"`            // Dropbox path or Google Drive folder ID; "" = the whole drive
+	RefreshToken string             `bson:"refreshToken,omitempty" json:"-"` // drive OAuth token, encrypted like delivery targets'
+	AutoSync     bool               `bson:"autoSync" json:"autoSync"`        // synced by IMPORT_SCHEDULE
+	Files        []ImportedFile     `bson:"files,omitempty" json:"-"`        // files already dealt with
+	LastSync     *ImportSync        `bson:"lastSync,omitempty" json:"lastSync,omitempty"`
+	CreatedAt    time.Time          `bson:"createdAt" json:"createdAt"`
+}
+
+// ImportedFile is a drive file an import has dealt with, at the revision it saw. It is looked at again only once
+// its revision changes.
+type ImportedFile struct {
+	FileID   string             `bson:"fileId" json:"fileId"`
+	Revision string             `bson:"revision" json:"revision"`
+	BookID   primitive.ObjectID `bson:"bookId" json:"bookId"` // the book it was imported as, or the existing copy it duplicates
+}
+
+// ImportSync is the outcome of a source's latest sync.
+type ImportSync struct {
+	At         time.Time `bson:"at" json:"at"`
+	Imported   int       `bson:"imported" json:"imported"`
+	Duplicates int       `bson:"duplicates" json:"duplicates"` // already in the library (same SHA-256)
+	Failed     int       `bson:"failed" json:"failed"`         // tried again at the next sync
+	Error      string    `bson:"error,omitempty" json:"error,omitempty"`
+}
@@ -0,0 +1,26 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// IPBlock refuses every API request from an address or range, e.g. a scraper's. Admins add them.
+type IPBlock struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Range     string             `bson:"range" json:"range"` // a CIDR prefix; single addresses are /32 or /128
+	Reason    string             `bson:"reason,omitempty" json:"reason,omitempty"`
+	CreatedBy string             `bson:"createdBy" json:"createdBy"` // email
+	CreatedAt time.Time          `bson:"createdAt" json:"createdAt"`
+	ExpiresAt *time.Time         `bson:"expiresAt,omitempty" json:"expiresAt,omitempty"` // nil = until removed
+}
+
+// FlaggedIP is an address refused for a while for making requests faster than a person would (see
+// middleware.BurstGuard). Flags are per instance and not stored.
+type FlaggedIP struct {
+	IP        string    `json:"ip"`
+	Requests  int       `json:"requests"` // in the window that tripped the guard
+	FlaggedAt time.Time `json:"flaggedAt"`
+	Until     time.Time `json:"until"`
+}
@@ -0,0 +1,71 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Job run statuses.
+const (
+	JobStatusRunning     = "running"
+	JobStatusSucceeded   = "succeeded"
+	JobStatusFailed      = "failed"
+	JobStatusInterrupted = "interrupted" // stopped by a shutdown; resumable jobs continue from Checkpoint at the next start
+	JobStatusCancelled   = "cancelled"   // stopped by an admin; not resumed
+)
+
+// JobRun records one execution of a background admin job (e.g. storage verification) and its progress.
+type JobRun struct {
+	ID          primitive.ObjectID  `bson:"_id,omitempty" json:"id"`
+	Type        string              `bson:"type" json:"type"`
+	Status      string              `bson:"status" json:"status"`
+	Total       int                 `bson:"total" json:"total"`
+	Processed   int                 `bson:"processed" json:"processed"`
+	Summary     map[string]int      `bson:"summary,omitempty" json:"summary,omitempty"` // job-specific counters, e.g. {"missing": 2}
+	Error       string              `bson:"error,omitempty" json:"error,omitempty"`
+	StartedBy   string              `bson:"startedBy,omitempty" json:"startedBy,omitempty"`
+	Instance    string              `bson:"instance,omitempty" json:"instance,omitempty"` // API instance that ran it
+	StartedAt   time.Time           `bson:"startedAt" json:"startedAt"`
+	FinishedAt  *time.Time          `bson:"finishedAt,omitempty" json:"finishedAt,omitempty"`
+	Params      map[string]string   `bson:"params,omitempty" json:"params,omitempty"`           // job options, kept so an interrupted run can be resumed
+	Checkpoint  string              `bson:"checkpoint,omitempty" json:"checkpoint,omitempty"`   // last item processed, in the job's own format
+	ResumedFrom *primitive.ObjectID `bson:"resumedFrom,omitempty" json:"resumedFrom,omitempty"` // the interrupted run this one continues
+	Log         []JobLogLine        `bson:"log,omitempty" json:"log,omitempty"`                 // the run's latest messages, oldest first
+	// CancelRequested is set by an admin cancelling the run; the instance running it stops it.
+	CancelRequested bool `bson:"cancelRequested,omitempty" json:"cancelRequested,omitempty"`
+}
+
+// JobLogLine is a message a job run logged.
+type JobLogLine struct {
+	Time    time.Time `bson:"time" json:"time"`
+	Message string    `bson:"message" json:"message"`
+}
+
+// JobRunFilter selects job runs for a history listing, newest first.
+type JobRunFilter struct {
+	Type   string     // "" = any
+	Status string     // "" = any
+	Before *time.Time // runs started before this; nil = from the newest
+	Since  *time.Time // runs started at or after this; nil = any
+	Limit  int        // 0 = 50, or every run when Since is set
+}
+
+// JobType describes a job admins can start from GET /api/admin/jobs.
+type JobType struct {
+	Type        string     `json:"type"`
+	Description string     `json:"description"`
+	Params      []JobParam `json:"params"`
+	Available   bool       `json:"available"`
+	Unavailable string     `json:"unavailable,omitempty"` // why the job can't run, e.g. storage is not configured
+	Schedule    string     `json:"schedule,omitempty"`    // cron schedule it also runs on
+	LastRun     *JobRun    `json:"lastRun,omitempty"`
+}
+
+// JobParam is an option a job is started with.
+type JobParam struct {
+	Name        string `json:"name"`
+	Type        string `json:"type"` // "bool"
+	Description string `json:"description"`
+	Default     string `json:"default"`
+}
@@ -0,0 +1,10 @@
+package models
+
+import "time"
+
+// Lock is a lease on a named task (a job type, the scheduler) held by one API instance until ExpiresAt.
+type Lock struct {
+	Name      string    `bson:"_id" json:"name"`
+	Owner     string    `bson:"owner" json:"owner"` // instance ID, see jobs.InstanceID
+	ExpiresAt time.Time `bson:"expiresAt" json:"expiresAt"`
+}
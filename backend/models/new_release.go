@@ -0,0 +1,24 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// NewRelease is a recently published book by an author a user has finished a book by, found by the new releases
+// job in the metadata provider and not in the library.
+type NewRelease struct {
+	ID           primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID       primitive.ObjectID `bson:"userId" json:"-"`
+	Key          string             `bson:"key" json:"-"`         // author and title, lowercased; a user has one release per key
+	Author       string             `bson:"author" json:"author"` // the author it was found for
+	ISBN         string             `bson:"isbn,omitempty" json:"isbn,omitempty"`
+	Title        string             `bson:"title" json:"title"`
+	Authors      []string           `bson:"authors,omitempty" json:"authors,omitempty"`
+	Publisher    string             `bson:"publisher,omitempty" json:"publisher,omitempty"`
+	PublishDate  string             `bson:"publishDate,omitempty" json:"publishDate,omitempty"`
+	CoverURL     string             `bson:"coverUrl,omitempty" json:"coverUrl,omitempty"`
+	ThumbnailURL string             `bson:"thumbnailUrl,omitempty" json:"thumbnailUrl,omitempty"`
+	FoundAt      time.Time          `bson:"foundAt" json:"foundAt"`
+}
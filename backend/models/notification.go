@@ -0,0 +1,27 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Notification kinds.
+const (
+	NotificationBackupFailed = "backup_failed"
+	NotificationNewReleases  = "new_releases"
+	NotificationPriceDrop    = "price_drop"
+	NotificationStorageQuota = "storage_quota"
+)
+
+// Notification is an in-app message for one user (e.g. an admin alert that a scheduled backup failed).
+type Notification struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID    primitive.ObjectID `bson:"userId" json:"userId"`
+	Kind      string             `bson:"kind" json:"kind"`
+	Title     string             `bson:"title" json:"title"`
+	Body      string             `bson:"body,omitempty" json:"body,omitempty"`
+	Link      string             `bson:"link,omitempty" json:"link,omitempty"` // API path with more detail, e.g. /api/admin/backups
+	CreatedAt time.Time          `bson:"createdAt" json:"createdAt"`
+	ReadAt    *time.Time         `bson:"readAt,omitempty" json:"readAt,omitempty"`
+}
@@ -0,0 +1,19 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// PricePoint is a price a provider quoted for a watched wishlist item at one check.
+type PricePoint struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"-"`
+	ItemID    primitive.ObjectID `bson:"itemId" json:"-"`
+	UserID    primitive.ObjectID `bson:"userId" json:"-"`
+	Provider  string             `bson:"provider" json:"provider"`
+	Price     float64            `bson:"price" json:"price"`
+	Currency  string             `bson:"currency" json:"currency"`
+	URL       string             `bson:"url,omitempty" json:"url,omitempty"`
+	CheckedAt time.Time          `bson:"checkedAt" json:"checkedAt"`
+}
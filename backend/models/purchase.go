@@ -0,0 +1,38 @@
+package models
+
+import (
+	"slices"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Purchase records where and how a book's copy was bought, as proof of ownership. One per book, keyed by its ID;
+// only admins and the book's uploader can see it.
+type Purchase struct {
+	BookID       primitive.ObjectID `bson:"_id" json:"bookId"`
+	Store        string             `bson:"store,omitempty" json:"store,omitempty"`
+	PurchasedOn  string             `bson:"purchasedOn,omitempty" json:"purchasedOn,omitempty"` // YYYY-MM-DD
+	OrderID      string             `bson:"orderId,omitempty" json:"orderId,omitempty"`
+	Price        float64            `bson:"price,omitempty" json:"price,omitempty"`
+	Currency     string             `bson:"currency,omitempty" json:"currency,omitempty"` // ISO 4217
+	LicenseNotes string             `bson:"licenseNotes,omitempty" json:"licenseNotes,omitempty"`
+	UpdatedBy    string             `bson:"updatedBy" json:"updatedBy"` // email
+	UpdatedAt    time.Time          `bson:"updatedAt" json:"updatedAt"`
+}
+
+// SortPurchases orders purchases oldest purchase first, those without a date last.
+func SortPurchases(purchases []Purchase) {
+	slices.SortStableFunc(purchases, func(a, b Purchase) int {
+		switch {
+		case a.PurchasedOn == b.PurchasedOn:
+			return a.UpdatedAt.Compare(b.UpdatedAt)
+		case a.PurchasedOn == "":
+			return 1
+		case b.PurchasedOn == "":
+			return -1
+		}
+		return strings.Compare(a.PurchasedOn, b.PurchasedOn)
+	})
+}
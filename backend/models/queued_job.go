@@ -0,0 +1,41 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Queued job statuses.
+const (
+	QueuedJobQueued    = "queued" // waiting for a worker, or for its next attempt (see RunAt)
+	QueuedJobRunning   = "running"
+	QueuedJobSucceeded = "succeeded"
+	QueuedJobFailed    = "failed" // out of attempts, or failed in a way another attempt can't fix
+)
+
+// QueuedJob is work a user asked for that runs in the background (e.g. sending a book to a Kindle), picked up by
+// the job queue's workers on any instance and retried with backoff when an attempt fails transiently.
+type QueuedJob struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Kind        string             `bson:"kind" json:"kind"`
+	UserID      primitive.ObjectID `bson:"userId" json:"-"`
+	Params      map[string]string  `bson:"params,omitempty" json:"-"` // the handler's input, in its own format
+	Status      string             `bson:"status" json:"status"`
+	Attempts    int                `bson:"attempts" json:"attempts"` // attempts started, including a running one
+	MaxAttempts int                `bson:"maxAttempts" json:"maxAttempts"`
+	RunAt       time.Time          `bson:"runAt" json:"runAt"` // when a queued job is due
+	// LeaseUntil is when a running job is given up for lost with its instance, and runs again.
+	LeaseUntil *time.Time        `bson:"leaseUntil,omitempty" json:"-"`
+	Instance   string            `bson:"instance,omitempty" json:"-"`
+	Error      string            `bson:"error,omitempty" json:"error,omitempty"` // the last failed attempt's
+	Result     map[string]string `bson:"result,omitempty" json:"result,omitempty"`
+	CreatedAt  time.Time         `bson:"createdAt" json:"createdAt"`
+	UpdatedAt  time.Time         `bson:"updatedAt" json:"updatedAt"`
+	FinishedAt *time.Time        `bson:"finishedAt,omitempty" json:"finishedAt,omitempty"`
+}
+
+// Pending reports whether the job has yet to succeed or fail.
+func (j *QueuedJob) Pending() bool {
+	return j.Status == QueuedJobQueued || j.Status == QueuedJobRunning
+}
@@ -0,0 +1,29 @@
+package models
+
+// Endpoint classes with their own rate limits: requests that are expensive for S3 or the database.
+const (
+	RateSearch    = "search"    // GET /api/books?q=
+	RateCovers    = "covers"    // GET /api/books/{id}/cover
+	RateDetails   = "details"   // GET /api/books/{id}
+	RateDownloads = "downloads" // download links and streamed files
+	RateMetadata  = "metadata"  // metadata refresh (calls Google Books)
+	RateLookup    = "lookup"    // GET /api/lookup, the public metadata lookup
+	RateStats     = "stats"     // GET /api/public/stats and the badge
+	RateAssistant = "assistant" // POST /api/ask (calls a chat model)
+)
+
+// RateClasses lists the endpoint classes.
+var RateClasses = []string{RateSearch, RateCovers, RateDetails, RateDownloads, RateMetadata, RateLookup, RateStats, RateAssistant}
+
+// RateLimits are requests per minute by role, then endpoint class; 0 = unlimited. Requests without a token
+// (cover images, signed file links, public lookups and stats) count under RoleGuest.
+type RateLimits map[string]map[string]int
+
+// RateLimitStat counts requests to one endpoint class by one role since the server started.
+type RateLimitStat struct {
+	Class   string `json:"class"`
+	Role    string `json:"role"`
+	Limit   int    `json:"limit"` // per minute; 0 = unlimited
+	Allowed uint64 `json:"allowed"`
+	Limited uint64 `json:"limited"` // answered with 429
+}
@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ReadingProgress is a user's latest position in a book, as last reported by the web reader or a device.
+// Each user has at most one per book.
+type ReadingProgress struct {
+	ID     primitive.ObjectID `bson:"_id,omitempty" json:"-"`
+	UserID primitive.ObjectID `bson:"userId" json:"-"`
+	BookID primitive.ObjectID `bson:"bookId" json:"bookId"`
+	// Anchor is the web reader position: an EPUB CFI, or "page=N" for PDFs.
+	Anchor         string    `bson:"anchor,omitempty" json:"anchor,omitempty"`
+	KindleLocation int       `bson:"kindleLocation,omitempty" json:"kindleLocation,omitempty"`
+	Percent        float64   `bson:"percent" json:"percent"`                   // 0-100
+	Device         string    `bson:"device,omitempty" json:"device,omitempty"` // what reported it, e.g. "web" or a device name
+	UpdatedAt      time.Time `bson:"updatedAt" json:"updatedAt"`
+}
@@ -0,0 +1,25 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// FinishedPercent is the reading progress at which a book counts as finished, for recommendations.
+const FinishedPercent = 95
+
+// Recommendation is a book suggested to a user, with why it was suggested.
+type Recommendation struct {
+	BookID primitive.ObjectID `bson:"bookId" json:"bookId"`
+	Score  float64            `bson:"score" json:"score"`
+	Reason string             `bson:"reason" json:"reason"` // e.g. "Because you finished 2 books by Ursula K. Le Guin"
+}
+
+// UserRecommendations are a user's recommendations as last computed, best first. Each user has at most one.
+type UserRecommendations struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"-"`
+	UserID     primitive.ObjectID `bson:"userId" json:"-"`
+	Items      []Recommendation   `bson:"items" json:"items"`
+	ComputedAt time.Time          `bson:"computedAt" json:"computedAt"`
+}
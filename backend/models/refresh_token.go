@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// RefreshToken is one of the refresh tokens of a sign-in, exchanged at POST /api/auth/refresh for a new access
+// token and a new refresh token of the same family. Only a hash of the token is stored. A token used a second
+// time has leaked, and revokes its whole family.
+type RefreshToken struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID    primitive.ObjectID `bson:"userId" json:"userId"`
+	Family    primitive.ObjectID `bson:"family" json:"family"` // the sign-in: the ID of its first token
+	TokenHash string             `bson:"tokenHash" json:"-"`   // hex SHA-256 of the token
+	CreatedAt time.Time          `bson:"createdAt" json:"createdAt"`
+	ExpiresAt time.Time          `bson:"expiresAt" json:"expiresAt"`
+	UsedAt    *time.Time         `bson:"usedAt,omitempty" json:"usedAt,omitempty"`       // when it was exchanged
+	RevokedAt *time.Time         `bson:"revokedAt,omitempty" json:"revokedAt,omitempty"` // by sign-out, reuse or a password reset
+}
@@ -0,0 +1,44 @@
+package models
+
+import "time"
+
+// SettingsID is the _id of the one settings document.
+const SettingsID = "app"
+
+// Settings are server-wide settings admins change at runtime. They are stored, so every instance sees them.
+type Settings struct {
+	ID          string      `bson:"_id" json:"-"`
+	Maintenance Maintenance `bson:"maintenance" json:"maintenance"`
+	// PresignedDownloadsDisabled makes downloads stream through the API instead of handing out storage URLs,
+	// whatever DOWNLOAD_MODE says, so no link to the bucket leaves the server.
+	PresignedDownloadsDisabled bool `bson:"presignedDownloadsDisabled,omitempty" json:"presignedDownloadsDisabled"`
+	// PublicStats publishes library-wide totals at /api/public/stats and as a badge at /api/public/stats.svg.
+	PublicStats bool `bson:"publicStats,omitempty" json:"publicStats"`
+	// Terms are the terms of use every user but admins and guests must accept before using the API.
+	Terms Terms `bson:"terms,omitempty" json:"terms"`
+	// TelemetryID is the instance's TelemetryReport.InstanceID, made by the telemetry job.
+	TelemetryID string `bson:"telemetryId,omitempty" json:"-"`
+	// StorageAlerts is kept by the storage alerts job, not set by admins.
+	StorageAlerts StorageAlerts `bson:"storageAlerts,omitempty" json:"storageAlerts"`
+	UpdatedAt   time.Time   `bson:"updatedAt,omitempty" json:"updatedAt,omitempty"`
+	UpdatedBy   string      `bson:"updatedBy,omitempty" json:"updatedBy,omitempty"` // admin email
+}
+
+// Maintenance mode: while Enabled, the API answers everyone but admins with 503.
+type Maintenance struct {
+	Enabled bool   `bson:"enabled" json:"enabled"`
+	Message string `bson:"message,omitempty" json:"message,omitempty"` // shown to users, e.g. "Restoring a backup"
+	// RetryAfter is the Retry-After sent with the 503, in seconds; 0 uses DefaultMaintenanceRetryAfter.
+	RetryAfter int        `bson:"retryAfter,omitempty" json:"retryAfter,omitempty"`
+	Since      *time.Time `bson:"since,omitempty" json:"since,omitempty"`
+}
+
+// Terms of use, set by admins. None while Text is empty.
+type Terms struct {
+	Text      string     `bson:"text,omitempty" json:"text"`
+	Version   int        `bson:"version,omitempty" json:"version"` // bumped on every change to Text, so users accept it again
+	UpdatedAt *time.Time `bson:"updatedAt,omitempty" json:"updatedAt,omitempty"`
+}
+
+// DefaultMaintenanceRetryAfter is the Retry-After, in seconds, when maintenance does not set one.
+const DefaultMaintenanceRetryAfter = 300
@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// SMTPPoolStat reports one SMTP account's pooled connections and sends since the server started.
+type SMTPPoolStat struct {
+	Host         string     `json:"host"`
+	Port         int        `json:"port"`
+	Username     string     `json:"username"`
+	Open         int        `json:"open"` // connections open now, sending or idle
+	Idle         int        `json:"idle"`
+	Dials        uint64     `json:"dials"`        // connections opened
+	DialFailures uint64     `json:"dialFailures"` // connections that failed to open or log in
+	Reuses       uint64     `json:"reuses"`       // sends on a connection left open by an earlier one
+	Sends        uint64     `json:"sends"`
+	Failures     uint64     `json:"failures"` // sends that failed
+	LastError    string     `json:"lastError,omitempty"`
+	LastErrorAt  *time.Time `json:"lastErrorAt,omitempty"`
+	LastSendAt   *time.Time `json:"lastSendAt,omitempty"`
+}
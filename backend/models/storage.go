@@ -0,0 +1,66 @@
+package models
+
+// StorageBucket is the bytes and book count for one format or uploader.
+type StorageBucket struct {
+	Key   string `bson:"_id" json:"key"`
+	Bytes int64  `bson:"bytes" json:"bytes"`
+	Books int    `bson:"books" json:"books"`
+}
+
+// StorageGrowthPoint is the bytes added in one month (by book createdAt) and the running total up to that month.
+type StorageGrowthPoint struct {
+	Month           string `bson:"_id" json:"month"`
+	AddedBytes      int64  `bson:"bytes" json:"addedBytes"`
+	Books           int    `bson:"books" json:"books"`
+	CumulativeBytes int64  `bson:"-" json:"cumulativeBytes"`
+}
+
+// StorageUsage is the admin storage breakdown. Books uploaded before sizes were tracked are counted in UnknownSizeBooks.
+type StorageUsage struct {
+	TotalBytes       int64                `json:"totalBytes"`
+	TotalBooks       int                  `json:"totalBooks"`
+	UnknownSizeBooks int                  `json:"unknownSizeBooks"`
+	ByFormat         []StorageBucket      `json:"byFormat"`
+	ByUploader       []StorageBucket      `json:"byUploader"` // per-user usage, keyed by uploader email
+	Growth           []StorageGrowthPoint `json:"growth"`
+}
+
+// DownloadMonth is the bytes handed out as presigned download links in one month (by IssuedAt, UTC).
+type DownloadMonth struct {
+	Month string `bson:"_id" json:"month"`
+	Bytes int64  `bson:"bytes" json:"bytes"`
+	Links int    `bson:"links" json:"links"`
+}
+
+// StorageMonth is one month of StorageTrends.
+type StorageMonth struct {
+	Month         string `json:"month"`
+	AddedBytes    int64  `json:"addedBytes"`
+	StoredBytes   int64  `json:"storedBytes"` // at the end of the month
+	DownloadBytes int64  `json:"downloadBytes"`
+	Downloads     int    `json:"downloads"`
+}
+
+// StorageTrends is stored and downloaded bytes against the soft quotas, with the last months' history.
+// Percentages are of the quota and are 0 when there is none.
+type StorageTrends struct {
+	StoredBytes         int64          `json:"storedBytes"`
+	StorageQuotaBytes   int64          `json:"storageQuotaBytes,omitempty"`
+	StoragePercent      float64        `json:"storagePercent,omitempty"`
+	MonthDownloadBytes  int64          `json:"monthDownloadBytes"` // this month so far
+	DownloadQuotaBytes  int64          `json:"downloadQuotaBytes,omitempty"`
+	DownloadPercent     float64        `json:"downloadPercent,omitempty"`
+	ProjectedMonthBytes int64          `json:"projectedMonthBytes"` // this month's downloads at the pace so far
+	AlertPercent        int            `json:"alertPercent,omitempty"`
+	Alerts              StorageAlerts  `json:"alerts"`
+	Months              []StorageMonth `json:"months"` // oldest first
+}
+
+// StorageAlerts records the storage quota alerts admins were sent, so each is sent once: the level reached is
+// the alert percent or 100 (over quota). The storage level drops when usage falls back under it; the download
+// level starts over each month.
+type StorageAlerts struct {
+	StorageLevel  int    `bson:"storageLevel,omitempty" json:"storageLevel,omitempty"`
+	DownloadMonth string `bson:"downloadMonth,omitempty" json:"downloadMonth,omitempty"` // "2006-01"
+	DownloadLevel int    `bson:"downloadLevel,omitempty" json:"downloadLevel,omitempty"`
+}
@@ -0,0 +1,73 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// SyncPeer is another instance of the app whose books (in the chosen collections) and reading progress this one
+// mirrors, by pulling them through the peer's /api/sync endpoints with an API key with the sync scope.
+type SyncPeer struct {
+	ID             primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Name           string             `bson:"name" json:"name"`
+	URL            string             `bson:"url" json:"url"`                    // the peer's base URL, e.g. https://books.example.com
+	APIKey         string             `bson:"apiKey" json:"-"`                   // encrypted like drive tokens
+	Collections    []string           `bson:"collections" json:"collections"`    // "all", "shelf:<shelf>" or "tag:<tag>", as the key's owner sees them
+	Progress       bool               `bson:"progress" json:"progress"`          // also mirror reading progress, matched by email and file
+	Cursors        []SyncCursor       `bson:"cursors,omitempty" json:"-"`        // where each collection's pull got to
+	ProgressCursor string             `bson:"progressCursor,omitempty" json:"-"` // where the reading progress pull got to
+	CreatedBy      string             `bson:"createdBy" json:"createdBy"`        // email; mirrored books are uploaded by them
+	LastSync       *PeerSync          `bson:"lastSync,omitempty" json:"lastSync,omitempty"`
+	CreatedAt      time.Time          `bson:"createdAt" json:"createdAt"`
+}
+
+// SyncCursor is how far a peer's collection has been pulled.
+type SyncCursor struct {
+	Collection string `bson:"collection" json:"collection"`
+	Cursor     string `bson:"cursor" json:"cursor"`
+}
+
+// PeerSync is the outcome of a peer's latest sync.
+type PeerSync struct {
+	At         time.Time `bson:"at" json:"at"`
+	Imported   int       `bson:"imported" json:"imported"`
+	Duplicates int       `bson:"duplicates" json:"duplicates"` // already in the library (same SHA-256)
+	Progress   int       `bson:"progress" json:"progress"`     // reading positions brought up to date
+	Failed     int       `bson:"failed" json:"failed"`         // tried again at the next sync
+	Error      string    `bson:"error,omitempty" json:"error,omitempty"`
+}
+
+// SyncBooksPage is a page of GET /api/sync/books: a collection's books in the order they were added.
+type SyncBooksPage struct {
+	Books  []SyncBook `json:"books"`
+	Cursor string     `json:"cursor"` // pass as ?cursor= for the books added after these; "" when there were none
+	More   bool       `json:"more"`
+}
+
+// SyncBook is a book offered to a peer, with a short-lived link to its file.
+type SyncBook struct {
+	Book
+	// FileURL downloads the file without other auth; paths starting with "/" are on the serving instance.
+	// Empty when the file can't be had now (no file, or archived to cold storage).
+	FileURL string `json:"fileUrl,omitempty"`
+}
+
+// SyncProgressPage is a page of GET /api/sync/progress: reading positions in the order they were last updated.
+type SyncProgressPage struct {
+	Progress []SyncProgress `json:"progress"`
+	Cursor   string         `json:"cursor"`
+	More     bool           `json:"more"`
+}
+
+// SyncProgress is a user's position in a book, identified across instances by the user's email and the file's
+// SHA-256.
+type SyncProgress struct {
+	Email          string    `json:"email"`
+	SHA256         string    `json:"sha256"`
+	Anchor         string    `json:"anchor,omitempty"`
+	KindleLocation int       `json:"kindleLocation,omitempty"`
+	Percent        float64   `json:"percent"`
+	Device         string    `json:"device,omitempty"`
+	UpdatedAt      time.Time `json:"updatedAt"`
+}
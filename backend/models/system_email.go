@@ -0,0 +1,25 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// System email statuses.
+const (
+	SystemEmailSent   = "sent"
+	SystemEmailFailed = "failed"
+)
+
+// SystemEmail records an email the app sent on its own behalf (invite, password reset, notification).
+// Send to Kindle is logged separately in EmailLog.
+type SystemEmail struct {
+	ID       primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Template string             `bson:"template" json:"template"`
+	ToEmail  string             `bson:"toEmail" json:"toEmail"`
+	Subject  string             `bson:"subject" json:"subject"`
+	Status   string             `bson:"status" json:"status"`
+	Error    string             `bson:"error,omitempty" json:"error,omitempty"`
+	SentAt   time.Time          `bson:"sentAt" json:"sentAt"`
+}
@@ -0,0 +1,17 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// TelegramChat links a private chat with the Telegram bot to a user; the bot acts as that user. A user has at most
+// one linked chat and a chat belongs to at most one user.
+type TelegramChat struct {
+	ID       primitive.ObjectID `bson:"_id,omitempty" json:"-"`
+	ChatID   int64              `bson:"chatId" json:"-"`
+	UserID   primitive.ObjectID `bson:"userId" json:"-"`
+	Username string             `bson:"username,omitempty" json:"username,omitempty"` // Telegram @username, if the user has one
+	LinkedAt time.Time          `bson:"linkedAt" json:"linkedAt"`
+}
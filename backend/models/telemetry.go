@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// TelemetryReport is what an instance that opted in to telemetry reports: aggregate counts only, never titles,
+// emails or anything else about a user or a book. Admins can see the next one at GET /api/admin/telemetry.
+type TelemetryReport struct {
+	// InstanceID is random, made for the first report; it only tells reports from one instance apart.
+	InstanceID string         `json:"instanceId"`
+	Version    string         `json:"version"` // the build's VCS revision; "" when unknown
+	GoVersion  string         `json:"goVersion"`
+	OS         string         `json:"os"`
+	Arch       string         `json:"arch"`
+	Books      int            `json:"books"`
+	Formats    map[string]int `json:"formats"`  // books by format
+	StoredGB   float64        `json:"storedGB"` // rounded to a tenth
+	Users      map[string]int `json:"users"`    // accounts by role
+	Features   []string       `json:"features"` // the optional features configured (see handlers.Capabilities)
+	Sends      int            `json:"sends"`    // books sent to Kindles and delivery targets in the last week
+	JobRuns    map[string]int `json:"jobRuns"`  // job runs in the last week, by job type
+	ReportedAt time.Time      `json:"reportedAt"`
+}
@@ -0,0 +1,15 @@
+package models
+
+// TimelineBucket is one point on a books timeline: a period label (e.g. "1990s", "1994", "2024-03") and how many books fall in it.
+type TimelineBucket struct {
+	Period string `bson:"_id" json:"period"`
+	Count  int    `bson:"count" json:"count"`
+}
+
+// BookTimeline groups the catalog by publication decade/year and by month added, for the timeline view.
+type BookTimeline struct {
+	PublishedByDecade []TimelineBucket `json:"publishedByDecade"`
+	PublishedByYear   []TimelineBucket `json:"publishedByYear"`
+	AddedByMonth      []TimelineBucket `json:"addedByMonth"`
+	Undated           int              `json:"undated"` // books whose publishDate does not start with a year
+}
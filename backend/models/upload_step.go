@@ -0,0 +1,34 @@
+package models
+
+// Upload pipeline steps, in the order they are reported. Saving the book record is not listed: a book only exists
+// once it has succeeded.
+const (
+	UploadStepStoreFile = "store-file"
+	UploadStepMetadata  = "fetch-metadata"
+	UploadStepCover     = "store-cover"
+)
+
+// Upload step statuses.
+const (
+	UploadStepDone    = "done"
+	UploadStepSkipped = "skipped" // nothing to do, e.g. no ISBN in the file or a PDF
+	UploadStepFailed  = "failed"  // every attempt failed; POST /api/books/{id}/retry-upload runs it again
+)
+
+// UploadStep is the outcome of one step of the upload pipeline, saved on the book.
+type UploadStep struct {
+	Name     string `bson:"name" json:"name"`
+	Status   string `bson:"status" json:"status"`
+	Attempts int    `bson:"attempts,omitempty" json:"attempts,omitempty"`
+	Error    string `bson:"error,omitempty" json:"error,omitempty"`
+}
+
+// UploadIncomplete reports whether any of steps failed.
+func UploadIncomplete(steps []UploadStep) bool {
+	for _, s := range steps {
+		if s.Status == UploadStepFailed {
+			return true
+		}
+	}
+	return false
+}
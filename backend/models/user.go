@@ -22,5 +22,11 @@ type User struct {
 	Password         string             `bson:"password" json:"-"` // bcrypt hash
 	Role             string             `bson:"role" json:"role"`   // admin, viewer, editor, guest
 	UseExtractedCover bool              `bson:"useExtractedCover" json:"useExtractedCover"` // prefer EPUB-extracted thumbnail over API cover
+	MaxContentRating string             `bson:"maxContentRating,omitempty" json:"maxContentRating,omitempty"` // set by an admin (e.g. for kids' accounts); "" = no limit
+	WeeklyDigest     bool               `bson:"weeklyDigest,omitempty" json:"weeklyDigest"`                   // opted in to the weekly digest email
+	DigestSentAt     *time.Time         `bson:"digestSentAt,omitempty" json:"-"`                              // when the last digest was sent
+	TermsVersion     int                `bson:"termsVersion,omitempty" json:"termsVersion,omitempty"`         // the version of the terms of use last accepted (see Terms)
+	TermsAcceptedAt  *time.Time         `bson:"termsAcceptedAt,omitempty" json:"termsAcceptedAt,omitempty"`
+	OnboardedAt      *time.Time         `bson:"onboardedAt,omitempty" json:"onboardedAt,omitempty"`           // when the user finished the first-login walkthrough
 	CreatedAt        time.Time          `bson:"createdAt" json:"createdAt"`
 }
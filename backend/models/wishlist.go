@@ -0,0 +1,39 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// WishlistItem is a book a user wants but the library doesn't have a file for: metadata only, usually saved from
+// a storefront page with POST /api/clip.
+type WishlistItem struct {
+	ID           primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID       primitive.ObjectID `bson:"userId" json:"-"`
+	ISBN         string             `bson:"isbn,omitempty" json:"isbn,omitempty"`
+	Title        string             `bson:"title" json:"title"`
+	Authors      []string           `bson:"authors,omitempty" json:"authors,omitempty"`
+	Publisher    string             `bson:"publisher,omitempty" json:"publisher,omitempty"`
+	PublishDate  string             `bson:"publishDate,omitempty" json:"publishDate,omitempty"`
+	CoverURL     string             `bson:"coverUrl,omitempty" json:"coverUrl,omitempty"`
+	ThumbnailURL string             `bson:"thumbnailUrl,omitempty" json:"thumbnailUrl,omitempty"`
+	SourceURL    string             `bson:"sourceUrl,omitempty" json:"sourceUrl,omitempty"` // the page it was saved from
+	Notes        string             `bson:"notes,omitempty" json:"notes,omitempty"`
+	PriceWatch   *PriceWatch        `bson:"priceWatch,omitempty" json:"priceWatch,omitempty"`
+	CreatedAt    time.Time          `bson:"createdAt" json:"createdAt"`
+}
+
+// PriceWatch tracks what a wishlist item costs at the configured price providers, to tell the user when it drops
+// to their threshold.
+type PriceWatch struct {
+	Threshold float64    `bson:"threshold" json:"threshold"`                   // notify at or below this price
+	Currency  string     `bson:"currency" json:"currency"`                     // ISO 4217, e.g. "USD"; prices in other currencies are ignored
+	Price     float64    `bson:"price,omitempty" json:"price,omitempty"`       // lowest price at the last check
+	Provider  string     `bson:"provider,omitempty" json:"provider,omitempty"` // where Price was found
+	URL       string     `bson:"url,omitempty" json:"url,omitempty"`
+	CheckedAt *time.Time `bson:"checkedAt,omitempty" json:"checkedAt,omitempty"`
+	// NotifiedPrice is the last price the user was told about; they are told again only about a lower one. It is
+	// cleared when the price goes back above Threshold.
+	NotifiedPrice float64 `bson:"notifiedPrice,omitempty" json:"notifiedPrice,omitempty"`
+}
@@ -0,0 +1,59 @@
+// Package notify delivers in-app notifications (e.g. admin alerts from background jobs).
+package notify
+
+import (
+	"context"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/kevinaaaquil/books/backend/models"
+	"github.com/kevinaaaquil/books/backend/store"
+	"github.com/kevinaaaquil/books/backend/systemmail"
+)
+
+type Service struct {
+	DB   store.Store
+	Mail *systemmail.Service // nil = in-app notifications only
+}
+
+// NotifyAdmins records a notification for every admin user and, when system mail is configured, emails them.
+// Email failures are logged (and recorded in the system email log) but not returned.
+func (s *Service) NotifyAdmins(ctx context.Context, kind, title, body, link string) error {
+	admins, err := s.DB.UsersByRole(ctx, models.RoleAdmin)
+	if err != nil {
+		return err
+	}
+	for _, admin := range admins {
+		if err := s.NotifyUser(ctx, &admin, kind, title, body, link); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// NotifyUser records a notification for user and, when system mail is configured, emails them. Email failures
+// are logged (and recorded in the system email log) but not returned.
+func (s *Service) NotifyUser(ctx context.Context, user *models.User, kind, title, body, link string) error {
+	n := &models.Notification{
+		UserID:    user.ID,
+		Kind:      kind,
+		Title:     title,
+		Body:      body,
+		Link:      link,
+		CreatedAt: time.Now(),
+	}
+	if err := s.DB.InsertNotification(ctx, n); err != nil {
+		return err
+	}
+	if s.Mail != nil {
+		data := systemmail.Data{Title: title, Body: body}
+		if link != "" && !strings.HasPrefix(link, "/api/") {
+			data.Link = s.Mail.URL(link)
+		}
+		if err := s.Mail.Send(ctx, user.Email, systemmail.Notification, data); err != nil {
+			log.Printf("notify: email %s: %v", user.Email, err)
+		}
+	}
+	return nil
+}
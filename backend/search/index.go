@@ -0,0 +1,279 @@
+// Package search keeps an in-memory full-text index of the library: book metadata, weighted above the text of
+// EPUBs. It is rebuilt from the database and storage at startup and by the reindex job, and kept current by the
+// handlers that change books.
+package search
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/kevinaaaquil/books/backend/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Field weights: a match in the title counts for more than one in the text.
+const (
+	weightTitle    = 8
+	weightAuthor   = 6
+	weightMetadata = 3 // publisher, categories, ISBN
+	weightPreface  = 2
+	weightText     = 1
+)
+
+// maxTextTokens caps the distinct words indexed from one book's text.
+const maxTextTokens = 20000
+
+// Hit is a book matching a query, with its relevance.
+type Hit struct {
+	BookID primitive.ObjectID
+	Score  float64
+}
+
+// Stats describe the index for the admin status endpoint.
+type Stats struct {
+	Documents  int        `json:"documents"`
+	Terms      int        `json:"terms"`
+	BuiltAt    *time.Time `json:"builtAt,omitempty"` // last completed rebuild; nil before the first
+	Rebuilding bool       `json:"rebuilding"`
+}
+
+// Index maps words to the books containing them. It is safe for concurrent use.
+type Index struct {
+	mu       sync.RWMutex
+	postings map[string]map[primitive.ObjectID]float64
+	docs     map[primitive.ObjectID]*doc
+	builtAt  *time.Time
+	next     *Index // set while a rebuild runs; receives every change made to this index
+}
+
+// doc keeps a book's weighted tokens so they can be removed or the metadata replaced.
+type doc struct {
+	metadata map[string]float64
+	text     []string
+}
+
+func New() *Index {
+	return &Index{postings: map[string]map[primitive.ObjectID]float64{}, docs: map[primitive.ObjectID]*doc{}}
+}
+
+// Tokenize splits s into lowercase words of letters and digits.
+func Tokenize(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) })
+}
+
+func metadataTokens(b *models.Book) map[string]float64 {
+	weights := map[string]float64{}
+	add := func(s string, w float64) {
+		for _, t := range Tokenize(s) {
+			if w > weights[t] {
+				weights[t] = w
+			}
+		}
+	}
+	add(b.Title, weightTitle)
+	for _, a := range b.Authors {
+		add(a, weightAuthor)
+	}
+	add(b.Publisher, weightMetadata)
+	add(b.Category, weightMetadata)
+	for _, c := range b.Categories {
+		add(c, weightMetadata)
+	}
+	add(b.ISBN, weightMetadata)
+	add(b.DOI, weightMetadata)
+	add(b.Journal, weightMetadata)
+	add(b.Preface, weightPreface)
+	return weights
+}
+
+func textTokens(text string) []string {
+	seen := map[string]bool{}
+	var tokens []string
+	for _, t := range Tokenize(text) {
+		if len(tokens) == maxTextTokens {
+			break
+		}
+		if !seen[t] {
+			seen[t] = true
+			tokens = append(tokens, t)
+		}
+	}
+	return tokens
+}
+
+// Put indexes a book's metadata and text (empty for PDFs or unreadable files), replacing any earlier entry.
+func (idx *Index) Put(b *models.Book, text string) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.put(b.ID, &doc{metadata: metadataTokens(b), text: textTokens(text)})
+	if idx.next != nil {
+		idx.next.Put(b, text)
+	}
+}
+
+// PutMetadata re-indexes a book's metadata, keeping its indexed text.
+func (idx *Index) PutMetadata(b *models.Book) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	d := &doc{metadata: metadataTokens(b)}
+	if old := idx.docs[b.ID]; old != nil {
+		d.text = old.text
+	}
+	idx.put(b.ID, d)
+	if idx.next != nil {
+		idx.next.PutMetadata(b)
+	}
+}
+
+// Remove drops a book from the index.
+func (idx *Index) Remove(id primitive.ObjectID) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.remove(id)
+	if idx.next != nil {
+		idx.next.Remove(id)
+	}
+}
+
+func (idx *Index) put(id primitive.ObjectID, d *doc) {
+	idx.remove(id)
+	idx.docs[id] = d
+	weights := make(map[string]float64, len(d.metadata)+len(d.text))
+	for _, t := range d.text {
+		weights[t] = weightText
+	}
+	for t, w := range d.metadata {
+		weights[t] = w + weights[t]
+	}
+	for t, w := range weights {
+		books := idx.postings[t]
+		if books == nil {
+			books = map[primitive.ObjectID]float64{}
+			idx.postings[t] = books
+		}
+		books[id] = w
+	}
+}
+
+func (idx *Index) remove(id primitive.ObjectID) {
+	d := idx.docs[id]
+	if d == nil {
+		return
+	}
+	drop := func(t string) {
+		if books := idx.postings[t]; books != nil {
+			delete(books, id)
+			if len(books) == 0 {
+				delete(idx.postings, t)
+			}
+		}
+	}
+	for t := range d.metadata {
+		drop(t)
+	}
+	for _, t := range d.text {
+		drop(t)
+	}
+	delete(idx.docs, id)
+}
+
+// Search returns the books matching every word of query, best first. The last word also matches as a prefix,
+// so results update while the user types.
+func (idx *Index) Search(query string) []Hit {
+	tokens := Tokenize(query)
+	if len(tokens) == 0 {
+		return nil
+	}
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	var scores map[primitive.ObjectID]float64
+	for i, t := range tokens {
+		matches := map[primitive.ObjectID]float64{}
+		for id, w := range idx.postings[t] {
+			matches[id] = w
+		}
+		if i == len(tokens)-1 {
+			for term, books := range idx.postings {
+				if term == t || !strings.HasPrefix(term, t) {
+					continue
+				}
+				for id, w := range books {
+					// A prefix match ranks below the whole word.
+					if w/2 > matches[id] {
+						matches[id] = w / 2
+					}
+				}
+			}
+		}
+		if scores == nil {
+			scores = matches
+			continue
+		}
+		for id := range scores {
+			if w, ok := matches[id]; ok {
+				scores[id] += w
+			} else {
+				delete(scores, id)
+			}
+		}
+	}
+	hits := make([]Hit, 0, len(scores))
+	for id, s := range scores {
+		hits = append(hits, Hit{BookID: id, Score: s})
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].BookID.Hex() < hits[j].BookID.Hex()
+	})
+	return hits
+}
+
+// StartRebuild returns an empty index to fill from scratch. Until FinishRebuild or AbortRebuild, changes made to
+// idx are also applied to it, so none are lost to a rebuild running concurrently.
+func (idx *Index) StartRebuild() *Index {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.next = New()
+	return idx.next
+}
+
+// FinishRebuild replaces idx's contents with next, the index returned by StartRebuild.
+func (idx *Index) FinishRebuild(next *Index, now time.Time) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	if idx.next != next {
+		return
+	}
+	next.mu.Lock()
+	idx.postings, idx.docs = next.postings, next.docs
+	next.mu.Unlock()
+	idx.next = nil
+	idx.builtAt = &now
+}
+
+// AbortRebuild discards next, keeping idx's contents.
+func (idx *Index) AbortRebuild(next *Index) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	if idx.next == next {
+		idx.next = nil
+	}
+}
+
+// Contains reports whether the book is indexed.
+func (idx *Index) Contains(id primitive.ObjectID) bool {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	return idx.docs[id] != nil
+}
+
+func (idx *Index) Stats() Stats {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	return Stats{Documents: len(idx.docs), Terms: len(idx.postings), BuiltAt: idx.builtAt, Rebuilding: idx.next != nil}
+}
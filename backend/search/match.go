@@ -0,0 +1,115 @@
+package search
+
+import (
+	"math"
+	"sort"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// passageChars is the length Passages aims for; passages end at the next space after it.
+const passageChars = 600
+
+// stopWords are left out of Match and Passages queries: questions are full of them and they match every book.
+var stopWords = map[string]bool{
+	"a": true, "about": true, "all": true, "an": true, "and": true, "any": true, "are": true, "as": true, "at": true,
+	"be": true, "book": true, "books": true, "but": true, "by": true, "can": true, "cover": true, "covers": true,
+	"did": true, "do": true, "does": true, "for": true, "from": true, "have": true, "how": true, "i": true, "in": true,
+	"is": true, "it": true, "library": true, "me": true, "mention": true, "mentions": true, "my": true, "of": true,
+	"on": true, "or": true, "read": true, "that": true, "the": true, "there": true, "this": true, "to": true,
+	"was": true, "what": true, "when": true, "where": true, "which": true, "who": true, "why": true, "with": true,
+	"you": true,
+}
+
+// queryTerms returns the distinct words of query other than stop words.
+func queryTerms(query string) []string {
+	seen := map[string]bool{}
+	var terms []string
+	for _, t := range Tokenize(query) {
+		if !stopWords[t] && !seen[t] {
+			seen[t] = true
+			terms = append(terms, t)
+		}
+	}
+	return terms
+}
+
+// Match returns the books matching any word of query, best first: unlike Search, for questions in plain words.
+// Each word counts by where it was found (as in Search) and by how few books have it.
+func (idx *Index) Match(query string) []Hit {
+	terms := queryTerms(query)
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	n := float64(len(idx.docs))
+	scores := map[primitive.ObjectID]float64{}
+	for _, t := range terms {
+		books := idx.postings[t]
+		if len(books) == 0 {
+			continue
+		}
+		idf := math.Log(1 + n/float64(len(books)))
+		for id, w := range books {
+			scores[id] += w * idf
+		}
+	}
+	out := make([]Hit, 0, len(scores))
+	for id, s := range scores {
+		out = append(out, Hit{BookID: id, Score: s})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Score != out[j].Score {
+			return out[i].Score > out[j].Score
+		}
+		return out[i].BookID.Hex() < out[j].BookID.Hex()
+	})
+	return out
+}
+
+// Passages returns up to n passages of text, about passageChars long, that contain the most words of query, best
+// first; none when no passage has any.
+func Passages(text, query string, n int) []string {
+	terms := queryTerms(query)
+	if len(terms) == 0 || n < 1 {
+		return nil
+	}
+	type passage struct {
+		text  string
+		score int
+	}
+	var passages []passage
+	for start := 0; start < len(text); {
+		end := start + passageChars
+		if end >= len(text) {
+			end = len(text)
+		} else if i := strings.IndexAny(text[end:], " \n"); i >= 0 {
+			end += i
+		} else {
+			end = len(text)
+		}
+		chunk := strings.Join(strings.Fields(text[start:end]), " ")
+		words := map[string]bool{}
+		for _, t := range Tokenize(chunk) {
+			words[t] = true
+		}
+		score := 0
+		for _, t := range terms {
+			if words[t] {
+				score++
+			}
+		}
+		if score > 0 {
+			passages = append(passages, passage{chunk, score})
+		}
+		start = end
+	}
+	sort.SliceStable(passages, func(i, j int) bool { return passages[i].score > passages[j].score })
+	var out []string
+	for _, p := range passages {
+		if len(out) == n {
+			break
+		}
+		out = append(out, p.text)
+	}
+	return out
+}
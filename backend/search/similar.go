@@ -0,0 +1,77 @@
+package search
+
+import (
+	"math"
+	"sort"
+	"unicode"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// similarTerms is how many of a book's most distinctive words Similar looks for in other books.
+const similarTerms = 100
+
+// Similar returns the books whose indexed words share the most distinctive words of the book's text, best first,
+// scored from 0 to 1 (every one of those words found): a "more like this" query over TF-IDF weights. The book
+// itself is left out; a book without indexed text has none.
+func (idx *Index) Similar(id primitive.ObjectID) []Hit {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	d := idx.docs[id]
+	if d == nil || len(d.text) == 0 {
+		return nil
+	}
+	n := float64(len(idx.docs))
+	type term struct {
+		token string
+		idf   float64
+	}
+	var terms []term
+	for _, t := range d.text {
+		df := len(idx.postings[t])
+		// Words no other book has can't match, and ones most books have say nothing; nor do numbers.
+		if df < 2 || float64(df) > n/2 || len(t) < 3 || isNumber(t) {
+			continue
+		}
+		terms = append(terms, term{t, math.Log(n / float64(df))})
+	}
+	sort.Slice(terms, func(i, j int) bool {
+		if terms[i].idf != terms[j].idf {
+			return terms[i].idf > terms[j].idf
+		}
+		return terms[i].token < terms[j].token
+	})
+	if len(terms) > similarTerms {
+		terms = terms[:similarTerms]
+	}
+	var total float64
+	scores := map[primitive.ObjectID]float64{}
+	for _, t := range terms {
+		total += t.idf
+		for other := range idx.postings[t.token] {
+			if other != id {
+				scores[other] += t.idf
+			}
+		}
+	}
+	hits := make([]Hit, 0, len(scores))
+	for other, s := range scores {
+		hits = append(hits, Hit{BookID: other, Score: s / total})
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].BookID.Hex() < hits[j].BookID.Hex()
+	})
+	return hits
+}
+
+func isNumber(s string) bool {
+	for _, r := range s {
+		if !unicode.IsDigit(r) {
+			return false
+		}
+	}
+	return true
+}
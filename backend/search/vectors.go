@@ -0,0 +1,122 @@
+package search
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Vectors is an in-memory index of book embeddings for semantic search, compared by cosine similarity. The
+// embeddings themselves are kept in the database; Vectors holds those of one model, loaded as they change (see
+// SyncedTo). It is safe for concurrent use.
+type Vectors struct {
+	mu     sync.RWMutex
+	vecs   map[primitive.ObjectID][]float32 // unit length
+	synced time.Time
+}
+
+func NewVectors() *Vectors {
+	return &Vectors{vecs: map[primitive.ObjectID][]float32{}}
+}
+
+// Put sets a book's embedding.
+func (v *Vectors) Put(id primitive.ObjectID, vec []float32) {
+	unit := normalize(vec)
+	if unit == nil {
+		return
+	}
+	v.mu.Lock()
+	v.vecs[id] = unit
+	v.mu.Unlock()
+}
+
+// Remove drops a book's embedding.
+func (v *Vectors) Remove(id primitive.ObjectID) {
+	v.mu.Lock()
+	delete(v.vecs, id)
+	v.mu.Unlock()
+}
+
+// Vector returns a book's embedding, scaled to unit length; nil when it has none.
+func (v *Vectors) Vector(id primitive.ObjectID) []float32 {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	return v.vecs[id]
+}
+
+// Len is the number of books with an embedding.
+func (v *Vectors) Len() int {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	return len(v.vecs)
+}
+
+// Synced is the latest update time of the stored embeddings loaded so far; zero before any.
+func (v *Vectors) Synced() time.Time {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	return v.synced
+}
+
+// SyncedTo records that stored embeddings updated up to t have been loaded.
+func (v *Vectors) SyncedTo(t time.Time) {
+	v.mu.Lock()
+	if t.After(v.synced) {
+		v.synced = t
+	}
+	v.mu.Unlock()
+}
+
+// Search returns the books whose embeddings are most similar to query, best first, with their cosine similarity
+// as the score: at most limit, and none below minScore. Embeddings of another length than query's are skipped.
+func (v *Vectors) Search(query []float32, limit int, minScore float64) []Hit {
+	q := normalize(query)
+	if q == nil {
+		return nil
+	}
+	v.mu.RLock()
+	hits := make([]Hit, 0, len(v.vecs))
+	for id, vec := range v.vecs {
+		if len(vec) != len(q) {
+			continue
+		}
+		var dot float64
+		for i := range vec {
+			dot += float64(vec[i]) * float64(q[i])
+		}
+		if dot >= minScore {
+			hits = append(hits, Hit{BookID: id, Score: dot})
+		}
+	}
+	v.mu.RUnlock()
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].BookID.Hex() < hits[j].BookID.Hex()
+	})
+	if limit > 0 && len(hits) > limit {
+		hits = hits[:limit]
+	}
+	return hits
+}
+
+// normalize returns vec scaled to unit length, or nil for an empty or zero vector.
+func normalize(vec []float32) []float32 {
+	var sum float64
+	for _, x := range vec {
+		sum += float64(x) * float64(x)
+	}
+	if sum == 0 {
+		return nil
+	}
+	norm := math.Sqrt(sum)
+	unit := make([]float32, len(vec))
+	for i, x := range vec {
+		unit[i] = float32(float64(x) / norm)
+	}
+	return unit
+}
@@ -0,0 +1,186 @@
+package service
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	arxivAPIBase = "https://export.arxiv.org/api/query"
+	arxivPDFBase = "https://arxiv.org/pdf/"
+)
+
+// ErrFileTooLarge is returned by ArXiv.DownloadPDF for files over the limit.
+var ErrFileTooLarge = errors.New("file is too large")
+
+// ArXiv fetches papers from arXiv: metadata from its Atom API and the PDF.
+type ArXiv struct {
+	APIURL  string        // "" = https://export.arxiv.org/api/query
+	PDFURL  string        // prefix of PDF links, followed by the versioned ID; "" = https://arxiv.org/pdf/
+	Timeout time.Duration // per metadata lookup; 0 = 15s. Downloads are limited by the client only
+	Client  *http.Client  // nil = DefaultOutbound
+}
+
+// ArXivPaper is a paper's metadata and where its PDF is.
+type ArXivPaper struct {
+	ID       string // versioned, e.g. "2101.00001v2"
+	Metadata BookMetadata
+	PDFURL   string
+}
+
+// arxivID matches new-style (2101.00001) and old-style (hep-th/9901001) identifiers, with an optional version.
+var arxivID = regexp.MustCompile(`^(\d{4}\.\d{4,5}|[a-z-]+(?:\.[A-Z]{2})?/\d{7})(v\d+)?$`)
+
+// ParseArXivID returns the identifier in s, which may also be an arxiv: reference or an abs or pdf link; "" when
+// there is none.
+func ParseArXivID(s string) string {
+	s = strings.TrimSpace(s)
+	if len(s) >= 6 && strings.EqualFold(s[:6], "arxiv:") {
+		s = s[6:]
+	}
+	if u, err := url.Parse(s); err == nil && strings.HasSuffix(u.Host, "arxiv.org") {
+		for _, prefix := range []string{"/abs/", "/pdf/"} {
+			if strings.HasPrefix(u.Path, prefix) {
+				s = strings.TrimSuffix(strings.TrimPrefix(u.Path, prefix), ".pdf")
+			}
+		}
+	}
+	if !arxivID.MatchString(s) {
+		return ""
+	}
+	return s
+}
+
+// arxivFeed is the part of an Atom API response books keep.
+type arxivFeed struct {
+	Entries []struct {
+		ID        string `xml:"http://www.w3.org/2005/Atom id"`
+		Title     string `xml:"http://www.w3.org/2005/Atom title"`
+		Summary   string `xml:"http://www.w3.org/2005/Atom summary"`
+		Published string `xml:"http://www.w3.org/2005/Atom published"`
+		Authors   []struct {
+			Name string `xml:"http://www.w3.org/2005/Atom name"`
+		} `xml:"http://www.w3.org/2005/Atom author"`
+		Categories []struct {
+			Term string `xml:"term,attr"`
+		} `xml:"http://www.w3.org/2005/Atom category"`
+		Primary struct {
+			Term string `xml:"term,attr"`
+		} `xml:"http://arxiv.org/schemas/atom primary_category"`
+		DOI        string `xml:"http://arxiv.org/schemas/atom doi"`
+		JournalRef string `xml:"http://arxiv.org/schemas/atom journal_ref"`
+	} `xml:"http://www.w3.org/2005/Atom entry"`
+}
+
+// Paper looks up a paper by its identifier (see ParseArXivID); without a version, the latest. ErrNoMetadata when
+// arXiv has no such paper. The metadata has the title, authors, abstract, categories (the primary one first),
+// first submission date, the journal reference and DOI when the paper was published, else arXiv's own DOI.
+func (a ArXiv) Paper(ctx context.Context, id string) (*ArXivPaper, error) {
+	timeout := a.Timeout
+	if timeout <= 0 {
+		timeout = defaultGoogleBooksTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	base := a.APIURL
+	if base == "" {
+		base = arxivAPIBase
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, base+"?id_list="+url.QueryEscape(id), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := outboundClient(a.Client).Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("arxiv returned %d", resp.StatusCode)
+	}
+	var feed arxivFeed
+	if err := xml.NewDecoder(resp.Body).Decode(&feed); err != nil {
+		return nil, err
+	}
+	// Unknown identifiers come back as an entry without a title (an error entry).
+	if len(feed.Entries) == 0 || strings.TrimSpace(feed.Entries[0].Title) == "" || strings.Contains(feed.Entries[0].ID, "/api/errors") {
+		return nil, fmt.Errorf("%w for arxiv %s", ErrNoMetadata, id)
+	}
+	e := feed.Entries[0]
+	p := &ArXivPaper{ID: id}
+	if i := strings.Index(e.ID, "/abs/"); i >= 0 {
+		p.ID = e.ID[i+len("/abs/"):]
+	}
+	m := &p.Metadata
+	m.Provider = "arxiv"
+	m.Title = strings.Join(strings.Fields(e.Title), " ")
+	for _, au := range e.Authors {
+		if name := strings.TrimSpace(au.Name); name != "" {
+			m.Authors = append(m.Authors, name)
+		}
+	}
+	m.Preface = strings.Join(strings.Fields(e.Summary), " ")
+	if len(e.Published) >= 10 {
+		m.PublishDate = e.Published[:10]
+	}
+	if e.Primary.Term != "" {
+		m.Categories = append(m.Categories, e.Primary.Term)
+	}
+	for _, c := range e.Categories {
+		if c.Term != "" && c.Term != e.Primary.Term {
+			m.Categories = append(m.Categories, c.Term)
+		}
+	}
+	if len(m.Categories) > 0 {
+		m.Category = m.Categories[0]
+	}
+	m.Publisher = "arXiv"
+	m.Journal = strings.Join(strings.Fields(e.JournalRef), " ")
+	m.DOI = strings.TrimSpace(e.DOI)
+	if match := arxivID.FindStringSubmatch(p.ID); m.DOI == "" && match != nil {
+		m.DOI = "10.48550/arXiv." + match[1]
+	}
+	m.Document = true
+	pdfBase := a.PDFURL
+	if pdfBase == "" {
+		pdfBase = arxivPDFBase
+	}
+	p.PDFURL = pdfBase + p.ID
+	return p, nil
+}
+
+// DownloadPDF fetches the paper's PDF, failing with ErrFileTooLarge when it is over maxBytes (0 = no limit).
+func (a ArXiv) DownloadPDF(ctx context.Context, p *ArXivPaper, maxBytes int64) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.PDFURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := outboundClient(a.Client).Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("arxiv returned %d for the pdf", resp.StatusCode)
+	}
+	var body io.Reader = resp.Body
+	if maxBytes > 0 {
+		body = io.LimitReader(resp.Body, maxBytes+1)
+	}
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, err
+	}
+	if maxBytes > 0 && int64(len(data)) > maxBytes {
+		return nil, ErrFileTooLarge
+	}
+	return data, nil
+}
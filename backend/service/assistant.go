@@ -0,0 +1,209 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// AssistantSource is a book an Assistant may answer from: its metadata and passages of its text.
+type AssistantSource struct {
+	ID          string
+	Title       string
+	Authors     []string
+	Categories  []string
+	Description string
+	Passages    []string
+}
+
+// AssistantAnswer is an Assistant's answer, with the IDs of the sources it relied on.
+type AssistantAnswer struct {
+	Text  string
+	Cited []string
+}
+
+// Assistant answers questions about a library from the books given to it, not from what the model knows.
+// OpenAIAssistant and AnthropicAssistant implement it.
+type Assistant interface {
+	Answer(ctx context.Context, question string, sources []AssistantSource) (*AssistantAnswer, error)
+}
+
+// AssistantAPIError is an error answer from an assistant's chat API.
+type AssistantAPIError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *AssistantAPIError) Error() string {
+	return fmt.Sprintf("assistant api: %d %s", e.StatusCode, e.Message)
+}
+
+// assistantSystemPrompt grounds the model in the numbered sources of assistantPrompt.
+const assistantSystemPrompt = `You answer questions about the reader's own library of books. Use only the numbered books ` +
+	`given with the question, never other books you know of, and refer to books by their title. If none of them ` +
+	`answer the question, say so. Answer with JSON only: {"answer": "...", "books": [n, ...]}, listing the numbers ` +
+	`of the books your answer relies on.`
+
+// assistantPrompt lists the sources, numbered from 1, then the question.
+func assistantPrompt(question string, sources []AssistantSource) string {
+	var sb strings.Builder
+	sb.WriteString("Books:\n")
+	for i, s := range sources {
+		fmt.Fprintf(&sb, "\n[%d] %s", i+1, s.Title)
+		if len(s.Authors) > 0 {
+			fmt.Fprintf(&sb, " by %s", strings.Join(s.Authors, ", "))
+		}
+		sb.WriteByte('\n')
+		if len(s.Categories) > 0 {
+			fmt.Fprintf(&sb, "Categories: %s\n", strings.Join(s.Categories, "; "))
+		}
+		if s.Description != "" {
+			fmt.Fprintf(&sb, "Description: %s\n", s.Description)
+		}
+		for _, p := range s.Passages {
+			fmt.Fprintf(&sb, "Excerpt: %s\n", p)
+		}
+	}
+	fmt.Fprintf(&sb, "\nQuestion: %s", question)
+	return sb.String()
+}
+
+// parseAssistantAnswer reads the model's JSON answer and maps the book numbers it cites back to source IDs.
+func parseAssistantAnswer(content string, sources []AssistantSource) (*AssistantAnswer, error) {
+	// Models sometimes wrap the JSON in a code fence.
+	content = strings.TrimSpace(content)
+	if i, j := strings.Index(content, "{"), strings.LastIndex(content, "}"); i >= 0 && j > i {
+		content = content[i : j+1]
+	}
+	var out struct {
+		Answer string            `json:"answer"`
+		Books  []json.RawMessage `json:"books"`
+	}
+	if err := json.Unmarshal([]byte(content), &out); err != nil || strings.TrimSpace(out.Answer) == "" {
+		return nil, fmt.Errorf("assistant api: unexpected answer %q", content)
+	}
+	answer := &AssistantAnswer{Text: strings.TrimSpace(out.Answer), Cited: []string{}}
+	seen := map[int]bool{}
+	for _, raw := range out.Books {
+		// Numbers, or numbers as strings.
+		n, err := strconv.Atoi(strings.Trim(string(raw), `"[] `))
+		if err != nil || n < 1 || n > len(sources) || seen[n] {
+			continue
+		}
+		seen[n] = true
+		answer.Cited = append(answer.Cited, sources[n-1].ID)
+	}
+	return answer, nil
+}
+
+// postChat posts body to url with headers and decodes the JSON answer into out.
+func postChat(ctx context.Context, client *http.Client, url string, headers map[string]string, body any, out any) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return &AssistantAPIError{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(msg))}
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(out); err != nil {
+		return fmt.Errorf("assistant api: %w", err)
+	}
+	return nil
+}
+
+// OpenAIAssistant asks an OpenAI-compatible chat completions endpoint (POST /v1/chat/completions).
+type OpenAIAssistant struct {
+	URL    string
+	APIKey string
+	Model  string // e.g. gpt-4o-mini
+	Client *http.Client
+}
+
+func (a *OpenAIAssistant) Answer(ctx context.Context, question string, sources []AssistantSource) (*AssistantAnswer, error) {
+	headers := map[string]string{}
+	if a.APIKey != "" {
+		headers["Authorization"] = "Bearer " + a.APIKey
+	}
+	var out struct {
+		Choices []struct {
+			Message struct {
+				Content string `json:"content"`
+			} `json:"message"`
+		} `json:"choices"`
+	}
+	err := postChat(ctx, a.Client, a.URL, headers, map[string]any{
+		"model":       a.Model,
+		"temperature": 0,
+		"messages": []map[string]string{
+			{"role": "system", "content": assistantSystemPrompt},
+			{"role": "user", "content": assistantPrompt(question, sources)},
+		},
+	}, &out)
+	if err != nil {
+		return nil, err
+	}
+	if len(out.Choices) == 0 {
+		return nil, errors.New("assistant api: no answer")
+	}
+	return parseAssistantAnswer(out.Choices[0].Message.Content, sources)
+}
+
+// AnthropicAssistant asks the Anthropic Messages API (POST /v1/messages).
+type AnthropicAssistant struct {
+	URL    string
+	APIKey string
+	Model  string
+	Client *http.Client
+}
+
+func (a *AnthropicAssistant) Answer(ctx context.Context, question string, sources []AssistantSource) (*AssistantAnswer, error) {
+	var out struct {
+		Content []struct {
+			Type string `json:"type"`
+			Text string `json:"text"`
+		} `json:"content"`
+	}
+	err := postChat(ctx, a.Client, a.URL, map[string]string{"x-api-key": a.APIKey, "anthropic-version": "2023-06-01"}, map[string]any{
+		"model":       a.Model,
+		"max_tokens":  1024,
+		"temperature": 0,
+		"system":      assistantSystemPrompt,
+		"messages":    []map[string]string{{"role": "user", "content": assistantPrompt(question, sources)}},
+	}, &out)
+	if err != nil {
+		return nil, err
+	}
+	var text strings.Builder
+	for _, c := range out.Content {
+		if c.Type == "text" {
+			text.WriteString(c.Text)
+		}
+	}
+	if text.Len() == 0 {
+		return nil, errors.New("assistant api: no answer")
+	}
+	return parseAssistantAnswer(text.String(), sources)
+}
@@ -0,0 +1,231 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// ClassifyTextChars is how much of a book's text a Classifier is given, from the start.
+const ClassifyTextChars = 20000
+
+// MaxSuggestedCategories is the most categories a Classifier suggests for one book.
+const MaxSuggestedCategories = 3
+
+// ClassifyInput is what a Classifier knows about a book.
+type ClassifyInput struct {
+	Title       string
+	Authors     []string
+	Description string
+	Text        string // the start of the book's text (EPUBs), up to ClassifyTextChars; may be empty
+}
+
+// Classifier suggests categories for books that have none. KeywordClassifier and ChatClassifier implement it.
+type Classifier interface {
+	// Classify returns up to MaxSuggestedCategories categories for the book, best first; none when it can't tell.
+	Classify(ctx context.Context, in ClassifyInput) ([]string, error)
+}
+
+// CategoryRule suggests Category for books that mention its keywords (lower case; phrases allowed).
+type CategoryRule struct {
+	Category string
+	Keywords []string
+}
+
+// DefaultCategoryRules are the categories KeywordClassifier and ChatClassifier choose from by default, named as
+// Google Books names them so suggestions sit alongside looked-up categories.
+var DefaultCategoryRules = []CategoryRule{
+	{"Fiction / Science Fiction", []string{"spaceship", "starship", "galaxy", "planet", "alien", "robot", "android", "time travel", "interstellar", "space station", "colony", "dystopia", "cyborg"}},
+	{"Fiction / Fantasy", []string{"dragon", "wizard", "sorcerer", "magic", "spell", "kingdom", "elf", "elves", "dwarf", "quest", "sword", "enchanted", "witch", "prophecy"}},
+	{"Fiction / Mystery & Detective", []string{"detective", "murder", "inspector", "investigation", "clue", "suspect", "alibi", "culprit", "sleuth", "crime scene", "whodunit"}},
+	{"Fiction / Thrillers", []string{"thriller", "assassin", "conspiracy", "spy", "hostage", "kidnapped", "agent", "fugitive", "chase", "terrorist"}},
+	{"Fiction / Romance", []string{"love", "romance", "heart", "kiss", "wedding", "marriage", "courtship", "lover", "passion", "engaged"}},
+	{"Fiction / Horror", []string{"horror", "ghost", "haunted", "vampire", "demon", "monster", "zombie", "nightmare", "terror", "undead"}},
+	{"Juvenile Fiction", []string{"children", "kids", "picture book", "bedtime", "young readers", "ages 4", "ages 6", "ages 8", "puppy", "bunny"}},
+	{"History", []string{"history", "historical", "century", "war", "empire", "revolution", "ancient", "medieval", "dynasty", "civilization", "battle"}},
+	{"Biography & Autobiography", []string{"biography", "memoir", "autobiography", "his life", "her life", "born in", "childhood", "career", "life story"}},
+	{"Science", []string{"science", "scientific", "physics", "chemistry", "biology", "evolution", "quantum", "experiment", "universe", "research"}},
+	{"Mathematics", []string{"mathematics", "theorem", "proof", "algebra", "calculus", "geometry", "equation", "probability", "statistics"}},
+	{"Computers", []string{"software", "programming", "computer", "algorithm", "code", "developer", "database", "network", "internet", "machine learning"}},
+	{"Business & Economics", []string{"business", "economics", "market", "management", "leadership", "company", "investment", "finance", "startup", "entrepreneur", "strategy"}},
+	{"Self-Help", []string{"self-help", "habits", "productivity", "motivation", "happiness", "mindset", "success", "anxiety", "confidence", "personal growth"}},
+	{"Philosophy", []string{"philosophy", "philosopher", "ethics", "metaphysics", "existence", "reason", "morality", "consciousness", "stoic"}},
+	{"Religion", []string{"religion", "god", "faith", "church", "bible", "prayer", "spiritual", "theology", "scripture", "buddhism"}},
+	{"Cooking", []string{"recipe", "recipes", "cooking", "kitchen", "ingredients", "bake", "baking", "cuisine", "chef", "tablespoon"}},
+	{"Health & Fitness", []string{"health", "fitness", "diet", "exercise", "nutrition", "weight", "workout", "wellness", "sleep"}},
+	{"Travel", []string{"travel", "journey", "guidebook", "itinerary", "destination", "tourist", "road trip", "backpacking"}},
+	{"Poetry", []string{"poetry", "poems", "poem", "verse", "sonnet", "stanza", "poet"}},
+	{"Art", []string{"art", "painting", "artist", "drawing", "sculpture", "museum", "design", "photography"}},
+	{"Music", []string{"music", "musician", "song", "band", "album", "composer", "orchestra", "guitar", "jazz"}},
+}
+
+// CategoryNames returns the categories of rules, in order.
+func CategoryNames(rules []CategoryRule) []string {
+	names := make([]string, len(rules))
+	for i, r := range rules {
+		names[i] = r.Category
+	}
+	return names
+}
+
+// KeywordClassifier suggests categories by counting rule keywords in the title, description and text; matches in
+// the title and description count more than ones in the text.
+type KeywordClassifier struct {
+	Rules []CategoryRule // nil = DefaultCategoryRules
+}
+
+// keywordMinScore is the least score a category needs to be suggested: a few mentions, not a passing one.
+const keywordMinScore = 4
+
+func (k *KeywordClassifier) Classify(ctx context.Context, in ClassifyInput) ([]string, error) {
+	rules := k.Rules
+	if rules == nil {
+		rules = DefaultCategoryRules
+	}
+	about := " " + normalizeWords(in.Title+" "+in.Description) + " "
+	text := " " + normalizeWords(in.Text) + " "
+	type scored struct {
+		category string
+		score    int
+	}
+	var found []scored
+	for _, rule := range rules {
+		score := 0
+		for _, kw := range rule.Keywords {
+			kw = " " + normalizeWords(kw) + " "
+			score += 3*strings.Count(about, kw) + min(strings.Count(text, kw), 5)
+		}
+		if score >= keywordMinScore {
+			found = append(found, scored{rule.Category, score})
+		}
+	}
+	sort.SliceStable(found, func(i, j int) bool { return found[i].score > found[j].score })
+	var categories []string
+	for _, f := range found {
+		// Only categories nearly as strong as the best, so a novel's passing mention of a war isn't History.
+		if len(categories) == MaxSuggestedCategories || 2*f.score < found[0].score {
+			break
+		}
+		categories = append(categories, f.category)
+	}
+	return categories, nil
+}
+
+// normalizeWords lower-cases s and keeps only its words, separated by single spaces.
+func normalizeWords(s string) string {
+	return strings.Join(strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r) && r != '-'
+	}), " ")
+}
+
+// ChatClassifier asks an OpenAI-compatible chat completions endpoint (POST /v1/chat/completions) to pick
+// categories from Categories.
+type ChatClassifier struct {
+	URL        string
+	APIKey     string
+	Model      string   // e.g. gpt-4o-mini
+	Categories []string // the categories to choose from; nil = those of DefaultCategoryRules
+	Client     *http.Client
+}
+
+// ClassifierAPIError is an error answer from a classifier API.
+type ClassifierAPIError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *ClassifierAPIError) Error() string {
+	return fmt.Sprintf("classifier api: %d %s", e.StatusCode, e.Message)
+}
+
+// IsPermanentClassifierError reports whether err is an answer from a classifier API that trying again won't
+// change: a 4xx other than a timeout or rate limit.
+func IsPermanentClassifierError(err error) bool {
+	var apiErr *ClassifierAPIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode/100 == 4 &&
+		apiErr.StatusCode != http.StatusRequestTimeout && apiErr.StatusCode != http.StatusTooManyRequests
+}
+
+func (c *ChatClassifier) Classify(ctx context.Context, in ClassifyInput) ([]string, error) {
+	categories := c.Categories
+	if categories == nil {
+		categories = CategoryNames(DefaultCategoryRules)
+	}
+	prompt := fmt.Sprintf("Title: %s\nAuthors: %s\nDescription: %s\n\nStart of the book:\n%s",
+		in.Title, strings.Join(in.Authors, ", "), in.Description, in.Text)
+	body, err := json.Marshal(map[string]any{
+		"model":       c.Model,
+		"temperature": 0,
+		"messages": []map[string]string{
+			{"role": "system", "content": fmt.Sprintf("You file books in a library. Choose up to %d categories that fit the book, best first, "+
+				"only from this list: %s. Answer with JSON only: {\"categories\": [...]}, with an empty list if none fit.",
+				MaxSuggestedCategories, strings.Join(categories, "; "))},
+			{"role": "user", "content": prompt},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.APIKey)
+	}
+	client := c.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, &ClassifierAPIError{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(msg))}
+	}
+	var out struct {
+		Choices []struct {
+			Message struct {
+				Content string `json:"content"`
+			} `json:"message"`
+		} `json:"choices"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&out); err != nil {
+		return nil, fmt.Errorf("classifier api: %w", err)
+	}
+	if len(out.Choices) == 0 {
+		return nil, fmt.Errorf("classifier api: no answer")
+	}
+	// Models sometimes wrap the JSON in a code fence.
+	content := strings.TrimSpace(out.Choices[0].Message.Content)
+	if i, j := strings.Index(content, "{"), strings.LastIndex(content, "}"); i >= 0 && j > i {
+		content = content[i : j+1]
+	}
+	var answer struct {
+		Categories []string `json:"categories"`
+	}
+	if err := json.Unmarshal([]byte(content), &answer); err != nil {
+		return nil, fmt.Errorf("classifier api: unexpected answer %q", content)
+	}
+	// Only categories from the list, as the list writes them.
+	var picked []string
+	for _, a := range answer.Categories {
+		i := slices.IndexFunc(categories, func(c string) bool { return strings.EqualFold(c, strings.TrimSpace(a)) })
+		if i >= 0 && !slices.Contains(picked, categories[i]) && len(picked) < MaxSuggestedCategories {
+			picked = append(picked, categories[i])
+		}
+	}
+	return picked, nil
+}
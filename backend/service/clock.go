@@ -0,0 +1,15 @@
+package service
+
+import "time"
+
+// Clock tells the time. Handlers take one so tests can fix timestamps.
+type Clock interface {
+	Now() time.Time
+}
+
+// SystemClock is the real clock.
+type SystemClock struct{}
+
+func (SystemClock) Now() time.Time {
+	return time.Now()
+}
@@ -0,0 +1,92 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Converter converts books between formats for devices that can't take the stored one. CalibreConverter
+// implements it.
+type Converter interface {
+	// CanConvert reports whether books in format from can be converted to format to.
+	CanConvert(from, to string) bool
+	// Convert reads a book in format from and returns it converted to format to, with its size. Closing out
+	// releases any temporary files.
+	Convert(ctx context.Context, in io.Reader, from, to string) (out io.ReadCloser, size int64, err error)
+}
+
+// CalibreConverter runs Calibre's ebook-convert.
+type CalibreConverter struct {
+	Path string // ebook-convert executable
+}
+
+// calibreFormats are the formats books are converted from and to.
+var calibreFormats = map[string]bool{"epub": true, "pdf": true, "mobi": true, "azw3": true}
+
+func (c *CalibreConverter) CanConvert(from, to string) bool {
+	return calibreFormats[from] && calibreFormats[to] && from != to
+}
+
+func (c *CalibreConverter) Convert(ctx context.Context, in io.Reader, from, to string) (io.ReadCloser, int64, error) {
+	if !c.CanConvert(from, to) {
+		return nil, 0, fmt.Errorf("cannot convert %s to %s", from, to)
+	}
+	dir, err := os.MkdirTemp("", "books-convert-")
+	if err != nil {
+		return nil, 0, err
+	}
+	cleanup := func() { os.RemoveAll(dir) }
+	src, dst := filepath.Join(dir, "book."+from), filepath.Join(dir, "book."+to)
+	f, err := os.Create(src)
+	if err == nil {
+		_, err = io.Copy(f, in)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+	}
+	if err != nil {
+		cleanup()
+		return nil, 0, err
+	}
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, c.Path, src, dst)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		cleanup()
+		msg := strings.TrimSpace(stderr.String())
+		if len(msg) > 300 {
+			msg = msg[len(msg)-300:]
+		}
+		return nil, 0, fmt.Errorf("ebook-convert: %w: %s", err, msg)
+	}
+	out, err := os.Open(dst)
+	if err != nil {
+		cleanup()
+		return nil, 0, err
+	}
+	info, err := out.Stat()
+	if err != nil {
+		out.Close()
+		cleanup()
+		return nil, 0, err
+	}
+	return &tempFile{File: out, cleanup: cleanup}, info.Size(), nil
+}
+
+// tempFile removes its directory when closed.
+type tempFile struct {
+	*os.File
+	cleanup func()
+}
+
+func (t *tempFile) Close() error {
+	err := t.File.Close()
+	t.cleanup()
+	return err
+}
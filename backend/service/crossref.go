@@ -0,0 +1,152 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const crossrefBase = "https://api.crossref.org"
+
+// Crossref fetches metadata for DOIs from the Crossref REST API, which registers the DOIs of most journal
+// articles, conference papers, theses and reports.
+type Crossref struct {
+	Mailto  string        // contact address sent with requests, for Crossref's faster "polite" pool; may be empty
+	BaseURL string        // "" = https://api.crossref.org
+	Timeout time.Duration // per lookup; 0 = 15s
+	Client  *http.Client  // nil = DefaultOutbound
+}
+
+// crossrefWork is the part of GET /works/{doi} books keep.
+type crossrefWork struct {
+	DOI       string   `json:"DOI"`
+	Type      string   `json:"type"`
+	Title     []string `json:"title"`
+	Subtitle  []string `json:"subtitle"`
+	Container []string `json:"container-title"`
+	Publisher string   `json:"publisher"`
+	Page      string   `json:"page"`
+	Abstract  string   `json:"abstract"` // JATS XML
+	Subject   []string `json:"subject"`
+	ISBN      []string `json:"ISBN"`
+	Author    []struct {
+		Given  string `json:"given"`
+		Family string `json:"family"`
+		Name   string `json:"name"` // organizations
+	} `json:"author"`
+	Institution []struct {
+		Name string `json:"name"`
+	} `json:"institution"` // theses: the awarding institution
+	Issued struct {
+		DateParts [][]int `json:"date-parts"`
+	} `json:"issued"`
+}
+
+// crossrefBookTypes are the Crossref work types that are books rather than documents.
+var crossrefBookTypes = map[string]bool{"book": true, "monograph": true, "edited-book": true, "reference-book": true, "book-set": true}
+
+// FetchByDOI maps the work's title and subtitle, authors, journal (or proceedings, or book for chapters),
+// publisher (the institution for theses), issue date, page count from its page range, abstract and subjects.
+func (c Crossref) FetchByDOI(ctx context.Context, doi string) (*BookMetadata, error) {
+	doi = strings.TrimSpace(doi)
+	if doi == "" {
+		return nil, fmt.Errorf("doi is required")
+	}
+	timeout := c.Timeout
+	if timeout <= 0 {
+		timeout = defaultGoogleBooksTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	base := c.BaseURL
+	if base == "" {
+		base = crossrefBase
+	}
+	u := strings.TrimSuffix(base, "/") + "/works/" + strings.ReplaceAll(url.PathEscape(doi), "%2F", "/")
+	if c.Mailto != "" {
+		u += "?mailto=" + url.QueryEscape(c.Mailto)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := outboundClient(c.Client).Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("%w for doi %s", ErrNoMetadata, doi)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("crossref returned %d", resp.StatusCode)
+	}
+	var data struct {
+		Message crossrefWork `json:"message"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
+		return nil, err
+	}
+	return data.Message.metadata(doi), nil
+}
+
+func (w *crossrefWork) metadata(doi string) *BookMetadata {
+	m := &BookMetadata{DOI: w.DOI, Publisher: w.Publisher, Categories: w.Subject, Document: !crossrefBookTypes[w.Type], Provider: "crossref"}
+	if m.DOI == "" {
+		m.DOI = doi
+	}
+	if len(w.Title) > 0 {
+		m.Title = plainText(w.Title[0])
+	}
+	if len(w.Subtitle) > 0 && w.Subtitle[0] != "" {
+		m.Title += ": " + plainText(w.Subtitle[0])
+	}
+	for _, a := range w.Author {
+		name := strings.TrimSpace(a.Given + " " + a.Family)
+		if name == "" {
+			name = a.Name
+		}
+		if name != "" {
+			m.Authors = append(m.Authors, name)
+		}
+	}
+	if len(w.Container) > 0 {
+		m.Journal = plainText(w.Container[0])
+	}
+	if w.Type == "dissertation" && len(w.Institution) > 0 {
+		m.Publisher = w.Institution[0].Name
+	}
+	if len(w.Issued.DateParts) > 0 {
+		var parts []string
+		for i, n := range w.Issued.DateParts[0] {
+			if i == 0 {
+				parts = append(parts, fmt.Sprintf("%04d", n))
+			} else {
+				parts = append(parts, fmt.Sprintf("%02d", n))
+			}
+		}
+		m.PublishDate = strings.Join(parts, "-")
+	}
+	if first, last, ok := strings.Cut(w.Page, "-"); ok {
+		f, ferr := strconv.Atoi(strings.TrimSpace(first))
+		l, lerr := strconv.Atoi(strings.TrimSpace(last))
+		if ferr == nil && lerr == nil && l >= f {
+			m.PageCount = l - f + 1
+		}
+	}
+	m.Preface = strings.TrimPrefix(plainText(w.Abstract), "Abstract ") // JATS abstracts often start with a title
+	if len(m.Categories) > 0 {
+		m.Category = m.Categories[0]
+	}
+	if !m.Document && len(w.ISBN) > 0 {
+		m.ISBN = strings.ReplaceAll(w.ISBN[0], "-", "")
+		m.CoverURL = openLibraryCoverURL(m.ISBN, "L")
+		m.ThumbnailURL = openLibraryCoverURL(m.ISBN, "M")
+	}
+	return m
+}
@@ -0,0 +1,260 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Drive uploads books to a user's cloud storage, linked with OAuth 2.0 (authorization code flow with an
+// offline refresh token). DropboxDrive and GoogleDrive implement it.
+type Drive interface {
+	// AuthURL is where the user grants access; the provider then redirects to redirectURL with code and state.
+	AuthURL(state, redirectURL string) string
+	// Link exchanges the authorization code for a refresh token and prepares the folder books are uploaded to.
+	Link(ctx context.Context, code, redirectURL string) (refreshToken, folder string, err error)
+	// Upload stores r as name in folder, keeping any existing file of that name.
+	Upload(ctx context.Context, refreshToken, folder, name string, r io.Reader) error
+}
+
+// driveClient allows for large books on slow uploads.
+var driveClient = &http.Client{Timeout: 10 * time.Minute}
+
+type oauthToken struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// postToken calls an OAuth token endpoint with form (client credentials included).
+func postToken(ctx context.Context, tokenURL string, form url.Values, provider string) (*oauthToken, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	var tok oauthToken
+	if err := doDriveAPI(req, provider, &tok); err != nil {
+		return nil, err
+	}
+	if tok.AccessToken == "" {
+		return nil, fmt.Errorf("%s returned no access token", provider)
+	}
+	return &tok, nil
+}
+
+// doDriveAPI sends req, decoding a JSON response into out (when not nil). A non-2xx response becomes an error
+// that includes the start of the body.
+func doDriveAPI(req *http.Request, provider string, out interface{}) error {
+	resp, err := driveClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s returned %d: %s", provider, resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// DropboxDrive uploads to a folder in the user's Dropbox.
+type DropboxDrive struct {
+	ClientID, ClientSecret string
+	Folder                 string // empty = /Books
+	BaseURL                string // empty = Dropbox's own hosts; tests point every request at one server
+}
+
+func (d *DropboxDrive) url(host, path string) string {
+	if d.BaseURL != "" {
+		return strings.TrimSuffix(d.BaseURL, "/") + path
+	}
+	return "https://" + host + path
+}
+
+func (d *DropboxDrive) AuthURL(state, redirectURL string) string {
+	q := url.Values{
+		"client_id":         {d.ClientID},
+		"response_type":     {"code"},
+		"redirect_uri":      {redirectURL},
+		"state":             {state},
+		"token_access_type": {"offline"},
+	}
+	return d.url("www.dropbox.com", "/oauth2/authorize") + "?" + q.Encode()
+}
+
+func (d *DropboxDrive) Link(ctx context.Context, code, redirectURL string) (string, string, error) {
+	tok, err := postToken(ctx, d.url("api.dropboxapi.com", "/oauth2/token"), url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {redirectURL},
+		"client_id":     {d.ClientID},
+		"client_secret": {d.ClientSecret},
+	}, "dropbox")
+	if err != nil {
+		return "", "", err
+	}
+	if tok.RefreshToken == "" {
+		return "", "", fmt.Errorf("dropbox returned no refresh token")
+	}
+	folder := d.Folder
+	if folder == "" {
+		folder = "/Books"
+	}
+	// Dropbox creates the folder with the first upload.
+	return tok.RefreshToken, folder, nil
+}
+
+func (d *DropboxDrive) Upload(ctx context.Context, refreshToken, folder, name string, r io.Reader) error {
+	access, err := d.accessToken(ctx, refreshToken)
+	if err != nil {
+		return err
+	}
+	arg, err := json.Marshal(map[string]interface{}{
+		"path":       strings.TrimSuffix(folder, "/") + "/" + name,
+		"mode":       "add",
+		"autorename": true,
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.url("content.dropboxapi.com", "/2/files/upload"), r)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+access)
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("Dropbox-API-Arg", asciiJSON(string(arg)))
+	return doDriveAPI(req, "dropbox", nil)
+}
+
+// asciiJSON escapes non-ASCII characters in a JSON document, as Dropbox requires for JSON in HTTP headers.
+func asciiJSON(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r < 0x80:
+			b.WriteRune(r)
+		case r > 0xFFFF:
+			r -= 0x10000
+			fmt.Fprintf(&b, `\u%04x\u%04x`, 0xD800+(r>>10), 0xDC00+(r&0x3FF))
+		default:
+			fmt.Fprintf(&b, `\u%04x`, r)
+		}
+	}
+	return b.String()
+}
+
+// GoogleDrive uploads to a folder it creates in the user's Google Drive. It asks only for the drive.file scope,
+// so it can see nothing but the files it created.
+type GoogleDrive struct {
+	ClientID, ClientSecret string
+	FolderName             string // empty = Books
+	BaseURL                string // empty = Google's own hosts; tests point every request at one server
+}
+
+func (g *GoogleDrive) url(host, path string) string {
+	if g.BaseURL != "" {
+		return strings.TrimSuffix(g.BaseURL, "/") + path
+	}
+	return "https://" + host + path
+}
+
+func (g *GoogleDrive) AuthURL(state, redirectURL string) string {
+	q := url.Values{
+		"client_id":     {g.ClientID},
+		"response_type": {"code"},
+		"redirect_uri":  {redirectURL},
+		"state":         {state},
+		"scope":         {"https://www.googleapis.com/auth/drive.file"},
+		"access_type":   {"offline"},
+		"prompt":        {"consent"}, // so a refresh token is issued even when relinking
+	}
+	return g.url("accounts.google.com", "/o/oauth2/v2/auth") + "?" + q.Encode()
+}
+
+func (g *GoogleDrive) Link(ctx context.Context, code, redirectURL string) (string, string, error) {
+	tok, err := postToken(ctx, g.url("oauth2.googleapis.com", "/token"), url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {redirectURL},
+		"client_id":     {g.ClientID},
+		"client_secret": {g.ClientSecret},
+	}, "google drive")
+	if err != nil {
+		return "", "", err
+	}
+	if tok.RefreshToken == "" {
+		return "", "", fmt.Errorf("google drive returned no refresh token")
+	}
+	name := g.FolderName
+	if name == "" {
+		name = "Books"
+	}
+	meta, err := json.Marshal(map[string]string{"name": name, "mimeType": "application/vnd.google-apps.folder"})
+	if err != nil {
+		return "", "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, g.url("www.googleapis.com", "/drive/v3/files"), strings.NewReader(string(meta)))
+	if err != nil {
+		return "", "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+tok.AccessToken)
+	req.Header.Set("Content-Type", "application/json")
+	var folder struct {
+		ID string `json:"id"`
+	}
+	if err := doDriveAPI(req, "google drive", &folder); err != nil {
+		return "", "", err
+	}
+	return tok.RefreshToken, folder.ID, nil
+}
+
+func (g *GoogleDrive) Upload(ctx context.Context, refreshToken, folder, name string, r io.Reader) error {
+	access, err := g.accessToken(ctx, refreshToken)
+	if err != nil {
+		return err
+	}
+	meta, err := json.Marshal(map[string]interface{}{"name": name, "parents": []string{folder}})
+	if err != nil {
+		return err
+	}
+	// A multipart/related body of the metadata then the file, streamed so the book is not held in memory.
+	pr, pw := io.Pipe()
+	form := multipart.NewWriter(pw)
+	go func() {
+		part, err := form.CreatePart(textproto.MIMEHeader{"Content-Type": {"application/json; charset=UTF-8"}})
+		if err == nil {
+			_, err = part.Write(meta)
+		}
+		if err == nil {
+			part, err = form.CreatePart(textproto.MIMEHeader{"Content-Type": {"application/octet-stream"}})
+		}
+		if err == nil {
+			_, err = io.Copy(part, r)
+		}
+		if err == nil {
+			err = form.Close()
+		}
+		pw.CloseWithError(err)
+	}()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, g.url("www.googleapis.com", "/upload/drive/v3/files?uploadType=multipart"), pr)
+	if err != nil {
+		pr.CloseWithError(err)
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+access)
+	req.Header.Set("Content-Type", "multipart/related; boundary="+form.Boundary())
+	err = doDriveAPI(req, "google drive", nil)
+	pr.CloseWithError(io.ErrClosedPipe) // unblocks the writer if the request failed before reading everything
+	return err
+}
@@ -0,0 +1,270 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// DriveFile is a file in a linked drive folder.
+type DriveFile struct {
+	ID       string // stays the same when the file is renamed or moved
+	Path     string // below the listed folder, for display
+	Size     int64
+	Revision string // changes whenever the content does
+}
+
+// DriveSource reads books from a user's cloud storage. It is linked separately from Drive, with read access to
+// the user's files rather than only to those the app created. DropboxDrive and GoogleDrive implement it.
+type DriveSource interface {
+	// ImportAuthURL is where the user grants read access; the provider then redirects to redirectURL.
+	ImportAuthURL(state, redirectURL string) string
+	// LinkImport exchanges the authorization code for a refresh token.
+	LinkImport(ctx context.Context, code, redirectURL string) (refreshToken string, err error)
+	// ListFiles returns the files in folder and its subfolders.
+	ListFiles(ctx context.Context, refreshToken, folder string) ([]DriveFile, error)
+	// Download opens a file listed by ListFiles.
+	Download(ctx context.Context, refreshToken, fileID string) (io.ReadCloser, error)
+}
+
+// downloadDriveFile sends req and returns the response body, or an error for a non-2xx response.
+func downloadDriveFile(req *http.Request, provider string) (io.ReadCloser, error) {
+	resp, err := driveClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		defer resp.Body.Close()
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("%s returned %d: %s", provider, resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	return resp.Body, nil
+}
+
+func (d *DropboxDrive) accessToken(ctx context.Context, refreshToken string) (string, error) {
+	tok, err := postToken(ctx, d.url("api.dropboxapi.com", "/oauth2/token"), url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {refreshToken},
+		"client_id":     {d.ClientID},
+		"client_secret": {d.ClientSecret},
+	}, "dropbox")
+	if err != nil {
+		return "", err
+	}
+	return tok.AccessToken, nil
+}
+
+// ImportAuthURL asks for the scopes to list and read files, which the Dropbox app must have enabled.
+func (d *DropboxDrive) ImportAuthURL(state, redirectURL string) string {
+	q := url.Values{
+		"client_id":         {d.ClientID},
+		"response_type":     {"code"},
+		"redirect_uri":      {redirectURL},
+		"state":             {state},
+		"token_access_type": {"offline"},
+		"scope":             {"files.metadata.read files.content.read"},
+	}
+	return d.url("www.dropbox.com", "/oauth2/authorize") + "?" + q.Encode()
+}
+
+func (d *DropboxDrive) LinkImport(ctx context.Context, code, redirectURL string) (string, error) {
+	refreshToken, _, err := d.Link(ctx, code, redirectURL)
+	return refreshToken, err
+}
+
+// ListFiles lists folder (a path such as /Calibre; "" or / for the whole Dropbox) recursively.
+func (d *DropboxDrive) ListFiles(ctx context.Context, refreshToken, folder string) ([]DriveFile, error) {
+	access, err := d.accessToken(ctx, refreshToken)
+	if err != nil {
+		return nil, err
+	}
+	folder = strings.TrimSuffix(folder, "/")
+	var files []DriveFile
+	endpoint, body := "/2/files/list_folder", map[string]interface{}{"path": folder, "recursive": true}
+	for {
+		arg, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.url("api.dropboxapi.com", endpoint), strings.NewReader(string(arg)))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", "Bearer "+access)
+		req.Header.Set("Content-Type", "application/json")
+		var page struct {
+			Entries []struct {
+				Tag         string `json:".tag"`
+				ID          string `json:"id"`
+				PathDisplay string `json:"path_display"`
+				Size        int64  `json:"size"`
+				Rev         string `json:"rev"`
+			} `json:"entries"`
+			Cursor  string `json:"cursor"`
+			HasMore bool   `json:"has_more"`
+		}
+		if err := doDriveAPI(req, "dropbox", &page); err != nil {
+			return nil, err
+		}
+		for _, e := range page.Entries {
+			if e.Tag != "file" {
+				continue
+			}
+			rel := e.PathDisplay
+			if strings.HasPrefix(strings.ToLower(rel), strings.ToLower(folder)) { // Dropbox paths are case-insensitive
+				rel = rel[len(folder):]
+			}
+			rel = strings.TrimPrefix(rel, "/")
+			files = append(files, DriveFile{ID: e.ID, Path: rel, Size: e.Size, Revision: e.Rev})
+		}
+		if !page.HasMore {
+			return files, nil
+		}
+		endpoint, body = "/2/files/list_folder/continue", map[string]interface{}{"cursor": page.Cursor}
+	}
+}
+
+func (d *DropboxDrive) Download(ctx context.Context, refreshToken, fileID string) (io.ReadCloser, error) {
+	access, err := d.accessToken(ctx, refreshToken)
+	if err != nil {
+		return nil, err
+	}
+	arg, err := json.Marshal(map[string]string{"path": fileID})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.url("content.dropboxapi.com", "/2/files/download"), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+access)
+	req.Header.Set("Dropbox-API-Arg", asciiJSON(string(arg)))
+	return downloadDriveFile(req, "dropbox")
+}
+
+func (g *GoogleDrive) accessToken(ctx context.Context, refreshToken string) (string, error) {
+	tok, err := postToken(ctx, g.url("oauth2.googleapis.com", "/token"), url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {refreshToken},
+		"client_id":     {g.ClientID},
+		"client_secret": {g.ClientSecret},
+	}, "google drive")
+	if err != nil {
+		return "", err
+	}
+	return tok.AccessToken, nil
+}
+
+// ImportAuthURL asks for read-only access to all of the user's files, as importing reads files the app did not
+// create (uploads need only drive.file).
+func (g *GoogleDrive) ImportAuthURL(state, redirectURL string) string {
+	q := url.Values{
+		"client_id":     {g.ClientID},
+		"response_type": {"code"},
+		"redirect_uri":  {redirectURL},
+		"state":         {state},
+		"scope":         {"https://www.googleapis.com/auth/drive.readonly"},
+		"access_type":   {"offline"},
+		"prompt":        {"consent"},
+	}
+	return g.url("accounts.google.com", "/o/oauth2/v2/auth") + "?" + q.Encode()
+}
+
+func (g *GoogleDrive) LinkImport(ctx context.Context, code, redirectURL string) (string, error) {
+	tok, err := postToken(ctx, g.url("oauth2.googleapis.com", "/token"), url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {redirectURL},
+		"client_id":     {g.ClientID},
+		"client_secret": {g.ClientSecret},
+	}, "google drive")
+	if err != nil {
+		return "", err
+	}
+	if tok.RefreshToken == "" {
+		return "", fmt.Errorf("google drive returned no refresh token")
+	}
+	return tok.RefreshToken, nil
+}
+
+// googleFolderType is the MIME type of Google Drive folders.
+const googleFolderType = "application/vnd.google-apps.folder"
+
+// ListFiles lists folder (a folder ID, as in the folder's URL; "" for the whole drive) and its subfolders.
+// Google Docs and other files without binary content are left out.
+func (g *GoogleDrive) ListFiles(ctx context.Context, refreshToken, folder string) ([]DriveFile, error) {
+	access, err := g.accessToken(ctx, refreshToken)
+	if err != nil {
+		return nil, err
+	}
+	if folder == "" {
+		folder = "root"
+	}
+	type pending struct{ id, path string }
+	var files []DriveFile
+	queue := []pending{{id: folder}}
+	for len(queue) > 0 {
+		dir := queue[0]
+		queue = queue[1:]
+		pageToken := ""
+		for {
+			q := url.Values{
+				"q":        {"'" + strings.ReplaceAll(dir.id, "'", `\'`) + "' in parents and trashed = false"},
+				"fields":   {"nextPageToken,files(id,name,mimeType,size,md5Checksum)"},
+				"pageSize": {"1000"},
+			}
+			if pageToken != "" {
+				q.Set("pageToken", pageToken)
+			}
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, g.url("www.googleapis.com", "/drive/v3/files")+"?"+q.Encode(), nil)
+			if err != nil {
+				return nil, err
+			}
+			req.Header.Set("Authorization", "Bearer "+access)
+			var page struct {
+				NextPageToken string `json:"nextPageToken"`
+				Files         []struct {
+					ID          string `json:"id"`
+					Name        string `json:"name"`
+					MimeType    string `json:"mimeType"`
+					Size        int64  `json:"size,string"`
+					MD5Checksum string `json:"md5Checksum"`
+				} `json:"files"`
+			}
+			if err := doDriveAPI(req, "google drive", &page); err != nil {
+				return nil, err
+			}
+			for _, f := range page.Files {
+				switch {
+				case f.MimeType == googleFolderType:
+					queue = append(queue, pending{id: f.ID, path: path.Join(dir.path, f.Name)})
+				case f.MD5Checksum != "":
+					files = append(files, DriveFile{ID: f.ID, Path: path.Join(dir.path, f.Name), Size: f.Size, Revision: f.MD5Checksum})
+				}
+			}
+			if page.NextPageToken == "" {
+				break
+			}
+			pageToken = page.NextPageToken
+		}
+	}
+	return files, nil
+}
+
+func (g *GoogleDrive) Download(ctx context.Context, refreshToken, fileID string) (io.ReadCloser, error) {
+	access, err := g.accessToken(ctx, refreshToken)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, g.url("www.googleapis.com", "/drive/v3/files/"+url.PathEscape(fileID))+"?alt=media", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+access)
+	return downloadDriveFile(req, "google drive")
+}
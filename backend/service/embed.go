@@ -0,0 +1,103 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// EmbedTextChars is how much of a book's text, from the start, is embedded with its metadata.
+const EmbedTextChars = 6000
+
+// Embedder turns text into vectors for semantic search. EmbeddingsAPI implements it.
+type Embedder interface {
+	// Model names the model; vectors from different models can't be compared.
+	Model() string
+	// Embed returns one vector per text, in order.
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+// EmbeddingsAPI embeds with an OpenAI-compatible embeddings endpoint (POST /v1/embeddings).
+type EmbeddingsAPI struct {
+	URL       string
+	APIKey    string
+	ModelName string // e.g. text-embedding-3-small
+	Client    *http.Client
+}
+
+// EmbeddingsAPIError is an error answer from an embeddings API.
+type EmbeddingsAPIError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *EmbeddingsAPIError) Error() string {
+	return fmt.Sprintf("embeddings api: %d %s", e.StatusCode, e.Message)
+}
+
+// IsPermanentEmbeddingsError reports whether err is an answer from an embeddings API that trying again won't
+// change: a 4xx other than a timeout or rate limit.
+func IsPermanentEmbeddingsError(err error) bool {
+	var apiErr *EmbeddingsAPIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode/100 == 4 &&
+		apiErr.StatusCode != http.StatusRequestTimeout && apiErr.StatusCode != http.StatusTooManyRequests
+}
+
+func (e *EmbeddingsAPI) Model() string {
+	return e.ModelName
+}
+
+func (e *EmbeddingsAPI) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	body, err := json.Marshal(map[string]any{"model": e.ModelName, "input": texts})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if e.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+e.APIKey)
+	}
+	client := e.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, &EmbeddingsAPIError{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(msg))}
+	}
+	var out struct {
+		Data []struct {
+			Index     int       `json:"index"`
+			Embedding []float32 `json:"embedding"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 64<<20)).Decode(&out); err != nil {
+		return nil, fmt.Errorf("embeddings api: %w", err)
+	}
+	vectors := make([][]float32, len(texts))
+	for _, d := range out.Data {
+		if d.Index < 0 || d.Index >= len(texts) || len(d.Embedding) == 0 {
+			return nil, fmt.Errorf("embeddings api: unexpected embedding %d", d.Index)
+		}
+		vectors[d.Index] = d.Embedding
+	}
+	for i, v := range vectors {
+		if v == nil {
+			return nil, fmt.Errorf("embeddings api: no embedding for input %d", i)
+		}
+	}
+	return vectors, nil
+}
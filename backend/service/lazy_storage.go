@@ -0,0 +1,191 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sync"
+	"time"
+)
+
+// ErrStorageUnavailable is returned by LazyStorage while its store cannot be opened or reached.
+var ErrStorageUnavailable = errors.New("storage unavailable")
+
+// Pinger is implemented by object stores that can check they are reachable with working credentials.
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// LazyStorage is an ObjectStore opened in the background, so the API can start (and serve the catalog) while S3 is
+// unreachable or misconfigured. Until Monitor has opened it, every call fails with ErrStorageUnavailable.
+type LazyStorage struct {
+	name string
+	open func(ctx context.Context) (ObjectStore, error)
+
+	ready chan struct{} // closed once the store is open
+
+	mu    sync.RWMutex
+	store ObjectStore
+	err   error  // last open or ping failure; nil while healthy
+	cause string // last failure logged, so a store that stays down is not logged on every retry
+}
+
+// NewLazyStorage returns a LazyStorage that opens its store with open; name is used in logs.
+func NewLazyStorage(name string, open func(ctx context.Context) (ObjectStore, error)) *LazyStorage {
+	return &LazyStorage{name: name, open: open, ready: make(chan struct{}), err: fmt.Errorf("%w: %s not connected yet", ErrStorageUnavailable, name)}
+}
+
+// Monitor opens the store, retrying with backoff up to interval, and then pings it every interval when it is a
+// Pinger, until ctx is cancelled.
+func (s *LazyStorage) Monitor(ctx context.Context, interval time.Duration) {
+	delay := time.Second
+	for {
+		s.check(ctx)
+		wait := interval
+		if s.current() == nil {
+			wait = min(delay, interval)
+			delay *= 2
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+	}
+}
+
+// check opens the store if it is not yet open, else pings it, and records the outcome.
+func (s *LazyStorage) check(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	store := s.current()
+	var err error
+	if store == nil {
+		store, err = s.open(ctx)
+	}
+	if p, ok := store.(Pinger); ok && err == nil {
+		err = p.Ping(ctx)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err == nil && s.store == nil {
+		s.store = store
+		close(s.ready)
+	}
+	if err == nil {
+		if s.err != nil {
+			log.Printf("%s: available", s.name)
+		}
+		s.err, s.cause = nil, ""
+		return
+	}
+	s.err = fmt.Errorf("%w: %s: %v", ErrStorageUnavailable, s.name, err)
+	if err.Error() != s.cause {
+		s.cause = err.Error()
+		log.Printf("%s: unavailable: %v", s.name, err)
+	}
+}
+
+// Ready is closed once the store has been opened.
+func (s *LazyStorage) Ready() <-chan struct{} {
+	return s.ready
+}
+
+func (s *LazyStorage) current() ObjectStore {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.store
+}
+
+// Err returns nil while the store is open and reachable, else why it is not (wrapping ErrStorageUnavailable).
+func (s *LazyStorage) Err() error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.err
+}
+
+// get returns the store, or ErrStorageUnavailable while it has not been opened. A store that is open but failed
+// its last ping is still returned: the call may succeed, and reports its own error if not.
+func (s *LazyStorage) get() (ObjectStore, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.store == nil {
+		return nil, s.err
+	}
+	return s.store, nil
+}
+
+func (s *LazyStorage) Upload(ctx context.Context, prefix, originalFilename string, body io.Reader, contentType string) (string, error) {
+	store, err := s.get()
+	if err != nil {
+		return "", err
+	}
+	return store.Upload(ctx, prefix, originalFilename, body, contentType)
+}
+
+func (s *LazyStorage) UploadWithSHA256(ctx context.Context, prefix, originalFilename string, body io.Reader, contentType, sha256Hex string) (string, error) {
+	store, err := s.get()
+	if err != nil {
+		return "", err
+	}
+	return store.UploadWithSHA256(ctx, prefix, originalFilename, body, contentType, sha256Hex)
+}
+
+func (s *LazyStorage) Put(ctx context.Context, key string, body io.Reader, contentType string) error {
+	store, err := s.get()
+	if err != nil {
+		return err
+	}
+	return store.Put(ctx, key, body, contentType)
+}
+
+func (s *LazyStorage) GetObject(ctx context.Context, key string) (io.ReadCloser, string, error) {
+	store, err := s.get()
+	if err != nil {
+		return nil, "", err
+	}
+	return store.GetObject(ctx, key)
+}
+
+func (s *LazyStorage) HeadObject(ctx context.Context, key string) (*ObjectInfo, error) {
+	store, err := s.get()
+	if err != nil {
+		return nil, err
+	}
+	return store.HeadObject(ctx, key)
+}
+
+func (s *LazyStorage) OpenObject(ctx context.Context, key string) (Object, error) {
+	store, err := s.get()
+	if err != nil {
+		return nil, err
+	}
+	return store.OpenObject(ctx, key)
+}
+
+func (s *LazyStorage) PresignedGetURL(ctx context.Context, key string, expiry time.Duration, responseFilename string) (string, error) {
+	store, err := s.get()
+	if err != nil {
+		return "", err
+	}
+	return store.PresignedGetURL(ctx, key, expiry, responseFilename)
+}
+
+func (s *LazyStorage) Delete(ctx context.Context, key string) error {
+	store, err := s.get()
+	if err != nil {
+		return err
+	}
+	return store.Delete(ctx, key)
+}
+
+// StorageErr returns why storage cannot be used right now: nil when it is available or cannot tell (only
+// LazyStorage reports its state). Storage must not be nil.
+func StorageErr(storage ObjectStore) error {
+	if s, ok := storage.(*LazyStorage); ok {
+		return s.Err()
+	}
+	return nil
+}
@@ -0,0 +1,230 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// LocalStoragePath is the API path prefix LocalStorage serves signed downloads under (see ServeSigned).
+const LocalStoragePath = "/api/storage/"
+
+// LocalStorage is the ObjectStore backed by a directory on disk, for single-binary installs without S3.
+// Each object is a file at <root>/<key>; its content type and SHA-256 live in a <key>.meta JSON sidecar.
+// Download URLs are signed links to ServeSigned, mirroring S3 presigned URLs.
+type LocalStorage struct {
+	root   string
+	signer *URLSigner
+}
+
+type localMeta struct {
+	ContentType string `json:"contentType"`
+	SHA256      string `json:"sha256,omitempty"`
+}
+
+// NewLocalStorage stores objects under root, creating it if needed. signer signs download URLs.
+func NewLocalStorage(root string, signer *URLSigner) (*LocalStorage, error) {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return nil, err
+	}
+	return &LocalStorage{root: root, signer: signer}, nil
+}
+
+// path maps a key to its file, rejecting keys that would escape root.
+func (l *LocalStorage) path(key string) (string, error) {
+	p := filepath.Join(l.root, filepath.FromSlash(key))
+	if key == "" || !strings.HasPrefix(p, l.root+string(filepath.Separator)) || strings.HasSuffix(p, ".meta") {
+		return "", fmt.Errorf("invalid object key %q", key)
+	}
+	return p, nil
+}
+
+func (l *LocalStorage) Upload(ctx context.Context, prefix, originalFilename string, body io.Reader, contentType string) (string, error) {
+	return l.UploadWithSHA256(ctx, prefix, originalFilename, body, contentType, "")
+}
+
+func (l *LocalStorage) UploadWithSHA256(ctx context.Context, prefix, originalFilename string, body io.Reader, contentType, sha256Hex string) (string, error) {
+	key := prefix + uuid.New().String() + filepath.Ext(originalFilename)
+	if err := l.write(key, body, contentType, sha256Hex); err != nil {
+		return "", err
+	}
+	return key, nil
+}
+
+func (l *LocalStorage) Put(ctx context.Context, key string, body io.Reader, contentType string) error {
+	return l.write(key, body, contentType, "")
+}
+
+func (l *LocalStorage) PutWithSHA256(ctx context.Context, key string, body io.Reader, contentType, sha256Hex string) error {
+	return l.write(key, body, contentType, sha256Hex)
+}
+
+// write stores body via a temp file and rename, so readers never see a partial object.
+// If wantSHA256 is set, a body with a different hash is rejected (as S3 does).
+func (l *LocalStorage) write(key string, body io.Reader, contentType, wantSHA256 string) error {
+	p, err := l.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(p), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	h := sha256.New()
+	if _, err := io.Copy(tmp, io.TeeReader(body, h)); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	sum := hex.EncodeToString(h.Sum(nil))
+	if wantSHA256 != "" && !strings.EqualFold(wantSHA256, sum) {
+		return fmt.Errorf("sha256 mismatch for %s", key)
+	}
+	meta, err := json.Marshal(localMeta{ContentType: contentType, SHA256: sum})
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(p+".meta", meta, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), p)
+}
+
+func (l *LocalStorage) Delete(ctx context.Context, key string) error {
+	p, err := l.path(key)
+	if err != nil {
+		return err
+	}
+	os.Remove(p + ".meta")
+	if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (l *LocalStorage) GetObject(ctx context.Context, key string) (io.ReadCloser, string, error) {
+	obj, err := l.OpenObject(ctx, key)
+	if err != nil {
+		return nil, "", err
+	}
+	return obj, obj.Info().ContentType, nil
+}
+
+func (l *LocalStorage) HeadObject(ctx context.Context, key string) (*ObjectInfo, error) {
+	p, err := l.path(key)
+	if err != nil {
+		return nil, err
+	}
+	st, err := os.Stat(p)
+	if os.IsNotExist(err) {
+		return nil, ErrObjectNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	var meta localMeta
+	if b, err := os.ReadFile(p + ".meta"); err == nil {
+		json.Unmarshal(b, &meta)
+	}
+	info := &ObjectInfo{
+		Size:         st.Size(),
+		ContentType:  meta.ContentType,
+		LastModified: st.ModTime(),
+		SHA256:       meta.SHA256,
+	}
+	if meta.SHA256 != "" {
+		info.ETag = `"` + meta.SHA256 + `"`
+	} else {
+		info.ETag = fmt.Sprintf(`"%x-%x"`, st.Size(), st.ModTime().UnixNano())
+	}
+	return info, nil
+}
+
+type localObject struct {
+	*os.File
+	info ObjectInfo
+}
+
+func (o *localObject) Info() ObjectInfo {
+	return o.info
+}
+
+func (l *LocalStorage) OpenObject(ctx context.Context, key string) (Object, error) {
+	info, err := l.HeadObject(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	p, _ := l.path(key)
+	f, err := os.Open(p)
+	if os.IsNotExist(err) {
+		return nil, ErrObjectNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &localObject{File: f, info: *info}, nil
+}
+
+// PresignedGetURL returns a relative, signed URL to ServeSigned. The API base URL is prepended by the client.
+func (l *LocalStorage) PresignedGetURL(ctx context.Context, key string, expiry time.Duration, responseFilename string) (string, error) {
+	if _, err := l.path(key); err != nil {
+		return "", err
+	}
+	u := l.signer.Sign(LocalStoragePath+key, expiry)
+	if responseFilename != "" {
+		u += "&" + url.Values{"filename": {responseFilename}}.Encode()
+	}
+	return u, nil
+}
+
+// ServeSigned serves GET/HEAD LocalStoragePath<key> for URLs issued by PresignedGetURL (public; the signature stands in for auth).
+func (l *LocalStorage) ServeSigned(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !strings.HasPrefix(r.URL.Path, LocalStoragePath) || !l.signer.Verify(r.URL.Path, r.URL.Query()) {
+		http.Error(w, `{"error":"invalid or expired link"}`, http.StatusForbidden)
+		return
+	}
+	obj, err := l.OpenObject(r.Context(), strings.TrimPrefix(r.URL.Path, LocalStoragePath))
+	if err == ErrObjectNotFound {
+		http.Error(w, `{"error":"file not found"}`, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, `{"error":"failed to read file"}`, http.StatusInternalServerError)
+		return
+	}
+	defer obj.Close()
+	info := obj.Info()
+	if info.ContentType != "" {
+		w.Header().Set("Content-Type", info.ContentType)
+	}
+	w.Header().Set("ETag", info.ETag)
+	if name := r.URL.Query().Get("filename"); name != "" {
+		w.Header().Set("Content-Disposition", ContentDisposition(name))
+	}
+	http.ServeContent(w, r, "", info.LastModified, obj)
+}
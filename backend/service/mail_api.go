@@ -0,0 +1,158 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// mailAPIClient allows for large attachments on slow uploads.
+var mailAPIClient = &http.Client{Timeout: 2 * time.Minute}
+
+// MailgunMailer sends raw MIME messages through Mailgun's HTTP API.
+type MailgunMailer struct {
+	Domain  string
+	APIKey  string
+	From    string
+	BaseURL string // empty = https://api.mailgun.net; EU accounts use https://api.eu.mailgun.net
+}
+
+func (s *MailgunMailer) UsesSenderAccount() bool {
+	return false
+}
+
+func (s *MailgunMailer) Send(ctx context.Context, m *Mail) error {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	form.WriteField("to", m.To)
+	part, err := form.CreateFormFile("message", "message.mime")
+	if err != nil {
+		return err
+	}
+	if _, err := m.message(s.From).WriteTo(part); err != nil {
+		return err
+	}
+	if err := form.Close(); err != nil {
+		return err
+	}
+	base := s.BaseURL
+	if base == "" {
+		base = "https://api.mailgun.net"
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(base, "/")+"/v3/"+s.Domain+"/messages.mime", &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	req.SetBasicAuth("api", s.APIKey)
+	return doMailAPI(req, "mailgun")
+}
+
+// SendGridMailer sends through SendGrid's v3 mail/send API.
+type SendGridMailer struct {
+	APIKey string
+	From   string
+}
+
+func (s *SendGridMailer) UsesSenderAccount() bool {
+	return false
+}
+
+type sendGridAddress struct {
+	Email string `json:"email"`
+}
+
+type sendGridPersonalization struct {
+	To []sendGridAddress `json:"to"`
+}
+
+type sendGridRequest struct {
+	Personalizations []sendGridPersonalization `json:"personalizations"`
+	From             sendGridAddress           `json:"from"`
+	ReplyTo          *sendGridAddress          `json:"reply_to,omitempty"`
+	Subject          string                    `json:"subject"`
+	Content          []sendGridContent         `json:"content"`
+	Attachments      []sendGridAttached        `json:"attachments,omitempty"`
+}
+
+type sendGridContent struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+type sendGridAttached struct {
+	Content     string `json:"content"` // base64
+	Type        string `json:"type"`
+	Filename    string `json:"filename"`
+	Disposition string `json:"disposition"`
+}
+
+func (s *SendGridMailer) Send(ctx context.Context, m *Mail) error {
+	payload := sendGridRequest{
+		Personalizations: []sendGridPersonalization{{To: []sendGridAddress{{Email: m.To}}}},
+		From:             sendGridAddress{Email: s.From},
+		Subject:          m.Subject,
+		Content:          []sendGridContent{{Type: "text/plain", Value: m.Body}},
+	}
+	if m.HTML != "" {
+		payload.Content = append(payload.Content, sendGridContent{Type: "text/html", Value: m.HTML})
+	}
+	if m.From != "" && m.From != s.From {
+		payload.ReplyTo = &sendGridAddress{Email: m.From}
+	}
+	if m.Attachment != nil {
+		data, err := io.ReadAll(m.Attachment)
+		if err != nil {
+			return err
+		}
+		payload.Attachments = []sendGridAttached{{
+			Content:     base64.StdEncoding.EncodeToString(data),
+			Type:        m.attachmentType(),
+			Filename:    m.AttachmentName,
+			Disposition: "attachment",
+		}}
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "https://api.sendgrid.com/v3/mail/send", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+s.APIKey)
+	return doMailAPI(req, "sendgrid")
+}
+
+// MailAPIError is a mail API's non-2xx response.
+type MailAPIError struct {
+	Provider   string
+	StatusCode int
+	Message    string // the start of the body
+}
+
+func (e *MailAPIError) Error() string {
+	return fmt.Sprintf("%s returned %d: %s", e.Provider, e.StatusCode, e.Message)
+}
+
+// doMailAPI sends req and turns a non-2xx response into a *MailAPIError.
+func doMailAPI(req *http.Request, provider string) error {
+	resp, err := mailAPIClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return &MailAPIError{Provider: provider, StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(msg))}
+	}
+	return nil
+}
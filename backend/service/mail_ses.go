@@ -0,0 +1,53 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/sesv2"
+	"github.com/aws/aws-sdk-go-v2/service/sesv2/types"
+)
+
+// SESMailer sends raw MIME messages through the Amazon SES v2 API over HTTPS, from a verified address.
+type SESMailer struct {
+	client *sesv2.Client
+	from   string
+}
+
+func NewSESMailer(ctx context.Context, region, accessKeyID, secretAccessKey, from string) (*SESMailer, error) {
+	if from == "" {
+		return nil, fmt.Errorf("MAIL_FROM is required for SES")
+	}
+	opts := []func(*config.LoadOptions) error{config.WithRegion(region)}
+	if accessKeyID != "" && secretAccessKey != "" {
+		opts = append(opts, config.WithCredentialsProvider(
+			credentials.NewStaticCredentialsProvider(accessKeyID, secretAccessKey, ""),
+		))
+	}
+	cfg, err := config.LoadDefaultConfig(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &SESMailer{client: sesv2.NewFromConfig(cfg), from: from}, nil
+}
+
+func (s *SESMailer) UsesSenderAccount() bool {
+	return false
+}
+
+func (s *SESMailer) Send(ctx context.Context, m *Mail) error {
+	var raw bytes.Buffer
+	if _, err := m.message(s.from).WriteTo(&raw); err != nil {
+		return err
+	}
+	_, err := s.client.SendEmail(ctx, &sesv2.SendEmailInput{
+		FromEmailAddress: aws.String(s.from),
+		Destination:      &types.Destination{ToAddresses: []string{m.To}},
+		Content:          &types.EmailContent{Raw: &types.RawMessage{Data: raw.Bytes()}},
+	})
+	return err
+}
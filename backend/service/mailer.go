@@ -0,0 +1,134 @@
+package service
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"net/textproto"
+	"path/filepath"
+
+	mail "github.com/go-mail/mail/v2"
+)
+
+// Mail is an outgoing message with an optional attachment.
+type Mail struct {
+	From, To, Subject, Body string
+	HTML                    string // optional HTML alternative to the plain-text Body
+	AttachmentName          string
+	AttachmentType          string    // MIME type of the attachment; "" = guessed from AttachmentName's extension
+	Attachment              io.Reader // nil = no attachment
+	// Username and Password are the sender's own SMTP credentials (for Kindle sends, the user's iCloud account).
+	// Only used by mailers whose UsesSenderAccount is true.
+	Username, Password string
+}
+
+// Mailer delivers mail. SMTPMailer, SESMailer, MailgunMailer and SendGridMailer implement it.
+type Mailer interface {
+	Send(ctx context.Context, m *Mail) error
+	// UsesSenderAccount reports whether Send authenticates as the sender with Mail.Username/Password.
+	// Otherwise the mailer sends from its own configured address and Mail.From becomes the Reply-To.
+	UsesSenderAccount() bool
+}
+
+// IsPermanentMailError reports whether err from a Mailer is a rejection that sending again won't fix: an SMTP 5xx
+// reply (an unknown mailbox, failed authentication) or a mail API's 4xx response other than 429. Network
+// failures, SMTP 4xx replies (greylisting, a full mailbox) and API 5xx responses may pass.
+func IsPermanentMailError(err error) bool {
+	var sendErr *mail.SendError
+	if errors.As(err, &sendErr) {
+		err = sendErr.Cause
+	}
+	var smtpErr *textproto.Error
+	if errors.As(err, &smtpErr) {
+		return smtpErr.Code >= 500
+	}
+	var apiErr *MailAPIError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode/100 == 4 && apiErr.StatusCode != http.StatusTooManyRequests
+	}
+	return false
+}
+
+const (
+	iCloudSMTPHost = "smtp.mail.me.com"
+	iCloudSMTPPort = 587
+)
+
+// SMTPMailer sends through an SMTP server: STARTTLS on 587, implicit TLS on 465. The zero value sends through
+// iCloud as the sender. With Username set it authenticates as that account instead and sends from From.
+type SMTPMailer struct {
+	Host               string
+	Port               int
+	Username, Password string
+	From               string
+	TLSConfig          *tls.Config // nil = verify the server against the system roots
+	Pool               *SMTPPool   // keeps connections open between sends; nil opens one per send
+}
+
+func (s SMTPMailer) UsesSenderAccount() bool {
+	return s.Username == ""
+}
+
+func (s SMTPMailer) Send(ctx context.Context, m *Mail) error {
+	host, port := iCloudSMTPHost, iCloudSMTPPort
+	if s.Host != "" {
+		host, port = s.Host, s.Port
+	}
+	from, username, password := m.From, m.Username, m.Password
+	if !s.UsesSenderAccount() {
+		from, username, password = s.From, s.Username, s.Password
+	}
+	if s.Pool != nil {
+		return s.Pool.Send(ctx, host, port, username, password, s.TLSConfig, m.message(from))
+	}
+	return smtpDialer(host, port, username, password, s.TLSConfig).DialAndSend(m.message(from))
+}
+
+// smtpDialer returns a dialer for host:port logging in as username: implicit TLS on 465, mandatory STARTTLS
+// otherwise.
+func smtpDialer(host string, port int, username, password string, tlsConfig *tls.Config) *mail.Dialer {
+	d := mail.NewDialer(host, port, username, password)
+	if !d.SSL {
+		d.StartTLSPolicy = mail.MandatoryStartTLS
+	}
+	d.TLSConfig = tlsConfig
+	return d
+}
+
+// message builds the MIME message sent from from; when that isn't the requester's address, replies go to them.
+func (m *Mail) message(from string) *mail.Message {
+	msg := mail.NewMessage()
+	msg.SetHeader("From", from)
+	if m.From != "" && m.From != from {
+		msg.SetHeader("Reply-To", m.From)
+	}
+	msg.SetHeader("To", m.To)
+	msg.SetHeader("Subject", m.Subject)
+	msg.SetBody("text/plain", m.Body)
+	if m.HTML != "" {
+		msg.AddAlternative("text/html", m.HTML)
+	}
+	if m.Attachment != nil {
+		// Set both headers, so the type doesn't depend on the host's MIME table and names are quoted properly.
+		name := map[string]string{"name": m.AttachmentName}
+		msg.AttachReader(m.AttachmentName, m.Attachment, mail.SetHeader(map[string][]string{
+			"Content-Type":        {mime.FormatMediaType(m.attachmentType(), name)},
+			"Content-Disposition": {mime.FormatMediaType("attachment", map[string]string{"filename": m.AttachmentName})},
+		}))
+	}
+	return msg
+}
+
+// attachmentType is AttachmentType, or else the type of AttachmentName's extension.
+func (m *Mail) attachmentType() string {
+	if m.AttachmentType != "" {
+		return m.AttachmentType
+	}
+	if t := mime.TypeByExtension(filepath.Ext(m.AttachmentName)); t != "" {
+		return t
+	}
+	return "application/octet-stream"
+}
@@ -0,0 +1,243 @@
+package service
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SRU fetches MARC 21 records from an SRU (Search/Retrieve via URL) server, the HTTP interface library catalogues
+// such as the Library of Congress and OCLC offer alongside, or as a gateway to, Z39.50. It finds older and
+// academic titles Google Books lacks.
+type SRU struct {
+	URL       string        // the server's base URL, e.g. "http://lx2.loc.gov:210/LCDB"; query parameters in it are kept
+	ISBNIndex string        // CQL index for ISBNs; "" = "bath.isbn"
+	Timeout   time.Duration // per lookup; 0 = 15s
+	Client    *http.Client  // nil = DefaultOutbound
+}
+
+// defaultSRUIndex is the Bath profile ISBN index most SRU servers support.
+const defaultSRUIndex = "bath.isbn"
+
+// FetchByISBN looks up the first MARCXML record for the ISBN. ErrNoMetadata when the server has none.
+func (s SRU) FetchByISBN(ctx context.Context, isbn string) (*BookMetadata, error) {
+	isbn = strings.ReplaceAll(strings.TrimSpace(isbn), "-", "")
+	if isbn == "" {
+		return nil, fmt.Errorf("isbn is required")
+	}
+	timeout := s.Timeout
+	if timeout <= 0 {
+		timeout = defaultGoogleBooksTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		return nil, err
+	}
+	index := s.ISBNIndex
+	if index == "" {
+		index = defaultSRUIndex
+	}
+	q := u.Query()
+	if q.Get("version") == "" {
+		q.Set("version", "1.1")
+	}
+	q.Set("operation", "searchRetrieve")
+	q.Set("query", index+"="+isbn)
+	q.Set("recordSchema", "marcxml")
+	q.Set("recordPacking", "xml")
+	q.Set("maximumRecords", "1")
+	u.RawQuery = q.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	client := outboundClient(s.Client)
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("sru %s returned %d", u.Host, resp.StatusCode)
+	}
+	var data struct {
+		Records []struct {
+			Data struct {
+				Record *MARCRecord `xml:"record"`
+			} `xml:"recordData"`
+		} `xml:"records>record"`
+		Diagnostics []struct {
+			Message string `xml:"message"`
+		} `xml:"diagnostics>diagnostic"`
+	}
+	if err := xml.NewDecoder(resp.Body).Decode(&data); err != nil {
+		return nil, fmt.Errorf("sru %s: %w", u.Host, err)
+	}
+	if len(data.Diagnostics) > 0 {
+		return nil, fmt.Errorf("sru %s: %s", u.Host, data.Diagnostics[0].Message)
+	}
+	if len(data.Records) == 0 || data.Records[0].Data.Record == nil {
+		return nil, fmt.Errorf("%w for isbn %s", ErrNoMetadata, isbn)
+	}
+	return data.Records[0].Data.Record.Metadata(isbn), nil
+}
+
+// MARCRecord is a MARC 21 bibliographic record in MARCXML.
+type MARCRecord struct {
+	Leader        string `xml:"leader"`
+	ControlFields []struct {
+		Tag   string `xml:"tag,attr"`
+		Value string `xml:",chardata"`
+	} `xml:"controlfield"`
+	DataFields []MARCField `xml:"datafield"`
+}
+
+// MARCField is a data field with its indicators and subfields.
+type MARCField struct {
+	Tag       string `xml:"tag,attr"`
+	Ind1      string `xml:"ind1,attr"`
+	Ind2      string `xml:"ind2,attr"`
+	Subfields []struct {
+		Code  string `xml:"code,attr"`
+		Value string `xml:",chardata"`
+	} `xml:"subfield"`
+}
+
+// sub returns the field's first subfield with the code, without ISBD punctuation; "" when there is none.
+func (f MARCField) sub(code string) string {
+	for _, s := range f.Subfields {
+		if s.Code == code {
+			return marcTrim(s.Value)
+		}
+	}
+	return ""
+}
+
+func (r *MARCRecord) fields(tag string) []MARCField {
+	var out []MARCField
+	for _, f := range r.DataFields {
+		if f.Tag == tag {
+			out = append(out, f)
+		}
+	}
+	return out
+}
+
+// marcTrim strips the ISBD punctuation MARC subfields end with (" /", " :", ";", ",", a final period).
+func marcTrim(s string) string {
+	s = strings.TrimSpace(s)
+	for {
+		t := strings.TrimSpace(strings.TrimRight(s, "/:;,="))
+		if strings.HasSuffix(t, ".") && !strings.HasSuffix(t, "..") && !marcInitial.MatchString(t) {
+			t = strings.TrimSuffix(t, ".")
+		}
+		if t == s {
+			return s
+		}
+		s = t
+	}
+}
+
+var (
+	marcInitial = regexp.MustCompile(`(^|\s)\p{Lu}\.$`) // "Tolkien, J. R. R." keeps its last period
+	marcYear    = regexp.MustCompile(`\d{4}`)
+	marcPages   = regexp.MustCompile(`(\d+)\s*(?:p\b|p\.|pages)`)
+)
+
+// Metadata maps the record: 245 title and subtitle, 100/110 and 700/710 authors (added entries only without a
+// relator or as author), 020 ISBN (isbn when there is none), 250 edition, 264/260 publisher and date (008 when
+// those have no year), 300 page count, 520 summary, and 650/655 subject headings as categories.
+func (r *MARCRecord) Metadata(isbn string) *BookMetadata {
+	m := &BookMetadata{ISBN: isbn, Provider: "sru"}
+	for _, f := range r.fields("020") {
+		if a := strings.Fields(f.sub("a")); len(a) > 0 {
+			m.ISBN = strings.ReplaceAll(a[0], "-", "")
+			break
+		}
+	}
+	if t := r.fields("245"); len(t) > 0 {
+		m.Title = t[0].sub("a")
+		if b := t[0].sub("b"); b != "" {
+			m.Title += ": " + b
+		}
+	}
+	for _, tag := range []string{"100", "110", "700", "710"} {
+		for _, f := range r.fields(tag) {
+			if relator := strings.ToLower(f.sub("e")); tag[0] == '7' && (relator != "" && relator != "author" || f.sub("4") != "" && f.sub("4") != "aut") {
+				continue
+			}
+			name := f.sub("a")
+			if tag[1] == '0' && f.Ind1 == "1" { // surname first
+				if last, first, ok := strings.Cut(name, ","); ok {
+					name = strings.TrimSpace(first) + " " + strings.TrimSpace(last)
+				}
+			}
+			if name != "" {
+				m.Authors = append(m.Authors, name)
+			}
+		}
+	}
+	if e := r.fields("250"); len(e) > 0 {
+		m.Edition = e[0].sub("a")
+	}
+	for _, tag := range []string{"264", "260"} {
+		for _, f := range r.fields(tag) {
+			if tag == "264" && f.Ind2 != "1" { // 1: publication
+				continue
+			}
+			if m.Publisher == "" {
+				m.Publisher = strings.Trim(f.sub("b"), "[]")
+			}
+			if m.PublishDate == "" {
+				m.PublishDate = marcYear.FindString(f.sub("c"))
+			}
+		}
+	}
+	if m.PublishDate == "" {
+		for _, c := range r.ControlFields {
+			if c.Tag == "008" && len(c.Value) >= 11 && marcYear.MatchString(c.Value[7:11]) {
+				m.PublishDate = c.Value[7:11]
+			}
+		}
+	}
+	for _, f := range r.fields("300") {
+		for _, match := range marcPages.FindAllStringSubmatch(f.sub("a"), -1) {
+			if n, _ := strconv.Atoi(match[1]); n > m.PageCount {
+				m.PageCount = n
+			}
+		}
+	}
+	if s := r.fields("520"); len(s) > 0 {
+		m.Preface = s[0].sub("a")
+	}
+	for _, tag := range []string{"650", "655"} {
+		for _, f := range r.fields(tag) {
+			heading := f.sub("a")
+			for _, s := range f.Subfields {
+				if s.Code == "x" || s.Code == "v" {
+					heading += " -- " + marcTrim(s.Value)
+				}
+			}
+			if heading != "" && !slices.Contains(m.Categories, heading) {
+				m.Categories = append(m.Categories, heading)
+			}
+		}
+	}
+	if len(m.Categories) > 0 {
+		m.Category = m.Categories[0]
+	}
+	if m.ISBN != "" {
+		m.CoverURL = openLibraryCoverURL(m.ISBN, "L")
+		m.ThumbnailURL = openLibraryCoverURL(m.ISBN, "M")
+	}
+	return m
+}
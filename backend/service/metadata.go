@@ -1,46 +1,51 @@
 package service
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
 	"time"
 )
 
 const googleBooksBase = "https://www.googleapis.com/books/v1/volumes"
 
-// googleBooksClient has a short timeout so slow/hung responses don't block uploads.
-var googleBooksClient = &http.Client{Timeout: 15 * time.Second}
+// defaultGoogleBooksTimeout is short so slow/hung responses don't block uploads.
+const defaultGoogleBooksTimeout = 15 * time.Second
 
-// googleBooksVolumesResp is the response from GET /volumes?q=isbn:...
+// googleBooksVolumesResp is the response from GET /volumes?q=isbn:... (or inauthor:...)
 type googleBooksVolumesResp struct {
 	TotalItems int `json:"totalItems"`
 	Items      []struct {
-		VolumeInfo struct {
-			Title         string   `json:"title"`
-			Subtitle      string   `json:"subtitle"`
-			Authors       []string `json:"authors"`
-			Publisher     string   `json:"publisher"`
-			PublishedDate string   `json:"publishedDate"`
-			Description   string   `json:"description"`
-			PageCount     int      `json:"pageCount"`
-			Categories    []string `json:"categories"`
-			ImageLinks    struct {
-				SmallThumbnail string `json:"smallThumbnail"`
-				Thumbnail      string `json:"thumbnail"`
-			} `json:"imageLinks"`
-			IndustryIdentifiers []struct {
-				Type       string `json:"type"`
-				Identifier string `json:"identifier"`
-			} `json:"industryIdentifiers"`
-			AverageRating float64 `json:"averageRating"`
-			RatingsCount  int     `json:"ratingsCount"`
-		} `json:"volumeInfo"`
+		VolumeInfo googleBooksVolume `json:"volumeInfo"`
 	} `json:"items"`
 }
 
+type googleBooksVolume struct {
+	Title         string   `json:"title"`
+	Subtitle      string   `json:"subtitle"`
+	Authors       []string `json:"authors"`
+	Publisher     string   `json:"publisher"`
+	PublishedDate string   `json:"publishedDate"`
+	Description   string   `json:"description"`
+	PageCount     int      `json:"pageCount"`
+	Categories    []string `json:"categories"`
+	ImageLinks    struct {
+		SmallThumbnail string `json:"smallThumbnail"`
+		Thumbnail      string `json:"thumbnail"`
+	} `json:"imageLinks"`
+	IndustryIdentifiers []struct {
+		Type       string `json:"type"`
+		Identifier string `json:"identifier"`
+	} `json:"industryIdentifiers"`
+	AverageRating float64 `json:"averageRating"`
+	RatingsCount  int     `json:"ratingsCount"`
+}
+
 // BookMetadata is the normalized metadata we store and return.
 type BookMetadata struct {
 	Title         string
@@ -57,18 +62,65 @@ type BookMetadata struct {
 	Categories    []string
 	RatingAverage float64
 	RatingCount   int
+	DOI           string
+	Journal       string // the journal, proceedings or book a document appeared in
+	Document      bool   // a paper, thesis or report rather than a book
+	Provider      string // where it came from: googlebooks, sru, crossref, arxiv, or file for the book file's own
+}
+
+// ErrNoMetadata is returned by FetchByISBN when the ISBN is unknown; retrying will not help.
+var ErrNoMetadata = errors.New("no volume found")
+
+// MetadataProvider looks up book metadata by ISBN. GoogleBooks is the production implementation.
+type MetadataProvider interface {
+	// FetchByISBN gives up when ctx is done, returning its error.
+	FetchByISBN(ctx context.Context, isbn string) (*BookMetadata, error)
+}
+
+// DOIProvider looks up metadata by DOI, for papers and theses. Crossref is the production implementation.
+type DOIProvider interface {
+	// FetchByDOI returns ErrNoMetadata for unknown DOIs and gives up when ctx is done, returning its error.
+	FetchByDOI(ctx context.Context, doi string) (*BookMetadata, error)
+}
+
+// AuthorSearcher is implemented by metadata providers that can list an author's books (GoogleBooks does).
+type AuthorSearcher interface {
+	// SearchByAuthor returns up to limit of the author's books, most recently published first.
+	SearchByAuthor(ctx context.Context, author string, limit int) ([]BookMetadata, error)
 }
 
-// FetchMetadataByISBN fetches book metadata from Google Books API by ISBN.
-func FetchMetadataByISBN(isbn string) (*BookMetadata, error) {
+// TitleSearcher is implemented by metadata providers that can find books by title and author (GoogleBooks does),
+// for books without an ISBN.
+type TitleSearcher interface {
+	// SearchByTitle returns up to limit books matching title and, when given, author, best match first.
+	SearchByTitle(ctx context.Context, title, author string, limit int) ([]BookMetadata, error)
+}
+
+// GoogleBooks fetches metadata from the Google Books API.
+type GoogleBooks struct {
+	Timeout time.Duration // per lookup; 0 = 15s
+	Client  *http.Client  // nil = DefaultOutbound
+}
+
+// FetchByISBN fetches book metadata from Google Books API by ISBN.
+func (g GoogleBooks) FetchByISBN(ctx context.Context, isbn string) (*BookMetadata, error) {
 	isbn = strings.ReplaceAll(strings.TrimSpace(isbn), "-", "")
 	if isbn == "" {
 		return nil, fmt.Errorf("isbn is required")
 	}
+	timeout := g.Timeout
+	if timeout <= 0 {
+		timeout = defaultGoogleBooksTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
 	q := url.Values{}
 	q.Set("q", "isbn:"+isbn)
-	u := googleBooksBase + "?" + q.Encode()
-	resp, err := googleBooksClient.Get(u)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, googleBooksBase+"?"+q.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := outboundClient(g.Client).Do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -81,10 +133,15 @@ func FetchMetadataByISBN(isbn string) (*BookMetadata, error) {
 		return nil, err
 	}
 	if data.TotalItems == 0 || len(data.Items) == 0 {
-		return nil, fmt.Errorf("no volume found for isbn %s", isbn)
+		return nil, fmt.Errorf("%w for isbn %s", ErrNoMetadata, isbn)
 	}
-	vi := data.Items[0].VolumeInfo
+	return volumeMetadata(data.Items[0].VolumeInfo, isbn), nil
+}
+
+// volumeMetadata normalizes a Google Books volume; isbn is used when the volume lists none.
+func volumeMetadata(vi googleBooksVolume, isbn string) *BookMetadata {
 	meta := &BookMetadata{
+		Provider:      "googlebooks",
 		Title:         vi.Title,
 		Authors:       vi.Authors,
 		Publisher:     vi.Publisher,
@@ -115,7 +172,73 @@ func FetchMetadataByISBN(isbn string) (*BookMetadata, error) {
 		meta.ThumbnailURL = openLibraryCoverURL(meta.ISBN, "M")
 	}
 	meta.Preface = strings.TrimSpace(vi.Description)
-	return meta, nil
+	return meta
+}
+
+// SearchByAuthor lists the author's books on Google Books, most recently published first.
+func (g GoogleBooks) SearchByAuthor(ctx context.Context, author string, limit int) ([]BookMetadata, error) {
+	author = strings.TrimSpace(author)
+	if author == "" {
+		return nil, fmt.Errorf("author is required")
+	}
+	timeout := g.Timeout
+	if timeout <= 0 {
+		timeout = defaultGoogleBooksTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	q := url.Values{}
+	q.Set("q", `inauthor:"`+strings.ReplaceAll(author, `"`, "")+`"`)
+	q.Set("orderBy", "newest")
+	return g.volumes(ctx, q, limit)
+}
+
+// SearchByTitle searches Google Books by title and, when given, author, in Google's order of relevance.
+func (g GoogleBooks) SearchByTitle(ctx context.Context, title, author string, limit int) ([]BookMetadata, error) {
+	title = strings.TrimSpace(title)
+	if title == "" {
+		return nil, fmt.Errorf("title is required")
+	}
+	timeout := g.Timeout
+	if timeout <= 0 {
+		timeout = defaultGoogleBooksTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	query := `intitle:"` + strings.ReplaceAll(title, `"`, "") + `"`
+	if author = strings.TrimSpace(author); author != "" {
+		query += ` inauthor:"` + strings.ReplaceAll(author, `"`, "") + `"`
+	}
+	q := url.Values{}
+	q.Set("q", query)
+	return g.volumes(ctx, q, limit)
+}
+
+// volumes runs a volume search with query q, returning up to limit (at most 40) books.
+func (g GoogleBooks) volumes(ctx context.Context, q url.Values, limit int) ([]BookMetadata, error) {
+	q.Set("printType", "books")
+	q.Set("maxResults", strconv.Itoa(min(max(limit, 1), 40)))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, googleBooksBase+"?"+q.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := outboundClient(g.Client).Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("google books returned %d", resp.StatusCode)
+	}
+	var data googleBooksVolumesResp
+	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
+		return nil, err
+	}
+	books := make([]BookMetadata, 0, len(data.Items))
+	for _, item := range data.Items {
+		books = append(books, *volumeMetadata(item.VolumeInfo, ""))
+	}
+	return books, nil
 }
 
 // openLibraryCoverURL returns a direct cover image URL by ISBN. Size: S (small), M (medium), L (large). No captcha.
@@ -131,3 +254,58 @@ func openLibraryCoverURL(isbn, size string) string {
 	}
 	return "https://covers.openlibrary.org/b/isbn/" + url.PathEscape(clean) + "-" + size + ".jpg"
 }
+
+// MetadataChain asks its providers in order and returns the first metadata found, for METADATA_PROVIDERS.
+// Providers that have none (ErrNoMetadata) or fail are skipped; when all do, the chain returns ErrNoMetadata if
+// none failed, else the first failure.
+type MetadataChain []MetadataProvider
+
+func (c MetadataChain) FetchByISBN(ctx context.Context, isbn string) (*BookMetadata, error) {
+	var failed error
+	for _, p := range c {
+		meta, err := p.FetchByISBN(ctx, isbn)
+		if err == nil {
+			return meta, nil
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if !errors.Is(err, ErrNoMetadata) && failed == nil {
+			failed = err
+		}
+	}
+	if failed != nil {
+		return nil, failed
+	}
+	return nil, fmt.Errorf("%w for isbn %s", ErrNoMetadata, isbn)
+}
+
+// AuthorSearcherOf returns p as an AuthorSearcher: p itself, or for a MetadataChain the first provider in it that
+// is one.
+func AuthorSearcherOf(p MetadataProvider) (AuthorSearcher, bool) {
+	if chain, ok := p.(MetadataChain); ok {
+		for _, p := range chain {
+			if s, ok := AuthorSearcherOf(p); ok {
+				return s, true
+			}
+		}
+		return nil, false
+	}
+	s, ok := p.(AuthorSearcher)
+	return s, ok
+}
+
+// TitleSearcherOf returns p as a TitleSearcher: p itself, or for a MetadataChain the first provider in it that is
+// one.
+func TitleSearcherOf(p MetadataProvider) (TitleSearcher, bool) {
+	if chain, ok := p.(MetadataChain); ok {
+		for _, p := range chain {
+			if s, ok := TitleSearcherOf(p); ok {
+				return s, true
+			}
+		}
+		return nil, false
+	}
+	s, ok := p.(TitleSearcher)
+	return s, ok
+}
@@ -0,0 +1,309 @@
+package service
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ONIXProduct is one product record of an ONIX 3.0 message, mapped to the metadata books keep.
+type ONIXProduct struct {
+	RecordReference string
+	Delete          bool // NotificationType 05: the sender withdrew the record
+	Metadata        BookMetadata
+}
+
+// ErrNotONIX is returned by ParseONIX when the document is not an ONIX message. Messages must be UTF-8.
+var ErrNotONIX = errors.New("not an ONIX message")
+
+// onixShortTags maps the ONIX 3.0 short tags ParseONIX reads to their reference names, so messages in either
+// form parse the same.
+var onixShortTags = map[string]string{
+	"onixmessage": "ONIXMessage", "product": "Product", "a001": "RecordReference", "a002": "NotificationType",
+	"productidentifier": "ProductIdentifier", "b221": "ProductIDType", "b244": "IDValue",
+	"descriptivedetail": "DescriptiveDetail", "titledetail": "TitleDetail", "b202": "TitleType",
+	"titleelement": "TitleElement", "x409": "TitleElementLevel", "b203": "TitleText", "b030": "TitlePrefix",
+	"b031": "TitleWithoutPrefix", "b029": "Subtitle", "contributor": "Contributor", "b034": "SequenceNumber",
+	"b035": "ContributorRole", "b036": "PersonName", "b039": "NamesBeforeKey", "b040": "KeyNames",
+	"b047": "CorporateName", "b057": "EditionNumber", "b058": "EditionStatement", "extent": "Extent",
+	"b218": "ExtentType", "b219": "ExtentValue", "b220": "ExtentUnit", "subject": "Subject", "x425": "MainSubject",
+	"b067": "SubjectSchemeIdentifier", "b070": "SubjectHeadingText", "collateraldetail": "CollateralDetail",
+	"textcontent": "TextContent", "x426": "TextType", "d104": "Text", "supportingresource": "SupportingResource",
+	"x436": "ResourceContentType", "resourceversion": "ResourceVersion", "x435": "ResourceLink",
+	"publishingdetail": "PublishingDetail", "publisher": "Publisher", "b291": "PublishingRole",
+	"b081": "PublisherName", "publishingdate": "PublishingDate", "x448": "PublishingDateRole", "b306": "Date",
+}
+
+// onixNode is an element of the message; names are reference names.
+type onixNode struct {
+	name     string
+	text     strings.Builder
+	children []*onixNode
+}
+
+func (n *onixNode) all(name string) []*onixNode {
+	var out []*onixNode
+	if n == nil {
+		return out
+	}
+	for _, c := range n.children {
+		if c.name == name {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// child returns the first child named name, following a path of names; nil when there is none.
+func (n *onixNode) child(path ...string) *onixNode {
+	for _, name := range path {
+		if children := n.all(name); len(children) > 0 {
+			n = children[0]
+		} else {
+			return nil
+		}
+	}
+	return n
+}
+
+// value is the trimmed text of the child at path, "" when there is none.
+func (n *onixNode) value(path ...string) string {
+	if c := n.child(path...); c != nil {
+		return strings.TrimSpace(c.text.String())
+	}
+	return ""
+}
+
+// ParseONIX reads the product records of an ONIX 3.0 message (reference or short tags) and maps what books keep:
+// the ISBN, the distinctive title with its subtitle, authors (contributors with role A01, in sequence), subjects
+// with heading text as categories (the main subject first; keywords are ignored), the description, publisher,
+// publication date, page count, edition and front cover link.
+func ParseONIX(r io.Reader) ([]ONIXProduct, error) {
+	dec := xml.NewDecoder(r)
+	dec.Strict = false // descriptions often carry HTML entities such as &nbsp;
+	dec.Entity = xml.HTMLEntity
+	var (
+		products []ONIXProduct
+		stack    []*onixNode
+		root     bool
+	)
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			name := t.Name.Local
+			if ref, ok := onixShortTags[strings.ToLower(name)]; ok {
+				name = ref
+			}
+			if !root {
+				if name != "ONIXMessage" {
+					return nil, ErrNotONIX
+				}
+				for _, a := range t.Attr {
+					if a.Name.Local == "release" && !strings.HasPrefix(a.Value, "3") {
+						return nil, fmt.Errorf("ONIX release %s is not supported; only 3.0 is", a.Value)
+					}
+				}
+				root = true
+			}
+			n := &onixNode{name: name}
+			if len(stack) > 0 {
+				parent := stack[len(stack)-1]
+				parent.children = append(parent.children, n)
+			}
+			stack = append(stack, n)
+		case xml.CharData:
+			// Text of nested markup (XHTML descriptions) counts towards every element it is in.
+			for _, n := range stack {
+				if n.name != "ONIXMessage" && n.name != "Product" {
+					n.text.Write(t)
+				}
+			}
+		case xml.EndElement:
+			if len(stack) == 0 {
+				continue
+			}
+			n := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			if n.name == "Product" && len(stack) == 1 {
+				products = append(products, onixProduct(n))
+				stack[0].children = nil // keep memory flat on large messages
+			}
+		}
+	}
+	if !root {
+		return nil, ErrNotONIX
+	}
+	return products, nil
+}
+
+func onixProduct(p *onixNode) ONIXProduct {
+	out := ONIXProduct{RecordReference: p.value("RecordReference"), Delete: p.value("NotificationType") == "05"}
+	m := &out.Metadata
+
+	ids := map[string]string{}
+	for _, id := range p.all("ProductIdentifier") {
+		ids[id.value("ProductIDType")] = strings.ReplaceAll(id.value("IDValue"), "-", "")
+	}
+	switch gtin := ids["03"]; {
+	case ids["15"] != "":
+		m.ISBN = ids["15"]
+	case strings.HasPrefix(gtin, "978") || strings.HasPrefix(gtin, "979"):
+		m.ISBN = gtin
+	default:
+		m.ISBN = ids["02"]
+	}
+
+	d := p.child("DescriptiveDetail")
+	for _, td := range d.all("TitleDetail") {
+		if td.value("TitleType") != "01" {
+			continue
+		}
+		for _, te := range td.all("TitleElement") {
+			if level := te.value("TitleElementLevel"); level != "" && level != "01" {
+				continue
+			}
+			m.Title = te.value("TitleText")
+			if m.Title == "" {
+				m.Title = strings.TrimSpace(te.value("TitlePrefix") + " " + te.value("TitleWithoutPrefix"))
+			}
+			if sub := te.value("Subtitle"); sub != "" {
+				m.Title += ": " + sub
+			}
+			break
+		}
+		break
+	}
+
+	type author struct {
+		seq  int
+		name string
+	}
+	var authors []author
+	for i, c := range d.all("Contributor") {
+		if c.value("ContributorRole") != "A01" {
+			continue
+		}
+		name := c.value("PersonName")
+		if name == "" {
+			name = strings.TrimSpace(c.value("NamesBeforeKey") + " " + c.value("KeyNames"))
+		}
+		if name == "" {
+			name = c.value("CorporateName")
+		}
+		seq, err := strconv.Atoi(c.value("SequenceNumber"))
+		if err != nil {
+			seq = 1000 + i // unnumbered ones after the numbered, as listed
+		}
+		if name != "" {
+			authors = append(authors, author{seq, name})
+		}
+	}
+	sort.SliceStable(authors, func(i, j int) bool { return authors[i].seq < authors[j].seq })
+	for _, a := range authors {
+		m.Authors = append(m.Authors, a.name)
+	}
+
+	for _, s := range d.all("Subject") {
+		heading := s.value("SubjectHeadingText")
+		if heading == "" || s.value("SubjectSchemeIdentifier") == "20" { // 20: keywords
+			continue
+		}
+		if s.child("MainSubject") != nil && m.Category == "" {
+			m.Categories = append([]string{heading}, m.Categories...)
+			m.Category = heading
+		} else {
+			m.Categories = append(m.Categories, heading)
+		}
+	}
+	if m.Category == "" && len(m.Categories) > 0 {
+		m.Category = m.Categories[0]
+	}
+
+	pages := map[string]int{}
+	for _, e := range d.all("Extent") {
+		if n, err := strconv.Atoi(e.value("ExtentValue")); err == nil && e.value("ExtentUnit") == "03" { // 03: pages
+			pages[e.value("ExtentType")] = n
+		}
+	}
+	for _, t := range []string{"00", "11", "07"} { // main content, content, total numbered pages
+		if pages[t] > 0 {
+			m.PageCount = pages[t]
+			break
+		}
+	}
+
+	m.Edition = d.value("EditionStatement")
+	if m.Edition == "" {
+		if n := d.value("EditionNumber"); n != "" {
+			m.Edition = n
+		}
+	}
+
+	c := p.child("CollateralDetail")
+	texts := map[string]string{}
+	for _, t := range c.all("TextContent") {
+		if typ := t.value("TextType"); texts[typ] == "" {
+			texts[typ] = plainText(t.value("Text"))
+		}
+	}
+	m.Preface = texts["03"] // description
+	if m.Preface == "" {
+		m.Preface = texts["02"] // short description
+	}
+	for _, r := range c.all("SupportingResource") {
+		if r.value("ResourceContentType") == "01" { // front cover
+			m.CoverURL = r.value("ResourceVersion", "ResourceLink")
+			m.ThumbnailURL = m.CoverURL
+			break
+		}
+	}
+
+	pd := p.child("PublishingDetail")
+	for _, pub := range pd.all("Publisher") {
+		if role := pub.value("PublishingRole"); role == "" || role == "01" {
+			m.Publisher = pub.value("PublisherName")
+			break
+		}
+	}
+	for _, date := range pd.all("PublishingDate") {
+		if date.value("PublishingDateRole") == "01" {
+			m.PublishDate = onixDate(date.value("Date"))
+			break
+		}
+	}
+	return out
+}
+
+var htmlTag = regexp.MustCompile(`<[^>]*>`)
+
+// plainText turns text that may be (escaped) HTML or XML markup into plain text.
+func plainText(s string) string {
+	if strings.Contains(s, "<") {
+		s = htmlTag.ReplaceAllString(s, " ")
+	}
+	return strings.Join(strings.Fields(html.UnescapeString(s)), " ")
+}
+
+// onixDate turns an ONIX date (YYYYMMDD, YYYYMM or YYYY) into the form books keep (2006-01-02, 2006-01 or 2006).
+func onixDate(s string) string {
+	switch {
+	case len(s) >= 8:
+		return s[:4] + "-" + s[4:6] + "-" + s[6:8]
+	case len(s) == 6:
+		return s[:4] + "-" + s[4:6]
+	}
+	return s
+}
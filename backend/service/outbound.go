@@ -0,0 +1,151 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// DefaultUserAgent names the server to the APIs it calls.
+const DefaultUserAgent = "books/1.0 (+https://github.com/kevinaaaquil/books)"
+
+// ErrRateLimited is wrapped by the *RetryAfterError outbound clients return while an API has asked them to wait.
+var ErrRateLimited = errors.New("rate limited")
+
+// RetryAfterError is returned instead of sending a request to a host that answered an earlier one with 429 or 503
+// and a Retry-After longer than the client waits out.
+type RetryAfterError struct {
+	Host  string
+	Until time.Time
+}
+
+func (e *RetryAfterError) Error() string {
+	return fmt.Sprintf("%s asked to retry after %s", e.Host, e.Until.UTC().Format(time.RFC3339))
+}
+
+func (e *RetryAfterError) Unwrap() error { return ErrRateLimited }
+
+// OutboundOptions configure NewOutboundClient.
+type OutboundOptions struct {
+	UserAgent    string        // "" = DefaultUserAgent
+	MaxRetryWait time.Duration // longest Retry-After waited out before retrying; 0 = 30s
+	Retries      int           // retries of a 429 or 503; 0 = 2
+}
+
+// NewOutboundClient returns the client for requests to third-party APIs: metadata providers, cover images, price
+// stores and paper archives. Every request names the server (UserAgent, unless the request sets its own), and 429
+// and 503 answers are retried once their Retry-After has passed. A Retry-After longer than MaxRetryWait is not
+// waited out: the answer is returned, and further requests to the host fail with a *RetryAfterError until then
+// instead of being sent. Requests are otherwise bounded by their context and the client's 10-minute timeout.
+func NewOutboundClient(o OutboundOptions) *http.Client {
+	if o.UserAgent == "" {
+		o.UserAgent = DefaultUserAgent
+	}
+	if o.MaxRetryWait <= 0 {
+		o.MaxRetryWait = 30 * time.Second
+	}
+	if o.Retries <= 0 {
+		o.Retries = 2
+	}
+	base := http.DefaultTransport.(*http.Transport).Clone()
+	base.DialContext = (&net.Dialer{Timeout: 15 * time.Second, KeepAlive: 30 * time.Second}).DialContext
+	base.TLSHandshakeTimeout = 15 * time.Second
+	base.ResponseHeaderTimeout = time.Minute
+	base.MaxIdleConnsPerHost = 4
+	return &http.Client{
+		Timeout:   10 * time.Minute,
+		Transport: &outboundTransport{base: base, opts: o, blocked: map[string]time.Time{}},
+	}
+}
+
+// DefaultOutbound is the outbound client providers use when theirs is nil.
+var DefaultOutbound = NewOutboundClient(OutboundOptions{})
+
+func outboundClient(c *http.Client) *http.Client {
+	if c != nil {
+		return c
+	}
+	return DefaultOutbound
+}
+
+type outboundTransport struct {
+	base http.RoundTripper
+	opts OutboundOptions
+
+	mu      sync.Mutex
+	blocked map[string]time.Time // host -> when it may be asked again
+}
+
+func (t *outboundTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	host := req.URL.Host
+	t.mu.Lock()
+	until, ok := t.blocked[host]
+	if ok && !time.Now().Before(until) {
+		delete(t.blocked, host)
+		ok = false
+	}
+	t.mu.Unlock()
+	if ok {
+		return nil, &RetryAfterError{Host: host, Until: until}
+	}
+	if req.Header.Get("User-Agent") == "" {
+		req = req.Clone(req.Context())
+		req.Header.Set("User-Agent", t.opts.UserAgent)
+	}
+	for attempt := 0; ; attempt++ {
+		resp, err := t.base.RoundTrip(req)
+		if err != nil || (resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode != http.StatusServiceUnavailable) {
+			return resp, err
+		}
+		wait, ok := retryAfter(resp.Header.Get("Retry-After"), time.Now())
+		if !ok {
+			if resp.StatusCode == http.StatusServiceUnavailable {
+				return resp, nil // an outage, not a request to slow down
+			}
+			wait = time.Duration(attempt+1) * time.Second
+		}
+		if wait > t.opts.MaxRetryWait {
+			t.mu.Lock()
+			t.blocked[host] = time.Now().Add(wait)
+			t.mu.Unlock()
+			return resp, nil
+		}
+		if attempt == t.opts.Retries || (req.Body != nil && req.GetBody == nil) {
+			return resp, nil
+		}
+		resp.Body.Close()
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		}
+	}
+}
+
+// retryAfter parses a Retry-After header, in seconds or as an HTTP date.
+func retryAfter(v string, now time.Time) (time.Duration, bool) {
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second, true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return max(t.Sub(now), 0), true
+	}
+	return 0, false
+}
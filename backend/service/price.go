@@ -0,0 +1,178 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PriceQuery identifies a book to price. Providers match by ISBN when there is one.
+type PriceQuery struct {
+	ISBN    string
+	Title   string
+	Authors []string
+}
+
+// PriceQuote is what a book costs at a store.
+type PriceQuote struct {
+	Provider string
+	Price    float64
+	Currency string // ISO 4217, e.g. "USD"
+	URL      string // where to buy it
+}
+
+// PriceProvider looks up what books cost at a store. Price returns nil when the store doesn't sell the book.
+type PriceProvider interface {
+	Name() string
+	Price(ctx context.Context, q PriceQuery) (*PriceQuote, error)
+}
+
+// GoogleBooksPrices prices ebooks on Google Play Books, from the sale info the Google Books API returns. Only
+// books with an ISBN can be priced.
+type GoogleBooksPrices struct {
+	Country string        // ISO 3166 country whose store is asked, e.g. "US"
+	Timeout time.Duration // per lookup; 0 = 15s
+	Client  *http.Client  // nil = DefaultOutbound
+}
+
+func (g GoogleBooksPrices) Name() string { return "Google Play Books" }
+
+func (g GoogleBooksPrices) Price(ctx context.Context, q PriceQuery) (*PriceQuote, error) {
+	isbn := strings.ReplaceAll(strings.TrimSpace(q.ISBN), "-", "")
+	if isbn == "" {
+		return nil, nil
+	}
+	timeout := g.Timeout
+	if timeout <= 0 {
+		timeout = defaultGoogleBooksTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	v := url.Values{}
+	v.Set("q", "isbn:"+isbn)
+	if g.Country != "" {
+		v.Set("country", g.Country)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, googleBooksBase+"?"+v.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := outboundClient(g.Client).Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("google books returned %d", resp.StatusCode)
+	}
+	var data struct {
+		Items []struct {
+			SaleInfo struct {
+				Saleability string `json:"saleability"`
+				RetailPrice *struct {
+					Amount       float64 `json:"amount"`
+					CurrencyCode string  `json:"currencyCode"`
+				} `json:"retailPrice"`
+				BuyLink string `json:"buyLink"`
+			} `json:"saleInfo"`
+		} `json:"items"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
+		return nil, err
+	}
+	for _, item := range data.Items {
+		s := item.SaleInfo
+		if s.Saleability == "FOR_SALE" && s.RetailPrice != nil {
+			return &PriceQuote{Provider: g.Name(), Price: s.RetailPrice.Amount, Currency: s.RetailPrice.CurrencyCode, URL: s.BuyLink}, nil
+		}
+	}
+	return nil, nil
+}
+
+// priceFeedTTL is how long a PriceFeed reuses a download of its feed.
+const priceFeedTTL = 10 * time.Minute
+
+// PriceFeedEntry is one book in a price feed.
+type PriceFeedEntry struct {
+	ISBN     string   `json:"isbn"`
+	Title    string   `json:"title"`
+	Authors  []string `json:"authors"`
+	Price    float64  `json:"price"`
+	Currency string   `json:"currency"`
+	URL      string   `json:"url"`
+}
+
+// PriceFeed prices books from a JSON array of PriceFeedEntry at URL, such as a store's daily deals exported by
+// a script. Books without an ISBN are matched by title and first author.
+type PriceFeed struct {
+	URL    string
+	Client *http.Client // nil = DefaultOutbound
+
+	mu      sync.Mutex
+	entries []PriceFeedEntry
+	fetched time.Time
+}
+
+// Name is the feed's host, e.g. "deals.example.com".
+func (f *PriceFeed) Name() string {
+	if u, err := url.Parse(f.URL); err == nil && u.Host != "" {
+		return u.Host
+	}
+	return f.URL
+}
+
+func (f *PriceFeed) Price(ctx context.Context, q PriceQuery) (*PriceQuote, error) {
+	entries, err := f.load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	isbn := strings.ReplaceAll(strings.TrimSpace(q.ISBN), "-", "")
+	for _, e := range entries {
+		if isbn != "" && e.ISBN != "" {
+			if strings.ReplaceAll(e.ISBN, "-", "") != isbn {
+				continue
+			}
+		} else if !strings.EqualFold(strings.TrimSpace(e.Title), strings.TrimSpace(q.Title)) ||
+			len(e.Authors) > 0 && len(q.Authors) > 0 && !strings.EqualFold(e.Authors[0], q.Authors[0]) {
+			continue
+		}
+		return &PriceQuote{Provider: f.Name(), Price: e.Price, Currency: strings.ToUpper(e.Currency), URL: e.URL}, nil
+	}
+	return nil, nil
+}
+
+// load returns the feed's entries, downloading it again when the last download is older than priceFeedTTL.
+func (f *PriceFeed) load(ctx context.Context) ([]PriceFeedEntry, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.entries != nil && time.Since(f.fetched) < priceFeedTTL {
+		return f.entries, nil
+	}
+	client := outboundClient(f.Client)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, f.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("price feed %s returned %d", f.Name(), resp.StatusCode)
+	}
+	var entries []PriceFeedEntry
+	if err := json.NewDecoder(resp.Body).Decode(&entries); err != nil {
+		return nil, fmt.Errorf("price feed %s: %w", f.Name(), err)
+	}
+	if entries == nil {
+		entries = []PriceFeedEntry{}
+	}
+	f.entries, f.fetched = entries, time.Now()
+	return entries, nil
+}
@@ -2,8 +2,13 @@ package service
 
 import (
 	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
+	"net/http"
+	"net/url"
 	"path/filepath"
 	"strings"
 	"time"
@@ -12,16 +17,22 @@ import (
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
 	"github.com/google/uuid"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/aws/aws-sdk-go-v2/otelaws"
 )
 
+// S3Service is the ObjectStore backed by an S3 bucket.
 type S3Service struct {
 	client *s3.Client
 	bucket string
 	region string
 }
 
-func NewS3Service(ctx context.Context, bucket, region, accessKeyID, secretAccessKey string) (*S3Service, error) {
+// NewS3Service connects to bucket on AWS, or on the S3-compatible server at endpoint (MinIO, Ceph, R2), addressed
+// by path, when endpoint is set.
+func NewS3Service(ctx context.Context, endpoint, bucket, region, accessKeyID, secretAccessKey string) (*S3Service, error) {
 	if bucket == "" {
 		return nil, fmt.Errorf("AWS_S3_BUCKET is required")
 	}
@@ -35,27 +46,59 @@ func NewS3Service(ctx context.Context, bucket, region, accessKeyID, secretAccess
 	if err != nil {
 		return nil, err
 	}
+	// Calls made for a traced request become its children: "S3.PutObject" (see tracing).
+	otelaws.AppendMiddlewares(&cfg.APIOptions)
 	return &S3Service{
-		client: s3.NewFromConfig(cfg),
+		client: s3.NewFromConfig(cfg, func(o *s3.Options) {
+			if endpoint != "" {
+				o.BaseEndpoint = aws.String(endpoint)
+				o.UsePathStyle = true
+			}
+		}),
 		bucket: bucket,
 		region: region,
 	}, nil
 }
 
+// Ping checks that the bucket exists and the credentials can reach it.
+func (s *S3Service) Ping(ctx context.Context) error {
+	_, err := s.client.HeadBucket(ctx, &s3.HeadBucketInput{Bucket: aws.String(s.bucket)})
+	return err
+}
+
 // Upload stores the file in S3 under prefix (e.g. "user-id/"). Returns the object key.
 func (s *S3Service) Upload(ctx context.Context, prefix, originalFilename string, body io.Reader, contentType string) (string, error) {
-	ext := filepath.Ext(originalFilename)
-	key := prefix + uuid.New().String() + ext
-	_, err := s.client.PutObject(ctx, &s3.PutObjectInput{
+	return s.UploadWithSHA256(ctx, prefix, originalFilename, body, contentType, "")
+}
+
+// UploadWithSHA256 is Upload with the body's hex SHA-256: S3 rejects the upload if the body doesn't match,
+// and stores the checksum so HeadObject can report it later for integrity checks.
+func (s *S3Service) UploadWithSHA256(ctx context.Context, prefix, originalFilename string, body io.Reader, contentType, sha256Hex string) (string, error) {
+	key := prefix + uuid.New().String() + filepath.Ext(originalFilename)
+	if err := s.PutWithSHA256(ctx, key, body, contentType, sha256Hex); err != nil {
+		return "", err
+	}
+	return key, nil
+}
+
+// PutWithSHA256 is Put that has S3 reject a body not matching sha256Hex and store the checksum.
+func (s *S3Service) PutWithSHA256(ctx context.Context, key string, body io.Reader, contentType, sha256Hex string) error {
+	input := &s3.PutObjectInput{
 		Bucket:      aws.String(s.bucket),
 		Key:         aws.String(key),
 		Body:        body,
 		ContentType: aws.String(contentType),
-	})
-	if err != nil {
-		return "", err
 	}
-	return key, nil
+	if sha256Hex != "" {
+		sum, err := hex.DecodeString(sha256Hex)
+		if err != nil {
+			return fmt.Errorf("invalid sha256: %w", err)
+		}
+		input.ChecksumAlgorithm = types.ChecksumAlgorithmSha256
+		input.ChecksumSHA256 = aws.String(base64.StdEncoding.EncodeToString(sum))
+	}
+	_, err := s.client.PutObject(ctx, input)
+	return err
 }
 
 // Delete removes the object from S3.
@@ -92,11 +135,7 @@ func (s *S3Service) PresignedGetURL(ctx context.Context, key string, expiry time
 		Key:    aws.String(key),
 	}
 	if responseFilename != "" {
-		// Sanitize for Content-Disposition: escape \ and ", then quote
-		safe := responseFilename
-		safe = strings.ReplaceAll(safe, "\\", "\\\\")
-		safe = strings.ReplaceAll(safe, "\"", "\\\"")
-		input.ResponseContentDisposition = aws.String(`attachment; filename="` + safe + `"`)
+		input.ResponseContentDisposition = aws.String(ContentDisposition(responseFilename))
 	}
 	presigner := s3.NewPresignClient(s.client)
 	req, err := presigner.PresignGetObject(ctx, input, func(opts *s3.PresignOptions) {
@@ -107,3 +146,212 @@ func (s *S3Service) PresignedGetURL(ctx context.Context, key string, expiry time
 	}
 	return req.URL, nil
 }
+
+// HeadObject returns the object's size and metadata without downloading it. Returns ErrObjectNotFound if the key does not exist.
+func (s *S3Service) HeadObject(ctx context.Context, key string) (*ObjectInfo, error) {
+	out, err := s.client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket:       aws.String(s.bucket),
+		Key:          aws.String(key),
+		ChecksumMode: types.ChecksumModeEnabled,
+	})
+	if err != nil {
+		var apiErr smithy.APIError
+		if errors.As(err, &apiErr) && (apiErr.ErrorCode() == "NotFound" || apiErr.ErrorCode() == "NoSuchKey") {
+			return nil, ErrObjectNotFound
+		}
+		return nil, err
+	}
+	info := &ObjectInfo{
+		Size:        aws.ToInt64(out.ContentLength),
+		ContentType: aws.ToString(out.ContentType),
+		ETag:        aws.ToString(out.ETag),
+	}
+	if out.LastModified != nil {
+		info.LastModified = *out.LastModified
+	}
+	// Multipart checksums ("<base64>-<parts>") are checksums of part checksums, not of the object; skip them.
+	if c := aws.ToString(out.ChecksumSHA256); c != "" && !strings.Contains(c, "-") {
+		if sum, err := base64.StdEncoding.DecodeString(c); err == nil {
+			info.SHA256 = hex.EncodeToString(sum)
+		}
+	}
+	return info, nil
+}
+
+// s3Object is an Object backed by S3. Each Read after a Seek issues a ranged GET from the new offset,
+// so only the requested bytes are fetched.
+type s3Object struct {
+	ObjectInfo
+	ctx    context.Context
+	s      *S3Service
+	key    string
+	offset int64
+	body   io.ReadCloser
+}
+
+// OpenObject returns a seekable reader over key. Returns ErrObjectNotFound if the key does not exist. Caller must Close it.
+func (s *S3Service) OpenObject(ctx context.Context, key string) (Object, error) {
+	info, err := s.HeadObject(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	return &s3Object{ObjectInfo: *info, ctx: ctx, s: s, key: key}, nil
+}
+
+func (o *s3Object) Info() ObjectInfo {
+	return o.ObjectInfo
+}
+
+func (o *s3Object) Read(p []byte) (int, error) {
+	if o.offset >= o.Size {
+		return 0, io.EOF
+	}
+	if o.body == nil {
+		out, err := o.s.client.GetObject(o.ctx, &s3.GetObjectInput{
+			Bucket: aws.String(o.s.bucket),
+			Key:    aws.String(o.key),
+			Range:  aws.String(fmt.Sprintf("bytes=%d-", o.offset)),
+		})
+		if err != nil {
+			return 0, err
+		}
+		o.body = out.Body
+	}
+	n, err := o.body.Read(p)
+	o.offset += int64(n)
+	return n, err
+}
+
+func (o *s3Object) Seek(offset int64, whence int) (int64, error) {
+	var next int64
+	switch whence {
+	case io.SeekStart:
+		next = offset
+	case io.SeekCurrent:
+		next = o.offset + offset
+	case io.SeekEnd:
+		next = o.Size + offset
+	default:
+		return 0, errors.New("invalid whence")
+	}
+	if next < 0 {
+		return 0, errors.New("negative position")
+	}
+	if next != o.offset && o.body != nil {
+		o.body.Close()
+		o.body = nil
+	}
+	o.offset = next
+	return next, nil
+}
+
+// ReadAt fetches just p's bytes at off with a ranged GET. It does not move the offset Read uses.
+func (o *s3Object) ReadAt(p []byte, off int64) (int, error) {
+	if off >= o.Size {
+		return 0, io.EOF
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+	end := min(off+int64(len(p)), o.Size)
+	out, err := o.s.client.GetObject(o.ctx, &s3.GetObjectInput{
+		Bucket: aws.String(o.s.bucket),
+		Key:    aws.String(o.key),
+		Range:  aws.String(fmt.Sprintf("bytes=%d-%d", off, end-1)),
+	})
+	if err != nil {
+		return 0, err
+	}
+	defer out.Body.Close()
+	n, err := io.ReadFull(out.Body, p[:end-off])
+	if err == nil && end-off < int64(len(p)) {
+		err = io.EOF
+	}
+	return n, err
+}
+
+func (o *s3Object) Close() error {
+	if o.body == nil {
+		return nil
+	}
+	err := o.body.Close()
+	o.body = nil
+	return err
+}
+
+// Put stores body under an exact key (unlike Upload, which generates one).
+func (s *S3Service) Put(ctx context.Context, key string, body io.Reader, contentType string) error {
+	_, err := s.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(s.bucket),
+		Key:         aws.String(key),
+		Body:        body,
+		ContentType: aws.String(contentType),
+	})
+	return err
+}
+
+// SetStorageClass copies the object onto itself in class. Single-request copies are limited to 5 GB, well over
+// any upload.
+func (s *S3Service) SetStorageClass(ctx context.Context, key, class string) error {
+	_, err := s.client.CopyObject(ctx, &s3.CopyObjectInput{
+		Bucket:            aws.String(s.bucket),
+		Key:               aws.String(key),
+		CopySource:        aws.String(s.bucket + "/" + url.PathEscape(key)),
+		StorageClass:      types.StorageClass(class),
+		MetadataDirective: types.MetadataDirectiveCopy,
+		ChecksumAlgorithm: types.ChecksumAlgorithmSha256,
+	})
+	return err
+}
+
+// TagObject replaces the object's tags with tag=value.
+func (s *S3Service) TagObject(ctx context.Context, key, tag, value string) error {
+	_, err := s.client.PutObjectTagging(ctx, &s3.PutObjectTaggingInput{
+		Bucket:  aws.String(s.bucket),
+		Key:     aws.String(key),
+		Tagging: &types.Tagging{TagSet: []types.Tag{{Key: aws.String(tag), Value: aws.String(value)}}},
+	})
+	return err
+}
+
+// Tier returns the object's storage class and the state of its restore, from the x-amz-restore header
+// (`ongoing-request="false", expiry-date="Fri, 21 Dec 2012 00:00:00 GMT"`).
+func (s *S3Service) Tier(ctx context.Context, key string) (*TierInfo, error) {
+	out, err := s.client.HeadObject(ctx, &s3.HeadObjectInput{Bucket: aws.String(s.bucket), Key: aws.String(key)})
+	if err != nil {
+		var apiErr smithy.APIError
+		if errors.As(err, &apiErr) && (apiErr.ErrorCode() == "NotFound" || apiErr.ErrorCode() == "NoSuchKey") {
+			return nil, ErrObjectNotFound
+		}
+		return nil, err
+	}
+	info := &TierInfo{Class: string(out.StorageClass)}
+	if info.Class == StorageClassStandard {
+		info.Class = ""
+	}
+	restore := aws.ToString(out.Restore)
+	info.Restoring = strings.Contains(restore, `ongoing-request="true"`)
+	if _, rest, ok := strings.Cut(restore, `expiry-date="`); ok {
+		if date, _, ok := strings.Cut(rest, `"`); ok {
+			info.RestoredUntil, _ = http.ParseTime(date)
+		}
+	}
+	return info, nil
+}
+
+// Restore starts a Standard-tier restore (hours for GLACIER, up to 12 for DEEP_ARCHIVE) of a copy that lasts days.
+func (s *S3Service) Restore(ctx context.Context, key string, days int) error {
+	_, err := s.client.RestoreObject(ctx, &s3.RestoreObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+		RestoreRequest: &types.RestoreRequest{
+			Days:                 aws.Int32(int32(days)),
+			GlacierJobParameters: &types.GlacierJobParameters{Tier: types.TierStandard},
+		},
+	})
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) && apiErr.ErrorCode() == "RestoreAlreadyInProgress" {
+		return nil
+	}
+	return err
+}
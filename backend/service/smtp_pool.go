@@ -0,0 +1,222 @@
+package service
+
+import (
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"slices"
+	"sync"
+	"time"
+
+	mail "github.com/go-mail/mail/v2"
+	"github.com/kevinaaaquil/books/backend/models"
+)
+
+const (
+	// smtpDialTimeout bounds connecting and logging in.
+	smtpDialTimeout = 10 * time.Second
+	// smtpSendTimeout bounds one message's transaction, attachment included.
+	smtpSendTimeout = 5 * time.Minute
+)
+
+// SMTPPool keeps go-mail connections open between sends, per server and account, so a batch of sends logs in
+// once instead of opening a TLS session for every book. A connection is closed once it has been idle for the
+// pool's idle time, or when a send on it fails; go-mail reopens one the server closed while it was idle. Stats
+// reports each account's connections and sends.
+type SMTPPool struct {
+	size int
+	idle time.Duration
+
+	mu     sync.Mutex
+	conns  map[smtpAccount][]*smtpConn // idle connections, most recently used last
+	stats  map[smtpAddr]*models.SMTPPoolStat
+	closed bool
+}
+
+// NewSMTPPool returns a pool that keeps up to size idle connections per account, each for up to idle.
+func NewSMTPPool(size int, idle time.Duration) *SMTPPool {
+	return &SMTPPool{
+		size:  max(size, 1),
+		idle:  idle,
+		conns: map[smtpAccount][]*smtpConn{},
+		stats: map[smtpAddr]*models.SMTPPoolStat{},
+	}
+}
+
+// smtpAddr is the server and account that stats are reported under.
+type smtpAddr struct {
+	host     string
+	port     int
+	username string
+}
+
+// smtpAccount is what a connection is logged in as; the password is hashed, so a changed password gets new
+// connections.
+type smtpAccount struct {
+	smtpAddr
+	password [sha256.Size]byte
+}
+
+type smtpConn struct {
+	sender mail.SendCloser
+	timer  *time.Timer // closes it once idle for too long
+}
+
+// Send sends msg to host:port as username over an idle connection, or a new one when none is left open.
+func (p *SMTPPool) Send(ctx context.Context, host string, port int, username, password string, tlsConfig *tls.Config, msg *mail.Message) error {
+	a := smtpAccount{smtpAddr{host, port, username}, sha256.Sum256([]byte(password))}
+	c := p.take(a)
+	if c == nil {
+		d := smtpDialer(host, port, username, password, tlsConfig)
+		d.Timeout = smtpDialTimeout
+		sender, err := d.Dial()
+		p.dialed(a, err)
+		if err != nil {
+			p.sent(a, err)
+			return err
+		}
+		// The sender keeps d, and sets its timeout as the deadline of each transaction.
+		d.Timeout = smtpSendTimeout
+		c = &smtpConn{sender: sender}
+	}
+	err := mail.Send(c.sender, msg)
+	p.sent(a, err)
+	// A failed transaction may still be open on the server, and go-mail has no RSET to end it.
+	if err == nil {
+		p.put(a, c)
+	} else {
+		p.discard(a, c)
+	}
+	return err
+}
+
+// take returns an idle connection to a, or nil.
+func (p *SMTPPool) take(a smtpAccount) *smtpConn {
+	for {
+		p.mu.Lock()
+		idle := p.conns[a]
+		if len(idle) == 0 {
+			p.mu.Unlock()
+			return nil
+		}
+		c := idle[len(idle)-1]
+		p.conns[a] = idle[:len(idle)-1]
+		p.mu.Unlock()
+		// A timer that already fired is waiting to close c; it won't find it now, so c is closed here.
+		if !c.timer.Stop() {
+			p.discard(a, c)
+			continue
+		}
+		p.mu.Lock()
+		p.stat(a).Reuses++
+		p.mu.Unlock()
+		return c
+	}
+}
+
+// put leaves c open for the next send to a, unless enough connections to a are idle already.
+func (p *SMTPPool) put(a smtpAccount, c *smtpConn) {
+	p.mu.Lock()
+	if p.closed || len(p.conns[a]) >= p.size {
+		p.mu.Unlock()
+		p.discard(a, c)
+		return
+	}
+	c.timer = time.AfterFunc(p.idle, func() { p.expire(a, c) })
+	p.conns[a] = append(p.conns[a], c)
+	p.mu.Unlock()
+}
+
+// expire closes c if it is still idle.
+func (p *SMTPPool) expire(a smtpAccount, c *smtpConn) {
+	p.mu.Lock()
+	i := slices.Index(p.conns[a], c)
+	if i >= 0 {
+		p.conns[a] = slices.Delete(p.conns[a], i, i+1)
+	}
+	p.mu.Unlock()
+	if i >= 0 {
+		p.discard(a, c)
+	}
+}
+
+// discard closes c, which is no longer idle.
+func (p *SMTPPool) discard(a smtpAccount, c *smtpConn) {
+	c.sender.Close()
+	p.mu.Lock()
+	p.stat(a).Open--
+	p.mu.Unlock()
+}
+
+func (p *SMTPPool) dialed(a smtpAccount, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	s := p.stat(a)
+	if err != nil {
+		s.DialFailures++
+		return
+	}
+	s.Dials++
+	s.Open++
+}
+
+func (p *SMTPPool) sent(a smtpAccount, err error) {
+	now := time.Now().UTC()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	s := p.stat(a)
+	s.Sends++
+	s.LastSendAt = &now
+	if err != nil {
+		s.Failures++
+		s.LastError, s.LastErrorAt = err.Error(), &now
+	}
+}
+
+// stat returns the stats of a's server and account. Hold p.mu.
+func (p *SMTPPool) stat(a smtpAccount) *models.SMTPPoolStat {
+	s := p.stats[a.smtpAddr]
+	if s == nil {
+		s = &models.SMTPPoolStat{Host: a.host, Port: a.port, Username: a.username}
+		p.stats[a.smtpAddr] = s
+	}
+	return s
+}
+
+// Stats reports each server and account the pool has sent through, by host and username.
+func (p *SMTPPool) Stats() []models.SMTPPoolStat {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	idle := map[smtpAddr]int{}
+	for a, conns := range p.conns {
+		idle[a.smtpAddr] += len(conns)
+	}
+	stats := make([]models.SMTPPoolStat, 0, len(p.stats))
+	for addr, s := range p.stats {
+		stat := *s
+		stat.Idle = idle[addr]
+		stats = append(stats, stat)
+	}
+	slices.SortFunc(stats, func(a, b models.SMTPPoolStat) int {
+		return cmp.Or(cmp.Compare(a.Host, b.Host), cmp.Compare(a.Port, b.Port), cmp.Compare(a.Username, b.Username))
+	})
+	return stats
+}
+
+// Close closes the idle connections; connections in use are closed once their send is done.
+func (p *SMTPPool) Close() error {
+	p.mu.Lock()
+	p.closed = true
+	conns := p.conns
+	p.conns = map[smtpAccount][]*smtpConn{}
+	p.mu.Unlock()
+	for a, idle := range conns {
+		for _, c := range idle {
+			// A timer that fired won't find c any more either.
+			c.timer.Stop()
+			p.discard(a, c)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,161 @@
+package service
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sync"
+)
+
+// SNSMessage is an Amazon SNS HTTP(S) delivery: a subscription confirmation or a notification.
+type SNSMessage struct {
+	Type             string `json:"Type"`
+	MessageID        string `json:"MessageId"`
+	Token            string `json:"Token"`
+	TopicArn         string `json:"TopicArn"`
+	Subject          string `json:"Subject"`
+	Message          string `json:"Message"`
+	Timestamp        string `json:"Timestamp"`
+	SignatureVersion string `json:"SignatureVersion"`
+	Signature        string `json:"Signature"`
+	SigningCertURL   string `json:"SigningCertURL"`
+	SubscribeURL     string `json:"SubscribeURL"`
+}
+
+// snsCertHost is the host SNS signing certificates are served from.
+var snsCertHost = regexp.MustCompile(`^sns\.[a-z0-9-]+\.amazonaws\.com(\.cn)?$`)
+
+// SNSVerifier checks that SNS messages were signed by Amazon SNS, fetching (and caching) the signing
+// certificates over HTTPS from SNS's own hosts.
+type SNSVerifier struct {
+	Client *http.Client
+
+	mu    sync.Mutex
+	certs map[string]*x509.Certificate
+}
+
+// Verify returns an error unless m carries a valid SNS signature.
+func (v *SNSVerifier) Verify(ctx context.Context, m *SNSMessage) error {
+	var hash crypto.Hash
+	switch m.SignatureVersion {
+	case "1":
+		hash = crypto.SHA1
+	case "2":
+		hash = crypto.SHA256
+	default:
+		return fmt.Errorf("unsupported signature version %q", m.SignatureVersion)
+	}
+	sig, err := base64.StdEncoding.DecodeString(m.Signature)
+	if err != nil {
+		return fmt.Errorf("signature: %w", err)
+	}
+	cert, err := v.cert(ctx, m.SigningCertURL)
+	if err != nil {
+		return err
+	}
+	key, ok := cert.PublicKey.(*rsa.PublicKey)
+	if !ok {
+		return errors.New("signing certificate has no RSA key")
+	}
+	var digest []byte
+	if hash == crypto.SHA1 {
+		sum := sha1.Sum([]byte(snsStringToSign(m)))
+		digest = sum[:]
+	} else {
+		sum := sha256.Sum256([]byte(snsStringToSign(m)))
+		digest = sum[:]
+	}
+	if err := rsa.VerifyPKCS1v15(key, hash, digest, sig); err != nil {
+		return errors.New("invalid signature")
+	}
+	return nil
+}
+
+// snsStringToSign is the canonical form of m that SNS signs.
+func snsStringToSign(m *SNSMessage) string {
+	field := func(name, value string) string { return name + "\n" + value + "\n" }
+	s := field("Message", m.Message) + field("MessageId", m.MessageID)
+	if m.Type == "Notification" {
+		if m.Subject != "" {
+			s += field("Subject", m.Subject)
+		}
+		return s + field("Timestamp", m.Timestamp) + field("TopicArn", m.TopicArn) + field("Type", m.Type)
+	}
+	return s + field("SubscribeURL", m.SubscribeURL) + field("Timestamp", m.Timestamp) + field("Token", m.Token) +
+		field("TopicArn", m.TopicArn) + field("Type", m.Type)
+}
+
+func (v *SNSVerifier) cert(ctx context.Context, certURL string) (*x509.Certificate, error) {
+	u, err := url.Parse(certURL)
+	if err != nil || u.Scheme != "https" || !snsCertHost.MatchString(u.Host) {
+		return nil, fmt.Errorf("signing certificate URL %q is not an SNS host", certURL)
+	}
+	v.mu.Lock()
+	cert := v.certs[certURL]
+	v.mu.Unlock()
+	if cert != nil {
+		return cert, nil
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, certURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err := v.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetch signing certificate: %w", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch signing certificate: %s", res.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(res.Body, 64<<10))
+	if err != nil {
+		return nil, fmt.Errorf("fetch signing certificate: %w", err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("signing certificate is not PEM")
+	}
+	if cert, err = x509.ParseCertificate(block.Bytes); err != nil {
+		return nil, fmt.Errorf("signing certificate: %w", err)
+	}
+	v.mu.Lock()
+	if v.certs == nil {
+		v.certs = map[string]*x509.Certificate{}
+	}
+	v.certs[certURL] = cert
+	v.mu.Unlock()
+	return cert, nil
+}
+
+// ConfirmSubscription visits m's SubscribeURL, which must be on SNS's own host, to confirm a subscription.
+func (v *SNSVerifier) ConfirmSubscription(ctx context.Context, m *SNSMessage) error {
+	u, err := url.Parse(m.SubscribeURL)
+	if err != nil || u.Scheme != "https" || !snsCertHost.MatchString(u.Host) {
+		return fmt.Errorf("subscribe URL %q is not an SNS host", m.SubscribeURL)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, m.SubscribeURL, nil)
+	if err != nil {
+		return err
+	}
+	res, err := v.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("confirm subscription: %s", res.Status)
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"io"
+	"mime"
+	"time"
+)
+
+// ObjectStore stores book files and covers. S3Service and LocalStorage implement it.
+type ObjectStore interface {
+	// Upload stores body under prefix with a generated name (keeping originalFilename's extension) and returns the key.
+	Upload(ctx context.Context, prefix, originalFilename string, body io.Reader, contentType string) (string, error)
+	// UploadWithSHA256 is Upload that rejects a body not matching sha256Hex and records the checksum for HeadObject.
+	UploadWithSHA256(ctx context.Context, prefix, originalFilename string, body io.Reader, contentType, sha256Hex string) (string, error)
+	// Put stores body under an exact key, replacing any existing object.
+	Put(ctx context.Context, key string, body io.Reader, contentType string) error
+	// GetObject returns the object's body and content type. Caller must close the body.
+	GetObject(ctx context.Context, key string) (body io.ReadCloser, contentType string, err error)
+	// HeadObject returns the object's metadata, or ErrObjectNotFound.
+	HeadObject(ctx context.Context, key string) (*ObjectInfo, error)
+	// OpenObject returns a seekable reader over the object, or ErrObjectNotFound. Caller must close it.
+	OpenObject(ctx context.Context, key string) (Object, error)
+	// PresignedGetURL returns a temporary download URL; responseFilename, if set, names the saved file.
+	PresignedGetURL(ctx context.Context, key string, expiry time.Duration, responseFilename string) (string, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// ChecksumPutter is implemented by object stores that can check and record a SHA-256 when storing under an exact
+// key, so HeadObject reports it.
+type ChecksumPutter interface {
+	// PutWithSHA256 is Put that rejects a body not matching sha256Hex. body must be seekable for S3.
+	PutWithSHA256(ctx context.Context, key string, body io.Reader, contentType, sha256Hex string) error
+}
+
+// Object is an open stored object, seekable so it can be passed to http.ServeContent, and readable at offsets so
+// files can be read out of a stored zip (such as an EPUB) without fetching all of it.
+type Object interface {
+	io.ReadSeekCloser
+	io.ReaderAt
+	Info() ObjectInfo
+}
+
+// ErrObjectNotFound is returned when the requested key does not exist in storage.
+var ErrObjectNotFound = errors.New("object not found")
+
+// ObjectInfo is the metadata returned by HeadObject.
+type ObjectInfo struct {
+	Size         int64
+	ContentType  string
+	ETag         string
+	LastModified time.Time
+	SHA256       string // hex; empty when the object was stored without a SHA-256 checksum
+}
+
+// ContentDisposition returns an attachment Content-Disposition for filename. Non-ASCII names
+// (e.g. titles with accents) are encoded as filename*=utf-8''... per RFC 6266.
+func ContentDisposition(filename string) string {
+	if v := mime.FormatMediaType("attachment", map[string]string{"filename": filename}); v != "" {
+		return v
+	}
+	return "attachment"
+}
@@ -0,0 +1,173 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TelegramMaxDownloadBytes is the largest file the Bot API lets a bot download.
+const TelegramMaxDownloadBytes = 20 << 20
+
+// TelegramMaxUploadBytes is the largest file the Bot API lets a bot send.
+const TelegramMaxUploadBytes = 50 << 20
+
+// TelegramClient calls the Telegram Bot API with a bot token from @BotFather.
+type TelegramClient struct {
+	Token   string
+	BaseURL string // empty = https://api.telegram.org; tests point it at a fake
+}
+
+// TelegramUpdate is an incoming update; only messages are asked for.
+type TelegramUpdate struct {
+	UpdateID int64            `json:"update_id"`
+	Message  *TelegramMessage `json:"message"`
+}
+
+type TelegramMessage struct {
+	MessageID int64 `json:"message_id"`
+	Chat      struct {
+		ID   int64  `json:"id"`
+		Type string `json:"type"` // "private" for one-to-one chats with the bot
+	} `json:"chat"`
+	From *struct {
+		ID       int64  `json:"id"`
+		Username string `json:"username"`
+	} `json:"from"`
+	Text     string            `json:"text"`
+	Document *TelegramDocument `json:"document"`
+}
+
+type TelegramDocument struct {
+	FileID   string `json:"file_id"`
+	FileName string `json:"file_name"`
+	MimeType string `json:"mime_type"`
+	FileSize int64  `json:"file_size"`
+}
+
+// telegramClient allows for long polls and large books.
+var telegramClient = &http.Client{Timeout: 5 * time.Minute}
+
+func (c *TelegramClient) url(path string) string {
+	base := "https://api.telegram.org"
+	if c.BaseURL != "" {
+		base = strings.TrimSuffix(c.BaseURL, "/")
+	}
+	return base + path
+}
+
+// call invokes a Bot API method, decoding its result into out (when not nil).
+func (c *TelegramClient) call(ctx context.Context, method, contentType string, body io.Reader, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url("/bot"+c.Token+"/"+method), body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	resp, err := telegramClient.Do(req)
+	if err != nil {
+		// The URL holds the token; keep it out of logs.
+		if ue, ok := err.(*url.Error); ok {
+			err = ue.Err
+		}
+		return fmt.Errorf("telegram %s: %w", method, err)
+	}
+	defer resp.Body.Close()
+	var res struct {
+		OK          bool            `json:"ok"`
+		Description string          `json:"description"`
+		Result      json.RawMessage `json:"result"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return fmt.Errorf("telegram %s returned %d", method, resp.StatusCode)
+	}
+	if !res.OK {
+		return fmt.Errorf("telegram %s: %s", method, res.Description)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.Unmarshal(res.Result, out)
+}
+
+func (c *TelegramClient) callJSON(ctx context.Context, method string, params interface{}, out interface{}) error {
+	body, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	return c.call(ctx, method, "application/json", bytes.NewReader(body), out)
+}
+
+// GetUpdates long-polls for messages after offset (the last update ID seen + 1), waiting up to timeout.
+func (c *TelegramClient) GetUpdates(ctx context.Context, offset int64, timeout time.Duration) ([]TelegramUpdate, error) {
+	var updates []TelegramUpdate
+	err := c.callJSON(ctx, "getUpdates", map[string]interface{}{
+		"offset":          offset,
+		"timeout":         int(timeout / time.Second),
+		"allowed_updates": []string{"message"},
+	}, &updates)
+	return updates, err
+}
+
+// SendMessage sends plain text to a chat.
+func (c *TelegramClient) SendMessage(ctx context.Context, chatID int64, text string) error {
+	return c.callJSON(ctx, "sendMessage", map[string]interface{}{"chat_id": chatID, "text": text}, nil)
+}
+
+// SendDocument sends r to a chat as a file named name, with an optional caption.
+func (c *TelegramClient) SendDocument(ctx context.Context, chatID int64, name string, r io.Reader, caption string) error {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	mw.WriteField("chat_id", strconv.FormatInt(chatID, 10))
+	if caption != "" {
+		mw.WriteField("caption", caption)
+	}
+	h := make(textproto.MIMEHeader)
+	h.Set("Content-Disposition", fmt.Sprintf(`form-data; name="document"; filename=%q`, name))
+	h.Set("Content-Type", "application/octet-stream")
+	part, err := mw.CreatePart(h)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(part, r); err != nil {
+		return err
+	}
+	if err := mw.Close(); err != nil {
+		return err
+	}
+	return c.call(ctx, "sendDocument", mw.FormDataContentType(), &body, nil)
+}
+
+// DownloadFile opens a file sent to the bot, by its file ID.
+func (c *TelegramClient) DownloadFile(ctx context.Context, fileID string) (io.ReadCloser, error) {
+	var file struct {
+		FilePath string `json:"file_path"`
+	}
+	if err := c.callJSON(ctx, "getFile", map[string]string{"file_id": fileID}, &file); err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.url("/file/bot"+c.Token+"/"+file.FilePath), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := telegramClient.Do(req)
+	if err != nil {
+		if ue, ok := err.(*url.Error); ok {
+			err = ue.Err
+		}
+		return nil, fmt.Errorf("telegram file download: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("telegram file download returned %d", resp.StatusCode)
+	}
+	return resp.Body, nil
+}
@@ -0,0 +1,60 @@
+package service
+
+import (
+	"context"
+	"time"
+)
+
+// Storage classes files can be moved to. Objects in GLACIER and DEEP_ARCHIVE must be restored before they can be
+// read; the others are read as usual, at a higher price per read.
+const (
+	StorageClassStandard    = "STANDARD"
+	StorageClassIA          = "STANDARD_IA"
+	StorageClassGlacierIR   = "GLACIER_IR"
+	StorageClassGlacier     = "GLACIER"
+	StorageClassDeepArchive = "DEEP_ARCHIVE"
+)
+
+// ColdStorageClasses are the classes COLD_STORAGE_CLASS may name.
+var ColdStorageClasses = []string{StorageClassIA, StorageClassGlacierIR, StorageClassGlacier, StorageClassDeepArchive}
+
+// NeedsRestore reports whether objects in class must be restored before they can be read.
+func NeedsRestore(class string) bool {
+	return class == StorageClassGlacier || class == StorageClassDeepArchive
+}
+
+// TierInfo is an object's storage class and the state of its restore, if it needs one.
+type TierInfo struct {
+	Class         string    // "" = standard
+	Restoring     bool      // a restore is in progress
+	RestoredUntil time.Time // when the restored copy expires; zero when there is none
+}
+
+// Readable reports whether the object can be read at now.
+func (t *TierInfo) Readable(now time.Time) bool {
+	return !NeedsRestore(t.Class) || t.RestoredUntil.After(now)
+}
+
+// Tierer is implemented by object stores with storage classes (S3), so rarely read books can be moved to cheaper
+// tiers and restored when they are wanted again.
+type Tierer interface {
+	// SetStorageClass rewrites the object in place in class, keeping its content type and checksum.
+	SetStorageClass(ctx context.Context, key, class string) error
+	// TagObject sets the object's tags to key=value, for bucket lifecycle rules that transition tagged objects.
+	TagObject(ctx context.Context, key, tag, value string) error
+	// Tier returns the object's storage class and restore state, or ErrObjectNotFound.
+	Tier(ctx context.Context, key string) (*TierInfo, error)
+	// Restore starts making a readable copy of an archived object that lasts days. Restoring an object already
+	// being restored is not an error.
+	Restore(ctx context.Context, key string, days int) error
+}
+
+// TiererOf returns storage's Tierer: storage itself, or the store a LazyStorage has opened. False when it has none,
+// or a LazyStorage has not connected yet.
+func TiererOf(storage ObjectStore) (Tierer, bool) {
+	if lazy, ok := storage.(*LazyStorage); ok {
+		storage = lazy.current()
+	}
+	t, ok := storage.(Tierer)
+	return t, ok
+}
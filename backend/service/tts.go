@@ -0,0 +1,189 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// SpeechChunkChars is the most text a Speaker is given at once; speech APIs take up to 4096 characters.
+const SpeechChunkChars = 4000
+
+// Speaker reads text aloud for read-aloud audio. PiperSpeaker and SpeechAPI implement it.
+type Speaker interface {
+	// Speak reads up to SpeechChunkChars of text aloud and writes it to w as MP3 frames without tags, so the
+	// audio of consecutive chunks can be joined into one file.
+	Speak(ctx context.Context, text string, w io.Writer) error
+	// Voice names the voice, for listeners.
+	Voice() string
+}
+
+// SpeechChunks splits text into pieces of up to limit characters for a Speaker, between sentences where it can,
+// else between words.
+func SpeechChunks(text string, limit int) []string {
+	var chunks []string
+	text = strings.Join(strings.Fields(text), " ")
+	for len(text) > limit {
+		cut := strings.LastIndexAny(text[:limit], ".!?…;:")
+		if cut < limit/2 {
+			cut = strings.LastIndex(text[:limit], " ")
+		} else {
+			_, size := utf8.DecodeRuneInString(text[cut:])
+			cut += size // keep the punctuation
+		}
+		if cut <= 0 {
+			// One word longer than limit: cut it, on a character boundary.
+			cut = limit
+			for cut > 0 && !utf8.RuneStart(text[cut]) {
+				cut--
+			}
+		}
+		chunks = append(chunks, strings.TrimSpace(text[:cut]))
+		text = strings.TrimSpace(text[cut:])
+	}
+	if strings.IndexFunc(text, func(r rune) bool { return unicode.IsLetter(r) || unicode.IsNumber(r) }) >= 0 {
+		chunks = append(chunks, text)
+	}
+	return chunks
+}
+
+// PiperSpeaker reads with a local piper voice, and encodes its WAV as MP3 with ffmpeg.
+type PiperSpeaker struct {
+	Path   string // piper executable
+	Model  string // voice model (.onnx)
+	FFmpeg string // ffmpeg executable
+}
+
+func (p *PiperSpeaker) Voice() string {
+	return strings.TrimSuffix(filepath.Base(p.Model), ".onnx")
+}
+
+func (p *PiperSpeaker) Speak(ctx context.Context, text string, w io.Writer) error {
+	dir, err := os.MkdirTemp("", "books-tts-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	wav := filepath.Join(dir, "speech.wav")
+	piper := exec.CommandContext(ctx, p.Path, "--model", p.Model, "--output_file", wav)
+	piper.Stdin = strings.NewReader(text)
+	if err := runTool("piper", piper); err != nil {
+		return err
+	}
+	// Bare MP3 frames (no ID3 tag or Xing header), so chunks concatenate into one stream.
+	ffmpeg := exec.CommandContext(ctx, p.FFmpeg, "-nostdin", "-loglevel", "error", "-i", wav,
+		"-codec:a", "libmp3lame", "-qscale:a", "5", "-write_xing", "0", "-id3v2_version", "0", "-f", "mp3", "pipe:1")
+	ffmpeg.Stdout = w
+	return runTool("ffmpeg", ffmpeg)
+}
+
+// runTool runs cmd, and returns the end of what it wrote to stderr with its error.
+func runTool(name string, cmd *exec.Cmd) error {
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if len(msg) > 300 {
+			msg = msg[len(msg)-300:]
+		}
+		return fmt.Errorf("%s: %w: %s", name, err, msg)
+	}
+	return nil
+}
+
+// SpeechAPI reads with an OpenAI-compatible speech endpoint (POST /v1/audio/speech).
+type SpeechAPI struct {
+	URL    string
+	APIKey string
+	Model  string // e.g. tts-1
+	Name   string // the voice, e.g. alloy
+	Client *http.Client
+}
+
+// SpeechAPIError is an error answer from a speech API.
+type SpeechAPIError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *SpeechAPIError) Error() string {
+	return fmt.Sprintf("speech api: %d %s", e.StatusCode, e.Message)
+}
+
+// IsPermanentSpeechError reports whether err is an answer from a speech API that trying again won't change: a
+// 4xx other than a timeout or rate limit.
+func IsPermanentSpeechError(err error) bool {
+	var apiErr *SpeechAPIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode/100 == 4 &&
+		apiErr.StatusCode != http.StatusRequestTimeout && apiErr.StatusCode != http.StatusTooManyRequests
+}
+
+func (s *SpeechAPI) Voice() string {
+	return s.Name
+}
+
+func (s *SpeechAPI) Speak(ctx context.Context, text string, w io.Writer) error {
+	body, err := json.Marshal(map[string]string{"model": s.Model, "voice": s.Name, "input": text, "response_format": "mp3"})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+s.APIKey)
+	}
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return &SpeechAPIError{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(msg))}
+	}
+	return copyMP3Frames(w, resp.Body)
+}
+
+// copyMP3Frames copies an MP3 stream without its leading ID3v2 tag, if it has one, so streams can be joined.
+func copyMP3Frames(w io.Writer, r io.Reader) error {
+	header := make([]byte, 10)
+	n, err := io.ReadFull(r, header)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		_, err = w.Write(header[:n])
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	if string(header[:3]) == "ID3" {
+		// The tag size is a 28-bit syncsafe integer, excluding the header (and a footer, when flagged).
+		size := int64(header[6])<<21 | int64(header[7])<<14 | int64(header[8])<<7 | int64(header[9])
+		if header[5]&0x10 != 0 {
+			size += 10
+		}
+		if _, err := io.CopyN(io.Discard, r, size); err != nil {
+			return err
+		}
+	} else if _, err := w.Write(header); err != nil {
+		return err
+	}
+	_, err = io.Copy(w, r)
+	return err
+}
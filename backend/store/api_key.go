@@ -0,0 +1,103 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/kevinaaaquil/books/backend/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func (db *DB) InsertAPIKey(ctx context.Context, k *models.APIKey) (primitive.ObjectID, error) {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
+	res, err := db.APIKeys().InsertOne(ctx, k)
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+	return res.InsertedID.(primitive.ObjectID), nil
+}
+
+// APIKeysForUser returns the user's keys, oldest first.
+func (db *DB) APIKeysForUser(ctx context.Context, userID primitive.ObjectID) ([]models.APIKey, error) {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
+	cur, err := db.APIKeys().Find(ctx, bson.M{"userId": userID}, options.Find().SetSort(bson.M{"createdAt": 1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+	keys := []models.APIKey{}
+	if err := cur.All(ctx, &keys); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// APIKeyByHash returns the key with this hash, or nil if there is none.
+func (db *DB) APIKeyByHash(ctx context.Context, keyHash string) (*models.APIKey, error) {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
+	var k models.APIKey
+	err := db.APIKeys().FindOne(ctx, bson.M{"keyHash": keyHash}).Decode(&k)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &k, nil
+}
+
+// TouchAPIKey records that a key was used at.
+func (db *DB) TouchAPIKey(ctx context.Context, id primitive.ObjectID, at time.Time) error {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
+	_, err := db.APIKeys().UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{"lastUsedAt": at}})
+	return err
+}
+
+// DeleteAPIKey revokes one of the user's keys. Returns false if it does not exist.
+func (db *DB) DeleteAPIKey(ctx context.Context, userID, id primitive.ObjectID) (bool, error) {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
+	res, err := db.APIKeys().DeleteOne(ctx, bson.M{"_id": id, "userId": userID})
+	if err != nil {
+		return false, err
+	}
+	return res.DeletedCount > 0, nil
+}
+
+// RotateAPIKey replaces the secret of one of the user's keys, keeping its name, scopes and expiry. Returns false
+// if it does not exist.
+func (db *DB) RotateAPIKey(ctx context.Context, userID, id primitive.ObjectID, prefix, keyHash string, at time.Time) (bool, error) {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
+	res, err := db.APIKeys().UpdateOne(ctx, bson.M{"_id": id, "userId": userID}, bson.M{
+		"$set":   bson.M{"prefix": prefix, "keyHash": keyHash, "rotatedAt": at},
+		"$unset": bson.M{"lastUsedAt": ""},
+	})
+	if err != nil {
+		return false, err
+	}
+	return res.MatchedCount > 0, nil
+}
+
+// AllAPIKeys returns every user's keys, oldest first.
+func (db *DB) AllAPIKeys(ctx context.Context) ([]models.APIKey, error) {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
+	cur, err := db.APIKeys().Find(ctx, bson.M{}, options.Find().SetSort(bson.M{"createdAt": 1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+	keys := []models.APIKey{}
+	if err := cur.All(ctx, &keys); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
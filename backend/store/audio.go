@@ -0,0 +1,79 @@
+package store
+
+import (
+	"context"
+
+	"github.com/kevinaaaquil/books/backend/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// UpsertAudioTrack replaces t.BookID's track for t.Chapter, and sets t.ID.
+func (db *DB) UpsertAudioTrack(ctx context.Context, t *models.AudioTrack) error {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
+	set := bson.M{
+		"title":       t.Title,
+		"status":      t.Status,
+		"percent":     t.Percent,
+		"characters":  t.Characters,
+		"voice":       t.Voice,
+		"s3Key":       t.S3Key,
+		"sizeBytes":   t.SizeBytes,
+		"error":       t.Error,
+		"requestedBy": t.RequestedBy,
+		"createdAt":   t.CreatedAt,
+		"updatedAt":   t.UpdatedAt,
+	}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After).SetProjection(bson.M{"_id": 1})
+	var stored struct {
+		ID primitive.ObjectID `bson:"_id"`
+	}
+	err := db.AudioTracksCollection().FindOneAndUpdate(ctx, bson.M{"bookId": t.BookID, "chapter": t.Chapter}, bson.M{"$set": set}, opts).Decode(&stored)
+	if err != nil {
+		return err
+	}
+	t.ID = stored.ID
+	return nil
+}
+
+// AudioTracks returns the book's tracks by chapter.
+func (db *DB) AudioTracks(ctx context.Context, bookID primitive.ObjectID) ([]models.AudioTrack, error) {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
+	cur, err := db.AudioTracksCollection().Find(ctx, bson.M{"bookId": bookID}, options.Find().SetSort(bson.D{{Key: "chapter", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+	var tracks []models.AudioTrack
+	if err := cur.All(ctx, &tracks); err != nil {
+		return nil, err
+	}
+	return tracks, nil
+}
+
+// AudioTrack returns the book's track for chapter, or nil if there is none.
+func (db *DB) AudioTrack(ctx context.Context, bookID primitive.ObjectID, chapter int) (*models.AudioTrack, error) {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
+	var t models.AudioTrack
+	err := db.AudioTracksCollection().FindOne(ctx, bson.M{"bookId": bookID, "chapter": chapter}).Decode(&t)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// DeleteAudioTracks removes a deleted book's tracks.
+func (db *DB) DeleteAudioTracks(ctx context.Context, bookID primitive.ObjectID) error {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
+	_, err := db.AudioTracksCollection().DeleteMany(ctx, bson.M{"bookId": bookID})
+	return err
+}
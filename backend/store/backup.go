@@ -0,0 +1,81 @@
+package store
+
+import (
+	"context"
+
+	"github.com/kevinaaaquil/books/backend/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func (db *DB) InsertBackup(ctx context.Context, b *models.Backup) (primitive.ObjectID, error) {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
+	res, err := db.Backups().InsertOne(ctx, b, options.InsertOne())
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+	return res.InsertedID.(primitive.ObjectID), nil
+}
+
+// ListBackups returns all recorded backups, newest first.
+func (db *DB) ListBackups(ctx context.Context) ([]models.Backup, error) {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
+	cur, err := db.Backups().Find(ctx, bson.M{}, options.Find().SetSort(bson.M{"createdAt": -1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+	backups := []models.Backup{}
+	if err := cur.All(ctx, &backups); err != nil {
+		return nil, err
+	}
+	return backups, nil
+}
+
+func (db *DB) DeleteBackup(ctx context.Context, id primitive.ObjectID) error {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
+	_, err := db.Backups().DeleteOne(ctx, bson.M{"_id": id})
+	return err
+}
+
+// LatestJobRun returns the most recent run of the given job type, or nil if it never ran.
+func (db *DB) LatestJobRun(ctx context.Context, jobType string) (*models.JobRun, error) {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
+	var run models.JobRun
+	err := db.JobRuns().FindOne(ctx, bson.M{"type": jobType}, options.FindOne().SetSort(bson.M{"startedAt": -1})).Decode(&run)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
+
+// ForEachDocument calls fn with every document in the named collection, stopping at the first error.
+func (db *DB) ForEachDocument(ctx context.Context, collection string, fn func(doc bson.Raw) error) error {
+	cur, err := db.Database.Collection(collection).Find(ctx, bson.M{})
+	if err != nil {
+		return err
+	}
+	defer cur.Close(ctx)
+	for cur.Next(ctx) {
+		if err := fn(cur.Current); err != nil {
+			return err
+		}
+	}
+	return cur.Err()
+}
+
+// CollectionNames lists the collections in the database.
+func (db *DB) CollectionNames(ctx context.Context) ([]string, error) {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
+	return db.Database.ListCollectionNames(ctx, bson.M{})
+}
@@ -2,14 +2,18 @@ package store
 
 import (
 	"context"
+	"time"
 
 	"github.com/kevinaaaquil/books/backend/models"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 func (db *DB) InsertBook(ctx context.Context, book *models.Book) (primitive.ObjectID, error) {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
 	res, err := db.Books().InsertOne(ctx, book, options.InsertOne())
 	if err != nil {
 		return primitive.NilObjectID, err
@@ -18,7 +22,9 @@ func (db *DB) InsertBook(ctx context.Context, book *models.Book) (primitive.Obje
 }
 
 func (db *DB) AllBooks(ctx context.Context) ([]models.Book, error) {
-	cur, err := db.Books().Find(ctx, bson.M{}, options.Find().SetSort(bson.M{"createdAt": -1}))
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
+	cur, err := db.BooksForListing().Find(ctx, bson.M{}, options.Find().SetSort(bson.M{"createdAt": -1}))
 	if err != nil {
 		return nil, err
 	}
@@ -32,7 +38,9 @@ func (db *DB) AllBooks(ctx context.Context) ([]models.Book, error) {
 
 // BooksVisibleToGuest returns books where viewByGuest is true (for guest-role users).
 func (db *DB) BooksVisibleToGuest(ctx context.Context) ([]models.Book, error) {
-	cur, err := db.Books().Find(ctx, bson.M{"viewByGuest": true}, options.Find().SetSort(bson.M{"createdAt": -1}))
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
+	cur, err := db.BooksForListing().Find(ctx, bson.M{"viewByGuest": true}, options.Find().SetSort(bson.M{"createdAt": -1}))
 	if err != nil {
 		return nil, err
 	}
@@ -45,8 +53,28 @@ func (db *DB) BooksVisibleToGuest(ctx context.Context) ([]models.Book, error) {
 }
 
 func (db *DB) BookByID(ctx context.Context, id primitive.ObjectID) (*models.Book, error) {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
 	var book models.Book
 	err := db.Books().FindOne(ctx, bson.M{"_id": id}).Decode(&book)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &book, nil
+}
+
+// BookBySHA256 returns a book whose file has the given hex SHA-256, or nil if there is none.
+func (db *DB) BookBySHA256(ctx context.Context, sha256 string) (*models.Book, error) {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
+	var book models.Book
+	err := db.Books().FindOne(ctx, bson.M{"sha256": sha256}).Decode(&book)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -55,8 +83,13 @@ func (db *DB) BookByID(ctx context.Context, id primitive.ObjectID) (*models.Book
 
 // DeleteBook removes a book by ID. Returns the deleted book's S3Key, CoverS3Key (if any), and any error.
 func (db *DB) DeleteBook(ctx context.Context, id primitive.ObjectID) (s3Key, coverS3Key string, err error) {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
 	var book models.Book
 	err = db.Books().FindOneAndDelete(ctx, bson.M{"_id": id}).Decode(&book)
+	if err == mongo.ErrNoDocuments {
+		return "", "", ErrNotFound
+	}
 	if err != nil {
 		return "", "", err
 	}
@@ -65,12 +98,17 @@ func (db *DB) DeleteBook(ctx context.Context, id primitive.ObjectID) (s3Key, cov
 
 // UpdateBookMetadata updates a book's metadata fields by ID.
 func (db *DB) UpdateBookMetadata(ctx context.Context, id primitive.ObjectID, book *models.Book) error {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
 	update := bson.M{
 		"title":          book.Title,
 		"authors":        book.Authors,
 		"publisher":      book.Publisher,
 		"publishDate":    book.PublishDate,
 		"isbn":           book.ISBN,
+		"doi":            book.DOI,
+		"journal":        book.Journal,
+		"kind":           book.Kind,
 		"pageCount":      book.PageCount,
 		"coverUrl":       book.CoverURL,
 		"thumbnailUrl":   book.ThumbnailURL,
@@ -80,6 +118,7 @@ func (db *DB) UpdateBookMetadata(ctx context.Context, id primitive.ObjectID, boo
 		"categories":     book.Categories,
 		"ratingAverage": book.RatingAverage,
 		"ratingCount":    book.RatingCount,
+		"metadataError":  book.MetadataError,
 	}
 	_, err := db.Books().UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": update})
 	return err
@@ -87,6 +126,158 @@ func (db *DB) UpdateBookMetadata(ctx context.Context, id primitive.ObjectID, boo
 
 // UpdateBookViewByGuest sets viewByGuest for a book (admin only).
 func (db *DB) UpdateBookViewByGuest(ctx context.Context, id primitive.ObjectID, viewByGuest bool) error {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
 	_, err := db.Books().UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{"viewByGuest": viewByGuest}})
 	return err
 }
+
+// SetBookContentRating sets a book's content rating; "" goes back to inferring it from the categories.
+func (db *DB) SetBookContentRating(ctx context.Context, id primitive.ObjectID, rating string) error {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
+	_, err := db.Books().UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{"contentRating": rating}})
+	return err
+}
+
+// SetBookSuggestedCategories records the classifier's suggested categories for a book; nil clears them.
+func (db *DB) SetBookSuggestedCategories(ctx context.Context, id primitive.ObjectID, categories []string) error {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
+	update := bson.M{"$set": bson.M{"suggestedCategories": categories}}
+	if len(categories) == 0 {
+		update = bson.M{"$unset": bson.M{"suggestedCategories": ""}}
+	}
+	_, err := db.Books().UpdateOne(ctx, bson.M{"_id": id}, update)
+	return err
+}
+
+// SetBookMetadataError records the reason the last metadata lookup for a book failed.
+func (db *DB) SetBookMetadataError(ctx context.Context, id primitive.ObjectID, msg string) error {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
+	_, err := db.Books().UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{"metadataError": msg}})
+	return err
+}
+
+// SetBookCover records the storage key of a book's cover image.
+func (db *DB) SetBookCover(ctx context.Context, id primitive.ObjectID, coverS3Key string) error {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
+	_, err := db.Books().UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{"coverS3Key": coverS3Key}})
+	return err
+}
+
+// SetBookUploadSteps records the outcome of each upload step for a book.
+func (db *DB) SetBookUploadSteps(ctx context.Context, id primitive.ObjectID, steps []models.UploadStep) error {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
+	_, err := db.Books().UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{"uploadSteps": steps}})
+	return err
+}
+
+// bookIterationOrder is the order of ForEachBook and ForEachBookMissingFileInfo. Ties on createdAt are broken by
+// _id so the order is the same on every pass, which resumed jobs rely on.
+var bookIterationOrder = bson.D{{Key: "createdAt", Value: 1}, {Key: "_id", Value: 1}}
+
+// ForEachBook calls fn for every book (oldest first), stopping at the first error.
+func (db *DB) ForEachBook(ctx context.Context, fn func(*models.Book) error) error {
+	cur, err := db.Books().Find(ctx, bson.M{}, options.Find().SetSort(bookIterationOrder))
+	if err != nil {
+		return err
+	}
+	defer cur.Close(ctx)
+	for cur.Next(ctx) {
+		var book models.Book
+		if err := cur.Decode(&book); err != nil {
+			return err
+		}
+		if err := fn(&book); err != nil {
+			return err
+		}
+	}
+	return cur.Err()
+}
+
+// UpdateBookFileStatus records the result of a storage integrity check for a book.
+func (db *DB) UpdateBookFileStatus(ctx context.Context, id primitive.ObjectID, status string, checkedAt time.Time) error {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
+	_, err := db.Books().UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{"fileStatus": status, "fileCheckedAt": checkedAt}})
+	return err
+}
+
+// SetBookSize records the stored file size for a book (fills in sizes for books uploaded before they were tracked).
+func (db *DB) SetBookSize(ctx context.Context, id primitive.ObjectID, size int64) error {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
+	_, err := db.Books().UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{"sizeBytes": size}})
+	return err
+}
+
+// SetBookStorageClass records the storage class a book's file was moved to ("" = standard, clearing archivedAt).
+func (db *DB) SetBookStorageClass(ctx context.Context, id primitive.ObjectID, class string, archivedAt time.Time) error {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
+	update := bson.M{"$set": bson.M{"storageClass": class, "archivedAt": archivedAt}}
+	if class == "" {
+		update = bson.M{"$unset": bson.M{"storageClass": "", "archivedAt": "", "restoreRequestedAt": ""}}
+	}
+	_, err := db.Books().UpdateOne(ctx, bson.M{"_id": id}, update)
+	return err
+}
+
+// SetBookRestoreRequested records when a restore of the book's archived file was started.
+func (db *DB) SetBookRestoreRequested(ctx context.Context, id primitive.ObjectID, at time.Time) error {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
+	_, err := db.Books().UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{"restoreRequestedAt": at}})
+	return err
+}
+
+// UpdateBookFileInfo saves the size, hash and counts computed from a book's file, and its EPUB parse error ("" clears it).
+func (db *DB) UpdateBookFileInfo(ctx context.Context, id primitive.ObjectID, info models.FileInfo, parseError string) error {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
+	set := bson.M{
+		"sizeBytes":     info.SizeBytes,
+		"sha256":        info.SHA256,
+		"wordCount":     info.WordCount,
+		"filePageCount": info.FilePageCount,
+		"accessibility": info.Accessibility,
+		"parseError":    parseError,
+	}
+	_, err := db.Books().UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": set})
+	return err
+}
+
+// BooksMissingFileInfoCount returns how many books have no recorded size or hash.
+func (db *DB) BooksMissingFileInfoCount(ctx context.Context) (int64, error) {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
+	return db.Books().CountDocuments(ctx, missingFileInfoFilter)
+}
+
+// ForEachBookMissingFileInfo calls fn for every book with no recorded size or hash, stopping at the first error.
+func (db *DB) ForEachBookMissingFileInfo(ctx context.Context, fn func(*models.Book) error) error {
+	cur, err := db.Books().Find(ctx, missingFileInfoFilter, options.Find().SetSort(bookIterationOrder))
+	if err != nil {
+		return err
+	}
+	defer cur.Close(ctx)
+	for cur.Next(ctx) {
+		var book models.Book
+		if err := cur.Decode(&book); err != nil {
+			return err
+		}
+		if err := fn(&book); err != nil {
+			return err
+		}
+	}
+	return cur.Err()
+}
+
+var missingFileInfoFilter = bson.M{"$or": bson.A{
+	bson.M{"sizeBytes": bson.M{"$in": bson.A{nil, 0}}},
+	bson.M{"sha256": bson.M{"$in": bson.A{nil, ""}}},
+}}
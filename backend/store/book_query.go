@@ -0,0 +1,121 @@
+package store
+
+import (
+	"context"
+	"regexp"
+	"slices"
+
+	"github.com/kevinaaaquil/books/backend/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// BooksPage returns the page of books q selects and how many match across every page. Filtering, sorting and
+// paging all run in the server: a $facet returns the page and the count in one round trip.
+func (db *DB) BooksPage(ctx context.Context, q models.BookQuery) ([]models.Book, int, error) {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
+	pipeline := append(mongo.Pipeline{{{Key: "$match", Value: bookQueryFilter(q)}}}, contentRatingStages(q.MaxContentRating)...)
+	dir := 1
+	if q.Desc {
+		dir = -1
+	}
+	var sort bson.D
+	switch q.Sort {
+	case models.BookSortTitle:
+		pipeline = append(pipeline, bson.D{{Key: "$addFields", Value: bson.M{"_sortKey": bson.M{"$toLower": "$title"}}}})
+		sort = bson.D{{Key: "_sortKey", Value: dir}}
+	case models.BookSortAuthor:
+		pipeline = append(pipeline, bson.D{{Key: "$addFields", Value: bson.M{"_sortKey": bson.M{"$toLower": bson.M{"$arrayElemAt": bson.A{bson.M{"$ifNull": bson.A{"$authors", bson.A{}}}, 0}}}}}})
+		sort = bson.D{{Key: "_sortKey", Value: dir}}
+	case models.BookSortRating:
+		// Unrated books have neither field; count them as 0 like the Go zero value.
+		pipeline = append(pipeline, bson.D{{Key: "$addFields", Value: bson.M{
+			"_sortKey":   bson.M{"$ifNull": bson.A{"$ratingAverage", 0}},
+			"_sortCount": bson.M{"$ifNull": bson.A{"$ratingCount", 0}},
+		}}})
+		sort = bson.D{{Key: "_sortKey", Value: dir}, {Key: "_sortCount", Value: dir}}
+	default:
+		sort = bson.D{{Key: "createdAt", Value: dir}}
+	}
+	page := bson.A{
+		bson.D{{Key: "$sort", Value: append(sort, bson.E{Key: "_id", Value: dir})}},
+		bson.D{{Key: "$skip", Value: int64(q.Offset)}},
+	}
+	if q.Limit > 0 {
+		page = append(page, bson.D{{Key: "$limit", Value: int64(q.Limit)}})
+	}
+	page = append(page, bson.D{{Key: "$project", Value: bson.M{"_sortKey": 0, "_sortCount": 0}}})
+	pipeline = append(pipeline, bson.D{{Key: "$facet", Value: bson.M{
+		"books": page,
+		"total": bson.A{bson.D{{Key: "$count", Value: "n"}}},
+	}}})
+
+	cur, err := db.BooksForListing().Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer cur.Close(ctx)
+	var facets []struct {
+		Books []models.Book `bson:"books"`
+		Total []struct {
+			N int `bson:"n"`
+		} `bson:"total"`
+	}
+	if err := cur.All(ctx, &facets); err != nil {
+		return nil, 0, err
+	}
+	if len(facets) == 0 || len(facets[0].Total) == 0 {
+		return []models.Book{}, 0, nil
+	}
+	return facets[0].Books, facets[0].Total[0].N, nil
+}
+
+// bookQueryFilter matches the books q selects, except by content rating (see contentRatingStages).
+func bookQueryFilter(q models.BookQuery) bson.M {
+	filter := bson.M{}
+	if q.GuestOnly {
+		filter["viewByGuest"] = true
+	}
+	if q.IDs != nil {
+		filter["_id"] = bson.M{"$in": q.IDs}
+	}
+	var features bson.A
+	for _, f := range q.Accessibility {
+		features = append(features, bson.M{"accessibility.features": bson.M{"$regex": "^" + regexp.QuoteMeta(f) + "$", "$options": "i"}})
+	}
+	if features != nil {
+		filter["$and"] = features
+	}
+	return filter
+}
+
+// contentRatingStages returns the stages keeping only books a user limited to maxRating may see (see
+// models.ContentRatingAllowed), with the rating inferred from the categories as models.InferContentRating does
+// for books that have none set. It returns no stages when maxRating is "".
+func contentRatingStages(maxRating string) []bson.D {
+	if maxRating == "" {
+		return nil
+	}
+	var branches bson.A
+	for _, m := range models.ContentRatingCategories {
+		branches = append(branches, bson.M{
+			"case": bson.M{"$regexMatch": bson.M{"input": "$$c", "regex": regexp.QuoteMeta(m.Word), "options": "i"}},
+			"then": slices.Index(models.ContentRatings, m.Rating),
+		})
+	}
+	inferred := bson.M{"$max": bson.A{-1, bson.M{"$max": bson.M{"$map": bson.M{
+		"input": bson.M{"$ifNull": bson.A{"$categories", bson.A{}}},
+		"as":    "c",
+		"in":    bson.M{"$switch": bson.M{"branches": branches, "default": -1}},
+	}}}}}
+	level := bson.M{"$cond": bson.A{
+		bson.M{"$gt": bson.A{bson.M{"$ifNull": bson.A{"$contentRating", ""}}, ""}},
+		bson.M{"$indexOfArray": bson.A{models.ContentRatings, "$contentRating"}},
+		inferred,
+	}}
+	return []bson.D{{{Key: "$match", Value: bson.M{"$expr": bson.M{"$let": bson.M{
+		"vars": bson.M{"level": level},
+		"in":   bson.M{"$and": bson.A{bson.M{"$gte": bson.A{"$$level", 0}}, bson.M{"$lte": bson.A{"$$level", slices.Index(models.ContentRatings, maxRating)}}}},
+	}}}}}}
+}
@@ -0,0 +1,128 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/kevinaaaquil/books/backend/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Server error codes for change streams.
+const (
+	codeChangeStreamReplicaSetOnly = 40573 // "$changeStream is only supported on replica sets"
+	codeChangeStreamHistoryLost    = 286   // the resume point has aged out of the oplog
+)
+
+// watchRetryDelay is how long WatchBooks waits before reopening a failed change stream.
+const watchRetryDelay = 5 * time.Second
+
+type bookChangeEvent struct {
+	OperationType string `bson:"operationType"`
+	DocumentKey   struct {
+		ID primitive.ObjectID `bson:"_id"`
+	} `bson:"documentKey"`
+	FullDocument      *models.Book `bson:"fullDocument"`
+	UpdateDescription struct {
+		UpdatedFields bson.Raw `bson:"updatedFields"`
+		RemovedFields []string `bson:"removedFields"`
+	} `bson:"updateDescription"`
+}
+
+// WatchBooks follows a change stream on the books collection. After an error it reopens the stream where it
+// left off; if that point is no longer in the oplog it starts again from the present, so changes in between are
+// missed (a search reindex recovers them).
+func (db *DB) WatchBooks(ctx context.Context, fn func(BookChange)) error {
+	var resume bson.Raw
+	for {
+		err := db.watchBooks(ctx, &resume, fn)
+		if ctx.Err() != nil {
+			return nil
+		}
+		var serverErr mongo.ServerError
+		if errors.As(err, &serverErr) {
+			if serverErr.HasErrorCode(codeChangeStreamReplicaSetOnly) {
+				return fmt.Errorf("%w: %v", ErrWatchUnsupported, err)
+			}
+			if serverErr.HasErrorCode(codeChangeStreamHistoryLost) {
+				resume = nil
+			}
+		}
+		log.Printf("mongodb: books change stream: %v; reopening in %s", err, watchRetryDelay)
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(watchRetryDelay):
+		}
+	}
+}
+
+func (db *DB) watchBooks(ctx context.Context, resume *bson.Raw, fn func(BookChange)) error {
+	opts := options.ChangeStream().SetFullDocument(options.UpdateLookup)
+	if *resume != nil {
+		opts.SetStartAfter(*resume)
+	}
+	stream, err := db.Books().Watch(ctx, mongo.Pipeline{}, opts)
+	if err != nil {
+		return err
+	}
+	defer stream.Close(context.Background())
+	for stream.Next(ctx) {
+		var ev bookChangeEvent
+		if err := stream.Decode(&ev); err != nil {
+			return err
+		}
+		if change, ok := ev.change(); ok {
+			fn(change)
+		}
+		*resume = stream.ResumeToken()
+	}
+	if err := stream.Err(); err != nil {
+		return err
+	}
+	// The stream was invalidated (collection dropped or renamed); start watching the new collection afresh.
+	*resume = nil
+	return errors.New("change stream closed")
+}
+
+// change converts a change event; ok is false for events that don't concern a single book (drop, invalidate, ...)
+// and for updates to books deleted before the event was read.
+func (ev *bookChangeEvent) change() (BookChange, bool) {
+	c := BookChange{BookID: ev.DocumentKey.ID, Book: ev.FullDocument}
+	switch ev.OperationType {
+	case "insert":
+		c.Op = BookInserted
+	case "replace":
+		c.Op = BookUpdated
+	case "update":
+		c.Op = BookUpdated
+		c.Fields = []string{}
+		elems, _ := ev.UpdateDescription.UpdatedFields.Elements()
+		for _, e := range elems {
+			c.Fields = append(c.Fields, topLevelField(e.Key()))
+		}
+		for _, f := range ev.UpdateDescription.RemovedFields {
+			c.Fields = append(c.Fields, topLevelField(f))
+		}
+	case "delete":
+		c.Op = BookDeleted
+		c.Book = nil
+		return c, true
+	default:
+		return c, false
+	}
+	return c, c.Book != nil
+}
+
+// topLevelField returns the first element of a dotted field path ("fileInfo.sizeBytes" -> "fileInfo").
+func topLevelField(path string) string {
+	field, _, _ := strings.Cut(path, ".")
+	return field
+}
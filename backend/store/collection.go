@@ -0,0 +1,103 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/kevinaaaquil/books/backend/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func (db *DB) InsertCollection(ctx context.Context, c *models.Collection) (primitive.ObjectID, error) {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
+	doc := *c
+	if doc.BookIDs == nil {
+		doc.BookIDs = []primitive.ObjectID{}
+	}
+	res, err := db.Collections().InsertOne(ctx, doc)
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+	return res.InsertedID.(primitive.ObjectID), nil
+}
+
+// CollectionsForUser returns the user's collections, oldest first.
+func (db *DB) CollectionsForUser(ctx context.Context, userID primitive.ObjectID) ([]models.Collection, error) {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
+	cur, err := db.Collections().Find(ctx, bson.M{"userId": userID}, options.Find().SetSort(bson.D{{Key: "createdAt", Value: 1}, {Key: "_id", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+	collections := []models.Collection{}
+	if err := cur.All(ctx, &collections); err != nil {
+		return nil, err
+	}
+	return collections, nil
+}
+
+// Collection returns one of the user's collections, or nil if it does not exist or belongs to someone else.
+func (db *DB) Collection(ctx context.Context, userID, id primitive.ObjectID) (*models.Collection, error) {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
+	var c models.Collection
+	err := db.Collections().FindOne(ctx, bson.M{"_id": id, "userId": userID}).Decode(&c)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// RenameCollection renames one of the user's collections. Returns false if it does not exist.
+func (db *DB) RenameCollection(ctx context.Context, userID, id primitive.ObjectID, name string, at time.Time) (bool, error) {
+	return db.updateCollection(ctx, userID, id, bson.M{"$set": bson.M{"name": name, "updatedAt": at}})
+}
+
+// AddBookToCollection appends bookID to one of the user's collections, unless it is there already. Returns false
+// if the collection does not exist.
+func (db *DB) AddBookToCollection(ctx context.Context, userID, id, bookID primitive.ObjectID, at time.Time) (bool, error) {
+	return db.updateCollection(ctx, userID, id, bson.M{"$addToSet": bson.M{"bookIds": bookID}, "$set": bson.M{"updatedAt": at}})
+}
+
+// RemoveBookFromCollection takes bookID off one of the user's collections. Returns false if the collection does
+// not exist.
+func (db *DB) RemoveBookFromCollection(ctx context.Context, userID, id, bookID primitive.ObjectID, at time.Time) (bool, error) {
+	return db.updateCollection(ctx, userID, id, bson.M{"$pull": bson.M{"bookIds": bookID}, "$set": bson.M{"updatedAt": at}})
+}
+
+func (db *DB) updateCollection(ctx context.Context, userID, id primitive.ObjectID, update bson.M) (bool, error) {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
+	res, err := db.Collections().UpdateOne(ctx, bson.M{"_id": id, "userId": userID}, update)
+	if err != nil {
+		return false, err
+	}
+	return res.MatchedCount > 0, nil
+}
+
+// RemoveBookFromCollections takes a deleted book off every collection.
+func (db *DB) RemoveBookFromCollections(ctx context.Context, bookID primitive.ObjectID) error {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
+	_, err := db.Collections().UpdateMany(ctx, bson.M{"bookIds": bookID}, bson.M{"$pull": bson.M{"bookIds": bookID}})
+	return err
+}
+
+// DeleteCollection removes one of the user's collections. Returns false if it does not exist.
+func (db *DB) DeleteCollection(ctx context.Context, userID, id primitive.ObjectID) (bool, error) {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
+	res, err := db.Collections().DeleteOne(ctx, bson.M{"_id": id, "userId": userID})
+	if err != nil {
+		return false, err
+	}
+	return res.DeletedCount > 0, nil
+}
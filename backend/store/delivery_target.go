@@ -0,0 +1,70 @@
+package store
+
+import (
+	"context"
+
+	"github.com/kevinaaaquil/books/backend/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func (db *DB) InsertDeliveryTarget(ctx context.Context, t *models.DeliveryTarget) (primitive.ObjectID, error) {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
+	res, err := db.DeliveryTargets().InsertOne(ctx, t)
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+	return res.InsertedID.(primitive.ObjectID), nil
+}
+
+// DeliveryTargetsForUser returns the user's targets, oldest first.
+func (db *DB) DeliveryTargetsForUser(ctx context.Context, userID primitive.ObjectID) ([]models.DeliveryTarget, error) {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
+	cur, err := db.DeliveryTargets().Find(ctx, bson.M{"userId": userID}, options.Find().SetSort(bson.M{"createdAt": 1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+	targets := []models.DeliveryTarget{}
+	if err := cur.All(ctx, &targets); err != nil {
+		return nil, err
+	}
+	return targets, nil
+}
+
+// DeliveryTarget returns one of the user's targets, or nil if it does not exist or belongs to someone else.
+func (db *DB) DeliveryTarget(ctx context.Context, userID, id primitive.ObjectID) (*models.DeliveryTarget, error) {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
+	var t models.DeliveryTarget
+	err := db.DeliveryTargets().FindOne(ctx, bson.M{"_id": id, "userId": userID}).Decode(&t)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func (db *DB) UpdateDeliveryTargetVerification(ctx context.Context, id primitive.ObjectID, v *models.AddressVerification) error {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
+	_, err := db.DeliveryTargets().UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{"verification": v}})
+	return err
+}
+
+// DeleteDeliveryTarget removes one of the user's targets. Returns false if it does not exist.
+func (db *DB) DeleteDeliveryTarget(ctx context.Context, userID, id primitive.ObjectID) (bool, error) {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
+	res, err := db.DeliveryTargets().DeleteOne(ctx, bson.M{"_id": id, "userId": userID})
+	if err != nil {
+		return false, err
+	}
+	return res.DeletedCount > 0, nil
+}
@@ -0,0 +1,75 @@
+package store
+
+import (
+	"context"
+
+	"github.com/kevinaaaquil/books/backend/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func (db *DB) InsertDevice(ctx context.Context, d *models.Device) (primitive.ObjectID, error) {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
+	res, err := db.Devices().InsertOne(ctx, d)
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+	return res.InsertedID.(primitive.ObjectID), nil
+}
+
+// DevicesForUser returns the user's devices, oldest first.
+func (db *DB) DevicesForUser(ctx context.Context, userID primitive.ObjectID) ([]models.Device, error) {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
+	cur, err := db.Devices().Find(ctx, bson.M{"userId": userID}, options.Find().SetSort(bson.M{"createdAt": 1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+	devices := []models.Device{}
+	if err := cur.All(ctx, &devices); err != nil {
+		return nil, err
+	}
+	return devices, nil
+}
+
+// Device returns one of the user's devices, or nil if it does not exist or belongs to someone else.
+func (db *DB) Device(ctx context.Context, userID, id primitive.ObjectID) (*models.Device, error) {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
+	var d models.Device
+	err := db.Devices().FindOne(ctx, bson.M{"_id": id, "userId": userID}).Decode(&d)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+// UpdateDevice replaces the profile of one of d.UserID's devices. Returns false if it does not exist.
+func (db *DB) UpdateDevice(ctx context.Context, d *models.Device) (bool, error) {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
+	set := bson.M{"name": d.Name, "type": d.Type, "formats": d.Formats, "maxAttachmentMB": d.MaxAttachmentMB, "targetId": d.TargetID, "optimize": d.Optimize}
+	res, err := db.Devices().UpdateOne(ctx, bson.M{"_id": d.ID, "userId": d.UserID}, bson.M{"$set": set})
+	if err != nil {
+		return false, err
+	}
+	return res.MatchedCount > 0, nil
+}
+
+// DeleteDevice removes one of the user's devices. Returns false if it does not exist.
+func (db *DB) DeleteDevice(ctx context.Context, userID, id primitive.ObjectID) (bool, error) {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
+	res, err := db.Devices().DeleteOne(ctx, bson.M{"_id": id, "userId": userID})
+	if err != nil {
+		return false, err
+	}
+	return res.DeletedCount > 0, nil
+}
@@ -0,0 +1,102 @@
+package docstore
+
+import (
+	"context"
+	"time"
+
+	"github.com/kevinaaaquil/books/backend/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func (s *Store) InsertAPIKey(ctx context.Context, k *models.APIKey) (primitive.ObjectID, error) {
+	ctx, cancel := opCtx(ctx)
+	defer cancel()
+	c := *k
+	if c.ID.IsZero() {
+		c.ID = primitive.NewObjectID()
+	}
+	if err := insertDoc(ctx, s, collAPIKeys, c.ID, &c); err != nil {
+		return primitive.NilObjectID, err
+	}
+	return c.ID, nil
+}
+
+// APIKeysForUser returns the user's keys, oldest first.
+func (s *Store) APIKeysForUser(ctx context.Context, userID primitive.ObjectID) ([]models.APIKey, error) {
+	ctx, cancel := opCtx(ctx)
+	defer cancel()
+	keys, err := findBy[models.APIKey](ctx, s, collAPIKeys, "userId.$oid", userID.Hex())
+	if err != nil {
+		return nil, err
+	}
+	byTime(keys, false, func(k *models.APIKey) time.Time { return k.CreatedAt })
+	return keys, nil
+}
+
+// APIKeyByHash returns the key with this hash, or nil if there is none.
+func (s *Store) APIKeyByHash(ctx context.Context, keyHash string) (*models.APIKey, error) {
+	ctx, cancel := opCtx(ctx)
+	defer cancel()
+	keys, err := findBy[models.APIKey](ctx, s, collAPIKeys, "keyHash", keyHash)
+	if err != nil || len(keys) == 0 {
+		return nil, err
+	}
+	return &keys[0], nil
+}
+
+// TouchAPIKey records that a key was used at.
+func (s *Store) TouchAPIKey(ctx context.Context, id primitive.ObjectID, at time.Time) error {
+	ctx, cancel := opCtx(ctx)
+	defer cancel()
+	_, err := updateDoc(ctx, s, collAPIKeys, id, func(k *models.APIKey) { k.LastUsedAt = &at })
+	return err
+}
+
+// DeleteAPIKey revokes one of the user's keys. Returns false if it does not exist.
+func (s *Store) DeleteAPIKey(ctx context.Context, userID, id primitive.ObjectID) (bool, error) {
+	ctx, cancel := opCtx(ctx)
+	defer cancel()
+	k, err := getDoc[models.APIKey](ctx, s, collAPIKeys, id)
+	if isNotFound(err) || (err == nil && k.UserID != userID) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if _, err := s.engine.Delete(ctx, collAPIKeys, id.Hex()); err != nil {
+		if isNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// RotateAPIKey replaces the secret of one of the user's keys, keeping its name, scopes and expiry. Returns false
+// if it does not exist.
+func (s *Store) RotateAPIKey(ctx context.Context, userID, id primitive.ObjectID, prefix, keyHash string, at time.Time) (bool, error) {
+	ctx, cancel := opCtx(ctx)
+	defer cancel()
+	k, err := getDoc[models.APIKey](ctx, s, collAPIKeys, id)
+	if isNotFound(err) || (err == nil && k.UserID != userID) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return updateDoc(ctx, s, collAPIKeys, id, func(k *models.APIKey) {
+		k.Prefix, k.KeyHash, k.RotatedAt, k.LastUsedAt = prefix, keyHash, &at, nil
+	})
+}
+
+// AllAPIKeys returns every user's keys, oldest first.
+func (s *Store) AllAPIKeys(ctx context.Context) ([]models.APIKey, error) {
+	ctx, cancel := opCtx(ctx)
+	defer cancel()
+	keys, err := findAll[models.APIKey](ctx, s, collAPIKeys, nil)
+	if err != nil {
+		return nil, err
+	}
+	byTime(keys, false, func(k *models.APIKey) time.Time { return k.CreatedAt })
+	return keys, nil
+}
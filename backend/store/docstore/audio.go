@@ -0,0 +1,75 @@
+package docstore
+
+import (
+	"context"
+	"slices"
+
+	"github.com/kevinaaaquil/books/backend/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// UpsertAudioTrack replaces t.BookID's track for t.Chapter, and sets t.ID.
+func (s *Store) UpsertAudioTrack(ctx context.Context, t *models.AudioTrack) error {
+	existing, err := s.AudioTrack(ctx, t.BookID, t.Chapter)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := opCtx(ctx)
+	defer cancel()
+	doc := *t
+	doc.URL = ""
+	if existing != nil {
+		doc.ID = existing.ID
+		if _, err := updateDoc(ctx, s, collAudioTracks, doc.ID, func(stored *models.AudioTrack) { *stored = doc }); err != nil {
+			return err
+		}
+	} else {
+		doc.ID = primitive.NewObjectID()
+		if err := insertDoc(ctx, s, collAudioTracks, doc.ID, &doc); err != nil {
+			return err
+		}
+	}
+	t.ID = doc.ID
+	return nil
+}
+
+// AudioTracks returns the book's tracks by chapter.
+func (s *Store) AudioTracks(ctx context.Context, bookID primitive.ObjectID) ([]models.AudioTrack, error) {
+	ctx, cancel := opCtx(ctx)
+	defer cancel()
+	tracks, err := findAll(ctx, s, collAudioTracks, func(t *models.AudioTrack) bool { return t.BookID == bookID })
+	if err != nil {
+		return nil, err
+	}
+	slices.SortFunc(tracks, func(a, b models.AudioTrack) int { return a.Chapter - b.Chapter })
+	return tracks, nil
+}
+
+// AudioTrack returns the book's track for chapter, or nil if there is none.
+func (s *Store) AudioTrack(ctx context.Context, bookID primitive.ObjectID, chapter int) (*models.AudioTrack, error) {
+	ctx, cancel := opCtx(ctx)
+	defer cancel()
+	found, err := findAll(ctx, s, collAudioTracks, func(t *models.AudioTrack) bool {
+		return t.BookID == bookID && t.Chapter == chapter
+	})
+	if err != nil || len(found) == 0 {
+		return nil, err
+	}
+	return &found[0], nil
+}
+
+// DeleteAudioTracks removes a deleted book's tracks.
+func (s *Store) DeleteAudioTracks(ctx context.Context, bookID primitive.ObjectID) error {
+	ctx, cancel := opCtx(ctx)
+	defer cancel()
+	tracks, err := findAll(ctx, s, collAudioTracks, func(t *models.AudioTrack) bool { return t.BookID == bookID })
+	if err != nil {
+		return err
+	}
+	for _, t := range tracks {
+		if _, err := s.engine.Delete(ctx, collAudioTracks, t.ID.Hex()); err != nil && !isNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
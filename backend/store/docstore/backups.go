@@ -0,0 +1,44 @@
+package docstore
+
+import (
+	"context"
+	"time"
+
+	"github.com/kevinaaaquil/books/backend/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func (s *Store) InsertBackup(ctx context.Context, b *models.Backup) (primitive.ObjectID, error) {
+	ctx, cancel := opCtx(ctx)
+	defer cancel()
+	c := *b
+	if c.ID.IsZero() {
+		c.ID = primitive.NewObjectID()
+	}
+	if err := insertDoc(ctx, s, collBackups, c.ID, &c); err != nil {
+		return primitive.NilObjectID, err
+	}
+	return c.ID, nil
+}
+
+// ListBackups returns all recorded backups, newest first.
+func (s *Store) ListBackups(ctx context.Context) ([]models.Backup, error) {
+	ctx, cancel := opCtx(ctx)
+	defer cancel()
+	backups, err := findAll[models.Backup](ctx, s, collBackups, nil)
+	if err != nil {
+		return nil, err
+	}
+	byTime(backups, true, func(b *models.Backup) time.Time { return b.CreatedAt })
+	return backups, nil
+}
+
+func (s *Store) DeleteBackup(ctx context.Context, id primitive.ObjectID) error {
+	ctx, cancel := opCtx(ctx)
+	defer cancel()
+	_, err := s.engine.Delete(ctx, collBackups, id.Hex())
+	if isNotFound(err) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,95 @@
+package docstore
+
+import (
+	"context"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/kevinaaaquil/books/backend/models"
+)
+
+// bookPager is implemented by engines that select, sort and page books in the database, so that Store.BooksPage
+// only loads the page it returns. The memory engine does it in Go.
+type bookPager interface {
+	booksPage(ctx context.Context, q models.BookQuery) (docs [][]byte, total int, err error)
+}
+
+// bookSQL is how a SQL engine reads the book fields BooksPage filters and sorts on. The sort keys are written
+// exactly as in the books_* indexes of migration 0026, which the database only uses when they match.
+type bookSQL struct {
+	title, firstAuthor, ratingAverage, ratingCount, createdAt, id string // sort keys; text ones compare bytewise as in Go
+
+	viewByGuest   string                   // true when the book is visible to guests
+	contentRating string                   // the rating set on the book, "" when none
+	elements      func(path string) string // a FROM item listing the strings of the array at path as e.value
+	param         func(n int) string       // the placeholder of the nth argument, from 1
+	noLimit       string                   // the LIMIT that returns every row
+}
+
+// query returns the WHERE clause selecting the books q asks for (as models.BookQuery.Matches does) with its
+// arguments, and the ORDER BY, LIMIT and OFFSET clauses of the page (as models.SortBooks orders it).
+func (d *bookSQL) query(q models.BookQuery) (where string, args []any, page string) {
+	arg := func(v any) string {
+		args = append(args, v)
+		return d.param(len(args))
+	}
+	conds := []string{"TRUE"}
+	if q.GuestOnly {
+		conds = append(conds, d.viewByGuest)
+	}
+	if q.IDs != nil {
+		ids := make([]string, len(q.IDs))
+		for i, id := range q.IDs {
+			ids[i] = arg(id.Hex())
+		}
+		if len(ids) == 0 {
+			ids = []string{"NULL"}
+		}
+		conds = append(conds, "id IN ("+strings.Join(ids, ", ")+")")
+	}
+	for _, f := range q.Accessibility {
+		conds = append(conds, "EXISTS (SELECT 1 FROM "+d.elements("accessibility.features")+" WHERE lower(e.value) = lower("+arg(f)+"))")
+	}
+	if q.MaxContentRating != "" {
+		// The level of the rating set on the book, else the highest one its categories imply (see
+		// models.InferContentRating), or -1 when there is neither.
+		inferred := "CASE"
+		for _, m := range models.ContentRatingCategories {
+			inferred += " WHEN lower(e.value) LIKE " + sqlString("%"+m.Word+"%") + " THEN " + strconv.Itoa(slices.Index(models.ContentRatings, m.Rating))
+		}
+		level := "CASE " + d.contentRating + " WHEN '' THEN (SELECT COALESCE(MAX(" + inferred + " ELSE -1 END), -1) FROM " + d.elements("categories") + ")"
+		for i, r := range models.ContentRatings {
+			level += " WHEN " + sqlString(r) + " THEN " + strconv.Itoa(i)
+		}
+		level += " ELSE -1 END"
+		conds = append(conds, level+" BETWEEN 0 AND "+strconv.Itoa(slices.Index(models.ContentRatings, q.MaxContentRating)))
+	}
+
+	var keys []string
+	switch q.Sort {
+	case models.BookSortTitle:
+		keys = []string{d.title}
+	case models.BookSortAuthor:
+		keys = []string{d.firstAuthor}
+	case models.BookSortRating:
+		keys = []string{d.ratingAverage, d.ratingCount}
+	default:
+		keys = []string{d.createdAt}
+	}
+	dir := " ASC"
+	if q.Desc {
+		dir = " DESC"
+	}
+	limit := d.noLimit
+	if q.Limit > 0 {
+		limit = strconv.Itoa(q.Limit)
+	}
+	page = " ORDER BY " + strings.Join(append(keys, d.id), dir+", ") + dir + " LIMIT " + limit + " OFFSET " + strconv.Itoa(q.Offset)
+	return strings.Join(conds, " AND "), args, page
+}
+
+// sqlString quotes s as an SQL string literal.
+func sqlString(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
@@ -0,0 +1,262 @@
+package docstore
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/kevinaaaquil/books/backend/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func bookCreatedAt(b *models.Book) time.Time { return b.CreatedAt }
+
+func (s *Store) InsertBook(ctx context.Context, book *models.Book) (primitive.ObjectID, error) {
+	ctx, cancel := opCtx(ctx)
+	defer cancel()
+	b := *book
+	if b.ID.IsZero() {
+		b.ID = primitive.NewObjectID()
+	}
+	if err := insertDoc(ctx, s, collBooks, b.ID, &b); err != nil {
+		return primitive.NilObjectID, err
+	}
+	return b.ID, nil
+}
+
+func (s *Store) AllBooks(ctx context.Context) ([]models.Book, error) {
+	ctx, cancel := opCtx(ctx)
+	defer cancel()
+	books, err := findAll[models.Book](ctx, s, collBooks, nil)
+	if err != nil {
+		return nil, err
+	}
+	byTime(books, true, bookCreatedAt)
+	return books, nil
+}
+
+// BooksVisibleToGuest returns books where viewByGuest is true (for guest-role users).
+func (s *Store) BooksVisibleToGuest(ctx context.Context) ([]models.Book, error) {
+	ctx, cancel := opCtx(ctx)
+	defer cancel()
+	books, err := findAll(ctx, s, collBooks, func(b *models.Book) bool { return b.ViewByGuest })
+	if err != nil {
+		return nil, err
+	}
+	byTime(books, true, bookCreatedAt)
+	return books, nil
+}
+
+// BooksPage returns the page of books q selects and how many match across every page.
+func (s *Store) BooksPage(ctx context.Context, q models.BookQuery) ([]models.Book, int, error) {
+	ctx, cancel := opCtx(ctx)
+	defer cancel()
+	if p, ok := s.engine.(bookPager); ok {
+		docs, total, err := p.booksPage(ctx, q)
+		if err != nil {
+			return nil, 0, err
+		}
+		books := make([]models.Book, len(docs))
+		for i, doc := range docs {
+			if err := decode(doc, &books[i]); err != nil {
+				return nil, 0, err
+			}
+		}
+		return books, total, nil
+	}
+	books, err := findAll(ctx, s, collBooks, q.Matches)
+	if err != nil {
+		return nil, 0, err
+	}
+	models.SortBooks(books, q.Sort, q.Desc)
+	total := len(books)
+	books = books[min(q.Offset, total):]
+	if q.Limit > 0 {
+		books = books[:min(q.Limit, len(books))]
+	}
+	return books, total, nil
+}
+
+func (s *Store) BookByID(ctx context.Context, id primitive.ObjectID) (*models.Book, error) {
+	ctx, cancel := opCtx(ctx)
+	defer cancel()
+	return getDoc[models.Book](ctx, s, collBooks, id)
+}
+
+// BookBySHA256 returns a book whose file has the given hex SHA-256, or nil if there is none.
+func (s *Store) BookBySHA256(ctx context.Context, sha256 string) (*models.Book, error) {
+	ctx, cancel := opCtx(ctx)
+	defer cancel()
+	if sha256 == "" {
+		return nil, nil
+	}
+	books, err := findBy[models.Book](ctx, s, collBooks, "sha256", sha256)
+	if err != nil || len(books) == 0 {
+		return nil, err
+	}
+	return &books[0], nil
+}
+
+// DeleteBook removes a book by ID. Returns the deleted book's S3Key, CoverS3Key (if any), and any error.
+func (s *Store) DeleteBook(ctx context.Context, id primitive.ObjectID) (s3Key, coverS3Key string, err error) {
+	ctx, cancel := opCtx(ctx)
+	defer cancel()
+	doc, err := s.engine.Delete(ctx, collBooks, id.Hex())
+	if err != nil {
+		return "", "", err
+	}
+	var book models.Book
+	if err := decode(doc, &book); err != nil {
+		return "", "", err
+	}
+	return book.S3Key, book.CoverS3Key, nil
+}
+
+func (s *Store) updateBook(ctx context.Context, id primitive.ObjectID, fn func(*models.Book)) error {
+	ctx, cancel := opCtx(ctx)
+	defer cancel()
+	_, err := updateDoc(ctx, s, collBooks, id, fn)
+	return err
+}
+
+// UpdateBookMetadata updates a book's metadata fields by ID.
+func (s *Store) UpdateBookMetadata(ctx context.Context, id primitive.ObjectID, book *models.Book) error {
+	return s.updateBook(ctx, id, func(b *models.Book) {
+		b.Title = book.Title
+		b.Authors = book.Authors
+		b.Publisher = book.Publisher
+		b.PublishDate = book.PublishDate
+		b.ISBN = book.ISBN
+		b.DOI = book.DOI
+		b.Journal = book.Journal
+		b.Kind = book.Kind
+		b.PageCount = book.PageCount
+		b.CoverURL = book.CoverURL
+		b.ThumbnailURL = book.ThumbnailURL
+		b.Edition = book.Edition
+		b.Preface = book.Preface
+		b.Category = book.Category
+		b.Categories = book.Categories
+		b.RatingAverage = book.RatingAverage
+		b.RatingCount = book.RatingCount
+		b.MetadataError = book.MetadataError
+	})
+}
+
+// UpdateBookViewByGuest sets viewByGuest for a book (admin only).
+func (s *Store) UpdateBookViewByGuest(ctx context.Context, id primitive.ObjectID, viewByGuest bool) error {
+	return s.updateBook(ctx, id, func(b *models.Book) { b.ViewByGuest = viewByGuest })
+}
+
+// SetBookContentRating sets a book's content rating; "" goes back to inferring it from the categories.
+func (s *Store) SetBookContentRating(ctx context.Context, id primitive.ObjectID, rating string) error {
+	return s.updateBook(ctx, id, func(b *models.Book) { b.ContentRating = rating })
+}
+
+// SetBookSuggestedCategories records the classifier's suggested categories for a book; nil clears them.
+func (s *Store) SetBookSuggestedCategories(ctx context.Context, id primitive.ObjectID, categories []string) error {
+	return s.updateBook(ctx, id, func(b *models.Book) { b.SuggestedCategories = categories })
+}
+
+// SetBookMetadataError records the reason the last metadata lookup for a book failed.
+func (s *Store) SetBookMetadataError(ctx context.Context, id primitive.ObjectID, msg string) error {
+	return s.updateBook(ctx, id, func(b *models.Book) { b.MetadataError = msg })
+}
+
+// SetBookCover records the storage key of a book's cover image.
+func (s *Store) SetBookCover(ctx context.Context, id primitive.ObjectID, coverS3Key string) error {
+	return s.updateBook(ctx, id, func(b *models.Book) { b.CoverS3Key = coverS3Key })
+}
+
+// SetBookUploadSteps records the outcome of each upload step for a book.
+func (s *Store) SetBookUploadSteps(ctx context.Context, id primitive.ObjectID, steps []models.UploadStep) error {
+	return s.updateBook(ctx, id, func(b *models.Book) { b.UploadSteps = steps })
+}
+
+// UpdateBookFileStatus records the result of a storage integrity check for a book.
+func (s *Store) UpdateBookFileStatus(ctx context.Context, id primitive.ObjectID, status string, checkedAt time.Time) error {
+	return s.updateBook(ctx, id, func(b *models.Book) {
+		b.FileStatus = status
+		b.FileCheckedAt = &checkedAt
+	})
+}
+
+// SetBookSize records the stored file size for a book.
+func (s *Store) SetBookSize(ctx context.Context, id primitive.ObjectID, size int64) error {
+	return s.updateBook(ctx, id, func(b *models.Book) { b.SizeBytes = size })
+}
+
+// SetBookStorageClass records the storage class a book's file was moved to ("" = standard, clearing archivedAt).
+func (s *Store) SetBookStorageClass(ctx context.Context, id primitive.ObjectID, class string, archivedAt time.Time) error {
+	return s.updateBook(ctx, id, func(b *models.Book) {
+		b.StorageClass, b.ArchivedAt = class, &archivedAt
+		if class == "" {
+			b.ArchivedAt, b.RestoreRequestedAt = nil, nil
+		}
+	})
+}
+
+// SetBookRestoreRequested records when a restore of the book's archived file was started.
+func (s *Store) SetBookRestoreRequested(ctx context.Context, id primitive.ObjectID, at time.Time) error {
+	return s.updateBook(ctx, id, func(b *models.Book) { b.RestoreRequestedAt = &at })
+}
+
+// UpdateBookFileInfo saves the size, hash and counts computed from a book's file, and its EPUB parse error ("" clears it).
+func (s *Store) UpdateBookFileInfo(ctx context.Context, id primitive.ObjectID, info models.FileInfo, parseError string) error {
+	return s.updateBook(ctx, id, func(b *models.Book) {
+		b.FileInfo = info
+		b.ParseError = parseError
+	})
+}
+
+// forEachBook calls fn for every book matching keep (oldest first), stopping at the first error.
+// Books are loaded up front, so fn may write to the store.
+func (s *Store) forEachBook(ctx context.Context, keep func(*models.Book) bool, fn func(*models.Book) error) error {
+	books, err := findAll(ctx, s, collBooks, keep)
+	if err != nil {
+		return err
+	}
+	// Oldest first with ties broken by ID, as in MongoDB, so resumed jobs see the same order on every pass.
+	sort.Slice(books, func(i, j int) bool {
+		if a, b := books[i].CreatedAt, books[j].CreatedAt; !a.Equal(b) {
+			return a.Before(b)
+		}
+		return books[i].ID.Hex() < books[j].ID.Hex()
+	})
+	for i := range books {
+		if err := fn(&books[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ForEachBook calls fn for every book (oldest first), stopping at the first error.
+func (s *Store) ForEachBook(ctx context.Context, fn func(*models.Book) error) error {
+	return s.forEachBook(ctx, nil, fn)
+}
+
+func missingFileInfo(b *models.Book) bool {
+	return b.SizeBytes == 0 || b.SHA256 == ""
+}
+
+// BooksMissingFileInfoCount returns how many books have no recorded size or hash.
+func (s *Store) BooksMissingFileInfoCount(ctx context.Context) (int64, error) {
+	ctx, cancel := opCtx(ctx)
+	defer cancel()
+	books, err := findAll(ctx, s, collBooks, missingFileInfo)
+	return int64(len(books)), err
+}
+
+// ForEachBookMissingFileInfo calls fn for every book with no recorded size or hash, stopping at the first error.
+func (s *Store) ForEachBookMissingFileInfo(ctx context.Context, fn func(*models.Book) error) error {
+	return s.forEachBook(ctx, missingFileInfo, fn)
+}
+
+// BooksCount returns the number of books in the catalog.
+func (s *Store) BooksCount(ctx context.Context) (int64, error) {
+	ctx, cancel := opCtx(ctx)
+	defer cancel()
+	docs, err := s.engine.All(ctx, collBooks)
+	return int64(len(docs)), err
+}
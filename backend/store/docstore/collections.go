@@ -0,0 +1,122 @@
+package docstore
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/kevinaaaquil/books/backend/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func (s *Store) InsertCollection(ctx context.Context, c *models.Collection) (primitive.ObjectID, error) {
+	ctx, cancel := opCtx(ctx)
+	defer cancel()
+	doc := *c
+	if doc.ID.IsZero() {
+		doc.ID = primitive.NewObjectID()
+	}
+	if doc.BookIDs == nil {
+		doc.BookIDs = []primitive.ObjectID{}
+	}
+	if err := insertDoc(ctx, s, collCollections, doc.ID, &doc); err != nil {
+		return primitive.NilObjectID, err
+	}
+	return doc.ID, nil
+}
+
+// CollectionsForUser returns the user's collections, oldest first.
+func (s *Store) CollectionsForUser(ctx context.Context, userID primitive.ObjectID) ([]models.Collection, error) {
+	ctx, cancel := opCtx(ctx)
+	defer cancel()
+	collections, err := findAll(ctx, s, collCollections, func(c *models.Collection) bool { return c.UserID == userID })
+	if err != nil {
+		return nil, err
+	}
+	// Created in the same instant: by ID, like Mongo's sort.
+	slices.SortFunc(collections, func(a, b models.Collection) int { return strings.Compare(a.ID.Hex(), b.ID.Hex()) })
+	byTime(collections, false, func(c *models.Collection) time.Time { return c.CreatedAt })
+	return collections, nil
+}
+
+// Collection returns one of the user's collections, or nil if it does not exist or belongs to someone else.
+func (s *Store) Collection(ctx context.Context, userID, id primitive.ObjectID) (*models.Collection, error) {
+	ctx, cancel := opCtx(ctx)
+	defer cancel()
+	c, err := getDoc[models.Collection](ctx, s, collCollections, id)
+	if isNotFound(err) || (err == nil && c.UserID != userID) {
+		return nil, nil
+	}
+	return c, err
+}
+
+// RenameCollection renames one of the user's collections. Returns false if it does not exist.
+func (s *Store) RenameCollection(ctx context.Context, userID, id primitive.ObjectID, name string, at time.Time) (bool, error) {
+	return s.updateCollection(ctx, userID, id, func(c *models.Collection) {
+		c.Name, c.UpdatedAt = name, at
+	})
+}
+
+// AddBookToCollection appends bookID to one of the user's collections, unless it is there already. Returns false
+// if the collection does not exist.
+func (s *Store) AddBookToCollection(ctx context.Context, userID, id, bookID primitive.ObjectID, at time.Time) (bool, error) {
+	return s.updateCollection(ctx, userID, id, func(c *models.Collection) {
+		if !slices.Contains(c.BookIDs, bookID) {
+			c.BookIDs = append(c.BookIDs, bookID)
+		}
+		c.UpdatedAt = at
+	})
+}
+
+// RemoveBookFromCollection takes bookID off one of the user's collections. Returns false if the collection does
+// not exist.
+func (s *Store) RemoveBookFromCollection(ctx context.Context, userID, id, bookID primitive.ObjectID, at time.Time) (bool, error) {
+	return s.updateCollection(ctx, userID, id, func(c *models.Collection) {
+		c.BookIDs = slices.DeleteFunc(c.BookIDs, func(b primitive.ObjectID) bool { return b == bookID })
+		c.UpdatedAt = at
+	})
+}
+
+func (s *Store) updateCollection(ctx context.Context, userID, id primitive.ObjectID, fn func(*models.Collection)) (bool, error) {
+	ctx, cancel := opCtx(ctx)
+	defer cancel()
+	if existing, err := s.Collection(ctx, userID, id); err != nil || existing == nil {
+		return false, err
+	}
+	return updateDoc(ctx, s, collCollections, id, fn)
+}
+
+// RemoveBookFromCollections takes a deleted book off every collection.
+func (s *Store) RemoveBookFromCollections(ctx context.Context, bookID primitive.ObjectID) error {
+	ctx, cancel := opCtx(ctx)
+	defer cancel()
+	collections, err := findAll(ctx, s, collCollections, func(c *models.Collection) bool { return slices.Contains(c.BookIDs, bookID) })
+	if err != nil {
+		return err
+	}
+	for _, c := range collections {
+		if _, err := updateDoc(ctx, s, collCollections, c.ID, func(c *models.Collection) {
+			c.BookIDs = slices.DeleteFunc(c.BookIDs, func(b primitive.ObjectID) bool { return b == bookID })
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteCollection removes one of the user's collections. Returns false if it does not exist.
+func (s *Store) DeleteCollection(ctx context.Context, userID, id primitive.ObjectID) (bool, error) {
+	ctx, cancel := opCtx(ctx)
+	defer cancel()
+	if existing, err := s.Collection(ctx, userID, id); err != nil || existing == nil {
+		return false, err
+	}
+	if _, err := s.engine.Delete(ctx, collCollections, id.Hex()); err != nil {
+		if isNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
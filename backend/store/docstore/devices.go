@@ -0,0 +1,74 @@
+package docstore
+
+import (
+	"context"
+	"time"
+
+	"github.com/kevinaaaquil/books/backend/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func (s *Store) InsertDevice(ctx context.Context, d *models.Device) (primitive.ObjectID, error) {
+	ctx, cancel := opCtx(ctx)
+	defer cancel()
+	c := *d
+	if c.ID.IsZero() {
+		c.ID = primitive.NewObjectID()
+	}
+	if err := insertDoc(ctx, s, collDevices, c.ID, &c); err != nil {
+		return primitive.NilObjectID, err
+	}
+	return c.ID, nil
+}
+
+// DevicesForUser returns the user's devices, oldest first.
+func (s *Store) DevicesForUser(ctx context.Context, userID primitive.ObjectID) ([]models.Device, error) {
+	ctx, cancel := opCtx(ctx)
+	defer cancel()
+	devices, err := findAll(ctx, s, collDevices, func(d *models.Device) bool { return d.UserID == userID })
+	if err != nil {
+		return nil, err
+	}
+	byTime(devices, false, func(d *models.Device) time.Time { return d.CreatedAt })
+	return devices, nil
+}
+
+// Device returns one of the user's devices, or nil if it does not exist or belongs to someone else.
+func (s *Store) Device(ctx context.Context, userID, id primitive.ObjectID) (*models.Device, error) {
+	ctx, cancel := opCtx(ctx)
+	defer cancel()
+	d, err := getDoc[models.Device](ctx, s, collDevices, id)
+	if isNotFound(err) || (err == nil && d.UserID != userID) {
+		return nil, nil
+	}
+	return d, err
+}
+
+// UpdateDevice replaces the profile of one of d.UserID's devices. Returns false if it does not exist.
+func (s *Store) UpdateDevice(ctx context.Context, d *models.Device) (bool, error) {
+	ctx, cancel := opCtx(ctx)
+	defer cancel()
+	if existing, err := s.Device(ctx, d.UserID, d.ID); err != nil || existing == nil {
+		return false, err
+	}
+	return updateDoc(ctx, s, collDevices, d.ID, func(stored *models.Device) {
+		stored.Name, stored.Type, stored.Formats = d.Name, d.Type, d.Formats
+		stored.MaxAttachmentMB, stored.TargetID, stored.Optimize = d.MaxAttachmentMB, d.TargetID, d.Optimize
+	})
+}
+
+// DeleteDevice removes one of the user's devices. Returns false if it does not exist.
+func (s *Store) DeleteDevice(ctx context.Context, userID, id primitive.ObjectID) (bool, error) {
+	ctx, cancel := opCtx(ctx)
+	defer cancel()
+	if existing, err := s.Device(ctx, userID, id); err != nil || existing == nil {
+		return false, err
+	}
+	if _, err := s.engine.Delete(ctx, collDevices, id.Hex()); err != nil {
+		if isNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
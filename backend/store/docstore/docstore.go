@@ -0,0 +1,262 @@
+// Package docstore implements store.Store on top of a minimal document Engine (Postgres, SQLite or memory).
+// Documents keep the MongoDB layout (same field names, ObjectIDs and dates, as relaxed Extended JSON),
+// so backups and exports look the same whichever backend wrote them. Lookups on hot paths (users by email, books, API keys and
+// refresh tokens by hash, keys and tokens by owner or sign-in, Telegram links by chat or user) go through
+// Engine.Find and indexed fields, and the SQL engines page books in the database; other queries load a collection
+// and filter in Go, which is fine for a personal library but not meant for millions of books.
+package docstore
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"github.com/kevinaaaquil/books/backend/store"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Collection names, matching the MongoDB collections.
+const (
+	collUsers           = "users"
+	collBooks           = "books"
+	collEmailConfig     = "kindle_config"
+	collEmailLogs       = "email_logs"
+	collJobRuns         = "job_runs"
+	collNotifications   = "notifications"
+	collBackups         = "backups"
+	collSystemEmails    = "system_emails"
+	collTargets         = "delivery_targets"
+	collDevices         = "devices"
+	collCollections     = "collections"
+	collAudioTracks     = "audio_tracks"
+	collEmbeddings      = "book_embeddings"
+	collProgress        = "reading_progress"
+	collLocks           = "locks"
+	collSettings        = "settings"
+	collDownloadLinks   = "download_links"
+	collImportSources   = "import_sources"
+	collTelegramChats   = "telegram_chats"
+	collAPIKeys         = "api_keys"
+	collWishlist        = "wishlist"
+	collRecommendations = "recommendations"
+	collNewReleases     = "new_releases"
+	collPriceHistory    = "price_history"
+	collPurchases       = "purchases"
+	collSyncPeers       = "sync_peers"
+	collRefreshTokens   = "refresh_tokens"
+	collJobQueue        = "job_queue"
+	collIPBlocks        = "ip_blocks"
+)
+
+// collections lists every collection an Engine must provide.
+var collections = []string{collUsers, collBooks, collEmailConfig, collEmailLogs, collJobRuns, collNotifications, collBackups, collSystemEmails, collTargets, collDevices, collCollections, collAudioTracks, collEmbeddings, collProgress, collLocks, collSettings, collDownloadLinks, collImportSources, collTelegramChats, collAPIKeys, collWishlist, collRecommendations, collNewReleases, collPriceHistory, collPurchases, collSyncPeers, collRefreshTokens, collJobQueue, collIPBlocks}
+
+// ErrDuplicate is returned by Engine.Insert when a document with the same ID exists.
+var ErrDuplicate = errors.New("docstore: duplicate id")
+
+func isNotFound(err error) bool { return errors.Is(err, store.ErrNotFound) }
+
+func isDuplicate(err error) bool { return errors.Is(err, ErrDuplicate) }
+
+// opTimeout bounds each store operation, like MONGODB_OP_TIMEOUT does for MongoDB.
+const opTimeout = 10 * time.Second
+
+// Engine stores JSON documents by collection and ID. Implementations must make Update atomic per document.
+type Engine interface {
+	// Get returns the document, or store.ErrNotFound.
+	Get(ctx context.Context, collection, id string) ([]byte, error)
+	// Insert adds a new document; it fails if the ID already exists.
+	Insert(ctx context.Context, collection, id string, doc []byte) error
+	// Update replaces the document with fn's result, or returns store.ErrNotFound.
+	Update(ctx context.Context, collection, id string, fn func(doc []byte) ([]byte, error)) error
+	// Delete removes the document and returns it, or returns store.ErrNotFound.
+	Delete(ctx context.Context, collection, id string) ([]byte, error)
+	// All returns every document in the collection, in no particular order.
+	All(ctx context.Context, collection string) ([][]byte, error)
+	// Find returns the documents whose field at path (dot-separated, e.g. keyHash or userId.$oid for an ObjectID)
+	// equals value, a string or an int64, in no particular order. Paths queried often should have an index in the
+	// migrations.
+	Find(ctx context.Context, collection, path string, value any) ([][]byte, error)
+	Ping(ctx context.Context) error
+	Close() error
+}
+
+// Store implements store.Store over an Engine.
+type Store struct {
+	engine  Engine
+	healthy atomic.Bool
+}
+
+var _ store.Store = (*Store)(nil)
+
+// New wraps an Engine that has already been opened and migrated.
+func New(engine Engine) *Store {
+	s := &Store{engine: engine}
+	s.healthy.Store(true)
+	return s
+}
+
+func opCtx(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, opTimeout)
+}
+
+// encode and decode convert between models and stored documents (relaxed Extended JSON, honouring bson tags).
+func encode(v interface{}) ([]byte, error) {
+	return bson.MarshalExtJSON(v, false, false)
+}
+
+func decode(doc []byte, v interface{}) error {
+	return bson.UnmarshalExtJSON(doc, false, v)
+}
+
+// getDoc loads one document into a new T, returning store.ErrNotFound if it does not exist.
+func getDoc[T any](ctx context.Context, s *Store, collection string, id primitive.ObjectID) (*T, error) {
+	doc, err := s.engine.Get(ctx, collection, id.Hex())
+	if err != nil {
+		return nil, err
+	}
+	var v T
+	if err := decode(doc, &v); err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+// findBy loads the documents of the collection whose field at path equals value (see Engine.Find).
+func findBy[T any](ctx context.Context, s *Store, collection, path string, value any) ([]T, error) {
+	docs, err := s.engine.Find(ctx, collection, path, value)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]T, 0, len(docs))
+	for _, doc := range docs {
+		var v T
+		if err := decode(doc, &v); err != nil {
+			return nil, err
+		}
+		out = append(out, v)
+	}
+	return out, nil
+}
+
+// findAll loads every document of the collection for which keep returns true (all when keep is nil).
+func findAll[T any](ctx context.Context, s *Store, collection string, keep func(*T) bool) ([]T, error) {
+	docs, err := s.engine.All(ctx, collection)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]T, 0, len(docs))
+	for _, doc := range docs {
+		var v T
+		if err := decode(doc, &v); err != nil {
+			return nil, err
+		}
+		if keep == nil || keep(&v) {
+			out = append(out, v)
+		}
+	}
+	return out, nil
+}
+
+// insertDoc stores v under id.
+func insertDoc(ctx context.Context, s *Store, collection string, id primitive.ObjectID, v interface{}) error {
+	doc, err := encode(v)
+	if err != nil {
+		return err
+	}
+	return s.engine.Insert(ctx, collection, id.Hex(), doc)
+}
+
+// updateDoc applies fn to the stored document atomically. Like Mongo's UpdateOne, a missing document is not an
+// error; found reports whether it existed.
+func updateDoc[T any](ctx context.Context, s *Store, collection string, id primitive.ObjectID, fn func(*T)) (found bool, err error) {
+	err = s.engine.Update(ctx, collection, id.Hex(), func(doc []byte) ([]byte, error) {
+		var v T
+		if err := decode(doc, &v); err != nil {
+			return nil, err
+		}
+		fn(&v)
+		return encode(&v)
+	})
+	if isNotFound(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// byTime sorts items by the time key returns, newest first when desc.
+func byTime[T any](items []T, desc bool, key func(*T) time.Time) {
+	sort.SliceStable(items, func(i, j int) bool {
+		if desc {
+			return key(&items[i]).After(key(&items[j]))
+		}
+		return key(&items[i]).Before(key(&items[j]))
+	})
+}
+
+// Healthy reports whether the last health check reached the database.
+func (s *Store) Healthy() bool {
+	return s.healthy.Load()
+}
+
+// MonitorHealth pings the database every interval until ctx is cancelled, updating Healthy and logging transitions.
+func (s *Store) MonitorHealth(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		pingCtx, cancel := opCtx(ctx)
+		err := s.engine.Ping(pingCtx)
+		cancel()
+		if ctx.Err() != nil {
+			return
+		}
+		healthy := err == nil
+		if s.healthy.Swap(healthy) != healthy {
+			if healthy {
+				log.Println("database: reachable again")
+			} else {
+				log.Printf("database: unreachable: %v", err)
+			}
+		}
+	}
+}
+
+func (s *Store) Disconnect(ctx context.Context) error {
+	return s.engine.Close()
+}
+
+// CollectionNames lists the collections backed up by the backup job.
+func (s *Store) CollectionNames(ctx context.Context) ([]string, error) {
+	return append([]string(nil), collections...), nil
+}
+
+// ForEachDocument calls fn with every document in the collection converted to BSON, stopping at the first error.
+func (s *Store) ForEachDocument(ctx context.Context, collection string, fn func(doc bson.Raw) error) error {
+	docs, err := s.engine.All(ctx, collection)
+	if err != nil {
+		return err
+	}
+	for _, doc := range docs {
+		var d bson.D
+		if err := decode(doc, &d); err != nil {
+			return err
+		}
+		raw, err := bson.Marshal(d)
+		if err != nil {
+			return err
+		}
+		if err := fn(raw); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package docstore
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/kevinaaaquil/books/backend/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// InsertDownloadLink records an issued download URL.
+func (s *Store) InsertDownloadLink(ctx context.Context, l *models.DownloadLink) error {
+	ctx, cancel := opCtx(ctx)
+	defer cancel()
+	if l.ID.IsZero() {
+		l.ID = primitive.NewObjectID()
+	}
+	return insertDoc(ctx, s, collDownloadLinks, l.ID, l)
+}
+
+// RecentDownloadLinks returns the most recently issued download URLs, newest first; only bookID's when it is set.
+func (s *Store) RecentDownloadLinks(ctx context.Context, bookID primitive.ObjectID, limit int64) ([]models.DownloadLink, error) {
+	ctx, cancel := opCtx(ctx)
+	defer cancel()
+	links, err := findAll(ctx, s, collDownloadLinks, func(l *models.DownloadLink) bool {
+		return bookID.IsZero() || l.BookID == bookID
+	})
+	if err != nil {
+		return nil, err
+	}
+	byTime(links, true, func(l *models.DownloadLink) time.Time { return l.IssuedAt })
+	if limit > 0 && int64(len(links)) > limit {
+		links = links[:limit]
+	}
+	return links, nil
+}
+
+// PresignedDownloadsByMonth sums the bytes of presigned links issued since since, by month (UTC), oldest first.
+func (s *Store) PresignedDownloadsByMonth(ctx context.Context, since time.Time) ([]models.DownloadMonth, error) {
+	ctx, cancel := opCtx(ctx)
+	defer cancel()
+	links, err := findAll(ctx, s, collDownloadLinks, func(l *models.DownloadLink) bool {
+		return l.Kind == models.DownloadLinkPresigned && !l.IssuedAt.Before(since)
+	})
+	if err != nil {
+		return nil, err
+	}
+	byMonth := map[string]*models.DownloadMonth{}
+	months := []models.DownloadMonth{}
+	for _, l := range links {
+		key := l.IssuedAt.UTC().Format("2006-01")
+		m, ok := byMonth[key]
+		if !ok {
+			m = &models.DownloadMonth{Month: key}
+			byMonth[key] = m
+		}
+		m.Bytes += l.Bytes
+		m.Links++
+	}
+	for _, m := range byMonth {
+		months = append(months, *m)
+	}
+	sort.Slice(months, func(i, j int) bool { return months[i].Month < months[j].Month })
+	return months, nil
+}
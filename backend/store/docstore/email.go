@@ -0,0 +1,118 @@
+package docstore
+
+import (
+	"context"
+	"time"
+
+	"github.com/kevinaaaquil/books/backend/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// EnsureEmailConfigIndex is a no-op: new configs are stored under the user's ID, so each user has at most one.
+func (s *Store) EnsureEmailConfigIndex(ctx context.Context) error {
+	return nil
+}
+
+// GetEmailConfig returns the Kindle/email config for the given user, or nil if none exists.
+func (s *Store) GetEmailConfig(ctx context.Context, userID primitive.ObjectID) (*models.EmailConfig, error) {
+	ctx, cancel := opCtx(ctx)
+	defer cancel()
+	cfg, err := getDoc[models.EmailConfig](ctx, s, collEmailConfig, userID)
+	if !isNotFound(err) {
+		return cfg, err
+	}
+	// Configs imported from MongoDB keep their own _id.
+	configs, err := findAll(ctx, s, collEmailConfig, func(c *models.EmailConfig) bool { return c.UserID == userID })
+	if err != nil || len(configs) == 0 {
+		return nil, err
+	}
+	return &configs[0], nil
+}
+
+// UpsertEmailConfig creates or updates the Kindle/email config for the given user.
+func (s *Store) UpsertEmailConfig(ctx context.Context, userID primitive.ObjectID, cfg *models.EmailConfig) error {
+	existing, err := s.GetEmailConfig(ctx, userID)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := opCtx(ctx)
+	defer cancel()
+	set := func(c *models.EmailConfig) {
+		c.UserID = userID
+		c.AppSpecificPassword = cfg.AppSpecificPassword
+		c.ICloudMail = cfg.ICloudMail
+		c.SenderMail = cfg.SenderMail
+		c.KindleMail = cfg.KindleMail
+		c.KindleVerification = cfg.KindleVerification
+	}
+	if existing != nil {
+		_, err := updateDoc(ctx, s, collEmailConfig, existing.ID, set)
+		return err
+	}
+	c := models.EmailConfig{ID: userID}
+	set(&c)
+	err = insertDoc(ctx, s, collEmailConfig, c.ID, &c)
+	if err != nil && isDuplicate(err) {
+		// Lost a race with a concurrent upsert for the same user; apply ours on top.
+		_, err = updateDoc(ctx, s, collEmailConfig, c.ID, set)
+	}
+	return err
+}
+
+// InsertEmailLog records that a book was sent to an email by a user.
+func (s *Store) InsertEmailLog(ctx context.Context, log *models.EmailLog) error {
+	ctx, cancel := opCtx(ctx)
+	defer cancel()
+	l := *log
+	if l.ID.IsZero() {
+		l.ID = primitive.NewObjectID()
+	}
+	return insertDoc(ctx, s, collEmailLogs, l.ID, &l)
+}
+
+// EmailLogsSince returns sends at or after since, oldest first.
+func (s *Store) EmailLogsSince(ctx context.Context, since time.Time) ([]models.EmailLog, error) {
+	return s.findEmailLogs(ctx, func(l *models.EmailLog) bool { return !l.SentAt.Before(since) })
+}
+
+// UserEmailLogsSince returns the user's sends at or after since, oldest first.
+func (s *Store) UserEmailLogsSince(ctx context.Context, userID primitive.ObjectID, since time.Time) ([]models.EmailLog, error) {
+	return s.findEmailLogs(ctx, func(l *models.EmailLog) bool { return l.UserID == userID && !l.SentAt.Before(since) })
+}
+
+func (s *Store) findEmailLogs(ctx context.Context, keep func(*models.EmailLog) bool) ([]models.EmailLog, error) {
+	ctx, cancel := opCtx(ctx)
+	defer cancel()
+	logs, err := findAll(ctx, s, collEmailLogs, keep)
+	if err != nil {
+		return nil, err
+	}
+	byTime(logs, false, func(l *models.EmailLog) time.Time { return l.SentAt })
+	return logs, nil
+}
+
+// InsertSystemEmail records an invite, password reset or notification email and whether it was sent.
+func (s *Store) InsertSystemEmail(ctx context.Context, e *models.SystemEmail) error {
+	ctx, cancel := opCtx(ctx)
+	defer cancel()
+	c := *e
+	if c.ID.IsZero() {
+		c.ID = primitive.NewObjectID()
+	}
+	return insertDoc(ctx, s, collSystemEmails, c.ID, &c)
+}
+
+// RecentSystemEmails returns the most recent system emails, newest first.
+func (s *Store) RecentSystemEmails(ctx context.Context, limit int64) ([]models.SystemEmail, error) {
+	ctx, cancel := opCtx(ctx)
+	defer cancel()
+	emails, err := findAll[models.SystemEmail](ctx, s, collSystemEmails, nil)
+	if err != nil {
+		return nil, err
+	}
+	byTime(emails, true, func(e *models.SystemEmail) time.Time { return e.SentAt })
+	if limit > 0 && int64(len(emails)) > limit {
+		emails = emails[:limit]
+	}
+	return emails, nil
+}
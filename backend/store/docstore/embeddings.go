@@ -0,0 +1,67 @@
+package docstore
+
+import (
+	"context"
+	"time"
+
+	"github.com/kevinaaaquil/books/backend/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// UpsertBookEmbedding replaces e.BookID's embedding, and sets e.ID.
+func (s *Store) UpsertBookEmbedding(ctx context.Context, e *models.BookEmbedding) error {
+	existing, err := s.BookEmbedding(ctx, e.BookID)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := opCtx(ctx)
+	defer cancel()
+	doc := *e
+	if existing != nil {
+		doc.ID = existing.ID
+		if _, err := updateDoc(ctx, s, collEmbeddings, doc.ID, func(stored *models.BookEmbedding) { *stored = doc }); err != nil {
+			return err
+		}
+	} else {
+		doc.ID = primitive.NewObjectID()
+		if err := insertDoc(ctx, s, collEmbeddings, doc.ID, &doc); err != nil {
+			return err
+		}
+	}
+	e.ID = doc.ID
+	return nil
+}
+
+// BookEmbedding returns the book's embedding, or nil if it has none.
+func (s *Store) BookEmbedding(ctx context.Context, bookID primitive.ObjectID) (*models.BookEmbedding, error) {
+	ctx, cancel := opCtx(ctx)
+	defer cancel()
+	found, err := findAll(ctx, s, collEmbeddings, func(e *models.BookEmbedding) bool { return e.BookID == bookID })
+	if err != nil || len(found) == 0 {
+		return nil, err
+	}
+	return &found[0], nil
+}
+
+// BookEmbeddingsSince returns the embeddings updated at or after since; every one for the zero time.
+func (s *Store) BookEmbeddingsSince(ctx context.Context, since time.Time) ([]models.BookEmbedding, error) {
+	ctx, cancel := opCtx(ctx)
+	defer cancel()
+	return findAll(ctx, s, collEmbeddings, func(e *models.BookEmbedding) bool { return !e.UpdatedAt.Before(since) })
+}
+
+// DeleteBookEmbedding removes a deleted book's embedding.
+func (s *Store) DeleteBookEmbedding(ctx context.Context, bookID primitive.ObjectID) error {
+	ctx, cancel := opCtx(ctx)
+	defer cancel()
+	found, err := findAll(ctx, s, collEmbeddings, func(e *models.BookEmbedding) bool { return e.BookID == bookID })
+	if err != nil {
+		return err
+	}
+	for _, e := range found {
+		if _, err := s.engine.Delete(ctx, collEmbeddings, e.ID.Hex()); err != nil && !isNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
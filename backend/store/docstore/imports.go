@@ -0,0 +1,76 @@
+package docstore
+
+import (
+	"context"
+	"time"
+
+	"github.com/kevinaaaquil/books/backend/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func (s *Store) InsertImportSource(ctx context.Context, src *models.ImportSource) (primitive.ObjectID, error) {
+	ctx, cancel := opCtx(ctx)
+	defer cancel()
+	if src.ID.IsZero() {
+		src.ID = primitive.NewObjectID()
+	}
+	if err := insertDoc(ctx, s, collImportSources, src.ID, src); err != nil {
+		return primitive.NilObjectID, err
+	}
+	return src.ID, nil
+}
+
+// ImportSources returns every import source, oldest first.
+func (s *Store) ImportSources(ctx context.Context) ([]models.ImportSource, error) {
+	ctx, cancel := opCtx(ctx)
+	defer cancel()
+	sources, err := findAll(ctx, s, collImportSources, func(*models.ImportSource) bool { return true })
+	if err != nil {
+		return nil, err
+	}
+	byTime(sources, false, func(src *models.ImportSource) time.Time { return src.CreatedAt })
+	return sources, nil
+}
+
+// ImportSource returns a source, or nil if it does not exist.
+func (s *Store) ImportSource(ctx context.Context, id primitive.ObjectID) (*models.ImportSource, error) {
+	ctx, cancel := opCtx(ctx)
+	defer cancel()
+	src, err := getDoc[models.ImportSource](ctx, s, collImportSources, id)
+	if isNotFound(err) {
+		return nil, nil
+	}
+	return src, err
+}
+
+// UpdateImportSource sets a source's Name, Folder and AutoSync. Returns false if it does not exist.
+func (s *Store) UpdateImportSource(ctx context.Context, src *models.ImportSource) (bool, error) {
+	ctx, cancel := opCtx(ctx)
+	defer cancel()
+	return updateDoc(ctx, s, collImportSources, src.ID, func(stored *models.ImportSource) {
+		stored.Name, stored.Folder, stored.AutoSync = src.Name, src.Folder, src.AutoSync
+	})
+}
+
+// SaveImportProgress records the files a sync has dealt with and its outcome. A deleted source stays deleted.
+func (s *Store) SaveImportProgress(ctx context.Context, id primitive.ObjectID, files []models.ImportedFile, last *models.ImportSync) error {
+	ctx, cancel := opCtx(ctx)
+	defer cancel()
+	_, err := updateDoc(ctx, s, collImportSources, id, func(stored *models.ImportSource) {
+		stored.Files, stored.LastSync = files, last
+	})
+	return err
+}
+
+// DeleteImportSource removes a source. Returns false if it does not exist.
+func (s *Store) DeleteImportSource(ctx context.Context, id primitive.ObjectID) (bool, error) {
+	ctx, cancel := opCtx(ctx)
+	defer cancel()
+	if _, err := s.engine.Delete(ctx, collImportSources, id.Hex()); err != nil {
+		if isNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
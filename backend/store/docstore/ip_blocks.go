@@ -0,0 +1,70 @@
+package docstore
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/kevinaaaquil/books/backend/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func (s *Store) InsertIPBlock(ctx context.Context, b *models.IPBlock) (primitive.ObjectID, error) {
+	ctx, cancel := opCtx(ctx)
+	defer cancel()
+	if b.ID.IsZero() {
+		b.ID = primitive.NewObjectID()
+	}
+	if err := insertDoc(ctx, s, collIPBlocks, b.ID, b); err != nil {
+		return primitive.NilObjectID, err
+	}
+	return b.ID, nil
+}
+
+// IPBlocks returns the blocks in force at now, oldest first.
+func (s *Store) IPBlocks(ctx context.Context, now time.Time) ([]models.IPBlock, error) {
+	ctx, cancel := opCtx(ctx)
+	defer cancel()
+	blocks, err := findAll(ctx, s, collIPBlocks, func(b *models.IPBlock) bool {
+		return b.ExpiresAt == nil || b.ExpiresAt.After(now)
+	})
+	if err != nil {
+		return nil, err
+	}
+	// Blocks made in the same instant keep the order of their IDs, as in MongoDB.
+	slices.SortFunc(blocks, func(a, b models.IPBlock) int { return strings.Compare(a.ID.Hex(), b.ID.Hex()) })
+	byTime(blocks, false, func(b *models.IPBlock) time.Time { return b.CreatedAt })
+	return blocks, nil
+}
+
+// DeleteIPBlock removes a block. Returns false if it does not exist.
+func (s *Store) DeleteIPBlock(ctx context.Context, id primitive.ObjectID) (bool, error) {
+	ctx, cancel := opCtx(ctx)
+	defer cancel()
+	if _, err := s.engine.Delete(ctx, collIPBlocks, id.Hex()); err != nil {
+		if isNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// DeleteExpiredIPBlocks removes the blocks that expired before before.
+func (s *Store) DeleteExpiredIPBlocks(ctx context.Context, before time.Time) error {
+	ctx, cancel := opCtx(ctx)
+	defer cancel()
+	blocks, err := findAll(ctx, s, collIPBlocks, func(b *models.IPBlock) bool {
+		return b.ExpiresAt != nil && b.ExpiresAt.Before(before)
+	})
+	if err != nil {
+		return err
+	}
+	for _, b := range blocks {
+		if _, err := s.engine.Delete(ctx, collIPBlocks, b.ID.Hex()); err != nil && !isNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
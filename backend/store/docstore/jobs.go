@@ -0,0 +1,98 @@
+package docstore
+
+import (
+	"context"
+	"time"
+
+	"github.com/kevinaaaquil/books/backend/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func (s *Store) InsertJobRun(ctx context.Context, run *models.JobRun) (primitive.ObjectID, error) {
+	ctx, cancel := opCtx(ctx)
+	defer cancel()
+	r := *run
+	if r.ID.IsZero() {
+		r.ID = primitive.NewObjectID()
+	}
+	if err := insertDoc(ctx, s, collJobRuns, r.ID, &r); err != nil {
+		return primitive.NilObjectID, err
+	}
+	return r.ID, nil
+}
+
+// UpdateJobRun saves the run's status, progress counters, error and finish time.
+func (s *Store) UpdateJobRun(ctx context.Context, run *models.JobRun) error {
+	ctx, cancel := opCtx(ctx)
+	defer cancel()
+	_, err := updateDoc(ctx, s, collJobRuns, run.ID, func(r *models.JobRun) {
+		r.Status = run.Status
+		r.Total = run.Total
+		r.Processed = run.Processed
+		r.Summary = run.Summary
+		r.Error = run.Error
+		r.FinishedAt = run.FinishedAt
+		r.Checkpoint = run.Checkpoint
+		r.Log = run.Log
+	})
+	return err
+}
+
+// JobRunByID returns the job run with the given ID, or nil if none exists.
+func (s *Store) JobRunByID(ctx context.Context, id primitive.ObjectID) (*models.JobRun, error) {
+	ctx, cancel := opCtx(ctx)
+	defer cancel()
+	run, err := getDoc[models.JobRun](ctx, s, collJobRuns, id)
+	if isNotFound(err) {
+		return nil, nil
+	}
+	return run, err
+}
+
+// LatestJobRun returns the most recent run of the given job type, or nil if it never ran.
+func (s *Store) LatestJobRun(ctx context.Context, jobType string) (*models.JobRun, error) {
+	ctx, cancel := opCtx(ctx)
+	defer cancel()
+	runs, err := findAll(ctx, s, collJobRuns, func(r *models.JobRun) bool { return r.Type == jobType })
+	if err != nil || len(runs) == 0 {
+		return nil, err
+	}
+	byTime(runs, true, func(r *models.JobRun) time.Time { return r.StartedAt })
+	return &runs[0], nil
+}
+
+// ListJobRuns returns the runs matching f, newest first.
+func (s *Store) ListJobRuns(ctx context.Context, f models.JobRunFilter) ([]models.JobRun, error) {
+	ctx, cancel := opCtx(ctx)
+	defer cancel()
+	runs, err := findAll(ctx, s, collJobRuns, func(r *models.JobRun) bool {
+		return (f.Type == "" || r.Type == f.Type) && (f.Status == "" || r.Status == f.Status) &&
+			(f.Before == nil || r.StartedAt.Before(*f.Before)) && (f.Since == nil || !r.StartedAt.Before(*f.Since))
+	})
+	if err != nil {
+		return nil, err
+	}
+	byTime(runs, true, func(r *models.JobRun) time.Time { return r.StartedAt })
+	limit := f.Limit
+	if limit <= 0 {
+		if f.Since != nil {
+			return runs, nil
+		}
+		limit = 50
+	}
+	return runs[:min(limit, len(runs))], nil
+}
+
+// RequestJobCancel marks a running run as cancelled by an admin, for the instance running it to stop. It returns
+// false when the run does not exist or is not running.
+func (s *Store) RequestJobCancel(ctx context.Context, id primitive.ObjectID) (bool, error) {
+	ctx, cancel := opCtx(ctx)
+	defer cancel()
+	running := false
+	_, err := updateDoc(ctx, s, collJobRuns, id, func(r *models.JobRun) {
+		if running = r.Status == models.JobStatusRunning; running {
+			r.CancelRequested = true
+		}
+	})
+	return running, err
+}
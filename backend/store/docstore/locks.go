@@ -0,0 +1,66 @@
+package docstore
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/kevinaaaquil/books/backend/models"
+)
+
+var errLockHeld = errors.New("lock held")
+
+// AcquireLock takes or renews the named lease. Locks are stored under their name rather than an ObjectID.
+func (s *Store) AcquireLock(ctx context.Context, name, owner string, now time.Time, ttl time.Duration) (bool, error) {
+	ctx, cancel := opCtx(ctx)
+	defer cancel()
+	lock, err := encode(&models.Lock{Name: name, Owner: owner, ExpiresAt: now.Add(ttl)})
+	if err != nil {
+		return false, err
+	}
+	err = s.engine.Update(ctx, collLocks, name, func(doc []byte) ([]byte, error) {
+		var held models.Lock
+		if err := decode(doc, &held); err != nil {
+			return nil, err
+		}
+		if held.Owner != owner && held.ExpiresAt.After(now) {
+			return nil, errLockHeld
+		}
+		return lock, nil
+	})
+	switch {
+	case err == nil:
+		return true, nil
+	case errors.Is(err, errLockHeld):
+		return false, nil
+	case !isNotFound(err):
+		return false, err
+	}
+	err = s.engine.Insert(ctx, collLocks, name, lock)
+	if isDuplicate(err) {
+		// Another instance took it first.
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// ReleaseLock expires the lease if owner holds it.
+func (s *Store) ReleaseLock(ctx context.Context, name, owner string) error {
+	ctx, cancel := opCtx(ctx)
+	defer cancel()
+	err := s.engine.Update(ctx, collLocks, name, func(doc []byte) ([]byte, error) {
+		var held models.Lock
+		if err := decode(doc, &held); err != nil {
+			return nil, err
+		}
+		if held.Owner != owner {
+			return nil, errLockHeld
+		}
+		held.ExpiresAt = time.Time{}
+		return encode(&held)
+	})
+	if isNotFound(err) || errors.Is(err, errLockHeld) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,138 @@
+package docstore
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/kevinaaaquil/books/backend/store"
+)
+
+// memoryEngine keeps documents in maps. Nothing is persisted; it backs --demo mode and tests.
+type memoryEngine struct {
+	mu          sync.RWMutex
+	collections map[string]map[string][]byte
+}
+
+// NewMemory returns an empty in-memory store.
+func NewMemory() *Store {
+	e := &memoryEngine{collections: map[string]map[string][]byte{}}
+	for _, c := range collections {
+		e.collections[c] = map[string][]byte{}
+	}
+	return New(e)
+}
+
+// docs returns the collection's map, creating it for collections not listed in collections. Callers hold mu.
+func (e *memoryEngine) docs(collection string) map[string][]byte {
+	docs, ok := e.collections[collection]
+	if !ok {
+		docs = map[string][]byte{}
+		e.collections[collection] = docs
+	}
+	return docs
+}
+
+func clone(doc []byte) []byte {
+	return append([]byte(nil), doc...)
+}
+
+func (e *memoryEngine) Get(ctx context.Context, collection, id string) ([]byte, error) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	doc, ok := e.collections[collection][id]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+	return clone(doc), nil
+}
+
+func (e *memoryEngine) Insert(ctx context.Context, collection, id string, doc []byte) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	docs := e.docs(collection)
+	if _, ok := docs[id]; ok {
+		return ErrDuplicate
+	}
+	docs[id] = clone(doc)
+	return nil
+}
+
+func (e *memoryEngine) Update(ctx context.Context, collection, id string, fn func(doc []byte) ([]byte, error)) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	docs := e.docs(collection)
+	doc, ok := docs[id]
+	if !ok {
+		return store.ErrNotFound
+	}
+	doc, err := fn(clone(doc))
+	if err != nil {
+		return err
+	}
+	docs[id] = clone(doc)
+	return nil
+}
+
+func (e *memoryEngine) Delete(ctx context.Context, collection, id string) ([]byte, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	docs := e.docs(collection)
+	doc, ok := docs[id]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+	delete(docs, id)
+	return doc, nil
+}
+
+func (e *memoryEngine) All(ctx context.Context, collection string) ([][]byte, error) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	out := make([][]byte, 0, len(e.collections[collection]))
+	for _, doc := range e.collections[collection] {
+		out = append(out, clone(doc))
+	}
+	return out, nil
+}
+
+func (e *memoryEngine) Find(ctx context.Context, collection, path string, value any) ([][]byte, error) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	var out [][]byte
+	for _, doc := range e.collections[collection] {
+		var v interface{}
+		d := json.NewDecoder(bytes.NewReader(doc))
+		d.UseNumber()
+		if err := d.Decode(&v); err != nil {
+			return nil, err
+		}
+		for _, k := range strings.Split(path, ".") {
+			m, _ := v.(map[string]interface{})
+			v = m[k]
+		}
+		n, isInt := value.(int64)
+		switch v := v.(type) {
+		case string:
+			if v == value {
+				out = append(out, clone(doc))
+			}
+		case json.Number:
+			if isInt && v.String() == strconv.FormatInt(n, 10) {
+				out = append(out, clone(doc))
+			}
+		}
+	}
+	return out, nil
+}
+
+func (e *memoryEngine) Ping(ctx context.Context) error {
+	return nil
+}
+
+func (e *memoryEngine) Close() error {
+	return nil
+}
@@ -0,0 +1,12 @@
+package docstore
+
+import (
+	"testing"
+
+	"github.com/kevinaaaquil/books/backend/store"
+	"github.com/kevinaaaquil/books/backend/store/storetest"
+)
+
+func TestMemoryStore(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) store.Store { return NewMemory() })
+}
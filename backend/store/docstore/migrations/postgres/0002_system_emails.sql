@@ -0,0 +1 @@
+CREATE TABLE system_emails (id TEXT PRIMARY KEY, doc JSONB NOT NULL);
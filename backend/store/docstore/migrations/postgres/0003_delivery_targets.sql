@@ -0,0 +1 @@
+CREATE TABLE delivery_targets (id TEXT PRIMARY KEY, doc JSONB NOT NULL);
@@ -0,0 +1 @@
+CREATE TABLE reading_progress (id TEXT PRIMARY KEY, doc JSONB NOT NULL);
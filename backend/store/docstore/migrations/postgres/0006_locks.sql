@@ -0,0 +1 @@
+CREATE TABLE locks (id TEXT PRIMARY KEY, doc JSONB NOT NULL);
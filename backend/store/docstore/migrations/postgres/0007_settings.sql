@@ -0,0 +1 @@
+CREATE TABLE settings (id TEXT PRIMARY KEY, doc JSONB NOT NULL);
@@ -0,0 +1 @@
+CREATE TABLE telegram_chats (id TEXT PRIMARY KEY, doc JSONB NOT NULL);
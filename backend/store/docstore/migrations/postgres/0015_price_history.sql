@@ -0,0 +1 @@
+CREATE TABLE price_history (id TEXT PRIMARY KEY, doc JSONB NOT NULL);
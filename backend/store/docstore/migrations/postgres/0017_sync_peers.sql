@@ -0,0 +1 @@
+CREATE TABLE sync_peers (id TEXT PRIMARY KEY, doc JSONB NOT NULL);
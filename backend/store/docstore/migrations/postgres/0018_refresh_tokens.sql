@@ -0,0 +1 @@
+CREATE TABLE refresh_tokens (id TEXT PRIMARY KEY, doc JSONB NOT NULL);
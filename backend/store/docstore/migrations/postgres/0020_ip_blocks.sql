@@ -0,0 +1 @@
+CREATE TABLE ip_blocks (id TEXT PRIMARY KEY, doc JSONB NOT NULL);
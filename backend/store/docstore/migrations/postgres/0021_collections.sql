@@ -0,0 +1 @@
+CREATE TABLE collections (id TEXT PRIMARY KEY, doc JSONB NOT NULL);
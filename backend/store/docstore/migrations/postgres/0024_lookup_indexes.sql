@@ -0,0 +1,5 @@
+CREATE INDEX api_keys_key_hash ON api_keys ((doc->>'keyHash'));
+CREATE INDEX api_keys_user_id ON api_keys ((doc->'userId'->>'$oid'));
+CREATE INDEX refresh_tokens_token_hash ON refresh_tokens ((doc->>'tokenHash'));
+CREATE INDEX refresh_tokens_family ON refresh_tokens ((doc->'family'->>'$oid'));
+CREATE INDEX refresh_tokens_user_id ON refresh_tokens ((doc->'userId'->>'$oid'));
@@ -0,0 +1 @@
+CREATE UNIQUE INDEX users_email ON users ((doc->>'email'));
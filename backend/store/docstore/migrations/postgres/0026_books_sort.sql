@@ -0,0 +1,13 @@
+-- BooksPage sorts in the database (see bookSQL): one index per sort order, on the key exactly as the query writes
+-- it and then the ID that breaks ties. docstore_time reads an Extended JSON date; its text always ends in Z, so
+-- the result does not depend on the session's time zone and the function may be declared IMMUTABLE.
+CREATE FUNCTION docstore_time(d JSONB) RETURNS TIMESTAMPTZ LANGUAGE sql IMMUTABLE AS $$
+  SELECT COALESCE(CASE jsonb_typeof(d->'$date')
+    WHEN 'string' THEN (d->>'$date')::timestamptz
+    ELSE to_timestamp((d->'$date'->>'$numberLong')::bigint / 1000.0)
+  END, '-infinity')
+$$;
+CREATE INDEX books_created_at ON books (docstore_time(doc->'createdAt'), id COLLATE "C");
+CREATE INDEX books_title ON books ((lower(COALESCE(doc->>'title', ''))) COLLATE "C", id COLLATE "C");
+CREATE INDEX books_first_author ON books ((lower(COALESCE(doc->'authors'->>0, ''))) COLLATE "C", id COLLATE "C");
+CREATE INDEX books_rating ON books ((COALESCE((doc->>'ratingAverage')::float8, 0)), (COALESCE((doc->>'ratingCount')::bigint, 0)), id COLLATE "C");
@@ -0,0 +1,2 @@
+CREATE INDEX telegram_chats_chat_id ON telegram_chats ((doc->>'chatId'));
+CREATE INDEX telegram_chats_user_id ON telegram_chats ((doc->'userId'->>'$oid'));
@@ -0,0 +1,8 @@
+-- One table per MongoDB collection. doc holds the document as relaxed Extended JSON; id is the ObjectID hex.
+CREATE TABLE users (id TEXT PRIMARY KEY, doc TEXT NOT NULL);
+CREATE TABLE books (id TEXT PRIMARY KEY, doc TEXT NOT NULL);
+CREATE TABLE kindle_config (id TEXT PRIMARY KEY, doc TEXT NOT NULL);
+CREATE TABLE email_logs (id TEXT PRIMARY KEY, doc TEXT NOT NULL);
+CREATE TABLE job_runs (id TEXT PRIMARY KEY, doc TEXT NOT NULL);
+CREATE TABLE notifications (id TEXT PRIMARY KEY, doc TEXT NOT NULL);
+CREATE TABLE backups (id TEXT PRIMARY KEY, doc TEXT NOT NULL);
@@ -0,0 +1 @@
+CREATE TABLE devices (id TEXT PRIMARY KEY, doc TEXT NOT NULL);
@@ -0,0 +1 @@
+CREATE TABLE download_links (id TEXT PRIMARY KEY, doc TEXT NOT NULL);
@@ -0,0 +1 @@
+CREATE TABLE import_sources (id TEXT PRIMARY KEY, doc TEXT NOT NULL);
@@ -0,0 +1 @@
+CREATE TABLE api_keys (id TEXT PRIMARY KEY, doc TEXT NOT NULL);
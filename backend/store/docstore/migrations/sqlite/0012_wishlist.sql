@@ -0,0 +1 @@
+CREATE TABLE wishlist (id TEXT PRIMARY KEY, doc TEXT NOT NULL);
@@ -0,0 +1 @@
+CREATE TABLE recommendations (id TEXT PRIMARY KEY, doc TEXT NOT NULL);
@@ -0,0 +1 @@
+CREATE TABLE new_releases (id TEXT PRIMARY KEY, doc TEXT NOT NULL);
@@ -0,0 +1 @@
+CREATE TABLE purchases (id TEXT PRIMARY KEY, doc TEXT NOT NULL);
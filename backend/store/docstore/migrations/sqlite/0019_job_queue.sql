@@ -0,0 +1 @@
+CREATE TABLE job_queue (id TEXT PRIMARY KEY, doc TEXT NOT NULL);
@@ -0,0 +1 @@
+CREATE TABLE audio_tracks (id TEXT PRIMARY KEY, doc TEXT NOT NULL);
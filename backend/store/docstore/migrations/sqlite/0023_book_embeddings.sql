@@ -0,0 +1 @@
+CREATE TABLE book_embeddings (id TEXT PRIMARY KEY, doc TEXT NOT NULL);
@@ -0,0 +1 @@
+CREATE UNIQUE INDEX users_email ON users (json_extract(doc, '$."email"'));
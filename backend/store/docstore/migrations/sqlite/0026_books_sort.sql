@@ -0,0 +1,6 @@
+-- BooksPage sorts in the database (see bookSQL): one index per sort order, on the key exactly as the query writes
+-- it (SQLite only uses an expression index when the text matches) and then the ID that breaks ties.
+CREATE INDEX books_created_at ON books (COALESCE(json_extract(doc, '$."createdAt"."$date"."$numberLong"') / 1000.0, unixepoch(json_extract(doc, '$."createdAt"."$date"'), 'subsec')), id);
+CREATE INDEX books_title ON books (lower(COALESCE(json_extract(doc, '$."title"'), '')), id);
+CREATE INDEX books_first_author ON books (lower(COALESCE(json_extract(doc, '$."authors"[0]'), '')), id);
+CREATE INDEX books_rating ON books (COALESCE(json_extract(doc, '$."ratingAverage"'), 0), COALESCE(json_extract(doc, '$."ratingCount"'), 0), id);
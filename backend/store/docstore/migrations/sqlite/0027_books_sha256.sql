@@ -0,0 +1 @@
+CREATE INDEX books_sha256 ON books (json_extract(doc, '$."sha256"'));
@@ -0,0 +1,2 @@
+CREATE INDEX telegram_chats_chat_id ON telegram_chats (json_extract(doc, '$."chatId"'));
+CREATE INDEX telegram_chats_user_id ON telegram_chats (json_extract(doc, '$."userId"."$oid"'));
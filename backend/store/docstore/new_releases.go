@@ -0,0 +1,46 @@
+package docstore
+
+import (
+	"context"
+	"sort"
+
+	"github.com/kevinaaaquil/books/backend/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// AddNewRelease stores r unless r.UserID already has a release with r.Key. Returns whether it was added.
+func (s *Store) AddNewRelease(ctx context.Context, r *models.NewRelease) (bool, error) {
+	ctx, cancel := opCtx(ctx)
+	defer cancel()
+	existing, err := findAll(ctx, s, collNewReleases, func(old *models.NewRelease) bool { return old.UserID == r.UserID && old.Key == r.Key })
+	if err != nil || len(existing) > 0 {
+		return false, err
+	}
+	if r.ID.IsZero() {
+		r.ID = primitive.NewObjectID()
+	}
+	if err := insertDoc(ctx, s, collNewReleases, r.ID, r); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// NewReleasesForUser returns the user's most recently found releases, newest first.
+func (s *Store) NewReleasesForUser(ctx context.Context, userID primitive.ObjectID, limit int64) ([]models.NewRelease, error) {
+	ctx, cancel := opCtx(ctx)
+	defer cancel()
+	releases, err := findAll(ctx, s, collNewReleases, func(r *models.NewRelease) bool { return r.UserID == userID })
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(releases, func(i, j int) bool {
+		if !releases[i].FoundAt.Equal(releases[j].FoundAt) {
+			return releases[i].FoundAt.After(releases[j].FoundAt)
+		}
+		return releases[i].PublishDate > releases[j].PublishDate
+	})
+	if limit > 0 && int64(len(releases)) > limit {
+		releases = releases[:limit]
+	}
+	return releases, nil
+}
@@ -0,0 +1,49 @@
+package docstore
+
+import (
+	"context"
+	"time"
+
+	"github.com/kevinaaaquil/books/backend/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func (s *Store) InsertNotification(ctx context.Context, n *models.Notification) error {
+	ctx, cancel := opCtx(ctx)
+	defer cancel()
+	c := *n
+	if c.ID.IsZero() {
+		c.ID = primitive.NewObjectID()
+	}
+	return insertDoc(ctx, s, collNotifications, c.ID, &c)
+}
+
+// NotificationsForUser returns the user's most recent notifications, newest first.
+func (s *Store) NotificationsForUser(ctx context.Context, userID primitive.ObjectID, limit int64) ([]models.Notification, error) {
+	ctx, cancel := opCtx(ctx)
+	defer cancel()
+	notifications, err := findAll(ctx, s, collNotifications, func(n *models.Notification) bool { return n.UserID == userID })
+	if err != nil {
+		return nil, err
+	}
+	byTime(notifications, true, func(n *models.Notification) time.Time { return n.CreatedAt })
+	if limit > 0 && int64(len(notifications)) > limit {
+		notifications = notifications[:limit]
+	}
+	return notifications, nil
+}
+
+// MarkNotificationRead sets readAt on one of the user's notifications. Returns false if it does not exist.
+func (s *Store) MarkNotificationRead(ctx context.Context, userID, id primitive.ObjectID) (bool, error) {
+	ctx, cancel := opCtx(ctx)
+	defer cancel()
+	n, err := getDoc[models.Notification](ctx, s, collNotifications, id)
+	if isNotFound(err) || (err == nil && n.UserID != userID) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	now := time.Now()
+	return updateDoc(ctx, s, collNotifications, id, func(n *models.Notification) { n.ReadAt = &now })
+}
@@ -0,0 +1,228 @@
+package docstore
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/kevinaaaquil/books/backend/models"
+	"github.com/kevinaaaquil/books/backend/store"
+)
+
+//go:embed migrations/postgres/*.sql
+var postgresMigrations embed.FS
+
+// migrationLockID is the pg_advisory_xact_lock key held while migrating, so replicas starting together don't race.
+const migrationLockID = 7420935
+
+type postgresEngine struct {
+	pool *pgxpool.Pool
+}
+
+// OpenPostgres connects to databaseURL (pool settings such as pool_max_conns go in the URL), applies pending
+// migrations and returns the store.
+func OpenPostgres(ctx context.Context, databaseURL string) (*Store, error) {
+	pool, err := pgxpool.New(ctx, databaseURL)
+	if err != nil {
+		return nil, err
+	}
+	if err := pool.Ping(ctx); err != nil {
+		pool.Close()
+		return nil, fmt.Errorf("%w: %v", store.ErrUnavailable, err)
+	}
+	if err := migratePostgres(ctx, pool); err != nil {
+		pool.Close()
+		return nil, fmt.Errorf("migrate: %w", err)
+	}
+	log.Println("Connected to Postgres")
+	return New(&postgresEngine{pool: pool}), nil
+}
+
+// migratePostgres applies migrations/postgres files newer than the recorded schema version, in one transaction.
+func migratePostgres(ctx context.Context, pool *pgxpool.Pool) error {
+	pending, err := migrations(postgresMigrations, "migrations/postgres")
+	if err != nil {
+		return err
+	}
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+	if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock($1)`, migrationLockID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (version INT PRIMARY KEY, applied_at TIMESTAMPTZ NOT NULL DEFAULT now())`); err != nil {
+		return err
+	}
+	var current int
+	if err := tx.QueryRow(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&current); err != nil {
+		return err
+	}
+	for _, m := range pending {
+		if m.version <= current {
+			continue
+		}
+		if _, err := tx.Exec(ctx, m.sql); err != nil {
+			return fmt.Errorf("%s: %w", m.name, err)
+		}
+		if _, err := tx.Exec(ctx, `INSERT INTO schema_migrations (version) VALUES ($1)`, m.version); err != nil {
+			return err
+		}
+		log.Printf("postgres: applied migration %s", m.name)
+	}
+	return tx.Commit(ctx)
+}
+
+func postgresTable(collection string) string {
+	return pgx.Identifier{collection}.Sanitize()
+}
+
+func (e *postgresEngine) Get(ctx context.Context, collection, id string) ([]byte, error) {
+	var doc []byte
+	err := e.pool.QueryRow(ctx, `SELECT doc FROM `+postgresTable(collection)+` WHERE id = $1`, id).Scan(&doc)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, store.ErrNotFound
+	}
+	return doc, err
+}
+
+func (e *postgresEngine) Insert(ctx context.Context, collection, id string, doc []byte) error {
+	_, err := e.pool.Exec(ctx, `INSERT INTO `+postgresTable(collection)+` (id, doc) VALUES ($1, $2)`, id, doc)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		return ErrDuplicate
+	}
+	return err
+}
+
+func (e *postgresEngine) Update(ctx context.Context, collection, id string, fn func(doc []byte) ([]byte, error)) error {
+	tx, err := e.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+	var doc []byte
+	err = tx.QueryRow(ctx, `SELECT doc FROM `+postgresTable(collection)+` WHERE id = $1 FOR UPDATE`, id).Scan(&doc)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return store.ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	doc, err = fn(doc)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, `UPDATE `+postgresTable(collection)+` SET doc = $2 WHERE id = $1`, id, doc); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+func (e *postgresEngine) Delete(ctx context.Context, collection, id string) ([]byte, error) {
+	var doc []byte
+	err := e.pool.QueryRow(ctx, `DELETE FROM `+postgresTable(collection)+` WHERE id = $1 RETURNING doc`, id).Scan(&doc)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, store.ErrNotFound
+	}
+	return doc, err
+}
+
+func (e *postgresEngine) All(ctx context.Context, collection string) ([][]byte, error) {
+	rows, err := e.pool.Query(ctx, `SELECT doc FROM `+postgresTable(collection))
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) ([]byte, error) {
+		var doc []byte
+		err := row.Scan(&doc)
+		return doc, err
+	})
+}
+
+func (e *postgresEngine) Find(ctx context.Context, collection, path string, value any) ([][]byte, error) {
+	if n, ok := value.(int64); ok {
+		value = strconv.FormatInt(n, 10) // ->> reads numbers as text too
+	}
+	rows, err := e.pool.Query(ctx, `SELECT doc FROM `+postgresTable(collection)+` WHERE `+postgresField(path)+` = $1`, value)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) ([]byte, error) {
+		var doc []byte
+		err := row.Scan(&doc)
+		return doc, err
+	})
+}
+
+// postgresField returns the text at path in doc, written with literal keys so that expression indexes such as
+// ((doc->>'keyHash')) match it.
+func postgresField(path string) string {
+	keys := strings.Split(path, ".")
+	expr := "doc"
+	for i, k := range keys {
+		op := "->"
+		if i == len(keys)-1 {
+			op = "->>"
+		}
+		expr += op + "'" + strings.ReplaceAll(k, "'", "''") + "'"
+	}
+	return "(" + expr + ")"
+}
+
+// postgresBooks sorts text with the "C" collation, which compares bytes like Go does whatever the database's
+// locale. docstore_time (migration 0026) reads an Extended JSON date.
+var postgresBooks = bookSQL{
+	title:         `lower(COALESCE(doc->>'title', '')) COLLATE "C"`,
+	firstAuthor:   `lower(COALESCE(doc->'authors'->>0, '')) COLLATE "C"`,
+	ratingAverage: `COALESCE((doc->>'ratingAverage')::float8, 0)`,
+	ratingCount:   `COALESCE((doc->>'ratingCount')::bigint, 0)`,
+	createdAt:     `docstore_time(doc->'createdAt')`,
+	id:            `id COLLATE "C"`,
+	viewByGuest:   `(doc->'viewByGuest') = 'true'`,
+	contentRating: `COALESCE(doc->>'contentRating', '')`,
+	elements: func(path string) string {
+		array := "doc"
+		for _, k := range strings.Split(path, ".") {
+			array += "->" + sqlString(k)
+		}
+		return "jsonb_array_elements_text(CASE jsonb_typeof(" + array + ") WHEN 'array' THEN " + array + " ELSE '[]' END) AS e(value)"
+	},
+	param:   func(n int) string { return "$" + strconv.Itoa(n) },
+	noLimit: "ALL",
+}
+
+func (e *postgresEngine) booksPage(ctx context.Context, q models.BookQuery) ([][]byte, int, error) {
+	where, args, page := postgresBooks.query(q)
+	var total int
+	if err := e.pool.QueryRow(ctx, `SELECT count(*) FROM `+postgresTable(collBooks)+` WHERE `+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+	rows, err := e.pool.Query(ctx, `SELECT doc FROM `+postgresTable(collBooks)+` WHERE `+where+page, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	docs, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) ([]byte, error) {
+		var doc []byte
+		err := row.Scan(&doc)
+		return doc, err
+	})
+	return docs, total, err
+}
+
+func (e *postgresEngine) Ping(ctx context.Context) error {
+	return e.pool.Ping(ctx)
+}
+
+func (e *postgresEngine) Close() error {
+	e.pool.Close()
+	return nil
+}
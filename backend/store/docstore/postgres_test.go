@@ -0,0 +1,57 @@
+package docstore
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/kevinaaaquil/books/backend/store"
+	"github.com/kevinaaaquil/books/backend/store/storetest"
+)
+
+// TestPostgresStore runs the store conformance suite against Postgres. Set TEST_DATABASE_URL to enable it;
+// each subtest migrates (and drops) its own schema.
+func TestPostgresStore(t *testing.T) {
+	databaseURL := os.Getenv("TEST_DATABASE_URL")
+	if databaseURL == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+	storetest.Run(t, func(t *testing.T) store.Store {
+		ctx := context.Background()
+		conn, err := pgx.Connect(ctx, databaseURL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close(ctx)
+		schema := fmt.Sprintf("books_test_%d", time.Now().UnixNano())
+		if _, err := conn.Exec(ctx, "CREATE SCHEMA "+schema); err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() {
+			conn, err := pgx.Connect(ctx, databaseURL)
+			if err != nil {
+				return
+			}
+			defer conn.Close(ctx)
+			conn.Exec(ctx, "DROP SCHEMA "+schema+" CASCADE")
+		})
+
+		u, err := url.Parse(databaseURL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		q := u.Query()
+		q.Set("search_path", schema)
+		u.RawQuery = q.Encode()
+		s, err := OpenPostgres(ctx, u.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { s.Disconnect(ctx) })
+		return s
+	})
+}
@@ -0,0 +1,50 @@
+package docstore
+
+import (
+	"context"
+	"time"
+
+	"github.com/kevinaaaquil/books/backend/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func (s *Store) InsertPricePoint(ctx context.Context, p *models.PricePoint) error {
+	ctx, cancel := opCtx(ctx)
+	defer cancel()
+	c := *p
+	if c.ID.IsZero() {
+		c.ID = primitive.NewObjectID()
+	}
+	return insertDoc(ctx, s, collPriceHistory, c.ID, &c)
+}
+
+// PriceHistory returns the item's most recent prices, newest first.
+func (s *Store) PriceHistory(ctx context.Context, itemID primitive.ObjectID, limit int64) ([]models.PricePoint, error) {
+	ctx, cancel := opCtx(ctx)
+	defer cancel()
+	points, err := findAll(ctx, s, collPriceHistory, func(p *models.PricePoint) bool { return p.ItemID == itemID })
+	if err != nil {
+		return nil, err
+	}
+	byTime(points, true, func(p *models.PricePoint) time.Time { return p.CheckedAt })
+	if limit > 0 && int64(len(points)) > limit {
+		points = points[:limit]
+	}
+	return points, nil
+}
+
+// DeletePriceHistory removes the item's prices.
+func (s *Store) DeletePriceHistory(ctx context.Context, itemID primitive.ObjectID) error {
+	ctx, cancel := opCtx(ctx)
+	defer cancel()
+	points, err := findAll(ctx, s, collPriceHistory, func(p *models.PricePoint) bool { return p.ItemID == itemID })
+	if err != nil {
+		return err
+	}
+	for _, p := range points {
+		if _, err := s.engine.Delete(ctx, collPriceHistory, p.ID.Hex()); err != nil && !isNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
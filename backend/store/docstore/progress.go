@@ -0,0 +1,62 @@
+package docstore
+
+import (
+	"context"
+	"time"
+
+	"github.com/kevinaaaquil/books/backend/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// UpsertReadingProgress replaces p.UserID's progress in p.BookID.
+func (s *Store) UpsertReadingProgress(ctx context.Context, p *models.ReadingProgress) error {
+	existing, err := s.ReadingProgressFor(ctx, p.UserID, p.BookID)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := opCtx(ctx)
+	defer cancel()
+	set := func(stored *models.ReadingProgress) {
+		stored.Anchor, stored.KindleLocation, stored.Percent = p.Anchor, p.KindleLocation, p.Percent
+		stored.Device, stored.UpdatedAt = p.Device, p.UpdatedAt
+	}
+	if existing != nil {
+		_, err := updateDoc(ctx, s, collProgress, existing.ID, set)
+		return err
+	}
+	c := models.ReadingProgress{ID: primitive.NewObjectID(), UserID: p.UserID, BookID: p.BookID}
+	set(&c)
+	return insertDoc(ctx, s, collProgress, c.ID, &c)
+}
+
+// ReadingProgressFor returns the user's progress in the book, or nil if none was recorded.
+func (s *Store) ReadingProgressFor(ctx context.Context, userID, bookID primitive.ObjectID) (*models.ReadingProgress, error) {
+	ctx, cancel := opCtx(ctx)
+	defer cancel()
+	found, err := findAll(ctx, s, collProgress, func(p *models.ReadingProgress) bool {
+		return p.UserID == userID && p.BookID == bookID
+	})
+	if err != nil || len(found) == 0 {
+		return nil, err
+	}
+	return &found[0], nil
+}
+
+// ReadingProgressByUser returns the user's progress in every book they have opened, most recently read first.
+func (s *Store) ReadingProgressByUser(ctx context.Context, userID primitive.ObjectID) ([]models.ReadingProgress, error) {
+	ctx, cancel := opCtx(ctx)
+	defer cancel()
+	all, err := findAll(ctx, s, collProgress, func(p *models.ReadingProgress) bool { return p.UserID == userID })
+	if err != nil {
+		return nil, err
+	}
+	byTime(all, true, func(p *models.ReadingProgress) time.Time { return p.UpdatedAt })
+	return all, nil
+}
+
+// AllReadingProgress returns every user's progress in every book, in no particular order.
+func (s *Store) AllReadingProgress(ctx context.Context) ([]models.ReadingProgress, error) {
+	ctx, cancel := opCtx(ctx)
+	defer cancel()
+	return findAll(ctx, s, collProgress, func(*models.ReadingProgress) bool { return true })
+}
@@ -0,0 +1,63 @@
+package docstore
+
+import (
+	"context"
+
+	"github.com/kevinaaaquil/books/backend/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// BookPurchase returns the book's purchase record, or nil if it has none.
+func (s *Store) BookPurchase(ctx context.Context, bookID primitive.ObjectID) (*models.Purchase, error) {
+	ctx, cancel := opCtx(ctx)
+	defer cancel()
+	p, err := getDoc[models.Purchase](ctx, s, collPurchases, bookID)
+	if isNotFound(err) {
+		return nil, nil
+	}
+	return p, err
+}
+
+// SaveBookPurchase creates or replaces p.BookID's purchase record.
+func (s *Store) SaveBookPurchase(ctx context.Context, p *models.Purchase) error {
+	ctx, cancel := opCtx(ctx)
+	defer cancel()
+	doc, err := encode(p)
+	if err != nil {
+		return err
+	}
+	id := p.BookID.Hex()
+	err = s.engine.Update(ctx, collPurchases, id, func([]byte) ([]byte, error) { return doc, nil })
+	if !isNotFound(err) {
+		return err
+	}
+	err = s.engine.Insert(ctx, collPurchases, id, doc)
+	if isDuplicate(err) {
+		// Saved concurrently; this save wins.
+		return s.engine.Update(ctx, collPurchases, id, func([]byte) ([]byte, error) { return doc, nil })
+	}
+	return err
+}
+
+// DeleteBookPurchase removes the book's purchase record. Returns false if it had none.
+func (s *Store) DeleteBookPurchase(ctx context.Context, bookID primitive.ObjectID) (bool, error) {
+	ctx, cancel := opCtx(ctx)
+	defer cancel()
+	_, err := s.engine.Delete(ctx, collPurchases, bookID.Hex())
+	if isNotFound(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// AllPurchases returns every purchase record, oldest purchase first; records without a date come last.
+func (s *Store) AllPurchases(ctx context.Context) ([]models.Purchase, error) {
+	ctx, cancel := opCtx(ctx)
+	defer cancel()
+	purchases, err := findAll[models.Purchase](ctx, s, collPurchases, nil)
+	if err != nil {
+		return nil, err
+	}
+	models.SortPurchases(purchases)
+	return purchases, nil
+}
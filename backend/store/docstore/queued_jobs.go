@@ -0,0 +1,121 @@
+package docstore
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/kevinaaaquil/books/backend/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func (s *Store) InsertQueuedJob(ctx context.Context, job *models.QueuedJob) error {
+	ctx, cancel := opCtx(ctx)
+	defer cancel()
+	if job.ID.IsZero() {
+		job.ID = primitive.NewObjectID()
+	}
+	return insertDoc(ctx, s, collJobQueue, job.ID, job)
+}
+
+// QueuedJobByID returns the job, or nil if there is none.
+func (s *Store) QueuedJobByID(ctx context.Context, id primitive.ObjectID) (*models.QueuedJob, error) {
+	ctx, cancel := opCtx(ctx)
+	defer cancel()
+	job, err := getDoc[models.QueuedJob](ctx, s, collJobQueue, id)
+	if isNotFound(err) {
+		return nil, nil
+	}
+	return job, err
+}
+
+// claimable reports whether an attempt of job may start at now.
+func claimable(job *models.QueuedJob, now time.Time) bool {
+	switch job.Status {
+	case models.QueuedJobQueued:
+		return !job.RunAt.After(now)
+	case models.QueuedJobRunning:
+		return job.LeaseUntil != nil && job.LeaseUntil.Before(now)
+	}
+	return false
+}
+
+// ClaimQueuedJob starts an attempt of the job due longest: a queued one whose RunAt has come, or a running one
+// whose lease ran out, of one of kinds (nil = any kind). Nil when none is due.
+func (s *Store) ClaimQueuedJob(ctx context.Context, instance string, kinds []string, now, leaseUntil time.Time) (*models.QueuedJob, error) {
+	ctx, cancel := opCtx(ctx)
+	defer cancel()
+	due, err := findAll(ctx, s, collJobQueue, func(job *models.QueuedJob) bool {
+		return claimable(job, now) && (kinds == nil || slices.Contains(kinds, job.Kind))
+	})
+	if err != nil {
+		return nil, err
+	}
+	byTime(due, false, func(job *models.QueuedJob) time.Time { return job.RunAt })
+	for _, candidate := range due {
+		var claimed *models.QueuedJob
+		// Another instance may claim it first; the update checks again.
+		if _, err := updateDoc(ctx, s, collJobQueue, candidate.ID, func(job *models.QueuedJob) {
+			claimed = nil
+			if claimable(job, now) {
+				job.Status, job.Instance, job.LeaseUntil, job.UpdatedAt = models.QueuedJobRunning, instance, &leaseUntil, now
+				job.Attempts++
+				claimed = job
+			}
+		}); err != nil {
+			return nil, err
+		}
+		if claimed != nil {
+			return claimed, nil
+		}
+	}
+	return nil, nil
+}
+
+// UpdateQueuedJob saves the outcome of instance's attempt, unless the job is no longer running there.
+func (s *Store) UpdateQueuedJob(ctx context.Context, job *models.QueuedJob, instance string) (bool, error) {
+	ctx, cancel := opCtx(ctx)
+	defer cancel()
+	saved := false
+	_, err := updateDoc(ctx, s, collJobQueue, job.ID, func(j *models.QueuedJob) {
+		saved = j.Status == models.QueuedJobRunning && j.Instance == instance
+		if !saved {
+			return
+		}
+		j.Status, j.Attempts, j.RunAt, j.LeaseUntil = job.Status, job.Attempts, job.RunAt, job.LeaseUntil
+		j.Error, j.Result, j.UpdatedAt, j.FinishedAt = job.Error, job.Result, job.UpdatedAt, job.FinishedAt
+	})
+	return saved, err
+}
+
+// PendingQueuedJobs returns the user's queued and running jobs of kind, oldest first.
+func (s *Store) PendingQueuedJobs(ctx context.Context, userID primitive.ObjectID, kind string) ([]models.QueuedJob, error) {
+	ctx, cancel := opCtx(ctx)
+	defer cancel()
+	jobs, err := findAll(ctx, s, collJobQueue, func(job *models.QueuedJob) bool {
+		return job.UserID == userID && job.Kind == kind && job.Pending()
+	})
+	if err != nil {
+		return nil, err
+	}
+	byTime(jobs, false, func(job *models.QueuedJob) time.Time { return job.CreatedAt })
+	return jobs, nil
+}
+
+// DeleteFinishedQueuedJobs removes jobs that succeeded or failed before before.
+func (s *Store) DeleteFinishedQueuedJobs(ctx context.Context, before time.Time) error {
+	ctx, cancel := opCtx(ctx)
+	defer cancel()
+	jobs, err := findAll(ctx, s, collJobQueue, func(job *models.QueuedJob) bool {
+		return job.FinishedAt != nil && job.FinishedAt.Before(before)
+	})
+	if err != nil {
+		return err
+	}
+	for _, job := range jobs {
+		if _, err := s.engine.Delete(ctx, collJobQueue, job.ID.Hex()); err != nil && !isNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,37 @@
+package docstore
+
+import (
+	"context"
+
+	"github.com/kevinaaaquil/books/backend/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// SaveRecommendations stores r, replacing r.UserID's previous recommendations.
+func (s *Store) SaveRecommendations(ctx context.Context, r *models.UserRecommendations) error {
+	existing, err := s.RecommendationsFor(ctx, r.UserID)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := opCtx(ctx)
+	defer cancel()
+	set := func(stored *models.UserRecommendations) { stored.Items, stored.ComputedAt = r.Items, r.ComputedAt }
+	if existing != nil {
+		_, err := updateDoc(ctx, s, collRecommendations, existing.ID, set)
+		return err
+	}
+	c := models.UserRecommendations{ID: primitive.NewObjectID(), UserID: r.UserID}
+	set(&c)
+	return insertDoc(ctx, s, collRecommendations, c.ID, &c)
+}
+
+// RecommendationsFor returns the user's recommendations, or nil if none were computed.
+func (s *Store) RecommendationsFor(ctx context.Context, userID primitive.ObjectID) (*models.UserRecommendations, error) {
+	ctx, cancel := opCtx(ctx)
+	defer cancel()
+	found, err := findAll(ctx, s, collRecommendations, func(r *models.UserRecommendations) bool { return r.UserID == userID })
+	if err != nil || len(found) == 0 {
+		return nil, err
+	}
+	return &found[0], nil
+}
@@ -0,0 +1,91 @@
+package docstore
+
+import (
+	"context"
+	"time"
+
+	"github.com/kevinaaaquil/books/backend/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func (s *Store) InsertRefreshToken(ctx context.Context, t *models.RefreshToken) error {
+	ctx, cancel := opCtx(ctx)
+	defer cancel()
+	if t.ID.IsZero() {
+		t.ID = primitive.NewObjectID()
+	}
+	return insertDoc(ctx, s, collRefreshTokens, t.ID, t)
+}
+
+// RefreshTokenByHash returns the token with this hash, or nil if there is none.
+func (s *Store) RefreshTokenByHash(ctx context.Context, tokenHash string) (*models.RefreshToken, error) {
+	ctx, cancel := opCtx(ctx)
+	defer cancel()
+	tokens, err := findBy[models.RefreshToken](ctx, s, collRefreshTokens, "tokenHash", tokenHash)
+	if err != nil || len(tokens) == 0 {
+		return nil, err
+	}
+	return &tokens[0], nil
+}
+
+// UseRefreshToken marks a token used at at, unless it was used or revoked already. Returns whether it did.
+func (s *Store) UseRefreshToken(ctx context.Context, id primitive.ObjectID, at time.Time) (bool, error) {
+	ctx, cancel := opCtx(ctx)
+	defer cancel()
+	used := false
+	_, err := updateDoc(ctx, s, collRefreshTokens, id, func(t *models.RefreshToken) {
+		if t.UsedAt == nil && t.RevokedAt == nil {
+			t.UsedAt, used = &at, true
+		}
+	})
+	return used, err
+}
+
+// RevokeRefreshTokenFamily revokes the tokens of a sign-in that are not revoked yet.
+func (s *Store) RevokeRefreshTokenFamily(ctx context.Context, family primitive.ObjectID, at time.Time) error {
+	return s.revokeRefreshTokens(ctx, at, "family.$oid", family.Hex())
+}
+
+// RevokeUserRefreshTokens revokes every sign-in of the user.
+func (s *Store) RevokeUserRefreshTokens(ctx context.Context, userID primitive.ObjectID, at time.Time) error {
+	return s.revokeRefreshTokens(ctx, at, "userId.$oid", userID.Hex())
+}
+
+// revokeRefreshTokens revokes the tokens whose field at path is value (see Engine.Find).
+func (s *Store) revokeRefreshTokens(ctx context.Context, at time.Time, path string, value any) error {
+	ctx, cancel := opCtx(ctx)
+	defer cancel()
+	tokens, err := findBy[models.RefreshToken](ctx, s, collRefreshTokens, path, value)
+	if err != nil {
+		return err
+	}
+	for _, t := range tokens {
+		if t.RevokedAt != nil {
+			continue
+		}
+		if _, err := updateDoc(ctx, s, collRefreshTokens, t.ID, func(t *models.RefreshToken) {
+			if t.RevokedAt == nil {
+				t.RevokedAt = &at
+			}
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteExpiredRefreshTokens removes the tokens that expired before before.
+func (s *Store) DeleteExpiredRefreshTokens(ctx context.Context, before time.Time) error {
+	ctx, cancel := opCtx(ctx)
+	defer cancel()
+	tokens, err := findAll(ctx, s, collRefreshTokens, func(t *models.RefreshToken) bool { return t.ExpiresAt.Before(before) })
+	if err != nil {
+		return err
+	}
+	for _, t := range tokens {
+		if _, err := s.engine.Delete(ctx, collRefreshTokens, t.ID.Hex()); err != nil && !isNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
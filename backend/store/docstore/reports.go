@@ -0,0 +1,186 @@
+package docstore
+
+import (
+	"context"
+	"regexp"
+	"sort"
+
+	"github.com/kevinaaaquil/books/backend/models"
+	"github.com/kevinaaaquil/books/backend/store"
+)
+
+// bookHealthChecks mirrors the MongoDB filters in store/health.go.
+var bookHealthChecks = map[string]func(*models.Book) bool{
+	models.IssueMissingCover:   func(b *models.Book) bool { return b.CoverURL == "" && b.CoverS3Key == "" },
+	models.IssueMissingISBN:    func(b *models.Book) bool { return b.ISBN == "" },
+	models.IssueZeroPageCount:  func(b *models.Book) bool { return b.PageCount == 0 },
+	models.IssueMetadataFailed: func(b *models.Book) bool { return b.MetadataError != "" },
+	models.IssueUnreadableEPUB: func(b *models.Book) bool { return b.Format == "epub" && b.ParseError != "" },
+	models.IssueSmallFile:      func(b *models.Book) bool { return b.SizeBytes > 0 && b.SizeBytes < store.SmallFileBytes },
+	models.IssueUploadFailed:   func(b *models.Book) bool { return models.UploadIncomplete(b.UploadSteps) },
+	models.IssueStorageProblem: func(b *models.Book) bool {
+		switch b.FileStatus {
+		case models.FileStatusMissing, models.FileStatusCorrupted, models.FileStatusCoverMissing:
+			return true
+		}
+		return false
+	},
+}
+
+// BooksWithHealthIssues returns, per issue code, the books that fail that check (sorted by title).
+func (s *Store) BooksWithHealthIssues(ctx context.Context) (map[string][]models.BookRef, error) {
+	ctx, cancel := opCtx(ctx)
+	defer cancel()
+	books, err := findAll[models.Book](ctx, s, collBooks, nil)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(books, func(i, j int) bool { return books[i].Title < books[j].Title })
+	out := make(map[string][]models.BookRef, len(bookHealthChecks))
+	for issue, check := range bookHealthChecks {
+		refs := []models.BookRef{}
+		for i := range books {
+			if check(&books[i]) {
+				refs = append(refs, models.BookRef{ID: books[i].ID, Title: books[i].Title, Format: books[i].Format})
+			}
+		}
+		out[issue] = refs
+	}
+	return out, nil
+}
+
+// publishYear matches publishDate values that start with a 4-digit year, as in store/timeline.go.
+var publishYear = regexp.MustCompile(`^[0-9]{4}`)
+
+// countBuckets turns period counts into buckets sorted by period.
+func countBuckets(counts map[string]int) []models.TimelineBucket {
+	buckets := make([]models.TimelineBucket, 0, len(counts))
+	for period, n := range counts {
+		buckets = append(buckets, models.TimelineBucket{Period: period, Count: n})
+	}
+	sort.Slice(buckets, func(i, j int) bool { return buckets[i].Period < buckets[j].Period })
+	return buckets
+}
+
+// BookTimeline groups the books q selects by publication decade/year and by month added.
+func (s *Store) BookTimeline(ctx context.Context, q models.BookQuery) (*models.BookTimeline, error) {
+	ctx, cancel := opCtx(ctx)
+	defer cancel()
+	books, err := findAll(ctx, s, collBooks, q.Matches)
+	if err != nil {
+		return nil, err
+	}
+	decades, years, months := map[string]int{}, map[string]int{}, map[string]int{}
+	timeline := &models.BookTimeline{}
+	for _, b := range books {
+		months[b.CreatedAt.UTC().Format("2006-01")]++
+		if !publishYear.MatchString(b.PublishDate) {
+			timeline.Undated++
+			continue
+		}
+		decades[b.PublishDate[:3]+"0s"]++
+		years[b.PublishDate[:4]]++
+	}
+	timeline.PublishedByDecade = countBuckets(decades)
+	timeline.PublishedByYear = countBuckets(years)
+	timeline.AddedByMonth = countBuckets(months)
+	return timeline, nil
+}
+
+// BooksForGraph returns up to limit books, newest first, with only the fields a library graph needs, and
+// whether there were more. When guestOnly is true only books with viewByGuest are returned.
+func (s *Store) BooksForGraph(ctx context.Context, guestOnly bool, limit int) ([]models.Book, bool, error) {
+	ctx, cancel := opCtx(ctx)
+	defer cancel()
+	books, err := findAll(ctx, s, collBooks, func(b *models.Book) bool { return !guestOnly || b.ViewByGuest })
+	if err != nil {
+		return nil, false, err
+	}
+	sort.Slice(books, func(i, j int) bool {
+		if !books[i].CreatedAt.Equal(books[j].CreatedAt) {
+			return books[i].CreatedAt.After(books[j].CreatedAt)
+		}
+		return books[i].ID.Hex() > books[j].ID.Hex() // as the MongoDB pipeline breaks ties
+	})
+	more := len(books) > limit
+	if more {
+		books = books[:limit]
+	}
+	for i, b := range books {
+		books[i] = models.Book{
+			ID:            b.ID,
+			Title:         b.Title,
+			Authors:       b.Authors,
+			Category:      b.Category,
+			Categories:    b.Categories,
+			PublishDate:   b.PublishDate,
+			ContentRating: b.ContentRating,
+			CreatedAt:     b.CreatedAt,
+		}
+	}
+	return books, more, nil
+}
+
+// sizeBuckets turns per-key totals into buckets sorted by bytes, largest first.
+func sizeBuckets(totals map[string]*models.StorageBucket) []models.StorageBucket {
+	buckets := make([]models.StorageBucket, 0, len(totals))
+	for _, b := range totals {
+		buckets = append(buckets, *b)
+	}
+	sort.Slice(buckets, func(i, j int) bool {
+		if buckets[i].Bytes != buckets[j].Bytes {
+			return buckets[i].Bytes > buckets[j].Bytes
+		}
+		return buckets[i].Key < buckets[j].Key
+	})
+	return buckets
+}
+
+// StorageUsage sums Book.SizeBytes by format, by uploader and by month added.
+func (s *Store) StorageUsage(ctx context.Context) (*models.StorageUsage, error) {
+	ctx, cancel := opCtx(ctx)
+	defer cancel()
+	books, err := findAll[models.Book](ctx, s, collBooks, nil)
+	if err != nil {
+		return nil, err
+	}
+	usage := &models.StorageUsage{}
+	byFormat := map[string]*models.StorageBucket{}
+	byUploader := map[string]*models.StorageBucket{}
+	growth := map[string]*models.StorageGrowthPoint{}
+	add := func(totals map[string]*models.StorageBucket, key string, size int64) {
+		if totals[key] == nil {
+			totals[key] = &models.StorageBucket{Key: key}
+		}
+		totals[key].Bytes += size
+		totals[key].Books++
+	}
+	for _, b := range books {
+		usage.TotalBytes += b.SizeBytes
+		usage.TotalBooks++
+		if b.SizeBytes == 0 {
+			usage.UnknownSizeBooks++
+		}
+		add(byFormat, b.Format, b.SizeBytes)
+		add(byUploader, b.UploadedByEmail, b.SizeBytes)
+		month := b.CreatedAt.UTC().Format("2006-01")
+		if growth[month] == nil {
+			growth[month] = &models.StorageGrowthPoint{Month: month}
+		}
+		growth[month].AddedBytes += b.SizeBytes
+		growth[month].Books++
+	}
+	usage.ByFormat = sizeBuckets(byFormat)
+	usage.ByUploader = sizeBuckets(byUploader)
+	usage.Growth = make([]models.StorageGrowthPoint, 0, len(growth))
+	for _, p := range growth {
+		usage.Growth = append(usage.Growth, *p)
+	}
+	sort.Slice(usage.Growth, func(i, j int) bool { return usage.Growth[i].Month < usage.Growth[j].Month })
+	var cumulative int64
+	for i := range usage.Growth {
+		cumulative += usage.Growth[i].AddedBytes
+		usage.Growth[i].CumulativeBytes = cumulative
+	}
+	return usage, nil
+}
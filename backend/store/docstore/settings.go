@@ -0,0 +1,46 @@
+package docstore
+
+import (
+	"context"
+
+	"github.com/kevinaaaquil/books/backend/models"
+)
+
+// Settings returns the server-wide settings; defaults when none were saved. Stored under models.SettingsID.
+func (s *Store) Settings(ctx context.Context) (*models.Settings, error) {
+	ctx, cancel := opCtx(ctx)
+	defer cancel()
+	doc, err := s.engine.Get(ctx, collSettings, models.SettingsID)
+	if isNotFound(err) {
+		return &models.Settings{ID: models.SettingsID}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var settings models.Settings
+	if err := decode(doc, &settings); err != nil {
+		return nil, err
+	}
+	return &settings, nil
+}
+
+// SaveSettings replaces the server-wide settings.
+func (s *Store) SaveSettings(ctx context.Context, settings *models.Settings) error {
+	ctx, cancel := opCtx(ctx)
+	defer cancel()
+	settings.ID = models.SettingsID
+	doc, err := encode(settings)
+	if err != nil {
+		return err
+	}
+	err = s.engine.Update(ctx, collSettings, models.SettingsID, func([]byte) ([]byte, error) { return doc, nil })
+	if !isNotFound(err) {
+		return err
+	}
+	err = s.engine.Insert(ctx, collSettings, models.SettingsID, doc)
+	if isDuplicate(err) {
+		// Saved concurrently by another instance; this save wins.
+		return s.engine.Update(ctx, collSettings, models.SettingsID, func([]byte) ([]byte, error) { return doc, nil })
+	}
+	return err
+}
@@ -0,0 +1,229 @@
+package docstore
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/kevinaaaquil/books/backend/models"
+	"github.com/kevinaaaquil/books/backend/store"
+	_ "modernc.org/sqlite" // pure Go driver, so the binary still builds with CGO_ENABLED=0
+)
+
+//go:embed migrations/sqlite/*.sql
+var sqliteMigrations embed.FS
+
+type sqliteEngine struct {
+	db *sql.DB
+}
+
+// OpenSQLite opens (creating if needed) the database file at path, applies pending migrations and returns the store.
+func OpenSQLite(ctx context.Context, path string) (*Store, error) {
+	db, err := sql.Open("sqlite", "file:"+path+"?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_pragma=synchronous(NORMAL)")
+	if err != nil {
+		return nil, err
+	}
+	// One connection serialises writers, which makes Update's read-modify-write atomic without retry loops.
+	db.SetMaxOpenConns(1)
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, err
+	}
+	if err := migrateSQLite(ctx, db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("migrate: %w", err)
+	}
+	log.Printf("Opened SQLite database %s", path)
+	return New(&sqliteEngine{db: db}), nil
+}
+
+// migrateSQLite applies migrations/sqlite files newer than the recorded schema version, in one transaction.
+func migrateSQLite(ctx context.Context, db *sql.DB) error {
+	pending, err := migrations(sqliteMigrations, "migrations/sqlite")
+	if err != nil {
+		return err
+	}
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (version INTEGER PRIMARY KEY, applied_at TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP)`); err != nil {
+		return err
+	}
+	var current int
+	if err := tx.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&current); err != nil {
+		return err
+	}
+	for _, m := range pending {
+		if m.version <= current {
+			continue
+		}
+		if _, err := tx.ExecContext(ctx, m.sql); err != nil {
+			return fmt.Errorf("%s: %w", m.name, err)
+		}
+		if _, err := tx.ExecContext(ctx, `INSERT INTO schema_migrations (version) VALUES (?)`, m.version); err != nil {
+			return err
+		}
+		log.Printf("sqlite: applied migration %s", m.name)
+	}
+	return tx.Commit()
+}
+
+func sqliteTable(collection string) string {
+	return `"` + strings.ReplaceAll(collection, `"`, `""`) + `"`
+}
+
+func (e *sqliteEngine) Get(ctx context.Context, collection, id string) ([]byte, error) {
+	var doc []byte
+	err := e.db.QueryRowContext(ctx, `SELECT doc FROM `+sqliteTable(collection)+` WHERE id = ?`, id).Scan(&doc)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, store.ErrNotFound
+	}
+	return doc, err
+}
+
+func (e *sqliteEngine) Insert(ctx context.Context, collection, id string, doc []byte) error {
+	res, err := e.db.ExecContext(ctx, `INSERT INTO `+sqliteTable(collection)+` (id, doc) VALUES (?, ?) ON CONFLICT (id) DO NOTHING`, id, string(doc))
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrDuplicate
+	}
+	return nil
+}
+
+func (e *sqliteEngine) Update(ctx context.Context, collection, id string, fn func(doc []byte) ([]byte, error)) error {
+	tx, err := e.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	var doc []byte
+	err = tx.QueryRowContext(ctx, `SELECT doc FROM `+sqliteTable(collection)+` WHERE id = ?`, id).Scan(&doc)
+	if errors.Is(err, sql.ErrNoRows) {
+		return store.ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	doc, err = fn(doc)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE `+sqliteTable(collection)+` SET doc = ? WHERE id = ?`, string(doc), id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (e *sqliteEngine) Delete(ctx context.Context, collection, id string) ([]byte, error) {
+	var doc []byte
+	err := e.db.QueryRowContext(ctx, `DELETE FROM `+sqliteTable(collection)+` WHERE id = ? RETURNING doc`, id).Scan(&doc)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, store.ErrNotFound
+	}
+	return doc, err
+}
+
+func (e *sqliteEngine) All(ctx context.Context, collection string) ([][]byte, error) {
+	rows, err := e.db.QueryContext(ctx, `SELECT doc FROM `+sqliteTable(collection))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var docs [][]byte
+	for rows.Next() {
+		var doc []byte
+		if err := rows.Scan(&doc); err != nil {
+			return nil, err
+		}
+		docs = append(docs, doc)
+	}
+	return docs, rows.Err()
+}
+
+func (e *sqliteEngine) Find(ctx context.Context, collection, path string, value any) ([][]byte, error) {
+	rows, err := e.db.QueryContext(ctx, `SELECT doc FROM `+sqliteTable(collection)+` WHERE `+sqliteField(path)+` = ?`, value)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var docs [][]byte
+	for rows.Next() {
+		var doc []byte
+		if err := rows.Scan(&doc); err != nil {
+			return nil, err
+		}
+		docs = append(docs, doc)
+	}
+	return docs, rows.Err()
+}
+
+// sqliteField returns the value at path in doc, written exactly as in the expression indexes of the migrations
+// (e.g. json_extract(doc, '$."userId"."$oid"')) so that SQLite uses them.
+func sqliteField(path string) string {
+	return "json_extract(doc, " + sqlString(sqlitePath(path)) + ")"
+}
+
+// sqlitePath returns the JSON path of a dot-separated path, e.g. $."userId"."$oid".
+func sqlitePath(path string) string {
+	p := "$"
+	for _, k := range strings.Split(path, ".") {
+		p += `."` + strings.ReplaceAll(k, `"`, `\"`) + `"`
+	}
+	return p
+}
+
+// sqliteBooks reads createdAt as Unix seconds, from the ISO text or (outside 1970-9999) the milliseconds of an
+// Extended JSON date.
+var sqliteBooks = bookSQL{
+	title:         `lower(COALESCE(json_extract(doc, '$."title"'), ''))`,
+	firstAuthor:   `lower(COALESCE(json_extract(doc, '$."authors"[0]'), ''))`,
+	ratingAverage: `COALESCE(json_extract(doc, '$."ratingAverage"'), 0)`,
+	ratingCount:   `COALESCE(json_extract(doc, '$."ratingCount"'), 0)`,
+	createdAt:     `COALESCE(json_extract(doc, '$."createdAt"."$date"."$numberLong"') / 1000.0, unixepoch(json_extract(doc, '$."createdAt"."$date"'), 'subsec'))`,
+	id:            `id`,
+	viewByGuest:   `json_type(doc, '$."viewByGuest"') = 'true'`,
+	contentRating: `COALESCE(json_extract(doc, '$."contentRating"'), '')`,
+	elements: func(path string) string {
+		return "json_each(doc, " + sqlString(sqlitePath(path)) + ") AS e"
+	},
+	param:   func(int) string { return "?" },
+	noLimit: "-1",
+}
+
+func (e *sqliteEngine) booksPage(ctx context.Context, q models.BookQuery) ([][]byte, int, error) {
+	where, args, page := sqliteBooks.query(q)
+	var total int
+	if err := e.db.QueryRowContext(ctx, `SELECT count(*) FROM `+sqliteTable(collBooks)+` WHERE `+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+	rows, err := e.db.QueryContext(ctx, `SELECT doc FROM `+sqliteTable(collBooks)+` WHERE `+where+page, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+	var docs [][]byte
+	for rows.Next() {
+		var doc []byte
+		if err := rows.Scan(&doc); err != nil {
+			return nil, 0, err
+		}
+		docs = append(docs, doc)
+	}
+	return docs, total, rows.Err()
+}
+
+func (e *sqliteEngine) Ping(ctx context.Context) error {
+	return e.db.PingContext(ctx)
+}
+
+func (e *sqliteEngine) Close() error {
+	return e.db.Close()
+}
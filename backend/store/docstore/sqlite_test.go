@@ -0,0 +1,21 @@
+package docstore
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/kevinaaaquil/books/backend/store"
+	"github.com/kevinaaaquil/books/backend/store/storetest"
+)
+
+func TestSQLiteStore(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) store.Store {
+		s, err := OpenSQLite(context.Background(), filepath.Join(t.TempDir(), "books.db"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { s.Disconnect(context.Background()) })
+		return s
+	})
+}
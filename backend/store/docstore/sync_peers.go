@@ -0,0 +1,56 @@
+package docstore
+
+import (
+	"context"
+	"time"
+
+	"github.com/kevinaaaquil/books/backend/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func (s *Store) InsertSyncPeer(ctx context.Context, p *models.SyncPeer) (primitive.ObjectID, error) {
+	ctx, cancel := opCtx(ctx)
+	defer cancel()
+	if p.ID.IsZero() {
+		p.ID = primitive.NewObjectID()
+	}
+	if err := insertDoc(ctx, s, collSyncPeers, p.ID, p); err != nil {
+		return primitive.NilObjectID, err
+	}
+	return p.ID, nil
+}
+
+// SyncPeers returns every peer, oldest first.
+func (s *Store) SyncPeers(ctx context.Context) ([]models.SyncPeer, error) {
+	ctx, cancel := opCtx(ctx)
+	defer cancel()
+	peers, err := findAll(ctx, s, collSyncPeers, func(*models.SyncPeer) bool { return true })
+	if err != nil {
+		return nil, err
+	}
+	byTime(peers, false, func(p *models.SyncPeer) time.Time { return p.CreatedAt })
+	return peers, nil
+}
+
+// SaveSyncProgress records how far a sync got and its outcome. A deleted peer stays deleted.
+func (s *Store) SaveSyncProgress(ctx context.Context, id primitive.ObjectID, cursors []models.SyncCursor, progressCursor string, last *models.PeerSync) error {
+	ctx, cancel := opCtx(ctx)
+	defer cancel()
+	_, err := updateDoc(ctx, s, collSyncPeers, id, func(stored *models.SyncPeer) {
+		stored.Cursors, stored.ProgressCursor, stored.LastSync = cursors, progressCursor, last
+	})
+	return err
+}
+
+// DeleteSyncPeer removes a peer. Returns false if it does not exist.
+func (s *Store) DeleteSyncPeer(ctx context.Context, id primitive.ObjectID) (bool, error) {
+	ctx, cancel := opCtx(ctx)
+	defer cancel()
+	if _, err := s.engine.Delete(ctx, collSyncPeers, id.Hex()); err != nil {
+		if isNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,72 @@
+package docstore
+
+import (
+	"context"
+	"time"
+
+	"github.com/kevinaaaquil/books/backend/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func (s *Store) InsertDeliveryTarget(ctx context.Context, t *models.DeliveryTarget) (primitive.ObjectID, error) {
+	ctx, cancel := opCtx(ctx)
+	defer cancel()
+	c := *t
+	if c.ID.IsZero() {
+		c.ID = primitive.NewObjectID()
+	}
+	if err := insertDoc(ctx, s, collTargets, c.ID, &c); err != nil {
+		return primitive.NilObjectID, err
+	}
+	return c.ID, nil
+}
+
+// DeliveryTargetsForUser returns the user's targets, oldest first.
+func (s *Store) DeliveryTargetsForUser(ctx context.Context, userID primitive.ObjectID) ([]models.DeliveryTarget, error) {
+	ctx, cancel := opCtx(ctx)
+	defer cancel()
+	targets, err := findAll(ctx, s, collTargets, func(t *models.DeliveryTarget) bool { return t.UserID == userID })
+	if err != nil {
+		return nil, err
+	}
+	byTime(targets, false, func(t *models.DeliveryTarget) time.Time { return t.CreatedAt })
+	return targets, nil
+}
+
+// DeliveryTarget returns one of the user's targets, or nil if it does not exist or belongs to someone else.
+func (s *Store) DeliveryTarget(ctx context.Context, userID, id primitive.ObjectID) (*models.DeliveryTarget, error) {
+	ctx, cancel := opCtx(ctx)
+	defer cancel()
+	t, err := getDoc[models.DeliveryTarget](ctx, s, collTargets, id)
+	if isNotFound(err) || (err == nil && t.UserID != userID) {
+		return nil, nil
+	}
+	return t, err
+}
+
+func (s *Store) UpdateDeliveryTargetVerification(ctx context.Context, id primitive.ObjectID, v *models.AddressVerification) error {
+	ctx, cancel := opCtx(ctx)
+	defer cancel()
+	_, err := updateDoc(ctx, s, collTargets, id, func(t *models.DeliveryTarget) { t.Verification = v })
+	return err
+}
+
+// DeleteDeliveryTarget removes one of the user's targets. Returns false if it does not exist.
+func (s *Store) DeleteDeliveryTarget(ctx context.Context, userID, id primitive.ObjectID) (bool, error) {
+	ctx, cancel := opCtx(ctx)
+	defer cancel()
+	t, err := getDoc[models.DeliveryTarget](ctx, s, collTargets, id)
+	if isNotFound(err) || (err == nil && t.UserID != userID) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if _, err := s.engine.Delete(ctx, collTargets, id.Hex()); err != nil {
+		if isNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,67 @@
+package docstore
+
+import (
+	"context"
+
+	"github.com/kevinaaaquil/books/backend/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// LinkTelegramChat stores c, replacing any link of the same chat or user.
+func (s *Store) LinkTelegramChat(ctx context.Context, c *models.TelegramChat) error {
+	ctx, cancel := opCtx(ctx)
+	defer cancel()
+	if _, err := s.deleteTelegramChats(ctx, "chatId", c.ChatID); err != nil {
+		return err
+	}
+	if _, err := s.deleteTelegramChats(ctx, "userId.$oid", c.UserID.Hex()); err != nil {
+		return err
+	}
+	if c.ID.IsZero() {
+		c.ID = primitive.NewObjectID()
+	}
+	return insertDoc(ctx, s, collTelegramChats, c.ID, c)
+}
+
+// TelegramChat returns the link of a chat, or nil if it is not linked.
+func (s *Store) TelegramChat(ctx context.Context, chatID int64) (*models.TelegramChat, error) {
+	return s.findTelegramChat(ctx, "chatId", chatID)
+}
+
+// TelegramChatForUser returns the user's linked chat, or nil if there is none.
+func (s *Store) TelegramChatForUser(ctx context.Context, userID primitive.ObjectID) (*models.TelegramChat, error) {
+	return s.findTelegramChat(ctx, "userId.$oid", userID.Hex())
+}
+
+// findTelegramChat returns a link whose field at path is value (see Engine.Find), or nil if there is none.
+func (s *Store) findTelegramChat(ctx context.Context, path string, value any) (*models.TelegramChat, error) {
+	ctx, cancel := opCtx(ctx)
+	defer cancel()
+	chats, err := findBy[models.TelegramChat](ctx, s, collTelegramChats, path, value)
+	if err != nil || len(chats) == 0 {
+		return nil, err
+	}
+	return &chats[0], nil
+}
+
+// UnlinkTelegram removes the user's linked chat. Returns false if there is none.
+func (s *Store) UnlinkTelegram(ctx context.Context, userID primitive.ObjectID) (bool, error) {
+	ctx, cancel := opCtx(ctx)
+	defer cancel()
+	n, err := s.deleteTelegramChats(ctx, "userId.$oid", userID.Hex())
+	return n > 0, err
+}
+
+// deleteTelegramChats removes the links whose field at path is value and returns how many there were.
+func (s *Store) deleteTelegramChats(ctx context.Context, path string, value any) (int, error) {
+	chats, err := findBy[models.TelegramChat](ctx, s, collTelegramChats, path, value)
+	if err != nil {
+		return 0, err
+	}
+	for _, c := range chats {
+		if _, err := s.engine.Delete(ctx, collTelegramChats, c.ID.Hex()); err != nil && !isNotFound(err) {
+			return 0, err
+		}
+	}
+	return len(chats), nil
+}
@@ -0,0 +1,169 @@
+package docstore
+
+import (
+	"context"
+	"time"
+
+	"github.com/kevinaaaquil/books/backend/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// firstUser returns the first user matching keep, or nil if none.
+func (s *Store) firstUser(ctx context.Context, keep func(*models.User) bool) (*models.User, error) {
+	ctx, cancel := opCtx(ctx)
+	defer cancel()
+	users, err := findAll(ctx, s, collUsers, keep)
+	if err != nil || len(users) == 0 {
+		return nil, err
+	}
+	byTime(users, false, func(u *models.User) time.Time { return u.CreatedAt })
+	return &users[0], nil
+}
+
+func (s *Store) countUsers(ctx context.Context, keep func(*models.User) bool) (int64, error) {
+	ctx, cancel := opCtx(ctx)
+	defer cancel()
+	users, err := findAll(ctx, s, collUsers, keep)
+	return int64(len(users)), err
+}
+
+// UsersCount returns the number of users.
+func (s *Store) UsersCount(ctx context.Context) (int64, error) {
+	return s.countUsers(ctx, nil)
+}
+
+// AdminsCount returns the number of users with role admin.
+func (s *Store) AdminsCount(ctx context.Context) (int64, error) {
+	return s.countUsers(ctx, func(u *models.User) bool { return u.Role == models.RoleAdmin })
+}
+
+func (s *Store) UserByEmail(ctx context.Context, email string) (*models.User, error) {
+	ctx, cancel := opCtx(ctx)
+	defer cancel()
+	users, err := findBy[models.User](ctx, s, collUsers, "email", email)
+	if err != nil || len(users) == 0 {
+		return nil, err
+	}
+	return &users[0], nil
+}
+
+// UserByRole returns one user with the given role, or nil if none.
+func (s *Store) UserByRole(ctx context.Context, role string) (*models.User, error) {
+	return s.firstUser(ctx, func(u *models.User) bool { return u.Role == role })
+}
+
+// UsersByRole returns all users with the given role.
+func (s *Store) UsersByRole(ctx context.Context, role string) ([]models.User, error) {
+	ctx, cancel := opCtx(ctx)
+	defer cancel()
+	return findAll(ctx, s, collUsers, func(u *models.User) bool { return u.Role == role })
+}
+
+func (s *Store) CreateUser(ctx context.Context, user *models.User) (primitive.ObjectID, error) {
+	ctx, cancel := opCtx(ctx)
+	defer cancel()
+	u := *user
+	if u.ID.IsZero() {
+		u.ID = primitive.NewObjectID()
+	}
+	if err := insertDoc(ctx, s, collUsers, u.ID, &u); err != nil {
+		return primitive.NilObjectID, err
+	}
+	return u.ID, nil
+}
+
+// UserByID returns the user, or nil if none exists.
+func (s *Store) UserByID(ctx context.Context, id primitive.ObjectID) (*models.User, error) {
+	ctx, cancel := opCtx(ctx)
+	defer cancel()
+	u, err := getDoc[models.User](ctx, s, collUsers, id)
+	if isNotFound(err) {
+		return nil, nil
+	}
+	return u, err
+}
+
+func (s *Store) ListUsers(ctx context.Context) ([]models.User, error) {
+	ctx, cancel := opCtx(ctx)
+	defer cancel()
+	users, err := findAll[models.User](ctx, s, collUsers, nil)
+	if err != nil {
+		return nil, err
+	}
+	byTime(users, false, func(u *models.User) time.Time { return u.CreatedAt })
+	return users, nil
+}
+
+func (s *Store) UpdateUser(ctx context.Context, id primitive.ObjectID, email *string, hashedPassword *string, role *string) error {
+	if email == nil && hashedPassword == nil && role == nil {
+		return nil
+	}
+	ctx, cancel := opCtx(ctx)
+	defer cancel()
+	_, err := updateDoc(ctx, s, collUsers, id, func(u *models.User) {
+		if email != nil {
+			u.Email = *email
+		}
+		if hashedPassword != nil {
+			u.Password = *hashedPassword
+		}
+		if role != nil {
+			u.Role = *role
+		}
+	})
+	return err
+}
+
+// UpdateUserUseExtractedCover sets the user's thumbnail preference.
+func (s *Store) UpdateUserUseExtractedCover(ctx context.Context, id primitive.ObjectID, useExtractedCover bool) error {
+	ctx, cancel := opCtx(ctx)
+	defer cancel()
+	_, err := updateDoc(ctx, s, collUsers, id, func(u *models.User) { u.UseExtractedCover = useExtractedCover })
+	return err
+}
+
+func (s *Store) UpdateUserMaxContentRating(ctx context.Context, id primitive.ObjectID, maxRating string) error {
+	ctx, cancel := opCtx(ctx)
+	defer cancel()
+	_, err := updateDoc(ctx, s, collUsers, id, func(u *models.User) { u.MaxContentRating = maxRating })
+	return err
+}
+
+func (s *Store) UpdateUserWeeklyDigest(ctx context.Context, id primitive.ObjectID, weeklyDigest bool) error {
+	ctx, cancel := opCtx(ctx)
+	defer cancel()
+	_, err := updateDoc(ctx, s, collUsers, id, func(u *models.User) { u.WeeklyDigest = weeklyDigest })
+	return err
+}
+
+func (s *Store) SetUserDigestSentAt(ctx context.Context, id primitive.ObjectID, at time.Time) error {
+	ctx, cancel := opCtx(ctx)
+	defer cancel()
+	_, err := updateDoc(ctx, s, collUsers, id, func(u *models.User) { u.DigestSentAt = &at })
+	return err
+}
+
+// SetUserTermsAccepted records that the user accepted version of the terms of use at at.
+func (s *Store) SetUserTermsAccepted(ctx context.Context, id primitive.ObjectID, version int, at time.Time) error {
+	ctx, cancel := opCtx(ctx)
+	defer cancel()
+	_, err := updateDoc(ctx, s, collUsers, id, func(u *models.User) { u.TermsVersion, u.TermsAcceptedAt = version, &at })
+	return err
+}
+
+func (s *Store) SetUserOnboardedAt(ctx context.Context, id primitive.ObjectID, at time.Time) error {
+	ctx, cancel := opCtx(ctx)
+	defer cancel()
+	_, err := updateDoc(ctx, s, collUsers, id, func(u *models.User) { u.OnboardedAt = &at })
+	return err
+}
+
+func (s *Store) DeleteUser(ctx context.Context, id primitive.ObjectID) error {
+	ctx, cancel := opCtx(ctx)
+	defer cancel()
+	_, err := s.engine.Delete(ctx, collUsers, id.Hex())
+	if isNotFound(err) {
+		return nil
+	}
+	return err
+}
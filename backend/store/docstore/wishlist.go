@@ -0,0 +1,76 @@
+package docstore
+
+import (
+	"context"
+	"time"
+
+	"github.com/kevinaaaquil/books/backend/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func (s *Store) InsertWishlistItem(ctx context.Context, item *models.WishlistItem) (primitive.ObjectID, error) {
+	ctx, cancel := opCtx(ctx)
+	defer cancel()
+	c := *item
+	if c.ID.IsZero() {
+		c.ID = primitive.NewObjectID()
+	}
+	if err := insertDoc(ctx, s, collWishlist, c.ID, &c); err != nil {
+		return primitive.NilObjectID, err
+	}
+	return c.ID, nil
+}
+
+// WishlistForUser returns the user's wishlist, newest first.
+func (s *Store) WishlistForUser(ctx context.Context, userID primitive.ObjectID) ([]models.WishlistItem, error) {
+	ctx, cancel := opCtx(ctx)
+	defer cancel()
+	items, err := findAll(ctx, s, collWishlist, func(i *models.WishlistItem) bool { return i.UserID == userID })
+	if err != nil {
+		return nil, err
+	}
+	byTime(items, true, func(i *models.WishlistItem) time.Time { return i.CreatedAt })
+	return items, nil
+}
+
+// wishlistItem returns one of the user's items, or nil if it does not exist or belongs to someone else.
+func (s *Store) wishlistItem(ctx context.Context, userID, id primitive.ObjectID) (*models.WishlistItem, error) {
+	item, err := getDoc[models.WishlistItem](ctx, s, collWishlist, id)
+	if isNotFound(err) || (err == nil && item.UserID != userID) {
+		return nil, nil
+	}
+	return item, err
+}
+
+// UpdateWishlistItem replaces one of item.UserID's items. Returns false if it does not exist.
+func (s *Store) UpdateWishlistItem(ctx context.Context, item *models.WishlistItem) (bool, error) {
+	ctx, cancel := opCtx(ctx)
+	defer cancel()
+	if existing, err := s.wishlistItem(ctx, item.UserID, item.ID); err != nil || existing == nil {
+		return false, err
+	}
+	return updateDoc(ctx, s, collWishlist, item.ID, func(stored *models.WishlistItem) { *stored = *item })
+}
+
+// DeleteWishlistItem removes one of the user's items. Returns false if it does not exist.
+func (s *Store) DeleteWishlistItem(ctx context.Context, userID, id primitive.ObjectID) (bool, error) {
+	ctx, cancel := opCtx(ctx)
+	defer cancel()
+	if existing, err := s.wishlistItem(ctx, userID, id); err != nil || existing == nil {
+		return false, err
+	}
+	if _, err := s.engine.Delete(ctx, collWishlist, id.Hex()); err != nil {
+		if isNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// WatchedWishlistItems returns every user's items with a price watch.
+func (s *Store) WatchedWishlistItems(ctx context.Context) ([]models.WishlistItem, error) {
+	ctx, cancel := opCtx(ctx)
+	defer cancel()
+	return findAll(ctx, s, collWishlist, func(i *models.WishlistItem) bool { return i.PriceWatch != nil })
+}
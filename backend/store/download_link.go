@@ -0,0 +1,68 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/kevinaaaquil/books/backend/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// InsertDownloadLink records an issued download URL.
+func (db *DB) InsertDownloadLink(ctx context.Context, l *models.DownloadLink) error {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
+	if l.ID.IsZero() {
+		l.ID = primitive.NewObjectID()
+	}
+	_, err := db.DownloadLinks().InsertOne(ctx, l)
+	return err
+}
+
+// RecentDownloadLinks returns the most recently issued download URLs, newest first; only bookID's when it is set.
+func (db *DB) RecentDownloadLinks(ctx context.Context, bookID primitive.ObjectID, limit int64) ([]models.DownloadLink, error) {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
+	filter := bson.M{}
+	if !bookID.IsZero() {
+		filter["bookId"] = bookID
+	}
+	cur, err := db.DownloadLinks().Find(ctx, filter, options.Find().SetSort(bson.M{"issuedAt": -1}).SetLimit(limit))
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+	links := []models.DownloadLink{}
+	if err := cur.All(ctx, &links); err != nil {
+		return nil, err
+	}
+	return links, nil
+}
+
+// PresignedDownloadsByMonth sums the bytes of presigned links issued since since, by month (UTC), oldest first.
+func (db *DB) PresignedDownloadsByMonth(ctx context.Context, since time.Time) ([]models.DownloadMonth, error) {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"kind": models.DownloadLinkPresigned, "issuedAt": bson.M{"$gte": since}}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":   bson.M{"$dateToString": bson.M{"format": "%Y-%m", "date": "$issuedAt"}},
+			"bytes": bson.M{"$sum": bson.M{"$ifNull": bson.A{"$bytes", 0}}},
+			"links": bson.M{"$sum": 1},
+		}}},
+		{{Key: "$sort", Value: bson.M{"_id": 1}}},
+	}
+	cur, err := db.DownloadLinks().Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+	months := []models.DownloadMonth{}
+	if err := cur.All(ctx, &months); err != nil {
+		return nil, err
+	}
+	return months, nil
+}
@@ -12,6 +12,8 @@ import (
 
 // EnsureEmailConfigIndex creates a unique index on userId so each user has at most one Kindle config.
 func (db *DB) EnsureEmailConfigIndex(ctx context.Context) error {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
 	idx := mongo.IndexModel{
 		Keys:    bson.D{{Key: "userId", Value: 1}},
 		Options: options.Index().SetUnique(true),
@@ -22,6 +24,8 @@ func (db *DB) EnsureEmailConfigIndex(ctx context.Context) error {
 
 // GetEmailConfig returns the Kindle/email config for the given user, or nil if none exists.
 func (db *DB) GetEmailConfig(ctx context.Context, userID primitive.ObjectID) (*models.EmailConfig, error) {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
 	var cfg models.EmailConfig
 	err := db.EmailConfig().FindOne(ctx, bson.M{"userId": userID}).Decode(&cfg)
 	if err == mongo.ErrNoDocuments {
@@ -35,6 +39,8 @@ func (db *DB) GetEmailConfig(ctx context.Context, userID primitive.ObjectID) (*m
 
 // UpsertEmailConfig creates or updates the Kindle/email config for the given user. Config has its own _id; userId links it to the user.
 func (db *DB) UpsertEmailConfig(ctx context.Context, userID primitive.ObjectID, cfg *models.EmailConfig) error {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
 	set := bson.M{
 		"userId":             userID,
 		"appSpecificPassword": cfg.AppSpecificPassword,
@@ -42,7 +48,13 @@ func (db *DB) UpsertEmailConfig(ctx context.Context, userID primitive.ObjectID,
 		"senderMail":          cfg.SenderMail,
 		"kindleMail":          cfg.KindleMail,
 	}
+	update := bson.M{"$set": set}
+	if cfg.KindleVerification != nil {
+		set["kindleVerification"] = cfg.KindleVerification
+	} else {
+		update["$unset"] = bson.M{"kindleVerification": ""}
+	}
 	opts := options.Update().SetUpsert(true)
-	_, err := db.EmailConfig().UpdateOne(ctx, bson.M{"userId": userID}, bson.M{"$set": set}, opts)
+	_, err := db.EmailConfig().UpdateOne(ctx, bson.M{"userId": userID}, update, opts)
 	return err
 }
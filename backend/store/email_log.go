@@ -2,13 +2,68 @@ package store
 
 import (
 	"context"
+	"time"
 
 	"github.com/kevinaaaquil/books/backend/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 // InsertEmailLog records that a book was sent to an email by a user.
 func (db *DB) InsertEmailLog(ctx context.Context, log *models.EmailLog) error {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
 	_, err := db.EmailLogs().InsertOne(ctx, log, options.InsertOne())
 	return err
 }
+
+// EmailLogsSince returns sends at or after since, oldest first.
+func (db *DB) EmailLogsSince(ctx context.Context, since time.Time) ([]models.EmailLog, error) {
+	return db.findEmailLogs(ctx, bson.M{"sentAt": bson.M{"$gte": since}})
+}
+
+// UserEmailLogsSince returns the user's sends at or after since, oldest first.
+func (db *DB) UserEmailLogsSince(ctx context.Context, userID primitive.ObjectID, since time.Time) ([]models.EmailLog, error) {
+	return db.findEmailLogs(ctx, bson.M{"userId": userID, "sentAt": bson.M{"$gte": since}})
+}
+
+func (db *DB) findEmailLogs(ctx context.Context, filter bson.M) ([]models.EmailLog, error) {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
+	cur, err := db.EmailLogs().Find(ctx, filter, options.Find().SetSort(bson.M{"sentAt": 1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+	logs := []models.EmailLog{}
+	if err := cur.All(ctx, &logs); err != nil {
+		return nil, err
+	}
+	return logs, nil
+}
+
+// InsertSystemEmail records an invite, password reset or notification email and whether it was sent.
+func (db *DB) InsertSystemEmail(ctx context.Context, e *models.SystemEmail) error {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
+	_, err := db.SystemEmails().InsertOne(ctx, e, options.InsertOne())
+	return err
+}
+
+// RecentSystemEmails returns the most recent system emails, newest first.
+func (db *DB) RecentSystemEmails(ctx context.Context, limit int64) ([]models.SystemEmail, error) {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
+	opts := options.Find().SetSort(bson.M{"sentAt": -1}).SetLimit(limit)
+	cur, err := db.SystemEmails().Find(ctx, bson.M{}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+	emails := []models.SystemEmail{}
+	if err := cur.All(ctx, &emails); err != nil {
+		return nil, err
+	}
+	return emails, nil
+}
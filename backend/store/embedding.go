@@ -0,0 +1,77 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/kevinaaaquil/books/backend/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// UpsertBookEmbedding replaces e.BookID's embedding, and sets e.ID.
+func (db *DB) UpsertBookEmbedding(ctx context.Context, e *models.BookEmbedding) error {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
+	set := bson.M{
+		"model":     e.Model,
+		"textHash":  e.TextHash,
+		"vector":    e.Vector,
+		"updatedAt": e.UpdatedAt,
+	}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After).SetProjection(bson.M{"_id": 1})
+	var stored struct {
+		ID primitive.ObjectID `bson:"_id"`
+	}
+	err := db.BookEmbeddings().FindOneAndUpdate(ctx, bson.M{"bookId": e.BookID}, bson.M{"$set": set}, opts).Decode(&stored)
+	if err != nil {
+		return err
+	}
+	e.ID = stored.ID
+	return nil
+}
+
+// BookEmbedding returns the book's embedding, or nil if it has none.
+func (db *DB) BookEmbedding(ctx context.Context, bookID primitive.ObjectID) (*models.BookEmbedding, error) {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
+	var e models.BookEmbedding
+	err := db.BookEmbeddings().FindOne(ctx, bson.M{"bookId": bookID}).Decode(&e)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+// BookEmbeddingsSince returns the embeddings updated at or after since; every one for the zero time.
+func (db *DB) BookEmbeddingsSince(ctx context.Context, since time.Time) ([]models.BookEmbedding, error) {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
+	filter := bson.M{}
+	if !since.IsZero() {
+		filter["updatedAt"] = bson.M{"$gte": since}
+	}
+	cur, err := db.BookEmbeddings().Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+	var embeddings []models.BookEmbedding
+	if err := cur.All(ctx, &embeddings); err != nil {
+		return nil, err
+	}
+	return embeddings, nil
+}
+
+// DeleteBookEmbedding removes a deleted book's embedding.
+func (db *DB) DeleteBookEmbedding(ctx context.Context, bookID primitive.ObjectID) error {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
+	_, err := db.BookEmbeddings().DeleteMany(ctx, bson.M{"bookId": bookID})
+	return err
+}
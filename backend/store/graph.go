@@ -0,0 +1,42 @@
+package store
+
+import (
+	"context"
+
+	"github.com/kevinaaaquil/books/backend/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// graphFields are the book fields BooksForGraph returns.
+var graphFields = bson.M{"title": 1, "authors": 1, "category": 1, "categories": 1, "publishDate": 1, "contentRating": 1, "createdAt": 1}
+
+// BooksForGraph returns up to limit books, newest first, with only the fields a library graph needs (title,
+// authors, categories, publication date and content rating), and whether there were more. When guestOnly is
+// true only books with viewByGuest are returned.
+func (db *DB) BooksForGraph(ctx context.Context, guestOnly bool, limit int) ([]models.Book, bool, error) {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
+	pipeline := mongo.Pipeline{}
+	if guestOnly {
+		pipeline = append(pipeline, bson.D{{Key: "$match", Value: bson.M{"viewByGuest": true}}})
+	}
+	pipeline = append(pipeline,
+		bson.D{{Key: "$sort", Value: bson.D{{Key: "createdAt", Value: -1}, {Key: "_id", Value: -1}}}},
+		bson.D{{Key: "$limit", Value: limit + 1}}, // one more than asked for tells whether there were more
+		bson.D{{Key: "$project", Value: graphFields}},
+	)
+	cur, err := db.BooksForListing().Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, false, err
+	}
+	defer cur.Close(ctx)
+	books := []models.Book{}
+	if err := cur.All(ctx, &books); err != nil {
+		return nil, false, err
+	}
+	if len(books) > limit {
+		return books[:limit], true, nil
+	}
+	return books, false, nil
+}
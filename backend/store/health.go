@@ -0,0 +1,66 @@
+package store
+
+import (
+	"context"
+
+	"github.com/kevinaaaquil/books/backend/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// emptyField matches documents where the field is missing, null, or an empty string.
+func emptyField() bson.M {
+	return bson.M{"$in": bson.A{nil, ""}}
+}
+
+// SmallFileBytes is the size under which a stored book file is reported as suspiciously small.
+const SmallFileBytes = 10 * 1024
+
+// bookHealthFilters maps each health issue to the filter selecting affected books.
+var bookHealthFilters = map[string]bson.M{
+	models.IssueMissingCover:   {"coverUrl": emptyField(), "coverS3Key": emptyField()},
+	models.IssueMissingISBN:    {"isbn": emptyField()},
+	models.IssueZeroPageCount:  {"pageCount": bson.M{"$in": bson.A{nil, 0}}},
+	models.IssueMetadataFailed: {"metadataError": bson.M{"$nin": bson.A{nil, ""}}},
+	models.IssueUnreadableEPUB: {"format": "epub", "parseError": bson.M{"$nin": bson.A{nil, ""}}},
+	models.IssueSmallFile:      {"sizeBytes": bson.M{"$gt": 0, "$lt": SmallFileBytes}},
+	models.IssueUploadFailed:   {"uploadSteps.status": models.UploadStepFailed},
+	models.IssueStorageProblem: {"fileStatus": bson.M{"$in": bson.A{models.FileStatusMissing, models.FileStatusCorrupted, models.FileStatusCoverMissing}}},
+}
+
+// BooksWithHealthIssues returns, per issue code, the books that fail that check (sorted by title).
+func (db *DB) BooksWithHealthIssues(ctx context.Context) (map[string][]models.BookRef, error) {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
+	facets := bson.M{}
+	for issue, filter := range bookHealthFilters {
+		facets[issue] = bson.A{
+			bson.D{{Key: "$match", Value: filter}},
+			bson.D{{Key: "$project", Value: bson.M{"title": 1, "format": 1}}},
+			bson.D{{Key: "$sort", Value: bson.M{"title": 1}}},
+		}
+	}
+	cur, err := db.Books().Aggregate(ctx, mongo.Pipeline{{{Key: "$facet", Value: facets}}})
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+	var results []map[string][]models.BookRef
+	if err := cur.All(ctx, &results); err != nil {
+		return nil, err
+	}
+	out := make(map[string][]models.BookRef, len(bookHealthFilters))
+	if len(results) > 0 {
+		for issue, books := range results[0] {
+			out[issue] = books
+		}
+	}
+	return out, nil
+}
+
+// BooksCount returns the number of books in the catalog.
+func (db *DB) BooksCount(ctx context.Context) (int64, error) {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
+	return db.Books().CountDocuments(ctx, bson.M{})
+}
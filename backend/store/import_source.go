@@ -0,0 +1,85 @@
+package store
+
+import (
+	"context"
+
+	"github.com/kevinaaaquil/books/backend/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func (db *DB) InsertImportSource(ctx context.Context, s *models.ImportSource) (primitive.ObjectID, error) {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
+	if s.ID.IsZero() {
+		s.ID = primitive.NewObjectID()
+	}
+	if _, err := db.ImportSourcesCollection().InsertOne(ctx, s); err != nil {
+		return primitive.NilObjectID, err
+	}
+	return s.ID, nil
+}
+
+// ImportSources returns every import source, oldest first.
+func (db *DB) ImportSources(ctx context.Context) ([]models.ImportSource, error) {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
+	cur, err := db.ImportSourcesCollection().Find(ctx, bson.M{}, options.Find().SetSort(bson.D{{Key: "createdAt", Value: 1}, {Key: "_id", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+	sources := []models.ImportSource{}
+	if err := cur.All(ctx, &sources); err != nil {
+		return nil, err
+	}
+	return sources, nil
+}
+
+// ImportSource returns a source, or nil if it does not exist.
+func (db *DB) ImportSource(ctx context.Context, id primitive.ObjectID) (*models.ImportSource, error) {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
+	var s models.ImportSource
+	err := db.ImportSourcesCollection().FindOne(ctx, bson.M{"_id": id}).Decode(&s)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// UpdateImportSource sets a source's Name, Folder and AutoSync. Returns false if it does not exist.
+func (db *DB) UpdateImportSource(ctx context.Context, s *models.ImportSource) (bool, error) {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
+	set := bson.M{"name": s.Name, "folder": s.Folder, "autoSync": s.AutoSync}
+	res, err := db.ImportSourcesCollection().UpdateOne(ctx, bson.M{"_id": s.ID}, bson.M{"$set": set})
+	if err != nil {
+		return false, err
+	}
+	return res.MatchedCount > 0, nil
+}
+
+// SaveImportProgress records the files a sync has dealt with and its outcome. A deleted source stays deleted.
+func (db *DB) SaveImportProgress(ctx context.Context, id primitive.ObjectID, files []models.ImportedFile, last *models.ImportSync) error {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
+	_, err := db.ImportSourcesCollection().UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{"files": files, "lastSync": last}})
+	return err
+}
+
+// DeleteImportSource removes a source. Returns false if it does not exist.
+func (db *DB) DeleteImportSource(ctx context.Context, id primitive.ObjectID) (bool, error) {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
+	res, err := db.ImportSourcesCollection().DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return false, err
+	}
+	return res.DeletedCount > 0, nil
+}
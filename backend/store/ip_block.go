@@ -0,0 +1,63 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/kevinaaaquil/books/backend/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func (db *DB) InsertIPBlock(ctx context.Context, b *models.IPBlock) (primitive.ObjectID, error) {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
+	if b.ID.IsZero() {
+		b.ID = primitive.NewObjectID()
+	}
+	if _, err := db.IPBlocksCollection().InsertOne(ctx, b); err != nil {
+		return primitive.NilObjectID, err
+	}
+	return b.ID, nil
+}
+
+// IPBlocks returns the blocks in force at now, oldest first.
+func (db *DB) IPBlocks(ctx context.Context, now time.Time) ([]models.IPBlock, error) {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
+	filter := bson.M{"$or": bson.A{
+		bson.M{"expiresAt": bson.M{"$exists": false}},
+		bson.M{"expiresAt": nil},
+		bson.M{"expiresAt": bson.M{"$gt": now}},
+	}}
+	cur, err := db.IPBlocksCollection().Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "createdAt", Value: 1}, {Key: "_id", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+	blocks := []models.IPBlock{}
+	if err := cur.All(ctx, &blocks); err != nil {
+		return nil, err
+	}
+	return blocks, nil
+}
+
+// DeleteIPBlock removes a block. Returns false if it does not exist.
+func (db *DB) DeleteIPBlock(ctx context.Context, id primitive.ObjectID) (bool, error) {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
+	res, err := db.IPBlocksCollection().DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return false, err
+	}
+	return res.DeletedCount > 0, nil
+}
+
+// DeleteExpiredIPBlocks removes the blocks that expired before before.
+func (db *DB) DeleteExpiredIPBlocks(ctx context.Context, before time.Time) error {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
+	_, err := db.IPBlocksCollection().DeleteMany(ctx, bson.M{"expiresAt": bson.M{"$lt": before}})
+	return err
+}
@@ -0,0 +1,105 @@
+package store
+
+import (
+	"context"
+
+	"github.com/kevinaaaquil/books/backend/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func (db *DB) InsertJobRun(ctx context.Context, run *models.JobRun) (primitive.ObjectID, error) {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
+	res, err := db.JobRuns().InsertOne(ctx, run, options.InsertOne())
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+	return res.InsertedID.(primitive.ObjectID), nil
+}
+
+// UpdateJobRun saves the run's status, progress counters, error and finish time.
+func (db *DB) UpdateJobRun(ctx context.Context, run *models.JobRun) error {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
+	set := bson.M{
+		"status":     run.Status,
+		"total":      run.Total,
+		"processed":  run.Processed,
+		"summary":    run.Summary,
+		"error":      run.Error,
+		"finishedAt": run.FinishedAt,
+		"checkpoint": run.Checkpoint,
+		"log":        run.Log,
+	}
+	_, err := db.JobRuns().UpdateOne(ctx, bson.M{"_id": run.ID}, bson.M{"$set": set})
+	return err
+}
+
+// JobRunByID returns the job run with the given ID, or nil if none exists.
+func (db *DB) JobRunByID(ctx context.Context, id primitive.ObjectID) (*models.JobRun, error) {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
+	var run models.JobRun
+	err := db.JobRuns().FindOne(ctx, bson.M{"_id": id}).Decode(&run)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
+
+// ListJobRuns returns the runs matching f, newest first.
+func (db *DB) ListJobRuns(ctx context.Context, f models.JobRunFilter) ([]models.JobRun, error) {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
+	filter := bson.M{}
+	if f.Type != "" {
+		filter["type"] = f.Type
+	}
+	if f.Status != "" {
+		filter["status"] = f.Status
+	}
+	startedAt := bson.M{}
+	if f.Before != nil {
+		startedAt["$lt"] = *f.Before
+	}
+	if f.Since != nil {
+		startedAt["$gte"] = *f.Since
+	}
+	if len(startedAt) > 0 {
+		filter["startedAt"] = startedAt
+	}
+	opts := options.Find().SetSort(bson.M{"startedAt": -1})
+	if f.Limit > 0 {
+		opts.SetLimit(int64(f.Limit))
+	} else if f.Since == nil {
+		opts.SetLimit(50)
+	}
+	cur, err := db.JobRuns().Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+	runs := []models.JobRun{}
+	if err := cur.All(ctx, &runs); err != nil {
+		return nil, err
+	}
+	return runs, nil
+}
+
+// RequestJobCancel marks a running run as cancelled by an admin, for the instance running it to stop. It returns
+// false when the run does not exist or is not running.
+func (db *DB) RequestJobCancel(ctx context.Context, id primitive.ObjectID) (bool, error) {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
+	res, err := db.JobRuns().UpdateOne(ctx, bson.M{"_id": id, "status": models.JobStatusRunning}, bson.M{"$set": bson.M{"cancelRequested": true}})
+	if err != nil {
+		return false, err
+	}
+	return res.MatchedCount > 0, nil
+}
@@ -0,0 +1,32 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// AcquireLock takes or renews the named lease. The filter matches only a lease owner may take; when another
+// owner holds it, the upsert's insert collides on _id and the lock is reported as held.
+func (db *DB) AcquireLock(ctx context.Context, name, owner string, now time.Time, ttl time.Duration) (bool, error) {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
+	filter := bson.M{"_id": name, "$or": bson.A{bson.M{"owner": owner}, bson.M{"expiresAt": bson.M{"$lte": now}}}}
+	update := bson.M{"$set": bson.M{"owner": owner, "expiresAt": now.Add(ttl)}}
+	_, err := db.Locks().UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+	if mongo.IsDuplicateKeyError(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// ReleaseLock deletes the lease if owner holds it.
+func (db *DB) ReleaseLock(ctx context.Context, name, owner string) error {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
+	_, err := db.Locks().DeleteOne(ctx, bson.M{"_id": name, "owner": owner})
+	return err
+}
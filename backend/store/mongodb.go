@@ -2,31 +2,144 @@ package store
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log"
+	"sync/atomic"
 	"time"
 
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"go.mongodb.org/mongo-driver/x/mongo/driver/topology"
+	"go.opentelemetry.io/contrib/instrumentation/go.mongodb.org/mongo-driver/mongo/otelmongo"
 )
 
 type DB struct {
 	Client   *mongo.Client
 	Database *mongo.Database
+
+	opTimeout time.Duration
+	healthy   atomic.Bool
+	listBooks *mongo.Collection // books with the listing read preference; see BooksForListing
+}
+
+// Options tunes the MongoDB client. Zero values keep the driver defaults.
+type Options struct {
+	MaxPoolSize            uint64
+	MinPoolSize            uint64
+	ConnectTimeout         time.Duration
+	ServerSelectionTimeout time.Duration // how long an operation waits for a reachable server before failing
+	OpTimeout              time.Duration // deadline applied to each store operation; 0 = none
+	RetryWrites            bool
+	RetryReads             bool
+	// ListReadPreference applies to read-heavy listing queries (e.g. "secondaryPreferred", "nearest").
+	// Empty or "primary" keeps every read on the primary.
+	ListReadPreference string
+	Trace              bool // record commands as spans of the traced requests they are sent for (see tracing.Register)
 }
 
-func NewMongoDB(ctx context.Context, uri, dbName string) (*DB, error) {
-	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+// ErrUnavailable is returned (wrapped) when MongoDB cannot be reached.
+var ErrUnavailable = errors.New("database unavailable")
+
+func NewMongoDB(ctx context.Context, uri, dbName string, opts Options) (*DB, error) {
+	clientOpts := options.Client().ApplyURI(uri).
+		SetRetryWrites(opts.RetryWrites).
+		SetRetryReads(opts.RetryReads)
+	if opts.MaxPoolSize > 0 {
+		clientOpts.SetMaxPoolSize(opts.MaxPoolSize)
+	}
+	if opts.MinPoolSize > 0 {
+		clientOpts.SetMinPoolSize(opts.MinPoolSize)
+	}
+	if opts.ConnectTimeout > 0 {
+		clientOpts.SetConnectTimeout(opts.ConnectTimeout)
+	}
+	if opts.ServerSelectionTimeout > 0 {
+		clientOpts.SetServerSelectionTimeout(opts.ServerSelectionTimeout)
+	}
+	if opts.Trace {
+		clientOpts.SetMonitor(otelmongo.NewMonitor())
+	}
+	listMode := readpref.PrimaryMode
+	if opts.ListReadPreference != "" {
+		m, err := readpref.ModeFromString(opts.ListReadPreference)
+		if err != nil {
+			return nil, err
+		}
+		listMode = m
+	}
+	listPref, err := readpref.New(listMode)
 	if err != nil {
 		return nil, err
 	}
-	if err := client.Ping(ctx, nil); err != nil {
+	client, err := mongo.Connect(ctx, clientOpts)
+	if err != nil {
 		return nil, err
 	}
+	if err := client.Ping(ctx, nil); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
 	log.Println("Connected to MongoDB")
-	return &DB{
-		Client:   client,
-		Database: client.Database(dbName),
-	}, nil
+	db := &DB{
+		Client:    client,
+		Database:  client.Database(dbName),
+		opTimeout: opts.OpTimeout,
+	}
+	db.listBooks = db.Database.Collection("books", options.Collection().SetReadPreference(listPref))
+	db.healthy.Store(true)
+	return db, nil
+}
+
+// opCtx bounds a single store operation by the configured OpTimeout.
+func (db *DB) opCtx(ctx context.Context) (context.Context, context.CancelFunc) {
+	if db.opTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, db.opTimeout)
+}
+
+// IsUnavailable reports whether err means MongoDB could not be reached (server selection failure,
+// network error or timeout), as opposed to a query error.
+func IsUnavailable(err error) bool {
+	if err == nil {
+		return false
+	}
+	var sel topology.ServerSelectionError
+	return errors.Is(err, ErrUnavailable) || errors.As(err, &sel) ||
+		mongo.IsNetworkError(err) || mongo.IsTimeout(err) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// Healthy reports whether the last health check reached MongoDB.
+func (db *DB) Healthy() bool {
+	return db.healthy.Load()
+}
+
+// MonitorHealth pings MongoDB every interval until ctx is cancelled, updating Healthy and logging transitions.
+func (db *DB) MonitorHealth(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		pingCtx, cancel := db.opCtx(ctx)
+		err := db.Client.Ping(pingCtx, nil)
+		cancel()
+		if ctx.Err() != nil {
+			return
+		}
+		healthy := err == nil
+		if db.healthy.Swap(healthy) != healthy {
+			if healthy {
+				log.Println("mongodb: reachable again")
+			} else {
+				log.Printf("mongodb: unreachable: %v", err)
+			}
+		}
+	}
 }
 
 func (db *DB) Users() *mongo.Collection {
@@ -37,6 +150,12 @@ func (db *DB) Books() *mongo.Collection {
 	return db.Database.Collection("books")
 }
 
+// BooksForListing is the books collection with the configured listing read preference. Use it only for
+// queries that tolerate replication lag; anything that must see a just-written book uses Books.
+func (db *DB) BooksForListing() *mongo.Collection {
+	return db.listBooks
+}
+
 func (db *DB) EmailConfig() *mongo.Collection {
 	return db.Database.Collection("kindle_config")
 }
@@ -45,6 +164,108 @@ func (db *DB) EmailLogs() *mongo.Collection {
 	return db.Database.Collection("email_logs")
 }
 
+func (db *DB) JobRuns() *mongo.Collection {
+	return db.Database.Collection("job_runs")
+}
+
+func (db *DB) Locks() *mongo.Collection {
+	return db.Database.Collection("locks")
+}
+
+func (db *DB) DownloadLinks() *mongo.Collection {
+	return db.Database.Collection("download_links")
+}
+
+// SettingsCollection holds the one settings document (Settings is the store method reading it).
+func (db *DB) SettingsCollection() *mongo.Collection {
+	return db.Database.Collection("settings")
+}
+
+// ImportSourcesCollection holds the import sources (ImportSources is the store method listing them).
+func (db *DB) ImportSourcesCollection() *mongo.Collection {
+	return db.Database.Collection("import_sources")
+}
+
+func (db *DB) TelegramChats() *mongo.Collection {
+	return db.Database.Collection("telegram_chats")
+}
+
+func (db *DB) APIKeys() *mongo.Collection {
+	return db.Database.Collection("api_keys")
+}
+
+func (db *DB) RefreshTokens() *mongo.Collection {
+	return db.Database.Collection("refresh_tokens")
+}
+
+func (db *DB) JobQueue() *mongo.Collection {
+	return db.Database.Collection("job_queue")
+}
+
+func (db *DB) IPBlocksCollection() *mongo.Collection {
+	return db.Database.Collection("ip_blocks")
+}
+
+func (db *DB) Wishlist() *mongo.Collection {
+	return db.Database.Collection("wishlist")
+}
+
+func (db *DB) Recommendations() *mongo.Collection {
+	return db.Database.Collection("recommendations")
+}
+
+func (db *DB) NewReleases() *mongo.Collection {
+	return db.Database.Collection("new_releases")
+}
+
+func (db *DB) PriceHistoryCollection() *mongo.Collection {
+	return db.Database.Collection("price_history")
+}
+
+func (db *DB) Purchases() *mongo.Collection {
+	return db.Database.Collection("purchases")
+}
+
+func (db *DB) SyncPeersCollection() *mongo.Collection {
+	return db.Database.Collection("sync_peers")
+}
+
+func (db *DB) Notifications() *mongo.Collection {
+	return db.Database.Collection("notifications")
+}
+
+func (db *DB) Backups() *mongo.Collection {
+	return db.Database.Collection("backups")
+}
+
+func (db *DB) SystemEmails() *mongo.Collection {
+	return db.Database.Collection("system_emails")
+}
+
+func (db *DB) DeliveryTargets() *mongo.Collection {
+	return db.Database.Collection("delivery_targets")
+}
+
+func (db *DB) Devices() *mongo.Collection {
+	return db.Database.Collection("devices")
+}
+
+func (db *DB) Collections() *mongo.Collection {
+	return db.Database.Collection("collections")
+}
+
+func (db *DB) AudioTracksCollection() *mongo.Collection {
+	return db.Database.Collection("audio_tracks")
+}
+
+func (db *DB) BookEmbeddings() *mongo.Collection {
+	return db.Database.Collection("book_embeddings")
+}
+
+func (db *DB) ReadingProgress() *mongo.Collection {
+	return db.Database.Collection("reading_progress")
+}
+
 func (db *DB) Disconnect(ctx context.Context) error {
 	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
 	defer cancel()
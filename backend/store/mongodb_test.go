@@ -0,0 +1,115 @@
+package store_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/kevinaaaquil/books/backend/models"
+	"github.com/kevinaaaquil/books/backend/store"
+	"github.com/kevinaaaquil/books/backend/store/storetest"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// TestMongoStore runs the store conformance suite against MongoDB. Set TEST_MONGODB_URI to enable it;
+// each subtest uses (and drops) its own database.
+func TestMongoStore(t *testing.T) {
+	uri := os.Getenv("TEST_MONGODB_URI")
+	if uri == "" {
+		t.Skip("TEST_MONGODB_URI not set")
+	}
+	storetest.Run(t, func(t *testing.T) store.Store {
+		ctx := context.Background()
+		opts := store.Options{ServerSelectionTimeout: 5 * time.Second, OpTimeout: 10 * time.Second}
+		db, err := store.NewMongoDB(ctx, uri, fmt.Sprintf("books_test_%d", time.Now().UnixNano()), opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() {
+			db.Database.Drop(ctx)
+			db.Disconnect(ctx)
+		})
+		return db
+	})
+}
+
+// TestMongoWatchBooks needs TEST_MONGODB_URI to point at a replica set; it is skipped on a standalone server.
+func TestMongoWatchBooks(t *testing.T) {
+	uri := os.Getenv("TEST_MONGODB_URI")
+	if uri == "" {
+		t.Skip("TEST_MONGODB_URI not set")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	db, err := store.NewMongoDB(ctx, uri, fmt.Sprintf("books_test_%d", time.Now().UnixNano()), store.Options{ServerSelectionTimeout: 5 * time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		db.Database.Drop(context.Background())
+		db.Disconnect(context.Background())
+	})
+	changes := make(chan store.BookChange, 10)
+	watchErr := make(chan error, 1)
+	go func() { watchErr <- db.WatchBooks(ctx, func(c store.BookChange) { changes <- c }) }()
+
+	next := func() store.BookChange {
+		t.Helper()
+		select {
+		case c := <-changes:
+			return c
+		case err := <-watchErr:
+			if errors.Is(err, store.ErrWatchUnsupported) {
+				t.Skip(err)
+			}
+			t.Fatalf("WatchBooks returned %v", err)
+		case <-ctx.Done():
+			t.Fatal("no change seen")
+		}
+		return store.BookChange{}
+	}
+	// The stream opens asynchronously; insert until the first change is seen.
+	var id primitive.ObjectID
+	for id.IsZero() {
+		select {
+		case err := <-watchErr:
+			if errors.Is(err, store.ErrWatchUnsupported) {
+				t.Skip(err)
+			}
+			t.Fatalf("WatchBooks returned %v", err)
+		case c := <-changes:
+			id = c.BookID
+			if c.Op != store.BookInserted || c.Book == nil {
+				t.Fatalf("first change = %+v", c)
+			}
+		case <-time.After(200 * time.Millisecond):
+			if _, err := db.InsertBook(ctx, &models.Book{Title: "Watched", Format: "epub"}); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	for drained := false; !drained; {
+		select {
+		case <-changes:
+		case <-time.After(500 * time.Millisecond):
+			drained = true
+		}
+	}
+
+	if err := db.UpdateBookViewByGuest(ctx, id, true); err != nil {
+		t.Fatal(err)
+	}
+	if c := next(); c.Op != store.BookUpdated || c.BookID != id || !c.Book.ViewByGuest || !slices.Equal(c.Fields, []string{"viewByGuest"}) {
+		t.Errorf("update = %+v", c)
+	}
+	if _, _, err := db.DeleteBook(ctx, id); err != nil {
+		t.Fatal(err)
+	}
+	if c := next(); c.Op != store.BookDeleted || c.BookID != id || c.Book != nil {
+		t.Errorf("delete = %+v", c)
+	}
+}
@@ -0,0 +1,41 @@
+package store
+
+import (
+	"context"
+
+	"github.com/kevinaaaquil/books/backend/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// AddNewRelease stores r unless r.UserID already has a release with r.Key. Returns whether it was added.
+func (db *DB) AddNewRelease(ctx context.Context, r *models.NewRelease) (bool, error) {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
+	if r.ID.IsZero() {
+		r.ID = primitive.NewObjectID()
+	}
+	res, err := db.NewReleases().UpdateOne(ctx, bson.M{"userId": r.UserID, "key": r.Key}, bson.M{"$setOnInsert": r}, options.Update().SetUpsert(true))
+	if err != nil {
+		return false, err
+	}
+	return res.UpsertedCount > 0, nil
+}
+
+// NewReleasesForUser returns the user's most recently found releases, newest first.
+func (db *DB) NewReleasesForUser(ctx context.Context, userID primitive.ObjectID, limit int64) ([]models.NewRelease, error) {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
+	opts := options.Find().SetSort(bson.D{{Key: "foundAt", Value: -1}, {Key: "publishDate", Value: -1}}).SetLimit(limit)
+	cur, err := db.NewReleases().Find(ctx, bson.M{"userId": userID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+	releases := []models.NewRelease{}
+	if err := cur.All(ctx, &releases); err != nil {
+		return nil, err
+	}
+	return releases, nil
+}
@@ -0,0 +1,62 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/kevinaaaquil/books/backend/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func (db *DB) InsertNotification(ctx context.Context, n *models.Notification) error {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
+	_, err := db.Notifications().InsertOne(ctx, n, options.InsertOne())
+	return err
+}
+
+// NotificationsForUser returns the user's most recent notifications, newest first.
+func (db *DB) NotificationsForUser(ctx context.Context, userID primitive.ObjectID, limit int64) ([]models.Notification, error) {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
+	opts := options.Find().SetSort(bson.M{"createdAt": -1}).SetLimit(limit)
+	cur, err := db.Notifications().Find(ctx, bson.M{"userId": userID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+	notifications := []models.Notification{}
+	if err := cur.All(ctx, &notifications); err != nil {
+		return nil, err
+	}
+	return notifications, nil
+}
+
+// MarkNotificationRead sets readAt on one of the user's notifications. Returns false if it does not exist.
+func (db *DB) MarkNotificationRead(ctx context.Context, userID, id primitive.ObjectID) (bool, error) {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
+	res, err := db.Notifications().UpdateOne(ctx, bson.M{"_id": id, "userId": userID}, bson.M{"$set": bson.M{"readAt": time.Now()}})
+	if err != nil {
+		return false, err
+	}
+	return res.MatchedCount > 0, nil
+}
+
+// UsersByRole returns all users with the given role.
+func (db *DB) UsersByRole(ctx context.Context, role string) ([]models.User, error) {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
+	cur, err := db.Users().Find(ctx, bson.M{"role": role})
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+	var users []models.User
+	if err := cur.All(ctx, &users); err != nil {
+		return nil, err
+	}
+	return users, nil
+}
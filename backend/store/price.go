@@ -0,0 +1,42 @@
+package store
+
+import (
+	"context"
+
+	"github.com/kevinaaaquil/books/backend/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func (db *DB) InsertPricePoint(ctx context.Context, p *models.PricePoint) error {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
+	_, err := db.PriceHistoryCollection().InsertOne(ctx, p)
+	return err
+}
+
+// PriceHistory returns the item's most recent prices, newest first.
+func (db *DB) PriceHistory(ctx context.Context, itemID primitive.ObjectID, limit int64) ([]models.PricePoint, error) {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
+	opts := options.Find().SetSort(bson.M{"checkedAt": -1}).SetLimit(limit)
+	cur, err := db.PriceHistoryCollection().Find(ctx, bson.M{"itemId": itemID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+	points := []models.PricePoint{}
+	if err := cur.All(ctx, &points); err != nil {
+		return nil, err
+	}
+	return points, nil
+}
+
+// DeletePriceHistory removes the item's prices.
+func (db *DB) DeletePriceHistory(ctx context.Context, itemID primitive.ObjectID) error {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
+	_, err := db.PriceHistoryCollection().DeleteMany(ctx, bson.M{"itemId": itemID})
+	return err
+}
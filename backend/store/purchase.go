@@ -0,0 +1,62 @@
+package store
+
+import (
+	"context"
+
+	"github.com/kevinaaaquil/books/backend/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// BookPurchase returns the book's purchase record, or nil if it has none.
+func (db *DB) BookPurchase(ctx context.Context, bookID primitive.ObjectID) (*models.Purchase, error) {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
+	var p models.Purchase
+	err := db.Purchases().FindOne(ctx, bson.M{"_id": bookID}).Decode(&p)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// SaveBookPurchase creates or replaces p.BookID's purchase record.
+func (db *DB) SaveBookPurchase(ctx context.Context, p *models.Purchase) error {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
+	_, err := db.Purchases().ReplaceOne(ctx, bson.M{"_id": p.BookID}, p, options.Replace().SetUpsert(true))
+	return err
+}
+
+// DeleteBookPurchase removes the book's purchase record. Returns false if it had none.
+func (db *DB) DeleteBookPurchase(ctx context.Context, bookID primitive.ObjectID) (bool, error) {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
+	res, err := db.Purchases().DeleteOne(ctx, bson.M{"_id": bookID})
+	if err != nil {
+		return false, err
+	}
+	return res.DeletedCount > 0, nil
+}
+
+// AllPurchases returns every purchase record, oldest purchase first; records without a date come last.
+func (db *DB) AllPurchases(ctx context.Context) ([]models.Purchase, error) {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
+	cur, err := db.Purchases().Find(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+	purchases := []models.Purchase{}
+	if err := cur.All(ctx, &purchases); err != nil {
+		return nil, err
+	}
+	models.SortPurchases(purchases)
+	return purchases, nil
+}
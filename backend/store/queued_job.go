@@ -0,0 +1,111 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/kevinaaaquil/books/backend/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func (db *DB) InsertQueuedJob(ctx context.Context, job *models.QueuedJob) error {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
+	if job.ID.IsZero() {
+		job.ID = primitive.NewObjectID()
+	}
+	_, err := db.JobQueue().InsertOne(ctx, job)
+	return err
+}
+
+// QueuedJobByID returns the job, or nil if there is none.
+func (db *DB) QueuedJobByID(ctx context.Context, id primitive.ObjectID) (*models.QueuedJob, error) {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
+	var job models.QueuedJob
+	err := db.JobQueue().FindOne(ctx, bson.M{"_id": id}).Decode(&job)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// ClaimQueuedJob starts an attempt of the job due longest: a queued one whose RunAt has come, or a running one
+// whose lease ran out, of one of kinds (nil = any kind). Nil when none is due.
+func (db *DB) ClaimQueuedJob(ctx context.Context, instance string, kinds []string, now, leaseUntil time.Time) (*models.QueuedJob, error) {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
+	filter := bson.M{"$or": bson.A{
+		bson.M{"status": models.QueuedJobQueued, "runAt": bson.M{"$lte": now}},
+		bson.M{"status": models.QueuedJobRunning, "leaseUntil": bson.M{"$lt": now}},
+	}}
+	if kinds != nil {
+		filter["kind"] = bson.M{"$in": kinds}
+	}
+	update := bson.M{
+		"$set": bson.M{"status": models.QueuedJobRunning, "instance": instance, "leaseUntil": leaseUntil, "updatedAt": now},
+		"$inc": bson.M{"attempts": 1},
+	}
+	opts := options.FindOneAndUpdate().SetSort(bson.M{"runAt": 1}).SetReturnDocument(options.After)
+	var job models.QueuedJob
+	err := db.JobQueue().FindOneAndUpdate(ctx, filter, update, opts).Decode(&job)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// UpdateQueuedJob saves the outcome of instance's attempt, unless the job is no longer running there.
+func (db *DB) UpdateQueuedJob(ctx context.Context, job *models.QueuedJob, instance string) (bool, error) {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
+	set := bson.M{
+		"status":     job.Status,
+		"attempts":   job.Attempts,
+		"runAt":      job.RunAt,
+		"leaseUntil": job.LeaseUntil,
+		"error":      job.Error,
+		"result":     job.Result,
+		"updatedAt":  job.UpdatedAt,
+		"finishedAt": job.FinishedAt,
+	}
+	res, err := db.JobQueue().UpdateOne(ctx, bson.M{"_id": job.ID, "status": models.QueuedJobRunning, "instance": instance}, bson.M{"$set": set})
+	if err != nil {
+		return false, err
+	}
+	return res.MatchedCount > 0, nil
+}
+
+// PendingQueuedJobs returns the user's queued and running jobs of kind, oldest first.
+func (db *DB) PendingQueuedJobs(ctx context.Context, userID primitive.ObjectID, kind string) ([]models.QueuedJob, error) {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
+	filter := bson.M{"userId": userID, "kind": kind, "status": bson.M{"$in": bson.A{models.QueuedJobQueued, models.QueuedJobRunning}}}
+	cur, err := db.JobQueue().Find(ctx, filter, options.Find().SetSort(bson.M{"createdAt": 1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+	jobs := []models.QueuedJob{}
+	if err := cur.All(ctx, &jobs); err != nil {
+		return nil, err
+	}
+	return jobs, nil
+}
+
+// DeleteFinishedQueuedJobs removes jobs that succeeded or failed before before.
+func (db *DB) DeleteFinishedQueuedJobs(ctx context.Context, before time.Time) error {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
+	_, err := db.JobQueue().DeleteMany(ctx, bson.M{"finishedAt": bson.M{"$lt": before}})
+	return err
+}
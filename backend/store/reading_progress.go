@@ -0,0 +1,73 @@
+package store
+
+import (
+	"context"
+
+	"github.com/kevinaaaquil/books/backend/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// UpsertReadingProgress replaces p.UserID's progress in p.BookID.
+func (db *DB) UpsertReadingProgress(ctx context.Context, p *models.ReadingProgress) error {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
+	set := bson.M{
+		"anchor":         p.Anchor,
+		"kindleLocation": p.KindleLocation,
+		"percent":        p.Percent,
+		"device":         p.Device,
+		"updatedAt":      p.UpdatedAt,
+	}
+	_, err := db.ReadingProgress().UpdateOne(ctx, bson.M{"userId": p.UserID, "bookId": p.BookID}, bson.M{"$set": set}, options.Update().SetUpsert(true))
+	return err
+}
+
+// ReadingProgressFor returns the user's progress in the book, or nil if none was recorded.
+func (db *DB) ReadingProgressFor(ctx context.Context, userID, bookID primitive.ObjectID) (*models.ReadingProgress, error) {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
+	var p models.ReadingProgress
+	err := db.ReadingProgress().FindOne(ctx, bson.M{"userId": userID, "bookId": bookID}).Decode(&p)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// ReadingProgressByUser returns the user's progress in every book they have opened, most recently read first.
+func (db *DB) ReadingProgressByUser(ctx context.Context, userID primitive.ObjectID) ([]models.ReadingProgress, error) {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
+	cur, err := db.ReadingProgress().Find(ctx, bson.M{"userId": userID}, options.Find().SetSort(bson.D{{Key: "updatedAt", Value: -1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+	var all []models.ReadingProgress
+	if err := cur.All(ctx, &all); err != nil {
+		return nil, err
+	}
+	return all, nil
+}
+
+// AllReadingProgress returns every user's progress in every book, in no particular order.
+func (db *DB) AllReadingProgress(ctx context.Context) ([]models.ReadingProgress, error) {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
+	cur, err := db.ReadingProgress().Find(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+	var all []models.ReadingProgress
+	if err := cur.All(ctx, &all); err != nil {
+		return nil, err
+	}
+	return all, nil
+}
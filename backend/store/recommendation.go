@@ -0,0 +1,35 @@
+package store
+
+import (
+	"context"
+
+	"github.com/kevinaaaquil/books/backend/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// SaveRecommendations stores r, replacing r.UserID's previous recommendations.
+func (db *DB) SaveRecommendations(ctx context.Context, r *models.UserRecommendations) error {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
+	set := bson.M{"items": r.Items, "computedAt": r.ComputedAt}
+	_, err := db.Recommendations().UpdateOne(ctx, bson.M{"userId": r.UserID}, bson.M{"$set": set}, options.Update().SetUpsert(true))
+	return err
+}
+
+// RecommendationsFor returns the user's recommendations, or nil if none were computed.
+func (db *DB) RecommendationsFor(ctx context.Context, userID primitive.ObjectID) (*models.UserRecommendations, error) {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
+	var r models.UserRecommendations
+	err := db.Recommendations().FindOne(ctx, bson.M{"userId": userID}).Decode(&r)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
@@ -0,0 +1,73 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/kevinaaaquil/books/backend/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func (db *DB) InsertRefreshToken(ctx context.Context, t *models.RefreshToken) error {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
+	if t.ID.IsZero() {
+		t.ID = primitive.NewObjectID()
+	}
+	_, err := db.RefreshTokens().InsertOne(ctx, t)
+	return err
+}
+
+// RefreshTokenByHash returns the token with this hash, or nil if there is none.
+func (db *DB) RefreshTokenByHash(ctx context.Context, tokenHash string) (*models.RefreshToken, error) {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
+	var t models.RefreshToken
+	err := db.RefreshTokens().FindOne(ctx, bson.M{"tokenHash": tokenHash}).Decode(&t)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// UseRefreshToken marks a token used at at, unless it was used or revoked already. Returns whether it did.
+func (db *DB) UseRefreshToken(ctx context.Context, id primitive.ObjectID, at time.Time) (bool, error) {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
+	res, err := db.RefreshTokens().UpdateOne(ctx,
+		bson.M{"_id": id, "usedAt": bson.M{"$exists": false}, "revokedAt": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"usedAt": at}})
+	if err != nil {
+		return false, err
+	}
+	return res.ModifiedCount > 0, nil
+}
+
+// RevokeRefreshTokenFamily revokes the tokens of a sign-in that are not revoked yet.
+func (db *DB) RevokeRefreshTokenFamily(ctx context.Context, family primitive.ObjectID, at time.Time) error {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
+	_, err := db.RefreshTokens().UpdateMany(ctx, bson.M{"family": family, "revokedAt": bson.M{"$exists": false}}, bson.M{"$set": bson.M{"revokedAt": at}})
+	return err
+}
+
+// RevokeUserRefreshTokens revokes every sign-in of the user.
+func (db *DB) RevokeUserRefreshTokens(ctx context.Context, userID primitive.ObjectID, at time.Time) error {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
+	_, err := db.RefreshTokens().UpdateMany(ctx, bson.M{"userId": userID, "revokedAt": bson.M{"$exists": false}}, bson.M{"$set": bson.M{"revokedAt": at}})
+	return err
+}
+
+// DeleteExpiredRefreshTokens removes the tokens that expired before before.
+func (db *DB) DeleteExpiredRefreshTokens(ctx context.Context, before time.Time) error {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
+	_, err := db.RefreshTokens().DeleteMany(ctx, bson.M{"expiresAt": bson.M{"$lt": before}})
+	return err
+}
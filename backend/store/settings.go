@@ -0,0 +1,34 @@
+package store
+
+import (
+	"context"
+
+	"github.com/kevinaaaquil/books/backend/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Settings returns the server-wide settings; defaults when none were saved.
+func (db *DB) Settings(ctx context.Context) (*models.Settings, error) {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
+	var s models.Settings
+	err := db.SettingsCollection().FindOne(ctx, bson.M{"_id": models.SettingsID}).Decode(&s)
+	if err == mongo.ErrNoDocuments {
+		return &models.Settings{ID: models.SettingsID}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// SaveSettings replaces the server-wide settings.
+func (db *DB) SaveSettings(ctx context.Context, s *models.Settings) error {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
+	s.ID = models.SettingsID
+	_, err := db.SettingsCollection().ReplaceOne(ctx, bson.M{"_id": models.SettingsID}, s, options.Replace().SetUpsert(true))
+	return err
+}
@@ -0,0 +1,81 @@
+package store
+
+import (
+	"context"
+
+	"github.com/kevinaaaquil/books/backend/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// StorageUsage sums Book.SizeBytes by format, by uploader and by month added in a single $facet query.
+func (db *DB) StorageUsage(ctx context.Context) (*models.StorageUsage, error) {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
+	sumBy := func(key interface{}) bson.D {
+		return bson.D{{Key: "$group", Value: bson.M{
+			"_id":   key,
+			"bytes": bson.M{"$sum": bson.M{"$ifNull": bson.A{"$sizeBytes", 0}}},
+			"books": bson.M{"$sum": 1},
+		}}}
+	}
+	pipeline := mongo.Pipeline{{{Key: "$facet", Value: bson.M{
+		"total":      bson.A{sumBy(nil)},
+		"byFormat":   bson.A{sumBy("$format"), bson.D{{Key: "$sort", Value: bson.M{"bytes": -1}}}},
+		"byUploader": bson.A{sumBy(bson.M{"$ifNull": bson.A{"$uploadedByEmail", ""}}), bson.D{{Key: "$sort", Value: bson.M{"bytes": -1}}}},
+		"growth": bson.A{
+			sumBy(bson.M{"$dateToString": bson.M{"format": "%Y-%m", "date": "$createdAt"}}),
+			bson.D{{Key: "$sort", Value: bson.M{"_id": 1}}},
+		},
+		"unknown": bson.A{
+			bson.D{{Key: "$match", Value: bson.M{"sizeBytes": bson.M{"$in": bson.A{nil, 0}}}}},
+			bson.D{{Key: "$count", Value: "count"}},
+		},
+	}}}}
+	cur, err := db.BooksForListing().Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+	var facets []struct {
+		Total      []models.StorageBucket      `bson:"total"`
+		ByFormat   []models.StorageBucket      `bson:"byFormat"`
+		ByUploader []models.StorageBucket      `bson:"byUploader"`
+		Growth     []models.StorageGrowthPoint `bson:"growth"`
+		Unknown    []struct {
+			Count int `bson:"count"`
+		} `bson:"unknown"`
+	}
+	if err := cur.All(ctx, &facets); err != nil {
+		return nil, err
+	}
+	usage := &models.StorageUsage{
+		ByFormat:   []models.StorageBucket{},
+		ByUploader: []models.StorageBucket{},
+		Growth:     []models.StorageGrowthPoint{},
+	}
+	if len(facets) == 0 {
+		return usage, nil
+	}
+	f := facets[0]
+	if len(f.Total) > 0 {
+		usage.TotalBytes = f.Total[0].Bytes
+		usage.TotalBooks = f.Total[0].Books
+	}
+	if len(f.Unknown) > 0 {
+		usage.UnknownSizeBooks = f.Unknown[0].Count
+	}
+	if f.ByFormat != nil {
+		usage.ByFormat = f.ByFormat
+	}
+	if f.ByUploader != nil {
+		usage.ByUploader = f.ByUploader
+	}
+	var cumulative int64
+	for _, p := range f.Growth {
+		cumulative += p.AddedBytes
+		p.CumulativeBytes = cumulative
+		usage.Growth = append(usage.Growth, p)
+	}
+	return usage, nil
+}
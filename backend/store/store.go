@@ -0,0 +1,454 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/kevinaaaquil/books/backend/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ErrNotFound is returned by lookups that require the document to exist (BookByID, DeleteBook).
+// Lookups documented as returning nil when missing (UserByID, GetEmailConfig, ...) do not use it.
+var ErrNotFound = errors.New("not found")
+
+// BookStore persists the book catalog.
+type BookStore interface {
+	InsertBook(ctx context.Context, book *models.Book) (primitive.ObjectID, error)
+	AllBooks(ctx context.Context) ([]models.Book, error)
+	BooksVisibleToGuest(ctx context.Context) ([]models.Book, error)
+	// BooksPage returns the page of books q selects and how many match across every page.
+	BooksPage(ctx context.Context, q models.BookQuery) ([]models.Book, int, error)
+	BookByID(ctx context.Context, id primitive.ObjectID) (*models.Book, error)
+	// BookBySHA256 returns a book whose file has the given hex SHA-256, or nil if there is none.
+	BookBySHA256(ctx context.Context, sha256 string) (*models.Book, error)
+	DeleteBook(ctx context.Context, id primitive.ObjectID) (s3Key, coverS3Key string, err error)
+	UpdateBookMetadata(ctx context.Context, id primitive.ObjectID, book *models.Book) error
+	UpdateBookViewByGuest(ctx context.Context, id primitive.ObjectID, viewByGuest bool) error
+	// SetBookContentRating sets a book's content rating; "" goes back to inferring it from the categories.
+	SetBookContentRating(ctx context.Context, id primitive.ObjectID, rating string) error
+	SetBookMetadataError(ctx context.Context, id primitive.ObjectID, msg string) error
+	// SetBookSuggestedCategories records the classifier's suggested categories for a book; nil clears them.
+	SetBookSuggestedCategories(ctx context.Context, id primitive.ObjectID, categories []string) error
+	SetBookCover(ctx context.Context, id primitive.ObjectID, coverS3Key string) error
+	SetBookUploadSteps(ctx context.Context, id primitive.ObjectID, steps []models.UploadStep) error
+	// ForEachBook calls fn for every book, oldest first (ties by ID), stopping at the first error.
+	ForEachBook(ctx context.Context, fn func(*models.Book) error) error
+	UpdateBookFileStatus(ctx context.Context, id primitive.ObjectID, status string, checkedAt time.Time) error
+	SetBookSize(ctx context.Context, id primitive.ObjectID, size int64) error
+	// SetBookStorageClass records the storage class a book's file was moved to ("" = standard, clearing archivedAt).
+	SetBookStorageClass(ctx context.Context, id primitive.ObjectID, class string, archivedAt time.Time) error
+	SetBookRestoreRequested(ctx context.Context, id primitive.ObjectID, at time.Time) error
+	UpdateBookFileInfo(ctx context.Context, id primitive.ObjectID, info models.FileInfo, parseError string) error
+	BooksMissingFileInfoCount(ctx context.Context) (int64, error)
+	ForEachBookMissingFileInfo(ctx context.Context, fn func(*models.Book) error) error
+	BooksCount(ctx context.Context) (int64, error)
+	BooksWithHealthIssues(ctx context.Context) (map[string][]models.BookRef, error)
+	// BookTimeline counts the books q selects by publication decade/year and by month added; its sort and paging
+	// are ignored.
+	BookTimeline(ctx context.Context, q models.BookQuery) (*models.BookTimeline, error)
+	// BooksForGraph returns up to limit books, newest first, with only their title, authors, categories, publishDate
+	// and contentRating set, and whether there were more.
+	BooksForGraph(ctx context.Context, guestOnly bool, limit int) ([]models.Book, bool, error)
+	StorageUsage(ctx context.Context) (*models.StorageUsage, error)
+}
+
+// Book change operations reported by a BookWatcher.
+const (
+	BookInserted = "insert"
+	BookUpdated  = "update"
+	BookDeleted  = "delete"
+)
+
+// BookChange is one write to the books collection.
+type BookChange struct {
+	Op     string
+	BookID primitive.ObjectID
+	Book   *models.Book // the book after the change; nil for deletes
+	Fields []string     // top-level fields an update set or removed; nil when the whole document was replaced
+}
+
+// ErrWatchUnsupported is returned by WatchBooks when the deployment has no change feed (e.g. a standalone mongod).
+var ErrWatchUnsupported = errors.New("change streams not supported")
+
+// BookWatcher reports changes to the books collection as they happen, including those made outside the app (in
+// the mongo shell, by scripts or restores). *DB implements it with change streams, which need a replica set;
+// the docstore backends have no change feed and rely on the app's own write paths.
+type BookWatcher interface {
+	// WatchBooks calls fn for each change until ctx is cancelled, reconnecting and resuming after errors.
+	WatchBooks(ctx context.Context, fn func(BookChange)) error
+}
+
+// UserStore persists user accounts.
+type UserStore interface {
+	UsersCount(ctx context.Context) (int64, error)
+	AdminsCount(ctx context.Context) (int64, error)
+	UserByEmail(ctx context.Context, email string) (*models.User, error)
+	UserByRole(ctx context.Context, role string) (*models.User, error)
+	UsersByRole(ctx context.Context, role string) ([]models.User, error)
+	CreateUser(ctx context.Context, user *models.User) (primitive.ObjectID, error)
+	UserByID(ctx context.Context, id primitive.ObjectID) (*models.User, error)
+	ListUsers(ctx context.Context) ([]models.User, error)
+	UpdateUser(ctx context.Context, id primitive.ObjectID, email *string, hashedPassword *string, role *string) error
+	UpdateUserUseExtractedCover(ctx context.Context, id primitive.ObjectID, useExtractedCover bool) error
+	UpdateUserMaxContentRating(ctx context.Context, id primitive.ObjectID, maxRating string) error
+	UpdateUserWeeklyDigest(ctx context.Context, id primitive.ObjectID, weeklyDigest bool) error
+	// SetUserDigestSentAt records when the user was last sent the weekly digest.
+	SetUserDigestSentAt(ctx context.Context, id primitive.ObjectID, at time.Time) error
+	// SetUserTermsAccepted records that the user accepted version of the terms of use (see models.Terms).
+	SetUserTermsAccepted(ctx context.Context, id primitive.ObjectID, version int, at time.Time) error
+	// SetUserOnboardedAt records when the user finished the first-login walkthrough.
+	SetUserOnboardedAt(ctx context.Context, id primitive.ObjectID, at time.Time) error
+	DeleteUser(ctx context.Context, id primitive.ObjectID) error
+}
+
+// EmailConfigStore persists each user's Kindle/email settings.
+type EmailConfigStore interface {
+	EnsureEmailConfigIndex(ctx context.Context) error
+	GetEmailConfig(ctx context.Context, userID primitive.ObjectID) (*models.EmailConfig, error)
+	UpsertEmailConfig(ctx context.Context, userID primitive.ObjectID, cfg *models.EmailConfig) error
+}
+
+// EmailLogStore records sent emails.
+type EmailLogStore interface {
+	InsertEmailLog(ctx context.Context, log *models.EmailLog) error
+	// EmailLogsSince returns sends at or after since, oldest first.
+	EmailLogsSince(ctx context.Context, since time.Time) ([]models.EmailLog, error)
+	// UserEmailLogsSince is EmailLogsSince for one user.
+	UserEmailLogsSince(ctx context.Context, userID primitive.ObjectID, since time.Time) ([]models.EmailLog, error)
+}
+
+// SystemEmailStore records emails the app sends itself (invites, password resets, notifications).
+type SystemEmailStore interface {
+	InsertSystemEmail(ctx context.Context, e *models.SystemEmail) error
+	RecentSystemEmails(ctx context.Context, limit int64) ([]models.SystemEmail, error)
+}
+
+// DeliveryTargetStore persists users' non-Kindle delivery targets (email addresses and linked drives).
+type DeliveryTargetStore interface {
+	InsertDeliveryTarget(ctx context.Context, t *models.DeliveryTarget) (primitive.ObjectID, error)
+	// DeliveryTargetsForUser returns the user's targets, oldest first.
+	DeliveryTargetsForUser(ctx context.Context, userID primitive.ObjectID) ([]models.DeliveryTarget, error)
+	// DeliveryTarget returns one of the user's targets, or nil if it does not exist or belongs to someone else.
+	DeliveryTarget(ctx context.Context, userID, id primitive.ObjectID) (*models.DeliveryTarget, error)
+	UpdateDeliveryTargetVerification(ctx context.Context, id primitive.ObjectID, v *models.AddressVerification) error
+	// DeleteDeliveryTarget removes one of the user's targets. Returns false if it does not exist.
+	DeleteDeliveryTarget(ctx context.Context, userID, id primitive.ObjectID) (bool, error)
+}
+
+// DeviceStore persists users' reading device profiles.
+type DeviceStore interface {
+	InsertDevice(ctx context.Context, d *models.Device) (primitive.ObjectID, error)
+	// DevicesForUser returns the user's devices, oldest first.
+	DevicesForUser(ctx context.Context, userID primitive.ObjectID) ([]models.Device, error)
+	// Device returns one of the user's devices, or nil if it does not exist or belongs to someone else.
+	Device(ctx context.Context, userID, id primitive.ObjectID) (*models.Device, error)
+	// UpdateDevice replaces the profile of one of d.UserID's devices. Returns false if it does not exist.
+	UpdateDevice(ctx context.Context, d *models.Device) (bool, error)
+	// DeleteDevice removes one of the user's devices. Returns false if it does not exist.
+	DeleteDevice(ctx context.Context, userID, id primitive.ObjectID) (bool, error)
+}
+
+// ReadingProgressStore persists each user's latest position in each book.
+type ReadingProgressStore interface {
+	// UpsertReadingProgress replaces p.UserID's progress in p.BookID.
+	UpsertReadingProgress(ctx context.Context, p *models.ReadingProgress) error
+	// ReadingProgressFor returns the user's progress in the book, or nil if none was recorded.
+	ReadingProgressFor(ctx context.Context, userID, bookID primitive.ObjectID) (*models.ReadingProgress, error)
+	// ReadingProgressByUser returns the user's progress in every book they have opened, most recently read first.
+	ReadingProgressByUser(ctx context.Context, userID primitive.ObjectID) ([]models.ReadingProgress, error)
+	// AllReadingProgress returns every user's progress in every book, in no particular order.
+	AllReadingProgress(ctx context.Context) ([]models.ReadingProgress, error)
+}
+
+// JobStore persists background job runs.
+type JobStore interface {
+	InsertJobRun(ctx context.Context, run *models.JobRun) (primitive.ObjectID, error)
+	UpdateJobRun(ctx context.Context, run *models.JobRun) error
+	JobRunByID(ctx context.Context, id primitive.ObjectID) (*models.JobRun, error)
+	LatestJobRun(ctx context.Context, jobType string) (*models.JobRun, error)
+	ListJobRuns(ctx context.Context, f models.JobRunFilter) ([]models.JobRun, error)
+	RequestJobCancel(ctx context.Context, id primitive.ObjectID) (bool, error)
+}
+
+// QueuedJobStore persists the job queue (see jobs.Queue).
+type QueuedJobStore interface {
+	InsertQueuedJob(ctx context.Context, job *models.QueuedJob) error
+	// QueuedJobByID returns the job, or nil if there is none.
+	QueuedJobByID(ctx context.Context, id primitive.ObjectID) (*models.QueuedJob, error)
+	// ClaimQueuedJob starts an attempt of the job due longest, for instance until leaseUntil: a queued job whose
+	// RunAt has come, or a running one whose lease ran out before now, of one of kinds (nil = any). Nil when none is due.
+	ClaimQueuedJob(ctx context.Context, instance string, kinds []string, now, leaseUntil time.Time) (*models.QueuedJob, error)
+	// UpdateQueuedJob saves the outcome of instance's attempt: status, attempts, RunAt, error, result and times.
+	// It returns false, saving nothing, when the job is no longer running on instance.
+	UpdateQueuedJob(ctx context.Context, job *models.QueuedJob, instance string) (bool, error)
+	// PendingQueuedJobs returns the user's queued and running jobs of kind, oldest first.
+	PendingQueuedJobs(ctx context.Context, userID primitive.ObjectID, kind string) ([]models.QueuedJob, error)
+	// DeleteFinishedQueuedJobs removes jobs that succeeded or failed before before.
+	DeleteFinishedQueuedJobs(ctx context.Context, before time.Time) error
+}
+
+// LockStore holds leases on named tasks so that, with several API instances, only one runs each job or scheduler.
+type LockStore interface {
+	// AcquireLock takes the named lease for owner, or renews it if owner already holds it, until now+ttl.
+	// It returns false if another owner holds a lease that has not expired.
+	AcquireLock(ctx context.Context, name, owner string, now time.Time, ttl time.Duration) (bool, error)
+	// ReleaseLock gives up owner's lease; a lease since taken by another owner is left alone.
+	ReleaseLock(ctx context.Context, name, owner string) error
+}
+
+// NotificationStore persists in-app notifications.
+type NotificationStore interface {
+	InsertNotification(ctx context.Context, n *models.Notification) error
+	NotificationsForUser(ctx context.Context, userID primitive.ObjectID, limit int64) ([]models.Notification, error)
+	MarkNotificationRead(ctx context.Context, userID, id primitive.ObjectID) (bool, error)
+}
+
+// SettingsStore persists the server-wide settings, one document shared by every instance.
+type SettingsStore interface {
+	// Settings returns the settings, with defaults when none were saved.
+	Settings(ctx context.Context) (*models.Settings, error)
+	SaveSettings(ctx context.Context, s *models.Settings) error
+}
+
+// DownloadLinkStore records the download URLs the API issues.
+type DownloadLinkStore interface {
+	InsertDownloadLink(ctx context.Context, l *models.DownloadLink) error
+	// RecentDownloadLinks returns the most recent links, newest first; only bookID's unless it is zero.
+	RecentDownloadLinks(ctx context.Context, bookID primitive.ObjectID, limit int64) ([]models.DownloadLink, error)
+	// PresignedDownloadsByMonth sums the bytes of presigned links issued since since, by month (UTC), oldest first.
+	PresignedDownloadsByMonth(ctx context.Context, since time.Time) ([]models.DownloadMonth, error)
+}
+
+// ImportSourceStore persists the cloud drive folders books are imported from.
+type ImportSourceStore interface {
+	InsertImportSource(ctx context.Context, s *models.ImportSource) (primitive.ObjectID, error)
+	// ImportSources returns every user's sources, oldest first.
+	ImportSources(ctx context.Context) ([]models.ImportSource, error)
+	// ImportSource returns a source, or nil if it does not exist.
+	ImportSource(ctx context.Context, id primitive.ObjectID) (*models.ImportSource, error)
+	// UpdateImportSource sets a source's Name, Folder and AutoSync. Returns false if it does not exist.
+	UpdateImportSource(ctx context.Context, s *models.ImportSource) (bool, error)
+	// SaveImportProgress records the files a sync has dealt with and its outcome. A deleted source stays deleted.
+	SaveImportProgress(ctx context.Context, id primitive.ObjectID, files []models.ImportedFile, last *models.ImportSync) error
+	// DeleteImportSource removes a source. Returns false if it does not exist.
+	DeleteImportSource(ctx context.Context, id primitive.ObjectID) (bool, error)
+}
+
+// TelegramStore persists the Telegram chats linked to users (see models.TelegramChat).
+type TelegramStore interface {
+	// LinkTelegramChat stores c, replacing any link of the same chat or user.
+	LinkTelegramChat(ctx context.Context, c *models.TelegramChat) error
+	// TelegramChat returns the link of a chat, or nil if it is not linked.
+	TelegramChat(ctx context.Context, chatID int64) (*models.TelegramChat, error)
+	// TelegramChatForUser returns the user's linked chat, or nil if there is none.
+	TelegramChatForUser(ctx context.Context, userID primitive.ObjectID) (*models.TelegramChat, error)
+	// UnlinkTelegram removes the user's linked chat. Returns false if there is none.
+	UnlinkTelegram(ctx context.Context, userID primitive.ObjectID) (bool, error)
+}
+
+// APIKeyStore persists users' API keys (see models.APIKey).
+type APIKeyStore interface {
+	InsertAPIKey(ctx context.Context, k *models.APIKey) (primitive.ObjectID, error)
+	// APIKeysForUser returns the user's keys, oldest first.
+	APIKeysForUser(ctx context.Context, userID primitive.ObjectID) ([]models.APIKey, error)
+	// APIKeyByHash returns the key with this hash, or nil if there is none.
+	APIKeyByHash(ctx context.Context, keyHash string) (*models.APIKey, error)
+	// TouchAPIKey records that a key was used at.
+	TouchAPIKey(ctx context.Context, id primitive.ObjectID, at time.Time) error
+	// DeleteAPIKey revokes one of the user's keys. Returns false if it does not exist.
+	DeleteAPIKey(ctx context.Context, userID, id primitive.ObjectID) (bool, error)
+	// RotateAPIKey replaces the secret of one of the user's keys, keeping its name, scopes and expiry. Returns false
+	// if it does not exist.
+	RotateAPIKey(ctx context.Context, userID, id primitive.ObjectID, prefix, keyHash string, at time.Time) (bool, error)
+	// AllAPIKeys returns every user's keys, oldest first.
+	AllAPIKeys(ctx context.Context) ([]models.APIKey, error)
+}
+
+// RefreshTokenStore persists the refresh tokens of sign-ins (see models.RefreshToken).
+type RefreshTokenStore interface {
+	InsertRefreshToken(ctx context.Context, t *models.RefreshToken) error
+	// RefreshTokenByHash returns the token with this hash, or nil if there is none.
+	RefreshTokenByHash(ctx context.Context, tokenHash string) (*models.RefreshToken, error)
+	// UseRefreshToken marks a token used at at, unless it was used or revoked already. Returns whether it did.
+	UseRefreshToken(ctx context.Context, id primitive.ObjectID, at time.Time) (bool, error)
+	// RevokeRefreshTokenFamily revokes the tokens of a sign-in that are not revoked yet.
+	RevokeRefreshTokenFamily(ctx context.Context, family primitive.ObjectID, at time.Time) error
+	// RevokeUserRefreshTokens revokes every sign-in of the user.
+	RevokeUserRefreshTokens(ctx context.Context, userID primitive.ObjectID, at time.Time) error
+	// DeleteExpiredRefreshTokens removes the tokens that expired before before.
+	DeleteExpiredRefreshTokens(ctx context.Context, before time.Time) error
+}
+
+// IPBlockStore persists the addresses and ranges admins refuse (see models.IPBlock).
+type IPBlockStore interface {
+	InsertIPBlock(ctx context.Context, b *models.IPBlock) (primitive.ObjectID, error)
+	// IPBlocks returns the blocks in force at now, oldest first.
+	IPBlocks(ctx context.Context, now time.Time) ([]models.IPBlock, error)
+	// DeleteIPBlock removes a block. Returns false if it does not exist.
+	DeleteIPBlock(ctx context.Context, id primitive.ObjectID) (bool, error)
+	// DeleteExpiredIPBlocks removes the blocks that expired before before.
+	DeleteExpiredIPBlocks(ctx context.Context, before time.Time) error
+}
+
+// SyncPeerStore persists the instances this one mirrors (see models.SyncPeer).
+type SyncPeerStore interface {
+	InsertSyncPeer(ctx context.Context, p *models.SyncPeer) (primitive.ObjectID, error)
+	// SyncPeers returns every peer, oldest first.
+	SyncPeers(ctx context.Context) ([]models.SyncPeer, error)
+	// SaveSyncProgress records how far a sync got and its outcome. A deleted peer stays deleted.
+	SaveSyncProgress(ctx context.Context, id primitive.ObjectID, cursors []models.SyncCursor, progressCursor string, last *models.PeerSync) error
+	// DeleteSyncPeer removes a peer. Returns false if it does not exist.
+	DeleteSyncPeer(ctx context.Context, id primitive.ObjectID) (bool, error)
+}
+
+// CollectionStore persists the collections users shelve books in (see models.Collection).
+type CollectionStore interface {
+	InsertCollection(ctx context.Context, c *models.Collection) (primitive.ObjectID, error)
+	// CollectionsForUser returns the user's collections, oldest first.
+	CollectionsForUser(ctx context.Context, userID primitive.ObjectID) ([]models.Collection, error)
+	// Collection returns one of the user's collections, or nil if it does not exist or belongs to someone else.
+	Collection(ctx context.Context, userID, id primitive.ObjectID) (*models.Collection, error)
+	// RenameCollection renames one of the user's collections. Returns false if it does not exist.
+	RenameCollection(ctx context.Context, userID, id primitive.ObjectID, name string, at time.Time) (bool, error)
+	// AddBookToCollection appends bookID to one of the user's collections, unless it is there already. Returns
+	// false if the collection does not exist.
+	AddBookToCollection(ctx context.Context, userID, id, bookID primitive.ObjectID, at time.Time) (bool, error)
+	// RemoveBookFromCollection takes bookID off one of the user's collections. Returns false if the collection
+	// does not exist.
+	RemoveBookFromCollection(ctx context.Context, userID, id, bookID primitive.ObjectID, at time.Time) (bool, error)
+	// RemoveBookFromCollections takes a deleted book off every collection.
+	RemoveBookFromCollections(ctx context.Context, bookID primitive.ObjectID) error
+	// DeleteCollection removes one of the user's collections. Returns false if it does not exist.
+	DeleteCollection(ctx context.Context, userID, id primitive.ObjectID) (bool, error)
+}
+
+// AudioTrackStore persists books' read-aloud chapters (see models.AudioTrack).
+type AudioTrackStore interface {
+	// UpsertAudioTrack replaces t.BookID's track for t.Chapter, and sets t.ID.
+	UpsertAudioTrack(ctx context.Context, t *models.AudioTrack) error
+	// AudioTracks returns the book's tracks by chapter.
+	AudioTracks(ctx context.Context, bookID primitive.ObjectID) ([]models.AudioTrack, error)
+	// AudioTrack returns the book's track for chapter, or nil if there is none.
+	AudioTrack(ctx context.Context, bookID primitive.ObjectID, chapter int) (*models.AudioTrack, error)
+	// DeleteAudioTracks removes a deleted book's tracks.
+	DeleteAudioTracks(ctx context.Context, bookID primitive.ObjectID) error
+}
+
+// EmbeddingStore persists books' embeddings for semantic search (see models.BookEmbedding).
+type EmbeddingStore interface {
+	// UpsertBookEmbedding replaces e.BookID's embedding, and sets e.ID.
+	UpsertBookEmbedding(ctx context.Context, e *models.BookEmbedding) error
+	// BookEmbedding returns the book's embedding, or nil if it has none.
+	BookEmbedding(ctx context.Context, bookID primitive.ObjectID) (*models.BookEmbedding, error)
+	// BookEmbeddingsSince returns the embeddings updated at or after since; every one for the zero time.
+	BookEmbeddingsSince(ctx context.Context, since time.Time) ([]models.BookEmbedding, error)
+	// DeleteBookEmbedding removes a deleted book's embedding.
+	DeleteBookEmbedding(ctx context.Context, bookID primitive.ObjectID) error
+}
+
+// WishlistStore persists users' wishlists (see models.WishlistItem).
+type WishlistStore interface {
+	InsertWishlistItem(ctx context.Context, item *models.WishlistItem) (primitive.ObjectID, error)
+	// WishlistForUser returns the user's wishlist, newest first.
+	WishlistForUser(ctx context.Context, userID primitive.ObjectID) ([]models.WishlistItem, error)
+	// UpdateWishlistItem replaces one of item.UserID's items. Returns false if it does not exist.
+	UpdateWishlistItem(ctx context.Context, item *models.WishlistItem) (bool, error)
+	// DeleteWishlistItem removes one of the user's items. Returns false if it does not exist.
+	DeleteWishlistItem(ctx context.Context, userID, id primitive.ObjectID) (bool, error)
+	// WatchedWishlistItems returns every user's items with a price watch.
+	WatchedWishlistItems(ctx context.Context) ([]models.WishlistItem, error)
+}
+
+// PriceHistoryStore persists the prices found for watched wishlist items (see models.PricePoint).
+type PriceHistoryStore interface {
+	InsertPricePoint(ctx context.Context, p *models.PricePoint) error
+	// PriceHistory returns the item's most recent prices, newest first.
+	PriceHistory(ctx context.Context, itemID primitive.ObjectID, limit int64) ([]models.PricePoint, error)
+	// DeletePriceHistory removes the item's prices.
+	DeletePriceHistory(ctx context.Context, itemID primitive.ObjectID) error
+}
+
+// PurchaseStore persists the purchase records of books (see models.Purchase), one per book.
+type PurchaseStore interface {
+	// BookPurchase returns the book's purchase record, or nil if it has none.
+	BookPurchase(ctx context.Context, bookID primitive.ObjectID) (*models.Purchase, error)
+	// SaveBookPurchase creates or replaces p.BookID's purchase record.
+	SaveBookPurchase(ctx context.Context, p *models.Purchase) error
+	// DeleteBookPurchase removes the book's purchase record. Returns false if it had none.
+	DeleteBookPurchase(ctx context.Context, bookID primitive.ObjectID) (bool, error)
+	// AllPurchases returns every purchase record, oldest purchase first; records without a date come last.
+	AllPurchases(ctx context.Context) ([]models.Purchase, error)
+}
+
+// RecommendationStore persists each user's latest computed recommendations (see models.UserRecommendations).
+type RecommendationStore interface {
+	// SaveRecommendations stores r, replacing r.UserID's previous recommendations.
+	SaveRecommendations(ctx context.Context, r *models.UserRecommendations) error
+	// RecommendationsFor returns the user's recommendations, or nil if none were computed.
+	RecommendationsFor(ctx context.Context, userID primitive.ObjectID) (*models.UserRecommendations, error)
+}
+
+// NewReleaseStore persists the new releases found for users (see models.NewRelease).
+type NewReleaseStore interface {
+	// AddNewRelease stores r unless r.UserID already has a release with r.Key. Returns whether it was added.
+	AddNewRelease(ctx context.Context, r *models.NewRelease) (bool, error)
+	// NewReleasesForUser returns the user's most recently found releases, newest first.
+	NewReleasesForUser(ctx context.Context, userID primitive.ObjectID, limit int64) ([]models.NewRelease, error)
+}
+
+// BackupStore records backups and exposes raw documents for dumping them.
+type BackupStore interface {
+	InsertBackup(ctx context.Context, b *models.Backup) (primitive.ObjectID, error)
+	ListBackups(ctx context.Context) ([]models.Backup, error)
+	DeleteBackup(ctx context.Context, id primitive.ObjectID) error
+	CollectionNames(ctx context.Context) ([]string, error)
+	// ForEachDocument calls fn with every document in the collection, in Mongo's BSON layout.
+	ForEachDocument(ctx context.Context, collection string, fn func(doc bson.Raw) error) error
+}
+
+// Store is everything the app needs from its database. *DB (MongoDB) and docstore.Store (Postgres, SQLite, memory) implement it.
+type Store interface {
+	BookStore
+	UserStore
+	EmailConfigStore
+	EmailLogStore
+	SystemEmailStore
+	DeliveryTargetStore
+	DeviceStore
+	CollectionStore
+	AudioTrackStore
+	EmbeddingStore
+	ReadingProgressStore
+	JobStore
+	QueuedJobStore
+	LockStore
+	NotificationStore
+	SettingsStore
+	DownloadLinkStore
+	ImportSourceStore
+	TelegramStore
+	APIKeyStore
+	RefreshTokenStore
+	IPBlockStore
+	WishlistStore
+	SyncPeerStore
+	PriceHistoryStore
+	PurchaseStore
+	RecommendationStore
+	NewReleaseStore
+	BackupStore
+
+	// Healthy reports whether the last health check reached the database.
+	Healthy() bool
+	// MonitorHealth checks the database every interval until ctx is cancelled.
+	MonitorHealth(ctx context.Context, interval time.Duration)
+	Disconnect(ctx context.Context) error
+}
+
+var (
+	_ Store       = (*DB)(nil)
+	_ BookWatcher = (*DB)(nil)
+)
@@ -0,0 +1,1508 @@
+// Package storetest is a conformance suite run against every store.Store implementation,
+// so the MongoDB and Postgres backends are held to the same behaviour.
+package storetest
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/kevinaaaquil/books/backend/models"
+	"github.com/kevinaaaquil/books/backend/store"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Run runs the suite. open must return an empty store; it is called once per subtest.
+func Run(t *testing.T, open func(t *testing.T) store.Store) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, ctx context.Context, s store.Store)
+	}{
+		{"Books", testBooks},
+		{"BooksPage", testBooksPage},
+		{"BookUpdates", testBookUpdates},
+		{"BookFileInfo", testBookFileInfo},
+		{"BookReports", testBookReports},
+		{"Users", testUsers},
+		{"EmailConfig", testEmailConfig},
+		{"EmailLogs", testEmailLogs},
+		{"JobRuns", testJobRuns},
+		{"JobQueue", testJobQueue},
+		{"Notifications", testNotifications},
+		{"SystemEmails", testSystemEmails},
+		{"DeliveryTargets", testDeliveryTargets},
+		{"Devices", testDevices},
+		{"Collections", testCollections},
+		{"AudioTracks", testAudioTracks},
+		{"BookEmbeddings", testBookEmbeddings},
+		{"ReadingProgress", testReadingProgress},
+		{"Locks", testLocks},
+		{"Settings", testSettings},
+		{"DownloadLinks", testDownloadLinks},
+		{"ImportSources", testImportSources},
+		{"TelegramChats", testTelegramChats},
+		{"APIKeys", testAPIKeys},
+		{"RefreshTokens", testRefreshTokens},
+		{"Wishlist", testWishlist},
+		{"PriceHistory", testPriceHistory},
+		{"Purchases", testPurchases},
+		{"SyncPeers", testSyncPeers},
+		{"IPBlocks", testIPBlocks},
+		{"Recommendations", testRecommendations},
+		{"NewReleases", testNewReleases},
+		{"Backups", testBackups},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.fn(t, context.Background(), open(t))
+		})
+	}
+}
+
+// day returns a UTC time on the given date, truncated to milliseconds as all backends store it.
+func day(year int, month time.Month, d int) time.Time {
+	return time.Date(year, month, d, 12, 0, 0, 0, time.UTC)
+}
+
+func must(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func insertBook(t *testing.T, ctx context.Context, s store.Store, b models.Book) primitive.ObjectID {
+	t.Helper()
+	id, err := s.InsertBook(ctx, &b)
+	must(t, err)
+	if id.IsZero() {
+		t.Fatal("InsertBook returned a zero ID")
+	}
+	return id
+}
+
+func titles(books []models.Book) []string {
+	out := make([]string, len(books))
+	for i, b := range books {
+		out[i] = b.Title
+	}
+	return out
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func testBooks(t *testing.T, ctx context.Context, s store.Store) {
+	old := insertBook(t, ctx, s, models.Book{Title: "Old", Format: "epub", S3Key: "books/old.epub", CoverS3Key: "books/covers/old.jpg", Authors: []string{"A"}, CreatedAt: day(2023, 1, 1)})
+	insertBook(t, ctx, s, models.Book{Title: "New", Format: "pdf", S3Key: "books/new.pdf", ViewByGuest: true, CreatedAt: day(2024, 1, 1)})
+
+	all, err := s.AllBooks(ctx)
+	must(t, err)
+	if got := titles(all); !equal(got, []string{"New", "Old"}) {
+		t.Errorf("AllBooks = %v, want newest first", got)
+	}
+	guest, err := s.BooksVisibleToGuest(ctx)
+	must(t, err)
+	if got := titles(guest); !equal(got, []string{"New"}) {
+		t.Errorf("BooksVisibleToGuest = %v", got)
+	}
+	n, err := s.BooksCount(ctx)
+	must(t, err)
+	if n != 2 {
+		t.Errorf("BooksCount = %d, want 2", n)
+	}
+
+	b, err := s.BookByID(ctx, old)
+	must(t, err)
+	if b.ID != old || b.Title != "Old" || len(b.Authors) != 1 || !b.CreatedAt.Equal(day(2023, 1, 1)) {
+		t.Errorf("BookByID = %+v", b)
+	}
+	if _, err := s.BookByID(ctx, primitive.NewObjectID()); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("BookByID(missing) err = %v, want ErrNotFound", err)
+	}
+
+	var visited []string
+	must(t, s.ForEachBook(ctx, func(b *models.Book) error {
+		visited = append(visited, b.Title)
+		return nil
+	}))
+	if !equal(visited, []string{"Old", "New"}) {
+		t.Errorf("ForEachBook visited %v, want oldest first", visited)
+	}
+
+	key, cover, err := s.DeleteBook(ctx, old)
+	must(t, err)
+	if key != "books/old.epub" || cover != "books/covers/old.jpg" {
+		t.Errorf("DeleteBook = %q, %q", key, cover)
+	}
+	if _, _, err := s.DeleteBook(ctx, old); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("DeleteBook(again) err = %v, want ErrNotFound", err)
+	}
+}
+
+func testBooksPage(t *testing.T, ctx context.Context, s store.Store) {
+	emma := insertBook(t, ctx, s, models.Book{Title: "Emma", Authors: []string{"jane Austen"}, Categories: []string{"Juvenile Fiction"}, RatingAverage: 4, ViewByGuest: true, CreatedAt: day(2024, 1, 1)})
+	dune := insertBook(t, ctx, s, models.Book{Title: "dune", Authors: []string{"Frank Herbert"}, Categories: []string{"Young Adult Fiction"}, RatingAverage: 4.5, FileInfo: models.FileInfo{Accessibility: &models.Accessibility{Features: []string{"alternativeText"}}}, CreatedAt: day(2024, 1, 2)})
+	insertBook(t, ctx, s, models.Book{Title: "Beloved", Authors: []string{"Toni Morrison"}, Categories: []string{"Fiction / Adult"}, RatingAverage: 4, RatingCount: 10, ViewByGuest: true, CreatedAt: day(2024, 1, 3)})
+	insertBook(t, ctx, s, models.Book{Title: "Anathem", ContentRating: models.ContentRatingAll, CreatedAt: day(2024, 1, 4)})
+	insertBook(t, ctx, s, models.Book{Title: "Unrated", CreatedAt: day(2024, 1, 5)})
+
+	for _, tt := range []struct {
+		name  string
+		q     models.BookQuery
+		want  []string
+		total int
+	}{
+		{"newest", models.BookQuery{Desc: true}, []string{"Unrated", "Anathem", "Beloved", "dune", "Emma"}, 5},
+		{"title", models.BookQuery{Sort: models.BookSortTitle}, []string{"Anathem", "Beloved", "dune", "Emma", "Unrated"}, 5},
+		{"author", models.BookQuery{Sort: models.BookSortAuthor, Limit: 3}, []string{"Anathem", "Unrated", "dune"}, 5},
+		{"rating", models.BookQuery{Sort: models.BookSortRating, Desc: true, Limit: 3}, []string{"dune", "Beloved", "Emma"}, 5},
+		{"page", models.BookQuery{Sort: models.BookSortTitle, Offset: 2, Limit: 2}, []string{"dune", "Emma"}, 5},
+		{"past the end", models.BookQuery{Sort: models.BookSortTitle, Offset: 10, Limit: 2}, []string{}, 5},
+		{"guest", models.BookQuery{GuestOnly: true, Sort: models.BookSortTitle}, []string{"Beloved", "Emma"}, 2},
+		{"teen", models.BookQuery{MaxContentRating: models.ContentRatingTeen, Sort: models.BookSortTitle}, []string{"Anathem", "dune", "Emma"}, 3},
+		{"all", models.BookQuery{MaxContentRating: models.ContentRatingAll, Sort: models.BookSortTitle}, []string{"Anathem", "Emma"}, 2},
+		{"accessibility", models.BookQuery{Accessibility: []string{"ALTERNATIVETEXT"}}, []string{"dune"}, 1},
+		{"ids", models.BookQuery{IDs: []primitive.ObjectID{emma, dune}, Sort: models.BookSortTitle}, []string{"dune", "Emma"}, 2},
+		{"no ids", models.BookQuery{IDs: []primitive.ObjectID{}}, []string{}, 0},
+	} {
+		books, total, err := s.BooksPage(ctx, tt.q)
+		must(t, err)
+		if got := titles(books); !equal(got, tt.want) || total != tt.total {
+			t.Errorf("%s: BooksPage = %v, %d; want %v, %d", tt.name, got, total, tt.want, tt.total)
+		}
+	}
+}
+
+func testBookUpdates(t *testing.T, ctx context.Context, s store.Store) {
+	id := insertBook(t, ctx, s, models.Book{Title: "Draft", Format: "epub", S3Key: "k", CreatedAt: day(2024, 2, 1)})
+
+	must(t, s.UpdateBookMetadata(ctx, id, &models.Book{Title: "Final", Authors: []string{"X", "Y"}, ISBN: "978", PageCount: 10, RatingAverage: 4.5}))
+	must(t, s.UpdateBookViewByGuest(ctx, id, true))
+	must(t, s.SetBookContentRating(ctx, id, models.ContentRatingTeen))
+	must(t, s.SetBookMetadataError(ctx, id, "lookup failed"))
+	checked := day(2024, 3, 1)
+	must(t, s.UpdateBookFileStatus(ctx, id, models.FileStatusMissing, checked))
+	must(t, s.SetBookSize(ctx, id, 2048))
+	must(t, s.SetBookCover(ctx, id, "covers/c.png"))
+	steps := []models.UploadStep{{Name: models.UploadStepStoreFile, Status: models.UploadStepDone, Attempts: 1}, {Name: models.UploadStepMetadata, Status: models.UploadStepFailed, Attempts: 3, Error: "503"}}
+	must(t, s.SetBookUploadSteps(ctx, id, steps))
+
+	b, err := s.BookByID(ctx, id)
+	must(t, err)
+	if b.Title != "Final" || len(b.Authors) != 2 || b.ISBN != "978" || b.PageCount != 10 || b.RatingAverage != 4.5 {
+		t.Errorf("metadata not updated: %+v", b)
+	}
+	if b.S3Key != "k" || b.Format != "epub" {
+		t.Errorf("UpdateBookMetadata changed file fields: %+v", b)
+	}
+	if !b.ViewByGuest || b.ContentRating != models.ContentRatingTeen || b.MetadataError != "lookup failed" || b.SizeBytes != 2048 {
+		t.Errorf("updates not applied: %+v", b)
+	}
+	if b.FileStatus != models.FileStatusMissing || b.FileCheckedAt == nil || !b.FileCheckedAt.Equal(checked) {
+		t.Errorf("file status = %q at %v", b.FileStatus, b.FileCheckedAt)
+	}
+	if b.CoverS3Key != "covers/c.png" || len(b.UploadSteps) != 2 || b.UploadSteps[1] != steps[1] {
+		t.Errorf("cover %q, upload steps %+v", b.CoverS3Key, b.UploadSteps)
+	}
+
+	// Cold storage: archiving records the class; moving back to standard clears it and any restore.
+	must(t, s.SetBookStorageClass(ctx, id, "GLACIER", day(2024, 4, 1)))
+	must(t, s.SetBookRestoreRequested(ctx, id, day(2024, 5, 1)))
+	b, err = s.BookByID(ctx, id)
+	must(t, err)
+	if b.StorageClass != "GLACIER" || b.ArchivedAt == nil || !b.ArchivedAt.Equal(day(2024, 4, 1)) || b.RestoreRequestedAt == nil || !b.RestoreRequestedAt.Equal(day(2024, 5, 1)) {
+		t.Errorf("archived = %q at %v, restore %v", b.StorageClass, b.ArchivedAt, b.RestoreRequestedAt)
+	}
+	must(t, s.SetBookStorageClass(ctx, id, "", time.Time{}))
+	b, err = s.BookByID(ctx, id)
+	must(t, err)
+	if b.StorageClass != "" || b.ArchivedAt != nil || b.RestoreRequestedAt != nil {
+		t.Errorf("unarchived = %q at %v, restore %v", b.StorageClass, b.ArchivedAt, b.RestoreRequestedAt)
+	}
+
+	must(t, s.SetBookSuggestedCategories(ctx, id, []string{"History", "Science"}))
+	b, err = s.BookByID(ctx, id)
+	must(t, err)
+	if !equal(b.SuggestedCategories, []string{"History", "Science"}) {
+		t.Errorf("suggested categories = %v", b.SuggestedCategories)
+	}
+	must(t, s.SetBookSuggestedCategories(ctx, id, nil))
+	b, err = s.BookByID(ctx, id)
+	must(t, err)
+	if len(b.SuggestedCategories) != 0 {
+		t.Errorf("suggested categories after clearing = %v", b.SuggestedCategories)
+	}
+
+	// Updating a missing book is not an error, as with MongoDB's UpdateOne.
+	must(t, s.SetBookSize(ctx, primitive.NewObjectID(), 1))
+}
+
+func testBookFileInfo(t *testing.T, ctx context.Context, s store.Store) {
+	insertBook(t, ctx, s, models.Book{Title: "Done", FileInfo: models.FileInfo{SizeBytes: 100, SHA256: "abc"}, CreatedAt: day(2024, 1, 1)})
+	noHash := insertBook(t, ctx, s, models.Book{Title: "NoHash", FileInfo: models.FileInfo{SizeBytes: 100}, CreatedAt: day(2024, 1, 2)})
+	insertBook(t, ctx, s, models.Book{Title: "Nothing", CreatedAt: day(2024, 1, 3)})
+
+	n, err := s.BooksMissingFileInfoCount(ctx)
+	must(t, err)
+	if n != 2 {
+		t.Errorf("BooksMissingFileInfoCount = %d, want 2", n)
+	}
+	var visited []string
+	must(t, s.ForEachBookMissingFileInfo(ctx, func(b *models.Book) error {
+		visited = append(visited, b.Title)
+		return nil
+	}))
+	if !equal(visited, []string{"NoHash", "Nothing"}) {
+		t.Errorf("ForEachBookMissingFileInfo visited %v", visited)
+	}
+
+	info := models.FileInfo{SizeBytes: 300, SHA256: "def", WordCount: 42, Accessibility: &models.Accessibility{Features: []string{"alternativeText"}, AccessModes: []string{"textual"}}}
+	must(t, s.UpdateBookFileInfo(ctx, noHash, info, "bad opf"))
+	b, err := s.BookByID(ctx, noHash)
+	must(t, err)
+	if !reflect.DeepEqual(b.FileInfo, info) || b.ParseError != "bad opf" {
+		t.Errorf("UpdateBookFileInfo: got %+v, parseError %q", b.FileInfo, b.ParseError)
+	}
+	n, err = s.BooksMissingFileInfoCount(ctx)
+	must(t, err)
+	if n != 1 {
+		t.Errorf("BooksMissingFileInfoCount after update = %d, want 1", n)
+	}
+
+	if b, err := s.BookBySHA256(ctx, "def"); err != nil || b == nil || b.ID != noHash {
+		t.Errorf("BookBySHA256(def) = %+v, %v", b, err)
+	}
+	if b, err := s.BookBySHA256(ctx, "unknown"); err != nil || b != nil {
+		t.Errorf("BookBySHA256(unknown) = %+v, %v", b, err)
+	}
+}
+
+func testBookReports(t *testing.T, ctx context.Context, s store.Store) {
+	healthy := models.Book{Title: "Healthy", Format: "epub", ISBN: "1", PageCount: 5, CoverURL: "http://c", PublishDate: "1994-05-01",
+		UploadedByEmail: "a@x", FileInfo: models.FileInfo{SizeBytes: 50000, SHA256: "h"}, ViewByGuest: true, CreatedAt: day(2024, 1, 5)}
+	broken := models.Book{Title: "Broken", Format: "epub", ParseError: "zip", MetadataError: "404", PublishDate: "1999", ContentRating: models.ContentRatingTeen,
+		UploadedByEmail: "b@x", FileInfo: models.FileInfo{SizeBytes: 100}, FileStatus: models.FileStatusCorrupted, CreatedAt: day(2024, 1, 20),
+		UploadSteps: []models.UploadStep{{Name: models.UploadStepCover, Status: models.UploadStepFailed}}}
+	undated := models.Book{Title: "Undated", Format: "pdf", ISBN: "2", PageCount: 1, CoverS3Key: "c", UploadedByEmail: "a@x", CreatedAt: day(2024, 3, 1)}
+	for _, b := range []models.Book{healthy, broken, undated} {
+		insertBook(t, ctx, s, b)
+	}
+
+	issues, err := s.BooksWithHealthIssues(ctx)
+	must(t, err)
+	want := map[string][]string{
+		models.IssueMissingCover:   {"Broken"},
+		models.IssueMissingISBN:    {"Broken"},
+		models.IssueZeroPageCount:  {"Broken"},
+		models.IssueMetadataFailed: {"Broken"},
+		models.IssueUnreadableEPUB: {"Broken"},
+		models.IssueSmallFile:      {"Broken"},
+		models.IssueStorageProblem: {"Broken"},
+		models.IssueUploadFailed:   {"Broken"},
+	}
+	for issue, wantTitles := range want {
+		var got []string
+		for _, ref := range issues[issue] {
+			got = append(got, ref.Title)
+		}
+		if !equal(got, wantTitles) {
+			t.Errorf("health issue %s = %v, want %v", issue, got, wantTitles)
+		}
+	}
+
+	timeline, err := s.BookTimeline(ctx, models.BookQuery{})
+	must(t, err)
+	if timeline.Undated != 1 || len(timeline.PublishedByDecade) != 1 || timeline.PublishedByDecade[0] != (models.TimelineBucket{Period: "1990s", Count: 2}) {
+		t.Errorf("timeline decades = %+v, undated %d", timeline.PublishedByDecade, timeline.Undated)
+	}
+	if len(timeline.PublishedByYear) != 2 || timeline.PublishedByYear[0].Period != "1994" {
+		t.Errorf("timeline years = %+v", timeline.PublishedByYear)
+	}
+	wantMonths := []models.TimelineBucket{{Period: "2024-01", Count: 2}, {Period: "2024-03", Count: 1}}
+	if len(timeline.AddedByMonth) != 2 || timeline.AddedByMonth[0] != wantMonths[0] || timeline.AddedByMonth[1] != wantMonths[1] {
+		t.Errorf("timeline months = %+v", timeline.AddedByMonth)
+	}
+	guestTimeline, err := s.BookTimeline(ctx, models.BookQuery{GuestOnly: true})
+	must(t, err)
+	if len(guestTimeline.PublishedByYear) != 1 || guestTimeline.Undated != 0 {
+		t.Errorf("guest timeline = %+v", guestTimeline)
+	}
+	teenTimeline, err := s.BookTimeline(ctx, models.BookQuery{MaxContentRating: models.ContentRatingTeen})
+	must(t, err)
+	if len(teenTimeline.PublishedByYear) != 1 || teenTimeline.PublishedByYear[0].Period != "1999" || teenTimeline.Undated != 0 {
+		t.Errorf("teen timeline = %+v", teenTimeline)
+	}
+
+	graphBooks, more, err := s.BooksForGraph(ctx, false, 2)
+	must(t, err)
+	if len(graphBooks) != 2 || !more || graphBooks[0].Title != "Undated" || graphBooks[1].Title != "Broken" {
+		t.Fatalf("BooksForGraph = %+v, more %v", graphBooks, more)
+	}
+	if g := graphBooks[0]; g.ID.IsZero() || g.PublishDate != "" || g.Format != "" || g.CoverS3Key != "" {
+		t.Errorf("BooksForGraph returned more than the graph fields: %+v", g)
+	}
+	graphBooks, more, err = s.BooksForGraph(ctx, true, 10)
+	must(t, err)
+	if len(graphBooks) != 1 || more || graphBooks[0].PublishDate != "1994-05-01" {
+		t.Errorf("guest BooksForGraph = %+v, more %v", graphBooks, more)
+	}
+
+	usage, err := s.StorageUsage(ctx)
+	must(t, err)
+	if usage.TotalBytes != 50100 || usage.TotalBooks != 3 || usage.UnknownSizeBooks != 1 {
+		t.Errorf("usage totals = %+v", usage)
+	}
+	if len(usage.ByFormat) != 2 || usage.ByFormat[0] != (models.StorageBucket{Key: "epub", Bytes: 50100, Books: 2}) {
+		t.Errorf("usage by format = %+v", usage.ByFormat)
+	}
+	if len(usage.ByUploader) != 2 || usage.ByUploader[0].Key != "a@x" {
+		t.Errorf("usage by uploader = %+v", usage.ByUploader)
+	}
+	if len(usage.Growth) != 2 || usage.Growth[1].CumulativeBytes != 50100 || usage.Growth[0].Books != 2 {
+		t.Errorf("usage growth = %+v", usage.Growth)
+	}
+}
+
+func testUsers(t *testing.T, ctx context.Context, s store.Store) {
+	admin := &models.User{Email: "admin@x", Password: "hash", Role: models.RoleAdmin, CreatedAt: day(2024, 1, 1)}
+	adminID, err := s.CreateUser(ctx, admin)
+	must(t, err)
+	viewerID, err := s.CreateUser(ctx, &models.User{Email: "viewer@x", Role: models.RoleViewer, CreatedAt: day(2024, 1, 2)})
+	must(t, err)
+
+	if n, err := s.UsersCount(ctx); err != nil || n != 2 {
+		t.Errorf("UsersCount = %d, %v", n, err)
+	}
+	if n, err := s.AdminsCount(ctx); err != nil || n != 1 {
+		t.Errorf("AdminsCount = %d, %v", n, err)
+	}
+	u, err := s.UserByEmail(ctx, "admin@x")
+	must(t, err)
+	if u == nil || u.ID != adminID || u.Password != "hash" {
+		t.Errorf("UserByEmail = %+v", u)
+	}
+	if u, err := s.UserByEmail(ctx, "nobody@x"); err != nil || u != nil {
+		t.Errorf("UserByEmail(missing) = %+v, %v; want nil, nil", u, err)
+	}
+	if u, err := s.UserByRole(ctx, models.RoleViewer); err != nil || u == nil || u.ID != viewerID {
+		t.Errorf("UserByRole = %+v, %v", u, err)
+	}
+	if u, err := s.UserByID(ctx, primitive.NewObjectID()); err != nil || u != nil {
+		t.Errorf("UserByID(missing) = %+v, %v; want nil, nil", u, err)
+	}
+	admins, err := s.UsersByRole(ctx, models.RoleAdmin)
+	must(t, err)
+	if len(admins) != 1 {
+		t.Errorf("UsersByRole = %d users", len(admins))
+	}
+
+	email, role := "new@x", models.RoleEditor
+	must(t, s.UpdateUser(ctx, viewerID, &email, nil, &role))
+	must(t, s.UpdateUserUseExtractedCover(ctx, viewerID, true))
+	must(t, s.UpdateUserMaxContentRating(ctx, viewerID, models.ContentRatingAll))
+	must(t, s.UpdateUserWeeklyDigest(ctx, viewerID, true))
+	digestAt := time.Now().UTC().Truncate(time.Millisecond)
+	must(t, s.SetUserDigestSentAt(ctx, viewerID, digestAt))
+	must(t, s.SetUserTermsAccepted(ctx, viewerID, 2, digestAt))
+	must(t, s.SetUserOnboardedAt(ctx, viewerID, digestAt))
+	u, err = s.UserByID(ctx, viewerID)
+	must(t, err)
+	if u.Email != email || u.Role != role || !u.UseExtractedCover || u.MaxContentRating != models.ContentRatingAll || !u.WeeklyDigest || u.DigestSentAt == nil || !u.DigestSentAt.Equal(digestAt) {
+		t.Errorf("updated user = %+v", u)
+	}
+	if u.TermsVersion != 2 || u.TermsAcceptedAt == nil || !u.TermsAcceptedAt.Equal(digestAt) || u.OnboardedAt == nil || !u.OnboardedAt.Equal(digestAt) {
+		t.Errorf("terms and onboarding = %d, %v, %v", u.TermsVersion, u.TermsAcceptedAt, u.OnboardedAt)
+	}
+
+	users, err := s.ListUsers(ctx)
+	must(t, err)
+	if len(users) != 2 || users[0].ID != adminID {
+		t.Errorf("ListUsers not oldest first: %+v", users)
+	}
+	must(t, s.DeleteUser(ctx, viewerID))
+	if u, _ := s.UserByID(ctx, viewerID); u != nil {
+		t.Error("user still exists after DeleteUser")
+	}
+}
+
+func testEmailConfig(t *testing.T, ctx context.Context, s store.Store) {
+	must(t, s.EnsureEmailConfigIndex(ctx))
+	userID := primitive.NewObjectID()
+	if cfg, err := s.GetEmailConfig(ctx, userID); err != nil || cfg != nil {
+		t.Fatalf("GetEmailConfig(none) = %+v, %v", cfg, err)
+	}
+	must(t, s.UpsertEmailConfig(ctx, userID, &models.EmailConfig{SenderMail: "a@icloud", KindleMail: "k@kindle"}))
+	must(t, s.UpsertEmailConfig(ctx, userID, &models.EmailConfig{SenderMail: "b@icloud", KindleMail: "k@kindle"}))
+	cfg, err := s.GetEmailConfig(ctx, userID)
+	must(t, err)
+	if cfg == nil || cfg.UserID != userID || cfg.SenderMail != "b@icloud" || cfg.KindleMail != "k@kindle" {
+		t.Errorf("GetEmailConfig = %+v", cfg)
+	}
+	if !cfg.KindleVerified() {
+		t.Error("config without verification should count as verified")
+	}
+	sentAt := day(2024, 1, 2)
+	must(t, s.UpsertEmailConfig(ctx, userID, &models.EmailConfig{SenderMail: "b@icloud", KindleMail: "k2@kindle", KindleVerification: &models.AddressVerification{CodeHash: "abc", SentAt: &sentAt, Attempts: 2}}))
+	cfg, err = s.GetEmailConfig(ctx, userID)
+	must(t, err)
+	if v := cfg.KindleVerification; cfg.KindleVerified() || v.CodeHash != "abc" || v.Attempts != 2 || !v.SentAt.Equal(sentAt) {
+		t.Errorf("GetEmailConfig verification = %+v", v)
+	}
+	must(t, s.UpsertEmailConfig(ctx, userID, &models.EmailConfig{SenderMail: "b@icloud", KindleMail: "k2@kindle"}))
+	if cfg, err = s.GetEmailConfig(ctx, userID); err != nil || cfg.KindleVerification != nil {
+		t.Errorf("verification not cleared: %+v, %v", cfg, err)
+	}
+	if other, err := s.GetEmailConfig(ctx, primitive.NewObjectID()); err != nil || other != nil {
+		t.Errorf("GetEmailConfig(other user) = %+v, %v", other, err)
+	}
+	must(t, s.InsertEmailLog(ctx, &models.EmailLog{UserID: userID, BookID: primitive.NewObjectID(), SentAt: day(2024, 1, 1)}))
+}
+
+func testEmailLogs(t *testing.T, ctx context.Context, s store.Store) {
+	userID, otherID := primitive.NewObjectID(), primitive.NewObjectID()
+	must(t, s.InsertEmailLog(ctx, &models.EmailLog{UserID: userID, FileTitle: "old", SentAt: day(2024, 1, 1)}))
+	must(t, s.InsertEmailLog(ctx, &models.EmailLog{UserID: userID, FileTitle: "b", SentAt: day(2024, 1, 3)}))
+	must(t, s.InsertEmailLog(ctx, &models.EmailLog{UserID: userID, FileTitle: "a", SentAt: day(2024, 1, 2)}))
+	must(t, s.InsertEmailLog(ctx, &models.EmailLog{UserID: otherID, FileTitle: "other", SentAt: day(2024, 1, 2)}))
+
+	logs, err := s.EmailLogsSince(ctx, day(2024, 1, 2))
+	must(t, err)
+	if len(logs) != 3 || logs[2].FileTitle != "b" {
+		t.Errorf("EmailLogsSince = %+v", logs)
+	}
+	logs, err = s.UserEmailLogsSince(ctx, userID, day(2024, 1, 2))
+	must(t, err)
+	if len(logs) != 2 || logs[0].FileTitle != "a" || logs[1].FileTitle != "b" {
+		t.Errorf("UserEmailLogsSince = %+v", logs)
+	}
+}
+
+func testJobRuns(t *testing.T, ctx context.Context, s store.Store) {
+	first := &models.JobRun{Type: "verify", Status: models.JobStatusSucceeded, StartedAt: day(2024, 1, 1)}
+	_, err := s.InsertJobRun(ctx, first)
+	must(t, err)
+	run := &models.JobRun{Type: "verify", Status: models.JobStatusRunning, StartedAt: day(2024, 2, 1)}
+	id, err := s.InsertJobRun(ctx, run)
+	must(t, err)
+	run.ID = id
+
+	finished := day(2024, 2, 2)
+	run.Status, run.Total, run.Processed, run.Summary, run.FinishedAt = models.JobStatusFailed, 10, 4, map[string]int{"ok": 4}, &finished
+	run.Error = "boom"
+	must(t, s.UpdateJobRun(ctx, run))
+
+	got, err := s.JobRunByID(ctx, id)
+	must(t, err)
+	if got == nil || got.Status != models.JobStatusFailed || got.Processed != 4 || got.Summary["ok"] != 4 || got.Error != "boom" || got.FinishedAt == nil {
+		t.Errorf("JobRunByID = %+v", got)
+	}
+	if got, err := s.JobRunByID(ctx, primitive.NewObjectID()); err != nil || got != nil {
+		t.Errorf("JobRunByID(missing) = %+v, %v", got, err)
+	}
+	latest, err := s.LatestJobRun(ctx, "verify")
+	must(t, err)
+	if latest == nil || latest.ID != id {
+		t.Errorf("LatestJobRun = %+v", latest)
+	}
+	if latest, err := s.LatestJobRun(ctx, "never"); err != nil || latest != nil {
+		t.Errorf("LatestJobRun(never) = %+v, %v", latest, err)
+	}
+
+	// History, newest first, filtered by type, status and start time; logs are saved with progress.
+	other := &models.JobRun{Type: "backup", Status: models.JobStatusRunning, StartedAt: day(2024, 3, 1)}
+	otherID, err := s.InsertJobRun(ctx, other)
+	must(t, err)
+	other.ID = otherID
+	other.Log = []models.JobLogLine{{Time: day(2024, 3, 1), Message: "started"}}
+	must(t, s.UpdateJobRun(ctx, other))
+	runs, err := s.ListJobRuns(ctx, models.JobRunFilter{})
+	must(t, err)
+	if len(runs) != 3 || runs[0].ID != otherID || runs[2].Type != "verify" || len(runs[0].Log) != 1 || runs[0].Log[0].Message != "started" {
+		t.Errorf("ListJobRuns = %+v", runs)
+	}
+	before, since := day(2024, 2, 15), day(2024, 2, 1)
+	for f, want := range map[*models.JobRunFilter]int{
+		{Type: "verify"}: 2, {Status: models.JobStatusFailed}: 1, {Before: &before}: 2, {Limit: 1}: 1, {Type: "verify", Status: models.JobStatusRunning}: 0,
+		{Since: &since}: 2, {Since: &since, Before: &before}: 1,
+	} {
+		if runs, err := s.ListJobRuns(ctx, *f); err != nil || len(runs) != want {
+			t.Errorf("ListJobRuns(%+v) = %d runs, %v; want %d", *f, len(runs), err, want)
+		}
+	}
+
+	// Only running runs can be cancelled.
+	if ok, err := s.RequestJobCancel(ctx, otherID); err != nil || !ok {
+		t.Errorf("RequestJobCancel(running) = %v, %v", ok, err)
+	}
+	if got, _ := s.JobRunByID(ctx, otherID); got == nil || !got.CancelRequested {
+		t.Errorf("cancelled run = %+v", got)
+	}
+	for _, id := range []primitive.ObjectID{id, primitive.NewObjectID()} {
+		if ok, err := s.RequestJobCancel(ctx, id); err != nil || ok {
+			t.Errorf("RequestJobCancel(%s) = %v, %v", id.Hex(), ok, err)
+		}
+	}
+}
+
+func testNotifications(t *testing.T, ctx context.Context, s store.Store) {
+	userID, otherID := primitive.NewObjectID(), primitive.NewObjectID()
+	for i := 1; i <= 3; i++ {
+		must(t, s.InsertNotification(ctx, &models.Notification{UserID: userID, Title: string(rune('0' + i)), CreatedAt: day(2024, 1, i)}))
+	}
+	must(t, s.InsertNotification(ctx, &models.Notification{UserID: otherID, Title: "other", CreatedAt: day(2024, 1, 9)}))
+
+	list, err := s.NotificationsForUser(ctx, userID, 2)
+	must(t, err)
+	if len(list) != 2 || list[0].Title != "3" || list[1].Title != "2" {
+		t.Fatalf("NotificationsForUser = %+v", list)
+	}
+	if found, err := s.MarkNotificationRead(ctx, otherID, list[0].ID); err != nil || found {
+		t.Errorf("MarkNotificationRead by another user = %v, %v; want false", found, err)
+	}
+	found, err := s.MarkNotificationRead(ctx, userID, list[0].ID)
+	must(t, err)
+	if !found {
+		t.Error("MarkNotificationRead = false, want true")
+	}
+	list, err = s.NotificationsForUser(ctx, userID, 10)
+	must(t, err)
+	if len(list) != 3 || list[0].ReadAt == nil || list[1].ReadAt != nil {
+		t.Errorf("after MarkNotificationRead: %+v", list)
+	}
+}
+
+func testSystemEmails(t *testing.T, ctx context.Context, s store.Store) {
+	must(t, s.InsertSystemEmail(ctx, &models.SystemEmail{Template: "invite", ToEmail: "a@example.com", Status: models.SystemEmailSent, SentAt: day(2024, 1, 1)}))
+	must(t, s.InsertSystemEmail(ctx, &models.SystemEmail{Template: "password_reset", ToEmail: "b@example.com", Status: models.SystemEmailFailed, Error: "refused", SentAt: day(2024, 1, 3)}))
+	must(t, s.InsertSystemEmail(ctx, &models.SystemEmail{Template: "notification", ToEmail: "c@example.com", Status: models.SystemEmailSent, SentAt: day(2024, 1, 2)}))
+
+	list, err := s.RecentSystemEmails(ctx, 2)
+	must(t, err)
+	if len(list) != 2 || list[0].ToEmail != "b@example.com" || list[1].ToEmail != "c@example.com" {
+		t.Fatalf("RecentSystemEmails = %+v", list)
+	}
+	if list[0].Error != "refused" || !list[0].SentAt.Equal(day(2024, 1, 3)) {
+		t.Errorf("RecentSystemEmails[0] = %+v", list[0])
+	}
+}
+
+func testDeliveryTargets(t *testing.T, ctx context.Context, s store.Store) {
+	userID, otherID := primitive.NewObjectID(), primitive.NewObjectID()
+	sent := day(2024, 1, 5)
+	emailID, err := s.InsertDeliveryTarget(ctx, &models.DeliveryTarget{
+		UserID: userID, Kind: models.TargetEmail, Name: "Work", Email: "me@work.example", CreatedAt: day(2024, 1, 2),
+		Verification: &models.AddressVerification{CodeHash: "abc", SentAt: &sent},
+	})
+	must(t, err)
+	driveID, err := s.InsertDeliveryTarget(ctx, &models.DeliveryTarget{
+		UserID: userID, Kind: models.TargetDropbox, Name: "Dropbox", Folder: "/Books", RefreshToken: "secret", CreatedAt: day(2024, 1, 1),
+	})
+	must(t, err)
+	_, err = s.InsertDeliveryTarget(ctx, &models.DeliveryTarget{UserID: otherID, Kind: models.TargetEmail, Email: "x@example.com", CreatedAt: day(2024, 1, 3)})
+	must(t, err)
+
+	list, err := s.DeliveryTargetsForUser(ctx, userID)
+	must(t, err)
+	if len(list) != 2 || list[0].ID != driveID || list[1].ID != emailID {
+		t.Fatalf("DeliveryTargetsForUser = %+v", list)
+	}
+	if list[0].RefreshToken != "secret" || list[0].Folder != "/Books" {
+		t.Errorf("drive target = %+v", list[0])
+	}
+	if got, err := s.DeliveryTarget(ctx, otherID, emailID); err != nil || got != nil {
+		t.Errorf("DeliveryTarget by another user = %+v, %v; want nil", got, err)
+	}
+	got, err := s.DeliveryTarget(ctx, userID, emailID)
+	must(t, err)
+	if got == nil || got.Verification == nil || got.Verification.CodeHash != "abc" || got.Ready() {
+		t.Fatalf("DeliveryTarget = %+v", got)
+	}
+
+	must(t, s.UpdateDeliveryTargetVerification(ctx, emailID, &models.AddressVerification{Verified: true, VerifiedAt: &sent}))
+	got, err = s.DeliveryTarget(ctx, userID, emailID)
+	must(t, err)
+	if !got.Ready() || got.Verification.CodeHash != "" {
+		t.Errorf("after UpdateDeliveryTargetVerification: %+v", got.Verification)
+	}
+
+	if found, err := s.DeleteDeliveryTarget(ctx, otherID, emailID); err != nil || found {
+		t.Errorf("DeleteDeliveryTarget by another user = %v, %v; want false", found, err)
+	}
+	found, err := s.DeleteDeliveryTarget(ctx, userID, emailID)
+	must(t, err)
+	if !found {
+		t.Error("DeleteDeliveryTarget = false, want true")
+	}
+	if list, err := s.DeliveryTargetsForUser(ctx, userID); err != nil || len(list) != 1 {
+		t.Errorf("after delete: %+v, %v", list, err)
+	}
+}
+
+func testDevices(t *testing.T, ctx context.Context, s store.Store) {
+	userID, otherID, targetID := primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID()
+	kindleID, err := s.InsertDevice(ctx, &models.Device{UserID: userID, Name: "Paperwhite", Type: models.DeviceKindle, Formats: []string{"epub", "pdf"}, MaxAttachmentMB: 50, CreatedAt: day(2024, 1, 1)})
+	must(t, err)
+	koboID, err := s.InsertDevice(ctx, &models.Device{UserID: userID, Name: "Kobo", Type: models.DeviceKobo, Formats: []string{"epub"}, TargetID: targetID, CreatedAt: day(2024, 1, 2)})
+	must(t, err)
+	_, err = s.InsertDevice(ctx, &models.Device{UserID: otherID, Name: "Other", Type: models.DeviceTablet, CreatedAt: day(2024, 1, 3)})
+	must(t, err)
+
+	list, err := s.DevicesForUser(ctx, userID)
+	must(t, err)
+	if len(list) != 2 || list[0].ID != kindleID || list[1].ID != koboID || list[1].TargetID != targetID || list[0].MaxAttachmentMB != 50 {
+		t.Fatalf("DevicesForUser = %+v", list)
+	}
+	if got, err := s.Device(ctx, otherID, kindleID); err != nil || got != nil {
+		t.Errorf("Device by another user = %+v, %v; want nil", got, err)
+	}
+
+	update := list[1]
+	update.Name, update.Formats, update.TargetID = "Kobo Libra", []string{"pdf", "epub"}, primitive.NilObjectID
+	found, err := s.UpdateDevice(ctx, &update)
+	must(t, err)
+	if !found {
+		t.Fatal("UpdateDevice = false, want true")
+	}
+	got, err := s.Device(ctx, userID, koboID)
+	must(t, err)
+	if got.Name != "Kobo Libra" || !equal(got.Formats, []string{"pdf", "epub"}) || !got.TargetID.IsZero() || !got.CreatedAt.Equal(day(2024, 1, 2)) {
+		t.Errorf("after UpdateDevice: %+v", got)
+	}
+	update.UserID = otherID
+	if found, err := s.UpdateDevice(ctx, &update); err != nil || found {
+		t.Errorf("UpdateDevice by another user = %v, %v; want false", found, err)
+	}
+
+	if found, err := s.DeleteDevice(ctx, otherID, kindleID); err != nil || found {
+		t.Errorf("DeleteDevice by another user = %v, %v; want false", found, err)
+	}
+	found, err = s.DeleteDevice(ctx, userID, kindleID)
+	must(t, err)
+	if !found {
+		t.Error("DeleteDevice = false, want true")
+	}
+	if list, err := s.DevicesForUser(ctx, userID); err != nil || len(list) != 1 {
+		t.Errorf("after delete: %+v, %v", list, err)
+	}
+}
+
+func testCollections(t *testing.T, ctx context.Context, s store.Store) {
+	userID, otherID := primitive.NewObjectID(), primitive.NewObjectID()
+	book1, book2 := primitive.NewObjectID(), primitive.NewObjectID()
+	toReadID, err := s.InsertCollection(ctx, &models.Collection{UserID: userID, Name: "To Read", CreatedAt: day(2024, 1, 1), UpdatedAt: day(2024, 1, 1)})
+	must(t, err)
+	progID, err := s.InsertCollection(ctx, &models.Collection{UserID: userID, Name: "Programming", BookIDs: []primitive.ObjectID{book1}, CreatedAt: day(2024, 1, 2), UpdatedAt: day(2024, 1, 2)})
+	must(t, err)
+	otherCollID, err := s.InsertCollection(ctx, &models.Collection{UserID: otherID, Name: "Other", BookIDs: []primitive.ObjectID{book1}, CreatedAt: day(2024, 1, 3)})
+	must(t, err)
+
+	list, err := s.CollectionsForUser(ctx, userID)
+	must(t, err)
+	if len(list) != 2 || list[0].ID != toReadID || list[1].ID != progID || list[0].BookIDs == nil || len(list[1].BookIDs) != 1 {
+		t.Fatalf("CollectionsForUser = %+v", list)
+	}
+	if got, err := s.Collection(ctx, otherID, toReadID); err != nil || got != nil {
+		t.Errorf("Collection by another user = %+v, %v; want nil", got, err)
+	}
+
+	for _, id := range []primitive.ObjectID{book2, book1, book2} {
+		found, err := s.AddBookToCollection(ctx, userID, toReadID, id, day(2024, 2, 1))
+		must(t, err)
+		if !found {
+			t.Fatal("AddBookToCollection = false, want true")
+		}
+	}
+	found, err := s.RenameCollection(ctx, userID, toReadID, "Next Up", day(2024, 2, 2))
+	must(t, err)
+	if !found {
+		t.Fatal("RenameCollection = false, want true")
+	}
+	got, err := s.Collection(ctx, userID, toReadID)
+	must(t, err)
+	if got.Name != "Next Up" || len(got.BookIDs) != 2 || got.BookIDs[0] != book2 || got.BookIDs[1] != book1 ||
+		!got.UpdatedAt.Equal(day(2024, 2, 2)) || !got.CreatedAt.Equal(day(2024, 1, 1)) {
+		t.Errorf("after adds and rename: %+v", got)
+	}
+	if found, err := s.AddBookToCollection(ctx, otherID, toReadID, book1, day(2024, 2, 3)); err != nil || found {
+		t.Errorf("AddBookToCollection by another user = %v, %v; want false", found, err)
+	}
+	if found, err := s.RenameCollection(ctx, otherID, toReadID, "Mine", day(2024, 2, 3)); err != nil || found {
+		t.Errorf("RenameCollection by another user = %v, %v; want false", found, err)
+	}
+	found, err = s.RemoveBookFromCollection(ctx, userID, toReadID, book2, day(2024, 2, 4))
+	must(t, err)
+	if got, _ := s.Collection(ctx, userID, toReadID); !found || len(got.BookIDs) != 1 || got.BookIDs[0] != book1 {
+		t.Errorf("after RemoveBookFromCollection: %v, %+v", found, got)
+	}
+
+	// A deleted book leaves every collection.
+	must(t, s.RemoveBookFromCollections(ctx, book1))
+	for _, c := range []struct{ user, id primitive.ObjectID }{{userID, toReadID}, {userID, progID}, {otherID, otherCollID}} {
+		if got, err := s.Collection(ctx, c.user, c.id); err != nil || len(got.BookIDs) != 0 {
+			t.Errorf("after RemoveBookFromCollections: %+v, %v", got, err)
+		}
+	}
+
+	if found, err := s.DeleteCollection(ctx, otherID, toReadID); err != nil || found {
+		t.Errorf("DeleteCollection by another user = %v, %v; want false", found, err)
+	}
+	found, err = s.DeleteCollection(ctx, userID, toReadID)
+	must(t, err)
+	if !found {
+		t.Error("DeleteCollection = false, want true")
+	}
+	if list, err := s.CollectionsForUser(ctx, userID); err != nil || len(list) != 1 || list[0].ID != progID {
+		t.Errorf("after delete: %+v, %v", list, err)
+	}
+}
+
+func testAudioTracks(t *testing.T, ctx context.Context, s store.Store) {
+	bookID, otherID := primitive.NewObjectID(), primitive.NewObjectID()
+	if got, err := s.AudioTrack(ctx, bookID, 0); err != nil || got != nil {
+		t.Fatalf("AudioTrack before any = %+v, %v; want nil", got, err)
+	}
+	second := &models.AudioTrack{BookID: bookID, Chapter: 1, Title: "Two", Status: models.AudioQueued, RequestedBy: "a@example.com", CreatedAt: day(2024, 1, 1), UpdatedAt: day(2024, 1, 1)}
+	must(t, s.UpsertAudioTrack(ctx, second))
+	must(t, s.UpsertAudioTrack(ctx, &models.AudioTrack{BookID: bookID, Chapter: 0, Title: "One", Status: models.AudioQueued, CreatedAt: day(2024, 1, 1)}))
+	must(t, s.UpsertAudioTrack(ctx, &models.AudioTrack{BookID: otherID, Chapter: 1, Status: models.AudioQueued}))
+	if second.ID.IsZero() {
+		t.Fatal("UpsertAudioTrack didn't set the ID")
+	}
+
+	ready := *second
+	ready.ID = primitive.NilObjectID
+	ready.Status, ready.Percent, ready.S3Key, ready.SizeBytes, ready.UpdatedAt = models.AudioReady, 100, "audio/b/1.mp3", 2048, day(2024, 1, 2)
+	must(t, s.UpsertAudioTrack(ctx, &ready))
+	if ready.ID != second.ID {
+		t.Errorf("replaced track has ID %s, want %s", ready.ID.Hex(), second.ID.Hex())
+	}
+	got, err := s.AudioTrack(ctx, bookID, 1)
+	must(t, err)
+	if got == nil || got.Status != models.AudioReady || got.S3Key != "audio/b/1.mp3" || got.SizeBytes != 2048 || got.Title != "Two" ||
+		got.RequestedBy != "a@example.com" || !got.UpdatedAt.Equal(day(2024, 1, 2)) {
+		t.Errorf("after replace: %+v", got)
+	}
+	tracks, err := s.AudioTracks(ctx, bookID)
+	must(t, err)
+	if len(tracks) != 2 || tracks[0].Title != "One" || tracks[1].Title != "Two" {
+		t.Errorf("AudioTracks = %+v", tracks)
+	}
+
+	must(t, s.DeleteAudioTracks(ctx, bookID))
+	if tracks, err := s.AudioTracks(ctx, bookID); err != nil || len(tracks) != 0 {
+		t.Errorf("after delete: %+v, %v", tracks, err)
+	}
+	if tracks, err := s.AudioTracks(ctx, otherID); err != nil || len(tracks) != 1 {
+		t.Errorf("other book after delete: %+v, %v", tracks, err)
+	}
+}
+
+func testBookEmbeddings(t *testing.T, ctx context.Context, s store.Store) {
+	bookID, otherID := primitive.NewObjectID(), primitive.NewObjectID()
+	if got, err := s.BookEmbedding(ctx, bookID); err != nil || got != nil {
+		t.Fatalf("BookEmbedding before any = %+v, %v; want nil", got, err)
+	}
+	first := &models.BookEmbedding{BookID: bookID, Model: "m1", TextHash: "aa", Vector: []float32{0.5, -0.25}, UpdatedAt: day(2024, 1, 1)}
+	must(t, s.UpsertBookEmbedding(ctx, first))
+	must(t, s.UpsertBookEmbedding(ctx, &models.BookEmbedding{BookID: otherID, Model: "m1", TextHash: "bb", Vector: []float32{1, 0}, UpdatedAt: day(2024, 1, 3)}))
+	if first.ID.IsZero() {
+		t.Fatal("UpsertBookEmbedding didn't set the ID")
+	}
+	replaced := &models.BookEmbedding{BookID: bookID, Model: "m2", TextHash: "cc", Vector: []float32{0.125, 0.75, 1}, UpdatedAt: day(2024, 1, 2)}
+	must(t, s.UpsertBookEmbedding(ctx, replaced))
+	if replaced.ID != first.ID {
+		t.Errorf("replaced embedding has ID %s, want %s", replaced.ID.Hex(), first.ID.Hex())
+	}
+	got, err := s.BookEmbedding(ctx, bookID)
+	must(t, err)
+	if got == nil || got.Model != "m2" || got.TextHash != "cc" || !reflect.DeepEqual(got.Vector, []float32{0.125, 0.75, 1}) || !got.UpdatedAt.Equal(day(2024, 1, 2)) {
+		t.Errorf("after replace: %+v", got)
+	}
+
+	all, err := s.BookEmbeddingsSince(ctx, time.Time{})
+	must(t, err)
+	since, err := s.BookEmbeddingsSince(ctx, day(2024, 1, 3))
+	must(t, err)
+	if len(all) != 2 || len(since) != 1 || since[0].BookID != otherID {
+		t.Errorf("BookEmbeddingsSince = %d, then %+v", len(all), since)
+	}
+
+	must(t, s.DeleteBookEmbedding(ctx, bookID))
+	if got, err := s.BookEmbedding(ctx, bookID); err != nil || got != nil {
+		t.Errorf("after delete: %+v, %v", got, err)
+	}
+	if got, err := s.BookEmbedding(ctx, otherID); err != nil || got == nil {
+		t.Errorf("other book after delete: %+v, %v", got, err)
+	}
+}
+
+func testReadingProgress(t *testing.T, ctx context.Context, s store.Store) {
+	userID, otherID, bookID := primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID()
+	if got, err := s.ReadingProgressFor(ctx, userID, bookID); err != nil || got != nil {
+		t.Fatalf("ReadingProgressFor before any = %+v, %v; want nil", got, err)
+	}
+	must(t, s.UpsertReadingProgress(ctx, &models.ReadingProgress{UserID: userID, BookID: bookID, Anchor: "epubcfi(/6/4!/4/2)", Percent: 10, Device: "web", UpdatedAt: day(2024, 1, 1)}))
+	must(t, s.UpsertReadingProgress(ctx, &models.ReadingProgress{UserID: userID, BookID: bookID, KindleLocation: 1234, Percent: 42.5, Device: "Paperwhite", UpdatedAt: day(2024, 1, 2)}))
+	must(t, s.UpsertReadingProgress(ctx, &models.ReadingProgress{UserID: otherID, BookID: bookID, Percent: 90, UpdatedAt: day(2024, 1, 3)}))
+
+	got, err := s.ReadingProgressFor(ctx, userID, bookID)
+	must(t, err)
+	if got == nil || got.Anchor != "" || got.KindleLocation != 1234 || got.Percent != 42.5 || got.Device != "Paperwhite" || !got.UpdatedAt.Equal(day(2024, 1, 2)) {
+		t.Errorf("ReadingProgressFor = %+v, want the second upsert", got)
+	}
+	if got, err := s.ReadingProgressFor(ctx, otherID, bookID); err != nil || got == nil || got.Percent != 90 {
+		t.Errorf("other user's progress = %+v, %v", got, err)
+	}
+	otherBook := primitive.NewObjectID()
+	must(t, s.UpsertReadingProgress(ctx, &models.ReadingProgress{UserID: userID, BookID: otherBook, Percent: 5, UpdatedAt: day(2024, 1, 5)}))
+	if mine, err := s.ReadingProgressByUser(ctx, userID); err != nil || len(mine) != 2 || mine[0].BookID != otherBook || mine[1].BookID != bookID {
+		t.Errorf("ReadingProgressByUser = %+v, %v; want both of the user's books, newest first", mine, err)
+	}
+	if all, err := s.AllReadingProgress(ctx); err != nil || len(all) != 3 {
+		t.Errorf("AllReadingProgress = %+v, %v", all, err)
+	}
+}
+
+func testLocks(t *testing.T, ctx context.Context, s store.Store) {
+	now, ttl := day(2024, 1, 1), time.Minute
+	acquire := func(owner string, at time.Time, want bool) {
+		t.Helper()
+		got, err := s.AcquireLock(ctx, "job:backup", owner, at, ttl)
+		must(t, err)
+		if got != want {
+			t.Errorf("AcquireLock(%s, %s) = %v, want %v", owner, at.Format(time.TimeOnly), got, want)
+		}
+	}
+	acquire("a", now, true)
+	acquire("b", now.Add(30*time.Second), false)
+	acquire("a", now.Add(30*time.Second), true) // renewed until now+90s
+	acquire("b", now.Add(80*time.Second), false)
+	acquire("b", now.Add(90*time.Second), true) // a's lease expired
+	must(t, s.ReleaseLock(ctx, "job:backup", "a"))
+	acquire("a", now.Add(100*time.Second), false) // releasing someone else's lease does nothing
+	must(t, s.ReleaseLock(ctx, "job:backup", "b"))
+	acquire("a", now.Add(100*time.Second), true)
+	if ok, err := s.AcquireLock(ctx, "scheduler", "b", now.Add(100*time.Second), ttl); err != nil || !ok {
+		t.Errorf("AcquireLock(other name) = %v, %v", ok, err)
+	}
+	must(t, s.ReleaseLock(ctx, "unknown", "a"))
+}
+
+func testSettings(t *testing.T, ctx context.Context, s store.Store) {
+	got, err := s.Settings(ctx)
+	must(t, err)
+	if got.Maintenance.Enabled {
+		t.Errorf("default settings = %+v", got)
+	}
+	since := day(2024, 3, 1)
+	must(t, s.SaveSettings(ctx, &models.Settings{
+		Maintenance: models.Maintenance{Enabled: true, Message: "Restoring", RetryAfter: 60, Since: &since},
+		UpdatedBy:   "admin@example.com",
+	}))
+	must(t, s.SaveSettings(ctx, &models.Settings{Maintenance: models.Maintenance{Enabled: true, Message: "Moving storage", Since: &since}}))
+	got, err = s.Settings(ctx)
+	must(t, err)
+	m := got.Maintenance
+	if !m.Enabled || m.Message != "Moving storage" || m.RetryAfter != 0 || m.Since == nil || !m.Since.Equal(since) || got.UpdatedBy != "" {
+		t.Errorf("saved settings = %+v (maintenance %+v)", got, m)
+	}
+}
+
+func testDownloadLinks(t *testing.T, ctx context.Context, s store.Store) {
+	bookA, bookB, user := primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID()
+	for i, book := range []primitive.ObjectID{bookA, bookB, bookA} {
+		issued := day(2024, 5, 1+i)
+		must(t, s.InsertDownloadLink(ctx, &models.DownloadLink{
+			BookID: book, UserID: user, Role: models.RoleGuest, Kind: models.DownloadLinkPresigned,
+			IssuedAt: issued, ExpiresAt: issued.Add(2 * time.Minute),
+		}))
+	}
+	all, err := s.RecentDownloadLinks(ctx, primitive.NilObjectID, 2)
+	must(t, err)
+	if len(all) != 2 || !all[0].IssuedAt.Equal(day(2024, 5, 3)) || all[1].BookID != bookB {
+		t.Errorf("RecentDownloadLinks(all, 2) = %+v", all)
+	}
+	forA, err := s.RecentDownloadLinks(ctx, bookA, 10)
+	must(t, err)
+	if len(forA) != 2 || forA[0].BookID != bookA || forA[1].BookID != bookA || !forA[1].ExpiresAt.Equal(day(2024, 5, 1).Add(2*time.Minute)) {
+		t.Errorf("RecentDownloadLinks(bookA) = %+v", forA)
+	}
+
+	// Presigned links count toward the month they were issued in; stream links and older ones don't.
+	for _, l := range []models.DownloadLink{
+		{Kind: models.DownloadLinkPresigned, IssuedAt: day(2024, 6, 1), Bytes: 100},
+		{Kind: models.DownloadLinkPresigned, IssuedAt: day(2024, 6, 30), Bytes: 50},
+		{Kind: models.DownloadLinkStream, IssuedAt: day(2024, 6, 2), Bytes: 1000},
+		{Kind: models.DownloadLinkPresigned, IssuedAt: day(2024, 7, 1), Bytes: 7},
+	} {
+		l.BookID, l.UserID, l.Role, l.ExpiresAt = bookB, user, models.RoleViewer, l.IssuedAt.Add(time.Minute)
+		must(t, s.InsertDownloadLink(ctx, &l))
+	}
+	months, err := s.PresignedDownloadsByMonth(ctx, day(2024, 6, 1))
+	must(t, err)
+	want := []models.DownloadMonth{{Month: "2024-06", Bytes: 150, Links: 2}, {Month: "2024-07", Bytes: 7, Links: 1}}
+	if !slices.Equal(months, want) {
+		t.Errorf("PresignedDownloadsByMonth = %+v, want %+v", months, want)
+	}
+}
+
+func testImportSources(t *testing.T, ctx context.Context, s store.Store) {
+	user := primitive.NewObjectID()
+	newer, err := s.InsertImportSource(ctx, &models.ImportSource{UserID: user, Kind: models.TargetGoogleDrive, Name: "Google Drive", CreatedAt: day(2024, 2, 1)})
+	must(t, err)
+	older, err := s.InsertImportSource(ctx, &models.ImportSource{UserID: user, Kind: models.TargetDropbox, Name: "Dropbox", Folder: "/Books", RefreshToken: "tok", AutoSync: true, CreatedAt: day(2024, 1, 1)})
+	must(t, err)
+	sources, err := s.ImportSources(ctx)
+	must(t, err)
+	if len(sources) != 2 || sources[0].ID != older || sources[1].ID != newer {
+		t.Errorf("ImportSources = %+v", sources)
+	}
+
+	src, err := s.ImportSource(ctx, older)
+	must(t, err)
+	if src == nil || src.Folder != "/Books" || src.RefreshToken != "tok" || !src.AutoSync {
+		t.Fatalf("ImportSource = %+v", src)
+	}
+	files := []models.ImportedFile{{FileID: "id:1", Revision: "r1", BookID: primitive.NewObjectID()}}
+	must(t, s.SaveImportProgress(ctx, older, files, &models.ImportSync{At: day(2024, 3, 1), Imported: 1, Duplicates: 2}))
+	src.Name, src.Folder, src.AutoSync = "Calibre", "/Calibre", false
+	if ok, err := s.UpdateImportSource(ctx, src); err != nil || !ok {
+		t.Errorf("UpdateImportSource = %v, %v", ok, err)
+	}
+	got, err := s.ImportSource(ctx, older)
+	must(t, err)
+	if got.Name != "Calibre" || got.Folder != "/Calibre" || got.AutoSync || got.RefreshToken != "tok" {
+		t.Errorf("after UpdateImportSource = %+v", got)
+	}
+	if len(got.Files) != 1 || got.Files[0] != files[0] || got.LastSync == nil || got.LastSync.Duplicates != 2 || !got.LastSync.At.Equal(day(2024, 3, 1)) {
+		t.Errorf("after SaveImportProgress = %+v", got)
+	}
+
+	if ok, err := s.DeleteImportSource(ctx, older); err != nil || !ok {
+		t.Errorf("DeleteImportSource = %v, %v", ok, err)
+	}
+	if ok, err := s.DeleteImportSource(ctx, older); err != nil || ok {
+		t.Errorf("DeleteImportSource again = %v, %v", ok, err)
+	}
+	if got, err := s.ImportSource(ctx, older); err != nil || got != nil {
+		t.Errorf("ImportSource after delete = %+v, %v", got, err)
+	}
+	if ok, err := s.UpdateImportSource(ctx, src); err != nil || ok {
+		t.Errorf("UpdateImportSource after delete = %v, %v", ok, err)
+	}
+	must(t, s.SaveImportProgress(ctx, older, files, nil))
+	if got, err := s.ImportSource(ctx, older); err != nil || got != nil {
+		t.Errorf("SaveImportProgress recreated the source: %+v, %v", got, err)
+	}
+}
+
+func testTelegramChats(t *testing.T, ctx context.Context, s store.Store) {
+	alice, bob := primitive.NewObjectID(), primitive.NewObjectID()
+	must(t, s.LinkTelegramChat(ctx, &models.TelegramChat{ChatID: 100, UserID: alice, Username: "alice", LinkedAt: day(2024, 1, 1)}))
+	c, err := s.TelegramChat(ctx, 100)
+	must(t, err)
+	if c == nil || c.UserID != alice || c.Username != "alice" || !c.LinkedAt.Equal(day(2024, 1, 1)) {
+		t.Fatalf("TelegramChat(100) = %+v", c)
+	}
+
+	// Linking the chat to someone else, or the user to another chat, replaces the old link.
+	must(t, s.LinkTelegramChat(ctx, &models.TelegramChat{ChatID: 100, UserID: bob, LinkedAt: day(2024, 1, 2)}))
+	if c, err := s.TelegramChatForUser(ctx, alice); err != nil || c != nil {
+		t.Errorf("alice after chat relinked = %+v, %v", c, err)
+	}
+	must(t, s.LinkTelegramChat(ctx, &models.TelegramChat{ChatID: 200, UserID: bob, LinkedAt: day(2024, 1, 3)}))
+	if c, err := s.TelegramChat(ctx, 100); err != nil || c != nil {
+		t.Errorf("chat 100 after bob relinked = %+v, %v", c, err)
+	}
+	if c, err := s.TelegramChatForUser(ctx, bob); err != nil || c == nil || c.ChatID != 200 {
+		t.Errorf("TelegramChatForUser(bob) = %+v, %v", c, err)
+	}
+
+	if ok, err := s.UnlinkTelegram(ctx, bob); err != nil || !ok {
+		t.Errorf("UnlinkTelegram = %v, %v", ok, err)
+	}
+	if ok, err := s.UnlinkTelegram(ctx, bob); err != nil || ok {
+		t.Errorf("UnlinkTelegram again = %v, %v", ok, err)
+	}
+	if c, err := s.TelegramChat(ctx, 200); err != nil || c != nil {
+		t.Errorf("TelegramChat after unlink = %+v, %v", c, err)
+	}
+}
+
+func testAPIKeys(t *testing.T, ctx context.Context, s store.Store) {
+	alice, bob := primitive.NewObjectID(), primitive.NewObjectID()
+	newer, err := s.InsertAPIKey(ctx, &models.APIKey{UserID: alice, Name: "laptop", Prefix: "bk_2222", KeyHash: "hash-2", Scopes: []string{models.ScopeClip}, CreatedAt: day(2024, 1, 2)})
+	must(t, err)
+	older, err := s.InsertAPIKey(ctx, &models.APIKey{UserID: alice, Name: "phone", Prefix: "bk_1111", KeyHash: "hash-1", Scopes: []string{models.ScopeClip}, CreatedAt: day(2024, 1, 1)})
+	must(t, err)
+	_, err = s.InsertAPIKey(ctx, &models.APIKey{UserID: bob, Name: "bob's", KeyHash: "hash-3", CreatedAt: day(2024, 1, 3)})
+	must(t, err)
+
+	keys, err := s.APIKeysForUser(ctx, alice)
+	must(t, err)
+	if len(keys) != 2 || keys[0].ID != older || keys[1].ID != newer || keys[0].KeyHash != "hash-1" || !slices.Equal(keys[0].Scopes, []string{models.ScopeClip}) {
+		t.Fatalf("APIKeysForUser = %+v", keys)
+	}
+	k, err := s.APIKeyByHash(ctx, "hash-2")
+	must(t, err)
+	if k == nil || k.ID != newer || k.UserID != alice || k.LastUsedAt != nil {
+		t.Fatalf("APIKeyByHash = %+v", k)
+	}
+	if k, err := s.APIKeyByHash(ctx, "nope"); err != nil || k != nil {
+		t.Errorf("APIKeyByHash(unknown) = %+v, %v", k, err)
+	}
+	must(t, s.TouchAPIKey(ctx, newer, day(2024, 2, 1)))
+	if k, _ := s.APIKeyByHash(ctx, "hash-2"); k == nil || k.LastUsedAt == nil || !k.LastUsedAt.Equal(day(2024, 2, 1)) {
+		t.Errorf("after TouchAPIKey = %+v", k)
+	}
+
+	if ok, err := s.RotateAPIKey(ctx, bob, newer, "bk_3333", "hash-4", day(2024, 2, 2)); err != nil || ok {
+		t.Errorf("RotateAPIKey by someone else = %v, %v", ok, err)
+	}
+	if ok, err := s.RotateAPIKey(ctx, alice, newer, "bk_3333", "hash-4", day(2024, 2, 2)); err != nil || !ok {
+		t.Errorf("RotateAPIKey = %v, %v", ok, err)
+	}
+	if k, _ := s.APIKeyByHash(ctx, "hash-2"); k != nil {
+		t.Errorf("old hash still resolves after RotateAPIKey: %+v", k)
+	}
+	if k, _ := s.APIKeyByHash(ctx, "hash-4"); k == nil || k.ID != newer || k.Name != "laptop" || k.Prefix != "bk_3333" || k.RotatedAt == nil || !k.RotatedAt.Equal(day(2024, 2, 2)) || k.LastUsedAt != nil {
+		t.Errorf("after RotateAPIKey = %+v", k)
+	}
+	all, err := s.AllAPIKeys(ctx)
+	must(t, err)
+	if len(all) != 3 || all[0].ID != older || all[1].ID != newer || all[2].UserID != bob {
+		t.Errorf("AllAPIKeys = %+v", all)
+	}
+
+	if ok, err := s.DeleteAPIKey(ctx, bob, newer); err != nil || ok {
+		t.Errorf("DeleteAPIKey by someone else = %v, %v", ok, err)
+	}
+	if ok, err := s.DeleteAPIKey(ctx, alice, newer); err != nil || !ok {
+		t.Errorf("DeleteAPIKey = %v, %v", ok, err)
+	}
+	if ok, err := s.DeleteAPIKey(ctx, alice, newer); err != nil || ok {
+		t.Errorf("DeleteAPIKey again = %v, %v", ok, err)
+	}
+	if k, err := s.APIKeyByHash(ctx, "hash-4"); err != nil || k != nil {
+		t.Errorf("APIKeyByHash after delete = %+v, %v", k, err)
+	}
+}
+
+func testRefreshTokens(t *testing.T, ctx context.Context, s store.Store) {
+	alice, bob := primitive.NewObjectID(), primitive.NewObjectID()
+	first := &models.RefreshToken{UserID: alice, TokenHash: "hash-1", CreatedAt: day(2024, 1, 1), ExpiresAt: day(2024, 2, 1)}
+	first.ID = primitive.NewObjectID()
+	first.Family = first.ID
+	must(t, s.InsertRefreshToken(ctx, first))
+	second := &models.RefreshToken{UserID: alice, Family: first.ID, TokenHash: "hash-2", CreatedAt: day(2024, 1, 2), ExpiresAt: day(2024, 2, 2)}
+	must(t, s.InsertRefreshToken(ctx, second))
+	other := &models.RefreshToken{UserID: alice, TokenHash: "hash-3", CreatedAt: day(2024, 1, 3), ExpiresAt: day(2024, 2, 3)}
+	other.ID = primitive.NewObjectID()
+	other.Family = other.ID
+	must(t, s.InsertRefreshToken(ctx, other))
+	bobs := &models.RefreshToken{UserID: bob, TokenHash: "hash-4", CreatedAt: day(2024, 1, 4), ExpiresAt: day(2024, 2, 4)}
+	bobs.ID = primitive.NewObjectID()
+	bobs.Family = bobs.ID
+	must(t, s.InsertRefreshToken(ctx, bobs))
+
+	got, err := s.RefreshTokenByHash(ctx, "hash-2")
+	must(t, err)
+	if got == nil || got.ID != second.ID || got.Family != first.ID || got.UserID != alice || !got.ExpiresAt.Equal(day(2024, 2, 2)) || got.UsedAt != nil {
+		t.Fatalf("RefreshTokenByHash = %+v", got)
+	}
+	if got, err := s.RefreshTokenByHash(ctx, "nope"); err != nil || got != nil {
+		t.Errorf("RefreshTokenByHash(unknown) = %+v, %v", got, err)
+	}
+
+	if ok, err := s.UseRefreshToken(ctx, first.ID, day(2024, 1, 2)); err != nil || !ok {
+		t.Errorf("UseRefreshToken = %v, %v", ok, err)
+	}
+	if ok, err := s.UseRefreshToken(ctx, first.ID, day(2024, 1, 3)); err != nil || ok {
+		t.Errorf("UseRefreshToken again = %v, %v", ok, err)
+	}
+	if got, _ := s.RefreshTokenByHash(ctx, "hash-1"); got == nil || got.UsedAt == nil || !got.UsedAt.Equal(day(2024, 1, 2)) {
+		t.Errorf("after UseRefreshToken = %+v", got)
+	}
+
+	must(t, s.RevokeRefreshTokenFamily(ctx, first.ID, day(2024, 1, 5)))
+	for _, hash := range []string{"hash-1", "hash-2"} {
+		if got, _ := s.RefreshTokenByHash(ctx, hash); got == nil || got.RevokedAt == nil || !got.RevokedAt.Equal(day(2024, 1, 5)) {
+			t.Errorf("%s after RevokeRefreshTokenFamily = %+v", hash, got)
+		}
+	}
+	if got, _ := s.RefreshTokenByHash(ctx, "hash-3"); got == nil || got.RevokedAt != nil {
+		t.Errorf("other sign-in revoked with the family: %+v", got)
+	}
+	if ok, err := s.UseRefreshToken(ctx, second.ID, day(2024, 1, 6)); err != nil || ok {
+		t.Errorf("UseRefreshToken of a revoked token = %v, %v", ok, err)
+	}
+
+	must(t, s.RevokeUserRefreshTokens(ctx, alice, day(2024, 1, 7)))
+	if got, _ := s.RefreshTokenByHash(ctx, "hash-3"); got == nil || got.RevokedAt == nil || !got.RevokedAt.Equal(day(2024, 1, 7)) {
+		t.Errorf("after RevokeUserRefreshTokens = %+v", got)
+	}
+	if got, _ := s.RefreshTokenByHash(ctx, "hash-1"); got == nil || !got.RevokedAt.Equal(day(2024, 1, 5)) {
+		t.Errorf("RevokeUserRefreshTokens changed an earlier revocation: %+v", got)
+	}
+	if got, _ := s.RefreshTokenByHash(ctx, "hash-4"); got == nil || got.RevokedAt != nil {
+		t.Errorf("someone else's token revoked: %+v", got)
+	}
+
+	must(t, s.DeleteExpiredRefreshTokens(ctx, day(2024, 2, 3)))
+	for hash, kept := range map[string]bool{"hash-1": false, "hash-2": false, "hash-3": true, "hash-4": true} {
+		if got, err := s.RefreshTokenByHash(ctx, hash); err != nil || (got != nil) != kept {
+			t.Errorf("%s after DeleteExpiredRefreshTokens = %+v, %v; want kept %v", hash, got, err, kept)
+		}
+	}
+}
+
+func testJobQueue(t *testing.T, ctx context.Context, s store.Store) {
+	alice := primitive.NewObjectID()
+	queue := func(kind string, runAt time.Time) *models.QueuedJob {
+		job := &models.QueuedJob{Kind: kind, UserID: alice, Params: map[string]string{"bookId": "b"}, Status: models.QueuedJobQueued, MaxAttempts: 3, RunAt: runAt, CreatedAt: runAt, UpdatedAt: runAt}
+		must(t, s.InsertQueuedJob(ctx, job))
+		return job
+	}
+	later := queue("send", day(2024, 1, 3))
+	first := queue("send", day(2024, 1, 1))
+	other := queue("other", day(2024, 1, 2))
+
+	got, err := s.QueuedJobByID(ctx, first.ID)
+	must(t, err)
+	if got == nil || got.Kind != "send" || got.UserID != alice || got.Params["bookId"] != "b" || !got.RunAt.Equal(day(2024, 1, 1)) {
+		t.Fatalf("QueuedJobByID = %+v", got)
+	}
+	if got, err := s.QueuedJobByID(ctx, primitive.NewObjectID()); err != nil || got != nil {
+		t.Errorf("QueuedJobByID(unknown) = %+v, %v", got, err)
+	}
+
+	// The job due longest goes first; one not due yet waits.
+	lease := day(2024, 1, 2).Add(time.Hour)
+	if got, err := s.ClaimQueuedJob(ctx, "a", []string{"convert"}, day(2024, 1, 2), lease); err != nil || got != nil {
+		t.Errorf("ClaimQueuedJob of another kind = %+v, %v", got, err)
+	}
+	claimed, err := s.ClaimQueuedJob(ctx, "a", nil, day(2024, 1, 2), lease)
+	must(t, err)
+	if claimed == nil || claimed.ID != first.ID || claimed.Status != models.QueuedJobRunning || claimed.Attempts != 1 || claimed.Instance != "a" || !claimed.LeaseUntil.Equal(lease) {
+		t.Fatalf("ClaimQueuedJob = %+v", claimed)
+	}
+	claimed2, err := s.ClaimQueuedJob(ctx, "b", []string{"other"}, day(2024, 1, 2), lease)
+	must(t, err)
+	if claimed2 == nil || claimed2.ID != other.ID {
+		t.Fatalf("second ClaimQueuedJob = %+v", claimed2)
+	}
+	if got, err := s.ClaimQueuedJob(ctx, "b", nil, day(2024, 1, 2), lease); err != nil || got != nil {
+		t.Errorf("ClaimQueuedJob with nothing due = %+v, %v", got, err)
+	}
+
+	pending, err := s.PendingQueuedJobs(ctx, alice, "send")
+	must(t, err)
+	if len(pending) != 2 || pending[0].ID != first.ID || pending[1].ID != later.ID {
+		t.Errorf("PendingQueuedJobs = %+v", pending)
+	}
+
+	// Only the instance running the job saves its outcome.
+	finished := day(2024, 1, 2)
+	claimed.Status, claimed.Result, claimed.FinishedAt, claimed.LeaseUntil = models.QueuedJobSucceeded, map[string]string{"format": "epub"}, &finished, nil
+	if ok, err := s.UpdateQueuedJob(ctx, claimed, "b"); err != nil || ok {
+		t.Errorf("UpdateQueuedJob from another instance = %v, %v", ok, err)
+	}
+	if ok, err := s.UpdateQueuedJob(ctx, claimed, "a"); err != nil || !ok {
+		t.Errorf("UpdateQueuedJob = %v, %v", ok, err)
+	}
+	if got, _ := s.QueuedJobByID(ctx, first.ID); got == nil || got.Status != models.QueuedJobSucceeded || got.Result["format"] != "epub" || got.FinishedAt == nil {
+		t.Errorf("after UpdateQueuedJob = %+v", got)
+	}
+	if ok, err := s.UpdateQueuedJob(ctx, claimed, "a"); err != nil || ok {
+		t.Errorf("UpdateQueuedJob of a finished job = %v, %v", ok, err)
+	}
+
+	// A running job whose lease ran out is claimed again, counting another attempt.
+	again, err := s.ClaimQueuedJob(ctx, "c", nil, lease.Add(time.Minute), lease.Add(2*time.Hour))
+	must(t, err)
+	if again == nil || again.ID != other.ID || again.Attempts != 2 || again.Instance != "c" {
+		t.Fatalf("ClaimQueuedJob after the lease = %+v", again)
+	}
+	if ok, _ := s.UpdateQueuedJob(ctx, claimed2, "b"); ok {
+		t.Error("instance that lost the lease saved its outcome")
+	}
+
+	must(t, s.DeleteFinishedQueuedJobs(ctx, day(2024, 1, 3)))
+	if got, _ := s.QueuedJobByID(ctx, first.ID); got != nil {
+		t.Errorf("finished job kept: %+v", got)
+	}
+	if got, _ := s.QueuedJobByID(ctx, later.ID); got == nil {
+		t.Error("pending job deleted")
+	}
+}
+
+func testWishlist(t *testing.T, ctx context.Context, s store.Store) {
+	alice, bob := primitive.NewObjectID(), primitive.NewObjectID()
+	older, err := s.InsertWishlistItem(ctx, &models.WishlistItem{UserID: alice, ISBN: "9780141439518", Title: "Pride and Prejudice", Authors: []string{"Jane Austen"}, CreatedAt: day(2024, 1, 1)})
+	must(t, err)
+	newer, err := s.InsertWishlistItem(ctx, &models.WishlistItem{UserID: alice, Title: "Emma", SourceURL: "https://shop.example/emma", CreatedAt: day(2024, 1, 2)})
+	must(t, err)
+	_, err = s.InsertWishlistItem(ctx, &models.WishlistItem{UserID: bob, Title: "Persuasion", CreatedAt: day(2024, 1, 3)})
+	must(t, err)
+
+	items, err := s.WishlistForUser(ctx, alice)
+	must(t, err)
+	if len(items) != 2 || items[0].ID != newer || items[1].ID != older || !slices.Equal(items[1].Authors, []string{"Jane Austen"}) {
+		t.Fatalf("WishlistForUser = %+v", items)
+	}
+
+	item := items[0]
+	item.Notes = "birthday"
+	if ok, err := s.UpdateWishlistItem(ctx, &item); err != nil || !ok {
+		t.Errorf("UpdateWishlistItem = %v, %v", ok, err)
+	}
+	if items, _ := s.WishlistForUser(ctx, alice); len(items) != 2 || items[0].Notes != "birthday" || items[0].SourceURL != "https://shop.example/emma" {
+		t.Errorf("after UpdateWishlistItem = %+v", items)
+	}
+	item.UserID = bob
+	if ok, err := s.UpdateWishlistItem(ctx, &item); err != nil || ok {
+		t.Errorf("UpdateWishlistItem of someone else's item = %v, %v", ok, err)
+	}
+
+	if ok, err := s.DeleteWishlistItem(ctx, bob, older); err != nil || ok {
+		t.Errorf("DeleteWishlistItem by someone else = %v, %v", ok, err)
+	}
+	if ok, err := s.DeleteWishlistItem(ctx, alice, older); err != nil || !ok {
+		t.Errorf("DeleteWishlistItem = %v, %v", ok, err)
+	}
+	if ok, err := s.DeleteWishlistItem(ctx, alice, older); err != nil || ok {
+		t.Errorf("DeleteWishlistItem again = %v, %v", ok, err)
+	}
+	if items, _ := s.WishlistForUser(ctx, alice); len(items) != 1 {
+		t.Errorf("after delete = %+v", items)
+	}
+
+	item.UserID = alice
+	item.PriceWatch = &models.PriceWatch{Threshold: 4.99, Currency: "USD"}
+	if ok, err := s.UpdateWishlistItem(ctx, &item); err != nil || !ok {
+		t.Errorf("UpdateWishlistItem with a price watch = %v, %v", ok, err)
+	}
+	watched, err := s.WatchedWishlistItems(ctx)
+	must(t, err)
+	if len(watched) != 1 || watched[0].ID != newer || watched[0].PriceWatch == nil || watched[0].PriceWatch.Threshold != 4.99 {
+		t.Errorf("WatchedWishlistItems = %+v", watched)
+	}
+}
+
+func testPriceHistory(t *testing.T, ctx context.Context, s store.Store) {
+	userID, itemID, otherID := primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID()
+	must(t, s.InsertPricePoint(ctx, &models.PricePoint{ItemID: itemID, UserID: userID, Provider: "Store A", Price: 9.99, Currency: "USD", CheckedAt: day(2024, 1, 1)}))
+	must(t, s.InsertPricePoint(ctx, &models.PricePoint{ItemID: itemID, UserID: userID, Provider: "Store A", Price: 4.99, Currency: "USD", URL: "https://a.example/b", CheckedAt: day(2024, 1, 2)}))
+	must(t, s.InsertPricePoint(ctx, &models.PricePoint{ItemID: otherID, UserID: userID, Provider: "Store B", Price: 1, Currency: "EUR", CheckedAt: day(2024, 1, 3)}))
+
+	points, err := s.PriceHistory(ctx, itemID, 10)
+	must(t, err)
+	if len(points) != 2 || points[0].Price != 4.99 || points[0].URL != "https://a.example/b" || points[1].Price != 9.99 {
+		t.Errorf("PriceHistory = %+v", points)
+	}
+	if points, _ := s.PriceHistory(ctx, itemID, 1); len(points) != 1 || points[0].Price != 4.99 {
+		t.Errorf("PriceHistory limit 1 = %+v", points)
+	}
+	must(t, s.DeletePriceHistory(ctx, itemID))
+	if points, _ := s.PriceHistory(ctx, itemID, 10); len(points) != 0 {
+		t.Errorf("after DeletePriceHistory = %+v", points)
+	}
+	if points, _ := s.PriceHistory(ctx, otherID, 10); len(points) != 1 {
+		t.Errorf("other item's history = %+v", points)
+	}
+}
+
+func testPurchases(t *testing.T, ctx context.Context, s store.Store) {
+	bookA, bookB, bookC := primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID()
+	if got, err := s.BookPurchase(ctx, bookA); err != nil || got != nil {
+		t.Fatalf("BookPurchase before any = %+v, %v; want nil", got, err)
+	}
+	must(t, s.SaveBookPurchase(ctx, &models.Purchase{BookID: bookA, Store: "Kobo", PurchasedOn: "2023-05-01", UpdatedBy: "a@example.com", UpdatedAt: day(2024, 1, 1)}))
+	must(t, s.SaveBookPurchase(ctx, &models.Purchase{BookID: bookA, Store: "Kobo", PurchasedOn: "2023-05-02", OrderID: "K-1", Price: 7.99, Currency: "EUR", LicenseNotes: "DRM-free", UpdatedBy: "a@example.com", UpdatedAt: day(2024, 1, 2)}))
+	must(t, s.SaveBookPurchase(ctx, &models.Purchase{BookID: bookB, Store: "Humble Bundle", PurchasedOn: "2021-12-24", UpdatedAt: day(2024, 1, 3)}))
+	must(t, s.SaveBookPurchase(ctx, &models.Purchase{BookID: bookC, LicenseNotes: "gift", UpdatedAt: day(2024, 1, 4)}))
+
+	got, err := s.BookPurchase(ctx, bookA)
+	must(t, err)
+	if got == nil || got.PurchasedOn != "2023-05-02" || got.OrderID != "K-1" || got.Price != 7.99 || got.LicenseNotes != "DRM-free" || !got.UpdatedAt.Equal(day(2024, 1, 2)) {
+		t.Errorf("BookPurchase = %+v, want the second save", got)
+	}
+	all, err := s.AllPurchases(ctx)
+	must(t, err)
+	if len(all) != 3 || all[0].BookID != bookB || all[1].BookID != bookA || all[2].BookID != bookC {
+		t.Errorf("AllPurchases = %+v, want oldest purchase first, undated last", all)
+	}
+	if ok, err := s.DeleteBookPurchase(ctx, bookA); err != nil || !ok {
+		t.Errorf("DeleteBookPurchase = %v, %v", ok, err)
+	}
+	if ok, err := s.DeleteBookPurchase(ctx, bookA); err != nil || ok {
+		t.Errorf("DeleteBookPurchase again = %v, %v; want false", ok, err)
+	}
+	if got, _ := s.BookPurchase(ctx, bookA); got != nil {
+		t.Errorf("after DeleteBookPurchase = %+v", got)
+	}
+}
+
+func testRecommendations(t *testing.T, ctx context.Context, s store.Store) {
+	userID, otherID, bookA, bookB := primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID()
+	if got, err := s.RecommendationsFor(ctx, userID); err != nil || got != nil {
+		t.Fatalf("RecommendationsFor before any = %+v, %v; want nil", got, err)
+	}
+	must(t, s.SaveRecommendations(ctx, &models.UserRecommendations{UserID: userID, Items: []models.Recommendation{{BookID: bookA, Score: 1, Reason: "first"}}, ComputedAt: day(2024, 1, 1)}))
+	must(t, s.SaveRecommendations(ctx, &models.UserRecommendations{UserID: userID, Items: []models.Recommendation{{BookID: bookB, Score: 2, Reason: "second"}, {BookID: bookA, Score: 0.5}}, ComputedAt: day(2024, 1, 2)}))
+	must(t, s.SaveRecommendations(ctx, &models.UserRecommendations{UserID: otherID, ComputedAt: day(2024, 1, 3)}))
+
+	got, err := s.RecommendationsFor(ctx, userID)
+	must(t, err)
+	if got == nil || len(got.Items) != 2 || got.Items[0].BookID != bookB || got.Items[0].Reason != "second" || got.Items[1].Score != 0.5 || !got.ComputedAt.Equal(day(2024, 1, 2)) {
+		t.Errorf("RecommendationsFor = %+v, want the second save", got)
+	}
+	if got, err := s.RecommendationsFor(ctx, otherID); err != nil || got == nil || len(got.Items) != 0 {
+		t.Errorf("other user's recommendations = %+v, %v", got, err)
+	}
+}
+
+func testNewReleases(t *testing.T, ctx context.Context, s store.Store) {
+	alice, bob := primitive.NewObjectID(), primitive.NewObjectID()
+	add := func(r models.NewRelease, want bool) {
+		t.Helper()
+		added, err := s.AddNewRelease(ctx, &r)
+		must(t, err)
+		if added != want {
+			t.Errorf("AddNewRelease(%s) = %v, want %v", r.Title, added, want)
+		}
+	}
+	add(models.NewRelease{UserID: alice, Key: "9780000000001", Author: "Ann Leckie", ISBN: "9780000000001", Title: "Older", Authors: []string{"Ann Leckie"}, PublishDate: "2024-03", FoundAt: day(2024, 5, 1)}, true)
+	add(models.NewRelease{UserID: alice, Key: "ann leckie/newer", Author: "Ann Leckie", Title: "Newer", PublishDate: "2024-05-02", FoundAt: day(2024, 5, 2)}, true)
+	add(models.NewRelease{UserID: alice, Key: "9780000000001", Author: "Ann Leckie", Title: "Older again", FoundAt: day(2024, 5, 3)}, false)
+	add(models.NewRelease{UserID: bob, Key: "9780000000001", Author: "Ann Leckie", Title: "Older", FoundAt: day(2024, 5, 3)}, true)
+
+	releases, err := s.NewReleasesForUser(ctx, alice, 10)
+	must(t, err)
+	if len(releases) != 2 || releases[0].Title != "Newer" || releases[1].Title != "Older" || !slices.Equal(releases[1].Authors, []string{"Ann Leckie"}) || releases[1].ISBN != "9780000000001" {
+		t.Errorf("NewReleasesForUser = %+v", releases)
+	}
+	if releases, _ := s.NewReleasesForUser(ctx, alice, 1); len(releases) != 1 || releases[0].Title != "Newer" {
+		t.Errorf("NewReleasesForUser limit 1 = %+v", releases)
+	}
+}
+
+func testBackups(t *testing.T, ctx context.Context, s store.Store) {
+	oldID, err := s.InsertBackup(ctx, &models.Backup{Key: "backups/old.tar.gz", CreatedAt: day(2024, 1, 1)})
+	must(t, err)
+	_, err = s.InsertBackup(ctx, &models.Backup{Key: "backups/new.tar.gz", SizeBytes: 10, CreatedAt: day(2024, 1, 2)})
+	must(t, err)
+	backups, err := s.ListBackups(ctx)
+	must(t, err)
+	if len(backups) != 2 || backups[0].Key != "backups/new.tar.gz" {
+		t.Errorf("ListBackups = %+v", backups)
+	}
+	must(t, s.DeleteBackup(ctx, oldID))
+	backups, err = s.ListBackups(ctx)
+	must(t, err)
+	if len(backups) != 1 {
+		t.Errorf("ListBackups after delete = %d", len(backups))
+	}
+
+	names, err := s.CollectionNames(ctx)
+	must(t, err)
+	hasBackups := false
+	for _, n := range names {
+		hasBackups = hasBackups || n == "backups"
+	}
+	if !hasBackups {
+		t.Fatalf("CollectionNames = %v, missing backups", names)
+	}
+	var docs []bson.Raw
+	must(t, s.ForEachDocument(ctx, "backups", func(doc bson.Raw) error {
+		docs = append(docs, doc)
+		return nil
+	}))
+	if len(docs) != 1 {
+		t.Fatalf("ForEachDocument returned %d docs", len(docs))
+	}
+	var b models.Backup
+	must(t, bson.Unmarshal(docs[0], &b))
+	if b.Key != "backups/new.tar.gz" || b.SizeBytes != 10 || !b.CreatedAt.Equal(day(2024, 1, 2)) {
+		t.Errorf("ForEachDocument doc = %+v", b)
+	}
+	if _, ok := docs[0].Lookup("_id").ObjectIDOK(); !ok {
+		t.Error("ForEachDocument doc has no ObjectID _id")
+	}
+}
+
+func testIPBlocks(t *testing.T, ctx context.Context, s store.Store) {
+	expires := day(2024, 3, 1)
+	newer, err := s.InsertIPBlock(ctx, &models.IPBlock{Range: "2001:db8::/32", CreatedBy: "a@x", CreatedAt: day(2024, 2, 1)})
+	must(t, err)
+	older, err := s.InsertIPBlock(ctx, &models.IPBlock{Range: "203.0.113.7/32", Reason: "scraping covers", CreatedBy: "a@x", CreatedAt: day(2024, 1, 1), ExpiresAt: &expires})
+	must(t, err)
+	blocks, err := s.IPBlocks(ctx, day(2024, 2, 15))
+	must(t, err)
+	if len(blocks) != 2 || blocks[0].ID != older || blocks[1].ID != newer || blocks[0].Reason != "scraping covers" || blocks[0].ExpiresAt == nil || !blocks[0].ExpiresAt.Equal(expires) {
+		t.Fatalf("IPBlocks = %+v", blocks)
+	}
+	// An expired block is no longer in force, and is deleted once it expired before the cutoff.
+	if blocks, err := s.IPBlocks(ctx, day(2024, 3, 2)); err != nil || len(blocks) != 1 || blocks[0].ID != newer {
+		t.Errorf("IPBlocks after expiry = %+v, %v", blocks, err)
+	}
+	must(t, s.DeleteExpiredIPBlocks(ctx, day(2024, 2, 15)))
+	if ok, err := s.DeleteIPBlock(ctx, older); err != nil || !ok {
+		t.Errorf("DeleteIPBlock before cutoff = %v, %v", ok, err)
+	}
+	expired, err := s.InsertIPBlock(ctx, &models.IPBlock{Range: "198.51.100.0/24", CreatedAt: day(2024, 1, 1), ExpiresAt: &expires})
+	must(t, err)
+	must(t, s.DeleteExpiredIPBlocks(ctx, day(2024, 3, 2)))
+	if ok, err := s.DeleteIPBlock(ctx, expired); err != nil || ok {
+		t.Errorf("DeleteIPBlock after DeleteExpiredIPBlocks = %v, %v", ok, err)
+	}
+	if blocks, err := s.IPBlocks(ctx, day(2024, 1, 1)); err != nil || len(blocks) != 1 || blocks[0].ID != newer {
+		t.Errorf("IPBlocks after deletes = %+v, %v", blocks, err)
+	}
+}
+
+func testSyncPeers(t *testing.T, ctx context.Context, s store.Store) {
+	newer, err := s.InsertSyncPeer(ctx, &models.SyncPeer{Name: "VPS", URL: "https://vps.example.com", APIKey: "enc", Collections: []string{"all"}, CreatedAt: day(2024, 2, 1)})
+	must(t, err)
+	older, err := s.InsertSyncPeer(ctx, &models.SyncPeer{Name: "Home", URL: "http://home.lan:8080", Collections: []string{"tag:Science Fiction", "shelf:reading"}, Progress: true, CreatedBy: "a@x", CreatedAt: day(2024, 1, 1)})
+	must(t, err)
+	peers, err := s.SyncPeers(ctx)
+	must(t, err)
+	if len(peers) != 2 || peers[0].ID != older || peers[1].ID != newer || peers[1].APIKey != "enc" || !peers[0].Progress || len(peers[0].Collections) != 2 {
+		t.Fatalf("SyncPeers = %+v", peers)
+	}
+
+	cursors := []models.SyncCursor{{Collection: "tag:Science Fiction", Cursor: "c1"}}
+	must(t, s.SaveSyncProgress(ctx, older, cursors, "p1", &models.PeerSync{At: day(2024, 3, 1), Imported: 2, Progress: 3}))
+	peers, err = s.SyncPeers(ctx)
+	must(t, err)
+	got := peers[0]
+	if len(got.Cursors) != 1 || got.Cursors[0] != cursors[0] || got.ProgressCursor != "p1" || got.LastSync == nil || got.LastSync.Imported != 2 || !got.LastSync.At.Equal(day(2024, 3, 1)) || got.Name != "Home" {
+		t.Errorf("after SaveSyncProgress = %+v", got)
+	}
+
+	if ok, err := s.DeleteSyncPeer(ctx, older); err != nil || !ok {
+		t.Errorf("DeleteSyncPeer = %v, %v", ok, err)
+	}
+	if ok, err := s.DeleteSyncPeer(ctx, older); err != nil || ok {
+		t.Errorf("DeleteSyncPeer again = %v, %v", ok, err)
+	}
+	must(t, s.SaveSyncProgress(ctx, older, cursors, "p2", nil))
+	if peers, err := s.SyncPeers(ctx); err != nil || len(peers) != 1 || peers[0].ID != newer {
+		t.Errorf("SyncPeers after delete = %+v, %v", peers, err)
+	}
+}
@@ -0,0 +1,58 @@
+package store
+
+import (
+	"context"
+
+	"github.com/kevinaaaquil/books/backend/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func (db *DB) InsertSyncPeer(ctx context.Context, p *models.SyncPeer) (primitive.ObjectID, error) {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
+	if p.ID.IsZero() {
+		p.ID = primitive.NewObjectID()
+	}
+	if _, err := db.SyncPeersCollection().InsertOne(ctx, p); err != nil {
+		return primitive.NilObjectID, err
+	}
+	return p.ID, nil
+}
+
+// SyncPeers returns every peer, oldest first.
+func (db *DB) SyncPeers(ctx context.Context) ([]models.SyncPeer, error) {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
+	cur, err := db.SyncPeersCollection().Find(ctx, bson.M{}, options.Find().SetSort(bson.D{{Key: "createdAt", Value: 1}, {Key: "_id", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+	peers := []models.SyncPeer{}
+	if err := cur.All(ctx, &peers); err != nil {
+		return nil, err
+	}
+	return peers, nil
+}
+
+// SaveSyncProgress records how far a sync got and its outcome. A deleted peer stays deleted.
+func (db *DB) SaveSyncProgress(ctx context.Context, id primitive.ObjectID, cursors []models.SyncCursor, progressCursor string, last *models.PeerSync) error {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
+	set := bson.M{"cursors": cursors, "progressCursor": progressCursor, "lastSync": last}
+	_, err := db.SyncPeersCollection().UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": set})
+	return err
+}
+
+// DeleteSyncPeer removes a peer. Returns false if it does not exist.
+func (db *DB) DeleteSyncPeer(ctx context.Context, id primitive.ObjectID) (bool, error) {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
+	res, err := db.SyncPeersCollection().DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return false, err
+	}
+	return res.DeletedCount > 0, nil
+}
@@ -0,0 +1,59 @@
+package store
+
+import (
+	"context"
+
+	"github.com/kevinaaaquil/books/backend/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// LinkTelegramChat stores c, replacing any link of the same chat or user.
+func (db *DB) LinkTelegramChat(ctx context.Context, c *models.TelegramChat) error {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
+	if _, err := db.TelegramChats().DeleteMany(ctx, bson.M{"$or": bson.A{bson.M{"chatId": c.ChatID}, bson.M{"userId": c.UserID}}}); err != nil {
+		return err
+	}
+	if c.ID.IsZero() {
+		c.ID = primitive.NewObjectID()
+	}
+	_, err := db.TelegramChats().InsertOne(ctx, c)
+	return err
+}
+
+// TelegramChat returns the link of a chat, or nil if it is not linked.
+func (db *DB) TelegramChat(ctx context.Context, chatID int64) (*models.TelegramChat, error) {
+	return db.findTelegramChat(ctx, bson.M{"chatId": chatID})
+}
+
+// TelegramChatForUser returns the user's linked chat, or nil if there is none.
+func (db *DB) TelegramChatForUser(ctx context.Context, userID primitive.ObjectID) (*models.TelegramChat, error) {
+	return db.findTelegramChat(ctx, bson.M{"userId": userID})
+}
+
+func (db *DB) findTelegramChat(ctx context.Context, filter bson.M) (*models.TelegramChat, error) {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
+	var c models.TelegramChat
+	err := db.TelegramChats().FindOne(ctx, filter).Decode(&c)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// UnlinkTelegram removes the user's linked chat. Returns false if there is none.
+func (db *DB) UnlinkTelegram(ctx context.Context, userID primitive.ObjectID) (bool, error) {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
+	res, err := db.TelegramChats().DeleteMany(ctx, bson.M{"userId": userID})
+	if err != nil {
+		return false, err
+	}
+	return res.DeletedCount > 0, nil
+}
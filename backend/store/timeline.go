@@ -0,0 +1,90 @@
+package store
+
+import (
+	"context"
+
+	"github.com/kevinaaaquil/books/backend/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// publishYearPattern matches publishDate values that start with a 4-digit year ("1994", "1994-05", "1994-05-01").
+const publishYearPattern = "^[0-9]{4}"
+
+// BookTimeline aggregates the books q selects by publication decade/year and by month added in a single $facet
+// query.
+func (db *DB) BookTimeline(ctx context.Context, q models.BookQuery) (*models.BookTimeline, error) {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
+	dated := bson.D{{Key: "$match", Value: bson.M{"publishDate": bson.M{"$regex": publishYearPattern}}}}
+	sortByPeriod := bson.D{{Key: "$sort", Value: bson.M{"_id": 1}}}
+	pipeline := append(mongo.Pipeline{{{Key: "$match", Value: bookQueryFilter(q)}}}, contentRatingStages(q.MaxContentRating)...)
+	pipeline = append(pipeline, bson.D{{Key: "$facet", Value: bson.M{
+		"publishedByDecade": bson.A{
+			dated,
+			bson.D{{Key: "$group", Value: bson.M{
+				"_id":   bson.M{"$concat": bson.A{bson.M{"$substrBytes": bson.A{"$publishDate", 0, 3}}, "0s"}},
+				"count": bson.M{"$sum": 1},
+			}}},
+			sortByPeriod,
+		},
+		"publishedByYear": bson.A{
+			dated,
+			bson.D{{Key: "$group", Value: bson.M{
+				"_id":   bson.M{"$substrBytes": bson.A{"$publishDate", 0, 4}},
+				"count": bson.M{"$sum": 1},
+			}}},
+			sortByPeriod,
+		},
+		"addedByMonth": bson.A{
+			bson.D{{Key: "$group", Value: bson.M{
+				"_id":   bson.M{"$dateToString": bson.M{"format": "%Y-%m", "date": "$createdAt"}},
+				"count": bson.M{"$sum": 1},
+			}}},
+			sortByPeriod,
+		},
+		"undated": bson.A{
+			bson.D{{Key: "$match", Value: bson.M{"publishDate": bson.M{"$not": bson.M{"$regex": publishYearPattern}}}}},
+			bson.D{{Key: "$count", Value: "count"}},
+		},
+	}}})
+
+	cur, err := db.BooksForListing().Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+	var facets []struct {
+		PublishedByDecade []models.TimelineBucket `bson:"publishedByDecade"`
+		PublishedByYear   []models.TimelineBucket `bson:"publishedByYear"`
+		AddedByMonth      []models.TimelineBucket `bson:"addedByMonth"`
+		Undated           []struct {
+			Count int `bson:"count"`
+		} `bson:"undated"`
+	}
+	if err := cur.All(ctx, &facets); err != nil {
+		return nil, err
+	}
+	timeline := &models.BookTimeline{
+		PublishedByDecade: []models.TimelineBucket{},
+		PublishedByYear:   []models.TimelineBucket{},
+		AddedByMonth:      []models.TimelineBucket{},
+	}
+	if len(facets) == 0 {
+		return timeline, nil
+	}
+	f := facets[0]
+	if f.PublishedByDecade != nil {
+		timeline.PublishedByDecade = f.PublishedByDecade
+	}
+	if f.PublishedByYear != nil {
+		timeline.PublishedByYear = f.PublishedByYear
+	}
+	if f.AddedByMonth != nil {
+		timeline.AddedByMonth = f.AddedByMonth
+	}
+	if len(f.Undated) > 0 {
+		timeline.Undated = f.Undated[0].Count
+	}
+	return timeline, nil
+}
@@ -2,6 +2,7 @@ package store
 
 import (
 	"context"
+	"time"
 
 	"github.com/kevinaaaquil/books/backend/models"
 	"go.mongodb.org/mongo-driver/bson"
@@ -12,15 +13,21 @@ import (
 
 // UsersCount returns the number of documents in the users collection.
 func (db *DB) UsersCount(ctx context.Context) (int64, error) {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
 	return db.Users().CountDocuments(ctx, bson.M{})
 }
 
 // AdminsCount returns the number of users with role admin.
 func (db *DB) AdminsCount(ctx context.Context) (int64, error) {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
 	return db.Users().CountDocuments(ctx, bson.M{"role": "admin"})
 }
 
 func (db *DB) UserByEmail(ctx context.Context, email string) (*models.User, error) {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
 	var u models.User
 	err := db.Users().FindOne(ctx, bson.M{"email": email}).Decode(&u)
 	if err == mongo.ErrNoDocuments {
@@ -34,6 +41,8 @@ func (db *DB) UserByEmail(ctx context.Context, email string) (*models.User, erro
 
 // UserByRole returns one user with the given role, or nil if none.
 func (db *DB) UserByRole(ctx context.Context, role string) (*models.User, error) {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
 	var u models.User
 	err := db.Users().FindOne(ctx, bson.M{"role": role}).Decode(&u)
 	if err == mongo.ErrNoDocuments {
@@ -46,6 +55,8 @@ func (db *DB) UserByRole(ctx context.Context, role string) (*models.User, error)
 }
 
 func (db *DB) CreateUser(ctx context.Context, user *models.User) (primitive.ObjectID, error) {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
 	res, err := db.Users().InsertOne(ctx, user, options.InsertOne())
 	if err != nil {
 		return primitive.NilObjectID, err
@@ -54,6 +65,8 @@ func (db *DB) CreateUser(ctx context.Context, user *models.User) (primitive.Obje
 }
 
 func (db *DB) UserByID(ctx context.Context, id primitive.ObjectID) (*models.User, error) {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
 	var u models.User
 	err := db.Users().FindOne(ctx, bson.M{"_id": id}).Decode(&u)
 	if err == mongo.ErrNoDocuments {
@@ -66,6 +79,8 @@ func (db *DB) UserByID(ctx context.Context, id primitive.ObjectID) (*models.User
 }
 
 func (db *DB) ListUsers(ctx context.Context) ([]models.User, error) {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
 	cur, err := db.Users().Find(ctx, bson.M{}, options.Find().SetSort(bson.M{"createdAt": 1}))
 	if err != nil {
 		return nil, err
@@ -79,6 +94,8 @@ func (db *DB) ListUsers(ctx context.Context) ([]models.User, error) {
 }
 
 func (db *DB) UpdateUser(ctx context.Context, id primitive.ObjectID, email *string, hashedPassword *string, role *string) error {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
 	updates := bson.M{}
 	if email != nil {
 		updates["email"] = *email
@@ -98,11 +115,51 @@ func (db *DB) UpdateUser(ctx context.Context, id primitive.ObjectID, email *stri
 
 // UpdateUserUseExtractedCover sets the current user's thumbnail preference (persisted in MongoDB).
 func (db *DB) UpdateUserUseExtractedCover(ctx context.Context, id primitive.ObjectID, useExtractedCover bool) error {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
 	_, err := db.Users().UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{"useExtractedCover": useExtractedCover}})
 	return err
 }
 
+func (db *DB) UpdateUserMaxContentRating(ctx context.Context, id primitive.ObjectID, maxRating string) error {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
+	_, err := db.Users().UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{"maxContentRating": maxRating}})
+	return err
+}
+
+func (db *DB) UpdateUserWeeklyDigest(ctx context.Context, id primitive.ObjectID, weeklyDigest bool) error {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
+	_, err := db.Users().UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{"weeklyDigest": weeklyDigest}})
+	return err
+}
+
+func (db *DB) SetUserDigestSentAt(ctx context.Context, id primitive.ObjectID, at time.Time) error {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
+	_, err := db.Users().UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{"digestSentAt": at}})
+	return err
+}
+
+// SetUserTermsAccepted records that the user accepted version of the terms of use at at.
+func (db *DB) SetUserTermsAccepted(ctx context.Context, id primitive.ObjectID, version int, at time.Time) error {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
+	_, err := db.Users().UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{"termsVersion": version, "termsAcceptedAt": at}})
+	return err
+}
+
+func (db *DB) SetUserOnboardedAt(ctx context.Context, id primitive.ObjectID, at time.Time) error {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
+	_, err := db.Users().UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{"onboardedAt": at}})
+	return err
+}
+
 func (db *DB) DeleteUser(ctx context.Context, id primitive.ObjectID) error {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
 	_, err := db.Users().DeleteOne(ctx, bson.M{"_id": id})
 	return err
 }
@@ -0,0 +1,74 @@
+package store
+
+import (
+	"context"
+
+	"github.com/kevinaaaquil/books/backend/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func (db *DB) InsertWishlistItem(ctx context.Context, item *models.WishlistItem) (primitive.ObjectID, error) {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
+	res, err := db.Wishlist().InsertOne(ctx, item)
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+	return res.InsertedID.(primitive.ObjectID), nil
+}
+
+// WishlistForUser returns the user's wishlist, newest first.
+func (db *DB) WishlistForUser(ctx context.Context, userID primitive.ObjectID) ([]models.WishlistItem, error) {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
+	cur, err := db.Wishlist().Find(ctx, bson.M{"userId": userID}, options.Find().SetSort(bson.M{"createdAt": -1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+	items := []models.WishlistItem{}
+	if err := cur.All(ctx, &items); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// UpdateWishlistItem replaces one of item.UserID's items. Returns false if it does not exist.
+func (db *DB) UpdateWishlistItem(ctx context.Context, item *models.WishlistItem) (bool, error) {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
+	res, err := db.Wishlist().ReplaceOne(ctx, bson.M{"_id": item.ID, "userId": item.UserID}, item)
+	if err != nil {
+		return false, err
+	}
+	return res.MatchedCount > 0, nil
+}
+
+// DeleteWishlistItem removes one of the user's items. Returns false if it does not exist.
+func (db *DB) DeleteWishlistItem(ctx context.Context, userID, id primitive.ObjectID) (bool, error) {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
+	res, err := db.Wishlist().DeleteOne(ctx, bson.M{"_id": id, "userId": userID})
+	if err != nil {
+		return false, err
+	}
+	return res.DeletedCount > 0, nil
+}
+
+// WatchedWishlistItems returns every user's items with a price watch.
+func (db *DB) WatchedWishlistItems(ctx context.Context) ([]models.WishlistItem, error) {
+	ctx, cancel := db.opCtx(ctx)
+	defer cancel()
+	cur, err := db.Wishlist().Find(ctx, bson.M{"priceWatch": bson.M{"$exists": true}})
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+	items := []models.WishlistItem{}
+	if err := cur.All(ctx, &items); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
@@ -0,0 +1,178 @@
+// Package systemmail sends the app's own emails (invites, password resets, notifications, digests) through a
+// service.Mailer and logs each one, separately from Send to Kindle's email log.
+//
+// Each template is one file defining "subject", "text" and "html" blocks. The built-in templates are embedded;
+// a file with the same name in the override directory (SYSTEM_MAIL_TEMPLATE_DIR) replaces one of them.
+package systemmail
+
+import (
+	"bytes"
+	"context"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	texttemplate "text/template"
+
+	"github.com/kevinaaaquil/books/backend/models"
+	"github.com/kevinaaaquil/books/backend/service"
+	"github.com/kevinaaaquil/books/backend/store"
+)
+
+// Template names.
+const (
+	Invite        = "invite"
+	PasswordReset = "password_reset"
+	Notification  = "notification"
+	WeeklyDigest  = "digest"
+)
+
+var templateNames = []string{Invite, PasswordReset, Notification, WeeklyDigest}
+
+//go:embed templates/*.html
+var builtin embed.FS
+
+// Data is what templates render. AppURL and To are filled in by Send; each template uses only some of the rest.
+type Data struct {
+	AppURL    string
+	To        string
+	Link      string  // absolute URL of the email's call to action
+	Expires   string  // how long Link stays valid, e.g. "1 hour"
+	Role      string  // invite
+	InvitedBy string  // invite
+	Title     string  // notification
+	Body      string  // notification
+	Digest    *Digest // digest
+}
+
+// Digest is what the weekly digest reports, since the last one.
+type Digest struct {
+	Since     string       // e.g. "Jan 2"
+	NewBooks  []DigestBook // newest first
+	MoreBooks int          // new books beyond those listed
+	Reading   []DigestBook // books the user read, most recent first, with Percent
+	Finished  int          // how many of Reading were finished
+	Requests  []DigestBook // the user's wishlist items the library still lacks
+}
+
+// DigestBook is one book in a Digest.
+type DigestBook struct {
+	Title   string
+	Authors string
+	Percent int
+}
+
+type tmpl struct {
+	text *texttemplate.Template // subject and plain-text body
+	html *htmltemplate.Template
+}
+
+// Service renders and sends system emails. Create it with New.
+type Service struct {
+	Mailer service.Mailer
+	From   string
+	AppURL string // frontend base URL used in links, without a trailing slash
+	DB     store.Store
+	Clock  service.Clock
+
+	templates map[string]tmpl
+}
+
+// New parses the templates, preferring files in overrideDir (may be empty) over the built-in ones.
+func New(mailer service.Mailer, from, appURL, overrideDir string, db store.Store, clock service.Clock) (*Service, error) {
+	s := &Service{
+		Mailer:    mailer,
+		From:      from,
+		AppURL:    strings.TrimSuffix(appURL, "/"),
+		DB:        db,
+		Clock:     clock,
+		templates: map[string]tmpl{},
+	}
+	for _, name := range templateNames {
+		src, err := builtin.ReadFile("templates/" + name + ".html")
+		if err != nil {
+			return nil, err
+		}
+		if overrideDir != "" {
+			custom, err := os.ReadFile(filepath.Join(overrideDir, name+".html"))
+			if err == nil {
+				log.Printf("system mail: using %s template from %s", name, overrideDir)
+				src = custom
+			} else if !os.IsNotExist(err) {
+				return nil, err
+			}
+		}
+		t, err := parse(name, string(src))
+		if err != nil {
+			return nil, err
+		}
+		s.templates[name] = t
+	}
+	return s, nil
+}
+
+func parse(name, src string) (tmpl, error) {
+	text, err := texttemplate.New(name).Parse(src)
+	if err != nil {
+		return tmpl{}, fmt.Errorf("%s template: %w", name, err)
+	}
+	html, err := htmltemplate.New(name).Parse(src)
+	if err != nil {
+		return tmpl{}, fmt.Errorf("%s template: %w", name, err)
+	}
+	for _, block := range []string{"subject", "text", "html"} {
+		if text.Lookup(block) == nil {
+			return tmpl{}, fmt.Errorf("%s template: missing {{define %q}}", name, block)
+		}
+	}
+	return tmpl{text: text, html: html}, nil
+}
+
+// URL returns the frontend URL for path (which starts with "/").
+func (s *Service) URL(path string) string {
+	return s.AppURL + path
+}
+
+// render returns the subject, plain-text body and HTML body of the named template.
+func (s *Service) render(name string, data Data) (subject, text, html string, err error) {
+	t, ok := s.templates[name]
+	if !ok {
+		return "", "", "", fmt.Errorf("unknown template %q", name)
+	}
+	var buf bytes.Buffer
+	if err := t.text.ExecuteTemplate(&buf, "subject", data); err != nil {
+		return "", "", "", err
+	}
+	subject = strings.Join(strings.Fields(buf.String()), " ")
+	buf.Reset()
+	if err := t.text.ExecuteTemplate(&buf, "text", data); err != nil {
+		return "", "", "", err
+	}
+	text = strings.TrimSpace(buf.String()) + "\n"
+	buf.Reset()
+	if err := t.html.ExecuteTemplate(&buf, "html", data); err != nil {
+		return "", "", "", err
+	}
+	return subject, text, buf.String(), nil
+}
+
+// Send renders the named template for to and sends it. Every attempt is recorded in the system email log.
+func (s *Service) Send(ctx context.Context, to, name string, data Data) error {
+	data.AppURL, data.To = s.AppURL, to
+	subject, text, html, err := s.render(name, data)
+	if err == nil {
+		err = s.Mailer.Send(ctx, &service.Mail{From: s.From, To: to, Subject: subject, Body: text, HTML: html})
+	}
+	entry := &models.SystemEmail{Template: name, ToEmail: to, Subject: subject, Status: models.SystemEmailSent, SentAt: s.Clock.Now()}
+	if err != nil {
+		entry.Status = models.SystemEmailFailed
+		entry.Error = err.Error()
+	}
+	if lerr := s.DB.InsertSystemEmail(ctx, entry); lerr != nil {
+		log.Printf("system mail: log %s to %s: %v", name, to, lerr)
+	}
+	return err
+}
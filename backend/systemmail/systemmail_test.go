@@ -0,0 +1,103 @@
+package systemmail
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBuiltinTemplates(t *testing.T) {
+	s, err := New(nil, "books@example.com", "https://books.example.com/", "", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := Data{AppURL: s.AppURL, To: "a@example.com", Link: s.URL("/reset-password?token=x&invite=1"), Expires: "7 days", Role: "viewer", InvitedBy: "<admin>"}
+	subject, text, html, err := s.render(Invite, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if subject != "You've been invited to Books" {
+		t.Errorf("subject = %q", subject)
+	}
+	if !strings.Contains(text, "<admin> has invited you") || !strings.Contains(text, "https://books.example.com/reset-password?token=x&invite=1") {
+		t.Errorf("text = %q", text)
+	}
+	if !strings.Contains(html, "&lt;admin&gt;") || !strings.Contains(html, `href="https://books.example.com/reset-password?token=x&amp;invite=1"`) {
+		t.Errorf("html = %q", html)
+	}
+	for _, name := range templateNames {
+		if _, _, _, err := s.render(name, data); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+}
+
+func TestTemplateOverride(t *testing.T) {
+	dir := t.TempDir()
+	custom := `{{define "subject"}}Reset for {{.To}}{{end}}{{define "text"}}Go to {{.Link}}{{end}}{{define "html"}}<a href="{{.Link}}">reset</a>{{end}}`
+	if err := os.WriteFile(filepath.Join(dir, PasswordReset+".html"), []byte(custom), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	s, err := New(nil, "", "", dir, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	subject, text, _, err := s.render(PasswordReset, Data{To: "a@example.com", Link: "/r"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if subject != "Reset for a@example.com" || text != "Go to /r\n" {
+		t.Errorf("got %q / %q", subject, text)
+	}
+	// Templates without an override still use the built-in one.
+	if subject, _, _, _ := s.render(Invite, Data{}); subject != "You've been invited to Books" {
+		t.Errorf("invite subject = %q", subject)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, Invite+".html"), []byte(`{{define "subject"}}x{{end}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := New(nil, "", "", dir, nil, nil); err == nil || !strings.Contains(err.Error(), `missing {{define "text"}}`) {
+		t.Errorf("incomplete override: err = %v", err)
+	}
+}
+
+func TestDigestTemplate(t *testing.T) {
+	s, err := New(nil, "", "https://books.example.com", "", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest := &Digest{
+		Since:     "Jan 2",
+		NewBooks:  []DigestBook{{Title: "Dune", Authors: "Frank Herbert"}, {Title: "<Untitled>"}},
+		MoreBooks: 3,
+		Reading:   []DigestBook{{Title: "Dune", Percent: 100}, {Title: "Rome", Percent: 40}},
+		Finished:  1,
+		Requests:  []DigestBook{{Title: "Hyperion", Authors: "Dan Simmons"}},
+	}
+	subject, text, html, err := s.render(WeeklyDigest, Data{AppURL: s.AppURL, Digest: digest})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if subject != "[Books] Your week in the library" {
+		t.Errorf("subject = %q", subject)
+	}
+	for _, want := range []string{"since Jan 2", "- Dune by Frank Herbert\n", "- and 3 more\n", "Your reading (1 finished):\n- Dune: 100%\n- Rome: 40%\n", "- Hyperion by Dan Simmons\n"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("text lacks %q:\n%s", want, text)
+		}
+	}
+	if !strings.Contains(html, "&lt;Untitled&gt;") || !strings.Contains(html, "Rome <span style=\"color:#78716c\">40%</span>") {
+		t.Errorf("html = %s", html)
+	}
+
+	// Sections with nothing to report are left out.
+	_, text, _, err = s.render(WeeklyDigest, Data{Digest: &Digest{Since: "Jan 2", Requests: digest.Requests}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(text, "New books") || strings.Contains(text, "Your reading") || !strings.Contains(text, "Still on your wishlist") {
+		t.Errorf("text = %s", text)
+	}
+}
@@ -0,0 +1,461 @@
+// Package telegram is a Telegram bot for the library: users link a private chat to their account, then search,
+// get book files, send books to their Kindle and upload by sending a file. Every command is carried out as a
+// request to the API on the linked user's behalf, so roles, content ratings, rate limits and maintenance mode
+// apply exactly as they do in the web app.
+package telegram
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/kevinaaaquil/books/backend/jobs"
+	"github.com/kevinaaaquil/books/backend/middleware"
+	"github.com/kevinaaaquil/books/backend/models"
+	"github.com/kevinaaaquil/books/backend/service"
+	"github.com/kevinaaaquil/books/backend/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// LinkCodeTTL is how long a code from LinkCode can be used to link a chat.
+const LinkCodeTTL = 15 * time.Minute
+
+// searchResults is how many books a search lists.
+const searchResults = 10
+
+// pollTimeout is how long each getUpdates call waits for messages.
+const pollTimeout = 25 * time.Second
+
+// Bot answers the messages sent to the Telegram bot.
+type Bot struct {
+	Client    *service.TelegramClient
+	API       http.Handler // the app's router; commands are requests to it
+	DB        store.Store
+	JWTSecret string
+	Clock     service.Clock
+}
+
+// LinkCode returns a code that links a chat to userID until expires, when sent to the bot as "/start <code>"
+// (which is what the t.me/<bot>?start=<code> deep link does). It is signed with a key derived from JWT_SECRET, so
+// nothing needs storing; it fits Telegram's 64-character limit on start parameters.
+func LinkCode(jwtSecret string, userID primitive.ObjectID, expires time.Time) string {
+	payload := userID.Hex() + "-" + strconv.FormatInt(expires.Unix(), 36)
+	return payload + "-" + linkCodeMAC(jwtSecret, payload)
+}
+
+func linkCodeMAC(jwtSecret, payload string) string {
+	mac := hmac.New(sha256.New, []byte("telegram-link:"+jwtSecret))
+	mac.Write([]byte(payload))
+	return hex.EncodeToString(mac.Sum(nil)[:12])
+}
+
+// parseLinkCode returns the user a code from LinkCode links to, if it is genuine and has not expired.
+func parseLinkCode(jwtSecret, code string, now time.Time) (primitive.ObjectID, bool) {
+	i := strings.LastIndex(code, "-")
+	if i < 0 {
+		return primitive.NilObjectID, false
+	}
+	payload := code[:i]
+	if !hmac.Equal([]byte(code[i+1:]), []byte(linkCodeMAC(jwtSecret, payload))) {
+		return primitive.NilObjectID, false
+	}
+	hexID, exp, ok := strings.Cut(payload, "-")
+	expires, err := strconv.ParseInt(exp, 36, 64)
+	if !ok || err != nil || now.Unix() > expires {
+		return primitive.NilObjectID, false
+	}
+	userID, err := primitive.ObjectIDFromHex(hexID)
+	return userID, err == nil
+}
+
+// Run answers messages until ctx is cancelled. Telegram delivers each update to one poller, so with several
+// instances only the leader polls.
+func (b *Bot) Run(ctx context.Context, leader *jobs.Leader) {
+	var offset int64
+	for ctx.Err() == nil {
+		if !leader.IsLeader() {
+			sleep(ctx, 5*time.Second)
+			continue
+		}
+		updates, err := b.Client.GetUpdates(ctx, offset, pollTimeout)
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Printf("telegram: %v", err)
+				sleep(ctx, 5*time.Second)
+			}
+			continue
+		}
+		for _, u := range updates {
+			offset = u.UpdateID + 1
+			if u.Message != nil {
+				b.handle(ctx, u.Message)
+			}
+		}
+	}
+}
+
+func sleep(ctx context.Context, d time.Duration) {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+	case <-t.C:
+	}
+}
+
+const helpText = `Send me a title or author to search the library.
+
+/get_<id> sends you the book file
+/kindle_<id> sends it to your Kindle
+Send an EPUB or PDF to add it to the library (editors and admins).
+/unlink disconnects this chat from your account.`
+
+const notLinkedText = "This chat isn't linked to a Books account yet. Open Telegram linking in the Books app and follow the link it gives you."
+
+// command splits a message into its command ("" for plain text) and argument. "/get_<id>" is "/get <id>", so
+// results can offer tappable commands; "/cmd@botname" is "/cmd".
+func command(text string) (cmd, arg string) {
+	text = strings.TrimSpace(text)
+	if !strings.HasPrefix(text, "/") {
+		return "", text
+	}
+	cmd, arg, _ = strings.Cut(text[1:], " ")
+	cmd, _, _ = strings.Cut(cmd, "@")
+	if c, id, ok := strings.Cut(cmd, "_"); ok {
+		cmd, arg = c, id
+	}
+	return strings.ToLower(cmd), strings.TrimSpace(arg)
+}
+
+// handle answers one message.
+func (b *Bot) handle(ctx context.Context, m *service.TelegramMessage) {
+	chatID := m.Chat.ID
+	reply := func(text string) {
+		if err := b.Client.SendMessage(ctx, chatID, text); err != nil {
+			log.Printf("telegram: reply to %d: %v", chatID, err)
+		}
+	}
+	if m.Chat.Type != "private" {
+		reply("I only work in private chats.")
+		return
+	}
+	cmd, arg := command(m.Text)
+	switch {
+	case (cmd == "start" || cmd == "link") && arg != "":
+		reply(b.link(ctx, m, arg))
+		return
+	case cmd == "start" || cmd == "help":
+		reply(helpText)
+		return
+	}
+	user, err := b.chatUser(ctx, chatID)
+	if err != nil {
+		log.Printf("telegram: %v", err)
+		reply("Something went wrong; try again later.")
+		return
+	}
+	if user == nil {
+		reply(notLinkedText)
+		return
+	}
+	switch {
+	case m.Document != nil:
+		reply(b.upload(ctx, user, m.Document))
+	case cmd == "":
+		reply(b.search(ctx, user, arg))
+	case cmd == "search":
+		reply(b.search(ctx, user, arg))
+	case cmd == "get":
+		if text := b.sendFile(ctx, user, chatID, arg); text != "" {
+			reply(text)
+		}
+	case cmd == "kindle":
+		reply(b.sendToKindle(ctx, user, arg))
+	case cmd == "unlink":
+		if _, err := b.DB.UnlinkTelegram(ctx, user.ID); err != nil {
+			log.Printf("telegram: unlink: %v", err)
+			reply("Something went wrong; try again later.")
+			return
+		}
+		reply("This chat is no longer linked to " + user.Email + ".")
+	default:
+		reply(helpText)
+	}
+}
+
+// chatUser returns the user a chat is linked to, or nil if it isn't linked to an existing user.
+func (b *Bot) chatUser(ctx context.Context, chatID int64) (*models.User, error) {
+	c, err := b.DB.TelegramChat(ctx, chatID)
+	if err != nil || c == nil {
+		return nil, err
+	}
+	return b.DB.UserByID(ctx, c.UserID)
+}
+
+func (b *Bot) link(ctx context.Context, m *service.TelegramMessage, code string) string {
+	now := b.Clock.Now()
+	userID, ok := parseLinkCode(b.JWTSecret, code, now)
+	if !ok {
+		return "That link has expired. Get a new one from the Books app."
+	}
+	user, err := b.DB.UserByID(ctx, userID)
+	if err != nil {
+		log.Printf("telegram: link: %v", err)
+		return "Something went wrong; try again later."
+	}
+	if user == nil || user.Role == models.RoleGuest {
+		return "That account can't be linked."
+	}
+	c := &models.TelegramChat{ChatID: m.Chat.ID, UserID: user.ID, LinkedAt: now}
+	if m.From != nil {
+		c.Username = m.From.Username
+	}
+	if err := b.DB.LinkTelegramChat(ctx, c); err != nil {
+		log.Printf("telegram: link: %v", err)
+		return "Something went wrong; try again later."
+	}
+	return "Linked to " + user.Email + ".\n\n" + helpText
+}
+
+// apiResponse records what the API answered.
+type apiResponse struct {
+	status int
+	header http.Header
+	body   bytes.Buffer
+}
+
+func (r *apiResponse) Header() http.Header { return r.header }
+
+func (r *apiResponse) Write(p []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	return r.body.Write(p)
+}
+
+func (r *apiResponse) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+}
+
+// error returns the message of an error response.
+func (r *apiResponse) error() string {
+	var e struct {
+		Error string `json:"error"`
+	}
+	if json.Unmarshal(r.body.Bytes(), &e) == nil && e.Error != "" {
+		return e.Error
+	}
+	return strings.ToLower(http.StatusText(r.status))
+}
+
+// call makes an API request as user, with a token valid for as long as the request takes.
+func (b *Bot) call(ctx context.Context, user *models.User, method, path, contentType string, body io.Reader) (*apiResponse, error) {
+	now := b.Clock.Now()
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, &middleware.Claims{
+		UserID: user.ID.Hex(),
+		Email:  user.Email,
+		Role:   user.Role,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(now.Add(time.Minute)),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
+	}).SignedString([]byte(b.JWTSecret))
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(middleware.WithVia(ctx, models.SourceTelegram), method, path, body)
+	if err != nil {
+		return nil, err
+	}
+	req.RemoteAddr = "127.0.0.1:0"
+	req.Header.Set("Authorization", "Bearer "+token)
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	res := &apiResponse{header: http.Header{}}
+	b.API.ServeHTTP(res, req)
+	if res.status == 0 {
+		res.status = http.StatusOK
+	}
+	return res, nil
+}
+
+func (b *Bot) search(ctx context.Context, user *models.User, q string) string {
+	if q == "" {
+		return "Send a title or author to search for."
+	}
+	res, err := b.call(ctx, user, http.MethodGet, "/api/books?q="+url.QueryEscape(q), "", nil)
+	if err != nil {
+		log.Printf("telegram: search: %v", err)
+		return "Something went wrong; try again later."
+	}
+	if res.status != http.StatusOK {
+		return "Search failed: " + res.error()
+	}
+	var books []models.Book
+	if err := json.Unmarshal(res.body.Bytes(), &books); err != nil {
+		return "Search failed."
+	}
+	if len(books) == 0 {
+		return "No books match " + strconv.Quote(q) + "."
+	}
+	var sb strings.Builder
+	for i, book := range books {
+		if i == searchResults {
+			fmt.Fprintf(&sb, "…and %d more. Narrow your search to see them.", len(books)-searchResults)
+			break
+		}
+		fmt.Fprintf(&sb, "%d. %s", i+1, truncate(book.Title, 100))
+		if len(book.Authors) > 0 {
+			sb.WriteString(" — " + truncate(strings.Join(book.Authors, ", "), 60))
+		}
+		fmt.Fprintf(&sb, " (%s)\n/get_%s  /kindle_%s\n\n", book.Format, book.ID.Hex(), book.ID.Hex())
+	}
+	return strings.TrimSpace(sb.String())
+}
+
+func truncate(s string, n int) string {
+	if r := []rune(s); len(r) > n {
+		return string(r[:n-1]) + "…"
+	}
+	return s
+}
+
+// book loads a book the user can see, returning the reply to send instead when there is none.
+func (b *Bot) book(ctx context.Context, user *models.User, id string) (*models.Book, string) {
+	if _, err := primitive.ObjectIDFromHex(id); err != nil {
+		return nil, "Which book? Search first, then tap one of the commands in the results."
+	}
+	res, err := b.call(ctx, user, http.MethodGet, "/api/books/"+id, "", nil)
+	if err != nil {
+		log.Printf("telegram: book: %v", err)
+		return nil, "Something went wrong; try again later."
+	}
+	if res.status != http.StatusOK {
+		return nil, "Can't get that book: " + res.error()
+	}
+	var book models.Book
+	if err := json.Unmarshal(res.body.Bytes(), &book); err != nil {
+		return nil, "Can't get that book."
+	}
+	return &book, ""
+}
+
+// sendFile sends a book's file to the chat. It returns the reply to send instead, or "" once the file is sent.
+func (b *Bot) sendFile(ctx context.Context, user *models.User, chatID int64, id string) string {
+	book, text := b.book(ctx, user, id)
+	if book == nil {
+		return text
+	}
+	if book.SizeBytes > service.TelegramMaxUploadBytes {
+		return "That book is too large to send through Telegram; download it from the Books app."
+	}
+	res, err := b.call(ctx, user, http.MethodGet, "/api/books/"+id+"/download?mode=stream", "", nil)
+	if err != nil {
+		log.Printf("telegram: download: %v", err)
+		return "Something went wrong; try again later."
+	}
+	if res.status == http.StatusAccepted {
+		return "That book is archived and is being restored; try again in a few hours."
+	}
+	if res.status != http.StatusOK {
+		return "Can't download that book: " + res.error()
+	}
+	if res.body.Len() > service.TelegramMaxUploadBytes {
+		return "That book is too large to send through Telegram; download it from the Books app."
+	}
+	name := book.ID.Hex() + "." + book.Format
+	if _, params, err := mime.ParseMediaType(res.header.Get("Content-Disposition")); err == nil && params["filename"] != "" {
+		name = params["filename"]
+	}
+	if err := b.Client.SendDocument(ctx, chatID, name, &res.body, book.Title); err != nil {
+		log.Printf("telegram: send %s: %v", id, err)
+		return "Couldn't send the file; try again later."
+	}
+	return ""
+}
+
+func (b *Bot) sendToKindle(ctx context.Context, user *models.User, id string) string {
+	book, text := b.book(ctx, user, id)
+	if book == nil {
+		return text
+	}
+	res, err := b.call(ctx, user, http.MethodPost, "/api/books/"+id+"/send-to-kindle", "application/json", strings.NewReader("{}"))
+	if err != nil {
+		log.Printf("telegram: send to kindle: %v", err)
+		return "Something went wrong; try again later."
+	}
+	if res.status != http.StatusAccepted {
+		return "Couldn't send " + book.Title + ": " + res.error()
+	}
+	return "Sending " + book.Title + " to your Kindle."
+}
+
+// upload adds a file sent to the bot to the library.
+func (b *Bot) upload(ctx context.Context, user *models.User, doc *service.TelegramDocument) string {
+	if doc.FileSize > service.TelegramMaxDownloadBytes {
+		return "Telegram only lets bots receive files up to 20 MB; upload this one in the Books app."
+	}
+	file, err := b.Client.DownloadFile(ctx, doc.FileID)
+	if err != nil {
+		log.Printf("telegram: upload: %v", err)
+		return "Couldn't get the file from Telegram; try again."
+	}
+	defer file.Close()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	name := doc.FileName
+	if name == "" {
+		name = "book"
+	}
+	part, err := mw.CreateFormFile("file", name)
+	if err == nil {
+		_, err = io.Copy(part, file)
+	}
+	if err == nil {
+		err = mw.Close()
+	}
+	if err != nil {
+		log.Printf("telegram: upload: %v", err)
+		return "Couldn't get the file from Telegram; try again."
+	}
+	res, err := b.call(ctx, user, http.MethodPost, "/api/upload", mw.FormDataContentType(), &body)
+	if err != nil {
+		log.Printf("telegram: upload: %v", err)
+		return "Something went wrong; try again later."
+	}
+	if res.status != http.StatusOK && res.status != http.StatusCreated {
+		return "Couldn't add " + name + ": " + res.error()
+	}
+	var added struct {
+		ID          string   `json:"id"`
+		Title       string   `json:"title"`
+		FailedSteps []string `json:"failedSteps"`
+	}
+	json.Unmarshal(res.body.Bytes(), &added)
+	title := added.Title
+	if title == "" {
+		title = name
+	}
+	text := "Added " + title + " to the library.\n/get_" + added.ID + "  /kindle_" + added.ID
+	if len(added.FailedSteps) > 0 {
+		text += "\n(Some steps failed: " + strings.Join(added.FailedSteps, ", ") + "; they can be retried in the app.)"
+	}
+	return text
+}
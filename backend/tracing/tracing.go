@@ -0,0 +1,84 @@
+// Package tracing sets up OpenTelemetry for requests: the router's span of each sampled request (see
+// middleware.Trace), with the database and storage calls and the steps made while serving it as children,
+// exported to a collector over OTLP/HTTP so a slow request can be broken down into its parts. Spans are only
+// recorded under a sampled request: work started outside one, such as a job, is not traced.
+package tracing
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// scope names the spans this repo starts itself, as opposed to those of the instrumentation libraries.
+const scope = "github.com/kevinaaaquil/books/backend"
+
+// Propagator reads and writes the W3C traceparent header.
+var Propagator = propagation.TraceContext{}
+
+// New returns a provider exporting, in batches, to url (the collector's traces endpoint, e.g.
+// http://collector:4318/v1/traces) with headers, naming the service serviceName. sample is the share of requests
+// traced, from 0 to 1, for requests that don't come with a traceparent; a caller's decision is kept. Shut it down
+// to flush the last spans.
+func New(url string, headers map[string]string, serviceName string, sample float64) (*sdktrace.TracerProvider, error) {
+	exporter, err := otlptracehttp.New(context.Background(),
+		otlptracehttp.WithEndpointURL(url),
+		otlptracehttp.WithHeaders(headers),
+		otlptracehttp.WithTimeout(10*time.Second),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewSchemaless(semconv.ServiceName(serviceName))),
+		sdktrace.WithSampler(sdktrace.ParentBased(requestsOnly{sdktrace.TraceIDRatioBased(sample)})),
+	), nil
+}
+
+// Register makes tp the provider of the MongoDB and S3 instrumentation, which start their spans from the global
+// one.
+func Register(tp trace.TracerProvider) {
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(Propagator)
+}
+
+// requestsOnly samples new traces by rate, but only those of requests served: a database or storage call made
+// outside a request has no parent and is dropped.
+type requestsOnly struct {
+	rate sdktrace.Sampler
+}
+
+func (s requestsOnly) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	if p.Kind != trace.SpanKindServer {
+		return sdktrace.SamplingResult{Decision: sdktrace.Drop, Tracestate: trace.SpanContextFromContext(p.ParentContext).TraceState()}
+	}
+	return s.rate.ShouldSample(p)
+}
+
+func (s requestsOnly) Description() string {
+	return "RequestsOnly{" + s.rate.Description() + "}"
+}
+
+// Start begins a span under the one ctx is in, from the same provider, returning a context with the new span.
+// Without a span in ctx the span records nothing.
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return trace.SpanFromContext(ctx).TracerProvider().Tracer(scope).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// SetError marks span failed with err; a nil err changes nothing.
+func SetError(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+}
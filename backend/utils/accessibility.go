@@ -0,0 +1,63 @@
+package utils
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/kevinaaaquil/books/backend/models"
+)
+
+// EPUBAccessibility returns the schema.org accessibility metadata in an EPUB's OPF: EPUB 3
+// <meta property="schema:accessibilityFeature">alternativeText</meta> or EPUB 2
+// <meta name="schema:accessibilityFeature" content="alternativeText"/>. It returns nil when there is none.
+func EPUBAccessibility(pkg *Package) *models.Accessibility {
+	var a models.Accessibility
+	found := false
+	for _, m := range pkg.Metadata.Meta {
+		key := m.Property
+		if key == "" {
+			key = m.Name
+		}
+		if m.Refines != "" {
+			continue // refines another element, not the publication
+		}
+		key, ok := strings.CutPrefix(strings.ToLower(strings.TrimSpace(key)), "schema:")
+		if !ok {
+			continue
+		}
+		value := strings.Join(strings.Fields(m.Value), " ")
+		if value == "" {
+			value = strings.Join(strings.Fields(m.Content), " ")
+		}
+		if value == "" {
+			continue
+		}
+		switch key {
+		case "accessibilityfeature":
+			a.Features = appendUnique(a.Features, value)
+		case "accessmode":
+			a.AccessModes = appendUnique(a.AccessModes, value)
+		case "accessmodesufficient":
+			a.AccessModesSufficient = appendUnique(a.AccessModesSufficient, strings.ReplaceAll(value, " ", ""))
+		case "accessibilityhazard":
+			a.Hazards = appendUnique(a.Hazards, value)
+		case "accessibilitysummary":
+			a.Summary = value
+		default:
+			continue
+		}
+		found = true
+	}
+	if !found {
+		return nil
+	}
+	return &a
+}
+
+// appendUnique appends v to list unless it is already there, ignoring case.
+func appendUnique(list []string, v string) []string {
+	if slices.ContainsFunc(list, func(s string) bool { return strings.EqualFold(s, v) }) {
+		return list
+	}
+	return append(list, v)
+}
@@ -0,0 +1,50 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Chapter is a document of an EPUB's reading order that has text.
+type Chapter struct {
+	Title string // its first heading, or the document title; "Chapter N" when it has neither
+	Text  string
+}
+
+// EPUBChapters returns the documents of an EPUB that have text, in reading order.
+func EPUBChapters(fileBytes []byte) ([]Chapter, error) {
+	reader, opfPath, pkg, err := readEPUBPackage(fileBytes)
+	if err != nil {
+		return nil, err
+	}
+	hrefs := map[string]string{}
+	for _, item := range pkg.Manifest.Items {
+		if item.MediaType == "application/xhtml+xml" || item.MediaType == "text/html" {
+			hrefs[item.ID] = item.Href
+		}
+	}
+	var chapters []Chapter
+	for _, ref := range pkg.Spine.ItemRefs {
+		href, ok := hrefs[ref.IDRef]
+		if !ok {
+			continue
+		}
+		doc, err := findAndReadFileFromZip(reader, resolveOPFHref(opfPath, href))
+		if err != nil {
+			continue
+		}
+		p := sanitizePreview(doc, 0)
+		if p.Words == 0 {
+			continue
+		}
+		title := p.Chapter
+		if title == "" {
+			title = fmt.Sprintf("Chapter %d", len(chapters)+1)
+		}
+		chapters = append(chapters, Chapter{Title: title, Text: strings.TrimSpace(p.Text)})
+	}
+	if len(chapters) == 0 {
+		return nil, fmt.Errorf("no text found in EPUB")
+	}
+	return chapters, nil
+}
@@ -0,0 +1,211 @@
+package utils
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/kevinaaaquil/books/backend/models"
+)
+
+// readEPUBPackage opens an EPUB held in memory and parses its OPF. Returns the zip reader, the OPF path and the package.
+func readEPUBPackage(fileBytes []byte) (*zip.Reader, string, *Package, error) {
+	return readEPUBPackageAt(bytes.NewReader(fileBytes), int64(len(fileBytes)))
+}
+
+// readEPUBPackageAt is readEPUBPackage for an EPUB of size bytes read through r, such as a file on disk.
+func readEPUBPackageAt(r io.ReaderAt, size int64) (*zip.Reader, string, *Package, error) {
+	if size == 0 {
+		return nil, "", nil, fmt.Errorf("empty file")
+	}
+	reader, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("invalid EPUB file (not a valid ZIP): %v", err)
+	}
+	containerFile, err := findAndReadFileFromZip(reader, "META-INF/container.xml")
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("failed to read container.xml: %v", err)
+	}
+	var container Container
+	if err := xml.Unmarshal(containerFile, &container); err != nil {
+		return nil, "", nil, fmt.Errorf("failed to parse container.xml: %v", err)
+	}
+	if len(container.RootFiles.RootFile) == 0 {
+		return nil, "", nil, fmt.Errorf("no rootfile found in container.xml")
+	}
+	opfPath := container.RootFiles.RootFile[0].FullPath
+	opfContent, err := findAndReadFileFromZip(reader, opfPath)
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("failed to read OPF file: %v", err)
+	}
+	var pkg Package
+	if err := xml.Unmarshal(opfContent, &pkg); err != nil {
+		return nil, "", nil, fmt.Errorf("failed to parse OPF file: %v", err)
+	}
+	return reader, opfPath, &pkg, nil
+}
+
+// resolveOPFHref returns the zip path of a manifest href, which is relative to the OPF's directory.
+func resolveOPFHref(opfPath, href string) string {
+	dir := ""
+	if idx := strings.LastIndex(opfPath, "/"); idx >= 0 {
+		dir = opfPath[:idx+1]
+	}
+	return normalizeZipPath(dir + href)
+}
+
+// htmlText returns the visible text of an (X)HTML document, skipping script and style elements.
+// The decoder runs in non-strict mode so HTML entities and unclosed tags don't abort extraction.
+func htmlText(doc []byte) string {
+	d := xml.NewDecoder(bytes.NewReader(doc))
+	d.Strict = false
+	d.AutoClose = xml.HTMLAutoClose
+	d.Entity = xml.HTMLEntity
+	var sb strings.Builder
+	skip := 0
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			break
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if name := strings.ToLower(t.Name.Local); name == "script" || name == "style" {
+				skip++
+			}
+		case xml.EndElement:
+			if name := strings.ToLower(t.Name.Local); (name == "script" || name == "style") && skip > 0 {
+				skip--
+			}
+		case xml.CharData:
+			if skip == 0 {
+				sb.Write(t)
+				sb.WriteByte(' ')
+			}
+		}
+	}
+	return sb.String()
+}
+
+// EPUBText returns the visible text of all XHTML documents in an EPUB's manifest, in manifest order.
+func EPUBText(fileBytes []byte) (string, error) {
+	return EPUBTextAt(bytes.NewReader(fileBytes), int64(len(fileBytes)))
+}
+
+// EPUBTextAt is EPUBText for an EPUB of size bytes read through r.
+func EPUBTextAt(r io.ReaderAt, size int64) (string, error) {
+	reader, opfPath, pkg, err := readEPUBPackageAt(r, size)
+	if err != nil {
+		return "", err
+	}
+	var sb strings.Builder
+	for _, item := range pkg.Manifest.Items {
+		if item.MediaType != "application/xhtml+xml" && item.MediaType != "text/html" {
+			continue
+		}
+		doc, err := findAndReadFileFromZip(reader, resolveOPFHref(opfPath, item.Href))
+		if err != nil {
+			continue
+		}
+		sb.WriteString(htmlText(doc))
+		sb.WriteByte('\n')
+	}
+	return sb.String(), nil
+}
+
+// CountEPUBWords counts the words in all XHTML documents of an EPUB's manifest.
+func CountEPUBWords(fileBytes []byte) (int, error) {
+	text, err := EPUBText(fileBytes)
+	if err != nil {
+		return 0, err
+	}
+	return len(strings.Fields(text)), nil
+}
+
+// pdfPageObject matches page objects ("/Type /Page") but not the page tree ("/Type /Pages").
+var pdfPageObject = regexp.MustCompile(`/Type\s*/Page[^s]`)
+
+// CountPDFPages estimates a PDF's page count by counting page objects. Returns 0 when none are visible
+// (e.g. page objects inside compressed object streams).
+func CountPDFPages(fileBytes []byte) int {
+	return len(pdfPageObject.FindAllIndex(fileBytes, -1))
+}
+
+// countPDFPagesAt is CountPDFPages for a PDF of size bytes read through r, scanned a chunk at a time. Each chunk
+// keeps the end of the one before, so page objects split between them are counted once.
+func countPDFPagesAt(r io.ReaderAt, size int64) (int, error) {
+	const chunk, overlap = 1 << 20, 64
+	pages := 0
+	window := make([]byte, 0, chunk+overlap)
+	for off := int64(0); off < size; {
+		n := int(min(chunk, size-off))
+		start := len(window)
+		window = window[:start+n]
+		if _, err := r.ReadAt(window[start:], off); err != nil && err != io.EOF {
+			return pages, err
+		}
+		off += int64(n)
+		// Matches starting in the kept end are counted with the next chunk.
+		limit := len(window) - overlap
+		if off >= size {
+			limit = len(window)
+		}
+		for _, m := range pdfPageObject.FindAllIndex(window, -1) {
+			if m[0] < limit {
+				pages++
+			}
+		}
+		keep := min(overlap, len(window))
+		copy(window, window[len(window)-keep:])
+		window = window[:keep]
+	}
+	return pages, nil
+}
+
+// ComputeFileInfo hashes and measures a book file of the given format ("epub" or "pdf").
+// For EPUBs the returned error reports that the file could not be parsed; size and hash are still set.
+func ComputeFileInfo(fileBytes []byte, format string) (models.FileInfo, error) {
+	sum := sha256.Sum256(fileBytes)
+	return fileInfoAt(bytes.NewReader(fileBytes), int64(len(fileBytes)), hex.EncodeToString(sum[:]), format)
+}
+
+// ComputeFileInfoAt is ComputeFileInfo for a file of size bytes read through r, which is never held in memory
+// whole. sha256Hex is the file's checksum when it is known already, or "" to compute it.
+func ComputeFileInfoAt(r io.ReaderAt, size int64, sha256Hex, format string) (models.FileInfo, error) {
+	if sha256Hex == "" {
+		h := sha256.New()
+		if _, err := io.Copy(h, io.NewSectionReader(r, 0, size)); err != nil {
+			return models.FileInfo{SizeBytes: size}, err
+		}
+		sha256Hex = hex.EncodeToString(h.Sum(nil))
+	}
+	return fileInfoAt(r, size, sha256Hex, format)
+}
+
+func fileInfoAt(r io.ReaderAt, size int64, sha256Hex, format string) (models.FileInfo, error) {
+	info := models.FileInfo{SizeBytes: size, SHA256: sha256Hex}
+	switch format {
+	case "epub":
+		text, err := EPUBTextAt(r, size)
+		if err != nil {
+			return info, err
+		}
+		info.WordCount = len(strings.Fields(text))
+		if _, _, pkg, err := readEPUBPackageAt(r, size); err == nil {
+			info.Accessibility = EPUBAccessibility(pkg)
+		}
+	case "pdf":
+		pages, err := countPDFPagesAt(r, size)
+		if err != nil {
+			return info, err
+		}
+		info.FilePageCount = pages
+	}
+	return info, nil
+}
@@ -0,0 +1,172 @@
+package utils
+
+import (
+	"bytes"
+	"compress/zlib"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// doiPattern is Crossref's pattern for modern DOIs.
+var doiPattern = regexp.MustCompile(`(?i)\b10\.\d{4,9}/[-._;()/:a-z0-9]+`)
+
+const (
+	maxDOIStreams     = 64      // content streams searched, from the start of the file
+	maxDOIStreamBytes = 4 << 20 // decompressed bytes read from each
+	// A PDF read from disk is searched in its first pdfDOIHead bytes, holding the first page and usually the
+	// metadata, and its last pdfDOITail, where the info dictionary of an updated file is.
+	pdfDOIHead = 16 << 20
+	pdfDOITail = 1 << 20
+)
+
+// ExtractDOIFromPDF returns the DOI of a paper or thesis: the first one in the PDF's uncompressed metadata (the
+// document info dictionary or XMP), else the first in the text of its content streams, which for papers is their
+// own DOI on the first page. Returns "" when there is none. Text drawn with embedded CID fonts is not readable
+// this way, so some PDFs' DOIs are missed.
+func ExtractDOIFromPDF(fileBytes []byte) string {
+	if doi := findDOI(pdfMetadata(fileBytes)); doi != "" {
+		return doi
+	}
+	return contentDOI(fileBytes)
+}
+
+// ExtractDOIFromPDFAt is ExtractDOIFromPDF for a PDF of size bytes read through r. Only the start and end of
+// large files are read, so a DOI only in their middle is missed.
+func ExtractDOIFromPDFAt(r io.ReaderAt, size int64) string {
+	if size <= pdfDOIHead+pdfDOITail {
+		data := make([]byte, size)
+		if _, err := r.ReadAt(data, 0); err != nil && err != io.EOF {
+			return ""
+		}
+		return ExtractDOIFromPDF(data)
+	}
+	head, tail := make([]byte, pdfDOIHead), make([]byte, pdfDOITail)
+	if _, err := r.ReadAt(head, 0); err != nil {
+		return ""
+	}
+	if _, err := r.ReadAt(tail, size-pdfDOITail); err != nil && err != io.EOF {
+		return ""
+	}
+	if doi := findDOI(pdfMetadata(head) + "\n" + pdfMetadata(tail)); doi != "" {
+		return doi
+	}
+	return contentDOI(head)
+}
+
+// contentDOI returns the first DOI in the text of a PDF's content streams.
+func contentDOI(fileBytes []byte) string {
+	rest := fileBytes
+	for i := 0; i < maxDOIStreams; i++ {
+		start := bytes.Index(rest, []byte("stream"))
+		if start < 0 {
+			break
+		}
+		dict := rest[max(0, start-512):start]
+		body := rest[start+len("stream"):]
+		body = bytes.TrimPrefix(bytes.TrimPrefix(body, []byte("\r")), []byte("\n"))
+		end := bytes.Index(body, []byte("endstream"))
+		if end < 0 {
+			break
+		}
+		rest = body[end+len("endstream"):]
+		if bytes.Contains(dict[bytes.LastIndex(dict, []byte("<<"))+1:], []byte("/Image")) {
+			continue
+		}
+		content := body[:end]
+		if bytes.Contains(dict, []byte("/FlateDecode")) {
+			zr, err := zlib.NewReader(bytes.NewReader(content))
+			if err != nil {
+				continue
+			}
+			content, _ = io.ReadAll(io.LimitReader(zr, maxDOIStreamBytes))
+			zr.Close()
+		}
+		if doi := findDOI(pdfStrings(content)); doi != "" {
+			return doi
+		}
+	}
+	return ""
+}
+
+// pdfMetadata returns the parts of a PDF outside its streams, where the info dictionary and usually the XMP
+// packet are.
+func pdfMetadata(fileBytes []byte) string {
+	var b strings.Builder
+	rest := fileBytes
+	for {
+		start := bytes.Index(rest, []byte("stream"))
+		if start < 0 {
+			b.Write(rest)
+			break
+		}
+		b.Write(rest[:start])
+		end := bytes.Index(rest[start:], []byte("endstream"))
+		if end < 0 {
+			break
+		}
+		// XMP packets are stored as uncompressed streams; keep them.
+		if s := rest[start : start+end]; bytes.Contains(s, []byte("<x:xmpmeta")) || bytes.Contains(s, []byte("<rdf:RDF")) {
+			b.Write(s)
+		}
+		rest = rest[start+end+len("endstream"):]
+	}
+	return b.String()
+}
+
+// pdfStrings returns the literal strings a content stream draws. Strings in one TJ array are joined, since
+// kerning splits words across them; others are separated by spaces.
+func pdfStrings(content []byte) string {
+	var b strings.Builder
+	inArray := false
+	for i := 0; i < len(content); i++ {
+		switch content[i] {
+		case '[':
+			inArray = true
+		case ']':
+			inArray = false
+			b.WriteByte(' ')
+		case '(':
+			depth := 1
+			for i++; i < len(content) && depth > 0; i++ {
+				switch c := content[i]; c {
+				case '\\':
+					i++
+					if i < len(content) && strings.IndexByte("()\\", content[i]) >= 0 {
+						b.WriteByte(content[i])
+					}
+				case '(':
+					depth++
+					b.WriteByte(c)
+				case ')':
+					if depth--; depth > 0 {
+						b.WriteByte(c)
+					}
+				default:
+					b.WriteByte(c)
+				}
+			}
+			i--
+			if !inArray {
+				b.WriteByte(' ')
+			}
+		}
+	}
+	return b.String()
+}
+
+// findDOI returns the first DOI in s without the punctuation that ends the sentence around it.
+func findDOI(s string) string {
+	doi := doiPattern.FindString(s)
+	for doi != "" {
+		trimmed := strings.TrimRight(doi, ".,;:")
+		if strings.HasSuffix(trimmed, ")") && strings.Count(trimmed, ")") > strings.Count(trimmed, "(") {
+			trimmed = trimmed[:len(trimmed)-1]
+		}
+		if trimmed == doi {
+			break
+		}
+		doi = trimmed
+	}
+	return doi
+}
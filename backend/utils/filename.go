@@ -0,0 +1,130 @@
+package utils
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/kevinaaaquil/books/backend/models"
+	"golang.org/x/text/unicode/norm"
+)
+
+// DefaultFilenameTemplate is used for downloads and Kindle attachments when DOWNLOAD_FILENAME_TEMPLATE is unset.
+const DefaultFilenameTemplate = "{Author} - {Title}.{ext}"
+
+// filenameUnsafe are characters not allowed (or awkward) in filenames on common filesystems.
+const filenameUnsafe = `/\?%*:|"<>`
+
+var (
+	placeholderPattern = regexp.MustCompile(`\{[A-Za-z]+\}`)
+	repeatedSeparators = regexp.MustCompile(`(\s*[-_]\s*){2,}`)
+	repeatedSpaces     = regexp.MustCompile(`\s{2,}`)
+)
+
+// RenderFilename builds a download filename for book from tmpl. Supported placeholders:
+// {Title}, {Author} (first author), {Authors} (comma-separated), {Year}, {ISBN}, {Format}, {ext} and {OriginalName}.
+// Separators left dangling by empty placeholders are removed; if nothing usable remains the original
+// filename (or "book.<ext>") is returned.
+func RenderFilename(tmpl string, book *models.Book) string {
+	ext := strings.ToLower(strings.TrimPrefix(book.Format, "."))
+	if ext == "" {
+		ext = strings.TrimPrefix(strings.ToLower(filepath.Ext(book.OriginalName)), ".")
+	}
+	if ext == "" {
+		ext = "epub"
+	}
+	if tmpl == "" {
+		tmpl = DefaultFilenameTemplate
+	}
+	values := map[string]string{
+		"{Title}":        book.Title,
+		"{Author}":       "",
+		"{Authors}":      strings.Join(book.Authors, ", "),
+		"{Year}":         "",
+		"{ISBN}":         book.ISBN,
+		"{Format}":       strings.ToUpper(ext),
+		"{ext}":          ext,
+		"{OriginalName}": strings.TrimSuffix(book.OriginalName, filepath.Ext(book.OriginalName)),
+	}
+	if len(book.Authors) > 0 {
+		values["{Author}"] = book.Authors[0]
+	}
+	if len(book.PublishDate) >= 4 {
+		values["{Year}"] = book.PublishDate[:4]
+	}
+	// Render the base name and extension separately so cleanup never eats the dot before the extension.
+	base := strings.TrimSuffix(tmpl, ".{ext}")
+	render := func(s string) string {
+		return placeholderPattern.ReplaceAllStringFunc(s, func(p string) string {
+			v, ok := values[p]
+			if !ok {
+				return p
+			}
+			return sanitizeFilenamePart(v)
+		})
+	}
+	name := render(base)
+	name = repeatedSeparators.ReplaceAllStringFunc(name, func(s string) string {
+		if strings.Contains(s, "-") {
+			return " - "
+		}
+		return "_"
+	})
+	name = repeatedSpaces.ReplaceAllString(name, " ")
+	name = strings.Trim(name, " -_.")
+	if name == "" {
+		if book.OriginalName != "" && !strings.Contains(book.OriginalName, "/") {
+			// A converted book keeps its original name but not its extension.
+			return strings.TrimSuffix(book.OriginalName, filepath.Ext(book.OriginalName)) + "." + ext
+		}
+		return "book." + ext
+	}
+	if !strings.HasSuffix(strings.ToLower(name), "."+ext) {
+		name += "." + ext
+	}
+	return name
+}
+
+// asciiSpellings spell out letters that don't decompose into an ASCII letter and accents, and typographic
+// punctuation.
+var asciiSpellings = strings.NewReplacer(
+	"ß", "ss", "æ", "ae", "Æ", "AE", "œ", "oe", "Œ", "OE", "ø", "o", "Ø", "O", "ł", "l", "Ł", "L",
+	"đ", "d", "Đ", "D", "ð", "d", "Ð", "D", "þ", "th", "Þ", "Th", "ı", "i",
+	"‘", "'", "’", "'", "“", "'", "”", "'", "–", "-", "—", "-", "…", "...",
+)
+
+// ASCIIFilename returns name in printable ASCII, for mail attachments: Send to Kindle and some mail clients
+// mangle other characters. Accents are dropped, a few letters spelled out and other characters removed. The
+// name ends in .ext, and is "book.<ext>" when nothing is left of it.
+func ASCIIFilename(name, ext string) string {
+	ext = strings.ToLower(strings.TrimPrefix(ext, "."))
+	if e := filepath.Ext(name); strings.EqualFold(e, "."+ext) {
+		name = strings.TrimSuffix(name, e)
+	}
+	var b strings.Builder
+	for _, r := range norm.NFD.String(asciiSpellings.Replace(name)) {
+		if r >= ' ' && r <= '~' && !strings.ContainsRune(filenameUnsafe, r) {
+			b.WriteRune(r)
+		}
+	}
+	name = repeatedSeparators.ReplaceAllStringFunc(b.String(), func(s string) string {
+		if strings.Contains(s, "-") {
+			return " - "
+		}
+		return "_"
+	})
+	name = strings.Trim(repeatedSpaces.ReplaceAllString(name, " "), " -_.")
+	if name == "" {
+		name = "book"
+	}
+	return name + "." + ext
+}
+
+func sanitizeFilenamePart(s string) string {
+	s = strings.TrimSpace(s)
+	s = strings.ReplaceAll(s, ": ", " - ") // "Dune: Messiah" -> "Dune - Messiah"
+	for _, c := range filenameUnsafe {
+		s = strings.ReplaceAll(s, string(c), "-")
+	}
+	return s
+}
@@ -4,11 +4,15 @@ import (
 	"archive/zip"
 	"bytes"
 	"encoding/xml"
+	"errors"
 	"fmt"
 	"io"
 	"strings"
 )
 
+// ErrNoISBN is returned by ExtractISBNFromEPUB and ExtractISBNFromMultipartFile when the EPUB is readable but carries no ISBN.
+var ErrNoISBN = errors.New("no ISBN found in EPUB metadata")
+
 // Container represents the EPUB container.xml structure
 type Container struct {
 	XMLName   xml.Name `xml:"container"`
@@ -20,7 +24,7 @@ type Container struct {
 	} `xml:"rootfiles"`
 }
 
-// Package represents the EPUB OPF package structure (partial, for ISBN and cover)
+// Package represents the EPUB OPF package structure (partial, for ISBN, title and creators, cover and reading order)
 type Package struct {
 	XMLName  xml.Name `xml:"package"`
 	Metadata struct {
@@ -34,7 +38,14 @@ type Package struct {
 			Property string `xml:"property,attr"`
 			Refines  string `xml:"refines,attr"`
 			Content  string `xml:"content,attr"`
+			Value    string `xml:",chardata"` // EPUB 3 metas hold their value here, not in content
 		} `xml:"meta"`
+		Titles   []string `xml:"title"`
+		Creators []struct {
+			ID    string `xml:"id,attr"`
+			Role  string `xml:"role,attr"` // EPUB 2 opf:role; EPUB 3 refines it with a role meta
+			Value string `xml:",chardata"`
+		} `xml:"creator"`
 	} `xml:"metadata"`
 	Manifest struct {
 		Items []struct {
@@ -43,6 +54,11 @@ type Package struct {
 			MediaType string `xml:"media-type,attr"`
 		} `xml:"item"`
 	} `xml:"manifest"`
+	Spine struct {
+		ItemRefs []struct {
+			IDRef string `xml:"idref,attr"`
+		} `xml:"itemref"`
+	} `xml:"spine"`
 }
 
 // GoogleBooksResponse represents the response structure from Google Books API
@@ -77,8 +93,15 @@ func ExtractISBNFromMultipartFile(file io.Reader) (string, error) {
 		return "", fmt.Errorf("uploaded file is empty")
 	}
 
-	fileBytes := buffer.Bytes()
-	reader, err := zip.NewReader(bytes.NewReader(fileBytes), size)
+	return ExtractISBNFromEPUB(bytes.NewReader(buffer.Bytes()), size)
+}
+
+// ExtractISBNFromEPUB returns the ISBN of an EPUB of size bytes read through r, such as a file on disk.
+func ExtractISBNFromEPUB(r io.ReaderAt, size int64) (string, error) {
+	if size == 0 {
+		return "", fmt.Errorf("uploaded file is empty")
+	}
+	reader, err := zip.NewReader(r, size)
 	if err != nil {
 		return "", fmt.Errorf("invalid EPUB file (not a valid ZIP): %v", err)
 	}
@@ -161,16 +184,21 @@ func ExtractISBNFromMultipartFile(file io.Reader) (string, error) {
 		}
 	}
 
-	return "", fmt.Errorf("no ISBN found in EPUB metadata")
+	return "", ErrNoISBN
 }
 
 // ExtractCoverFromEPUBBytes extracts the cover image from an EPUB (ZIP). Returns (image bytes, media type, error).
 // Looks for <meta name="cover" content="id"/> in OPF metadata and the matching item in manifest.
 func ExtractCoverFromEPUBBytes(fileBytes []byte) ([]byte, string, error) {
-	if len(fileBytes) == 0 {
+	return ExtractCoverFromEPUB(bytes.NewReader(fileBytes), int64(len(fileBytes)))
+}
+
+// ExtractCoverFromEPUB is ExtractCoverFromEPUBBytes for an EPUB of size bytes read through r.
+func ExtractCoverFromEPUB(r io.ReaderAt, size int64) ([]byte, string, error) {
+	if size == 0 {
 		return nil, "", fmt.Errorf("empty file")
 	}
-	reader, err := zip.NewReader(bytes.NewReader(fileBytes), int64(len(fileBytes)))
+	reader, err := zip.NewReader(r, size)
 	if err != nil {
 		return nil, "", err
 	}
@@ -231,6 +259,44 @@ func ExtractCoverFromEPUBBytes(fileBytes []byte) ([]byte, string, error) {
 	return coverBytes, mediaType, nil
 }
 
+// EPUBTitleAuthors returns the title and authors in an EPUB's OPF (dc:title and dc:creator), for looking its
+// metadata up when it has no ISBN. Creators with a role other than author (aut), such as editors, are left out.
+func EPUBTitleAuthors(fileBytes []byte) (string, []string, error) {
+	return EPUBTitleAuthorsAt(bytes.NewReader(fileBytes), int64(len(fileBytes)))
+}
+
+// EPUBTitleAuthorsAt is EPUBTitleAuthors for an EPUB of size bytes read through r.
+func EPUBTitleAuthorsAt(r io.ReaderAt, size int64) (string, []string, error) {
+	_, _, pkg, err := readEPUBPackageAt(r, size)
+	if err != nil {
+		return "", nil, err
+	}
+	title := ""
+	for _, t := range pkg.Metadata.Titles {
+		if title = strings.Join(strings.Fields(t), " "); title != "" {
+			break
+		}
+	}
+	roles := map[string]string{} // creator id -> EPUB 3 role
+	for _, m := range pkg.Metadata.Meta {
+		if strings.EqualFold(strings.TrimSpace(m.Property), "role") {
+			roles[strings.TrimPrefix(strings.TrimSpace(m.Refines), "#")] = strings.TrimSpace(m.Value + m.Content)
+		}
+	}
+	var authors []string
+	for _, c := range pkg.Metadata.Creators {
+		role := c.Role
+		if r, ok := roles[c.ID]; ok && c.ID != "" {
+			role = r
+		}
+		name := strings.Join(strings.Fields(c.Value), " ")
+		if name != "" && (role == "" || strings.EqualFold(role, "aut")) {
+			authors = append(authors, name)
+		}
+	}
+	return title, authors, nil
+}
+
 // normalizeZipPath replaces backslashes with forward slashes for consistent matching.
 func normalizeZipPath(path string) string {
 	return strings.ReplaceAll(path, "\\", "/")
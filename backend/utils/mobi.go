@@ -0,0 +1,182 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+)
+
+// ErrNotMOBI is returned by ParseMOBI for files that are not Mobipocket books.
+var ErrNotMOBI = errors.New("not a MOBI or AZW3 file")
+
+// MOBIInfo is what ParseMOBI reads from a Mobipocket (MOBI) or Kindle Format 8 (AZW3) file.
+type MOBIInfo struct {
+	Format      string // "mobi", or "azw3" for KF8-only files
+	Title       string
+	Authors     []string
+	Publisher   string
+	Description string
+	ISBN        string // digits only; "" when the EXTH header has none
+	PublishDate string
+	Language    string
+	Subjects    []string
+	Encrypted   bool // DRM-protected: the text can't be read, and no device but the buyer's opens it
+	// CoverRecord is the index of the cover image's PDB record; -1 when there is none. See MOBICover.
+	CoverRecord int
+}
+
+// EXTH record types read by ParseMOBI.
+const (
+	exthAuthor      = 100
+	exthPublisher   = 101
+	exthDescription = 103
+	exthISBN        = 104
+	exthSubject     = 105
+	exthPublishDate = 106
+	exthCoverOffset = 201
+	exthTitle       = 503
+	exthLanguage    = 524
+)
+
+// IsMOBI reports whether data starts like a Mobipocket book (a Palm database of type BOOKMOBI).
+func IsMOBI(data []byte) bool {
+	return len(data) >= 68 && string(data[60:68]) == "BOOKMOBI"
+}
+
+// mobiRecord returns PDB record i of data.
+func mobiRecord(data []byte, i int) ([]byte, bool) {
+	count := int(binary.BigEndian.Uint16(data[76:78]))
+	if i < 0 || i >= count || 78+8*(i+1) > len(data) {
+		return nil, false
+	}
+	start := int(binary.BigEndian.Uint32(data[78+8*i:]))
+	end := len(data)
+	if i+1 < count && 78+8*(i+2) <= len(data) {
+		end = int(binary.BigEndian.Uint32(data[78+8*(i+1):]))
+	}
+	if start > end || end > len(data) {
+		return nil, false
+	}
+	return data[start:end], true
+}
+
+// ParseMOBI reads the format, metadata and cover location from a MOBI or AZW3 file's record 0: its MOBI header
+// (version 8 is KF8, i.e. AZW3) and EXTH records. The title falls back to the header's full name.
+func ParseMOBI(data []byte) (*MOBIInfo, error) {
+	if !IsMOBI(data) || len(data) < 78 {
+		return nil, ErrNotMOBI
+	}
+	rec0, ok := mobiRecord(data, 0)
+	if !ok || len(rec0) < 0x84 || string(rec0[16:20]) != "MOBI" {
+		return nil, ErrNotMOBI
+	}
+	be := binary.BigEndian
+	info := &MOBIInfo{Format: "mobi", Encrypted: be.Uint16(rec0[12:14]) != 0, CoverRecord: -1}
+	headerLen := int(be.Uint32(rec0[0x14:]))
+	utf8Text := be.Uint32(rec0[0x1C:]) == 65001
+	if be.Uint32(rec0[0x24:]) >= 8 {
+		info.Format = "azw3"
+	}
+	text := func(b []byte) string {
+		if utf8Text {
+			return strings.TrimSpace(strings.ToValidUTF8(string(b), ""))
+		}
+		return strings.TrimSpace(decodeCP1252(b))
+	}
+	if off, n := int(be.Uint32(rec0[0x54:])), int(be.Uint32(rec0[0x58:])); off > 0 && off+n <= len(rec0) {
+		info.Title = text(rec0[off : off+n])
+	}
+	firstImage := int(be.Uint32(rec0[0x6C:]))
+
+	exth := 16 + headerLen
+	if be.Uint32(rec0[0x80:])&0x40 == 0 || exth+12 > len(rec0) || string(rec0[exth:exth+4]) != "EXTH" {
+		return info, nil
+	}
+	count := int(be.Uint32(rec0[exth+8:]))
+	pos := exth + 12
+	for i := 0; i < count && pos+8 <= len(rec0); i++ {
+		typ, n := be.Uint32(rec0[pos:]), int(be.Uint32(rec0[pos+4:]))
+		if n < 8 || pos+n > len(rec0) {
+			break
+		}
+		value := rec0[pos+8 : pos+n]
+		pos += n
+		switch typ {
+		case exthAuthor:
+			// Some files hold several authors in one record, separated by "&" or ";".
+			for _, a := range strings.FieldsFunc(text(value), func(r rune) bool { return r == '&' || r == ';' }) {
+				if a = strings.TrimSpace(a); a != "" {
+					info.Authors = append(info.Authors, a)
+				}
+			}
+		case exthPublisher:
+			info.Publisher = text(value)
+		case exthDescription:
+			info.Description = text(value)
+		case exthISBN:
+			if isbn := sanitizeISBN(text(value)); isValidISBN(isbn) && info.ISBN == "" {
+				info.ISBN = isbn
+			}
+		case exthSubject:
+			if s := text(value); s != "" {
+				info.Subjects = append(info.Subjects, s)
+			}
+		case exthPublishDate:
+			info.PublishDate = text(value)
+		case exthTitle:
+			if t := text(value); t != "" {
+				info.Title = t
+			}
+		case exthLanguage:
+			info.Language = text(value)
+		case exthCoverOffset:
+			if len(value) == 4 && firstImage > 0 && firstImage != 0xFFFFFFFF {
+				if off := be.Uint32(value); off != 0xFFFFFFFF {
+					info.CoverRecord = firstImage + int(off)
+				}
+			}
+		}
+	}
+	return info, nil
+}
+
+// MOBICover returns the cover image ParseMOBI found in data and its content type, or nil when there is none.
+func MOBICover(data []byte, info *MOBIInfo) ([]byte, string) {
+	if info.CoverRecord < 0 {
+		return nil, ""
+	}
+	img, ok := mobiRecord(data, info.CoverRecord)
+	if !ok || len(img) == 0 {
+		return nil, ""
+	}
+	contentType := http.DetectContentType(img)
+	if !strings.HasPrefix(contentType, "image/") {
+		return nil, ""
+	}
+	return bytes.Clone(img), contentType
+}
+
+// cp1252 maps the Windows-1252 bytes 0x80-0x9F that differ from Latin-1; 0 marks the unassigned ones.
+var cp1252 = [32]rune{
+	'€', 0, '‚', 'ƒ', '„', '…', '†', '‡', 'ˆ', '‰', 'Š', '‹', 'Œ', 0, 'Ž', 0,
+	0, '‘', '’', '“', '”', '•', '–', '—', '˜', '™', 'š', '›', 'œ', 0, 'ž', 'Ÿ',
+}
+
+// decodeCP1252 decodes Windows-1252 text, the encoding of older MOBI files.
+func decodeCP1252(b []byte) string {
+	var s strings.Builder
+	s.Grow(len(b))
+	for _, c := range b {
+		r := rune(c)
+		if c >= 0x80 && c < 0xA0 {
+			if r = cp1252[c-0x80]; r == 0 {
+				r = utf8.RuneError
+			}
+		}
+		s.WriteRune(r)
+	}
+	return s.String()
+}
@@ -0,0 +1,170 @@
+package utils
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// OptimizeOptions choose what OptimizeEPUB removes or shrinks.
+type OptimizeOptions struct {
+	StripFonts     bool // drop embedded fonts and their @font-face rules
+	MaxImagePixels int  // downscale JPEG and PNG images whose longer side is larger; 0 = keep sizes
+}
+
+var (
+	fontExtensions = map[string]bool{".ttf": true, ".otf": true, ".woff": true, ".woff2": true, ".eot": true}
+	fontFaceRule   = regexp.MustCompile(`(?is)@font-face\s*\{[^}]*\}`)
+)
+
+func isFontItem(href, mediaType string) bool {
+	mt := strings.ToLower(mediaType)
+	return strings.HasPrefix(mt, "font/") || strings.Contains(mt, "font") || strings.Contains(mt, "opentype") ||
+		fontExtensions[strings.ToLower(path.Ext(href))]
+}
+
+// OptimizeEPUB returns a smaller copy of an EPUB for email delivery: embedded fonts removed (from the archive, the
+// manifest and stylesheets) and oversized images downscaled. Everything else is copied unchanged.
+func OptimizeEPUB(fileBytes []byte, opts OptimizeOptions) ([]byte, error) {
+	reader, opfPath, pkg, err := readEPUBPackage(fileBytes)
+	if err != nil {
+		return nil, err
+	}
+	opf, err := findAndReadFileFromZip(reader, opfPath)
+	if err != nil {
+		return nil, err
+	}
+	dropped := map[string]bool{} // zip paths
+	if opts.StripFonts {
+		for _, item := range pkg.Manifest.Items {
+			if !isFontItem(item.Href, item.MediaType) {
+				continue
+			}
+			dropped[resolveOPFHref(opfPath, item.Href)] = true
+			itemTag := regexp.MustCompile(`<item\b[^>]*\bid=["']` + regexp.QuoteMeta(item.ID) + `["'][^>]*>(\s*</item>)?`)
+			opf = itemTag.ReplaceAll(opf, nil)
+		}
+	}
+
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	// The mimetype entry must come first and be stored uncompressed.
+	if err := writeZipEntry(w, "mimetype", []byte("application/epub+zip"), zip.Store); err != nil {
+		return nil, err
+	}
+	for _, f := range reader.File {
+		name := normalizeZipPath(f.Name)
+		if name == "mimetype" || f.FileInfo().IsDir() {
+			continue
+		}
+		if opts.StripFonts && (dropped[name] || fontExtensions[strings.ToLower(path.Ext(name))]) {
+			continue
+		}
+		var data []byte
+		if name == normalizeZipPath(opfPath) {
+			data = opf
+		} else {
+			data, err = readZipFile(f)
+			if err != nil {
+				return nil, fmt.Errorf("read %s: %v", name, err)
+			}
+		}
+		switch ext := strings.ToLower(path.Ext(name)); {
+		case opts.StripFonts && ext == ".css":
+			data = fontFaceRule.ReplaceAll(data, nil)
+		case opts.MaxImagePixels > 0 && (ext == ".jpg" || ext == ".jpeg" || ext == ".png"):
+			data = downscaleImage(data, ext, opts.MaxImagePixels)
+		}
+		if err := writeZipEntry(w, name, data, zip.Deflate); err != nil {
+			return nil, err
+		}
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func readZipFile(f *zip.File) ([]byte, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(rc)
+}
+
+func writeZipEntry(w *zip.Writer, name string, data []byte, method uint16) error {
+	fw, err := w.CreateHeader(&zip.FileHeader{Name: name, Method: method})
+	if err != nil {
+		return err
+	}
+	_, err = fw.Write(data)
+	return err
+}
+
+// downscaleImage shrinks a JPEG or PNG so its longer side is maxPixels, returning data unchanged when it is
+// already small enough, can't be decoded, or would not get smaller.
+func downscaleImage(data []byte, ext string, maxPixels int) []byte {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil || (cfg.Width <= maxPixels && cfg.Height <= maxPixels) {
+		return data
+	}
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return data
+	}
+	w, h := cfg.Width, cfg.Height
+	if w >= h {
+		w, h = maxPixels, max(1, h*maxPixels/w)
+	} else {
+		w, h = max(1, w*maxPixels/h), maxPixels
+	}
+	dst := boxResize(src, w, h)
+	var out bytes.Buffer
+	if ext == ".png" {
+		err = png.Encode(&out, dst)
+	} else {
+		err = jpeg.Encode(&out, dst, &jpeg.Options{Quality: 80})
+	}
+	if err != nil || out.Len() >= len(data) {
+		return data
+	}
+	return out.Bytes()
+}
+
+// boxResize downscales src to w x h, averaging the source pixels that fall in each destination pixel.
+func boxResize(src image.Image, w, h int) *image.NRGBA {
+	b := src.Bounds()
+	dst := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		y0, y1 := b.Min.Y+y*b.Dy()/h, b.Min.Y+(y+1)*b.Dy()/h
+		for x := 0; x < w; x++ {
+			x0, x1 := b.Min.X+x*b.Dx()/w, b.Min.X+(x+1)*b.Dx()/w
+			var r, g, bl, a, n uint64
+			for sy := y0; sy < max(y1, y0+1); sy++ {
+				for sx := x0; sx < max(x1, x0+1); sx++ {
+					cr, cg, cb, ca := src.At(sx, sy).RGBA()
+					r, g, bl, a, n = r+uint64(cr), g+uint64(cg), bl+uint64(cb), a+uint64(ca), n+1
+				}
+			}
+			// Average premultiplied values, then un-premultiply for NRGBA.
+			a /= n
+			i := dst.PixOffset(x, y)
+			if a > 0 {
+				dst.Pix[i] = uint8(r / n * 0xffff / a >> 8)
+				dst.Pix[i+1] = uint8(g / n * 0xffff / a >> 8)
+				dst.Pix[i+2] = uint8(bl / n * 0xffff / a >> 8)
+			}
+			dst.Pix[i+3] = uint8(a >> 8)
+		}
+	}
+	return dst
+}
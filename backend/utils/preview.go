@@ -0,0 +1,175 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"html"
+	"strings"
+)
+
+// previewMinWords is how long a spine document must be to count as the first chapter rather than front matter
+// (cover, title page, contents, copyright).
+const previewMinWords = 150
+
+// Preview is a sanitized excerpt from the start of a book.
+type Preview struct {
+	Chapter   string // the excerpt's first heading, or the document title
+	HTML      string // only basic formatting elements, without attributes
+	Text      string
+	Words     int
+	Truncated bool // the chapter continues past the excerpt
+}
+
+// previewElements are kept in preview HTML; other elements are dropped but their text is kept.
+var previewElements = map[string]bool{
+	"p": true, "h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+	"em": true, "strong": true, "i": true, "b": true, "blockquote": true,
+	"ul": true, "ol": true, "li": true, "br": true, "hr": true,
+}
+
+// previewInline elements don't separate words in the preview text.
+var previewInline = map[string]bool{"em": true, "strong": true, "i": true, "b": true, "span": true, "a": true, "sup": true, "sub": true, "small": true}
+
+// previewSkipped elements are dropped with their content.
+var previewSkipped = map[string]bool{"head": true, "script": true, "style": true, "svg": true, "math": true, "nav": true}
+
+// EPUBPreview returns up to maxWords of an EPUB's first chapter: the first document in reading order with at least
+// previewMinWords words, or else the first with any text.
+func EPUBPreview(fileBytes []byte, maxWords int) (*Preview, error) {
+	reader, opfPath, pkg, err := readEPUBPackage(fileBytes)
+	if err != nil {
+		return nil, err
+	}
+	hrefs := map[string]string{}
+	for _, item := range pkg.Manifest.Items {
+		if item.MediaType == "application/xhtml+xml" || item.MediaType == "text/html" {
+			hrefs[item.ID] = item.Href
+		}
+	}
+	var fallback []byte
+	for _, ref := range pkg.Spine.ItemRefs {
+		href, ok := hrefs[ref.IDRef]
+		if !ok {
+			continue
+		}
+		doc, err := findAndReadFileFromZip(reader, resolveOPFHref(opfPath, href))
+		if err != nil {
+			continue
+		}
+		words := len(strings.Fields(htmlText(doc)))
+		if words >= previewMinWords {
+			return sanitizePreview(doc, maxWords), nil
+		}
+		if words > 0 && fallback == nil {
+			fallback = doc
+		}
+	}
+	if fallback == nil {
+		return nil, fmt.Errorf("no text found in EPUB")
+	}
+	return sanitizePreview(fallback, maxWords), nil
+}
+
+// sanitizePreview rebuilds an (X)HTML document from previewElements and text, stopping after maxWords words.
+func sanitizePreview(doc []byte, maxWords int) *Preview {
+	d := xml.NewDecoder(bytes.NewReader(doc))
+	d.Strict = false
+	d.AutoClose = xml.HTMLAutoClose
+	d.Entity = xml.HTMLEntity
+	p := &Preview{}
+	var out, text, heading strings.Builder
+	var open []string // kept elements not yet closed
+	skip, inHeading, inTitle := 0, false, false
+	title := ""
+	for !p.Truncated {
+		tok, err := d.Token()
+		if err != nil {
+			break
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			name := strings.ToLower(t.Name.Local)
+			if !previewInline[name] {
+				text.WriteByte(' ')
+			}
+			if name == "title" {
+				inTitle = true
+			}
+			if previewSkipped[name] {
+				skip++
+			}
+			if skip > 0 || !previewElements[name] {
+				continue
+			}
+			if name == "br" || name == "hr" {
+				out.WriteString("<" + name + "/>")
+				continue
+			}
+			if len(name) == 2 && name[0] == 'h' && p.Chapter == "" {
+				inHeading = true
+			}
+			out.WriteString("<" + name + ">")
+			open = append(open, name)
+		case xml.EndElement:
+			name := strings.ToLower(t.Name.Local)
+			if !previewInline[name] {
+				text.WriteByte(' ')
+			}
+			if name == "title" {
+				inTitle = false
+			}
+			if previewSkipped[name] && skip > 0 {
+				skip--
+				continue
+			}
+			if skip > 0 || name == "br" || name == "hr" {
+				continue
+			}
+			// Close up to the matching open element; unmatched end tags are ignored.
+			for i := len(open) - 1; i >= 0; i-- {
+				if open[i] != name {
+					continue
+				}
+				for j := len(open) - 1; j >= i; j-- {
+					out.WriteString("</" + open[j] + ">")
+				}
+				open = open[:i]
+				break
+			}
+			if inHeading && len(name) == 2 && name[0] == 'h' {
+				inHeading = false
+				p.Chapter = strings.Join(strings.Fields(heading.String()), " ")
+			}
+		case xml.CharData:
+			if inTitle {
+				title += string(t)
+			}
+			if skip > 0 {
+				continue
+			}
+			s := string(t)
+			words := strings.Fields(s)
+			if maxWords > 0 && p.Words+len(words) > maxWords {
+				words = words[:maxWords-p.Words]
+				s = strings.Join(words, " ") + "…"
+				p.Truncated = true
+			}
+			p.Words += len(words)
+			if inHeading {
+				heading.WriteString(s)
+			}
+			out.WriteString(html.EscapeString(s))
+			text.WriteString(s)
+		}
+	}
+	for i := len(open) - 1; i >= 0; i-- {
+		out.WriteString("</" + open[i] + ">")
+	}
+	if p.Chapter == "" {
+		p.Chapter = strings.Join(strings.Fields(title), " ")
+	}
+	p.HTML = strings.TrimSpace(out.String())
+	p.Text = strings.Join(strings.Fields(text.String()), " ")
+	return p
+}
@@ -0,0 +1,11 @@
+//go:build !embedweb
+
+package web
+
+import "io/fs"
+
+// Embedded returns nil: this binary was built without the frontend. Copy the export to web/dist and build with
+// -tags embedweb to include it.
+func Embedded() fs.FS {
+	return nil
+}
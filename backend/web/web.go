@@ -0,0 +1,71 @@
+// Package web serves the frontend's static export (next build with NEXT_OUTPUT=export) from the API's own
+// server, so the app deploys as one container.
+package web
+
+import (
+	"io/fs"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/kevinaaaquil/books/backend/middleware"
+)
+
+// DynamicPlaceholder is the parameter static exports render dynamic routes with (books/[id] becomes books/_.html);
+// Handler serves that page for any value, and the page reads the real one from the URL.
+const DynamicPlaceholder = "_"
+
+// Handler serves files from fsys. A path with no file of its own gets, in order: path.html, path/index.html, the
+// page for the same route with DynamicPlaceholder as its last segment, and finally index.html, so client-side
+// routes load the app instead of a 404.
+//
+// Build assets under /_next/static/ have content hashes in their names and are cached for good; HTML must be
+// revalidated so a deploy is picked up at once.
+func Handler(fsys fs.FS) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		name, ok := resolve(fsys, strings.Trim(path.Clean("/"+r.URL.Path), "/"))
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		policy := middleware.CacheStatic
+		switch {
+		case strings.HasPrefix(name, "_next/static/"):
+			policy = middleware.CacheImmutable
+		case strings.HasSuffix(name, ".html"):
+			policy = middleware.CacheNone
+		}
+		w.Header().Set("Cache-Control", policy.CacheControl)
+		http.ServeFileFS(w, r, fsys, name)
+	})
+}
+
+// resolve returns the file to serve for the cleaned, slash-trimmed URL path p.
+func resolve(fsys fs.FS, p string) (string, bool) {
+	if p == "" {
+		return "index.html", isFile(fsys, "index.html")
+	}
+	candidates := []string{p, p + ".html", p + "/index.html"}
+	if dir, _ := path.Split(p); dir != "" {
+		candidates = append(candidates, dir+DynamicPlaceholder+".html")
+	}
+	// Missing assets are real 404s; only page routes fall back to the app.
+	if path.Ext(p) == "" {
+		candidates = append(candidates, "index.html")
+	}
+	for _, name := range candidates {
+		if isFile(fsys, name) {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+func isFile(fsys fs.FS, name string) bool {
+	info, err := fs.Stat(fsys, name)
+	return err == nil && !info.IsDir()
+}
@@ -1,7 +1,9 @@
 import type { NextConfig } from "next";
 
+// NEXT_OUTPUT=export builds static files for the API to serve (the single-container image, see the root Dockerfile);
+// otherwise the app runs on its own Node server.
 const nextConfig: NextConfig = {
-  output: "standalone",
+  output: process.env.NEXT_OUTPUT === "export" ? "export" : "standalone",
 };
 
 export default nextConfig;
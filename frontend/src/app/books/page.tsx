@@ -3,7 +3,7 @@
 import { useEffect, useState, useRef } from "react";
 import { useRouter } from "next/navigation";
 import Link from "next/link";
-import { fetchBooks, uploadBook, deleteBook, clearToken, isAuthenticated, isAdmin, getMe, updateMePreferences, getDisplayCoverUrl, type Book, type User } from "@/lib/api";
+import { fetchBooks, uploadBook, deleteBook, logout, isAuthenticated, isAdmin, getMe, updateMePreferences, getDisplayCoverUrl, type Book, type User } from "@/lib/api";
 import { ProfileMenu } from "@/components/ProfileMenu";
 
 export default function BooksPage() {
@@ -17,6 +17,8 @@ export default function BooksPage() {
   const [noISBNNotification, setNoISBNNotification] = useState<string | null>(null);
   const [failedThumbnailIds, setFailedThumbnailIds] = useState<Set<string>>(new Set());
   const [useExtractedCover, setUseExtractedCover] = useState(false);
+  const [query, setQuery] = useState("");
+  const searched = useRef(false);
   const fileInputRef = useRef<HTMLInputElement>(null);
 
   const canUpload = me?.role === "admin" || me?.role === "editor";
@@ -41,6 +43,17 @@ export default function BooksPage() {
       .finally(() => setLoading(false));
   }, [router]);
 
+  useEffect(() => {
+    if (!searched.current && !query) return;
+    searched.current = true;
+    const timer = setTimeout(() => {
+      fetchBooks(query.trim())
+        .then((list) => setBooks(Array.isArray(list) ? list : []))
+        .catch(() => setBooks([]));
+    }, 250);
+    return () => clearTimeout(timer);
+  }, [query]);
+
   async function handleUpload(e: React.ChangeEvent<HTMLInputElement>) {
     const file = e.target.files?.[0];
     if (!file) return;
@@ -49,11 +62,13 @@ export default function BooksPage() {
     setUploading(true);
     try {
       const result = await uploadBook(file);
-      const list = await fetchBooks();
+      const list = await fetchBooks(query.trim());
       setBooks(Array.isArray(list) ? list : []);
       if (fileInputRef.current) fileInputRef.current.value = "";
       if (result.noISBNFound) {
         setNoISBNNotification("No ISBN was found in this EPUB. The book was uploaded but metadata was not fetched.");
+      } else if (result.failedSteps?.includes("store-cover")) {
+        setNoISBNNotification("The book was uploaded but its cover could not be stored. An editor can retry it later.");
       }
     } catch (err) {
       setUploadError(err instanceof Error ? err.message : "Upload failed");
@@ -88,7 +103,7 @@ export default function BooksPage() {
   }
 
   function handleLogout() {
-    clearToken();
+    logout();
     router.replace("/login");
     router.refresh();
   }
@@ -166,7 +181,17 @@ export default function BooksPage() {
           </div>
         )}
 
-        {bookList.length === 0 ? (
+        <input
+          type="search"
+          value={query}
+          onChange={(e) => setQuery(e.target.value)}
+          placeholder="Search titles, authors and text…"
+          className="mb-6 w-full rounded-lg border border-stone-300 dark:border-stone-600 bg-white dark:bg-stone-700 px-3 py-2 text-stone-900 dark:text-stone-100 focus:outline-none focus:ring-2 focus:ring-accent"
+        />
+
+        {bookList.length === 0 && query.trim() ? (
+          <p className="text-center text-stone-600 dark:text-stone-400">No books match “{query.trim()}”.</p>
+        ) : bookList.length === 0 ? (
           <div className="rounded-xl border-2 border-dashed border-accent/40 bg-accent-soft/50 dark:bg-accent-soft/50 p-12 text-center">
             <p className="text-accent-muted dark:text-accent-muted mb-1">
               No books yet.
@@ -211,6 +236,11 @@ export default function BooksPage() {
                           {book.authors.join(", ")}
                         </p>
                       ) : null}
+                      {book.kind === "document" && book.journal ? (
+                        <p className="text-xs italic text-stone-500 dark:text-stone-400 truncate mt-0.5" title={book.journal}>
+                          {book.journal}
+                        </p>
+                      ) : null}
                       {book.uploadedByEmail ? (
                         <p className="mt-1 text-xs text-stone-500 dark:text-stone-400 truncate">
                           Uploaded by {book.uploadedByEmail}
@@ -220,6 +250,11 @@ export default function BooksPage() {
                         <span className="inline-block text-xs font-medium uppercase px-2 py-0.5 rounded bg-stone-600 dark:bg-stone-500 text-white">
                           {book.format}
                         </span>
+                        {book.kind === "document" && (
+                          <span className="inline-block text-xs font-medium uppercase px-2 py-0.5 rounded bg-accent/15 text-accent ring-1 ring-accent/30">
+                            Document
+                          </span>
+                        )}
                         {canDelete && (
                           <button
                             type="button"
@@ -9,10 +9,14 @@ import {
   setRole,
   getEmailConfig,
   saveEmailConfig,
-  clearToken,
+  sendKindleVerification,
+  confirmKindleVerification,
+  logout,
   type EmailConfig,
 } from "@/lib/api";
 import { ProfileMenu } from "@/components/ProfileMenu";
+import { DeliveryTargets } from "@/components/DeliveryTargets";
+import { Devices } from "@/components/Devices";
 
 const emptyConfig: EmailConfig = {
   appSpecificPassword: "",
@@ -30,6 +34,11 @@ export default function EmailSetupPage() {
   const [error, setError] = useState("");
   const [success, setSuccess] = useState(false);
   const [isGuest, setIsGuest] = useState(false);
+  const [domainWarning, setDomainWarning] = useState(false);
+  const [code, setCode] = useState("");
+  const [verifying, setVerifying] = useState(false);
+  const [verifyMessage, setVerifyMessage] = useState("");
+  const [verifyError, setVerifyError] = useState("");
 
   useEffect(() => {
     if (!isAuthenticated()) {
@@ -50,23 +59,62 @@ export default function EmailSetupPage() {
       .finally(() => setLoading(false));
   }, [router]);
 
-  async function handleSubmit(e: React.FormEvent) {
-    e.preventDefault();
+  async function save(allowAnyDomain: boolean) {
     setError("");
     setSuccess(false);
     setSaving(true);
     try {
-      await saveEmailConfig(config);
+      const saved = await saveEmailConfig(config, allowAnyDomain);
+      setConfig((c) => ({ ...c, kindleVerified: saved.kindleVerified, verificationSentAt: saved.verificationSentAt }));
+      setDomainWarning(false);
       setSuccess(true);
     } catch (err) {
-      setError(err instanceof Error ? err.message : "Failed to save");
+      const e = err as Error & { code?: string };
+      setDomainWarning(e.code === "KINDLE_DOMAIN");
+      setError(e.message || "Failed to save");
     } finally {
       setSaving(false);
     }
   }
 
+  function handleSubmit(e: React.FormEvent) {
+    e.preventDefault();
+    save(false);
+  }
+
+  async function handleSendCode() {
+    setVerifyError("");
+    setVerifyMessage("");
+    setVerifying(true);
+    try {
+      const { kindleMail } = await sendKindleVerification();
+      setConfig((c) => ({ ...c, verificationSentAt: new Date().toISOString() }));
+      setVerifyMessage(`Code sent to ${kindleMail}. It arrives as a document in your Kindle library.`);
+    } catch (err) {
+      setVerifyError(err instanceof Error ? err.message : "Failed to send code");
+    } finally {
+      setVerifying(false);
+    }
+  }
+
+  async function handleConfirmCode(e: React.FormEvent) {
+    e.preventDefault();
+    setVerifyError("");
+    setVerifying(true);
+    try {
+      await confirmKindleVerification(code);
+      setConfig((c) => ({ ...c, kindleVerified: true }));
+      setVerifyMessage("Kindle address verified.");
+      setCode("");
+    } catch (err) {
+      setVerifyError(err instanceof Error ? err.message : "Failed to verify");
+    } finally {
+      setVerifying(false);
+    }
+  }
+
   function handleLogout() {
-    clearToken();
+    logout();
     router.replace("/login");
     router.refresh();
   }
@@ -172,6 +220,16 @@ export default function EmailSetupPage() {
             />
           </div>
           {error && <p className="text-sm text-red-600 dark:text-red-400">{error}</p>}
+          {domainWarning && (
+            <button
+              type="button"
+              onClick={() => save(true)}
+              disabled={saving}
+              className="text-sm font-medium text-accent-muted hover:text-accent underline disabled:opacity-50"
+            >
+              This address is correct, save anyway
+            </button>
+          )}
           {success && (
             <p className="text-sm text-green-600 dark:text-green-400">Kindle config saved.</p>
           )}
@@ -191,6 +249,49 @@ export default function EmailSetupPage() {
             </Link>
           </div>
         </form>
+        {config.kindleMail && config.kindleVerified === false && !isGuest && (
+          <div className="mt-6 rounded-xl border-2 border-amber-300/60 bg-white dark:bg-stone-800 p-6 space-y-3">
+            <h2 className="font-medium text-stone-900 dark:text-stone-100">Verify your Kindle address</h2>
+            <p className="text-sm text-stone-600 dark:text-stone-400">
+              We&apos;ll send a small document with a 6-digit code to {config.kindleMail}. Make sure the sender address is on
+              your Kindle&apos;s approved list, then enter the code here. Books can&apos;t be sent until the address is verified.
+            </p>
+            <button
+              type="button"
+              onClick={handleSendCode}
+              disabled={verifying}
+              className="rounded-lg border border-stone-300 dark:border-stone-600 px-4 py-2 text-stone-700 dark:text-stone-300 font-medium disabled:opacity-50"
+            >
+              {config.verificationSentAt ? "Send a new code" : "Send code"}
+            </button>
+            {config.verificationSentAt && (
+              <form onSubmit={handleConfirmCode} className="flex gap-2">
+                <input
+                  value={code}
+                  onChange={(e) => setCode(e.target.value)}
+                  inputMode="numeric"
+                  maxLength={6}
+                  placeholder="123456"
+                  className="w-32 rounded-lg border border-stone-300 dark:border-stone-600 bg-white dark:bg-stone-700 px-3 py-2 text-stone-900 dark:text-stone-100 focus:outline-none focus:ring-2 focus:ring-accent"
+                />
+                <button
+                  type="submit"
+                  disabled={verifying || code.length !== 6}
+                  className="rounded-lg bg-accent hover:bg-accent-hover text-stone-900 font-medium px-4 py-2 disabled:opacity-50"
+                >
+                  Verify
+                </button>
+              </form>
+            )}
+            {verifyError && <p className="text-sm text-red-600 dark:text-red-400">{verifyError}</p>}
+          </div>
+        )}
+        {verifyMessage && !config.kindleVerified && <p className="mt-3 text-sm text-green-600 dark:text-green-400">{verifyMessage}</p>}
+        {config.kindleMail && config.kindleVerified && (
+          <p className="mt-3 text-sm text-green-600 dark:text-green-400">✓ {config.kindleMail} is verified.</p>
+        )}
+        {!isGuest && <DeliveryTargets />}
+        {!isGuest && <Devices />}
       </main>
     </div>
   );
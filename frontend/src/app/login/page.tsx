@@ -2,6 +2,7 @@
 
 import { useState } from "react";
 import { useRouter } from "next/navigation";
+import Link from "next/link";
 import { login, loginAsGuest, setToken, setRole } from "@/lib/api";
 
 export default function LoginPage() {
@@ -18,8 +19,8 @@ export default function LoginPage() {
     setError("");
     setLoading(true);
     try {
-      const { token, role } = await login(email, password);
-      setToken(token);
+      const { token, refreshToken, role } = await login(email, password);
+      setToken(token, refreshToken);
       if (role) setRole(role);
       router.push("/books");
       router.refresh();
@@ -35,8 +36,8 @@ export default function LoginPage() {
     setError("");
     setGuestLoading(true);
     try {
-      const { token, role } = await loginAsGuest();
-      setToken(token);
+      const { token, refreshToken, role } = await loginAsGuest();
+      setToken(token, refreshToken);
       if (role) setRole(role);
       router.push("/books");
       router.refresh();
@@ -102,6 +103,11 @@ export default function LoginPage() {
               </button>
             </div>
           </div>
+          <div className="text-right -mt-2">
+            <Link href="/reset-password" className="text-xs text-accent-muted hover:underline">
+              Forgot password?
+            </Link>
+          </div>
           {error && (
             <p className="text-sm text-red-600 dark:text-red-400">{error}</p>
           )}
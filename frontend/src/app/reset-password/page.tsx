@@ -0,0 +1,135 @@
+"use client";
+
+import { Suspense, useState } from "react";
+import { useSearchParams } from "next/navigation";
+import Link from "next/link";
+import { forgotPassword, resetPassword } from "@/lib/api";
+
+const inputClass =
+  "w-full rounded-lg border border-stone-300 dark:border-stone-600 bg-white dark:bg-stone-700 px-3 py-2 text-stone-900 dark:text-stone-100 focus:outline-none focus:ring-2 focus:ring-accent";
+
+/** Without a token: ask for a reset link. With ?token= (from a reset or invite email): choose a new password. */
+function ResetPasswordForm() {
+  const params = useSearchParams();
+  const token = params.get("token") ?? "";
+  const invite = params.get("invite") === "1";
+  const [email, setEmail] = useState("");
+  const [password, setPassword] = useState("");
+  const [confirm, setConfirm] = useState("");
+  const [error, setError] = useState("");
+  const [done, setDone] = useState("");
+  const [loading, setLoading] = useState(false);
+
+  async function handleRequest(e: React.FormEvent) {
+    e.preventDefault();
+    setError("");
+    setLoading(true);
+    try {
+      const { message } = await forgotPassword(email);
+      setDone(message);
+    } catch (err) {
+      setError(err instanceof Error ? err.message : "Could not send reset link");
+    } finally {
+      setLoading(false);
+    }
+  }
+
+  async function handleReset(e: React.FormEvent) {
+    e.preventDefault();
+    setError("");
+    if (password !== confirm) {
+      setError("Passwords do not match");
+      return;
+    }
+    setLoading(true);
+    try {
+      const { email } = await resetPassword(token, password);
+      setDone(`Password set for ${email}. You can now sign in.`);
+    } catch (err) {
+      setError(err instanceof Error ? err.message : "Could not set password");
+    } finally {
+      setLoading(false);
+    }
+  }
+
+  const title = token ? (invite ? "Choose a password" : "Set a new password") : "Reset password";
+
+  return (
+    <div className="w-full max-w-sm rounded-xl border-2 border-accent/30 bg-white dark:bg-stone-800 shadow-lg shadow-accent/10 p-8">
+      <h1 className="text-2xl font-semibold text-stone-900 dark:text-stone-100 mb-1">{title}</h1>
+      <p className="text-accent-muted text-sm mb-6">
+        {token
+          ? invite
+            ? "Welcome! Choose a password to finish setting up your account."
+            : "Choose a new password for your account."
+          : "Enter your email and we'll send you a link to reset your password."}
+      </p>
+      {done ? (
+        <p className="text-sm text-stone-700 dark:text-stone-300">{done}</p>
+      ) : token ? (
+        <form onSubmit={handleReset} className="space-y-4">
+          <div>
+            <label htmlFor="password" className="block text-sm font-medium text-accent-muted mb-1">
+              New password
+            </label>
+            <input id="password" type="password" value={password} onChange={(e) => setPassword(e.target.value)} required className={inputClass} />
+          </div>
+          <div>
+            <label htmlFor="confirm" className="block text-sm font-medium text-accent-muted mb-1">
+              Confirm password
+            </label>
+            <input id="confirm" type="password" value={confirm} onChange={(e) => setConfirm(e.target.value)} required className={inputClass} />
+          </div>
+          {error && <p className="text-sm text-red-600 dark:text-red-400">{error}</p>}
+          <button
+            type="submit"
+            disabled={loading}
+            className="w-full rounded-lg bg-accent hover:bg-accent-hover text-stone-900 font-medium py-2.5 transition-colors disabled:opacity-50"
+          >
+            {loading ? "Saving…" : "Set password"}
+          </button>
+        </form>
+      ) : (
+        <form onSubmit={handleRequest} className="space-y-4">
+          <div>
+            <label htmlFor="email" className="block text-sm font-medium text-accent-muted mb-1">
+              Email
+            </label>
+            <input
+              id="email"
+              type="email"
+              value={email}
+              onChange={(e) => setEmail(e.target.value)}
+              required
+              className={inputClass}
+              placeholder="you@example.com"
+            />
+          </div>
+          {error && <p className="text-sm text-red-600 dark:text-red-400">{error}</p>}
+          <button
+            type="submit"
+            disabled={loading}
+            className="w-full rounded-lg bg-accent hover:bg-accent-hover text-stone-900 font-medium py-2.5 transition-colors disabled:opacity-50"
+          >
+            {loading ? "Sending…" : "Send reset link"}
+          </button>
+        </form>
+      )}
+      <p className="mt-6 text-center text-sm">
+        <Link href="/login" className="text-accent-muted hover:underline">
+          Back to sign in
+        </Link>
+      </p>
+    </div>
+  );
+}
+
+export default function ResetPasswordPage() {
+  return (
+    <div className="min-h-screen flex items-center justify-center bg-accent-soft dark:bg-accent-soft px-4">
+      <Suspense>
+        <ResetPasswordForm />
+      </Suspense>
+    </div>
+  );
+}
@@ -5,7 +5,7 @@ import { useRouter } from "next/navigation";
 import Link from "next/link";
 import {
   isAuthenticated,
-  clearToken,
+  logout,
   listUsers,
   createUser,
   updateUser,
@@ -63,7 +63,7 @@ export default function ManageUsersPage() {
   }, [router]);
 
   function handleLogout() {
-    clearToken();
+    logout();
     router.replace("/login");
     router.refresh();
   }
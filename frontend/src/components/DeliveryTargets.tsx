@@ -0,0 +1,196 @@
+"use client";
+
+import { useEffect, useState } from "react";
+import {
+  getTargets,
+  addEmailTarget,
+  resendTargetCode,
+  confirmTarget,
+  deleteTarget,
+  startDriveLink,
+  type DeliveryTarget,
+} from "@/lib/api";
+
+const inputClass =
+  "rounded-lg border border-stone-300 dark:border-stone-600 bg-white dark:bg-stone-700 px-3 py-2 text-stone-900 dark:text-stone-100 focus:outline-none focus:ring-2 focus:ring-accent";
+
+const providerNames: Record<string, string> = { dropbox: "Dropbox", gdrive: "Google Drive" };
+
+/**
+ * Other places books can be sent: email addresses (confirmed with an emailed code) and linked cloud drives.
+ * Linking a drive leaves the app; the API sends the user back with ?linked= or ?link_error=.
+ */
+export function DeliveryTargets() {
+  const [targets, setTargets] = useState<DeliveryTarget[]>([]);
+  const [providers, setProviders] = useState<string[]>([]);
+  const [name, setName] = useState("");
+  const [email, setEmail] = useState("");
+  const [codes, setCodes] = useState<Record<string, string>>({});
+  const [busy, setBusy] = useState(false);
+  const [error, setError] = useState("");
+  const [message, setMessage] = useState("");
+
+  useEffect(() => {
+    const params = new URLSearchParams(window.location.search);
+    const linked = params.get("linked");
+    if (linked) setMessage(`${providerNames[linked] ?? linked} linked.`);
+    setError(params.get("link_error") ?? "");
+    getTargets()
+      .then(({ targets, providers }) => {
+        setTargets(targets);
+        setProviders(providers);
+      })
+      .catch(() => setError("Failed to load delivery targets"));
+  }, []);
+
+  async function run(action: () => Promise<void>) {
+    setError("");
+    setMessage("");
+    setBusy(true);
+    try {
+      await action();
+    } catch (err) {
+      setError(err instanceof Error ? err.message : "Something went wrong");
+    } finally {
+      setBusy(false);
+    }
+  }
+
+  function handleAdd(e: React.FormEvent) {
+    e.preventDefault();
+    run(async () => {
+      const target = await addEmailTarget(name, email);
+      setTargets((ts) => [...ts, target]);
+      setName("");
+      setEmail("");
+      setMessage(`Confirmation code sent to ${target.email}.`);
+    });
+  }
+
+  function handleConfirm(id: string) {
+    run(async () => {
+      const target = await confirmTarget(id, codes[id] ?? "");
+      setTargets((ts) => ts.map((t) => (t.id === id ? target : t)));
+      setMessage(`${target.email} confirmed.`);
+    });
+  }
+
+  function handleResend(id: string) {
+    run(async () => {
+      await resendTargetCode(id);
+      setMessage("New code sent.");
+    });
+  }
+
+  function handleDelete(id: string) {
+    run(async () => {
+      await deleteTarget(id);
+      setTargets((ts) => ts.filter((t) => t.id !== id));
+    });
+  }
+
+  function handleLink(provider: string) {
+    run(async () => {
+      window.location.href = await startDriveLink(provider);
+    });
+  }
+
+  return (
+    <div className="mt-6 rounded-xl border-2 border-accent/20 bg-white dark:bg-stone-800 p-6 space-y-4">
+      <div>
+        <h2 className="font-medium text-stone-900 dark:text-stone-100">Other destinations</h2>
+        <p className="text-sm text-stone-600 dark:text-stone-400">
+          Send books to any email address or to a cloud drive. New addresses must be confirmed with a code emailed to them.
+        </p>
+      </div>
+      {targets.length > 0 && (
+        <ul className="divide-y divide-stone-200 dark:divide-stone-700">
+          {targets.map((t) => (
+            <li key={t.id} className="py-3 space-y-2">
+              <div className="flex items-center justify-between gap-3">
+                <div className="text-sm">
+                  <span className="font-medium text-stone-900 dark:text-stone-100">{t.name}</span>
+                  <span className="ml-2 text-stone-500 dark:text-stone-400">
+                    {t.kind === "email" ? t.email : `${providerNames[t.kind] ?? t.kind} · ${t.kind === "dropbox" ? t.folder : "Books folder"}`}
+                  </span>
+                  {!t.ready && <span className="ml-2 text-amber-600 dark:text-amber-400">awaiting confirmation</span>}
+                </div>
+                <button
+                  type="button"
+                  onClick={() => handleDelete(t.id)}
+                  disabled={busy}
+                  className="text-sm text-red-600 dark:text-red-400 hover:underline disabled:opacity-50"
+                >
+                  Remove
+                </button>
+              </div>
+              {!t.ready && (
+                <div className="flex gap-2">
+                  <input
+                    value={codes[t.id] ?? ""}
+                    onChange={(e) => setCodes((c) => ({ ...c, [t.id]: e.target.value }))}
+                    inputMode="numeric"
+                    maxLength={6}
+                    placeholder="123456"
+                    className={`w-32 ${inputClass}`}
+                  />
+                  <button
+                    type="button"
+                    onClick={() => handleConfirm(t.id)}
+                    disabled={busy || (codes[t.id] ?? "").length !== 6}
+                    className="rounded-lg bg-accent hover:bg-accent-hover text-stone-900 font-medium px-4 py-2 disabled:opacity-50"
+                  >
+                    Confirm
+                  </button>
+                  <button
+                    type="button"
+                    onClick={() => handleResend(t.id)}
+                    disabled={busy}
+                    className="text-sm text-accent-muted hover:text-accent underline disabled:opacity-50"
+                  >
+                    Send a new code
+                  </button>
+                </div>
+              )}
+            </li>
+          ))}
+        </ul>
+      )}
+      <form onSubmit={handleAdd} className="flex flex-wrap gap-2">
+        <input value={name} onChange={(e) => setName(e.target.value)} placeholder="Name (optional)" className={`w-40 ${inputClass}`} />
+        <input
+          type="email"
+          value={email}
+          onChange={(e) => setEmail(e.target.value)}
+          required
+          placeholder="someone@example.com"
+          className={`flex-1 min-w-[12rem] ${inputClass}`}
+        />
+        <button
+          type="submit"
+          disabled={busy}
+          className="rounded-lg border border-stone-300 dark:border-stone-600 px-4 py-2 text-stone-700 dark:text-stone-300 font-medium disabled:opacity-50"
+        >
+          Add address
+        </button>
+      </form>
+      {providers.length > 0 && (
+        <div className="flex flex-wrap gap-2">
+          {providers.map((p) => (
+            <button
+              key={p}
+              type="button"
+              onClick={() => handleLink(p)}
+              disabled={busy}
+              className="rounded-lg border border-stone-300 dark:border-stone-600 px-4 py-2 text-sm text-stone-700 dark:text-stone-300 font-medium disabled:opacity-50"
+            >
+              Link {providerNames[p] ?? p}
+            </button>
+          ))}
+        </div>
+      )}
+      {error && <p className="text-sm text-red-600 dark:text-red-400">{error}</p>}
+      {message && <p className="text-sm text-green-600 dark:text-green-400">{message}</p>}
+    </div>
+  );
+}
@@ -0,0 +1,164 @@
+"use client";
+
+import { useEffect, useState } from "react";
+import { getDevices, addDevice, deleteDevice, getTargets, type Device, type DeliveryTarget } from "@/lib/api";
+
+const inputClass =
+  "rounded-lg border border-stone-300 dark:border-stone-600 bg-white dark:bg-stone-700 px-3 py-2 text-stone-900 dark:text-stone-100 focus:outline-none focus:ring-2 focus:ring-accent";
+
+const typeNames: Record<Device["type"], string> = { kindle: "Kindle", kobo: "Kobo", tablet: "Tablet", other: "Other" };
+
+/**
+ * Reading devices with the formats they take and their attachment limit. Sending to a device picks (or converts
+ * to) its preferred format and delivers to its destination: the Kindle address or one of the delivery targets.
+ */
+export function Devices() {
+  const [devices, setDevices] = useState<Device[]>([]);
+  const [formats, setFormats] = useState<string[]>([]);
+  const [targets, setTargets] = useState<DeliveryTarget[]>([]);
+  const [name, setName] = useState("");
+  const [type, setType] = useState<Device["type"]>("kindle");
+  const [chosen, setChosen] = useState<string[]>([]);
+  const [maxMB, setMaxMB] = useState("");
+  const [targetId, setTargetId] = useState("");
+  const [optimize, setOptimize] = useState(false);
+  const [busy, setBusy] = useState(false);
+  const [error, setError] = useState("");
+
+  useEffect(() => {
+    Promise.all([getDevices(), getTargets()])
+      .then(([d, t]) => {
+        setDevices(d.devices);
+        setFormats(d.formats);
+        setTargets(t.targets.filter((target) => target.ready));
+      })
+      .catch(() => setError("Failed to load devices"));
+  }, []);
+
+  function toggleFormat(f: string) {
+    setChosen((cs) => (cs.includes(f) ? cs.filter((c) => c !== f) : [...cs, f]));
+  }
+
+  async function handleAdd(e: React.FormEvent) {
+    e.preventDefault();
+    setError("");
+    setBusy(true);
+    try {
+      const device = await addDevice({
+        name,
+        type,
+        formats: chosen,
+        maxAttachmentMB: maxMB === "" ? undefined : Number(maxMB),
+        targetId: targetId || undefined,
+        optimize,
+      });
+      setDevices((ds) => [...ds, device]);
+      setName("");
+      setChosen([]);
+      setMaxMB("");
+      setOptimize(false);
+    } catch (err) {
+      setError(err instanceof Error ? err.message : "Failed to add device");
+    } finally {
+      setBusy(false);
+    }
+  }
+
+  async function handleDelete(id: string) {
+    setError("");
+    try {
+      await deleteDevice(id);
+      setDevices((ds) => ds.filter((d) => d.id !== id));
+    } catch (err) {
+      setError(err instanceof Error ? err.message : "Failed to remove device");
+    }
+  }
+
+  function destinationName(d: Device) {
+    if (!d.targetId) return "Kindle address";
+    return targets.find((t) => t.id === d.targetId)?.name ?? "delivery target";
+  }
+
+  return (
+    <div className="mt-6 rounded-xl border-2 border-accent/20 bg-white dark:bg-stone-800 p-6 space-y-4">
+      <div>
+        <h2 className="font-medium text-stone-900 dark:text-stone-100">Devices</h2>
+        <p className="text-sm text-stone-600 dark:text-stone-400">
+          Books sent to a device use the first format it takes, converted if needed. Formats are in order of preference.
+        </p>
+      </div>
+      {devices.length > 0 && (
+        <ul className="divide-y divide-stone-200 dark:divide-stone-700">
+          {devices.map((d) => (
+            <li key={d.id} className="py-3 flex items-center justify-between gap-3">
+              <div className="text-sm">
+                <span className="font-medium text-stone-900 dark:text-stone-100">{d.name}</span>
+                <span className="ml-2 text-stone-500 dark:text-stone-400">
+                  {typeNames[d.type]} · {d.formats.join(", ")}
+                  {d.maxAttachmentMB ? ` · up to ${d.maxAttachmentMB} MB` : ""}
+                  {d.optimize ? " · optimized" : ""} · {destinationName(d)}
+                </span>
+              </div>
+              <button
+                type="button"
+                onClick={() => handleDelete(d.id)}
+                className="text-sm text-red-600 dark:text-red-400 hover:underline"
+              >
+                Remove
+              </button>
+            </li>
+          ))}
+        </ul>
+      )}
+      <form onSubmit={handleAdd} className="space-y-3">
+        <div className="flex flex-wrap gap-2">
+          <input value={name} onChange={(e) => setName(e.target.value)} required placeholder="Kindle Paperwhite" className={`flex-1 min-w-[10rem] ${inputClass}`} />
+          <select value={type} onChange={(e) => setType(e.target.value as Device["type"])} aria-label="Device type" className={inputClass}>
+            {Object.entries(typeNames).map(([value, label]) => (
+              <option key={value} value={value}>
+                {label}
+              </option>
+            ))}
+          </select>
+          <select value={targetId} onChange={(e) => setTargetId(e.target.value)} aria-label="Deliver to" className={inputClass}>
+            <option value="">Kindle address</option>
+            {targets.map((t) => (
+              <option key={t.id} value={t.id}>
+                {t.name}
+              </option>
+            ))}
+          </select>
+        </div>
+        <div className="flex flex-wrap items-center gap-3 text-sm text-stone-700 dark:text-stone-300">
+          {formats.map((f) => (
+            <label key={f} className="flex items-center gap-1">
+              <input type="checkbox" checked={chosen.includes(f)} onChange={() => toggleFormat(f)} />
+              {f}
+            </label>
+          ))}
+          <label className="flex items-center gap-1" title="Remove embedded fonts and shrink large images from every EPUB">
+            <input type="checkbox" checked={optimize} onChange={(e) => setOptimize(e.target.checked)} />
+            Always optimize
+          </label>
+          <input
+            type="number"
+            min={0}
+            value={maxMB}
+            onChange={(e) => setMaxMB(e.target.value)}
+            placeholder="Max MB"
+            className={`w-28 ${inputClass}`}
+          />
+          <button
+            type="submit"
+            disabled={busy}
+            className="rounded-lg border border-stone-300 dark:border-stone-600 px-4 py-2 text-stone-700 dark:text-stone-300 font-medium disabled:opacity-50"
+          >
+            Add device
+          </button>
+        </div>
+        <p className="text-xs text-stone-500 dark:text-stone-400">Leave formats and size empty to use the device type&apos;s defaults. EPUBs over the size limit are optimized automatically.</p>
+      </form>
+      {error && <p className="text-sm text-red-600 dark:text-red-400">{error}</p>}
+    </div>
+  );
+}
@@ -29,6 +29,13 @@ export function getDisplayCoverUrl(
   return getBookCoverOrThumbnailUrl(raw);
 }
 
+/** Human-readable file size, e.g. 1.4 MB. */
+export function formatBytes(bytes: number): string {
+  if (bytes < 1024) return `${bytes} B`;
+  if (bytes < 1024 * 1024) return `${(bytes / 1024).toFixed(1)} KB`;
+  return `${(bytes / (1024 * 1024)).toFixed(1)} MB`;
+}
+
 export type Book = {
   id: string;
   title: string;
@@ -36,6 +43,10 @@ export type Book = {
   publisher?: string;
   publishDate?: string;
   isbn?: string;
+  doi?: string;
+  journal?: string;
+  /** "document" for papers, theses and reports; absent for books. */
+  kind?: string;
   pageCount?: number;
   coverUrl?: string;
   thumbnailUrl?: string;
@@ -43,10 +54,24 @@ export type Book = {
   preface?: string;
   category?: string;
   categories?: string[];
+  /** Categories the classifier suggested, waiting for an editor to approve. */
+  suggestedCategories?: string[];
   ratingAverage?: number;
   ratingCount?: number;
   format: string;
   originalName: string;
+  sizeBytes?: number;
+  sha256?: string;
+  wordCount?: number;
+  filePageCount?: number;
+  /** schema.org accessibility metadata declared in an EPUB's OPF. */
+  accessibility?: {
+    features?: string[];
+    accessModes?: string[];
+    accessModesSufficient?: string[];
+    hazards?: string[];
+    summary?: string;
+  };
   uploadedByEmail?: string;
   extractedCoverUrl?: string;
   viewByGuest?: boolean;
@@ -58,6 +83,9 @@ export type User = {
   email: string;
   role: string;
   useExtractedCover?: boolean;
+  termsVersion?: number;
+  termsAcceptedAt?: string;
+  onboardedAt?: string;
   createdAt: string;
 };
 
@@ -67,9 +95,13 @@ function getToken(): string | null {
 }
 
 const ROLE_KEY = "role";
+const REFRESH_KEY = "refreshToken";
 
-export function setToken(token: string) {
+/** Stores the access token, and the refresh token that renews it (read-only replicas issue none). */
+export function setToken(token: string, refreshToken?: string) {
   localStorage.setItem("token", token);
+  if (refreshToken) localStorage.setItem(REFRESH_KEY, refreshToken);
+  else localStorage.removeItem(REFRESH_KEY);
 }
 
 export function setRole(role: string) {
@@ -83,9 +115,49 @@ export function getRole(): string | null {
 
 export function clearToken() {
   localStorage.removeItem("token");
+  localStorage.removeItem(REFRESH_KEY);
   localStorage.removeItem(ROLE_KEY);
 }
 
+/** Signs out: forgets the tokens and revokes the refresh token on the server. */
+export function logout() {
+  const refreshToken = typeof window === "undefined" ? null : localStorage.getItem(REFRESH_KEY);
+  clearToken();
+  if (!refreshToken) return;
+  fetch(`${getApiBaseUrl()}/api/auth/logout`, {
+    method: "POST",
+    headers: { "Content-Type": "application/json" },
+    body: JSON.stringify({ refreshToken }),
+  }).catch(() => {});
+}
+
+let refreshing: Promise<boolean> | null = null;
+
+/** Trades the refresh token for new tokens; concurrent callers share one request, since each token works once. */
+function refreshSession(): Promise<boolean> {
+  const refreshToken = typeof window === "undefined" ? null : localStorage.getItem(REFRESH_KEY);
+  if (!refreshToken) return Promise.resolve(false);
+  refreshing ??= (async () => {
+    try {
+      const res = await fetch(`${getApiBaseUrl()}/api/auth/refresh`, {
+        method: "POST",
+        headers: { "Content-Type": "application/json" },
+        body: JSON.stringify({ refreshToken }),
+      });
+      if (!res.ok) return false;
+      const data = (await res.json()) as { token: string; refreshToken?: string; role?: string };
+      setToken(data.token, data.refreshToken);
+      if (data.role) setRole(data.role);
+      return true;
+    } catch {
+      return false;
+    } finally {
+      refreshing = null;
+    }
+  })();
+  return refreshing;
+}
+
 export function isAuthenticated(): boolean {
   return !!getToken();
 }
@@ -117,13 +189,16 @@ export function canRefreshMetadata(): boolean {
   return r === "admin" || r === "editor";
 }
 
-async function authFetch(path: string, options: RequestInit = {}) {
+async function authFetch(path: string, options: RequestInit = {}, retried = false): Promise<Response> {
   const token = getToken();
   const headers: HeadersInit = {
     ...(options.headers as Record<string, string>),
   };
   if (token) headers["Authorization"] = `Bearer ${token}`;
   const res = await fetch(`${getApiBaseUrl()}${path}`, { ...options, headers });
+  if (res.status === 401 && token && !retried && (await refreshSession())) {
+    return authFetch(path, options, true);
+  }
   if (res.status === 401) {
     clearToken();
     if (typeof window !== "undefined") window.location.href = "/login";
@@ -131,7 +206,7 @@ async function authFetch(path: string, options: RequestInit = {}) {
   return res;
 }
 
-export async function login(email: string, password: string): Promise<{ token: string; email: string; role?: string }> {
+export async function login(email: string, password: string): Promise<{ token: string; refreshToken?: string; email: string; role?: string }> {
   const res = await fetch(`${getApiBaseUrl()}/api/auth/login`, {
     method: "POST",
     headers: { "Content-Type": "application/json" },
@@ -142,18 +217,68 @@ export async function login(email: string, password: string): Promise<{ token: s
   return data;
 }
 
-/** Sign in as guest (same privileges as a guest user: only books marked "View by guest"). */
-export async function loginAsGuest(): Promise<{ token: string; email: string; role?: string }> {
+/** Count the zero bits a SHA-256 digest starts with. */
+function leadingZeroBits(digest: ArrayBuffer): number {
+  let n = 0;
+  for (const byte of new Uint8Array(digest)) {
+    if (byte !== 0) return n + Math.clz32(byte) - 24;
+    n += 8;
+  }
+  return n;
+}
+
+/** Find a solution to a guest login challenge: SHA-256 of `challenge:solution` must start with `bits` zero bits. */
+async function solveGuestChallenge(challenge: string, bits: number): Promise<string> {
+  const encoder = new TextEncoder();
+  for (let i = 0; ; i++) {
+    const digest = await crypto.subtle.digest("SHA-256", encoder.encode(`${challenge}:${i}`));
+    if (leadingZeroBits(digest) >= bits) return String(i);
+  }
+}
+
+/** Sign in as guest (same privileges as a guest user: only books marked "View by guest"). Solves the server's
+ * proof-of-work challenge first when it asks for one. */
+export async function loginAsGuest(): Promise<{ token: string; refreshToken?: string; email: string; role?: string }> {
+  let body = {};
+  const challengeRes = await fetch(`${getApiBaseUrl()}/api/auth/guest/challenge`);
+  if (challengeRes.ok) {
+    const { challenge, bits } = (await challengeRes.json()) as { challenge: string; bits: number };
+    body = { challenge, solution: await solveGuestChallenge(challenge, bits) };
+  }
   const res = await fetch(`${getApiBaseUrl()}/api/auth/guest`, {
     method: "POST",
     headers: { "Content-Type": "application/json" },
-    body: JSON.stringify({}),
+    body: JSON.stringify(body),
   });
   const data = await res.json().catch(() => ({}));
   if (!res.ok) throw new Error(data.error || "Guest access not available");
   return data;
 }
 
+/** Ask for a password reset link by email. The response is the same whether or not the account exists. */
+export async function forgotPassword(email: string): Promise<{ message: string }> {
+  const res = await fetch(`${getApiBaseUrl()}/api/auth/forgot-password`, {
+    method: "POST",
+    headers: { "Content-Type": "application/json" },
+    body: JSON.stringify({ email }),
+  });
+  const data = await res.json().catch(() => ({}));
+  if (!res.ok) throw new Error(data.error || "Could not send reset link");
+  return data;
+}
+
+/** Set a new password with the token from a reset or invite email. */
+export async function resetPassword(token: string, password: string): Promise<{ message: string; email: string }> {
+  const res = await fetch(`${getApiBaseUrl()}/api/auth/reset-password`, {
+    method: "POST",
+    headers: { "Content-Type": "application/json" },
+    body: JSON.stringify({ token, password }),
+  });
+  const data = await res.json().catch(() => ({}));
+  if (!res.ok) throw new Error(data.error || "Could not set password");
+  return data;
+}
+
 export async function getMe(): Promise<User> {
   const res = await authFetch("/api/me");
   const data = await res.json().catch(() => ({}));
@@ -174,6 +299,35 @@ export async function updateMePreferences(prefs: { useExtractedCover: boolean })
   return data as User;
 }
 
+export type Terms = { text: string; version: number; updatedAt?: string };
+
+/** The terms of use, or null when the instance has none. Requests answered with 451 wait for acceptTerms. */
+export async function getTerms(): Promise<Terms | null> {
+  const res = await fetch(`${getApiBaseUrl()}/api/terms`);
+  if (res.status === 404) return null;
+  const data = await res.json().catch(() => ({}));
+  if (!res.ok) throw new Error((data as { error?: string }).error || "Failed to load the terms of use");
+  return data as Terms;
+}
+
+export async function acceptTerms(version: number): Promise<User> {
+  const res = await authFetch("/api/me/terms", {
+    method: "POST",
+    headers: { "Content-Type": "application/json" },
+    body: JSON.stringify({ version }),
+  });
+  const data = await res.json().catch(() => ({}));
+  if (!res.ok) throw new Error((data as { error?: string }).error || "Could not accept the terms of use");
+  return data as User;
+}
+
+export async function completeOnboarding(): Promise<User> {
+  const res = await authFetch("/api/me/onboarding", { method: "POST" });
+  const data = await res.json().catch(() => ({}));
+  if (!res.ok) throw new Error((data as { error?: string }).error || "Could not save onboarding");
+  return data as User;
+}
+
 export const USER_ROLES = ["viewer", "editor", "guest"] as const;
 export type CreateUserRole = (typeof USER_ROLES)[number];
 
@@ -238,12 +392,59 @@ export async function deleteUser(id: string): Promise<void> {
   }
 }
 
-export async function fetchBooks(): Promise<Book[]> {
-  const res = await authFetch("/api/books");
+/**
+ * Lists the library; with query, only matching books (metadata or EPUB text), best match first; with
+ * accessibility, only EPUBs declaring every one of those features (e.g. "alternativeText" for image descriptions).
+ */
+export async function fetchBooks(query?: string, accessibility?: string[]): Promise<Book[]> {
+  const params = new URLSearchParams();
+  if (query) params.set("q", query);
+  if (accessibility?.length) params.set("accessibility", accessibility.join(","));
+  const qs = params.toString();
+  const res = await authFetch(qs ? `/api/books?${qs}` : "/api/books");
   if (!res.ok) throw new Error("Failed to load books");
   return res.json();
 }
 
+export type SemanticHit = { book: Book; score: number };
+
+/** Find books by meaning ("cozy low-stakes fantasy"), best first. Fails when semantic search isn't enabled. */
+export async function semanticSearch(query: string, limit?: number): Promise<SemanticHit[]> {
+  const params = new URLSearchParams({ q: query });
+  if (limit) params.set("limit", String(limit));
+  const res = await authFetch(`/api/search/semantic?${params}`);
+  const data = await res.json().catch(() => ({}));
+  if (!res.ok) throw new Error((data as { error?: string }).error || "Failed to search");
+  return (data as { books: SemanticHit[] }).books;
+}
+
+/** Books like this one by their content: by embedding when available, otherwise by the words of its text. */
+export async function similarBooks(
+  bookId: string,
+  limit?: number
+): Promise<{ method: "embeddings" | "text"; books: SemanticHit[] }> {
+  const params = new URLSearchParams();
+  if (limit) params.set("limit", String(limit));
+  const res = await authFetch(`/api/books/${bookId}/similar?${params}`);
+  const data = await res.json().catch(() => ({}));
+  if (!res.ok) throw new Error((data as { error?: string }).error || "Failed to load similar books");
+  return data as { method: "embeddings" | "text"; books: SemanticHit[] };
+}
+
+export type AskAnswer = { question: string; answer: string; bookIds: string[]; books: Book[] };
+
+/** Asks the library assistant a question, answered from the user's books and citing them. */
+export async function askLibrary(question: string): Promise<AskAnswer> {
+  const res = await authFetch("/api/ask", {
+    method: "POST",
+    headers: { "Content-Type": "application/json" },
+    body: JSON.stringify({ question }),
+  });
+  const data = await res.json().catch(() => ({}));
+  if (!res.ok) throw new Error((data as { error?: string }).error || "Failed to ask");
+  return data as AskAnswer;
+}
+
 export async function fetchBook(id: string): Promise<Book> {
   const res = await authFetch(`/api/books/${id}`);
   if (!res.ok) throw new Error("Book not found");
@@ -254,7 +455,8 @@ export async function getDownloadUrl(id: string): Promise<string> {
   const res = await authFetch(`/api/books/${id}/download`);
   if (!res.ok) throw new Error("Failed to get download link");
   const data = await res.json();
-  return data.url;
+  // Stream mode returns a signed API path; presigned mode returns an absolute S3 URL.
+  return getBookCoverOrThumbnailUrl(data.url) ?? data.url;
 }
 
 /** Refetch metadata by ISBN and update the book. Pass isbn to use a new ISBN (overwrites existing); omit to use book's current ISBN. */
@@ -277,7 +479,38 @@ export async function refreshBookMetadata(id: string, isbn?: string): Promise<Bo
   return JSON.parse(text) as Book;
 }
 
-/** Send book to Kindle. Throws with { message, code?: 'KINDLE_CONFIG_REQUIRED' } on failure. */
+export type MetadataCandidate = {
+  isbn: string;
+  title: string;
+  authors?: string[];
+  publisher?: string;
+  publishDate?: string;
+  pageCount?: number;
+  coverUrl?: string;
+  thumbnailUrl?: string;
+};
+
+/** Search metadata by title and author (default: the book's). Pick a candidate with refreshBookMetadata(id, candidate.isbn). */
+export async function searchBookMetadata(id: string, title?: string, author?: string): Promise<MetadataCandidate[]> {
+  const res = await authFetch(`/api/books/${id}/search-metadata`, {
+    method: "POST",
+    headers: { "Content-Type": "application/json" },
+    body: JSON.stringify({ title: title?.trim() ?? "", author: author?.trim() ?? "" }),
+  });
+  const text = await res.text();
+  if (!res.ok) {
+    try {
+      const data = JSON.parse(text) as { error?: string };
+      throw new Error(data.error || "Failed to search metadata");
+    } catch (e) {
+      if (e instanceof Error) throw e;
+      throw new Error("Failed to search metadata");
+    }
+  }
+  return (JSON.parse(text) as { candidates: MetadataCandidate[] }).candidates;
+}
+
+/** Send book to Kindle. Throws with { message, code?: 'KINDLE_CONFIG_REQUIRED' | 'KINDLE_NOT_VERIFIED' | 'SEND_LIMIT_REACHED' } on failure. */
 export async function sendToKindle(bookId: string): Promise<{ message: string; kindleMail: string }> {
   const res = await authFetch(`/api/books/${bookId}/send-to-kindle`, { method: "POST" });
   const text = await res.text();
@@ -292,7 +525,31 @@ export async function sendToKindle(bookId: string): Promise<{ message: string; k
       throw new Error("Failed to send to Kindle");
     }
   }
-  return JSON.parse(text) as { message: string; kindleMail: string };
+  const { jobId } = JSON.parse(text) as { jobId: string };
+  return (await waitForJob(jobId, "Failed to send to Kindle")) as { message: string; kindleMail: string };
+}
+
+export type QueuedJob = {
+  id: string;
+  kind: string;
+  status: "queued" | "running" | "succeeded" | "failed";
+  attempts: number;
+  maxAttempts: number;
+  runAt: string;
+  error?: string;
+  result?: Record<string, string>;
+};
+
+/** Polls a queued job (e.g. a send) until it finishes; returns its result, or throws with its error. */
+export async function waitForJob(jobId: string, failure = "Job failed"): Promise<Record<string, string>> {
+  for (let delay = 500; ; delay = Math.min(delay * 2, 5000)) {
+    const res = await authFetch(`/api/jobs/${jobId}`);
+    if (!res.ok) throw new Error(failure);
+    const job = (await res.json()) as QueuedJob;
+    if (job.status === "succeeded") return job.result ?? {};
+    if (job.status === "failed") throw new Error(job.error || failure);
+    await new Promise((resolve) => setTimeout(resolve, delay));
+  }
 }
 
 /** Toggle whether a book is visible to guests (admin only). */
@@ -334,6 +591,8 @@ export type EmailConfig = {
   icloudMail: string;
   senderMail: string;
   kindleMail: string;
+  kindleVerified?: boolean;
+  verificationSentAt?: string;
 };
 
 export async function getEmailConfig(): Promise<EmailConfig> {
@@ -342,17 +601,20 @@ export async function getEmailConfig(): Promise<EmailConfig> {
   return res.json();
 }
 
-export async function saveEmailConfig(config: EmailConfig): Promise<EmailConfig> {
+/** Save Kindle config. Throws with { message, code?: 'KINDLE_DOMAIN' } when the Kindle address isn't at a Kindle domain; retry with allowAnyDomain to save it anyway. */
+export async function saveEmailConfig(config: EmailConfig, allowAnyDomain = false): Promise<EmailConfig> {
   const res = await authFetch("/api/email-config", {
     method: "PUT",
     headers: { "Content-Type": "application/json" },
-    body: JSON.stringify(config),
+    body: JSON.stringify({ ...config, allowAnyDomain }),
   });
   const text = await res.text();
   if (!res.ok) {
     try {
-      const data = JSON.parse(text) as { error?: string };
-      throw new Error(data.error || "Failed to save Kindle config");
+      const data = JSON.parse(text) as { error?: string; code?: string };
+      const err = new Error(data.error || "Failed to save Kindle config") as Error & { code?: string };
+      err.code = data.code;
+      throw err;
     } catch (e) {
       if (e instanceof Error) throw e;
       throw new Error("Failed to save Kindle config");
@@ -361,16 +623,224 @@ export async function saveEmailConfig(config: EmailConfig): Promise<EmailConfig>
   return JSON.parse(text) as EmailConfig;
 }
 
-export async function uploadBook(file: File): Promise<{ id: string; title: string; noISBNFound?: boolean }> {
-  const token = getToken();
-  if (!token) throw new Error("Not logged in");
-  const form = new FormData();
-  form.append("file", file);
-  const res = await fetch(`${getApiBaseUrl()}/api/upload`, {
+/** Send a verification code to the saved Kindle address, as a document that shows up in the Kindle library. */
+export async function sendKindleVerification(): Promise<{ message: string; kindleMail: string }> {
+  const res = await authFetch("/api/email-config/verification", { method: "POST" });
+  const data = await res.json().catch(() => ({}));
+  if (!res.ok) throw new Error((data as { error?: string }).error || "Failed to send verification code");
+  return data as { message: string; kindleMail: string };
+}
+
+/** Confirm the Kindle address with the code from the verification document. */
+export async function confirmKindleVerification(code: string): Promise<void> {
+  const res = await authFetch("/api/email-config/verification/confirm", {
     method: "POST",
-    headers: { Authorization: `Bearer ${token}` },
-    body: form,
+    headers: { "Content-Type": "application/json" },
+    body: JSON.stringify({ code }),
   });
+  const data = await res.json().catch(() => ({}));
+  if (!res.ok) throw new Error((data as { error?: string }).error || "Failed to verify code");
+}
+
+export type DeliveryTarget = {
+  id: string;
+  kind: "email" | "dropbox" | "gdrive";
+  name: string;
+  email?: string;
+  folder?: string;
+  ready: boolean;
+  verification?: { sentAt?: string };
+  createdAt: string;
+};
+
+/** List the user's delivery targets, and the drive providers this server can link. */
+export async function getTargets(): Promise<{ targets: DeliveryTarget[]; providers: string[] }> {
+  const res = await authFetch("/api/targets");
+  if (!res.ok) throw new Error("Failed to load delivery targets");
+  return res.json();
+}
+
+/** Add an email target. A confirmation code is emailed to it; books can be sent once it is confirmed. */
+export async function addEmailTarget(name: string, email: string): Promise<DeliveryTarget> {
+  const res = await authFetch("/api/targets", {
+    method: "POST",
+    headers: { "Content-Type": "application/json" },
+    body: JSON.stringify({ name, email }),
+  });
+  const data = await res.json().catch(() => ({}));
+  if (!res.ok) throw new Error((data as { error?: string }).error || "Failed to add address");
+  return data as DeliveryTarget;
+}
+
+export async function resendTargetCode(id: string): Promise<void> {
+  const res = await authFetch(`/api/targets/${id}/verification`, { method: "POST" });
+  const data = await res.json().catch(() => ({}));
+  if (!res.ok) throw new Error((data as { error?: string }).error || "Failed to send confirmation code");
+}
+
+export async function confirmTarget(id: string, code: string): Promise<DeliveryTarget> {
+  const res = await authFetch(`/api/targets/${id}/confirm`, {
+    method: "POST",
+    headers: { "Content-Type": "application/json" },
+    body: JSON.stringify({ code }),
+  });
+  const data = await res.json().catch(() => ({}));
+  if (!res.ok) throw new Error((data as { error?: string }).error || "Failed to confirm code");
+  return data as DeliveryTarget;
+}
+
+export async function deleteTarget(id: string): Promise<void> {
+  const res = await authFetch(`/api/targets/${id}`, { method: "DELETE" });
+  if (!res.ok) throw new Error("Failed to remove target");
+}
+
+/** Get the provider page where the user grants access to their drive; it redirects back to Kindle setup. */
+export async function startDriveLink(provider: string): Promise<string> {
+  const res = await authFetch(`/api/targets/oauth/${provider}/start`);
+  const data = await res.json().catch(() => ({}));
+  if (!res.ok) throw new Error((data as { error?: string }).error || "Failed to start linking");
+  return (data as { url: string }).url;
+}
+
+/**
+ * Send a book to a device or delivery target. A device's profile picks the format (converting when the server can)
+ * and where it goes. EPUBs over the device's size limit are sent optimized (fonts removed, images downscaled) unless
+ * optimize is false. Throws with { message, code?: 'KINDLE_CONFIG_REQUIRED' | 'KINDLE_NOT_VERIFIED' |
+ * 'TARGET_NOT_CONFIRMED' | 'SENDER_REQUIRED' | 'FORMAT_UNSUPPORTED' | 'FILE_TOO_LARGE' | 'SEND_LIMIT_REACHED' } on failure.
+ */
+export async function send(
+  bookId: string,
+  to: { deviceId?: string; targetId?: string; optimize?: boolean }
+): Promise<{ message: string; format: string; optimized?: string }> {
+  const res = await authFetch(`/api/books/${bookId}/send`, {
+    method: "POST",
+    headers: { "Content-Type": "application/json" },
+    body: JSON.stringify(to),
+  });
+  const text = await res.text();
+  if (!res.ok) {
+    let data: { error?: string; code?: string } = {};
+    try {
+      data = JSON.parse(text);
+    } catch {}
+    const err = new Error(data.error || "Failed to send") as Error & { code?: string };
+    err.code = data.code;
+    throw err;
+  }
+  const { jobId } = JSON.parse(text) as { jobId: string };
+  return (await waitForJob(jobId, "Failed to send")) as { message: string; format: string; optimized?: string };
+}
+
+export type Device = {
+  id: string;
+  name: string;
+  type: "kindle" | "kobo" | "tablet" | "other";
+  formats: string[];
+  maxAttachmentMB?: number;
+  targetId?: string;
+  optimize?: boolean;
+  createdAt: string;
+};
+
+export type DeviceInput = {
+  name: string;
+  type: Device["type"];
+  formats?: string[];
+  maxAttachmentMB?: number;
+  targetId?: string;
+  optimize?: boolean;
+};
+
+/** List the user's devices, and the formats a device can ask for. */
+export async function getDevices(): Promise<{ devices: Device[]; formats: string[] }> {
+  const res = await authFetch("/api/devices");
+  if (!res.ok) throw new Error("Failed to load devices");
+  return res.json();
+}
+
+/** Add a device. Formats and the attachment limit default to the device type's. */
+export async function addDevice(input: DeviceInput): Promise<Device> {
+  const res = await authFetch("/api/devices", {
+    method: "POST",
+    headers: { "Content-Type": "application/json" },
+    body: JSON.stringify(input),
+  });
+  const data = await res.json().catch(() => ({}));
+  if (!res.ok) throw new Error((data as { error?: string }).error || "Failed to add device");
+  return data as Device;
+}
+
+export async function updateDevice(id: string, input: DeviceInput): Promise<Device> {
+  const res = await authFetch(`/api/devices/${id}`, {
+    method: "PUT",
+    headers: { "Content-Type": "application/json" },
+    body: JSON.stringify(input),
+  });
+  const data = await res.json().catch(() => ({}));
+  if (!res.ok) throw new Error((data as { error?: string }).error || "Failed to save device");
+  return data as Device;
+}
+
+export async function deleteDevice(id: string): Promise<void> {
+  const res = await authFetch(`/api/devices/${id}`, { method: "DELETE" });
+  if (!res.ok) throw new Error("Failed to remove device");
+}
+
+export type Collection = {
+  id: string;
+  name: string;
+  bookIds: string[];
+  createdAt: string;
+  updatedAt: string;
+};
+
+async function collectionRequest<T>(path: string, init: RequestInit, failure: string): Promise<T> {
+  const res = await authFetch(path, { ...init, headers: { "Content-Type": "application/json" } });
+  if (res.status === 204) return undefined as T;
+  const data = await res.json().catch(() => ({}));
+  if (!res.ok) throw new Error((data as { error?: string }).error || failure);
+  return data as T;
+}
+
+/** List the user's collections (shelves like "To Read"), oldest first. */
+export async function getCollections(): Promise<Collection[]> {
+  const { collections } = await collectionRequest<{ collections: Collection[] }>("/api/collections", {}, "Failed to load collections");
+  return collections;
+}
+
+export async function createCollection(name: string, bookIds: string[] = []): Promise<Collection> {
+  return collectionRequest("/api/collections", { method: "POST", body: JSON.stringify({ name, bookIds }) }, "Failed to create collection");
+}
+
+export async function renameCollection(id: string, name: string): Promise<Collection> {
+  return collectionRequest(`/api/collections/${id}`, { method: "PATCH", body: JSON.stringify({ name }) }, "Failed to rename collection");
+}
+
+export async function deleteCollection(id: string): Promise<void> {
+  return collectionRequest(`/api/collections/${id}`, { method: "DELETE" }, "Failed to delete collection");
+}
+
+/** A collection with the books on it, in the order they were added. */
+export async function getCollectionBooks(id: string): Promise<{ collection: Collection; books: Book[] }> {
+  return collectionRequest(`/api/collections/${id}/books`, {}, "Failed to load collection");
+}
+
+export async function addBookToCollection(id: string, bookId: string): Promise<Collection> {
+  return collectionRequest(`/api/collections/${id}/books`, { method: "POST", body: JSON.stringify({ bookId }) }, "Failed to add book to collection");
+}
+
+export async function removeBookFromCollection(id: string, bookId: string): Promise<void> {
+  return collectionRequest(`/api/collections/${id}/books/${bookId}`, { method: "DELETE" }, "Failed to remove book from collection");
+}
+
+/** failedSteps are upload steps that failed after retries; the book is saved and POST /api/books/{id}/retry-upload runs them again. */
+export type UploadResult = { id: string; title: string; noISBNFound?: boolean; failedSteps?: string[] };
+
+export async function uploadBook(file: File): Promise<UploadResult> {
+  if (!getToken()) throw new Error("Not logged in");
+  const form = new FormData();
+  form.append("file", file);
+  const res = await authFetch("/api/upload", { method: "POST", body: form });
   const text = await res.text();
   if (!res.ok) {
     try {
@@ -383,8 +853,134 @@ export async function uploadBook(file: File): Promise<{ id: string; title: strin
     }
   }
   try {
-    return JSON.parse(text) as { id: string; title: string; noISBNFound?: boolean };
+    return JSON.parse(text) as UploadResult;
   } catch {
     throw new Error("Invalid response from server");
   }
 }
+
+export type ReadingProgress = {
+  bookId: string;
+  anchor?: string;
+  kindleLocation?: number;
+  percent: number;
+  device?: string;
+  updatedAt: string;
+  webUrl: string;
+};
+
+/** The user's latest position in a book with a link to resume it, or null if they haven't started it. */
+export async function getContinue(bookId: string): Promise<ReadingProgress | null> {
+  const res = await authFetch(`/api/books/${bookId}/continue`);
+  if (res.status === 404) return null;
+  if (!res.ok) throw new Error("Failed to load reading progress");
+  return res.json();
+}
+
+export type ReadingBook = { book: Book; progress: ReadingProgress };
+
+/** The books the user has started and not finished, most recently read first. */
+export async function getReading(): Promise<ReadingBook[]> {
+  const res = await authFetch("/api/me/reading");
+  if (!res.ok) throw new Error("Failed to load reading list");
+  const data = (await res.json()) as { books: ReadingBook[] };
+  return data.books;
+}
+
+/** Record the user's position in a book: a web reader anchor, a Kindle location, or both. */
+export async function saveProgress(
+  bookId: string,
+  progress: { anchor?: string; kindleLocation?: number; percent: number; device?: string }
+): Promise<ReadingProgress> {
+  const res = await authFetch(`/api/books/${bookId}/progress`, {
+    method: "PUT",
+    headers: { "Content-Type": "application/json" },
+    body: JSON.stringify(progress),
+  });
+  const data = await res.json().catch(() => ({}));
+  if (!res.ok) throw new Error((data as { error?: string }).error || "Failed to save progress");
+  return data as ReadingProgress;
+}
+
+/** Base URL of an EPUB's files and the headers to request them with, for an in-browser reader such as epub.js. */
+export function bookContent(bookId: string): { url: string; requestHeaders: Record<string, string> } {
+  const token = getToken();
+  return {
+    url: `${getApiBaseUrl()}/api/books/${bookId}/content/`,
+    requestHeaders: token ? { Authorization: `Bearer ${token}` } : {},
+  };
+}
+
+export type AudioTrack = {
+  chapter: number;
+  title: string;
+  status: "queued" | "generating" | "ready" | "failed";
+  percent: number;
+  characters: number;
+  voice?: string;
+  sizeBytes?: number;
+  error?: string;
+  url?: string; // signed; plays without sign-in
+};
+
+/** A book's read-aloud tracks, by chapter. */
+export async function getAudio(bookId: string): Promise<{ tracks: AudioTrack[]; ready: number; percent: number }> {
+  const res = await authFetch(`/api/books/${bookId}/audio`);
+  if (!res.ok) throw new Error("Failed to load audio");
+  return res.json();
+}
+
+/** Queue a chapter, or every chapter, of an EPUB to be read aloud (admin, editor). */
+export async function readAloud(bookId: string, chapter?: number, replace = false): Promise<{ tracks: AudioTrack[]; ready: number; percent: number }> {
+  const res = await authFetch(`/api/books/${bookId}/audio`, {
+    method: "POST",
+    headers: { "Content-Type": "application/json" },
+    body: JSON.stringify({ chapter, replace }),
+  });
+  const data = await res.json().catch(() => ({}));
+  if (!res.ok) throw new Error((data as { error?: string }).error || "Failed to read aloud");
+  return data as { tracks: AudioTrack[]; ready: number; percent: number };
+}
+
+/** Ask the classifier for a book's categories now (admin, editor). */
+export async function suggestCategories(bookId: string): Promise<Book> {
+  const res = await authFetch(`/api/books/${bookId}/categories/suggest`, { method: "POST" });
+  const data = await res.json().catch(() => ({}));
+  if (!res.ok) throw new Error((data as { error?: string }).error || "Failed to suggest categories");
+  return data as Book;
+}
+
+/** Make a book's suggested categories, or the given ones, its categories (admin, editor). */
+export async function approveCategories(bookId: string, categories?: string[]): Promise<Book> {
+  const res = await authFetch(`/api/books/${bookId}/categories/approve`, {
+    method: "POST",
+    headers: { "Content-Type": "application/json" },
+    body: JSON.stringify(categories ? { categories } : {}),
+  });
+  const data = await res.json().catch(() => ({}));
+  if (!res.ok) throw new Error((data as { error?: string }).error || "Failed to approve categories");
+  return data as Book;
+}
+
+/** Dismiss a book's suggested categories (admin, editor). */
+export async function dismissCategorySuggestions(bookId: string): Promise<void> {
+  const res = await authFetch(`/api/books/${bookId}/categories/suggestions`, { method: "DELETE" });
+  if (!res.ok) throw new Error("Failed to dismiss suggestions");
+}
+
+export type BookPreview = {
+  bookId: string;
+  chapter?: string;
+  html: string;
+  text: string;
+  words: number;
+  truncated: boolean;
+};
+
+/** The start of a book's first chapter (EPUB only). The HTML is sanitized by the server. Null when unavailable. */
+export async function getPreview(bookId: string): Promise<BookPreview | null> {
+  const res = await authFetch(`/api/books/${bookId}/preview`);
+  if (res.status === 404) return null;
+  if (!res.ok) throw new Error("Failed to load preview");
+  return res.json();
+}